@@ -0,0 +1,137 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper"
+)
+
+// minRefreshInterval avoids refreshing the cache too frequently when the zones are missed.
+const minRefreshInterval = time.Minute
+
+// AvailabilityZoneCache caches the availability zones that can be used to create dedicated ELB instances.
+// It is loaded at startup and refreshed periodically, or when a zone is missed.
+type AvailabilityZoneCache struct {
+	mutex sync.RWMutex
+
+	zones      sets.String
+	updateTime time.Time
+	interval   time.Duration
+
+	listFunc func() ([]string, error)
+}
+
+func NewAvailabilityZoneCache(client *wrapper.DedicatedLoadBalanceClient, interval time.Duration) *AvailabilityZoneCache {
+	if interval < minRefreshInterval {
+		interval = minRefreshInterval
+	}
+	return &AvailabilityZoneCache{
+		zones:    sets.NewString(),
+		interval: interval,
+		listFunc: func() ([]string, error) {
+			list, err := client.ListAvailabilityZones()
+			if err != nil {
+				return nil, err
+			}
+
+			zones := make([]string, 0)
+			for _, group := range list {
+				for _, az := range group {
+					zones = append(zones, az.Code)
+				}
+			}
+			return zones, nil
+		},
+	}
+}
+
+// Run loads the availability zones and refreshes them periodically until stopCh is closed.
+func (c *AvailabilityZoneCache) Run(stopCh <-chan struct{}) {
+	klog.Infof("starting to refresh availability zones every %v", c.interval)
+	wait.Until(func() {
+		if err := c.Refresh(); err != nil {
+			klog.Errorf("failed to refresh availability zones: %s", err)
+		}
+	}, c.interval, stopCh)
+}
+
+// Refresh reloads the availability zones.
+func (c *AvailabilityZoneCache) Refresh() error {
+	zones, err := c.listFunc()
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.zones = sets.NewString(zones...)
+	c.updateTime = time.Now()
+	klog.V(4).Infof("availability zones refreshed: %v", c.zones.List())
+	return nil
+}
+
+// Validate checks whether all the zones are available, the cache will be refreshed once if any of them is missed.
+func (c *AvailabilityZoneCache) Validate(zones []string) error {
+	missing := c.missing(zones)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	c.mutex.RLock()
+	expired := time.Since(c.updateTime) > minRefreshInterval
+	c.mutex.RUnlock()
+	if expired {
+		if err := c.Refresh(); err != nil {
+			return err
+		}
+		missing = c.missing(zones)
+	}
+
+	if len(missing) > 0 {
+		return status.Errorf(codes.InvalidArgument, "availability zones %v are not available, available zones: %v",
+			missing, c.list())
+	}
+	return nil
+}
+
+func (c *AvailabilityZoneCache) missing(zones []string) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	missing := make([]string, 0)
+	for _, z := range zones {
+		if !c.zones.Has(z) {
+			missing = append(missing, z)
+		}
+	}
+	return missing
+}
+
+func (c *AvailabilityZoneCache) list() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.zones.List()
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func newTestAZCache(calls *int, zones *[]string) *AvailabilityZoneCache {
+	return &AvailabilityZoneCache{
+		zones:    sets.NewString(),
+		interval: minRefreshInterval,
+		listFunc: func() ([]string, error) {
+			*calls++
+			if zones == nil {
+				return nil, fmt.Errorf("service unavailable")
+			}
+			return *zones, nil
+		},
+	}
+}
+
+func TestAvailabilityZoneCacheValidate(t *testing.T) {
+	calls := 0
+	zones := []string{"ap-southeast-1a", "ap-southeast-1b"}
+	c := newTestAZCache(&calls, &zones)
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		zones    []string
+		expected codes.Code
+	}{
+		{
+			name:     "all available",
+			zones:    []string{"ap-southeast-1a", "ap-southeast-1b"},
+			expected: codes.OK,
+		},
+		{
+			name:     "one available",
+			zones:    []string{"ap-southeast-1b"},
+			expected: codes.OK,
+		},
+		{
+			name:     "not available",
+			zones:    []string{"ap-southeast-1a", "ap-southeast-1c"},
+			expected: codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := c.Validate(testCase.zones)
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+		})
+	}
+
+	// The cache has just been refreshed, validations should not query the zones again.
+	if calls != 1 {
+		t.Fatalf("expected: 1 call, got: %v", calls)
+	}
+}
+
+func TestAvailabilityZoneCacheRefresh(t *testing.T) {
+	calls := 0
+	zones := []string{"ap-southeast-1a"}
+	c := newTestAZCache(&calls, &zones)
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if err := c.Validate([]string{"ap-southeast-1b"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+
+	zones = []string{"ap-southeast-1a", "ap-southeast-1b"}
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if err := c.Validate([]string{"ap-southeast-1b"}); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	// Refresh on miss when the cache is expired.
+	zones = []string{"ap-southeast-1a", "ap-southeast-1b", "ap-southeast-1c"}
+	c.updateTime = time.Now().Add(-2 * minRefreshInterval)
+	if err := c.Validate([]string{"ap-southeast-1c"}); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected: 3 calls, got: %v", calls)
+	}
+}
+
+func TestAvailabilityZoneCacheRefreshFailed(t *testing.T) {
+	calls := 0
+	c := newTestAZCache(&calls, nil)
+	if err := c.Validate([]string{"ap-southeast-1a"}); err == nil || status.Code(err) == codes.InvalidArgument {
+		t.Fatalf("expected: refresh error, got: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+// deleteTask deletes one resource of an ELB instance, such as a member or a pool. The tasks of a group,
+// such as the resources of a listener, depend on each other.
+type deleteTask struct {
+	group string
+	name  string
+	run   func() error
+}
+
+// runDeleteTiers runs the tiers of the deletes in order, such as the members before the pools before the listeners,
+// a tier is started after all the deletes of the previous tier are done. The deletes in a tier run concurrently,
+// up to concurrency at a time, 0 means no limit. The resources already deleted are tolerated. If a delete fails,
+// the deletes of its group in the following tiers are skipped, the other groups are still deleted.
+func runDeleteTiers(concurrency int, tiers ...[]deleteTask) error {
+	sem := semaphore.NewSemaphore(concurrency)
+	failed := make(map[string]bool)
+	errs := make([]error, 0)
+	for _, tier := range tiers {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		tierFailed := make(map[string]bool)
+		for _, task := range tier {
+			if failed[task.group] {
+				klog.V(4).Infof("Skip deleting %s, the resources it depends on are not deleted", task.name)
+				continue
+			}
+			task := task
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := sem.Acquire(context.Background()); err != nil {
+					return
+				}
+				defer sem.Release()
+
+				err := task.run()
+				if err == nil || common.IsNotFound(err) {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				tierFailed[task.group] = true
+				errs = append(errs, fmt.Errorf("failed to delete %s: %s", task.name, err))
+			}()
+		}
+		wg.Wait()
+		for group := range tierFailed {
+			failed[group] = true
+		}
+	}
+	return errors.NewAggregate(errs)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeDeleter records the deletes of the tiers, and the peak number of the deletes running simultaneously.
+type fakeDeleter struct {
+	mu       sync.Mutex
+	running  int
+	peak     int
+	finished map[string]time.Time
+	started  map[string]time.Time
+	errs     map[string]error
+}
+
+func (f *fakeDeleter) task(group, name string) deleteTask {
+	return deleteTask{group: group, name: name, run: func() error {
+		f.mu.Lock()
+		f.started[name] = time.Now()
+		f.running++
+		if f.running > f.peak {
+			f.peak = f.running
+		}
+		f.mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.running--
+		f.finished[name] = time.Now()
+		return f.errs[name]
+	}}
+}
+
+// newTestTiers returns the tiers of the members, pools and listeners of the listeners.
+func (f *fakeDeleter) newTestTiers(listeners, members int) [][]deleteTask {
+	tiers := make([][]deleteTask, 3)
+	for i := 0; i < listeners; i++ {
+		group := fmt.Sprintf("listener-%d", i)
+		for j := 0; j < members; j++ {
+			tiers[0] = append(tiers[0], f.task(group, fmt.Sprintf("member-%d-%d", i, j)))
+		}
+		tiers[1] = append(tiers[1], f.task(group, fmt.Sprintf("pool-%d", i)))
+		tiers[2] = append(tiers[2], f.task(group, group))
+	}
+	return tiers
+}
+
+func newFakeDeleter(errs map[string]error) *fakeDeleter {
+	return &fakeDeleter{finished: make(map[string]time.Time), started: make(map[string]time.Time), errs: errs}
+}
+
+func TestRunDeleteTiersOrdering(t *testing.T) {
+	f := newFakeDeleter(nil)
+	tiers := f.newTestTiers(3, 4)
+	if err := runDeleteTiers(4, tiers...); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+
+	if len(f.finished) != 18 {
+		t.Fatalf("expected: %d deletes, got: %d", 18, len(f.finished))
+	}
+	// every delete of a tier is started after all the deletes of the previous tier are finished.
+	for i := 1; i < len(tiers); i++ {
+		for _, prev := range tiers[i-1] {
+			for _, task := range tiers[i] {
+				if f.started[task.name].Before(f.finished[prev.name]) {
+					t.Fatalf("expected: %s deleted after %s", task.name, prev.name)
+				}
+			}
+		}
+	}
+	if f.peak < 2 || f.peak > 4 {
+		t.Fatalf("expected: 2 to %d deletes simultaneously, got: %d", 4, f.peak)
+	}
+}
+
+func TestRunDeleteTiersSequential(t *testing.T) {
+	f := newFakeDeleter(nil)
+	if err := runDeleteTiers(1, f.newTestTiers(2, 3)...); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if f.peak != 1 {
+		t.Fatalf("expected: %d delete simultaneously, got: %d", 1, f.peak)
+	}
+}
+
+func TestRunDeleteTiersErrors(t *testing.T) {
+	f := newFakeDeleter(map[string]error{
+		// the member already deleted is tolerated.
+		"member-0-1": status.Error(codes.NotFound, "member not found"),
+		"member-1-0": fmt.Errorf("internal error"),
+	})
+	err := runDeleteTiers(0, f.newTestTiers(3, 2)...)
+	if err == nil {
+		t.Fatalf("expected: the error of member-1-0, got: %v", err)
+	}
+
+	deleted := make([]string, 0)
+	for name := range f.finished {
+		deleted = append(deleted, name)
+	}
+	sort.Strings(deleted)
+	// the pool and the listener of listener-1 are kept, since its member is not deleted.
+	expected := []string{"listener-0", "listener-2", "member-0-0", "member-0-1", "member-1-0", "member-1-1",
+		"member-2-0", "member-2-1", "pool-0", "pool-2"}
+	if fmt.Sprint(deleted) != fmt.Sprint(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, deleted)
+	}
+}
@@ -20,18 +20,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/errors"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
 
 	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
 
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
@@ -45,10 +47,68 @@ const (
 	ElbAvailabilityZones = "kubernetes.io/elb.availability-zones"
 
 	ElbEnableTransparentClientIP = "kubernetes.io/elb.enable-transparent-client-ip"
+	ElbProxyProtocol             = "kubernetes.io/elb.proxy-protocol"
+	ElbTLSCiphersPolicy          = "kubernetes.io/elb.tls-ciphers-policy"
+	// ElbDefaultBackend is the Service port in the format of name:port that the requests of the HTTP/HTTPS listeners
+	// not routed to the service are forwarded to, such as a Service serving 404 pages.
+	ElbDefaultBackend = "kubernetes.io/elb.default-backend"
 )
 
+const (
+	// memberRegistrationModePort registers the members with the subnet of the VPC ports of the ECSs.
+	memberRegistrationModePort = "port"
+)
+
+// portLister lists the VPC ports, such as the ports of an ECS.
+type portLister interface {
+	ListPorts(req *vpcmodel.ListPortsRequest) ([]vpcmodel.Port, error)
+}
+
+// subnetLister lists the subnets, such as the subnets of the VPC.
+type subnetLister interface {
+	ListSubnets(req *vpcmodel.ListSubnetsRequest) ([]vpcmodel.Subnet, error)
+}
+
+const (
+	// The request timeout and the member timeout of the HTTP/HTTPS listeners range from 1 to 300 seconds.
+	minL7Timeout = 1
+	maxL7Timeout = 300
+
+	// The keepalive timeout of the TCP listeners ranges from 10 to 4000 seconds, defaults to 300 seconds.
+	minTCPKeepaliveTimeout     = 10
+	defaultTCPKeepaliveTimeout = 300
+)
+
+// proxyProtocolClient gets and updates the PROXY protocol of the listeners.
+type proxyProtocolClient interface {
+	GetListenerProxyProtocol(id string) (bool, error)
+	UpdateListenerProxyProtocol(id string, enable bool) error
+}
+
+// tlsCiphersPolicies are the security policies supported by the HTTPS listeners of dedicated ELB.
+var tlsCiphersPolicies = []string{
+	"tls-1-0-inherit",
+	"tls-1-0",
+	"tls-1-1",
+	"tls-1-2",
+	"tls-1-2-strict",
+	"tls-1-2-fs",
+	"tls-1-0-with-1-3",
+	"tls-1-2-fs-with-1-3",
+	"hybrid-policy-1-0",
+}
+
 type DedicatedLoadBalancer struct {
 	Basic
+	// replica is the index of the ELB instance among the ones of the availability zone groups of the service,
+	// 0 is the only ELB instance of the service without the groups.
+	replica int
+	// zones are the availability zones of the replica, the annotation of the service is used if empty.
+	zones []string
+}
+
+func (d *DedicatedLoadBalancer) withReconcile(ctx context.Context, correlationID string) cloudprovider.LoadBalancer {
+	return &DedicatedLoadBalancer{Basic: d.Basic.withReconcile(ctx, correlationID)}
 }
 
 func (d *DedicatedLoadBalancer) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (
@@ -63,19 +123,29 @@ func (d *DedicatedLoadBalancer) GetLoadBalancer(ctx context.Context, clusterName
 		return nil, false, err
 	}
 
-	lbStatus := d.buildStatus(loadbalancer)
+	lbStatus := d.buildStatus(service, loadbalancer)
+	groups, err := parseAvailabilityZoneGroups(service)
+	if err != nil || len(groups) < 2 || getStringFromSvsAnnotation(service, ElbID, "") != "" {
+		return lbStatus, true, nil
+	}
+	replicas, _, err := d.newLoadBalancerReplicas(ctx, clusterName, service).list(1, len(groups))
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range replicas {
+		lbStatus.Ingress = append(lbStatus.Ingress, d.buildStatus(service, &replicas[i]).Ingress...)
+	}
 	return lbStatus, true, nil
 }
 
-func (d *DedicatedLoadBalancer) buildStatus(loadbalancer *elbmodel.LoadBalancer) *v1.LoadBalancerStatus {
+func (d *DedicatedLoadBalancer) buildStatus(service *v1.Service, loadbalancer *elbmodel.LoadBalancer,
+) *v1.LoadBalancerStatus {
 	ingressIP := loadbalancer.VipAddress
 	if len(loadbalancer.Eips) > 0 && loadbalancer.Eips[0].EipAddress != nil {
 		ingressIP = *loadbalancer.Eips[0].EipAddress
 	}
 	return &v1.LoadBalancerStatus{
-		Ingress: []v1.LoadBalancerIngress{
-			{IP: ingressIP},
-		},
+		Ingress: buildIngress(service, ingressIP, loadbalancer.VipAddress),
 	}
 }
 
@@ -103,13 +173,32 @@ func (d *DedicatedLoadBalancer) getLoadBalancerInstance(ctx context.Context, clu
 	return &list[0], nil
 }
 
+// ensureDescription updates the description of the ELB instance if it does not match the service,
+// such as the instance is created before the description contains the UID.
+func (d *DedicatedLoadBalancer) ensureDescription(clusterName string, service *v1.Service,
+	loadbalancer *elbmodel.LoadBalancer) error {
+	desc := getLoadBalancerDescription(clusterName, service)
+	if loadbalancer.Description == desc {
+		return nil
+	}
+	klog.InfoS("Updating the description of the ELB", "operation", "UpdateInstance", "serviceName", klog.KObj(service),
+		"loadbalancerID", loadbalancer.Id, "description", desc)
+	if _, err := d.dedicatedELBClient.UpdateInstance(loadbalancer.Id, loadbalancer.Name, desc); err != nil {
+		logLoadBalancerError(err, "Failed to update the description of the ELB", "UpdateInstance", service,
+			loadbalancer.Id)
+		return status.Errorf(codes.Internal, "failed to update the description of the ELB %s: %v", loadbalancer.Id, err)
+	}
+	loadbalancer.Description = desc
+	return nil
+}
+
 func (d *DedicatedLoadBalancer) GetLoadBalancerName(_ context.Context, clusterName string, service *v1.Service) string {
 	klog.Infof("GetLoadBalancerName: called with service %s/%s", service.Namespace, service.Name)
 	if d.loadbalancerOpts.BusinessName != "" {
 		clusterName = d.loadbalancerOpts.BusinessName
 	}
 	name := fmt.Sprintf("k8s_service_%s_%s_%s", clusterName, service.Namespace, service.Name)
-	return utils.CutString(name, defaultMaxNameLength)
+	return getReplicaLoadBalancerName(utils.CutString(name, defaultMaxNameLength), d.replica)
 }
 
 func (d *DedicatedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
@@ -117,12 +206,52 @@ func (d *DedicatedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterN
 		return nil, cloudprovider.ImplementedElsewhere
 	}
 
-	klog.Infof("EnsureLoadBalancer: called with service %s/%s, node: %d", service.Namespace, service.Name, len(nodes))
+	klog.InfoS("Load balancer API is called", "operation", "EnsureLoadBalancer", "serviceName", klog.KObj(service),
+		"nodes", len(nodes))
 
 	if err := ensureLoadBalancerValidation(service, nodes); err != nil {
 		return nil, err
 	}
+	if _, err := parseHealthCheckPort(service); err != nil {
+		return nil, err
+	}
+	if _, err := parseTLSCiphersPolicy(service, ProtocolTerminatedHTTPS); err != nil {
+		return nil, err
+	}
+	for _, port := range service.Spec.Ports {
+		if _, err := parseSniContainerRefs(service, parseProtocol(service, port)); err != nil {
+			return nil, err
+		}
+		if _, _, err := parseL7Timeouts(service, parseProtocol(service, port), d.loadbalancerOpts); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateAvailabilityZoneGroups(service); err != nil {
+		return nil, err
+	}
+
+	if getStringFromSvsAnnotation(service, ElbID, "") != "" {
+		return d.ensureLoadBalancerInstance(ctx, clusterName, service, nodes)
+	}
+	// an ELB instance is created in each availability zone group, and the ones of the groups removed are deleted.
+	groups, _ := parseAvailabilityZoneGroups(service)
+	count := len(groups)
+	if count == 0 {
+		count = 1
+	}
+	return d.newLoadBalancerReplicas(ctx, clusterName, service).ensure(count,
+		func(replica int) (*v1.LoadBalancerStatus, error) {
+			zones := []string(nil)
+			if len(groups) > 0 {
+				zones = groups[replica]
+			}
+			return d.withReplica(replica, zones).ensureLoadBalancerInstance(ctx, clusterName, service, nodes)
+		})
+}
 
+// ensureLoadBalancerInstance creates or updates the ELB instance of the replica, with its listeners and pools.
+func (d *DedicatedLoadBalancer) ensureLoadBalancerInstance(ctx context.Context, clusterName string,
+	service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
 	// get exits or create a new ELB instance
 	loadbalancer, err := d.getLoadBalancerInstance(ctx, clusterName, service)
 	specifiedID := getStringFromSvsAnnotation(service, ElbID, "")
@@ -135,10 +264,21 @@ func (d *DedicatedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterN
 			return nil, e
 		}
 		loadbalancer, err = d.createLoadbalancer(clusterName, subnetID, service)
+	} else if err == nil && specifiedID == "" && d.loadbalancerOpts.EnableRecreation &&
+		isVipSubnetChanged(service, loadbalancer) {
+		loadbalancer, err = d.replaceLoadBalancer(clusterName, service, loadbalancer)
 	}
 	if err != nil {
 		return nil, err
 	}
+	if specifiedID == "" {
+		if err = d.ensureDescription(clusterName, service, loadbalancer); err != nil {
+			return nil, err
+		}
+		if err = d.ensureServiceTags(service, loadbalancer.Id); err != nil {
+			return nil, err
+		}
+	}
 
 	// query ELB listeners list
 	loadbalancerIDs := []string{loadbalancer.Id}
@@ -150,62 +290,226 @@ func (d *DedicatedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterN
 	}
 
 	for _, port := range service.Spec.Ports {
-		listener := d.filterListenerByPort(listeners, service, port)
+		if err = validateProxyProtocol(service, parseProtocol(service, port), d.loadbalancerOpts); err != nil {
+			return nil, err
+		}
+		if _, err = parseInsertHeaders(service, parseProtocol(service, port)); err != nil {
+			return nil, err
+		}
+		if err = validateDefaultBackend(service, parseProtocol(service, port), d.loadbalancerOpts); err != nil {
+			return nil, err
+		}
+	}
+	backend, err := getDefaultBackend(d.kubeClient.Services(service.Namespace), service)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]listenerKey, 0, len(listeners))
+	for _, lis := range listeners {
+		keys = append(keys, listenerKey{
+			ID:          lis.Id,
+			Name:        lis.Name,
+			Description: lis.Description,
+			Protocol:    lis.Protocol,
+			Port:        lis.ProtocolPort,
+		})
+	}
+
+	sharedPools := make(map[string]*elbmodel.Pool)
+	healthChecked := sets.NewString()
+	for _, op := range planListenerOperations(keys, service, specifiedID == "") {
+		if op.action == listenerActionDelete {
+			if err = d.deleteListeners(loadbalancer.Id, filterListenersByID(listeners, op.listenerIDs)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		port := op.port
+		var listener *elbmodel.Listener
 		// add or update listener
-		if listener == nil {
+		if op.action == listenerActionCreate {
 			listener, err = d.createListener(loadbalancer.Id, service, port)
 		} else {
+			listener = &filterListenersByID(listeners, op.listenerIDs)[0]
 			err = d.updateListener(listener, service, port)
 		}
 		if err != nil {
 			return nil, err
 		}
 
-		listeners = d.popListener(listeners, listener.Id)
-
-		// query pool or create pool
-		pool, err := d.getPool(loadbalancer.Id, listener.Id)
-		if err != nil && common.IsNotFound(err) {
-			pool, err = d.createPool(listener, service)
+		if err = d.ensureProxyProtocol(listener, service); err != nil {
+			return nil, err
 		}
+
+		// query pool or create pool, the ports with the same backend targets share one pool
+		pool, reconciled, err := d.ensurePool(loadbalancer.Id, listener, service, port, sharedPools)
 		if err != nil {
 			return nil, err
 		}
 
-		// add new members and remove the obsolete members.
-		if err = d.addOrRemoveMembers(loadbalancer, service, pool, port, nodes); err != nil {
-			return nil, err
+		// split the members into pools by the node label, the default pool keeps the nodes without the label.
+		nodePools, memberNodes := d.planListenerNodePools(listener, nodes)
+		if isNodePoolSupported(listener.Protocol) {
+			if err = d.ensureNodePools(loadbalancer, listener, pool, service, port, nodePools); err != nil {
+				return nil, err
+			}
+			if err = d.ensureDefaultBackend(loadbalancer, listener, pool, service, backend, nodes); err != nil {
+				return nil, err
+			}
+		}
+		if !reconciled {
+			// add new members and remove the obsolete members.
+			if err = d.addOrRemoveMembers(loadbalancer, service, pool, port, memberNodes); err != nil {
+				return nil, err
+			}
 		}
 
-		// add or remove health monitor
-		if err = d.ensureHealthCheck(loadbalancer.Id, pool, port, service, nodes[0]); err != nil {
-			return nil, err
+		// add or remove health monitor, once for each pool shared by the listeners.
+		if !healthChecked.Has(pool.Id) {
+			if err = d.ensureHealthCheck(loadbalancer.Id, pool, port, service, nodes[0]); err != nil {
+				return nil, err
+			}
+			healthChecked.Insert(pool.Id)
 		}
 	}
 
 	if specifiedID == "" {
-		// All remaining listeners are obsolete, delete them
-		err = d.deleteListeners(loadbalancer.Id, listeners)
+		// the status is not switched to a new ELB until it is ready to replace the retired ones.
+		if err = d.finishReplacement(clusterName, service, loadbalancer); err != nil {
+			return nil, err
+		}
+
+		// bind or release the EIP when the service is switched between internal and external
+		loadbalancer, err = d.ensureEIP(loadbalancer, service)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	lbStatus := d.buildStatus(loadbalancer)
+	lbStatus := d.buildStatus(service, loadbalancer)
 	return lbStatus, nil
 }
 
+type eipAction int
+
+const (
+	eipActionNone eipAction = iota
+	eipActionBind
+	eipActionRelease
+)
+
+// getEIPAction returns whether an EIP should be bound to or released from the ELB instance.
+func getEIPAction(service *v1.Service, loadbalancer *elbmodel.LoadBalancer, defaults *config.EIPAutoCreateOption) (
+	eipAction, error) {
+
+	bound := len(loadbalancer.Eips) > 0
+	if isInternalLoadBalancer(service) {
+		if bound {
+			return eipActionRelease, nil
+		}
+		return eipActionNone, nil
+	}
+
+	if bound {
+		return eipActionNone, nil
+	}
+	if getStringFromSvsAnnotation(service, ElbEipID, "") != "" {
+		return eipActionBind, nil
+	}
+	opts, err := parseEIPAutoCreateOptions(service, defaults)
+	if err != nil {
+		return eipActionNone, err
+	}
+	if opts != nil {
+		return eipActionBind, nil
+	}
+	return eipActionNone, nil
+}
+
+func (d *DedicatedLoadBalancer) ensureEIP(loadbalancer *elbmodel.LoadBalancer, service *v1.Service) (
+	*elbmodel.LoadBalancer, error) {
+
+	action, err := getEIPAction(service, loadbalancer, &d.loadbalancerOpts.EIPAutoCreateOption)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case eipActionRelease:
+		eipID := ""
+		if loadbalancer.Eips[0].EipId != nil {
+			eipID = *loadbalancer.Eips[0].EipId
+		}
+		klog.InfoS("Releasing the EIP of the internal ELB", "operation", "UpdateInstance",
+			"serviceName", klog.KObj(service), "loadbalancerID", loadbalancer.Id, "eipID", eipID)
+		if err = unbindEIP(d.eipClient, loadbalancer.VipPortId, eipID, isEIPKept(service, d.loadbalancerOpts)); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to release the EIP of the internal ELB %s: %s",
+				loadbalancer.Id, err)
+		}
+	case eipActionBind:
+		eipID := getStringFromSvsAnnotation(service, ElbEipID, "")
+		if eipID == "" {
+			eipID, err = d.createEIP(service)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to create EIP for the ELB %s: %s",
+					loadbalancer.Id, err)
+			}
+		}
+		klog.InfoS("Binding the EIP to the external ELB", "operation", "UpdateInstance",
+			"serviceName", klog.KObj(service), "loadbalancerID", loadbalancer.Id, "eipID", eipID)
+		if err = d.eipClient.Bind(eipID, loadbalancer.VipPortId); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to bind the EIP %s to the ELB %s: %s",
+				eipID, loadbalancer.Id, err)
+		}
+	default:
+		return loadbalancer, nil
+	}
+
+	return d.dedicatedELBClient.GetInstance(loadbalancer.Id)
+}
+
 func (d *DedicatedLoadBalancer) createLoadbalancer(clusterName, subnetID string, service *v1.Service) (*elbmodel.LoadBalancer, error) {
-	name := d.GetLoadBalancerName(context.TODO(), clusterName, service)
-	desc := fmt.Sprintf("Created by the ELB service(%s/%s) of the k8s cluster(%s).",
-		service.Namespace, service.Name, clusterName)
+	createOpt, err := d.newCreateLoadBalancerOption(clusterName, subnetID, service)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateSubnetAvailabilityZone(d.vpcClient, d.cloudConfig.VpcOpts.ID, subnetID,
+		createOpt.AvailabilityZoneList); err != nil {
+		return nil, err
+	}
 
-	azStr := getStringFromSvsAnnotation(service, ElbAvailabilityZones, "")
-	if azStr == "" {
-		return nil, status.Errorf(codes.InvalidArgument,
-			"Invalid argument, annotation \"kubernetes.io/elb.availability-zones\" cannot be empty")
+	id, err := createDedicatedLoadBalancerOnce(d.dedicatedELBClient, createOpt)
+	if err != nil {
+		logLoadBalancerError(err, "Failed to create the ELB", "CreateInstance", service, "")
+		return nil, err
+	}
+	klog.InfoS("Created the ELB", "operation", "CreateInstance", "serviceName", klog.KObj(service),
+		"loadbalancerID", id)
+	return d.dedicatedELBClient.WaitStatusActive(id)
+}
+
+func (d *DedicatedLoadBalancer) newCreateLoadBalancerOption(clusterName, subnetID string, service *v1.Service,
+) (*elbmodel.CreateLoadBalancerOption, error) {
+	name := d.GetLoadBalancerName(context.TODO(), clusterName, service)
+	desc := getLoadBalancerDescription(clusterName, service)
+
+	availabilityZoneList := d.zones
+	if len(availabilityZoneList) == 0 {
+		azStr := getStringFromSvsAnnotation(service, ElbAvailabilityZones, "")
+		if azStr == "" {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Invalid argument, annotation \"kubernetes.io/elb.availability-zones\" cannot be empty")
+		}
+		availabilityZoneList = strings.Split(azStr, ";")
+	}
+	if err := d.azCache.Validate(availabilityZoneList); err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			return nil, err
+		}
+		klog.Warningf("failed to query availability zones, skip validating them: %s", err)
 	}
-	availabilityZoneList := strings.Split(azStr, ";")
 
 	createOpt := &elbmodel.CreateLoadBalancerOption{
 		Name:                 &name,
@@ -225,9 +529,11 @@ func (d *DedicatedLoadBalancer) createLoadbalancer(clusterName, subnetID string,
 		createOpt.L7FlavorId = &l7FlavorID
 	}
 
-	// eip
+	// eip, the internal ELB only has a private VIP
 	eipID := getStringFromSvsAnnotation(service, ElbEipID, "")
-	if eipID != "" {
+	if isInternalLoadBalancer(service) {
+		klog.Infof("the ELB %s is internal, skip creating EIP", name)
+	} else if eipID != "" {
 		publicIPIDs := []string{eipID}
 		createOpt.PublicipIds = &publicIPIDs
 	} else {
@@ -238,16 +544,46 @@ func (d *DedicatedLoadBalancer) createLoadbalancer(clusterName, subnetID string,
 		}
 		createOpt.Publicip = eipCreateOpts
 	}
+	return createOpt, nil
+}
 
-	loadbalancer, err := d.dedicatedELBClient.CreateInstanceCompleted(createOpt)
+// validateSubnetAvailabilityZone checks that the VIP subnet is in one of the availability zones of the ELB, so that
+// the misconfiguration fails before the ELB is created. A subnet without an availability zone is in all of them,
+// and the validation is skipped if the subnet cannot be found.
+func validateSubnetAvailabilityZone(subnets subnetLister, vpcID, subnetID string, zones []string) error {
+	req := &vpcmodel.ListSubnetsRequest{}
+	if vpcID != "" {
+		req.VpcId = &vpcID
+	}
+	list, err := subnets.ListSubnets(req)
 	if err != nil {
-		return nil, err
+		klog.Warningf("failed to query the subnets, skip validating the availability zone of subnet %s: %s",
+			subnetID, err)
+		return nil
 	}
-	return loadbalancer, nil
+
+	for _, subnet := range list {
+		if subnet.Id != subnetID && subnet.NeutronSubnetId != subnetID {
+			continue
+		}
+		if subnet.AvailabilityZone == "" {
+			return nil
+		}
+		for _, zone := range zones {
+			if zone == subnet.AvailabilityZone {
+				return nil
+			}
+		}
+		return status.Errorf(codes.InvalidArgument, "the VIP subnet %s is in availability zone %s, "+
+			"which is not one of the availability zones of the ELB %v, check the subnet and the annotation %q",
+			subnetID, subnet.AvailabilityZone, zones, ElbAvailabilityZones)
+	}
+	klog.Warningf("subnet %s is not found, skip validating its availability zone", subnetID)
+	return nil
 }
 
 func (d *DedicatedLoadBalancer) parsePublicIP(service *v1.Service) (*elbmodel.CreateLoadBalancerPublicIpOption, error) {
-	eipOpt, err := parseEIPAutoCreateOptions(service)
+	eipOpt, err := parseEIPAutoCreateOptions(service, &d.loadbalancerOpts.EIPAutoCreateOption)
 	if err != nil {
 		return nil, err
 	}
@@ -255,8 +591,12 @@ func (d *DedicatedLoadBalancer) parsePublicIP(service *v1.Service) (*elbmodel.Cr
 	if eipOpt == nil {
 		return nil, nil
 	}
+	// The EIP is created along with the ELB instance, which is created once by createDedicatedLoadBalancerOnce,
+	// the token marks the EIP as createEIPWithToken does.
+	token := getIdempotencyToken(service, operationCreateEIP)
 	publicIP := &elbmodel.CreateLoadBalancerPublicIpOption{
 		NetworkType: eipOpt.IPType,
+		Description: &token,
 	}
 	if eipOpt.BandwidthSize != 0 {
 		shareType := &elbmodel.CreateLoadBalancerBandwidthOptionShareType{}
@@ -290,7 +630,8 @@ func (d *DedicatedLoadBalancer) filterListenerByPort(listeners []elbmodel.Listen
 	port v1.ServicePort) *elbmodel.Listener {
 	protocol := parseProtocol(service, port)
 	for _, listener := range listeners {
-		if listener.Protocol == protocol && listener.ProtocolPort == port.Port {
+		if listener.Protocol == protocol && listener.ProtocolPort == port.Port &&
+			!isListenerOfOtherService(service, listener.Description) {
 			return &listener
 		}
 	}
@@ -301,13 +642,14 @@ func (d *DedicatedLoadBalancer) filterListenerByPort(listeners []elbmodel.Listen
 func (d *DedicatedLoadBalancer) createListener(loadbalancerID string, service *v1.Service, port v1.ServicePort,
 ) (*elbmodel.Listener, error) {
 	xForwardFor := getBoolFromSvsAnnotation(service, ElbXForwardedHost, false)
-	name := utils.CutString(fmt.Sprintf("%s_%s_%v", service.Name, port.Protocol, port.Port), defaultMaxNameLength)
+	name := getListenerName(service, string(port.Protocol), port.Port)
+	description := getListenerDescription(service, port)
 
 	createOpt := &elbmodel.CreateListenerOption{
 		Name:           &name,
+		Description:    &description,
 		LoadbalancerId: loadbalancerID,
 		ProtocolPort:   port.Port,
-		InsertHeaders:  &elbmodel.ListenerInsertHeaders{XForwardedHost: &xForwardFor},
 	}
 
 	protocol := parseProtocol(service, port)
@@ -319,23 +661,39 @@ func (d *DedicatedLoadBalancer) createListener(loadbalancerID string, service *v
 	}
 	createOpt.Protocol = protocol
 
+	insertHeaders, err := parseInsertHeaders(service, protocol)
+	if err != nil {
+		return nil, err
+	}
+	createOpt.InsertHeaders = insertHeaders
+	createOpt.AdminStateUp = getAdminStateUp(service)
+
+	tlsCiphersPolicy, err := parseTLSCiphersPolicy(service, protocol)
+	if err != nil {
+		return nil, err
+	}
+	createOpt.TlsCiphersPolicy = tlsCiphersPolicy
+
+	sniContainerRefs, err := parseSniContainerRefs(service, protocol)
+	if err != nil {
+		return nil, err
+	}
+	createOpt.SniContainerRefs = sniContainerRefs
+
 	transparentClientIPEnable := getBoolFromSvsAnnotation(service, ElbEnableTransparentClientIP,
 		d.loadbalancerOpts.EnableTransparentClientIP)
 	if transparentClientIPEnable {
 		createOpt.TransparentClientIpEnable = &transparentClientIPEnable
 	}
 
-	if timeout := getIntFromSvsAnnotation(service, ElbIdleTimeout, d.loadbalancerOpts.IdleTimeout); timeout != 0 {
-		createOpt.KeepaliveTimeout = pointer.Int32(int32(timeout))
+	createOpt.KeepaliveTimeout, err = parseKeepaliveTimeout(service, protocol, d.loadbalancerOpts)
+	if err != nil {
+		return nil, err
 	}
 
-	if protocol == ProtocolHTTP || protocol == ProtocolTerminatedHTTPS {
-		if timeout := getIntFromSvsAnnotation(service, ElbRequestTimeout, d.loadbalancerOpts.RequestTimeout); timeout != 0 {
-			createOpt.ClientTimeout = pointer.Int32(int32(timeout))
-		}
-		if timeout := getIntFromSvsAnnotation(service, ElbResponseTimeout, d.loadbalancerOpts.ResponseTimeout); timeout != 0 {
-			createOpt.MemberTimeout = pointer.Int32(int32(timeout))
-		}
+	createOpt.ClientTimeout, createOpt.MemberTimeout, err = parseL7Timeouts(service, protocol, d.loadbalancerOpts)
+	if err != nil {
+		return nil, err
 	}
 
 	listener, err := d.dedicatedELBClient.CreateListener(createOpt)
@@ -349,10 +707,12 @@ func (d *DedicatedLoadBalancer) createListener(loadbalancerID string, service *v
 
 func (d *DedicatedLoadBalancer) updateListener(listener *elbmodel.Listener, service *v1.Service, port v1.ServicePort) error {
 	xForwardFor := getBoolFromSvsAnnotation(service, ElbXForwardedHost, false)
-	name := utils.CutString(fmt.Sprintf("%s_%s_%v", service.Name, port.Protocol, port.Port), defaultMaxNameLength)
+	name := getListenerName(service, string(port.Protocol), port.Port)
+	description := getListenerDescription(service, port)
 
 	updateOpts := &elbmodel.UpdateListenerOption{
-		Name: &name,
+		Name:        &name,
+		Description: &description,
 	}
 
 	protocol := parseProtocol(service, port)
@@ -363,9 +723,11 @@ func (d *DedicatedLoadBalancer) updateListener(listener *elbmodel.Listener, serv
 		updateOpts.TransparentClientIpEnable = &transparentClientIPEnable
 	}
 
-	if timeout := getIntFromSvsAnnotation(service, ElbIdleTimeout, d.loadbalancerOpts.IdleTimeout); timeout != 0 {
-		updateOpts.KeepaliveTimeout = pointer.Int32(int32(timeout))
+	keepaliveTimeout, err := parseKeepaliveTimeout(service, protocol, d.loadbalancerOpts)
+	if err != nil {
+		return err
 	}
+	updateOpts.KeepaliveTimeout = keepaliveTimeout
 
 	if protocol == ProtocolTerminatedHTTPS {
 		defaultTLSContainerRef := getStringFromSvsAnnotation(service, DefaultTLSContainerRef, "")
@@ -374,46 +736,313 @@ func (d *DedicatedLoadBalancer) updateListener(listener *elbmodel.Listener, serv
 		protocol = ProtocolHTTP
 	}
 
-	if protocol == ProtocolHTTP || protocol == ProtocolTerminatedHTTPS {
-		if timeout := getIntFromSvsAnnotation(service, ElbRequestTimeout, d.loadbalancerOpts.RequestTimeout); timeout != 0 {
-			updateOpts.ClientTimeout = pointer.Int32(int32(timeout))
-		}
-		if timeout := getIntFromSvsAnnotation(service, ElbResponseTimeout, d.loadbalancerOpts.ResponseTimeout); timeout != 0 {
-			updateOpts.MemberTimeout = pointer.Int32(int32(timeout))
-		}
+	insertHeaders, err := parseInsertHeaders(service, protocol)
+	if err != nil {
+		return err
+	}
+	updateOpts.InsertHeaders = insertHeaders
+	updateOpts.AdminStateUp = getAdminStateUp(service)
+
+	tlsCiphersPolicy, err := parseTLSCiphersPolicy(service, protocol)
+	if err != nil {
+		return err
+	}
+	updateOpts.TlsCiphersPolicy = tlsCiphersPolicy
+
+	sniContainerRefs, err := parseSniContainerRefs(service, protocol)
+	if err != nil {
+		return err
+	}
+	updateOpts.SniContainerRefs = sniContainerRefs
+
+	updateOpts.ClientTimeout, updateOpts.MemberTimeout, err = parseL7Timeouts(service, protocol, d.loadbalancerOpts)
+	if err != nil {
+		return err
 	}
 
 	klog.V(4).Infof("[DEBUG] Update dedicated instance listener options: %s", utils.ToString(updateOpts))
 
-	err := d.dedicatedELBClient.UpdateListener(listener.Id, updateOpts)
+	err = d.dedicatedELBClient.UpdateListener(listener.Id, updateOpts)
 	if err != nil {
 		return err
 	}
+	listener.Name = name
+	listener.Description = description
 
 	klog.Infof("Listener updated, id: %s, name: %s", listener.Id, listener.Name)
 	return nil
 }
 
+// parseTLSCiphersPolicy returns the security policy of the HTTPS listener specified by the annotation,
+// it returns nil if the annotation is not specified or the listener is not an HTTPS listener.
+func parseTLSCiphersPolicy(service *v1.Service, protocol string) (*string, error) {
+	policy := getStringFromSvsAnnotation(service, ElbTLSCiphersPolicy, "")
+	if policy == "" || protocol != ProtocolTerminatedHTTPS {
+		return nil, nil
+	}
+
+	for _, p := range tlsCiphersPolicies {
+		if p == policy {
+			return &policy, nil
+		}
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "invalid value %q of annotation %q, the supported values are: %s",
+		policy, ElbTLSCiphersPolicy, strings.Join(tlsCiphersPolicies, ", "))
+}
+
+// parseSniContainerRefs returns the IDs of the SNI certificates of the HTTPS listener specified by the annotation,
+// the ELB selects the certificate by the SNI of the client, or uses the default certificate if none matches.
+// An empty list is returned for the HTTPS listener without the annotation, so that the certificates removed from
+// the annotation are detached when the listener is updated. It returns nil if the listener is not an HTTPS listener.
+func parseSniContainerRefs(service *v1.Service, protocol string) (*[]string, error) {
+	value := getStringFromSvsAnnotation(service, ElbSniCertIDs, "")
+	if protocol != ProtocolTerminatedHTTPS {
+		if strings.TrimSpace(value) != "" {
+			return nil, status.Errorf(codes.InvalidArgument, "%q is only supported by HTTPS listeners, "+
+				"together with %q", ElbSniCertIDs, DefaultTLSContainerRef)
+		}
+		return nil, nil
+	}
+
+	refs := make([]string, 0)
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" && !utils.IsStrSliceContains(refs, id) {
+			refs = append(refs, id)
+		}
+	}
+	return &refs, nil
+}
+
+// parseL7Timeouts returns the request timeout and the member timeout of the HTTP/HTTPS listener, specified by
+// the annotations or the default options, nil if neither is specified. The annotations are rejected on the
+// TCP/UDP listeners, which do not support the timeouts, while the default options are ignored on them.
+func parseL7Timeouts(service *v1.Service, protocol string, opts *config.LoadBalancerOptions) (*int32, *int32, error) {
+	if protocol != ProtocolHTTP && protocol != ProtocolTerminatedHTTPS {
+		for _, key := range []string{ElbRequestTimeout, ElbMemberTimeout, ElbResponseTimeout} {
+			if _, ok := getAnnotation(service.Annotations, key); ok {
+				return nil, nil, status.Errorf(codes.InvalidArgument, "%q is only supported by HTTP/HTTPS listeners, "+
+					"got: %s", key, protocol)
+			}
+		}
+		return nil, nil, nil
+	}
+
+	clientTimeout, err := parseL7Timeout(service, opts.RequestTimeout, ElbRequestTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	memberTimeout, err := parseL7Timeout(service, opts.ResponseTimeout, ElbMemberTimeout, ElbResponseTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientTimeout, memberTimeout, nil
+}
+
+// parseKeepaliveTimeout returns the keepalive timeout of the listener, which is the idle timeout of the annotation
+// or the default value, nil means that the keepalive timeout of the listener is left unchanged. The keepalive
+// timeout of the TCP listeners is only set if it is configured, so that the value set on the console is kept.
+// ElbTCPKeepalive "true" restores the default keepalive timeout. The ELB does not turn off the keepalive of the TCP
+// listeners, if ElbTCPKeepalive is "false", the idle connections are closed after the minimum keepalive timeout.
+func parseKeepaliveTimeout(service *v1.Service, protocol string, opts *config.LoadBalancerOptions) (*int32, error) {
+	timeout := getIntFromSvsAnnotation(service, ElbIdleTimeout, opts.IdleTimeout)
+	keepalive, keepaliveSet := getAnnotation(service.Annotations, ElbTCPKeepalive)
+	if protocol != ProtocolTCP {
+		if keepaliveSet {
+			return nil, status.Errorf(codes.InvalidArgument, "%q is only supported by TCP listeners, got: %s",
+				ElbTCPKeepalive, protocol)
+		}
+		if timeout == 0 {
+			return nil, nil
+		}
+		return pointer.Int32(int32(timeout)), nil
+	}
+
+	if !getBoolFromSvsAnnotation(service, ElbTCPKeepalive, true) {
+		if _, ok := getAnnotation(service.Annotations, ElbIdleTimeout); ok {
+			return nil, status.Errorf(codes.InvalidArgument, "%q cannot be specified when %q is false",
+				ElbIdleTimeout, ElbTCPKeepalive)
+		}
+		return pointer.Int32(minTCPKeepaliveTimeout), nil
+	}
+	if timeout != 0 {
+		return pointer.Int32(int32(timeout)), nil
+	}
+	if keepaliveSet && strings.TrimSpace(keepalive) != "" {
+		return pointer.Int32(defaultTCPKeepaliveTimeout), nil
+	}
+	return nil, nil
+}
+
+// parseL7Timeout returns the timeout of the first annotation specified in keys, or the default value if none is
+// specified, the default value 0 means that the ELB default is used.
+func parseL7Timeout(service *v1.Service, defaultVal int, keys ...string) (*int32, error) {
+	for _, key := range keys {
+		value, ok := getAnnotation(service.Annotations, key)
+		if !ok {
+			continue
+		}
+		timeout, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || timeout < minL7Timeout || timeout > maxL7Timeout {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid value %q of annotation %q, "+
+				"it must be an integer from %d to %d", value, key, minL7Timeout, maxL7Timeout)
+		}
+		return pointer.Int32(int32(timeout)), nil
+	}
+	if defaultVal == 0 {
+		return nil, nil
+	}
+	return pointer.Int32(int32(defaultVal)), nil
+}
+
+// parseInsertHeaders returns the headers inserted into the requests forwarded to the backend by the listener.
+// The HTTP/HTTPS listeners always insert X-Forwarded-For, the annotation ElbXForwardedFor additionally inserts
+// X-Forwarded-Host, X-Forwarded-Port and X-Forwarded-For-Port, it is rejected on the TCP/UDP listeners.
+func parseInsertHeaders(service *v1.Service, protocol string) (*elbmodel.ListenerInsertHeaders, error) {
+	xForwardedHost := getBoolFromSvsAnnotation(service, ElbXForwardedHost, false)
+	xForwardedFor := getBoolFromSvsAnnotation(service, ElbXForwardedFor, false)
+
+	if protocol != ProtocolHTTP && protocol != ProtocolHTTPS && protocol != ProtocolTerminatedHTTPS {
+		if xForwardedFor {
+			return nil, status.Errorf(codes.InvalidArgument, "%q is only supported by HTTP/HTTPS listeners, got: %s",
+				ElbXForwardedFor, protocol)
+		}
+		return &elbmodel.ListenerInsertHeaders{XForwardedHost: &xForwardedHost}, nil
+	}
+
+	xForwardedHost = xForwardedHost || xForwardedFor
+	return &elbmodel.ListenerInsertHeaders{
+		XForwardedHost:    &xForwardedHost,
+		XForwardedPort:    &xForwardedFor,
+		XForwardedForPort: &xForwardedFor,
+	}, nil
+}
+
+// getAdminStateUp returns the administrative state of the listener, the listener is disabled
+// if the annotation ElbAdminStateUp is "false", so it stops forwarding the requests without being deleted.
+func getAdminStateUp(service *v1.Service) *bool {
+	adminStateUp := getBoolFromSvsAnnotation(service, ElbAdminStateUp, true)
+	return &adminStateUp
+}
+
+// ensureProxyProtocol enables or disables the PROXY protocol of the TCP listener as the annotation specifies.
+// The PROXY protocol set on the console is kept if the annotation is absent, and the listener is updated only if
+// its PROXY protocol differs.
+func (d *DedicatedLoadBalancer) ensureProxyProtocol(listener *elbmodel.Listener, service *v1.Service) error {
+	return ensureProxyProtocol(d.dedicatedELBClient, listener, service)
+}
+
+func ensureProxyProtocol(client proxyProtocolClient, listener *elbmodel.Listener, service *v1.Service) error {
+	// Only the TCP listeners support the PROXY protocol, it is never enabled on the others.
+	if listener.Protocol != ProtocolTCP {
+		return nil
+	}
+
+	if _, ok := getAnnotation(service.Annotations, ElbProxyProtocol); !ok {
+		return nil
+	}
+
+	enable := getBoolFromSvsAnnotation(service, ElbProxyProtocol, false)
+	enabled, err := client.GetListenerProxyProtocol(listener.Id)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to get PROXY protocol of listener %s: %v", listener.Id, err)
+	}
+	if enabled == enable {
+		return nil
+	}
+
+	klog.Infof("Set PROXY protocol of listener %s to %v", listener.Id, enable)
+	if err := client.UpdateListenerProxyProtocol(listener.Id, enable); err != nil {
+		return status.Errorf(codes.Internal, "failed to update PROXY protocol of listener %s: %v", listener.Id, err)
+	}
+	return nil
+}
+
+// validateProxyProtocol checks whether the listener protocol and the backend options support the PROXY protocol.
+func validateProxyProtocol(service *v1.Service, protocol string, opts *config.LoadBalancerOptions) error {
+	if !getBoolFromSvsAnnotation(service, ElbProxyProtocol, false) {
+		return nil
+	}
+
+	if protocol != ProtocolTCP {
+		return status.Errorf(codes.InvalidArgument, "PROXY protocol is only supported by TCP listeners, got: %s",
+			protocol)
+	}
+
+	// Both are used to pass the client IP to the backend, the backend cannot handle them at the same time.
+	if getBoolFromSvsAnnotation(service, ElbEnableTransparentClientIP, opts.EnableTransparentClientIP) {
+		return status.Errorf(codes.InvalidArgument, "PROXY protocol cannot be enabled together with %q",
+			ElbEnableTransparentClientIP)
+	}
+	return nil
+}
+
+// deleteListeners deletes the listeners and their pools, members and health monitors. The resources are deleted tier
+// by tier, the members, the health monitors, the pools and then the listeners, and the deletes of each tier run
+// concurrently, up to "delete-concurrency" at a time.
 func (d *DedicatedLoadBalancer) deleteListeners(elbID string, listeners []elbmodel.Listener) error {
 	errs := make([]error, 0)
+	var members, monitors, pools, listenerTasks []deleteTask
+	deletingPools := sets.NewString()
 	for _, lis := range listeners {
+		lis := lis
+		group := lis.Id
+		if err := d.deleteListenerNodePools(elbID, &lis); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := d.deleteListenerDefaultBackend(elbID, &lis); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
 		pool, err := d.getPool(elbID, lis.Id)
 		if err != nil && !common.IsNotFound(err) {
 			errs = append(errs, err)
 			continue
 		}
-		if err == nil {
-			delErrs := d.deletePool(pool)
-			if len(delErrs) > 0 {
-				errs = append(errs, delErrs...)
+		if err == nil && isPoolShared(pool, listeners) {
+			klog.Infof("Pool %s is still used by other listeners, skip deleting it", pool.Id)
+			// The listener cannot be deleted while it is still associated with the pool.
+			if err = d.dedicatedELBClient.RemoveListenerDefaultPool(lis.Id); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove the default pool of listener %s: %s", lis.Id, err))
+				continue
 			}
+		} else if err == nil && !deletingPools.Has(pool.Id) {
+			poolMembers, err := d.dedicatedELBClient.ListMembers(&elbmodel.ListMembersRequest{PoolId: pool.Id})
+			if err != nil && !common.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to list the members of pool %s: %s", pool.Id, err))
+				continue
+			}
+			// The pool shared by the listeners being deleted is deleted once, the listeners are deleted after it.
+			group = pool.Id
+			deletingPools.Insert(pool.Id)
+			for _, member := range poolMembers {
+				member := member
+				members = append(members, d.newDeleteTask(elbID, group, "member "+member.Id, func() error {
+					return d.dedicatedELBClient.DeleteMember(pool.Id, member.Id)
+				}))
+			}
+			if pool.HealthmonitorId != "" {
+				monitors = append(monitors, d.newDeleteTask(elbID, group, "health monitor "+pool.HealthmonitorId,
+					func() error {
+						return d.dedicatedELBClient.DeleteHealthMonitor(pool.HealthmonitorId)
+					}))
+			}
+			pools = append(pools, d.newDeleteTask(elbID, group, "pool "+pool.Id, func() error {
+				return d.dedicatedELBClient.DeletePool(pool.Id)
+			}))
+		} else if err == nil {
+			group = pool.Id
 		}
-		// delete ELB listener
-		if err = d.dedicatedELBClient.DeleteListener(elbID, lis.Id); err != nil && !common.IsNotFound(err) {
-			errs = append(errs, fmt.Errorf("failed to delete ELB listener %s : %s ", lis.Id, err))
-		}
+		listenerTasks = append(listenerTasks, d.newDeleteTask(elbID, group, "ELB listener "+lis.Id, func() error {
+			return d.dedicatedELBClient.DeleteListener(elbID, lis.Id)
+		}))
 	}
 
+	if err := runDeleteTiers(d.loadbalancerOpts.DeleteConcurrency, members, monitors, pools,
+		listenerTasks); err != nil {
+		errs = append(errs, err)
+	}
 	if len(errs) != 0 {
 		return fmt.Errorf("failed to delete listeners: %s", errors.NewAggregate(errs))
 	}
@@ -421,18 +1050,108 @@ func (d *DedicatedLoadBalancer) deleteListeners(elbID string, listeners []elbmod
 	return nil
 }
 
-func (d *DedicatedLoadBalancer) popListener(listeners []elbmodel.Listener, id string) []elbmodel.Listener {
-	for i, listener := range listeners {
-		if listener.Id == id {
-			listeners[i] = listeners[len(listeners)-1]
-			listeners = listeners[:len(listeners)-1]
-			break
+// newDeleteTask returns the task deleting a resource of the listener or its pool, the delete is retried if the ELB
+// instance is not ready, such as being updated by the other deletes of the tier.
+func (d *DedicatedLoadBalancer) newDeleteTask(elbID, group, name string, del func() error) deleteTask {
+	return deleteTask{group: group, name: name, run: func() error {
+		return d.retryOnNotReady(elbID, del)
+	}}
+}
+
+// deleteOrphanPools deletes the pools of the service that are not associated with any listener, the pools of the
+// other services or created out of the cloud provider in the ELB instance are never deleted.
+func (d *DedicatedLoadBalancer) deleteOrphanPools(elbID string, service *v1.Service) error {
+	loadbalancerIDs := []string{elbID}
+	pools, err := d.dedicatedELBClient.ListPools(&elbmodel.ListPoolsRequest{
+		LoadbalancerId: &loadbalancerIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for _, pool := range filterOrphanPools(pools, service) {
+		klog.Infof("Deleting pool %s, it is not associated with any listener", pool.Id)
+		pool := pool
+		errs = append(errs, d.deletePool(&pool)...)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to delete pools: %s", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// filterOrphanPools returns the pools of the service without any listener.
+func filterOrphanPools(pools []elbmodel.Pool, service *v1.Service) []elbmodel.Pool {
+	orphans := make([]elbmodel.Pool, 0)
+	for _, pool := range pools {
+		if len(pool.Listeners) == 0 && isPoolOwned(pool, service) {
+			orphans = append(orphans, pool)
+		}
+	}
+	return orphans
+}
+
+// isPoolOwned returns true if the pool is created for the service, which is identified by the description of the
+// pool, or by the pool name of a port of the service for the pools created without the description.
+func isPoolOwned(pool elbmodel.Pool, service *v1.Service) bool {
+	if pool.Description == getPoolDescription(service) {
+		return true
+	}
+	for _, port := range service.Spec.Ports {
+		if pool.Name == getPoolName(getListenerName(service, string(port.Protocol), port.Port)) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPoolDescription returns the description of the pools created for the service, such as
+// "k8s_service:default/nginx:", it identifies the service as the prefix of the listener descriptions does.
+func getPoolDescription(service *v1.Service) string {
+	return utils.CutString(getListenerOwner(service), maxListenerDescriptionLength)
+}
+
+// getPoolName returns the name of the pool created for the listener.
+func getPoolName(listenerName string) string {
+	return fmt.Sprintf("pl_%s", listenerName)
+}
+
+func filterListenersByID(listeners []elbmodel.Listener, ids []string) []elbmodel.Listener {
+	rst := make([]elbmodel.Listener, 0, len(ids))
+	for _, id := range ids {
+		for _, lis := range listeners {
+			if lis.Id == id {
+				rst = append(rst, lis)
+				break
+			}
 		}
 	}
-	return listeners
+	return rst
 }
 
-func (d *DedicatedLoadBalancer) createPool(listener *elbmodel.Listener, service *v1.Service) (*elbmodel.Pool, error) {
+func (d *DedicatedLoadBalancer) createPool(loadbalancerID string, listener *elbmodel.Listener, service *v1.Service,
+) (*elbmodel.Pool, error) {
+	createOpt, err := d.newCreatePoolOption(listener, service, getPoolName(listener.Name))
+	if err != nil {
+		return nil, err
+	}
+	// The listener already has a default pool, create the pool in the ELB instance and associate it later.
+	if listener.DefaultPoolId != "" {
+		createOpt.LoadbalancerId = &loadbalancerID
+	} else {
+		createOpt.ListenerId = &listener.Id
+	}
+	var pool *elbmodel.Pool
+	err = d.retryOnNotReady(loadbalancerID, func() error {
+		pool, err = d.dedicatedELBClient.CreatePool(createOpt)
+		return err
+	})
+	return pool, err
+}
+
+func (d *DedicatedLoadBalancer) newCreatePoolOption(listener *elbmodel.Listener, service *v1.Service, name string,
+) (*elbmodel.CreatePoolOption, error) {
 	var sessionPersistence *elbmodel.CreatePoolSessionPersistenceOption
 
 	persistence := d.getSessionAffinity(service)
@@ -449,18 +1168,115 @@ func (d *DedicatedLoadBalancer) createPool(listener *elbmodel.Listener, service
 	}
 
 	lbAlgorithm := getStringFromSvsAnnotation(service, ElbAlgorithm, d.loadbalancerOpts.LBAlgorithm)
-	name := fmt.Sprintf("pl_%s", listener.Name)
 	protocol := listener.Protocol
 	if protocol == ProtocolTerminatedHTTPS {
 		protocol = ProtocolHTTP
 	}
-	return d.dedicatedELBClient.CreatePool(&elbmodel.CreatePoolOption{
+	description := getPoolDescription(service)
+	return &elbmodel.CreatePoolOption{
 		Name:               &name,
+		Description:        &description,
 		Protocol:           protocol,
 		LbAlgorithm:        lbAlgorithm,
-		ListenerId:         &listener.Id,
 		SessionPersistence: sessionPersistence,
+	}, nil
+}
+
+// ensurePool gets or creates the pool of the listener, the listeners with the same backend targets share one pool.
+// sharedPools records the pools that have been reconciled, the second return value is true if the pool of the
+// listener is one of them, then the members do not need to be reconciled again.
+func (d *DedicatedLoadBalancer) ensurePool(loadbalancerID string, listener *elbmodel.Listener, service *v1.Service,
+	port v1.ServicePort, sharedPools map[string]*elbmodel.Pool) (*elbmodel.Pool, bool, error) {
+	key := getPoolKey(service, port, listener.Protocol)
+	sharedPool, ok := sharedPools[key]
+
+	pool, err := d.getPool(loadbalancerID, listener.Id)
+	if err != nil && !common.IsNotFound(err) {
+		return nil, false, err
+	}
+	if err == nil {
+		if ok && sharedPool.Id == pool.Id {
+			return pool, true, nil
+		}
+		// The backend targets of the port have changed, it can no longer share the pool with other ports.
+		if k := getPoolKeyByID(sharedPools, pool.Id); k == "" || k == key {
+			if !ok {
+				sharedPools[key] = pool
+			}
+			return pool, false, nil
+		}
+		klog.Infof("The backend targets of listener %s have changed, it will no longer use the pool %s",
+			listener.Id, pool.Id)
+	}
+
+	if ok {
+		if err = d.associatePool(listener, sharedPool); err != nil {
+			return nil, false, err
+		}
+		return sharedPool, true, nil
+	}
+
+	pool, err = d.createPool(loadbalancerID, listener, service)
+	if err != nil {
+		return nil, false, err
+	}
+	if listener.DefaultPoolId != "" {
+		if err = d.associatePool(listener, pool); err != nil {
+			return nil, false, err
+		}
+	}
+	sharedPools[key] = pool
+	return pool, false, nil
+}
+
+func (d *DedicatedLoadBalancer) associatePool(listener *elbmodel.Listener, pool *elbmodel.Pool) error {
+	klog.Infof("Associate pool %s with listener %s", pool.Id, listener.Id)
+	err := d.dedicatedELBClient.UpdateListener(listener.Id, &elbmodel.UpdateListenerOption{
+		DefaultPoolId: &pool.Id,
 	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to associate pool %s with listener %s: %v",
+			pool.Id, listener.Id, err)
+	}
+	listener.DefaultPoolId = pool.Id
+	return nil
+}
+
+// getPoolKey returns the key of the backend targets of the port, the ports with the same key can share one pool.
+func getPoolKey(service *v1.Service, port v1.ServicePort, protocol string) string {
+	if protocol == ProtocolTerminatedHTTPS {
+		protocol = ProtocolHTTP
+	}
+	if isPodTargeted(service) {
+		return fmt.Sprintf("%s:%s", protocol, port.TargetPort.String())
+	}
+	return fmt.Sprintf("%s:%d", protocol, port.NodePort)
+}
+
+func getPoolKeyByID(sharedPools map[string]*elbmodel.Pool, poolID string) string {
+	for key, pool := range sharedPools {
+		if pool.Id == poolID {
+			return key
+		}
+	}
+	return ""
+}
+
+// isPoolShared returns true if the pool is still used by listeners that are not being deleted.
+func isPoolShared(pool *elbmodel.Pool, deleting []elbmodel.Listener) bool {
+	for _, ref := range pool.Listeners {
+		found := false
+		for _, lis := range deleting {
+			if lis.Id == ref.Id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
 }
 
 func (d *DedicatedLoadBalancer) getPool(elbID, listenerID string) (*elbmodel.Pool, error) {
@@ -501,6 +1317,9 @@ func (d *DedicatedLoadBalancer) deletePool(pool *elbmodel.Pool) []error {
 
 func (d *DedicatedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.LoadBalancer, service *v1.Service,
 	pool *elbmodel.Pool, svcPort v1.ServicePort, nodes []*v1.Node) error {
+	if isENIMembers(service) {
+		return d.addOrRemoveENIMembers(loadbalancer, service, pool, svcPort)
+	}
 
 	members, err := d.dedicatedELBClient.ListMembers(&elbmodel.ListMembersRequest{PoolId: pool.Id})
 	if err != nil {
@@ -516,6 +1335,8 @@ func (d *DedicatedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.LoadBa
 	for _, node := range nodes {
 		nodeNameMapping[node.Name] = node
 	}
+	weights := drainCordonedNodes(getMemberWeights(nodes, d.loadbalancerOpts.MemberWeightResource), nodes,
+		d.loadbalancerOpts.DrainCordonedNodes)
 
 	podList, err := d.listPodsBySelector(context.TODO(), service.Namespace, service.Spec.Selector)
 	if err != nil {
@@ -535,8 +1356,10 @@ func (d *DedicatedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.LoadBa
 
 		node, ok := nodeNameMapping[pod.Spec.NodeName]
 		if !ok {
-			return fmt.Errorf("could not find the node where the Pod resides, Pod: %s/%s",
-				pod.Namespace, pod.Spec.NodeName)
+			// The node is excluded from the load balancers, or it is not ready.
+			klog.V(4).Infof("Pod %s/%s resides on node %s which is not a backend, skipping adding to ELB",
+				pod.Namespace, pod.Name, pod.Spec.NodeName)
+			continue
 		}
 
 		address, portNum, err := d.getMemberIP(service, node, pod, svcPort)
@@ -544,16 +1367,22 @@ func (d *DedicatedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.LoadBa
 			if common.IsNotFound(err) {
 				// Node failure, do not create member
 				klog.Warningf("Failed to create SharedLoadBalancer pool member for node %s: %v", node.Name, err)
+				d.sendWarningEvent("SkipLoadBalancerMember",
+					fmt.Sprintf("Skip adding node %s to the pool %s: %s", node.Name, pool.Name, err), service)
 				continue
 			} else {
 				return fmt.Errorf("error getting address for node %s: %v", node.Name, err)
 			}
 		}
 
-		key := fmt.Sprintf("%s:%d", address, svcPort.NodePort)
+		key := fmt.Sprintf("%s:%d", address, portNum)
 		if existsMember[key] {
 			klog.Infof("[addOrRemoveMembers] node already exists, skip adding, name: %s, address: %s, port: %d",
 				node.Name, address, portNum)
+			if err = d.updateMemberWeight(loadbalancer.Id, pool.Id, members, address, portNum,
+				getMemberWeight(weights, node)); err != nil {
+				return err
+			}
 			members = d.popMember(members, address, portNum)
 			continue
 		}
@@ -561,7 +1390,7 @@ func (d *DedicatedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.LoadBa
 		klog.Infof("[addOrRemoveMembers] add node to pool, name: %s, address: %s, port: %d",
 			node.Name, address, portNum)
 		// Add a member to the pool.
-		if err = d.addMember(service, loadbalancer, pool, pod, svcPort, node); err != nil {
+		if err = d.addMember(service, loadbalancer, pool, pod, svcPort, node, getMemberWeight(weights, node)); err != nil {
 			return err
 		}
 		existsMember[key] = true
@@ -580,7 +1409,8 @@ func (d *DedicatedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.LoadBa
 	return nil
 }
 
-func (d *DedicatedLoadBalancer) addMember(service *v1.Service, loadbalancer *elbmodel.LoadBalancer, pool *elbmodel.Pool, pod v1.Pod, svcPort v1.ServicePort, node *v1.Node) error {
+func (d *DedicatedLoadBalancer) addMember(service *v1.Service, loadbalancer *elbmodel.LoadBalancer, pool *elbmodel.Pool,
+	pod v1.Pod, svcPort v1.ServicePort, node *v1.Node, weight *int32) error {
 	klog.Infof("Add a member(%s) to pool %s", node.Name, pool.Id)
 	address, port, err := d.getMemberIP(service, node, pod, svcPort)
 	if err != nil {
@@ -592,16 +1422,21 @@ func (d *DedicatedLoadBalancer) addMember(service *v1.Service, loadbalancer *elb
 		Name:         &name,
 		ProtocolPort: port,
 		Address:      address,
+		Weight:       weight,
 	}
 	if !loadbalancer.IpTargetEnable {
-		subnetID, err := d.getNodeSubnetIDByHostIP(address)
+		subnetID, err := d.getMemberSubnetID(d.vpcClient, node, address)
 		if err != nil {
 			return err
 		}
 		opt.SubnetCidrId = &subnetID
 	}
 
-	if _, err = d.dedicatedELBClient.AddMember(pool.Id, opt); err != nil {
+	err = d.retryOnNotReady(loadbalancer.Id, func() error {
+		_, err := d.dedicatedELBClient.AddMember(pool.Id, opt)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("error creating SharedLoadBalancer pool member for node: %s, %v", node.Name, err)
 	}
 
@@ -614,46 +1449,84 @@ func (d *DedicatedLoadBalancer) addMember(service *v1.Service, loadbalancer *elb
 	return nil
 }
 
-func (d *DedicatedLoadBalancer) getMemberIP(service *v1.Service, node *v1.Node, pod v1.Pod, svcPort v1.ServicePort) (string, int32, error) {
-	if service.Spec.AllocateLoadBalancerNodePorts != nil && *service.Spec.AllocateLoadBalancerNodePorts {
-		klog.Infof("add member using the Node's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
+// getMemberSubnetID returns the subnet of the member address of the node. In the "port" registration mode,
+// it is the subnet of the VPC port of the ECS that has the address, which is found by the ID of the ECS,
+// so it is not confused by the same IP in other subnets. It falls back to looking up the ECS by the IP
+// if the port is not resolved.
+func (d *DedicatedLoadBalancer) getMemberSubnetID(ports portLister, node *v1.Node, address string) (string, error) {
+	if d.loadbalancerOpts.MemberRegistrationMode != memberRegistrationModePort {
+		return d.getNodeSubnetIDByHostIP(address)
+	}
 
-		address := ""
-		if pod.Status.HostIP != "" {
-			address = pod.Status.HostIP
-		} else {
-			addr, err := getNodeAddress(node)
-			if err != nil {
-				return "", 0, err
+	serverID, err := d.getNodeServerID(node)
+	if err != nil {
+		klog.Warningf("failed to get the ECS of node %s, register the member %s by the IP: %s", node.Name, address, err)
+		return d.getNodeSubnetIDByHostIP(address)
+	}
+	port, subnetID, err := getServerPort(ports, serverID, address)
+	if err != nil {
+		klog.Warningf("failed to resolve the port of node %s, register the member %s by the IP: %s",
+			node.Name, address, err)
+		return d.getNodeSubnetIDByHostIP(address)
+	}
+	klog.Infof("register the member %s of node %s with port %s, subnet: %s", address, node.Name, port.Id, subnetID)
+	return subnetID, nil
+}
+
+// getNodeServerID returns the ID of the ECS of the node, from the provider ID if it is set.
+func (d *DedicatedLoadBalancer) getNodeServerID(node *v1.Node) (string, error) {
+	if node.Spec.ProviderID != "" {
+		_, instanceID, err := parseProviderID(node.Spec.ProviderID)
+		return instanceID, err
+	}
+	instance, err := d.ecsClient.GetByNodeName(node.Name)
+	if err != nil {
+		return "", err
+	}
+	return instance.Id, nil
+}
+
+// getServerPort returns the VPC port of the ECS that has the IP, and the subnet of the IP.
+func getServerPort(client portLister, serverID, address string) (*vpcmodel.Port, string, error) {
+	ports, err := client.ListPorts(&vpcmodel.ListPortsRequest{DeviceId: &serverID})
+	if err != nil {
+		return nil, "", err
+	}
+	for idx := range ports {
+		for _, fixedIP := range ports[idx].FixedIps {
+			if fixedIP.IpAddress != nil && *fixedIP.IpAddress == address && fixedIP.SubnetId != nil {
+				return &ports[idx], *fixedIP.SubnetId, nil
 			}
-			address = addr
 		}
+	}
+	return nil, "", status.Errorf(codes.NotFound, "not found the port of ECS %s with IP %s", serverID, address)
+}
 
-		address, err := d.getPrimaryIP(address)
+func (d *DedicatedLoadBalancer) getMemberIP(service *v1.Service, node *v1.Node, pod v1.Pod, svcPort v1.ServicePort) (string, int32, error) {
+	if isPodTargeted(service) {
+		klog.Infof("add member using the Pod's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
+		return getPodMemberAddress(pod, svcPort)
+	}
+
+	klog.Infof("add member using the Node's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
+	if cidr := getBackendSubnetCIDR(service, node); cidr != "" {
+		address, err := getNodeAddressInCIDR(node, cidr)
 		if err != nil {
 			return "", 0, err
 		}
 		return address, svcPort.NodePort, nil
 	}
 
-	if service.Spec.AllocateLoadBalancerNodePorts != nil && !*service.Spec.AllocateLoadBalancerNodePorts {
-		klog.Infof("add member using the Pod's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-		// get IP and port from Pod
-		if svcPort.TargetPort.Type == intstr.Int {
-			klog.V(6).Infof("targetPort is a number, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-			return pod.Status.PodIP, svcPort.TargetPort.IntVal, nil
-		}
-
-		klog.V(6).Infof("targetPort is a name, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-		for _, c := range pod.Spec.Containers {
-			for _, p := range c.Ports {
-				if p.Name == svcPort.TargetPort.StrVal && string(p.Protocol) == string(svcPort.Protocol) {
-					return pod.Status.PodIP, p.ContainerPort, nil
-				}
-			}
-		}
+	address, err := getNodeMemberAddress(node, pod.Status.HostIP)
+	if err != nil {
+		return "", 0, err
 	}
-	return "", 0, fmt.Errorf("not found member IP and port")
+
+	address, err = d.getPrimaryIP(address)
+	if err != nil {
+		return "", 0, err
+	}
+	return address, svcPort.NodePort, nil
 }
 
 func (d *DedicatedLoadBalancer) deleteMember(elbID string, poolID string, member elbmodel.Member) error {
@@ -671,6 +1544,31 @@ func (d *DedicatedLoadBalancer) deleteMember(elbID string, poolID string, member
 	return nil
 }
 
+// updateMemberWeight updates the weight of the existing member if it differs, nil weight leaves it unchanged.
+func (d *DedicatedLoadBalancer) updateMemberWeight(elbID, poolID string, members []elbmodel.Member, addr string,
+	port int32, weight *int32) error {
+	if weight == nil {
+		return nil
+	}
+	for _, m := range members {
+		if m.Address != addr || m.ProtocolPort != port || m.Weight == *weight {
+			continue
+		}
+		klog.Infof("[addOrRemoveMembers] update the weight of member %s from %d to %d, address: %s, port: %d",
+			m.Id, m.Weight, *weight, addr, port)
+		if _, err := d.dedicatedELBClient.UpdateMember(poolID, m.Id,
+			&elbmodel.UpdateMemberOption{Weight: weight}); err != nil {
+			return fmt.Errorf("error updating the weight of member %s for pool %s: %v", m.Id, poolID, err)
+		}
+		loadbalancer, err := d.dedicatedELBClient.WaitStatusActive(elbID)
+		if err != nil {
+			return fmt.Errorf("timeout when waiting for loadbalancer to be ACTIVE after updating member, "+
+				"current provisioning status %s", loadbalancer.ProvisioningStatus)
+		}
+	}
+	return nil
+}
+
 func (d *DedicatedLoadBalancer) popMember(members []elbmodel.Member, addr string, port int32) []elbmodel.Member {
 	for i, m := range members {
 		if m.Address == addr && m.ProtocolPort == port {
@@ -718,17 +1616,18 @@ func (d *DedicatedLoadBalancer) ensureHealthCheck(loadbalancerID string, pool *e
 	port v1.ServicePort, service *v1.Service, node *v1.Node) error {
 	healthCheckOpts := getHealthCheckOptionFromAnnotation(service, d.loadbalancerOpts)
 	monitorID := pool.HealthmonitorId
-	klog.Infof("add or update or remove health check: %s : %#v", monitorID, healthCheckOpts)
+	klog.V(4).Infof("add or update or remove health check: %s : %#v", monitorID, healthCheckOpts)
 
 	// create health monitor
 	if monitorID == "" && healthCheckOpts.Enable {
-		_, err := d.createHealthMonitor(loadbalancerID, pool.Id, pool.Protocol, healthCheckOpts)
+		_, err := d.createHealthMonitor(loadbalancerID, pool.Id, pool.Protocol, service, port, healthCheckOpts)
 		return err
 	}
 
 	// update health monitor
 	if monitorID != "" && healthCheckOpts.Enable {
-		return d.updateHealthMonitor(monitorID, port.Protocol, healthCheckOpts)
+		return updateDedicatedHealthMonitor(d.dedicatedELBClient, monitorID, port.Protocol, service, port,
+			healthCheckOpts)
 	}
 
 	// delete health monitor
@@ -743,39 +1642,59 @@ func (d *DedicatedLoadBalancer) ensureHealthCheck(loadbalancerID string, pool *e
 	return nil
 }
 
-func (d *DedicatedLoadBalancer) updateHealthMonitor(id string, protocol v1.Protocol, opts *config.HealthCheckOption) error {
+// dedicatedHealthMonitorUpdater updates the health monitors of the dedicated ELB pools.
+type dedicatedHealthMonitorUpdater interface {
+	UpdateHealthMonitor(id string, req *elbmodel.UpdateHealthMonitorOption) error
+}
+
+// updateDedicatedHealthMonitor updates the health monitor in place, including the port and the URL path it checks,
+// such as when the health check node port of the service is changed. Recreating the monitor instead would mark
+// all the members unhealthy until they are checked again.
+func updateDedicatedHealthMonitor(client dedicatedHealthMonitorUpdater, id string, protocol v1.Protocol,
+	service *v1.Service, svcPort v1.ServicePort, opts *config.HealthCheckOption) error {
 	if protocol == ProtocolHTTPS || protocol == ProtocolTerminatedHTTPS {
 		protocol = ProtocolHTTP
 	} else if protocol == ProtocolUDP {
 		protocol = "UDP_CONNECT"
 	}
 
-	monitorProtocol := string(protocol)
 	if protocol == v1.ProtocolSCTP {
 		return status.Errorf(codes.InvalidArgument, "Protocol SCTP not supported")
 	}
 
-	return d.dedicatedELBClient.UpdateHealthMonitor(id, &elbmodel.UpdateHealthMonitorOption{
-		Type:       &monitorProtocol,
-		Timeout:    &opts.Timeout,
-		Delay:      &opts.Delay,
-		MaxRetries: &opts.MaxRetries,
+	target := getHealthMonitorTarget(service, svcPort, string(protocol), opts)
+	return client.UpdateHealthMonitor(id, &elbmodel.UpdateHealthMonitorOption{
+		Type:        &target.protocol,
+		Timeout:     &opts.Timeout,
+		Delay:       &opts.Delay,
+		MaxRetries:  &opts.MaxRetries,
+		MonitorPort: target.portPtr(),
+		UrlPath:     target.pathPtr(),
 	})
 }
 
-func (d *DedicatedLoadBalancer) createHealthMonitor(loadbalancerID, poolID, protocol string, opts *config.HealthCheckOption) (*elbmodel.HealthMonitor, error) {
+func (d *DedicatedLoadBalancer) createHealthMonitor(loadbalancerID, poolID, protocol string, service *v1.Service,
+	svcPort v1.ServicePort, opts *config.HealthCheckOption) (*elbmodel.HealthMonitor, error) {
 	if protocol == ProtocolHTTPS || protocol == ProtocolTerminatedHTTPS {
 		protocol = ProtocolHTTP
 	} else if protocol == ProtocolUDP {
 		protocol = "UDP_CONNECT"
 	}
 
-	monitor, err := d.dedicatedELBClient.CreateHealthMonitor(&elbmodel.CreateHealthMonitorOption{
-		PoolId:     poolID,
-		Type:       protocol,
-		Timeout:    opts.Timeout,
-		Delay:      opts.Delay,
-		MaxRetries: opts.MaxRetries,
+	target := getHealthMonitorTarget(service, svcPort, protocol, opts)
+	var monitor *elbmodel.HealthMonitor
+	err := d.retryOnNotReady(loadbalancerID, func() error {
+		var err error
+		monitor, err = d.dedicatedELBClient.CreateHealthMonitor(&elbmodel.CreateHealthMonitorOption{
+			PoolId:      poolID,
+			Type:        target.protocol,
+			Timeout:     opts.Timeout,
+			Delay:       opts.Delay,
+			MaxRetries:  opts.MaxRetries,
+			MonitorPort: target.portPtr(),
+			UrlPath:     target.pathPtr(),
+		})
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating SharedLoadBalancer pool health monitor: %v", err)
@@ -790,12 +1709,29 @@ func (d *DedicatedLoadBalancer) createHealthMonitor(loadbalancerID, poolID, prot
 }
 
 func (d *DedicatedLoadBalancer) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
-	klog.Infof("UpdateLoadBalancer: called with service %s/%s, node: %d", service.Namespace, service.Name, len(nodes))
+	klog.InfoS("Load balancer API is called", "operation", "UpdateLoadBalancer", "serviceName", klog.KObj(service),
+		"nodes", len(nodes))
 	if !d.isSupportedSvc(service) {
 		return cloudprovider.ImplementedElsewhere
 	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("there are no available nodes for LoadBalancer service %s/%s",
+			service.Namespace, service.Name)
+	}
 
-	// get exits or create a new ELB instance
+	groups, err := parseAvailabilityZoneGroups(service)
+	if err != nil || len(groups) < 2 || getStringFromSvsAnnotation(service, ElbID, "") != "" {
+		return d.updateLoadBalancerInstance(ctx, clusterName, service, nodes)
+	}
+	// the members of all the ELB instances of the availability zone groups are kept the same.
+	return d.newLoadBalancerReplicas(ctx, clusterName, service).update(len(groups), func(replica int) error {
+		return d.withReplica(replica, groups[replica]).updateLoadBalancerInstance(ctx, clusterName, service, nodes)
+	})
+}
+
+// updateLoadBalancerInstance updates the members and the health monitors of the ELB instance of the replica.
+func (d *DedicatedLoadBalancer) updateLoadBalancerInstance(ctx context.Context, clusterName string,
+	service *v1.Service, nodes []*v1.Node) error {
 	loadbalancer, err := d.getLoadBalancerInstance(ctx, clusterName, service)
 	if err != nil {
 		return err
@@ -810,6 +1746,8 @@ func (d *DedicatedLoadBalancer) UpdateLoadBalancer(ctx context.Context, clusterN
 		return err
 	}
 
+	sharedPools := make(map[string]*elbmodel.Pool)
+	healthChecked := sets.NewString()
 	for _, port := range service.Spec.Ports {
 		listener := d.filterListenerByPort(listeners, service, port)
 		if listener == nil {
@@ -817,49 +1755,75 @@ func (d *DedicatedLoadBalancer) UpdateLoadBalancer(ctx context.Context, clusterN
 				port.Protocol, port.Port)
 		}
 
-		// query pool or create pool
-		pool, err := d.getPool(loadbalancer.Id, listener.Id)
-		if err != nil && common.IsNotFound(err) {
-			pool, err = d.createPool(listener, service)
+		if err = validateProxyProtocol(service, listener.Protocol, d.loadbalancerOpts); err != nil {
+			return err
 		}
-		if err != nil {
+		if err = d.ensureProxyProtocol(listener, service); err != nil {
 			return err
 		}
 
-		// add new members and remove the obsolete members.
-		if err = d.addOrRemoveMembers(loadbalancer, service, pool, port, nodes); err != nil {
+		// query pool or create pool, the ports with the same backend targets share one pool
+		pool, reconciled, err := d.ensurePool(loadbalancer.Id, listener, service, port, sharedPools)
+		if err != nil {
 			return err
 		}
+		// split the members into pools by the node label as EnsureLoadBalancer does.
+		nodePools, memberNodes := d.planListenerNodePools(listener, nodes)
+		if isNodePoolSupported(listener.Protocol) {
+			if err = d.ensureNodePools(loadbalancer, listener, pool, service, port, nodePools); err != nil {
+				return err
+			}
+		}
+		if !reconciled {
+			// add new members and remove the obsolete members.
+			if err = d.addOrRemoveMembers(loadbalancer, service, pool, port, memberNodes); err != nil {
+				return err
+			}
+		}
 
-		// add or remove health monitor
-		if err = d.ensureHealthCheck(loadbalancer.Id, pool, port, service, nodes[0]); err != nil {
-			return err
+		// add or remove health monitor, once for each pool shared by the listeners.
+		if !healthChecked.Has(pool.Id) {
+			if err = d.ensureHealthCheck(loadbalancer.Id, pool, port, service, nodes[0]); err != nil {
+				return err
+			}
+			healthChecked.Insert(pool.Id)
 		}
 	}
 	return nil
 }
 
 func (d *DedicatedLoadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
-	klog.Infof("EnsureLoadBalancerDeleted: called with service %s/%s", service.Namespace, service.Name)
-	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	klog.Infof("EnsureLoadBalancerDeleted(%s, %s)", clusterName, serviceName)
+	klog.InfoS("Load balancer API is called", "operation", "EnsureLoadBalancerDeleted",
+		"serviceName", klog.KObj(service), "clusterName", clusterName)
+
+	// the ELB instances of the other availability zone groups are deleted regardless of the current groups,
+	// which may have been reduced or removed.
+	if getStringFromSvsAnnotation(service, ElbID, "") == "" {
+		if err := d.newLoadBalancerReplicas(ctx, clusterName, service).deleteFrom(1); err != nil {
+			return err
+		}
+	}
 
 	loadBalancer, err := d.getLoadBalancerInstance(ctx, clusterName, service)
 	if err != nil {
 		if common.IsNotFound(err) {
-			return nil
+			if getStringFromSvsAnnotation(service, ElbID, "") != "" {
+				return nil
+			}
+			// the ELB replacing the retired ones may not be created
+			return d.deleteRetiredLoadBalancers(clusterName, service)
 		}
 		return err
 	}
-	if err != nil {
-		return err
-	}
 
 	specifiedID := getStringFromSvsAnnotation(service, ElbID, "")
 	if specifiedID != "" {
 		err = d.deleteListener(loadBalancer, service)
 	} else {
 		err = d.deleteELBInstance(loadBalancer, service)
+		if err == nil {
+			err = d.deleteRetiredLoadBalancers(clusterName, service)
+		}
 	}
 
 	if err != nil {
@@ -902,17 +1866,24 @@ func (d *DedicatedLoadBalancer) deleteELBInstance(loadBalancer *elbmodel.LoadBal
 		return err
 	}
 
-	if err = d.deleteListeners(loadBalancer.Id, listenerArr); err != nil {
-		return err
+	keys := make([]listenerKey, 0, len(listenerArr))
+	for _, lis := range listenerArr {
+		keys = append(keys, listenerKey{ID: lis.Id, Name: lis.Name, Description: lis.Description})
+	}
+	if err = checkDeletionProtection(service, keys, d.cloudConfig.AuthOpts.ForceDeleteELB); err != nil {
+		klog.InfoS("Skipped deleting the ELB, only the listeners of the service are deleted",
+			"operation", "DeleteInstance", "serviceName", klog.KObj(service), "loadbalancerID", loadBalancer.Id,
+			"reason", err.Error())
+		return d.deleteListener(loadBalancer, service)
 	}
 
-	if err = d.dedicatedELBClient.DeleteInstance(loadBalancer.Id); err != nil {
+	if err = d.deleteListeners(loadBalancer.Id, listenerArr); err != nil {
 		return err
 	}
 
-	keepEip := getBoolFromSvsAnnotation(service, ELBKeepEip, d.loadbalancerOpts.KeepEIP)
-	if keepEip {
-		return nil
+	// The pools that are not associated with any listener, left by a failed creation or association.
+	if err = d.deleteOrphanPools(loadBalancer.Id, service); err != nil {
+		return err
 	}
 
 	lbEIP := ""
@@ -920,14 +1891,20 @@ func (d *DedicatedLoadBalancer) deleteELBInstance(loadBalancer *elbmodel.LoadBal
 		lbEIP = *loadBalancer.Eips[0].EipId
 	}
 	eipID := getStringFromSvsAnnotation(service, ElbEipID, lbEIP)
-	if eipID == "" {
-		return nil
+	if eipID != "" {
+		// unbind the EIP before releasing it, the EIP specified by the service is only unbound.
+		keepEip := isEIPKept(service, d.loadbalancerOpts)
+		if err = unbindEIP(d.eipClient, loadBalancer.VipPortId, eipID, keepEip); err != nil {
+			return status.Errorf(codes.Internal, "failed to release the EIP %s of the ELB %s: %s",
+				eipID, loadBalancer.Id, err)
+		}
 	}
 
-	klog.Infof("deleting unbind EIP: %v", eipID)
-	if err := d.eipClient.Delete(eipID); err != nil {
-		klog.Errorf("failed to delete EIP: %s", eipID)
+	klog.InfoS("Deleting the ELB", "operation", "DeleteInstance", "serviceName", klog.KObj(service),
+		"loadbalancerID", loadBalancer.Id)
+	if err = d.dedicatedELBClient.DeleteInstance(loadBalancer.Id); err != nil && !common.IsNotFound(err) {
+		logLoadBalancerError(err, "Failed to delete the ELB", "DeleteInstance", service, loadBalancer.Id)
+		return err
 	}
-
 	return nil
 }
@@ -0,0 +1,1139 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+func newTestService(annotations map[string]string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "test",
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestValidateProxyProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		opts        *config.LoadBalancerOptions
+		expected    codes.Code
+	}{
+		{
+			name:        "not specified",
+			annotations: map[string]string{},
+			protocol:    ProtocolHTTP,
+			opts:        &config.LoadBalancerOptions{},
+			expected:    codes.OK,
+		},
+		{
+			name:        "disabled on HTTP listener",
+			annotations: map[string]string{ElbProxyProtocol: "false"},
+			protocol:    ProtocolHTTP,
+			opts:        &config.LoadBalancerOptions{},
+			expected:    codes.OK,
+		},
+		{
+			name:        "enabled on TCP listener",
+			annotations: map[string]string{ElbProxyProtocol: "true"},
+			protocol:    ProtocolTCP,
+			opts:        &config.LoadBalancerOptions{},
+			expected:    codes.OK,
+		},
+		{
+			name:        "enabled on UDP listener",
+			annotations: map[string]string{ElbProxyProtocol: "true"},
+			protocol:    ProtocolUDP,
+			opts:        &config.LoadBalancerOptions{},
+			expected:    codes.InvalidArgument,
+		},
+		{
+			name:        "enabled on HTTP listener",
+			annotations: map[string]string{ElbProxyProtocol: "true"},
+			protocol:    ProtocolHTTP,
+			opts:        &config.LoadBalancerOptions{},
+			expected:    codes.InvalidArgument,
+		},
+		{
+			name: "enabled with transparent client IP annotation",
+			annotations: map[string]string{
+				ElbProxyProtocol:             "true",
+				ElbEnableTransparentClientIP: "true",
+			},
+			protocol: ProtocolTCP,
+			opts:     &config.LoadBalancerOptions{},
+			expected: codes.InvalidArgument,
+		},
+		{
+			name:        "enabled with transparent client IP by default",
+			annotations: map[string]string{ElbProxyProtocol: "true"},
+			protocol:    ProtocolTCP,
+			opts:        &config.LoadBalancerOptions{EnableTransparentClientIP: true},
+			expected:    codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := validateProxyProtocol(newTestService(testCase.annotations), testCase.protocol, testCase.opts)
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+		})
+	}
+}
+
+// fakeProxyProtocolClient records the updates of the PROXY protocol of the listeners.
+type fakeProxyProtocolClient struct {
+	enabled map[string]bool
+	gets    int
+	updates int
+}
+
+func (f *fakeProxyProtocolClient) GetListenerProxyProtocol(id string) (bool, error) {
+	f.gets++
+	return f.enabled[id], nil
+}
+
+func (f *fakeProxyProtocolClient) UpdateListenerProxyProtocol(id string, enable bool) error {
+	f.enabled[id] = enable
+	f.updates++
+	return nil
+}
+
+func TestEnsureProxyProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		enabled     bool
+		expected    bool
+		updates     int
+	}{
+		{
+			name:        "enable",
+			annotations: map[string]string{ElbProxyProtocol: "true"},
+			protocol:    ProtocolTCP,
+			expected:    true,
+			updates:     1,
+		},
+		{
+			name:        "already enabled",
+			annotations: map[string]string{ElbProxyProtocol: "true"},
+			protocol:    ProtocolTCP,
+			enabled:     true,
+			expected:    true,
+		},
+		{
+			name:        "disable",
+			annotations: map[string]string{ElbProxyProtocol: "false"},
+			protocol:    ProtocolTCP,
+			enabled:     true,
+			expected:    false,
+			updates:     1,
+		},
+		{
+			name:     "annotation absent",
+			protocol: ProtocolTCP,
+			enabled:  true,
+			expected: true,
+		},
+		{
+			name:        "HTTP listener",
+			annotations: map[string]string{ElbProxyProtocol: "false"},
+			protocol:    ProtocolHTTP,
+			expected:    false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &fakeProxyProtocolClient{enabled: map[string]bool{"listener-1": testCase.enabled}}
+			listener := &elbmodel.Listener{Id: "listener-1", Protocol: testCase.protocol}
+			if err := ensureProxyProtocol(client, listener, newTestService(testCase.annotations)); err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if client.enabled["listener-1"] != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, client.enabled["listener-1"])
+			}
+			if client.updates != testCase.updates {
+				t.Fatalf("expected: %v updates, got: %v", testCase.updates, client.updates)
+			}
+			if _, ok := testCase.annotations[ElbProxyProtocol]; !ok && client.gets != 0 {
+				t.Fatalf("expected: no gets without the annotation, got: %v", client.gets)
+			}
+		})
+	}
+}
+
+func TestParseKeepaliveTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		opts        *config.LoadBalancerOptions
+		expected    *int32
+		code        codes.Code
+	}{
+		{
+			name:     "TCP listener by default",
+			protocol: ProtocolTCP,
+			opts:     &config.LoadBalancerOptions{},
+		},
+		{
+			name:        "TCP keepalive enabled",
+			annotations: map[string]string{ElbTCPKeepalive: "true"},
+			protocol:    ProtocolTCP,
+			opts:        &config.LoadBalancerOptions{},
+			expected:    pointer.Int32(defaultTCPKeepaliveTimeout),
+		},
+		{
+			name:     "TCP listener with the default idle timeout",
+			protocol: ProtocolTCP,
+			opts:     &config.LoadBalancerOptions{IdleTimeout: 600},
+			expected: pointer.Int32(600),
+		},
+		{
+			name:        "TCP keepalive enabled with the idle timeout",
+			annotations: map[string]string{ElbTCPKeepalive: "true", ElbIdleTimeout: "900"},
+			protocol:    ProtocolTCP,
+			opts:        &config.LoadBalancerOptions{IdleTimeout: 600},
+			expected:    pointer.Int32(900),
+		},
+		{
+			name:        "TCP keepalive disabled",
+			annotations: map[string]string{ElbTCPKeepalive: "false"},
+			protocol:    ProtocolTCP,
+			opts:        &config.LoadBalancerOptions{IdleTimeout: 600},
+			expected:    pointer.Int32(minTCPKeepaliveTimeout),
+		},
+		{
+			name:        "TCP keepalive disabled with the idle timeout",
+			annotations: map[string]string{ElbTCPKeepalive: "false", ElbIdleTimeout: "900"},
+			protocol:    ProtocolTCP,
+			opts:        &config.LoadBalancerOptions{},
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "TCP keepalive on HTTP listener",
+			annotations: map[string]string{ElbTCPKeepalive: "false"},
+			protocol:    ProtocolHTTP,
+			opts:        &config.LoadBalancerOptions{},
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:     "HTTP listener by default",
+			protocol: ProtocolHTTP,
+			opts:     &config.LoadBalancerOptions{},
+		},
+		{
+			name:        "HTTP listener with the idle timeout",
+			annotations: map[string]string{ElbIdleTimeout: "0"},
+			protocol:    ProtocolHTTP,
+			opts:        &config.LoadBalancerOptions{IdleTimeout: 60},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			timeout, err := parseKeepaliveTimeout(newTestService(testCase.annotations), testCase.protocol,
+				testCase.opts)
+			if status.Code(err) != testCase.code {
+				t.Fatalf("expected: %v, got: %v", testCase.code, err)
+			}
+			if !reflect.DeepEqual(timeout, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", pointer.Int32Deref(testCase.expected, -1),
+					pointer.Int32Deref(timeout, -1))
+			}
+		})
+	}
+}
+
+func TestGetPoolKey(t *testing.T) {
+	podService := newTestService(nil)
+	podService.Spec.AllocateLoadBalancerNodePorts = pointer.Bool(false)
+	podService.Spec.Ports = []v1.ServicePort{
+		{Name: "http", Protocol: v1.ProtocolTCP, Port: 80, TargetPort: intstr.FromInt(8080)},
+		{Name: "http-alt", Protocol: v1.ProtocolTCP, Port: 8080, TargetPort: intstr.FromInt(8080)},
+		{Name: "named", Protocol: v1.ProtocolTCP, Port: 81, TargetPort: intstr.FromString("web")},
+		{Name: "named-alt", Protocol: v1.ProtocolTCP, Port: 8081, TargetPort: intstr.FromString("web")},
+		{Name: "metrics", Protocol: v1.ProtocolTCP, Port: 9090, TargetPort: intstr.FromInt(9090)},
+	}
+
+	nodePortService := newTestService(nil)
+	nodePortService.Spec.AllocateLoadBalancerNodePorts = pointer.Bool(true)
+	nodePortService.Spec.Ports = []v1.ServicePort{
+		{Name: "http", Protocol: v1.ProtocolTCP, Port: 80, TargetPort: intstr.FromInt(8080), NodePort: 30080},
+		{Name: "http-alt", Protocol: v1.ProtocolTCP, Port: 8080, TargetPort: intstr.FromInt(8080), NodePort: 30081},
+	}
+
+	tests := []struct {
+		name      string
+		service   *v1.Service
+		port1     v1.ServicePort
+		protocol1 string
+		port2     v1.ServicePort
+		protocol2 string
+		shared    bool
+	}{
+		{
+			name:      "same target port",
+			service:   podService,
+			port1:     podService.Spec.Ports[0],
+			protocol1: ProtocolTCP,
+			port2:     podService.Spec.Ports[1],
+			protocol2: ProtocolTCP,
+			shared:    true,
+		},
+		{
+			name:      "same named target port",
+			service:   podService,
+			port1:     podService.Spec.Ports[2],
+			protocol1: ProtocolTCP,
+			port2:     podService.Spec.Ports[3],
+			protocol2: ProtocolTCP,
+			shared:    true,
+		},
+		{
+			name:      "different target port",
+			service:   podService,
+			port1:     podService.Spec.Ports[0],
+			protocol1: ProtocolTCP,
+			port2:     podService.Spec.Ports[4],
+			protocol2: ProtocolTCP,
+			shared:    false,
+		},
+		{
+			name:      "different listener protocol",
+			service:   podService,
+			port1:     podService.Spec.Ports[0],
+			protocol1: ProtocolTCP,
+			port2:     podService.Spec.Ports[1],
+			protocol2: ProtocolHTTP,
+			shared:    false,
+		},
+		{
+			name:      "HTTP and terminated HTTPS",
+			service:   podService,
+			port1:     podService.Spec.Ports[0],
+			protocol1: ProtocolHTTP,
+			port2:     podService.Spec.Ports[1],
+			protocol2: ProtocolTerminatedHTTPS,
+			shared:    true,
+		},
+		{
+			name:      "different node port",
+			service:   nodePortService,
+			port1:     nodePortService.Spec.Ports[0],
+			protocol1: ProtocolTCP,
+			port2:     nodePortService.Spec.Ports[1],
+			protocol2: ProtocolTCP,
+			shared:    false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			key1 := getPoolKey(testCase.service, testCase.port1, testCase.protocol1)
+			key2 := getPoolKey(testCase.service, testCase.port2, testCase.protocol2)
+			if (key1 == key2) != testCase.shared {
+				t.Fatalf("expected shared: %v, got: %v and %v", testCase.shared, key1, key2)
+			}
+		})
+	}
+}
+
+func TestIsPoolShared(t *testing.T) {
+	pool := &elbmodel.Pool{
+		Id: "pool-1",
+		Listeners: []elbmodel.ListenerRef{
+			{Id: "listener-80"},
+			{Id: "listener-8080"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		pool     *elbmodel.Pool
+		deleting []elbmodel.Listener
+		expected bool
+	}{
+		{
+			name:     "one of the ports is removed",
+			pool:     pool,
+			deleting: []elbmodel.Listener{{Id: "listener-8080"}},
+			expected: true,
+		},
+		{
+			name:     "all ports are removed",
+			pool:     pool,
+			deleting: []elbmodel.Listener{{Id: "listener-8080"}, {Id: "listener-80"}},
+			expected: false,
+		},
+		{
+			name:     "pool is not shared",
+			pool:     &elbmodel.Pool{Id: "pool-2", Listeners: []elbmodel.ListenerRef{{Id: "listener-443"}}},
+			deleting: []elbmodel.Listener{{Id: "listener-443"}},
+			expected: false,
+		},
+		{
+			name:     "pool without listeners",
+			pool:     &elbmodel.Pool{Id: "pool-3"},
+			deleting: []elbmodel.Listener{{Id: "listener-443"}},
+			expected: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			shared := isPoolShared(testCase.pool, testCase.deleting)
+			if shared != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, shared)
+			}
+		})
+	}
+}
+
+func TestFilterOrphanPools(t *testing.T) {
+	service := newTestService(nil)
+	service.Spec.Ports = []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}}
+	owner := getPoolDescription(service)
+
+	tests := []struct {
+		name     string
+		pools    []elbmodel.Pool
+		expected []string
+	}{
+		{
+			name:     "no pools",
+			pools:    []elbmodel.Pool{},
+			expected: []string{},
+		},
+		{
+			name: "pool created but not associated",
+			pools: []elbmodel.Pool{
+				{Id: "pool-1", Description: owner},
+			},
+			expected: []string{"pool-1"},
+		},
+		{
+			name: "pools associated with listeners",
+			pools: []elbmodel.Pool{
+				{Id: "pool-1", Description: owner, Listeners: []elbmodel.ListenerRef{{Id: "listener-80"}}},
+				{Id: "pool-2", Description: owner, Listeners: []elbmodel.ListenerRef{{Id: "listener-443"}}},
+			},
+			expected: []string{},
+		},
+		{
+			name: "mixed",
+			pools: []elbmodel.Pool{
+				{Id: "pool-1", Description: owner, Listeners: []elbmodel.ListenerRef{{Id: "listener-80"}}},
+				{Id: "pool-2", Description: owner, Listeners: []elbmodel.ListenerRef{}},
+				{Id: "pool-3", Description: owner},
+			},
+			expected: []string{"pool-2", "pool-3"},
+		},
+		{
+			name: "pool created without the description",
+			pools: []elbmodel.Pool{
+				{Id: "pool-1", Name: "pl_test_TCP_80"},
+				{Id: "pool-2", Name: "pl_test_TCP_8080"},
+			},
+			expected: []string{"pool-1"},
+		},
+		{
+			name: "pools not owned by the service",
+			pools: []elbmodel.Pool{
+				{Id: "pool-1", Name: "manual"},
+				{Id: "pool-2", Description: "k8s_service:default/test-2:"},
+				{Id: "pool-3", Description: "k8s_service:other/test:", Name: "pl_other_TCP_80"},
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			orphans := filterOrphanPools(testCase.pools, service)
+			ids := make([]string, 0, len(orphans))
+			for _, p := range orphans {
+				ids = append(ids, p.Id)
+			}
+			if !reflect.DeepEqual(ids, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, ids)
+			}
+		})
+	}
+}
+
+func TestCheckDeletionProtection(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners []listenerKey
+		force     bool
+		expected  codes.Code
+	}{
+		{
+			name:      "no listeners",
+			listeners: []listenerKey{},
+			expected:  codes.OK,
+		},
+		{
+			name:      "only listeners of the service",
+			listeners: []listenerKey{{Name: "test_TCP_80"}, {Name: "test_TCP_443"}},
+			expected:  codes.OK,
+		},
+		{
+			name:      "protected by foreign listeners",
+			listeners: []listenerKey{{Name: "test_TCP_80"}, {Name: "manual-listener"}},
+			expected:  codes.FailedPrecondition,
+		},
+		{
+			name:      "protected by listeners of another service",
+			listeners: []listenerKey{{Name: "test-2_TCP_80"}},
+			expected:  codes.FailedPrecondition,
+		},
+		{
+			name: "listeners of the service identified by the description",
+			listeners: []listenerKey{
+				{Name: "renamed", Description: "k8s_service:default/test:80"},
+				{Name: "test_TCP_443"},
+			},
+			expected: codes.OK,
+		},
+		{
+			name:      "protected by listeners of a port not in the service",
+			listeners: []listenerKey{{Name: "test_TCP_8080"}},
+			expected:  codes.FailedPrecondition,
+		},
+		{
+			name:      "protected by listeners of another namespace",
+			listeners: []listenerKey{{Name: "test_TCP_80", Description: "k8s_service:other/test:80"}},
+			expected:  codes.FailedPrecondition,
+		},
+		{
+			name:      "force delete",
+			listeners: []listenerKey{{Name: "test_TCP_80"}, {Name: "manual-listener"}},
+			force:     true,
+			expected:  codes.OK,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := newTestService(nil)
+			service.Spec.Ports = []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}, {Protocol: v1.ProtocolTCP, Port: 443}}
+			err := checkDeletionProtection(service, testCase.listeners, testCase.force)
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+		})
+	}
+}
+
+func TestGetEIPAction(t *testing.T) {
+	withEIP := &elbmodel.LoadBalancer{
+		Id:         "elb-1",
+		VipAddress: "192.168.0.100",
+		Eips:       []elbmodel.EipInfo{{EipId: pointer.String("eip-1"), EipAddress: pointer.String("100.85.10.10")}},
+	}
+	withoutEIP := &elbmodel.LoadBalancer{Id: "elb-1", VipAddress: "192.168.0.100"}
+	autoCreate := `{"bandwidth_size": 5}`
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		loadbalancer *elbmodel.LoadBalancer
+		expected     eipAction
+	}{
+		{
+			name:         "internal only",
+			annotations:  map[string]string{ElbInternal: "true", AutoCreateEipOptions: autoCreate},
+			loadbalancer: withoutEIP,
+			expected:     eipActionNone,
+		},
+		{
+			name:         "internal by the beta annotation",
+			annotations:  map[string]string{ElbInternalBeta: "true"},
+			loadbalancer: withEIP,
+			expected:     eipActionRelease,
+		},
+		{
+			name:         "external to internal",
+			annotations:  map[string]string{ElbInternal: "true"},
+			loadbalancer: withEIP,
+			expected:     eipActionRelease,
+		},
+		{
+			name:         "external with EIP bound",
+			annotations:  map[string]string{AutoCreateEipOptions: autoCreate},
+			loadbalancer: withEIP,
+			expected:     eipActionNone,
+		},
+		{
+			name:         "internal to external with auto-created EIP",
+			annotations:  map[string]string{ElbInternal: "false", AutoCreateEipOptions: autoCreate},
+			loadbalancer: withoutEIP,
+			expected:     eipActionBind,
+		},
+		{
+			name:         "internal to external with specified EIP",
+			annotations:  map[string]string{ElbEipID: "eip-2"},
+			loadbalancer: withoutEIP,
+			expected:     eipActionBind,
+		},
+		{
+			name:         "external without EIP options",
+			annotations:  map[string]string{},
+			loadbalancer: withoutEIP,
+			expected:     eipActionNone,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			action, err := getEIPAction(newTestService(testCase.annotations), testCase.loadbalancer,
+				&config.EIPAutoCreateOption{ShareType: "PER", ChargeMode: "traffic"})
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if action != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, action)
+			}
+		})
+	}
+}
+
+func TestBuildStatus(t *testing.T) {
+	d := &DedicatedLoadBalancer{}
+	service := newTestService(nil)
+
+	// the external ELB reports both the EIP and the private VIP.
+	external := &elbmodel.LoadBalancer{
+		VipAddress: "192.168.0.10",
+		Eips:       []elbmodel.EipInfo{{EipAddress: pointer.String("100.85.0.10")}},
+	}
+	expected := []v1.LoadBalancerIngress{{IP: "100.85.0.10"}, {IP: "192.168.0.10"}}
+	if lbStatus := d.buildStatus(service, external); !reflect.DeepEqual(lbStatus.Ingress, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, lbStatus.Ingress)
+	}
+
+	internal := &elbmodel.LoadBalancer{VipAddress: "192.168.0.10"}
+	expected = []v1.LoadBalancerIngress{{IP: "192.168.0.10"}}
+	if lbStatus := d.buildStatus(service, internal); !reflect.DeepEqual(lbStatus.Ingress, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, lbStatus.Ingress)
+	}
+}
+
+func TestParseTLSCiphersPolicy(t *testing.T) {
+	for _, policy := range tlsCiphersPolicies {
+		t.Run(policy, func(t *testing.T) {
+			service := newTestService(map[string]string{ElbTLSCiphersPolicy: policy})
+			got, err := parseTLSCiphersPolicy(service, ProtocolTerminatedHTTPS)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got == nil || *got != policy {
+				t.Fatalf("expected: %v, got: %v", policy, got)
+			}
+		})
+	}
+}
+
+func TestParseTLSCiphersPolicyInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		expected    codes.Code
+	}{
+		{
+			name:        "not specified",
+			annotations: map[string]string{},
+			protocol:    ProtocolTerminatedHTTPS,
+			expected:    codes.OK,
+		},
+		{
+			name:        "not an HTTPS listener",
+			annotations: map[string]string{ElbTLSCiphersPolicy: "tls-1-2"},
+			protocol:    ProtocolTCP,
+			expected:    codes.OK,
+		},
+		{
+			name:        "unknown policy",
+			annotations: map[string]string{ElbTLSCiphersPolicy: "tls-1-3"},
+			protocol:    ProtocolTerminatedHTTPS,
+			expected:    codes.InvalidArgument,
+		},
+		{
+			name:        "case sensitive",
+			annotations: map[string]string{ElbTLSCiphersPolicy: "TLS-1-2"},
+			protocol:    ProtocolTerminatedHTTPS,
+			expected:    codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, err := parseTLSCiphersPolicy(newTestService(testCase.annotations), testCase.protocol)
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+			if got != nil {
+				t.Fatalf("expected: nil, got: %v", *got)
+			}
+			if err != nil && !strings.Contains(err.Error(), "tls-1-2-strict") {
+				t.Fatalf("expected the supported policies in the error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSniContainerRefs(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		expected    *[]string
+		code        codes.Code
+	}{
+		{
+			name:        "certificates",
+			annotations: map[string]string{ElbSniCertIDs: "cert-1, cert-2,,cert-1"},
+			protocol:    ProtocolTerminatedHTTPS,
+			expected:    &[]string{"cert-1", "cert-2"},
+		},
+		{
+			name:        "removed",
+			annotations: map[string]string{},
+			protocol:    ProtocolTerminatedHTTPS,
+			expected:    &[]string{},
+		},
+		{
+			name:        "not an HTTPS listener",
+			annotations: map[string]string{},
+			protocol:    ProtocolTCP,
+		},
+		{
+			name:        "without the default certificate",
+			annotations: map[string]string{ElbSniCertIDs: "cert-1"},
+			protocol:    ProtocolHTTP,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, err := parseSniContainerRefs(newTestService(testCase.annotations), testCase.protocol)
+			if status.Code(err) != testCase.code {
+				t.Fatalf("expected: %v, got: %v", testCase.code, err)
+			}
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpdateListenerOptionSniContainerRefs(t *testing.T) {
+	// the certificates removed from the annotation are detached by an empty list, rather than left unchanged.
+	for _, annotations := range []map[string]string{
+		{DefaultTLSContainerRef: "cert-0", ElbSniCertIDs: "cert-1,cert-2"},
+		{DefaultTLSContainerRef: "cert-0"},
+	} {
+		service := newTestService(annotations)
+		refs, err := parseSniContainerRefs(service, parseProtocol(service, v1.ServicePort{Port: 443}))
+		if err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+		got := elbmodel.UpdateListenerOption{SniContainerRefs: refs}.String()
+		expected := fmt.Sprintf(`"sni_container_refs":["%s"]`, strings.Join(*refs, `","`))
+		if len(*refs) == 0 {
+			expected = `"sni_container_refs":[]`
+		}
+		if !strings.Contains(got, expected) {
+			t.Fatalf("expected: %v, got: %v", expected, got)
+		}
+	}
+}
+
+func TestParseL7Timeouts(t *testing.T) {
+	opts := &config.LoadBalancerOptions{RequestTimeout: 60, ResponseTimeout: 60}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		opts        *config.LoadBalancerOptions
+		client      *int32
+		member      *int32
+		code        codes.Code
+	}{
+		{
+			name:        "annotations",
+			annotations: map[string]string{ElbRequestTimeout: "120", ElbMemberTimeout: "300"},
+			protocol:    ProtocolHTTP,
+			opts:        opts,
+			client:      pointer.Int32(120),
+			member:      pointer.Int32(300),
+		},
+		{
+			name:        "member timeout takes precedence",
+			annotations: map[string]string{ElbMemberTimeout: "200", ElbResponseTimeout: "100"},
+			protocol:    ProtocolTerminatedHTTPS,
+			opts:        opts,
+			client:      pointer.Int32(60),
+			member:      pointer.Int32(200),
+		},
+		{
+			name:        "response timeout",
+			annotations: map[string]string{ElbResponseTimeout: "100"},
+			protocol:    ProtocolHTTP,
+			opts:        opts,
+			client:      pointer.Int32(60),
+			member:      pointer.Int32(100),
+		},
+		{
+			name:        "ELB defaults",
+			annotations: map[string]string{},
+			protocol:    ProtocolHTTP,
+			opts:        &config.LoadBalancerOptions{},
+		},
+		{
+			name:        "out of range",
+			annotations: map[string]string{ElbMemberTimeout: "301"},
+			protocol:    ProtocolHTTP,
+			opts:        opts,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "not an integer",
+			annotations: map[string]string{ElbRequestTimeout: "1m"},
+			protocol:    ProtocolHTTP,
+			opts:        opts,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "defaults ignored on TCP listeners",
+			annotations: map[string]string{},
+			protocol:    ProtocolTCP,
+			opts:        opts,
+		},
+		{
+			name:        "rejected on UDP listeners",
+			annotations: map[string]string{ElbMemberTimeout: "100"},
+			protocol:    ProtocolUDP,
+			opts:        opts,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, member, err := parseL7Timeouts(newTestService(testCase.annotations), testCase.protocol, testCase.opts)
+			if status.Code(err) != testCase.code {
+				t.Fatalf("expected: %v, got: %v", testCase.code, err)
+			}
+			if !reflect.DeepEqual(client, testCase.client) || !reflect.DeepEqual(member, testCase.member) {
+				t.Fatalf("expected: %v, %v, got: %v, %v", testCase.client, testCase.member, client, member)
+			}
+		})
+	}
+}
+
+func TestParseInsertHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		expected    *elbmodel.ListenerInsertHeaders
+		code        codes.Code
+	}{
+		{
+			name:        "not specified",
+			annotations: map[string]string{},
+			protocol:    ProtocolHTTP,
+			expected: &elbmodel.ListenerInsertHeaders{
+				XForwardedHost:    pointer.Bool(false),
+				XForwardedPort:    pointer.Bool(false),
+				XForwardedForPort: pointer.Bool(false),
+			},
+		},
+		{
+			name:        "x-forwarded-for on HTTP",
+			annotations: map[string]string{ElbXForwardedFor: "true"},
+			protocol:    ProtocolHTTP,
+			expected: &elbmodel.ListenerInsertHeaders{
+				XForwardedHost:    pointer.Bool(true),
+				XForwardedPort:    pointer.Bool(true),
+				XForwardedForPort: pointer.Bool(true),
+			},
+		},
+		{
+			name:        "x-forwarded-for on HTTPS",
+			annotations: map[string]string{ElbXForwardedFor: "true"},
+			protocol:    ProtocolTerminatedHTTPS,
+			expected: &elbmodel.ListenerInsertHeaders{
+				XForwardedHost:    pointer.Bool(true),
+				XForwardedPort:    pointer.Bool(true),
+				XForwardedForPort: pointer.Bool(true),
+			},
+		},
+		{
+			name:        "x-forwarded-host only",
+			annotations: map[string]string{ElbXForwardedHost: "true"},
+			protocol:    ProtocolHTTP,
+			expected: &elbmodel.ListenerInsertHeaders{
+				XForwardedHost:    pointer.Bool(true),
+				XForwardedPort:    pointer.Bool(false),
+				XForwardedForPort: pointer.Bool(false),
+			},
+		},
+		{
+			name:        "TCP listener",
+			annotations: map[string]string{},
+			protocol:    ProtocolTCP,
+			expected:    &elbmodel.ListenerInsertHeaders{XForwardedHost: pointer.Bool(false)},
+		},
+		{
+			name:        "x-forwarded-for on TCP",
+			annotations: map[string]string{ElbXForwardedFor: "true"},
+			protocol:    ProtocolTCP,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "x-forwarded-for on UDP",
+			annotations: map[string]string{ElbXForwardedFor: "true"},
+			protocol:    ProtocolUDP,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, err := parseInsertHeaders(newTestService(testCase.annotations), testCase.protocol)
+			if status.Code(err) != testCase.code {
+				t.Fatalf("expected: %v, got: %v", testCase.code, err)
+			}
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetAdminStateUp(t *testing.T) {
+	// the listeners are disabled and re-enabled by changing the annotation, the default is enabled.
+	steps := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{name: "not specified", annotations: map[string]string{}, expected: true},
+		{name: "disabled", annotations: map[string]string{ElbAdminStateUp: "false"}, expected: false},
+		{name: "re-enabled", annotations: map[string]string{ElbAdminStateUp: "true"}, expected: true},
+		{name: "disabled again", annotations: map[string]string{ElbAdminStateUp: "false"}, expected: false},
+		{name: "annotation removed", annotations: map[string]string{}, expected: true},
+		{name: "invalid value", annotations: map[string]string{ElbAdminStateUp: "off"}, expected: true},
+	}
+
+	for _, te := range steps {
+		t.Run(te.name, func(t *testing.T) {
+			got := getAdminStateUp(newTestService(te.annotations))
+			if got == nil || *got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewCreateLoadBalancerOptionDescription(t *testing.T) {
+	d := &DedicatedLoadBalancer{Basic: Basic{
+		loadbalancerOpts: &config.LoadBalancerOptions{},
+		azCache:          &AvailabilityZoneCache{zones: sets.NewString("az-1")},
+	}}
+	service := newTestService(map[string]string{
+		ElbAvailabilityZones: "az-1",
+		ElbEipID:             "eip-1",
+	})
+	service.UID = "2f9a6c1e-0d4b-4a55-9b8e-3c1d2e4f5a6b"
+
+	createOpt, err := d.newCreateLoadBalancerOption("kubernetes", "subnet-1", service)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	expected := getLoadBalancerDescription("kubernetes", service)
+	if createOpt.Description == nil || *createOpt.Description != expected {
+		t.Fatalf("expected: %v, got: %v", expected, pointer.StringDeref(createOpt.Description, ""))
+	}
+	if !strings.Contains(expected, "service(default/test)") || !strings.Contains(expected, string(service.UID)) {
+		t.Fatalf("expected the description to identify the service, got: %v", expected)
+	}
+}
+
+// fakePortLister returns the ports of the ECSs by the device ID.
+type fakePortLister struct {
+	ports map[string][]vpcmodel.Port
+}
+
+func (f *fakePortLister) ListPorts(req *vpcmodel.ListPortsRequest) ([]vpcmodel.Port, error) {
+	if req.DeviceId == nil {
+		return nil, fmt.Errorf("the ports are not listed by the device ID")
+	}
+	return f.ports[*req.DeviceId], nil
+}
+
+func newTestPort(id, address, subnetID string) vpcmodel.Port {
+	return vpcmodel.Port{Id: id, FixedIps: []vpcmodel.FixedIp{{IpAddress: &address, SubnetId: &subnetID}}}
+}
+
+func TestGetMemberSubnetIDByPort(t *testing.T) {
+	// the ECSs in different subnets have the same IP.
+	ports := &fakePortLister{ports: map[string][]vpcmodel.Port{
+		"server-a": {newTestPort("port-a", "192.168.0.10", "subnet-a")},
+		"server-b": {
+			newTestPort("port-b0", "192.168.1.10", "subnet-b0"),
+			newTestPort("port-b", "192.168.0.10", "subnet-b"),
+		},
+	}}
+	d := &DedicatedLoadBalancer{Basic: Basic{
+		loadbalancerOpts: &config.LoadBalancerOptions{MemberRegistrationMode: memberRegistrationModePort},
+	}}
+
+	tests := []struct {
+		name       string
+		providerID string
+		address    string
+		expected   string
+	}{
+		{
+			name:       "server A",
+			providerID: providerIDPrefix + "server-a",
+			address:    "192.168.0.10",
+			expected:   "subnet-a",
+		},
+		{
+			name:       "server B",
+			providerID: providerIDPrefix + "server-b",
+			address:    "192.168.0.10",
+			expected:   "subnet-b",
+		},
+		{
+			name:       "secondary port of server B",
+			providerID: providerIDPrefix + "server-b",
+			address:    "192.168.1.10",
+			expected:   "subnet-b0",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			node := newTestNode(nil)
+			node.Spec.ProviderID = te.providerID
+			subnetID, err := d.getMemberSubnetID(ports, node, te.address)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if subnetID != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, subnetID)
+			}
+		})
+	}
+}
+
+func TestGetServerPort(t *testing.T) {
+	ports := &fakePortLister{ports: map[string][]vpcmodel.Port{
+		"server-a": {newTestPort("port-a", "192.168.0.10", "subnet-a")},
+	}}
+
+	port, subnetID, err := getServerPort(ports, "server-a", "192.168.0.10")
+	if err != nil || port.Id != "port-a" || subnetID != "subnet-a" {
+		t.Fatalf("expected: port-a in subnet-a, got: %v, %v, %v", port, subnetID, err)
+	}
+	// the IP of another ECS is not resolved, so the member falls back to the registration by the IP.
+	if _, _, err = getServerPort(ports, "server-b", "192.168.0.10"); !common.IsNotFound(err) {
+		t.Fatalf("expected: a not found error, got: %v", err)
+	}
+}
+
+// fakeSubnetLister returns the subnets, or the error if set.
+type fakeSubnetLister struct {
+	subnets []vpcmodel.Subnet
+	err     error
+}
+
+func (f *fakeSubnetLister) ListSubnets(_ *vpcmodel.ListSubnetsRequest) ([]vpcmodel.Subnet, error) {
+	return f.subnets, f.err
+}
+
+func TestValidateSubnetAvailabilityZone(t *testing.T) {
+	subnets := &fakeSubnetLister{subnets: []vpcmodel.Subnet{
+		{Id: "subnet-1", NeutronSubnetId: "neutron-subnet-1", AvailabilityZone: "az-1"},
+		{Id: "subnet-2", NeutronSubnetId: "neutron-subnet-2", AvailabilityZone: "az-2"},
+		{Id: "subnet-3", NeutronSubnetId: "neutron-subnet-3"},
+	}}
+
+	tests := []struct {
+		name     string
+		subnets  subnetLister
+		subnetID string
+		zones    []string
+		expected codes.Code
+	}{
+		{name: "matching zone", subnets: subnets, subnetID: "neutron-subnet-1", zones: []string{"az-1", "az-2"},
+			expected: codes.OK},
+		{name: "matching zone by subnet ID", subnets: subnets, subnetID: "subnet-2", zones: []string{"az-2"},
+			expected: codes.OK},
+		{name: "mismatching zone", subnets: subnets, subnetID: "neutron-subnet-1", zones: []string{"az-2", "az-3"},
+			expected: codes.InvalidArgument},
+		{name: "subnet in all zones", subnets: subnets, subnetID: "neutron-subnet-3", zones: []string{"az-3"},
+			expected: codes.OK},
+		{name: "subnet not found", subnets: subnets, subnetID: "neutron-subnet-4", zones: []string{"az-1"},
+			expected: codes.OK},
+		{name: "query failed", subnets: &fakeSubnetLister{err: fmt.Errorf("throttled")}, subnetID: "neutron-subnet-1",
+			zones: []string{"az-2"}, expected: codes.OK},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			err := validateSubnetAvailabilityZone(te.subnets, "vpc-1", te.subnetID, te.zones)
+			if status.Code(err) != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, err)
+			}
+		})
+	}
+}
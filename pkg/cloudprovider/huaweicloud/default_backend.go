@@ -0,0 +1,316 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+const (
+	l7RuleTypeHostName   = "HOST_NAME"
+	l7RuleTypePath       = "PATH"
+	l7CompareTypeEqualTo = "EQUAL_TO"
+	l7CompareStartsWith  = "STARTS_WITH"
+)
+
+// serviceGetter gets the Services of a namespace, such as the default backend of a service.
+type serviceGetter interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Service, error)
+}
+
+// defaultBackend is the Service port that the requests not routed to the service are forwarded to.
+type defaultBackend struct {
+	service *v1.Service
+	port    v1.ServicePort
+}
+
+// parseDefaultBackend returns the name and the port of the Service in the annotation ElbDefaultBackend,
+// in the format of name:port, the port is the name or the number of the service port. The port can be omitted
+// if the Service has only one port.
+func parseDefaultBackend(service *v1.Service) (string, string, error) {
+	value := strings.TrimSpace(getStringFromSvsAnnotation(service, ElbDefaultBackend, ""))
+	if value == "" {
+		return "", "", nil
+	}
+	name, port, _ := strings.Cut(value, ":")
+	if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+		return "", "", status.Errorf(codes.InvalidArgument, "invalid Service name in %q: %s",
+			ElbDefaultBackend, strings.Join(errs, ", "))
+	}
+	return name, strings.TrimSpace(port), nil
+}
+
+// getDefaultBackend returns the default backend of the service, nil if it is not specified.
+// An error is returned if the Service or the port does not exist, or the port has no node port.
+func getDefaultBackend(services serviceGetter, service *v1.Service) (*defaultBackend, error) {
+	name, port, err := parseDefaultBackend(service)
+	if err != nil || name == "" {
+		return nil, err
+	}
+
+	backend, err := services.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, status.Errorf(codes.InvalidArgument, "the Service %s/%s of %q is not found",
+				service.Namespace, name, ElbDefaultBackend)
+		}
+		return nil, err
+	}
+
+	svcPort, ok := findServicePort(backend, port)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "the port %q of %q is not found in the Service %s/%s",
+			port, ElbDefaultBackend, backend.Namespace, backend.Name)
+	}
+	if svcPort.NodePort == 0 && !isPodTargeted(backend) {
+		return nil, status.Errorf(codes.InvalidArgument, "the port %q of the Service %s/%s in %q has no node port",
+			port, backend.Namespace, backend.Name, ElbDefaultBackend)
+	}
+	return &defaultBackend{service: backend, port: svcPort}, nil
+}
+
+// findServicePort returns the port of the Service by the name or the number,
+// the only port is returned if port is empty.
+func findServicePort(service *v1.Service, port string) (v1.ServicePort, bool) {
+	if port == "" {
+		if len(service.Spec.Ports) == 1 {
+			return service.Spec.Ports[0], true
+		}
+		return v1.ServicePort{}, false
+	}
+	number, err := strconv.Atoi(port)
+	for _, p := range service.Spec.Ports {
+		if p.Name == port || (err == nil && int(p.Port) == number) {
+			return p, true
+		}
+	}
+	return v1.ServicePort{}, false
+}
+
+// validateDefaultBackend checks whether the default backend can be set on the listener of the protocol.
+// The requests to the hostname of kubernetes.io/elb.hostname are routed to the service and the others to the
+// default backend, so the hostname is required. It can not be used with the node pools either,
+// which forward all the requests to the pools.
+func validateDefaultBackend(service *v1.Service, protocol string, opts *config.LoadBalancerOptions) error {
+	if getStringFromSvsAnnotation(service, ElbDefaultBackend, "") == "" {
+		return nil
+	}
+	if !isNodePoolSupported(protocol) {
+		return status.Errorf(codes.InvalidArgument, "%q is only supported by HTTP/HTTPS listeners, got: %s",
+			ElbDefaultBackend, protocol)
+	}
+	if getIngressHostname(service) == "" {
+		return status.Errorf(codes.InvalidArgument, "%q requires %q to route the requests to the service",
+			ElbDefaultBackend, ElbHostname)
+	}
+	if opts.NodePoolLabel != "" {
+		return status.Errorf(codes.InvalidArgument, "%q can not be used with the node pools of %q",
+			ElbDefaultBackend, "node-pool-label")
+	}
+	return nil
+}
+
+func getDefaultBackendPoolName(listenerName string) string {
+	return utils.CutString(fmt.Sprintf("db_%s", listenerName), defaultMaxNameLength)
+}
+
+func getDefaultBackendPolicyName(listenerName string) string {
+	return utils.CutString(fmt.Sprintf("db_%s", listenerName), defaultMaxNameLength)
+}
+
+func getServiceRoutePolicyName(listenerName string) string {
+	return utils.CutString(fmt.Sprintf("rt_%s", listenerName), defaultMaxNameLength)
+}
+
+// newDefaultBackendPolicyOptions returns the policies of the listener: the requests to the hostname are forwarded
+// to the pool of the service, the host name rule takes precedence over the path rule, so that the unmatched
+// requests are forwarded to the pool of the default backend.
+func newDefaultBackendPolicyOptions(listener *elbmodel.Listener, hostname, poolID,
+	backendPoolID string) []elbmodel.CreateL7PolicyOption {
+	routeName := getServiceRoutePolicyName(listener.Name)
+	backendName := getDefaultBackendPolicyName(listener.Name)
+	return []elbmodel.CreateL7PolicyOption{
+		{
+			Name:           &routeName,
+			ListenerId:     listener.Id,
+			Action:         l7PolicyActionRedirectToPool,
+			RedirectPoolId: &poolID,
+			Rules: &[]elbmodel.CreateL7PolicyRuleOption{
+				{Type: l7RuleTypeHostName, CompareType: l7CompareTypeEqualTo, Value: hostname},
+			},
+		},
+		{
+			Name:           &backendName,
+			ListenerId:     listener.Id,
+			Action:         l7PolicyActionRedirectToPool,
+			RedirectPoolId: &backendPoolID,
+			Rules: &[]elbmodel.CreateL7PolicyRuleOption{
+				{Type: l7RuleTypePath, CompareType: l7CompareStartsWith, Value: "/"},
+			},
+		},
+	}
+}
+
+// ensureDefaultBackend forwards the requests of the listener not routed to the service to the default backend.
+// The pool and the policies of the default backend are deleted if it is not specified.
+func (d *DedicatedLoadBalancer) ensureDefaultBackend(loadbalancer *elbmodel.LoadBalancer,
+	listener *elbmodel.Listener, pool *elbmodel.Pool, service *v1.Service, backend *defaultBackend,
+	nodes []*v1.Node) error {
+	if backend == nil {
+		return d.deleteListenerDefaultBackend(loadbalancer.Id, listener)
+	}
+
+	loadbalancerIDs := []string{loadbalancer.Id}
+	existing, err := d.dedicatedELBClient.ListPools(&elbmodel.ListPoolsRequest{
+		LoadbalancerId: &loadbalancerIDs,
+	})
+	if err != nil {
+		return err
+	}
+	name := getDefaultBackendPoolName(listener.Name)
+	backendPool := findPoolByName(existing, name)
+	if backendPool == nil {
+		klog.Infof("Creating pool %s for the default backend %s/%s of listener %s", name,
+			backend.service.Namespace, backend.service.Name, listener.Id)
+		createOpt, err := d.newCreatePoolOption(listener, backend.service, name)
+		if err != nil {
+			return err
+		}
+		// The pool is not the default pool of the listener, it is associated by the policy.
+		createOpt.LoadbalancerId = &loadbalancer.Id
+		// The pool is owned by the service of the listener rather than the default backend.
+		description := getPoolDescription(service)
+		createOpt.Description = &description
+		if backendPool, err = d.dedicatedELBClient.CreatePool(createOpt); err != nil {
+			return err
+		}
+	}
+	if err = d.addOrRemoveMembers(loadbalancer, backend.service, backendPool, backend.port, nodes); err != nil {
+		return err
+	}
+	if err = d.ensureHealthCheck(loadbalancer.Id, backendPool, backend.port, backend.service, nodes[0]); err != nil {
+		return err
+	}
+
+	options := newDefaultBackendPolicyOptions(listener, getIngressHostname(service), pool.Id, backendPool.Id)
+	return d.ensureL7Policies(listener, options)
+}
+
+// ensureL7Policies creates the policies of the options, or updates the target pools and the rules of the existing
+// policies with the same names.
+func (d *DedicatedLoadBalancer) ensureL7Policies(listener *elbmodel.Listener,
+	options []elbmodel.CreateL7PolicyOption) error {
+	names := make([]string, 0, len(options))
+	for _, opt := range options {
+		names = append(names, *opt.Name)
+	}
+	policies, err := d.dedicatedELBClient.ListL7Policies(&elbmodel.ListL7PoliciesRequest{
+		ListenerId: &[]string{listener.Id},
+		Name:       &names,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, opt := range options {
+		opt := opt
+		policy := findL7PolicyByName(policies, *opt.Name)
+		if policy == nil {
+			klog.Infof("Creating policy %s of listener %s", *opt.Name, listener.Id)
+			if _, err = d.dedicatedELBClient.CreateL7Policy(&opt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rules := make([]elbmodel.CreateRuleOption, 0, len(*opt.Rules))
+		for _, r := range *opt.Rules {
+			rules = append(rules, elbmodel.CreateRuleOption{Type: r.Type, CompareType: r.CompareType, Value: r.Value})
+		}
+		err = d.dedicatedELBClient.UpdateL7Policy(policy.Id, &elbmodel.UpdateL7PolicyOption{
+			RedirectPoolId: opt.RedirectPoolId,
+			Rules:          &rules,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteListenerDefaultBackend deletes the policies and the pool of the default backend of the listener.
+func (d *DedicatedLoadBalancer) deleteListenerDefaultBackend(elbID string, listener *elbmodel.Listener) error {
+	if !isNodePoolSupported(listener.Protocol) {
+		return nil
+	}
+	policies, err := d.dedicatedELBClient.ListL7Policies(&elbmodel.ListL7PoliciesRequest{
+		ListenerId: &[]string{listener.Id},
+		Name:       &[]string{getServiceRoutePolicyName(listener.Name), getDefaultBackendPolicyName(listener.Name)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	for _, p := range policies {
+		klog.Infof("Deleting policy %s of listener %s, the default backend is not specified", p.Id, listener.Id)
+		if err = d.dedicatedELBClient.DeleteL7Policy(p.Id); err != nil && !common.IsNotFound(err) {
+			return err
+		}
+	}
+
+	loadbalancerIDs := []string{elbID}
+	pools, err := d.dedicatedELBClient.ListPools(&elbmodel.ListPoolsRequest{
+		LoadbalancerId: &loadbalancerIDs,
+	})
+	if err != nil {
+		return err
+	}
+	if pool := findPoolByName(pools, getDefaultBackendPoolName(listener.Name)); pool != nil {
+		klog.Infof("Deleting pool %s of the default backend of listener %s", pool.Id, listener.Id)
+		if errs := d.deletePool(pool); len(errs) > 0 {
+			return fmt.Errorf("failed to delete the default backend pool %s: %v", pool.Id, errs)
+		}
+	}
+	return nil
+}
+
+func findL7PolicyByName(policies []elbmodel.L7Policy, name string) *elbmodel.L7Policy {
+	for _, policy := range policies {
+		if policy.Name == name {
+			return &policy
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+// fakeServiceGetter returns the Services by name, NotFound for the others.
+type fakeServiceGetter map[string]*v1.Service
+
+func (f fakeServiceGetter) Get(_ context.Context, name string, _ metav1.GetOptions) (*v1.Service, error) {
+	if service, ok := f[name]; ok {
+		return service, nil
+	}
+	return nil, apierrors.NewNotFound(v1.Resource("services"), name)
+}
+
+func TestGetDefaultBackend(t *testing.T) {
+	services := fakeServiceGetter{
+		"not-found-page": {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "not-found-page"},
+			Spec: v1.ServiceSpec{Ports: []v1.ServicePort{
+				{Name: "http", Port: 80, NodePort: 30080},
+				{Name: "metrics", Port: 9090, NodePort: 30090},
+			}},
+		},
+		"single-port": {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "single-port"},
+			Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 8080, NodePort: 30088}}},
+		},
+		"cluster-ip": {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster-ip"},
+			Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 80}}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected int32
+		code     codes.Code
+	}{
+		{name: "not specified", value: "", expected: 0, code: codes.OK},
+		{name: "port name", value: "not-found-page:http", expected: 30080, code: codes.OK},
+		{name: "port number", value: "not-found-page:9090", expected: 30090, code: codes.OK},
+		{name: "port omitted", value: "single-port", expected: 30088, code: codes.OK},
+		{name: "port omitted of multiple ports", value: "not-found-page", code: codes.InvalidArgument},
+		{name: "service not found", value: "missing:80", code: codes.InvalidArgument},
+		{name: "port not found", value: "not-found-page:https", code: codes.InvalidArgument},
+		{name: "no node port", value: "cluster-ip:80", code: codes.InvalidArgument},
+		{name: "invalid name", value: "Not_Found:80", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(map[string]string{ElbDefaultBackend: tt.value})
+			backend, err := getDefaultBackend(services, service)
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected: %v, got: %v", tt.code, err)
+			}
+			if err != nil {
+				return
+			}
+			got := int32(0)
+			if backend != nil {
+				got = backend.port.NodePort
+			}
+			if got != tt.expected {
+				t.Fatalf("expected: %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultBackend(t *testing.T) {
+	annotations := map[string]string{ElbDefaultBackend: "not-found-page:80", ElbHostname: "web.example.com"}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		protocol    string
+		opts        *config.LoadBalancerOptions
+		wantErr     bool
+	}{
+		{name: "not specified", annotations: nil, protocol: ProtocolTCP, opts: &config.LoadBalancerOptions{}},
+		{name: "HTTP listener", annotations: annotations, protocol: ProtocolHTTP, opts: &config.LoadBalancerOptions{}},
+		{name: "TCP listener", annotations: annotations, protocol: ProtocolTCP, opts: &config.LoadBalancerOptions{},
+			wantErr: true},
+		{name: "without hostname", annotations: map[string]string{ElbDefaultBackend: "not-found-page:80"},
+			protocol: ProtocolHTTP, opts: &config.LoadBalancerOptions{}, wantErr: true},
+		{name: "with node pools", annotations: annotations, protocol: ProtocolHTTP,
+			opts: &config.LoadBalancerOptions{NodePoolLabel: "example.com/node-pool"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDefaultBackend(newTestService(tt.annotations), tt.protocol, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewDefaultBackendPolicyOptions(t *testing.T) {
+	listener := &elbmodel.Listener{Id: "listener-1", Name: "web_HTTP_80"}
+	options := newDefaultBackendPolicyOptions(listener, "web.example.com", "pool-1", "pool-2")
+
+	type expectedPolicy struct {
+		name  string
+		pool  string
+		rules []elbmodel.CreateL7PolicyRuleOption
+	}
+	expected := []expectedPolicy{
+		{name: "rt_web_HTTP_80", pool: "pool-1", rules: []elbmodel.CreateL7PolicyRuleOption{
+			{Type: "HOST_NAME", CompareType: "EQUAL_TO", Value: "web.example.com"},
+		}},
+		{name: "db_web_HTTP_80", pool: "pool-2", rules: []elbmodel.CreateL7PolicyRuleOption{
+			{Type: "PATH", CompareType: "STARTS_WITH", Value: "/"},
+		}},
+	}
+
+	got := make([]expectedPolicy, 0, len(options))
+	for _, opt := range options {
+		if opt.ListenerId != listener.Id || opt.Action != "REDIRECT_TO_POOL" {
+			t.Fatalf("expected: the policy redirecting to pool of %s, got: %v", listener.Id, opt)
+		}
+		got = append(got, expectedPolicy{name: *opt.Name, pool: *opt.RedirectPoolId, rules: *opt.Rules})
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, got)
+	}
+}
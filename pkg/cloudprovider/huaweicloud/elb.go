@@ -30,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog"
 )
 
@@ -37,6 +38,10 @@ type ELBCloud struct {
 	Basic
 }
 
+func (elb *ELBCloud) withReconcile(ctx context.Context, correlationID string) cloudprovider.LoadBalancer {
+	return &ELBCloud{Basic: elb.Basic.withReconcile(ctx, correlationID)}
+}
+
 // temp async job info
 // used for add members
 type tempJobInfo struct {
@@ -52,7 +57,17 @@ type tempServicePort struct {
 // getELBClient
 func (elb *ELBCloud) ELBClient() (*ELBClient, error) {
 	authOpts := elb.cloudConfig.AuthOpts
-	return NewELBClient(authOpts.Cloud, authOpts.Region, authOpts.ProjectID, authOpts.AccessKey, authOpts.SecretKey), nil
+	ak, sk, _, err := authOpts.GetCredentialProvider().GetCredentials(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	projectID, err := authOpts.GetProjectID()
+	if err != nil {
+		return nil, err
+	}
+	client := NewELBClient(authOpts.GetCloud(), authOpts.Region, projectID, ak, sk, authOpts.GetUserAgent())
+	client.ctx = elb.reconcileContext()
+	return client, nil
 }
 
 // GetLoadBalancer gets loadbalancer for service.
@@ -18,6 +18,7 @@ limitations under the License.
 package huaweicloud
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -224,6 +225,15 @@ const (
 type ELBClient struct {
 	ecsClient *ServiceClient
 	elbClient *ServiceClient
+	// ctx stops waiting for the jobs once it is done, such as the reconcile timing out.
+	ctx context.Context
+}
+
+func (e *ELBClient) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
 }
 
 // Asynchronous job query response
@@ -272,7 +282,7 @@ type EcsServers struct {
 	Servers []Server `json:"servers,omitempty"`
 }
 
-func NewELBClient(cloud, region, projectID, accessKey, secretKey string) *ELBClient {
+func NewELBClient(cloud, region, projectID, accessKey, secretKey, userAgent string) *ELBClient {
 	elbEndpoint := fmt.Sprintf("https://ecs.%s.%s", region, cloud)
 	ecsEndpoint := fmt.Sprintf("https://ecs.%s.%s", region, cloud)
 
@@ -283,17 +293,19 @@ func NewELBClient(cloud, region, projectID, accessKey, secretKey string) *ELBCli
 	}
 
 	ecsClient := &ServiceClient{
-		Client:   httpClient,
-		Endpoint: ecsEndpoint,
-		Access:   access,
-		TenantId: projectID,
+		Client:    httpClient,
+		Endpoint:  ecsEndpoint,
+		Access:    access,
+		TenantId:  projectID,
+		UserAgent: userAgent,
 	}
 
 	elbClient := &ServiceClient{
-		Client:   httpClient,
-		Endpoint: elbEndpoint,
-		Access:   access,
-		TenantId: projectID,
+		Client:    httpClient,
+		Endpoint:  elbEndpoint,
+		Access:    access,
+		TenantId:  projectID,
+		UserAgent: userAgent,
 	}
 
 	return &ELBClient{
@@ -347,7 +359,7 @@ func (e *ELBClient) waitJobEnd(jobID string) (*AsyncJobResp, error) {
 }
 
 func (e *ELBClient) WaitJobComplete(jobID string) error {
-	err := common.WaitForCompleted(func() (bool, error) {
+	err := common.WaitForCompleted(e.context(), func() (bool, error) {
 		job, err := e.GetJobStatus(jobID)
 		if err != nil {
 			klog.Errorf("Get job(%s) status error: %v", jobID, err)
@@ -369,7 +381,7 @@ func (e *ELBClient) WaitJobComplete(jobID string) error {
 }
 
 func (e *ELBClient) WaitMemberComplete(listenerID string, newMembers []*Member) error {
-	err := common.WaitForCompleted(func() (bool, error) {
+	err := common.WaitForCompleted(e.context(), func() (bool, error) {
 		members, err := e.ListMembers(listenerID)
 		if err != nil {
 			klog.Errorf("List members(%s) error: %v", listenerID, err)
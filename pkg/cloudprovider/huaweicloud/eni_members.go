@@ -0,0 +1,214 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+// ElbENIMembers registers the ready pods of the service by the IPs of their ENIs as the members,
+// instead of the nodes by the node ports, such as in the VPC-native clusters with an ENI for each pod.
+const ElbENIMembers = "kubernetes.io/elb.eni-members"
+
+// eniMemberTarget is a ready endpoint of the service port, the IP is the IP of the ENI of the pod.
+type eniMemberTarget struct {
+	address string
+	port    int32
+	// name is the name of the pod, or the address if the endpoint does not refer to a pod.
+	name string
+}
+
+// isENIMembers returns true if the pods are registered by the IPs of their ENIs, which also means
+// the service is pod-targeted.
+func isENIMembers(service *v1.Service) bool {
+	return getBoolFromSvsAnnotation(service, ElbENIMembers, false)
+}
+
+// getENIMemberTargets returns the ready IPv4 endpoints of the service port in the EndpointSlices of the service,
+// the target port is resolved by the EndpointSlices, so the named target ports are supported.
+func getENIMemberTargets(slices []discovery.EndpointSlice, svcPort v1.ServicePort) []eniMemberTarget {
+	targets := make([]eniMemberTarget, 0)
+	seen := make(map[string]bool)
+	for _, slice := range slices {
+		if slice.AddressType != discovery.AddressTypeIPv4 {
+			continue
+		}
+		port := getEndpointSlicePort(slice, svcPort)
+		if port == 0 {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			for _, address := range ep.Addresses {
+				key := fmt.Sprintf("%s:%d", address, port)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				name := address
+				if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+					name = ep.TargetRef.Name
+				}
+				targets = append(targets, eniMemberTarget{address: address, port: port, name: name})
+			}
+		}
+	}
+	return targets
+}
+
+// getEndpointSlicePort returns the port of the endpoints of the service port in the EndpointSlice,
+// the ports are matched by the name, 0 is returned if it is not found.
+func getEndpointSlicePort(slice discovery.EndpointSlice, svcPort v1.ServicePort) int32 {
+	for _, port := range slice.Ports {
+		if port.Port == nil || port.Name == nil || *port.Name != svcPort.Name {
+			continue
+		}
+		if port.Protocol != nil && *port.Protocol != svcPort.Protocol {
+			continue
+		}
+		return *port.Port
+	}
+	return 0
+}
+
+// getENISubnetID returns the subnet of the ENI that has the IP, such as the ENI of a pod.
+func getENISubnetID(ports portLister, address string) (string, error) {
+	fixedIPs := fmt.Sprintf("ip_address=%s", address)
+	list, err := ports.ListPorts(&vpcmodel.ListPortsRequest{FixedIps: &fixedIPs})
+	if err != nil {
+		return "", err
+	}
+	for _, port := range list {
+		for _, fixedIP := range port.FixedIps {
+			if fixedIP.IpAddress != nil && *fixedIP.IpAddress == address && fixedIP.SubnetId != nil {
+				return *fixedIP.SubnetId, nil
+			}
+		}
+	}
+	return "", status.Errorf(codes.NotFound, "not found the ENI with IP %s", address)
+}
+
+// newENIMemberOption returns the option to add the endpoint to the pool. The subnet of the member is the subnet
+// of the ENI, it is not needed if the ELB instance registers the members by IP across the VPCs.
+func newENIMemberOption(ports portLister, pool *elbmodel.Pool, target eniMemberTarget, ipTargetEnable bool) (
+	*elbmodel.CreateMemberOption, error) {
+	name := utils.CutString(fmt.Sprintf("member_%s_%s", pool.Name, target.name), defaultMaxNameLength)
+	opt := &elbmodel.CreateMemberOption{
+		Name:         &name,
+		ProtocolPort: target.port,
+		Address:      target.address,
+	}
+	if ipTargetEnable {
+		return opt, nil
+	}
+	subnetID, err := getENISubnetID(ports, target.address)
+	if err != nil {
+		return nil, err
+	}
+	opt.SubnetCidrId = &subnetID
+	return opt, nil
+}
+
+// listEndpointSlices lists the EndpointSlices of the service.
+func (b Basic) listEndpointSlices(ctx context.Context, service *v1.Service) ([]discovery.EndpointSlice, error) {
+	selector := labels.SelectorFromSet(map[string]string{discovery.LabelServiceName: service.Name})
+	list, err := b.discoveryClient.EndpointSlices(service.Namespace).List(ctx,
+		metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// addOrRemoveENIMembers registers the ready endpoints of the service port to the pool by the IPs of their ENIs,
+// and removes the members of the endpoints that are gone or not ready. The nodes are bypassed.
+func (d *DedicatedLoadBalancer) addOrRemoveENIMembers(loadbalancer *elbmodel.LoadBalancer, service *v1.Service,
+	pool *elbmodel.Pool, svcPort v1.ServicePort) error {
+	slices, err := d.listEndpointSlices(context.TODO(), service)
+	if err != nil {
+		return err
+	}
+	members, err := d.dedicatedELBClient.ListMembers(&elbmodel.ListMembersRequest{PoolId: pool.Id})
+	if err != nil {
+		return err
+	}
+	existsMember := make(map[string]bool)
+	for _, m := range members {
+		existsMember[fmt.Sprintf("%s:%d", m.Address, m.ProtocolPort)] = true
+	}
+
+	targets := getENIMemberTargets(slices, svcPort)
+	klog.Infof("LoadBalancer Service: %s/%s, ready endpoints of port %s: %d", service.Namespace, service.Name,
+		svcPort.Name, len(targets))
+	for _, target := range targets {
+		if existsMember[fmt.Sprintf("%s:%d", target.address, target.port)] {
+			members = d.popMember(members, target.address, target.port)
+			continue
+		}
+
+		opt, err := newENIMemberOption(d.vpcClient, pool, target, loadbalancer.IpTargetEnable)
+		if common.IsNotFound(err) {
+			klog.Warningf("Failed to resolve the ENI of endpoint %s: %v", target.name, err)
+			d.sendWarningEvent("SkipLoadBalancerMember",
+				fmt.Sprintf("Skip adding endpoint %s to the pool %s: %s", target.name, pool.Name, err), service)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error getting the subnet of endpoint %s: %v", target.name, err)
+		}
+
+		klog.Infof("[addOrRemoveENIMembers] add endpoint to pool, name: %s, address: %s, port: %d",
+			target.name, target.address, target.port)
+		err = d.retryOnNotReady(loadbalancer.Id, func() error {
+			_, err := d.dedicatedELBClient.AddMember(pool.Id, opt)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error creating pool member for endpoint %s: %v", target.name, err)
+		}
+		if _, err = d.dedicatedELBClient.WaitStatusActive(loadbalancer.Id); err != nil {
+			return fmt.Errorf("timeout when waiting for loadbalancer to be ACTIVE after adding members: %v", err)
+		}
+		existsMember[fmt.Sprintf("%s:%d", target.address, target.port)] = true
+	}
+
+	for _, member := range members {
+		klog.Infof("[addOrRemoveENIMembers] remove endpoint from pool, name: %s, address: %s, port: %d",
+			member.Name, member.Address, member.ProtocolPort)
+		if err = d.deleteMember(loadbalancer.Id, pool.Id, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	"k8s.io/utils/pointer"
+)
+
+// fakeENIPortLister returns the ENIs by their IPs.
+type fakeENIPortLister struct {
+	ports []vpcmodel.Port
+}
+
+func (f *fakeENIPortLister) ListPorts(req *vpcmodel.ListPortsRequest) ([]vpcmodel.Port, error) {
+	if req.FixedIps == nil {
+		return nil, fmt.Errorf("the ports are not listed by the IP")
+	}
+	address := strings.TrimPrefix(*req.FixedIps, "ip_address=")
+	list := make([]vpcmodel.Port, 0)
+	for _, port := range f.ports {
+		for _, fixedIP := range port.FixedIps {
+			if *fixedIP.IpAddress == address {
+				list = append(list, port)
+			}
+		}
+	}
+	return list, nil
+}
+
+func newTestEndpoint(address, pod string, ready bool) discovery.Endpoint {
+	return discovery.Endpoint{
+		Addresses:  []string{address},
+		Conditions: discovery.EndpointConditions{Ready: pointer.Bool(ready)},
+		TargetRef:  &v1.ObjectReference{Kind: "Pod", Name: pod},
+	}
+}
+
+func newTestEndpointSlice(addressType discovery.AddressType, port int32, endpoints ...discovery.Endpoint,
+) discovery.EndpointSlice {
+	protocol := v1.ProtocolTCP
+	return discovery.EndpointSlice{
+		AddressType: addressType,
+		Endpoints:   endpoints,
+		Ports:       []discovery.EndpointPort{{Name: pointer.String("http"), Port: &port, Protocol: &protocol}},
+	}
+}
+
+func TestGetENIMemberTargets(t *testing.T) {
+	slices := []discovery.EndpointSlice{
+		newTestEndpointSlice(discovery.AddressTypeIPv4, 8080,
+			newTestEndpoint("192.168.1.10", "web-0", true),
+			newTestEndpoint("192.168.1.11", "web-1", false),
+			discovery.Endpoint{Addresses: []string{"192.168.1.12"}}),
+		// the endpoint is in multiple slices during an update.
+		newTestEndpointSlice(discovery.AddressTypeIPv4, 8080, newTestEndpoint("192.168.1.10", "web-0", true)),
+		newTestEndpointSlice(discovery.AddressTypeIPv6, 8080, newTestEndpoint("fd00::10", "web-0", true)),
+	}
+
+	tests := []struct {
+		name     string
+		svcPort  v1.ServicePort
+		expected []eniMemberTarget
+	}{
+		{
+			name:    "named target port",
+			svcPort: v1.ServicePort{Name: "http", Protocol: v1.ProtocolTCP, Port: 80},
+			expected: []eniMemberTarget{
+				{address: "192.168.1.10", port: 8080, name: "web-0"},
+				{address: "192.168.1.12", port: 8080, name: "192.168.1.12"},
+			},
+		},
+		{
+			name:     "other port",
+			svcPort:  v1.ServicePort{Name: "https", Protocol: v1.ProtocolTCP, Port: 443},
+			expected: []eniMemberTarget{},
+		},
+		{
+			name:     "other protocol",
+			svcPort:  v1.ServicePort{Name: "http", Protocol: v1.ProtocolUDP, Port: 80},
+			expected: []eniMemberTarget{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := getENIMemberTargets(slices, tt.svcPort)
+			if !reflect.DeepEqual(targets, tt.expected) {
+				t.Fatalf("expected: %v, got: %v", tt.expected, targets)
+			}
+		})
+	}
+}
+
+func TestNewENIMemberOption(t *testing.T) {
+	// the secondary IPs of the pods are on the ENIs in different subnets from the nodes.
+	ports := &fakeENIPortLister{ports: []vpcmodel.Port{
+		newTestPort("eni-0", "192.168.1.10", "subnet-pod-a"),
+		newTestPort("eni-1", "192.168.2.10", "subnet-pod-b"),
+	}}
+	pool := &elbmodel.Pool{Name: "pool-http"}
+
+	tests := []struct {
+		name           string
+		target         eniMemberTarget
+		ipTargetEnable bool
+		expectedSubnet *string
+		expectedCode   codes.Code
+	}{
+		{
+			name:           "pod A",
+			target:         eniMemberTarget{address: "192.168.1.10", port: 8080, name: "web-0"},
+			expectedSubnet: pointer.String("subnet-pod-a"),
+		},
+		{
+			name:           "pod B",
+			target:         eniMemberTarget{address: "192.168.2.10", port: 8080, name: "web-1"},
+			expectedSubnet: pointer.String("subnet-pod-b"),
+		},
+		{
+			name:         "ENI not found",
+			target:       eniMemberTarget{address: "192.168.3.10", port: 8080, name: "web-2"},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:           "IP target enabled",
+			target:         eniMemberTarget{address: "192.168.3.10", port: 8080, name: "web-2"},
+			ipTargetEnable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt, err := newENIMemberOption(ports, pool, tt.target, tt.ipTargetEnable)
+			if status.Code(err) != tt.expectedCode {
+				t.Fatalf("expected: %v, got: %v", tt.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if opt.Address != tt.target.address || opt.ProtocolPort != tt.target.port {
+				t.Fatalf("expected: %s:%d, got: %s:%d", tt.target.address, tt.target.port, opt.Address,
+					opt.ProtocolPort)
+			}
+			if !reflect.DeepEqual(opt.SubnetCidrId, tt.expectedSubnet) {
+				t.Fatalf("expected: %v, got: %v", pointer.StringDeref(tt.expectedSubnet, ""),
+					pointer.StringDeref(opt.SubnetCidrId, ""))
+			}
+			if expected := "member_pool-http_" + tt.target.name; *opt.Name != expected {
+				t.Fatalf("expected: %s, got: %s", expected, *opt.Name)
+			}
+		})
+	}
+}
+
+func TestIsPodTargetedByENIMembers(t *testing.T) {
+	service := newTestService(map[string]string{ElbENIMembers: "true"})
+	if !isPodTargeted(service) {
+		t.Fatalf("expected: the service registering the ENIs is pod-targeted")
+	}
+	if isPodTargeted(newTestService(nil)) {
+		t.Fatalf("expected: the service is not pod-targeted")
+	}
+}
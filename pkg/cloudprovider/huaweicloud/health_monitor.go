@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+const (
+	// kubeProxyHealthCheckPath is the path served by kube-proxy on the health check node port of the service.
+	kubeProxyHealthCheckPath = "/healthz"
+	// defaultHealthCheckPath is the default URL path of the HTTP health monitors.
+	defaultHealthCheckPath = "/"
+)
+
+// healthMonitorTarget is what the health monitor of a pool checks.
+type healthMonitorTarget struct {
+	// protocol is the type of the health monitor, such as TCP, HTTP or UDP_CONNECT.
+	protocol string
+	// port is the port checked on the members, 0 means the port of each member.
+	port int32
+	// path is the URL path of the HTTP health monitor.
+	path string
+}
+
+// getHealthMonitorTarget returns the target of the health monitor of the service port, the protocol is the type of
+// the health monitor derived from the listener. The nodes of the service with the "Local" external traffic policy
+// are checked on the health check node port of kube-proxy over HTTP, so that only the nodes running the endpoints
+// are healthy. The others are checked on the node port of the service, so that the port is set back in place when
+// the policy is changed, or the node port of the port specified by kubernetes.io/elb.health-check-port.
+// The members of the pods are checked on their own ports, or the target port of the specified port.
+func getHealthMonitorTarget(service *v1.Service, svcPort v1.ServicePort, protocol string,
+	opts *config.HealthCheckOption) healthMonitorTarget {
+	// the annotation is validated before the health monitors are reconciled.
+	healthCheckPort, _ := parseHealthCheckPort(service)
+	if isPodTargeted(service) {
+		target := healthMonitorTarget{protocol: protocol, path: getHealthCheckPath(protocol, opts)}
+		if healthCheckPort != nil {
+			target.port = int32(healthCheckPort.TargetPort.IntValue())
+		}
+		return target
+	}
+	if service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal &&
+		service.Spec.HealthCheckNodePort > 0 {
+		return healthMonitorTarget{
+			protocol: ProtocolHTTP,
+			port:     service.Spec.HealthCheckNodePort,
+			path:     kubeProxyHealthCheckPath,
+		}
+	}
+	if healthCheckPort != nil {
+		svcPort = *healthCheckPort
+	}
+	return healthMonitorTarget{protocol: protocol, port: svcPort.NodePort, path: getHealthCheckPath(protocol, opts)}
+}
+
+// parseHealthCheckPort returns the service port specified by kubernetes.io/elb.health-check-port, nil if it is not
+// specified. The port must be open on the members, that is, it has a node port, or a numeric target port if the
+// pods are the members.
+func parseHealthCheckPort(service *v1.Service) (*v1.ServicePort, error) {
+	value := getStringFromSvsAnnotation(service, ElbHealthCheckPort, "")
+	if value == "" {
+		return nil, nil
+	}
+	port, ok := findServicePort(service, value)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "port %q of %s is not found in service %s/%s",
+			value, ElbHealthCheckPort, service.Namespace, service.Name)
+	}
+	if isPodTargeted(service) {
+		if port.TargetPort.IntValue() <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "port %q of %s must have a numeric target port "+
+				"when the pods are the members", value, ElbHealthCheckPort)
+		}
+	} else if port.NodePort <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "port %q of %s does not have a node port",
+			value, ElbHealthCheckPort)
+	}
+	return &port, nil
+}
+
+// getHealthCheckPath returns the URL path of the HTTP health monitor, "" for the other types.
+func getHealthCheckPath(protocol string, opts *config.HealthCheckOption) string {
+	if protocol != ProtocolHTTP {
+		return ""
+	}
+	if opts.Path != "" {
+		return opts.Path
+	}
+	return defaultHealthCheckPath
+}
+
+// portPtr returns the port to set on the health monitor, nil leaves the port of each member.
+func (t healthMonitorTarget) portPtr() *int32 {
+	if t.port == 0 {
+		return nil
+	}
+	return &t.port
+}
+
+// pathPtr returns the URL path to set on the health monitor, nil for the monitors other than HTTP.
+func (t healthMonitorTarget) pathPtr() *string {
+	if t.path == "" {
+		return nil
+	}
+	return &t.path
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"testing"
+
+	elbv2model "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+// fakeHealthMonitorUpdater records the updates of the health monitors.
+type fakeHealthMonitorUpdater struct {
+	dedicated []*elbmodel.UpdateHealthMonitorOption
+	shared    []*elbv2model.UpdateHealthmonitorReq
+}
+
+func (f *fakeHealthMonitorUpdater) UpdateHealthMonitor(_ string, req *elbmodel.UpdateHealthMonitorOption) error {
+	f.dedicated = append(f.dedicated, req)
+	return nil
+}
+
+// fakeSharedHealthMonitorUpdater adapts the fake to the health monitors of the shared ELB.
+type fakeSharedHealthMonitorUpdater struct {
+	*fakeHealthMonitorUpdater
+}
+
+func (f fakeSharedHealthMonitorUpdater) UpdateHealthMonitor(_ string, req *elbv2model.UpdateHealthmonitorReq) error {
+	f.shared = append(f.shared, req)
+	return nil
+}
+
+func newHealthCheckService(policy v1.ServiceExternalTrafficPolicyType, healthCheckNodePort int32) *v1.Service {
+	service := newTestService(nil)
+	service.Spec.ExternalTrafficPolicy = policy
+	service.Spec.HealthCheckNodePort = healthCheckNodePort
+	return service
+}
+
+func TestGetHealthMonitorTarget(t *testing.T) {
+	svcPort := v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080}
+	podTargeted := newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeLocal, 32000)
+	podTargeted.Spec.AllocateLoadBalancerNodePorts = pointer.Bool(false)
+	healthCheckPorts := []v1.ServicePort{svcPort,
+		{Name: "healthz", Protocol: v1.ProtocolTCP, Port: 8081, NodePort: 30081, TargetPort: intstr.FromInt(9081)}}
+	withHealthCheckPort := func(service *v1.Service) *v1.Service {
+		service.Annotations = map[string]string{ElbHealthCheckPort: "healthz"}
+		service.Spec.Ports = healthCheckPorts
+		return service
+	}
+	podTargetedHealthCheckPort := withHealthCheckPort(newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeLocal,
+		32000))
+	podTargetedHealthCheckPort.Spec.AllocateLoadBalancerNodePorts = pointer.Bool(false)
+
+	tests := []struct {
+		name     string
+		service  *v1.Service
+		protocol string
+		opts     *config.HealthCheckOption
+		expected healthMonitorTarget
+	}{
+		{
+			name:     "cluster policy",
+			service:  newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeCluster, 0),
+			protocol: "TCP",
+			opts:     &config.HealthCheckOption{},
+			expected: healthMonitorTarget{protocol: "TCP", port: 30080},
+		},
+		{
+			name:     "cluster policy over HTTP",
+			service:  newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeCluster, 0),
+			protocol: ProtocolHTTP,
+			opts:     &config.HealthCheckOption{Path: "/ready"},
+			expected: healthMonitorTarget{protocol: ProtocolHTTP, port: 30080, path: "/ready"},
+		},
+		{
+			name:     "local policy",
+			service:  newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeLocal, 32000),
+			protocol: "TCP",
+			opts:     &config.HealthCheckOption{},
+			expected: healthMonitorTarget{protocol: ProtocolHTTP, port: 32000, path: kubeProxyHealthCheckPath},
+		},
+		{
+			name:     "pods as members",
+			service:  podTargeted,
+			protocol: "TCP",
+			opts:     &config.HealthCheckOption{},
+			expected: healthMonitorTarget{protocol: "TCP"},
+		},
+		{
+			name:     "health check port",
+			service:  withHealthCheckPort(newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeCluster, 0)),
+			protocol: ProtocolHTTP,
+			opts:     &config.HealthCheckOption{Path: "/ready"},
+			expected: healthMonitorTarget{protocol: ProtocolHTTP, port: 30081, path: "/ready"},
+		},
+		{
+			name:     "local policy takes precedence over health check port",
+			service:  withHealthCheckPort(newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeLocal, 32000)),
+			protocol: "TCP",
+			opts:     &config.HealthCheckOption{},
+			expected: healthMonitorTarget{protocol: ProtocolHTTP, port: 32000, path: kubeProxyHealthCheckPath},
+		},
+		{
+			name:     "health check port of pods",
+			service:  podTargetedHealthCheckPort,
+			protocol: "TCP",
+			opts:     &config.HealthCheckOption{},
+			expected: healthMonitorTarget{protocol: "TCP", port: 9081},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := getHealthMonitorTarget(te.service, svcPort, te.protocol, te.opts)
+			if got != te.expected {
+				t.Fatalf("expected: %+v, got: %+v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseHealthCheckPort(t *testing.T) {
+	ports := []v1.ServicePort{
+		{Name: "http", Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080, TargetPort: intstr.FromString("http")},
+		{Name: "healthz", Protocol: v1.ProtocolTCP, Port: 8081, NodePort: 30081, TargetPort: intstr.FromInt(9081)},
+		{Name: "metrics", Protocol: v1.ProtocolTCP, Port: 9090},
+	}
+	tests := []struct {
+		name         string
+		value        string
+		podTargeted  bool
+		expected     int32
+		expectedCode codes.Code
+	}{
+		{name: "not specified", expected: 0},
+		{name: "by name", value: "healthz", expected: 8081},
+		{name: "by number", value: "8081", expected: 8081},
+		{name: "not found", value: "8082", expectedCode: codes.InvalidArgument},
+		{name: "no node port", value: "metrics", expectedCode: codes.InvalidArgument},
+		{name: "pods", value: "healthz", podTargeted: true, expected: 8081},
+		{name: "named target port of pods", value: "http", podTargeted: true, expectedCode: codes.InvalidArgument},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			service := newTestService(map[string]string{ElbHealthCheckPort: te.value})
+			service.Spec.Ports = ports
+			if te.podTargeted {
+				service.Spec.AllocateLoadBalancerNodePorts = pointer.Bool(false)
+			}
+			port, err := parseHealthCheckPort(service)
+			if status.Code(err) != te.expectedCode {
+				t.Fatalf("expected: %v, got: %v", te.expectedCode, err)
+			}
+			got := int32(0)
+			if port != nil {
+				got = port.Port
+			}
+			if got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpdateHealthMonitorInPlace(t *testing.T) {
+	svcPort := v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080}
+	opts := &config.HealthCheckOption{Enable: true, Delay: 5, Timeout: 3, MaxRetries: 3}
+
+	// the health check node port is allocated, changed, and released between the reconciles.
+	steps := []struct {
+		name     string
+		service  *v1.Service
+		protocol string
+		port     int32
+		path     *string
+	}{
+		{name: "cluster", service: newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeCluster, 0),
+			protocol: "TCP", port: 30080},
+		{name: "local", service: newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeLocal, 32000),
+			protocol: ProtocolHTTP, port: 32000, path: pointer.String(kubeProxyHealthCheckPath)},
+		{name: "health check node port changed",
+			service:  newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeLocal, 32001),
+			protocol: ProtocolHTTP, port: 32001, path: pointer.String(kubeProxyHealthCheckPath)},
+		{name: "back to cluster", service: newHealthCheckService(v1.ServiceExternalTrafficPolicyTypeCluster, 0),
+			protocol: "TCP", port: 30080},
+	}
+
+	updater := &fakeHealthMonitorUpdater{}
+	for idx, te := range steps {
+		t.Run(te.name, func(t *testing.T) {
+			if err := updateDedicatedHealthMonitor(updater, "monitor-1", v1.ProtocolTCP, te.service, svcPort,
+				opts); err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if err := updateSharedHealthMonitor(fakeSharedHealthMonitorUpdater{updater}, "monitor-2", "TCP",
+				te.service, svcPort, opts); err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if len(updater.dedicated) != idx+1 || len(updater.shared) != idx+1 {
+				t.Fatalf("expected: %d updates, got: %d, %d", idx+1, len(updater.dedicated), len(updater.shared))
+			}
+
+			dedicated, shared := updater.dedicated[idx], updater.shared[idx]
+			if pointer.StringDeref(dedicated.Type, "") != te.protocol ||
+				pointer.Int32Deref(dedicated.MonitorPort, 0) != te.port ||
+				pointer.StringDeref(dedicated.UrlPath, "") != pointer.StringDeref(te.path, "") {
+				t.Fatalf("expected: %s %d %v, got: %s", te.protocol, te.port, te.path, dedicated)
+			}
+			if pointer.StringDeref(shared.Type, "") != te.protocol ||
+				pointer.Int32Deref(shared.MonitorPort, 0) != te.port ||
+				pointer.StringDeref(shared.UrlPath, "") != pointer.StringDeref(te.path, "") {
+				t.Fatalf("expected: %s %d %v, got: %s", te.protocol, te.port, te.path, shared)
+			}
+		})
+	}
+}
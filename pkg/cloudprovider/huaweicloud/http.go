@@ -33,6 +33,7 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/apigw/core"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
 )
 
 const (
@@ -57,6 +58,8 @@ type ServiceClient struct {
 	Endpoint string
 	Access   *AccessInfo
 	TenantId string // nolint:golint // struct field `TenantId` should be `TenantID`
+	// UserAgent is the User-Agent of the requests, defaults to config.DefaultUserAgent.
+	UserAgent string
 }
 
 // request is used to help build up a request
@@ -164,11 +167,15 @@ func DoRequest(service *ServiceClient, throttle flowcontrol.RateLimiter, r *requ
 	url := service.Endpoint + r.url
 	// Create the HTTP request
 	req, err := http.NewRequest(r.method, url, body)
-	req.Header.Set("User-Agent", "huaweicloud-kubernetes-ccm")
-
 	if err != nil {
 		return nil, fmt.Errorf("http new request error")
 	}
+
+	userAgent := service.UserAgent
+	if userAgent == "" {
+		userAgent = config.DefaultUserAgent()
+	}
+	req.Header.Set("User-Agent", userAgent)
 	req.Close = true
 
 	// add the sign to request header if needed.
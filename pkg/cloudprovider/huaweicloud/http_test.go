@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDoRequestUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:      "default",
+			userAgent: "",
+			expected:  config.DefaultUserAgent(),
+		},
+		{
+			name:      "custom",
+			userAgent: "my-cluster/v1",
+			expected:  "my-cluster/v1",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			userAgent := ""
+			client := &ServiceClient{
+				Client: &http.Client{
+					Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						userAgent = req.Header.Get("User-Agent")
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       io.NopCloser(strings.NewReader("{}")),
+							Request:    req,
+						}, nil
+					}),
+				},
+				Endpoint:  "https://nat.ap-southeast-1.myhuaweicloud.com",
+				UserAgent: testCase.userAgent,
+			}
+
+			resp, err := DoRequest(client, nil, NewRequest(http.MethodGet, "/v2.0/nat_gateways", nil, nil))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			resp.Body.Close()
+
+			if userAgent != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, userAgent)
+			}
+		})
+	}
+}
@@ -35,8 +35,10 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	discoveryv1 "k8s.io/client-go/kubernetes/typed/discovery/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/leaderelection"
@@ -50,7 +52,9 @@ import (
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
 )
 
 // Cloud provider name: PaaS Web Services.
@@ -67,22 +71,45 @@ const (
 	ELBKeepEip           = "kubernetes.io/elb.keep-eip"
 	AutoCreateEipOptions = "kubernetes.io/elb.eip-auto-create-option"
 
+	// ElbDeletionProtection keeps the ELB of the service from being deleted until it is removed or set to false.
+	ElbDeletionProtection = "kubernetes.io/elb.deletion-protection"
+	// ElbUnmanaged stops reconciling the service while it is true, its ELB resources are left as they are.
+	ElbUnmanaged = "kubernetes.io/elb.unmanaged"
+
+	// ElbInternal and ElbInternalBeta specify the load balancer only has a private VIP without EIP.
+	ElbInternal     = "kubernetes.io/elb.internal"
+	ElbInternalBeta = "service.beta.kubernetes.io/huawei-load-balancer-internal"
+
 	ElbAlgorithm             = "kubernetes.io/elb.lb-algorithm"
 	ElbSessionAffinityFlag   = "kubernetes.io/elb.session-affinity-flag"
 	ElbSessionAffinityOption = "kubernetes.io/elb.session-affinity-option"
 
 	ElbHealthCheckFlag    = "kubernetes.io/elb.health-check-flag"
 	ElbHealthCheckOptions = "kubernetes.io/elb.health-check-option"
+	// ElbHealthCheckPort is the name or the number of the service port whose node port is checked by the health
+	// monitors, instead of the node port of each listener, such as the port of a health check sidecar.
+	ElbHealthCheckPort = "kubernetes.io/elb.health-check-port"
 
 	ElbXForwardedHost      = "kubernetes.io/elb.x-forwarded-host"
+	ElbXForwardedFor       = "kubernetes.io/elb.x-forwarded-for"
+	ElbAdminStateUp        = "kubernetes.io/elb.admin-state-up"
+	ElbHostname            = "kubernetes.io/elb.hostname"
+	ElbHostnameWithIP      = "kubernetes.io/elb.hostname-with-ip"
 	DefaultTLSContainerRef = "kubernetes.io/elb.default-tls-container-ref"
+	ElbSniCertIDs          = "kubernetes.io/elb.sni-cert-ids"
 
-	ElbIdleTimeout     = "kubernetes.io/elb.idle-timeout"
+	ElbIdleTimeout = "kubernetes.io/elb.idle-timeout"
+	// ElbTCPKeepalive "false" turns off the keepalive of the idle connections of the TCP listeners.
+	ElbTCPKeepalive    = "kubernetes.io/elb.tcp-keepalive"
 	ElbRequestTimeout  = "kubernetes.io/elb.request-timeout"
 	ElbResponseTimeout = "kubernetes.io/elb.response-timeout"
+	// ElbMemberTimeout takes precedence over ElbResponseTimeout, both set the member timeout of the listener.
+	ElbMemberTimeout = "kubernetes.io/elb.member-timeout"
 
 	NodeSubnetIDLabelKey = "node.kubernetes.io/subnetid"
 	ELBMarkAnnotation    = "kubernetes.io/elb.mark"
+	// ElbBackendSubnetCIDR can be set on the service or on the node, the one on the node takes precedence.
+	ElbBackendSubnetCIDR = "kubernetes.io/elb.backend-subnet-cidr"
 
 	MaxRetry   = 3
 	HealthzCCE = "cce-healthz"
@@ -126,11 +153,64 @@ type Basic struct {
 	ecsClient          *wrapper.EcsClient
 	vpcClient          *wrapper.VpcClient
 
+	azCache      *AvailabilityZoneCache
+	addressCache *NodeAddressCache
+	// nameCache caches the instance IDs of the nodes that have not got their provider IDs.
+	nameCache *NodeNameCache
+	// nodeIndexer looks up the nodes by their provider IDs from the node informer.
+	nodeIndexer *NodeIndexer
+	// localInstance is the instance data of the instance that the CCM runs on, fetched from the metadata service.
+	localInstance *localInstance
+	// reconcileSem bounds the reconciles and instance lookups that run simultaneously.
+	reconcileSem *semaphore.Semaphore
+	// reconcileMetrics records the outcomes of the LoadBalancer reconciles.
+	reconcileMetrics *reconcileMetrics
+	// shutdown drains the in-flight reconciles when the CCM stops.
+	shutdown *shutdownGuard
+	// routeUpdater bounds and batches the routes added to the route table.
+	routeUpdater *routeUpdater
+
 	restConfig    *rest.Config
 	kubeClient    *corev1.CoreV1Client
 	eventRecorder record.EventRecorder
+	// discoveryClient lists the EndpointSlices of the services, such as the pods registered by their ENI IPs.
+	discoveryClient discoveryv1.DiscoveryV1Interface
 
 	mutexLock *mutexkv.MutexKV
+	// reconcileCtx is the context of the reconcile that the Basic is scoped to, nil if it is not scoped.
+	reconcileCtx context.Context
+}
+
+// withReconcile returns a copy of the Basic for a reconcile, whose API clients send the correlation ID with
+// the API calls, and stop the API calls and the waits for the resources once ctx is done.
+func (b Basic) withReconcile(ctx context.Context, correlationID string) Basic {
+	b.reconcileCtx = ctx
+	if b.cloudConfig == nil || (correlationID == "" && ctx == nil) {
+		return b
+	}
+	opts := b.cloudConfig.AuthOpts.WithCorrelationID(correlationID).WithContext(ctx)
+	b.sharedELBClient = &wrapper.SharedLoadBalanceClient{AuthOpts: opts}
+	b.dedicatedELBClient = &wrapper.DedicatedLoadBalanceClient{AuthOpts: opts}
+	b.eipClient = &wrapper.EIpClient{AuthOpts: opts}
+	b.ecsClient = &wrapper.EcsClient{AuthOpts: opts}
+	b.vpcClient = &wrapper.VpcClient{AuthOpts: opts}
+	return b
+}
+
+// reconcileContext returns the context of the reconcile that the Basic is scoped to, context.Background()
+// if it is not scoped.
+func (b Basic) reconcileContext() context.Context {
+	if b.reconcileCtx == nil {
+		return context.Background()
+	}
+	return b.reconcileCtx
+}
+
+// correlatedLoadBalancer is implemented by the providers that call the APIs with the wrapper clients.
+type correlatedLoadBalancer interface {
+	// withReconcile returns a copy of the provider for a reconcile, whose API calls carry the correlation ID
+	// and are stopped once ctx is done.
+	withReconcile(ctx context.Context, correlationID string) cloudprovider.LoadBalancer
 }
 
 func (b Basic) listPodsBySelector(ctx context.Context, namespace string, selectors map[string]string) (*v1.PodList, error) {
@@ -143,6 +223,31 @@ func (b Basic) sendEvent(reason, msg string, service *v1.Service) {
 	b.eventRecorder.Event(service, v1.EventTypeNormal, reason, msg)
 }
 
+func (b Basic) sendWarningEvent(reason, msg string, service *v1.Service) {
+	b.eventRecorder.Event(service, v1.EventTypeWarning, reason, msg)
+}
+
+// isDeletionProtected returns true if the ELB of the service must not be deleted, by the annotation
+// ElbDeletionProtection, or by "deletion-protection" if the annotation is not specified.
+func isDeletionProtected(service *v1.Service, opts *config.LoadBalancerOptions) bool {
+	return getBoolFromSvsAnnotation(service, ElbDeletionProtection, opts.DeletionProtection)
+}
+
+// isReadOnly returns true if the cloud resources must not be created, updated or deleted.
+func (b Basic) isReadOnly() bool {
+	return b.cloudConfig != nil && b.cloudConfig.AuthOpts.ReadOnly
+}
+
+// checkReadOnly returns an error if the operation that mutates the cloud resources is rejected in the read-only mode.
+func (b Basic) checkReadOnly(operation string) error {
+	if !b.isReadOnly() {
+		return nil
+	}
+	klog.V(4).Infof("%s is skipped in the read-only mode", operation)
+	return status.Errorf(codes.FailedPrecondition, "%s is not allowed, the cloud provider is in the read-only mode",
+		operation)
+}
+
 func (b Basic) getSubnetID(service *v1.Service, node *v1.Node) (string, error) {
 	subnetID, err := b.getNodeSubnetID(node)
 	if err != nil {
@@ -211,7 +316,7 @@ func (b Basic) getNodeSubnetID(node *v1.Node) (string, error) {
 }
 
 func (b Basic) updateService(service *v1.Service, lbStatus *v1.LoadBalancerStatus) {
-	if service.Spec.LoadBalancerClass == nil || *service.Spec.LoadBalancerClass != LoadBalancerClass {
+	if service.Spec.LoadBalancerClass == nil || *service.Spec.LoadBalancerClass != b.getLoadBalancerClass() {
 		return
 	}
 
@@ -229,11 +334,37 @@ func (b Basic) updateService(service *v1.Service, lbStatus *v1.LoadBalancerStatu
 }
 
 func (b Basic) isSupportedSvc(svs *v1.Service) bool {
-	if svs.Spec.Type != v1.ServiceTypeLoadBalancer {
+	if svs.Spec.Type != v1.ServiceTypeLoadBalancer || isUnmanaged(svs) {
+		return false
+	}
+	return b.isSupportedClass(svs)
+}
+
+// isUnmanaged returns true if the service is annotated with kubernetes.io/elb.unmanaged, then its ELB resources
+// are neither created, updated nor deleted, such as during a manual debugging.
+func isUnmanaged(service *v1.Service) bool {
+	if !getBoolFromSvsAnnotation(service, ElbUnmanaged, false) {
 		return false
 	}
+	klog.V(4).Infof("Ignoring service %s/%s, it is annotated with %s", service.Namespace, service.Name, ElbUnmanaged)
+	return true
+}
 
-	if svs.Spec.LoadBalancerClass != nil && *svs.Spec.LoadBalancerClass != LoadBalancerClass {
+// getLoadBalancerClass returns the load balancer class handled by this controller, which is "loadbalancer-class"
+// if it is configured, otherwise LoadBalancerClass.
+func (b Basic) getLoadBalancerClass() string {
+	if b.loadbalancerOpts != nil && b.loadbalancerOpts.LoadBalancerClass != "" {
+		return b.loadbalancerOpts.LoadBalancerClass
+	}
+	return LoadBalancerClass
+}
+
+// isSupportedClass returns true if the load balancer class of the service is handled by this controller regardless
+// of the type, so that the ELB resources of the service changed away from LoadBalancer can be cleaned up.
+// The services without the class are handled as the default load balancer only if "loadbalancer-class" is not
+// configured, the services of the other classes are left to the other load balancer controllers.
+func (b Basic) isSupportedClass(svs *v1.Service) bool {
+	if svs.Spec.LoadBalancerClass != nil && *svs.Spec.LoadBalancerClass != b.getLoadBalancerClass() {
 		klog.Infof("Ignoring service %s/%s using loadbalancer class %s, it is not supported by this controller",
 			svs.Namespace, svs.Name, *svs.Spec.LoadBalancerClass)
 		return false
@@ -269,6 +400,8 @@ func (b Basic) getPrimaryIP(ip string) (string, error) {
 type CloudProvider struct {
 	Basic
 	providers map[LoadBalanceVersion]cloudprovider.LoadBalancer
+	// credentialProbe validates the credentials in the background and refreshes the ones about to expire.
+	credentialProbe *config.CredentialProbe
 }
 
 type LoadBalanceVersion int
@@ -307,12 +440,31 @@ func NewHWSCloud(cfg io.Reader) (*CloudProvider, error) {
 		klog.Errorf("failed to read loadbalancer config: %v", err)
 	}
 
-	klog.Infof("get loadbalancer config: %#v", elbCfg)
+	klog.V(4).Infof("get loadbalancer config: %#v", elbCfg)
+	if err = elbCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid loadbalancer config: %s", err)
+	}
 
 	restConfig, kubeClient, err := newKubeClient()
 	if err != nil {
 		return nil, err
 	}
+	discoveryClient, err := discoveryv1.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create discoveryClient failed with error: %v", err)
+	}
+
+	if cloudConfig.AuthOpts.CredentialSecret != "" {
+		provider, err := config.NewSecretCredentialProvider(kubeClient, &cloudConfig.AuthOpts)
+		if err != nil {
+			return nil, err
+		}
+		// Load the credentials at startup, so that a missing or malformed Secret fails fast.
+		if _, _, _, err = provider.GetCredentials(context.TODO()); err != nil {
+			return nil, err
+		}
+		cloudConfig.AuthOpts.SetCredentialProvider(provider)
+	}
 
 	broadcaster := record.NewBroadcaster()
 	broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: corev1.New(kubeClient.RESTClient()).Events("")})
@@ -323,6 +475,12 @@ func NewHWSCloud(cfg io.Reader) (*CloudProvider, error) {
 		return nil, fmt.Errorf("failed to init CloudControllerManagerOptions: %s", err)
 	}
 
+	dedicatedELBClient := &wrapper.DedicatedLoadBalanceClient{AuthOpts: &cloudConfig.AuthOpts}
+	vpcClient := &wrapper.VpcClient{AuthOpts: &cloudConfig.AuthOpts}
+	mutexLock := mutexkv.NewMutexKV()
+	azCache := NewAvailabilityZoneCache(dedicatedELBClient,
+		time.Duration(elbCfg.LoadBalancerOpts.AZRefreshInterval)*time.Second)
+
 	basic := Basic{
 		cloudControllerManagerOpts: ccmOpts,
 		cloudConfig:                cloudConfig,
@@ -332,25 +490,50 @@ func NewHWSCloud(cfg io.Reader) (*CloudProvider, error) {
 		metadataOpts:     &elbCfg.MetadataOpts,
 
 		sharedELBClient:    &wrapper.SharedLoadBalanceClient{AuthOpts: &cloudConfig.AuthOpts},
-		dedicatedELBClient: &wrapper.DedicatedLoadBalanceClient{AuthOpts: &cloudConfig.AuthOpts},
+		dedicatedELBClient: dedicatedELBClient,
 		eipClient:          &wrapper.EIpClient{AuthOpts: &cloudConfig.AuthOpts},
 		ecsClient:          &wrapper.EcsClient{AuthOpts: &cloudConfig.AuthOpts},
-		vpcClient:          &wrapper.VpcClient{AuthOpts: &cloudConfig.AuthOpts},
-
-		restConfig:    restConfig,
-		kubeClient:    kubeClient,
-		eventRecorder: recorder,
-		mutexLock:     mutexkv.NewMutexKV(),
+		vpcClient:          vpcClient,
+
+		azCache:      azCache,
+		addressCache: NewNodeAddressCache(defaultNodeAddressCacheTTL),
+		nameCache:    NewNodeNameCache(defaultNodeNameCacheTTL),
+		nodeIndexer:  &NodeIndexer{},
+		localInstance: &localInstance{fetch: func() (*metadata.InstanceData, error) {
+			return metadata.GetInstanceData(cloudConfig.AuthOpts.GetMetadataOptions())
+		}},
+		reconcileSem: semaphore.NewSemaphore(elbCfg.LoadBalancerOpts.MaxConcurrentReconciles),
+
+		reconcileMetrics: defaultReconcileMetrics,
+		shutdown:         newShutdownGuard(),
+		routeUpdater:     newRouteUpdater(vpcClient, mutexLock, &cloudConfig.VpcOpts),
+
+		restConfig:      restConfig,
+		kubeClient:      kubeClient,
+		eventRecorder:   recorder,
+		discoveryClient: discoveryClient,
+		mutexLock:       mutexLock,
 	}
 
+	registerMetrics()
+	setAnnotationPrefix(cloudConfig.AuthOpts.AnnotationPrefix)
+	wrapper.SetRetryBudget(cloudConfig.AuthOpts.RetryBudget, cloudConfig.AuthOpts.RetryBudgetRefillRatio)
+	wrapper.SetRetryPredicate(cloudConfig.AuthOpts.GetRetryPredicate())
+
 	hws := &CloudProvider{
 		Basic:     basic,
 		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{},
 	}
+	klog.Infof("effective config: %s", hws.DumpEffectiveConfig())
 	err = hws.listenerDeploy()
 	if err != nil {
 		return nil, err
 	}
+	hws.credentialProbe = config.NewCredentialProbe(&cloudConfig.AuthOpts, func() error {
+		limit := int32(1)
+		_, err := basic.ecsClient.List(&ecsmodel.ListServersDetailsRequest{Limit: &limit})
+		return err
+	})
 
 	hws.providers[VersionELB] = &ELBCloud{Basic: basic}
 	hws.providers[VersionShared] = &SharedLoadBalancer{Basic: basic}
@@ -360,6 +543,16 @@ func NewHWSCloud(cfg io.Reader) (*CloudProvider, error) {
 	return hws, nil
 }
 
+// DumpEffectiveConfig returns the configuration in effect after the defaults are applied, such as the endpoints,
+// the retry budget and the defaults of the annotations, with the credentials redacted.
+func (h *CloudProvider) DumpEffectiveConfig() *config.EffectiveConfig {
+	return config.NewEffectiveConfig(h.cloudConfig, &config.LoadbalancerConfig{
+		LoadBalancerOpts: *h.loadbalancerOpts,
+		NetworkingOpts:   *h.networkingOpts,
+		MetadataOpts:     *h.metadataOpts,
+	})
+}
+
 func newKubeClient() (*rest.Config, *corev1.CoreV1Client, error) {
 	clusterCfg, err := rest.InClusterConfig()
 	if err != nil {
@@ -374,17 +567,55 @@ func newKubeClient() (*rest.Config, *corev1.CoreV1Client, error) {
 	return clusterCfg, kubeClient, nil
 }
 
+// getProvider returns the provider of the version for the reconcile, whose API calls carry the correlation ID
+// and are stopped once ctx is done.
+func (h *CloudProvider) getProvider(ctx context.Context, version LoadBalanceVersion, correlationID string) (
+	cloudprovider.LoadBalancer, bool) {
+	provider, exist := h.providers[version]
+	if !exist {
+		return nil, false
+	}
+	if p, ok := provider.(correlatedLoadBalancer); ok {
+		return p.withReconcile(ctx, correlationID), true
+	}
+	return provider, true
+}
+
+// logReconcileResult logs the result of the reconcile of the load balancer of the service, the error code
+// and the request ID of the failed API call are logged as discrete keys.
+func logReconcileResult(err error, operation, key, correlationID string) {
+	keysAndValues := []interface{}{"operation", operation, "service", key, "correlationID", correlationID}
+	if err == nil {
+		klog.InfoS("Reconciled the load balancer", keysAndValues...)
+		return
+	}
+	klog.ErrorS(err, "Failed to reconcile the load balancer",
+		append(keysAndValues, common.ErrorKeysAndValues(err)...)...)
+}
+
 func (h *CloudProvider) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (status *v1.LoadBalancerStatus, exists bool, err error) {
-	if !h.isSupportedSvc(service) {
+	if !h.isSupportedClass(service) {
 		return nil, false, cloudprovider.ImplementedElsewhere
 	}
+	if isUnmanaged(service) {
+		// the ELB resources are not looked up, so the cleanup of the service leaves them as they are.
+		lbStatus := service.Status.LoadBalancer.DeepCopy()
+		return lbStatus, len(lbStatus.Ingress) > 0, nil
+	}
+	ctx, correlationID := common.EnsureCorrelationID(ctx)
+	klog.V(4).InfoS("Getting the load balancer", "operation", "GetLoadBalancer",
+		"service", klog.KObj(service), "correlationID", correlationID)
 
 	LBVersion, err := getLoadBalancerVersion(service)
+	if err != nil && service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		// The service is changed away from LoadBalancer, it has no ELB resources without the class.
+		return nil, false, nil
+	}
 	if err != nil {
 		return nil, false, err
 	}
 
-	provider, exist := h.providers[LBVersion]
+	provider, exist := h.getProvider(ctx, LBVersion, correlationID)
 	if !exist {
 		return nil, false, nil
 	}
@@ -414,67 +645,123 @@ func (h *CloudProvider) EnsureLoadBalancer(ctx context.Context, clusterName stri
 	if !h.isSupportedSvc(service) {
 		return nil, cloudprovider.ImplementedElsewhere
 	}
+	if err := h.checkReadOnly("EnsureLoadBalancer"); err != nil {
+		return nil, err
+	}
 	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	h.mutexLock.Lock(key)
-	defer h.mutexLock.Unlock(key)
+	ctx, correlationID := common.EnsureCorrelationID(ctx)
+	klog.InfoS("Reconciling the load balancer", "operation", "EnsureLoadBalancer", "service", key,
+		"correlationID", correlationID)
 
-	LBVersion, err := getLoadBalancerVersion(service)
+	var lbStatus *v1.LoadBalancerStatus
+	err := h.reconcile(ctx, key, func(ctx context.Context) error {
+		LBVersion, err := getLoadBalancerVersion(service)
+		if err != nil {
+			return err
+		}
+
+		provider, exist := h.getProvider(ctx, LBVersion, correlationID)
+		if !exist {
+			return nil
+		}
+
+		h.reconcileMetrics.startReconcile(key)
+		nodes = filterExcludedNodes(nodes, getExcludeNodeLabels(h.loadbalancerOpts)...)
+		lbStatus, err = provider.EnsureLoadBalancer(ctx, clusterName, service, nodes)
+		h.reconcileMetrics.finishReconcile(key, err)
+		logReconcileResult(err, "EnsureLoadBalancer", key, correlationID)
+		return err
+	})
 	if err != nil {
+		// lbStatus may still be set by the reconcile that times out.
 		return nil, err
 	}
-
-	provider, exist := h.providers[LBVersion]
-	if !exist {
-		return nil, nil
-	}
-
-	return provider.EnsureLoadBalancer(ctx, clusterName, service, nodes)
+	return lbStatus, nil
 }
 
 func (h *CloudProvider) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
 	if !h.isSupportedSvc(service) {
 		return cloudprovider.ImplementedElsewhere
 	}
-	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	h.mutexLock.Lock(key)
-	defer h.mutexLock.Unlock(key)
-
-	LBVersion, err := getLoadBalancerVersion(service)
-	if err != nil {
+	if err := h.checkReadOnly("UpdateLoadBalancer"); err != nil {
 		return err
 	}
+	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+	ctx, correlationID := common.EnsureCorrelationID(ctx)
+	klog.InfoS("Reconciling the load balancer", "operation", "UpdateLoadBalancer", "service", key,
+		"correlationID", correlationID)
 
-	provider, exist := h.providers[LBVersion]
-	if !exist {
-		return nil
-	}
+	return h.reconcile(ctx, key, func(ctx context.Context) error {
+		LBVersion, err := getLoadBalancerVersion(service)
+		if err != nil {
+			return err
+		}
+
+		provider, exist := h.getProvider(ctx, LBVersion, correlationID)
+		if !exist {
+			return nil
+		}
 
-	return provider.UpdateLoadBalancer(ctx, clusterName, service, nodes)
+		h.reconcileMetrics.startReconcile(key)
+		nodes = filterExcludedNodes(nodes, getExcludeNodeLabels(h.loadbalancerOpts)...)
+		err = provider.UpdateLoadBalancer(ctx, clusterName, service, nodes)
+		h.reconcileMetrics.finishReconcile(key, err)
+		logReconcileResult(err, "UpdateLoadBalancer", key, correlationID)
+		return err
+	})
 }
 
+// EnsureLoadBalancerDeleted deletes the ELB resources of the service, it is also called when the service
+// is changed away from LoadBalancer.
 func (h *CloudProvider) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
-	if !h.isSupportedSvc(service) {
+	if !h.isSupportedClass(service) || isUnmanaged(service) {
 		return cloudprovider.ImplementedElsewhere
 	}
+	if err := h.checkReadOnly("EnsureLoadBalancerDeleted"); err != nil {
+		return err
+	}
 	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	h.mutexLock.Lock(key)
-	defer h.mutexLock.Unlock(key)
+	ctx, correlationID := common.EnsureCorrelationID(ctx)
+	klog.InfoS("Reconciling the load balancer", "operation", "EnsureLoadBalancerDeleted", "service", key,
+		"correlationID", correlationID)
 
+	return h.reconcile(ctx, key, func(ctx context.Context) error {
+		return h.ensureLoadBalancerDeleted(ctx, clusterName, service, key, correlationID)
+	})
+}
+
+func (h *CloudProvider) ensureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service,
+	key, correlationID string) error {
 	LBVersion, err := getLoadBalancerVersion(service)
+	if err != nil && service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	provider, exist := h.providers[LBVersion]
+	provider, exist := h.getProvider(ctx, LBVersion, correlationID)
 	if !exist {
 		return nil
 	}
 
-	return provider.EnsureLoadBalancerDeleted(ctx, clusterName, service)
+	if isDeletionProtected(service, h.loadbalancerOpts) {
+		msg := fmt.Sprintf("the ELB is protected from deletion, remove the annotation %q or set it to false "+
+			"to delete it", ElbDeletionProtection)
+		klog.Warningf("skip deleting the ELB of service %s: %s", key, msg)
+		h.sendWarningEvent("DeletionProtected", msg, service)
+		// An error keeps the finalizer of the service, so the deletion is retried after the protection is removed.
+		return status.Errorf(codes.FailedPrecondition, "%s", msg)
+	}
+
+	err = provider.EnsureLoadBalancerDeleted(ctx, clusterName, service)
+	h.reconcileMetrics.finishDelete(key, err)
+	logReconcileResult(err, "EnsureLoadBalancerDeleted", key, correlationID)
+	return err
 }
 
 func getLoadBalancerVersion(service *v1.Service) (LoadBalanceVersion, error) {
-	class := service.Annotations[ElbClass]
+	class, _ := getAnnotation(service.Annotations, ElbClass)
 
 	switch class {
 	case "elasticity":
@@ -508,12 +795,92 @@ func (h *CloudProvider) HasClusterID() bool {
 
 // Initialize provides the cloud with a kubernetes client builder and may spawn goroutines
 // to perform housekeeping activities within the cloud provider.
+// The cache of availability zones and the probe of the credentials run in the background until stop is closed.
 func (h *CloudProvider) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	if h.azCache != nil {
+		go h.azCache.Run(stop)
+	}
+	if h.credentialProbe != nil {
+		go h.credentialProbe.Run(stop)
+	}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+		defer cancel()
+		if err := h.Shutdown(ctx); err != nil {
+			klog.Warningf("failed to wait for the in-flight reconciles on shutdown: %s", err)
+		}
+	}()
+}
+
+// Shutdown stops accepting new reconciles and waits for the in-flight ones to complete until ctx is done.
+// The scheduled deletions of the retired ELB instances are stopped, they are resumed by the next reconcile.
+func (h *CloudProvider) Shutdown(ctx context.Context) error {
+	return h.shutdown.shutdown(ctx)
+}
+
+// SetInformers implements cloudprovider.InformerUser, the cached data of the instances is purged
+// when their nodes are deleted or get their provider IDs, instead of being served until it expires.
+// The nodes are looked up by their provider IDs from the informer.
+func (h *CloudProvider) SetInformers(informerFactory informers.SharedInformerFactory) {
+	informer := informerFactory.Core().V1().Nodes().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: h.onNodeUpdated,
+		DeleteFunc: h.onNodeDeleted,
+	})
+	if err != nil {
+		klog.Errorf("failed to watch the deletions of the nodes: %s", err)
+	}
+
+	if err := informer.AddIndexers(cache.Indexers{nodeProviderIDIndex: indexNodeByProviderID}); err != nil {
+		klog.Errorf("failed to index the nodes by the provider IDs: %s", err)
+		return
+	}
+	if h.nodeIndexer != nil {
+		h.nodeIndexer.SetIndexer(informer.GetIndexer())
+	}
+}
+
+func (h *CloudProvider) onNodeDeleted(obj interface{}) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("detected that a node has been deleted, but the type conversion failed: %#v", obj)
+			return
+		}
+		if node, ok = tombstone.Obj.(*v1.Node); !ok {
+			klog.Errorf("detected that a node has been deleted, but the tombstone contains %#v", tombstone.Obj)
+			return
+		}
+	}
+	klog.V(4).Infof("detected that node %s has been deleted, provider ID: %s", node.Name, node.Spec.ProviderID)
+	h.InvalidateInstance(node.Spec.ProviderID)
+	if h.nameCache != nil {
+		h.nameCache.Delete(node.Name)
+	}
+}
+
+// onNodeUpdated purges the cached instance ID of the node once its provider ID is set,
+// the instance is looked up by the provider ID since then.
+func (h *CloudProvider) onNodeUpdated(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok || oldNode.Spec.ProviderID != "" || newNode.Spec.ProviderID == "" {
+		return
+	}
+	klog.V(4).Infof("detected that the provider ID of node %s is set: %s", newNode.Name, newNode.Spec.ProviderID)
+	if h.nameCache != nil {
+		h.nameCache.Delete(newNode.Name)
+	}
 }
 
 // TCPLoadBalancer returns an implementation of TCPLoadBalancer for Huawei Web Services.
 func (h *CloudProvider) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	// Only services with LoadBalancerClass=huaweicloud.com/elb are processed.
+	// Only the services of the configured class are processed, by the listeners of the services.
 	if h.loadbalancerOpts.LoadBalancerClass != "" {
 		return nil, false
 	}
@@ -523,16 +890,30 @@ func (h *CloudProvider) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
 
 // Instances returns an instances interface. Also returns true if the interface is supported, false otherwise.
 func (h *CloudProvider) Instances() (cloudprovider.Instances, bool) {
-	instance := &Instances{
-		Basic: h.Basic,
-	}
+	return h.newInstances(), true
+}
 
-	return instance, true
+// newInstances returns the Instances getting the ECS details by the ECS client of the cloud-config.
+func (h *CloudProvider) newInstances() *Instances {
+	return &Instances{
+		Basic:   h.Basic,
+		servers: h.ecsClient,
+		inProject: func(projectID string) serverGetter {
+			return h.ecsClient.InProject(projectID)
+		},
+	}
 }
 
 // Zones returns an implementation of Zones for Huawei Web Services.
 func (h *CloudProvider) Zones() (cloudprovider.Zones, bool) {
-	return nil, false
+	return &Zones{
+		Basic:    h.Basic,
+		servers:  h.ecsClient,
+		hostname: os.Hostname,
+		inProject: func(projectID string) serverGetter {
+			return h.ecsClient.InProject(projectID)
+		},
+	}, true
 }
 
 // Clusters returns an implementation of Clusters for Huawei Web Services.
@@ -541,8 +922,12 @@ func (h *CloudProvider) Clusters() (cloudprovider.Clusters, bool) {
 }
 
 // Routes returns an implementation of Routes for Huawei Web Services.
+// Only supported when the route table is specified in the cloud-config.
 func (h *CloudProvider) Routes() (cloudprovider.Routes, bool) {
-	return nil, false
+	if h.cloudConfig.VpcOpts.RouteTableID == "" {
+		return nil, false
+	}
+	return &Routes{Basic: h.Basic}, true
 }
 
 // ProviderName returns the cloud provider ID.
@@ -553,11 +938,7 @@ func (h *CloudProvider) ProviderName() string {
 // InstancesV2 is an implementation for instances and should only be implemented by external cloud providers.
 // Don't support this feature for now.
 func (h *CloudProvider) InstancesV2() (cloudprovider.InstancesV2, bool) {
-	instance := &Instances{
-		Basic: h.Basic,
-	}
-
-	return instance, true
+	return h.newInstances(), true
 }
 
 // ListClusters is an implementation of Clusters.ListClusters
@@ -691,7 +1072,19 @@ func (e *LoadBalancerServiceListener) startEndpointListener(handle func(*v1.Serv
 		_, err = serviceInformer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {},
 			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldSvs, _ := oldObj.(*v1.Service)
 				svs, _ := newObj.(*v1.Service)
+				// The class is cleared when the type is changed away from LoadBalancer,
+				// so the ELB resources are cleaned up with the old service.
+				if isChangedFromLoadBalancer(oldSvs, svs) && oldSvs.Spec.LoadBalancerClass != nil &&
+					e.isSupportedSvc(oldSvs) {
+					klog.Infof("Found service was changed from LoadBalancer to %s, namespace: %s, name: %s",
+						svs.Spec.Type, svs.Namespace, svs.Name)
+					e.goroutinePool.Submit(func() {
+						handle(oldSvs, true)
+					})
+					return
+				}
 				if svs.Spec.LoadBalancerClass == nil || !e.isSupportedSvc(svs) {
 					return
 				}
@@ -750,13 +1143,18 @@ func (e *LoadBalancerServiceListener) dispatcher(namespace, name, eType string,
 
 	klog.Infof("Dispatcher service, namespace: %s, name: %s", namespace, name)
 
-	if eType == endpointAdded && (svc.Spec.LoadBalancerClass == nil || *svc.Spec.LoadBalancerClass != LoadBalancerClass) {
+	if eType == endpointAdded && (svc.Spec.LoadBalancerClass == nil ||
+		*svc.Spec.LoadBalancerClass != e.getLoadBalancerClass()) {
 		return
 	}
 	handle(svc, false)
 }
 
 func (h *CloudProvider) listenerDeploy() error {
+	if h.isReadOnly() {
+		klog.Infof("The listeners are not started in the read-only mode")
+		return nil
+	}
 	listener := LoadBalancerServiceListener{
 		Basic:       h.Basic,
 		kubeClient:  h.kubeClient,
@@ -775,6 +1173,15 @@ func (h *CloudProvider) listenerDeploy() error {
 		stopChannel: make(chan struct{}, 1),
 	}
 
+	nodeListener := &NodeExclusionListener{
+		kubeClient:     h.kubeClient,
+		labelKeys:      getExcludeNodeLabels(h.loadbalancerOpts),
+		weightResource: h.loadbalancerOpts.MemberWeightResource,
+		drainCordoned:  h.loadbalancerOpts.DrainCordonedNodes,
+
+		stopChannel: make(chan struct{}, 1),
+	}
+
 	clusterName := h.cloudControllerManagerOpts.KubeCloudShared.ClusterName
 	id, err := os.Hostname()
 	if err != nil {
@@ -784,7 +1191,7 @@ func (h *CloudProvider) listenerDeploy() error {
 	go leaderElection(id, h.restConfig, h.eventRecorder, func(ctx context.Context) {
 		go secListener.startSecurityGroupListener()
 
-		listener.startEndpointListener(func(service *v1.Service, isDelete bool) {
+		handle := func(service *v1.Service, isDelete bool) {
 			klog.Infof("Got service %s/%s using loadbalancer class %s",
 				service.Namespace, service.Name, utils.ToString(service.Spec.LoadBalancerClass))
 
@@ -838,16 +1245,28 @@ func (h *CloudProvider) listenerDeploy() error {
 
 			klog.Errorf("failed to synchronization endpoint, service: %s/%s, error: %s",
 				service.Namespace, service.Name, err)
-		})
+		}
+		listener.startEndpointListener(handle)
+		go nodeListener.startNodeExclusionListener(handle)
+		if interval := h.loadbalancerOpts.OwnershipTagRepairInterval; interval > 0 {
+			go h.runOwnershipTagRepair(ctx, clusterName, time.Duration(interval)*time.Second)
+		}
 	}, func() {
 		listener.goroutinePool.Stop()
 		listener.stopListenerSlice()
 		secListener.stopSecurityGroupListener()
+		nodeListener.stopNodeExclusionListener()
 	})
 
 	return nil
 }
 
+// isChangedFromLoadBalancer returns true if the type of the service is changed away from LoadBalancer.
+func isChangedFromLoadBalancer(oldSvs, newSvs *v1.Service) bool {
+	return oldSvs != nil && newSvs != nil && oldSvs.Spec.Type == v1.ServiceTypeLoadBalancer &&
+		newSvs.Spec.Type != v1.ServiceTypeLoadBalancer
+}
+
 func leaderElection(id string, restConfig *rest.Config, recorder record.EventRecorder, onSuccess func(context.Context), onStop func()) {
 	leaseName := "endpoint-slice-listener"
 	leaseDuration := 60 * time.Second
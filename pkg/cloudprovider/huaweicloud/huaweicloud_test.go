@@ -0,0 +1,527 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+// fakeCleanupLoadBalancer stores the services that have the ELB resources.
+type fakeCleanupLoadBalancer struct {
+	fakeLoadBalancer
+	ensured map[string]bool
+}
+
+func (f *fakeCleanupLoadBalancer) GetLoadBalancer(_ context.Context, _ string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	return &v1.LoadBalancerStatus{}, f.ensured[service.Name], nil
+}
+
+func (f *fakeCleanupLoadBalancer) EnsureLoadBalancer(_ context.Context, _ string, service *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	f.ensured[service.Name] = true
+	return &v1.LoadBalancerStatus{}, nil
+}
+
+func (f *fakeCleanupLoadBalancer) EnsureLoadBalancerDeleted(_ context.Context, _ string, service *v1.Service) error {
+	delete(f.ensured, service.Name)
+	return nil
+}
+
+func TestServiceChangedToClusterIP(t *testing.T) {
+	lb := &fakeCleanupLoadBalancer{ensured: map[string]bool{}}
+	h := &CloudProvider{
+		Basic: Basic{
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	ctx := context.TODO()
+
+	service := newMetricsTestService("web")
+	if _, err := h.EnsureLoadBalancer(ctx, "kubernetes", service, []*v1.Node{newTestNode(nil)}); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	changed := service.DeepCopy()
+	changed.Spec.Type = v1.ServiceTypeClusterIP
+	if !isChangedFromLoadBalancer(service, changed) {
+		t.Fatalf("expected: the type change is detected, got: false")
+	}
+
+	// the service controller checks the ELB of the service changed away from LoadBalancer, then cleans it up.
+	_, exists, err := h.GetLoadBalancer(ctx, "kubernetes", changed)
+	if err != nil || !exists {
+		t.Fatalf("expected: the ELB exists, got: %v, %v", exists, err)
+	}
+	if err = h.EnsureLoadBalancerDeleted(ctx, "kubernetes", changed); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if lb.ensured["web"] {
+		t.Fatalf("expected: the ELB is cleaned up, got: it still exists")
+	}
+
+	// the ClusterIP service without the class annotation never has the ELB resources.
+	plain := newTestService(nil)
+	plain.Spec.Type = v1.ServiceTypeClusterIP
+	if _, exists, err = h.GetLoadBalancer(ctx, "kubernetes", plain); err != nil || exists {
+		t.Fatalf("expected: no ELB, got: %v, %v", exists, err)
+	}
+	if err = h.EnsureLoadBalancerDeleted(ctx, "kubernetes", plain); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	// the services of the other load balancer classes are left to their controllers.
+	other := changed.DeepCopy()
+	other.Spec.LoadBalancerClass = pointer.String("example.com/lb")
+	if err = h.EnsureLoadBalancerDeleted(ctx, "kubernetes", other); err != cloudprovider.ImplementedElsewhere {
+		t.Fatalf("expected: %v, got: %v", cloudprovider.ImplementedElsewhere, err)
+	}
+}
+
+// recordingLoadBalancer records the calls of the methods that mutate the ELB resources, and counts the lookups.
+type recordingLoadBalancer struct {
+	fakeLoadBalancer
+	mutations []string
+	gets      int
+}
+
+func (r *recordingLoadBalancer) GetLoadBalancer(_ context.Context, _ string, _ *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	r.gets++
+	return &v1.LoadBalancerStatus{}, true, nil
+}
+
+func (r *recordingLoadBalancer) EnsureLoadBalancer(_ context.Context, _ string, _ *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	r.mutations = append(r.mutations, "EnsureLoadBalancer")
+	return &v1.LoadBalancerStatus{}, nil
+}
+
+func (r *recordingLoadBalancer) UpdateLoadBalancer(_ context.Context, _ string, _ *v1.Service, _ []*v1.Node) error {
+	r.mutations = append(r.mutations, "UpdateLoadBalancer")
+	return nil
+}
+
+func (r *recordingLoadBalancer) EnsureLoadBalancerDeleted(_ context.Context, _ string, _ *v1.Service) error {
+	r.mutations = append(r.mutations, "EnsureLoadBalancerDeleted")
+	return nil
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	lb := &recordingLoadBalancer{}
+	cloudConfig := &config.CloudConfig{}
+	cloudConfig.AuthOpts.ReadOnly = true
+	h := &CloudProvider{
+		Basic: Basic{
+			cloudConfig:      cloudConfig,
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	ctx := context.TODO()
+	service := newMetricsTestService("web")
+	nodes := []*v1.Node{newTestNode(nil)}
+
+	// the read paths function normally.
+	if _, exists, err := h.GetLoadBalancer(ctx, "kubernetes", service); err != nil || !exists {
+		t.Fatalf("expected: the ELB exists, got: %v, %v", exists, err)
+	}
+
+	// the mutating paths are rejected before any client is called, the clients of the routes are nil.
+	routes := &Routes{Basic: h.Basic}
+	route := &cloudprovider.Route{DestinationCIDR: "10.0.1.0/24", TargetNode: "node-1"}
+	errs := map[string]error{
+		"EnsureLoadBalancer": func() error {
+			_, err := h.EnsureLoadBalancer(ctx, "kubernetes", service, nodes)
+			return err
+		}(),
+		"UpdateLoadBalancer":        h.UpdateLoadBalancer(ctx, "kubernetes", service, nodes),
+		"EnsureLoadBalancerDeleted": h.EnsureLoadBalancerDeleted(ctx, "kubernetes", service),
+		"CreateRoute":               routes.CreateRoute(ctx, "kubernetes", "node-1", route),
+		"DeleteRoute":               routes.DeleteRoute(ctx, "kubernetes", route),
+	}
+	for operation, err := range errs {
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected: %v of %s, got: %v", codes.FailedPrecondition, operation, err)
+		}
+	}
+	if len(lb.mutations) != 0 {
+		t.Fatalf("expected: no mutations, got: %v", lb.mutations)
+	}
+
+	// the services of the other load balancer classes are still left to their controllers.
+	other := service.DeepCopy()
+	other.Spec.LoadBalancerClass = pointer.String("example.com/lb")
+	if err := h.EnsureLoadBalancerDeleted(ctx, "kubernetes", other); err != cloudprovider.ImplementedElsewhere {
+		t.Fatalf("expected: %v, got: %v", cloudprovider.ImplementedElsewhere, err)
+	}
+
+	cloudConfig.AuthOpts.ReadOnly = false
+	if err := h.UpdateLoadBalancer(ctx, "kubernetes", service, nodes); err != nil || len(lb.mutations) != 1 {
+		t.Fatalf("expected: the update is called, got: %v, %v", lb.mutations, err)
+	}
+}
+
+// correlationLoadBalancer records the correlation IDs of the contexts that EnsureLoadBalancer is called with.
+type correlationLoadBalancer struct {
+	fakeLoadBalancer
+	correlationIDs []string
+}
+
+func (c *correlationLoadBalancer) EnsureLoadBalancer(ctx context.Context, _ string, _ *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	c.correlationIDs = append(c.correlationIDs, common.CorrelationIDFromContext(ctx))
+	return &v1.LoadBalancerStatus{}, nil
+}
+
+func TestCorrelationID(t *testing.T) {
+	lb := &correlationLoadBalancer{}
+	h := &CloudProvider{
+		Basic: Basic{
+			cloudConfig:      &config.CloudConfig{},
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	service := newMetricsTestService("web")
+	nodes := []*v1.Node{newTestNode(nil)}
+
+	// the correlation ID of the context is passed through, one is generated if it is absent.
+	ctx := common.WithCorrelationID(context.TODO(), "reconcile-1")
+	if _, err := h.EnsureLoadBalancer(ctx, "kubernetes", service, nodes); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if _, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if len(lb.correlationIDs) != 2 || lb.correlationIDs[0] != "reconcile-1" || lb.correlationIDs[1] == "" {
+		t.Fatalf("expected: [reconcile-1 <generated>], got: %v", lb.correlationIDs)
+	}
+
+	// the API clients of the provider send the correlation ID, the shared clients are not changed.
+	h.cloudConfig.AuthOpts.Region = "ap-southeast-1"
+	h.dedicatedELBClient = &wrapper.DedicatedLoadBalanceClient{AuthOpts: &h.cloudConfig.AuthOpts}
+	h.providers[VersionDedicated] = &DedicatedLoadBalancer{Basic: h.Basic}
+	reconcileCtx, cancel := context.WithCancel(context.TODO())
+	provider, _ := h.getProvider(reconcileCtx, VersionDedicated, "reconcile-1")
+	opts := provider.(*DedicatedLoadBalancer).dedicatedELBClient.AuthOpts
+	if opts.GetCorrelationID() != "reconcile-1" || opts.Region != "ap-southeast-1" {
+		t.Fatalf("expected: the correlation ID %v, got: %v", "reconcile-1", opts.GetCorrelationID())
+	}
+	// the API calls of the reconcile are stopped once it is cancelled, such as timing out.
+	cancel()
+	if _, err := opts.GetHcClient("elb"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if _, err := provider.(*DedicatedLoadBalancer).dedicatedELBClient.WaitStatusActive("elb-1"); !errors.Is(err,
+		context.Canceled) {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if got := h.dedicatedELBClient.AuthOpts.GetCorrelationID(); got != "" {
+		t.Fatalf("expected: the shared client is not changed, got: %v", got)
+	}
+	if err := h.dedicatedELBClient.AuthOpts.Context().Err(); err != nil {
+		t.Fatalf("expected: the shared client is not cancelled, got: %v", err)
+	}
+}
+
+// failingLoadBalancer fails the reconciles with the error.
+type failingLoadBalancer struct {
+	fakeLoadBalancer
+	err error
+}
+
+func (f *failingLoadBalancer) EnsureLoadBalancer(_ context.Context, _ string, _ *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	return nil, f.err
+}
+
+func TestLoadBalancerStructuredLogs(t *testing.T) {
+	lb := &failingLoadBalancer{
+		err: sdkerr.ServiceResponseError{StatusCode: 409, RequestId: "req-1", ErrorCode: "ELB.8902"},
+	}
+	h := &CloudProvider{
+		Basic: Basic{
+			cloudConfig:      &config.CloudConfig{},
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	service := newMetricsTestService("web")
+
+	ctx := common.WithCorrelationID(context.TODO(), "reconcile-1")
+	logs := captureLogs(func() {
+		_, _ = h.EnsureLoadBalancer(ctx, "kubernetes", service, []*v1.Node{newTestNode(nil)})
+	})
+	expected := []string{`"Reconciling the load balancer"`, `"Failed to reconcile the load balancer"`,
+		`operation="EnsureLoadBalancer"`, `service="default/web"`, `correlationID="reconcile-1"`,
+		`errorCode="ELB.8902"`, `statusCode=409`, `requestID="req-1"`}
+	for _, field := range expected {
+		if !strings.Contains(logs, field) {
+			t.Fatalf("expected: %v, got: %v", field, logs)
+		}
+	}
+}
+
+func TestDeletionProtection(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		opts        *config.LoadBalancerOptions
+		protected   bool
+	}{
+		{
+			name:        "protected by annotation",
+			annotations: map[string]string{ElbDeletionProtection: "true"},
+			opts:        &config.LoadBalancerOptions{},
+			protected:   true,
+		},
+		{
+			name:      "protected by default",
+			opts:      &config.LoadBalancerOptions{DeletionProtection: true},
+			protected: true,
+		},
+		{
+			name:        "protection removed",
+			annotations: map[string]string{ElbDeletionProtection: "false"},
+			opts:        &config.LoadBalancerOptions{DeletionProtection: true},
+		},
+		{
+			name: "not protected",
+			opts: &config.LoadBalancerOptions{},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			lb := &recordingLoadBalancer{}
+			recorder := record.NewFakeRecorder(10)
+			h := &CloudProvider{
+				Basic: Basic{
+					cloudConfig:      &config.CloudConfig{},
+					loadbalancerOpts: te.opts,
+					reconcileSem:     semaphore.NewSemaphore(0),
+					reconcileMetrics: newReconcileMetrics(),
+					mutexLock:        mutexkv.NewMutexKV(),
+					eventRecorder:    recorder,
+				},
+				providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+			}
+			service := newMetricsTestService("web")
+			for k, v := range te.annotations {
+				service.Annotations[k] = v
+			}
+
+			err := h.EnsureLoadBalancerDeleted(context.TODO(), "kubernetes", service)
+			if !te.protected {
+				if err != nil || len(lb.mutations) != 1 || len(recorder.Events) != 0 {
+					t.Fatalf("expected: the ELB is deleted, got: %v, %v", lb.mutations, err)
+				}
+				return
+			}
+			// the deletion is skipped and retried, the finalizer of the service is kept by the error.
+			if status.Code(err) != codes.FailedPrecondition || len(lb.mutations) != 0 {
+				t.Fatalf("expected: %v, got: %v, %v", codes.FailedPrecondition, lb.mutations, err)
+			}
+			if event := <-recorder.Events; !strings.Contains(event, "DeletionProtected") {
+				t.Fatalf("expected: a DeletionProtected event, got: %v", event)
+			}
+		})
+	}
+}
+
+func TestIsChangedFromLoadBalancer(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldType  v1.ServiceType
+		newType  v1.ServiceType
+		expected bool
+	}{
+		{name: "to ClusterIP", oldType: v1.ServiceTypeLoadBalancer, newType: v1.ServiceTypeClusterIP, expected: true},
+		{name: "to NodePort", oldType: v1.ServiceTypeLoadBalancer, newType: v1.ServiceTypeNodePort, expected: true},
+		{name: "unchanged", oldType: v1.ServiceTypeLoadBalancer, newType: v1.ServiceTypeLoadBalancer, expected: false},
+		{name: "to LoadBalancer", oldType: v1.ServiceTypeClusterIP, newType: v1.ServiceTypeLoadBalancer, expected: false},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			oldSvs, newSvs := newTestService(nil), newTestService(nil)
+			oldSvs.Spec.Type, newSvs.Spec.Type = te.oldType, te.newType
+			if got := isChangedFromLoadBalancer(oldSvs, newSvs); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestNodeDeletedInvalidatesInstance(t *testing.T) {
+	h := &CloudProvider{Basic: Basic{addressCache: NewNodeAddressCache(time.Minute)}}
+	build := func() ([]v1.NodeAddress, error) {
+		return []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}, nil
+	}
+
+	node := newTestNode(nil)
+	node.Spec.ProviderID = "huaweicloud://instance-1"
+	tests := []struct {
+		name string
+		obj  interface{}
+	}{
+		{name: "node", obj: node},
+		{name: "tombstone", obj: cache.DeletedFinalStateUnknown{Key: node.Name, Obj: node}},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			if _, err := h.addressCache.Get("instance-1", "2023-06-01T08:00:00Z", build); err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			h.onNodeDeleted(te.obj)
+			if _, ok := h.addressCache.get("instance-1", "2023-06-01T08:00:00Z"); ok {
+				t.Fatalf("expected: the entry is purged, got: it is cached")
+			}
+		})
+	}
+
+	// the unknown objects are ignored.
+	h.onNodeDeleted("node-1")
+}
+
+func TestLoadBalancerClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		class      *string
+		supported  bool
+		provided   bool
+	}{
+		{name: "empty class by default", configured: "", class: nil, supported: true, provided: true},
+		{name: "default class", configured: "", class: pointer.String(LoadBalancerClass), supported: true,
+			provided: true},
+		{name: "other class by default", configured: "", class: pointer.String("example.com/lb"), provided: true},
+		{name: "matching class", configured: "example.com/elb", class: pointer.String("example.com/elb"),
+			supported: true},
+		{name: "non-matching class", configured: "example.com/elb", class: pointer.String(LoadBalancerClass)},
+		{name: "empty class with the configured class", configured: "example.com/elb", class: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := &recordingLoadBalancer{}
+			h := &CloudProvider{
+				Basic: Basic{
+					cloudConfig:      &config.CloudConfig{},
+					loadbalancerOpts: &config.LoadBalancerOptions{LoadBalancerClass: tt.configured},
+					reconcileSem:     semaphore.NewSemaphore(0),
+					reconcileMetrics: newReconcileMetrics(),
+					shutdown:         newShutdownGuard(),
+					mutexLock:        mutexkv.NewMutexKV(),
+				},
+				providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+			}
+			service := newMetricsTestService("web")
+			service.Spec.LoadBalancerClass = tt.class
+
+			if _, provided := h.LoadBalancer(); provided != tt.provided {
+				t.Fatalf("expected the default load balancer: %v, got: %v", tt.provided, provided)
+			}
+			_, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, []*v1.Node{newTestNode(nil)})
+			if tt.supported {
+				if err != nil || len(lb.mutations) != 1 {
+					t.Fatalf("expected: the service is reconciled, got: %v, %v", lb.mutations, err)
+				}
+				return
+			}
+			if err != cloudprovider.ImplementedElsewhere || len(lb.mutations) != 0 {
+				t.Fatalf("expected: %v, got: %v, %v", cloudprovider.ImplementedElsewhere, lb.mutations, err)
+			}
+		})
+	}
+}
+
+func TestUnmanagedService(t *testing.T) {
+	lb := &recordingLoadBalancer{}
+	h := &CloudProvider{
+		Basic: Basic{
+			cloudConfig:      &config.CloudConfig{},
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			shutdown:         newShutdownGuard(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	service := newMetricsTestService("web")
+	service.Annotations[ElbUnmanaged] = "true"
+	service.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "192.168.0.100"}}
+	nodes := []*v1.Node{newTestNode(nil)}
+
+	lbStatus, exists, err := h.GetLoadBalancer(context.TODO(), "kubernetes", service)
+	if err != nil || !exists || !reflect.DeepEqual(*lbStatus, service.Status.LoadBalancer) {
+		t.Fatalf("expected: the current status, got: %v, %v, %v", lbStatus, exists, err)
+	}
+	_, ensureErr := h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes)
+	updateErr := h.UpdateLoadBalancer(context.TODO(), "kubernetes", service, nodes)
+	deleteErr := h.EnsureLoadBalancerDeleted(context.TODO(), "kubernetes", service)
+	for _, err := range []error{ensureErr, updateErr, deleteErr} {
+		if err != cloudprovider.ImplementedElsewhere {
+			t.Fatalf("expected: %v, got: %v", cloudprovider.ImplementedElsewhere, err)
+		}
+	}
+	if len(lb.mutations) != 0 || lb.gets != 0 {
+		t.Fatalf("expected: no operations, got: %v and %d lookups", lb.mutations, lb.gets)
+	}
+
+	// the service is reconciled again once the annotation is set to false.
+	service.Annotations[ElbUnmanaged] = "false"
+	if _, err = h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if _, _, err = h.GetLoadBalancer(context.TODO(), "kubernetes", service); err != nil || lb.gets != 1 {
+		t.Fatalf("expected: the ELB is looked up, got: %d lookups, %v", lb.gets, err)
+	}
+	if !reflect.DeepEqual(lb.mutations, []string{"EnsureLoadBalancer"}) {
+		t.Fatalf("expected: %v, got: %v", []string{"EnsureLoadBalancer"}, lb.mutations)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	eipmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	elbmodelv2 "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+)
+
+const operationCreateEIP = "create-eip"
+
+// eipCreator creates the EIPs, and lists them by the alias to find the one created by a previous attempt.
+type eipCreator interface {
+	Create(req *eipmodel.CreatePublicipRequestBody) (*eipmodel.PublicipCreateResp, error)
+	ListByAlias(alias string) ([]eipmodel.PublicipShowResp, error)
+}
+
+// dedicatedLoadBalancerCreator creates the dedicated ELB instances, and lists them to find the one created by
+// a previous attempt.
+type dedicatedLoadBalancerCreator interface {
+	CreateInstance(opt *elbmodel.CreateLoadBalancerOption) (*elbmodel.LoadBalancer, error)
+	ListInstances(req *elbmodel.ListLoadBalancersRequest) ([]elbmodel.LoadBalancer, error)
+}
+
+// sharedLoadBalancerCreator creates the shared ELB instances, and lists them to find the one created by
+// a previous attempt.
+type sharedLoadBalancerCreator interface {
+	CreateInstance(req *elbmodelv2.CreateLoadbalancerReq) (*elbmodelv2.LoadbalancerResp, error)
+	ListInstances(req *elbmodelv2.ListLoadbalancersRequest) ([]elbmodelv2.LoadbalancerResp, error)
+}
+
+// getIdempotencyToken returns a token derived from the UID of the service and the operation,
+// so the retries of the operation for the same service get the same token.
+func getIdempotencyToken(service *v1.Service, operation string) string {
+	id := string(service.UID)
+	if id == "" {
+		id = fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+	}
+	sum := sha256.Sum256([]byte(id + "/" + operation))
+	return fmt.Sprintf("k8s-%s-%s", operation, hex.EncodeToString(sum[:])[:16])
+}
+
+// createEIPWithToken creates an EIP with the token as its alias. The EIP APIs do not accept a client token,
+// so if an unbound EIP with the alias exists, it is created by a previous attempt that timed out,
+// and it is reused instead of creating a duplicate one.
+func createEIPWithToken(client eipCreator, token string, req *eipmodel.CreatePublicipRequestBody) (string, error) {
+	ips, err := client.ListByAlias(token)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if ip.Alias == nil || *ip.Alias != token || ip.Id == nil {
+			continue
+		}
+		if ip.PortId != nil && *ip.PortId != "" {
+			continue
+		}
+		klog.Infof("found the EIP %s created by a previous attempt with token %s, reusing it", *ip.Id, token)
+		return *ip.Id, nil
+	}
+
+	if req.Publicip != nil {
+		req.Publicip.Alias = &token
+	}
+	eip, err := client.Create(req)
+	if err != nil {
+		return "", err
+	}
+	return *eip.Id, nil
+}
+
+// createDedicatedLoadBalancerOnce creates the dedicated ELB instance and returns its ID. The ELB APIs do not accept
+// a client token either, the description of the instance identifies the service by its UID, so if an instance with
+// the name and the description exists, it is created by a previous attempt that timed out, and it is reused instead
+// of creating a duplicate one. The EIP created along with the instance is reused with it.
+func createDedicatedLoadBalancerOnce(client dedicatedLoadBalancerCreator, opt *elbmodel.CreateLoadBalancerOption,
+) (string, error) {
+	list, err := client.ListInstances(&elbmodel.ListLoadBalancersRequest{
+		Name:        &[]string{pointer.StringDeref(opt.Name, "")},
+		Description: &[]string{pointer.StringDeref(opt.Description, "")},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(list) > 0 {
+		klog.Infof("found the ELB %s created by a previous attempt, reusing it", list[0].Id)
+		return list[0].Id, nil
+	}
+
+	loadbalancer, err := client.CreateInstance(opt)
+	if err != nil {
+		return "", err
+	}
+	return loadbalancer.Id, nil
+}
+
+// createSharedLoadBalancerOnce creates the shared ELB instance and returns its ID, the instance created by
+// a previous attempt is reused as createDedicatedLoadBalancerOnce does.
+func createSharedLoadBalancerOnce(client sharedLoadBalancerCreator, req *elbmodelv2.CreateLoadbalancerReq,
+) (string, error) {
+	list, err := client.ListInstances(&elbmodelv2.ListLoadbalancersRequest{
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(list) > 0 {
+		klog.Infof("found the ELB %s created by a previous attempt, reusing it", list[0].Id)
+		return list[0].Id, nil
+	}
+
+	loadbalancer, err := client.CreateInstance(req)
+	if err != nil {
+		return "", err
+	}
+	return loadbalancer.Id, nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	eipmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	elbmodelv2 "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeEIPCreator stores the created EIPs, the creates in timeouts take effect but return an error.
+type fakeEIPCreator struct {
+	eips     []eipmodel.PublicipShowResp
+	timeouts int
+}
+
+func (f *fakeEIPCreator) Create(req *eipmodel.CreatePublicipRequestBody) (*eipmodel.PublicipCreateResp, error) {
+	id := fmt.Sprintf("eip-%d", len(f.eips)+1)
+	f.eips = append(f.eips, eipmodel.PublicipShowResp{Id: &id, Alias: req.Publicip.Alias})
+	if f.timeouts > 0 {
+		f.timeouts--
+		return nil, fmt.Errorf("request timeout")
+	}
+	return &eipmodel.PublicipCreateResp{Id: &id}, nil
+}
+
+func (f *fakeEIPCreator) ListByAlias(alias string) ([]eipmodel.PublicipShowResp, error) {
+	rst := make([]eipmodel.PublicipShowResp, 0)
+	for _, ip := range f.eips {
+		if ip.Alias != nil && *ip.Alias == alias {
+			rst = append(rst, ip)
+		}
+	}
+	return rst, nil
+}
+
+// fakeSharedELBCreator stores the created shared ELB instances, the creates in timeouts take effect but return
+// an error.
+type fakeSharedELBCreator struct {
+	instances []elbmodelv2.LoadbalancerResp
+	timeouts  int
+}
+
+func (f *fakeSharedELBCreator) CreateInstance(req *elbmodelv2.CreateLoadbalancerReq) (*elbmodelv2.LoadbalancerResp,
+	error) {
+	lb := elbmodelv2.LoadbalancerResp{
+		Id:          fmt.Sprintf("elb-%d", len(f.instances)+1),
+		Name:        *req.Name,
+		Description: *req.Description,
+	}
+	f.instances = append(f.instances, lb)
+	if f.timeouts > 0 {
+		f.timeouts--
+		return nil, fmt.Errorf("request timeout")
+	}
+	return &lb, nil
+}
+
+func (f *fakeSharedELBCreator) ListInstances(req *elbmodelv2.ListLoadbalancersRequest) ([]elbmodelv2.LoadbalancerResp,
+	error) {
+	rst := make([]elbmodelv2.LoadbalancerResp, 0)
+	for _, lb := range f.instances {
+		if lb.Name == *req.Name && lb.Description == *req.Description {
+			rst = append(rst, lb)
+		}
+	}
+	return rst, nil
+}
+
+func newCreatePublicipRequestBody() *eipmodel.CreatePublicipRequestBody {
+	return &eipmodel.CreatePublicipRequestBody{Publicip: &eipmodel.CreatePublicipOption{Type: "5_bgp"}}
+}
+
+func TestGetIdempotencyToken(t *testing.T) {
+	service := newTestService(nil)
+	service.UID = types.UID("0d6a1f5e-2b1c-4c4e-9f0a-7e6d5c4b3a21")
+
+	token := getIdempotencyToken(service, operationCreateEIP)
+	if !strings.HasPrefix(token, "k8s-create-eip-") {
+		t.Fatalf("expected: token prefixed with k8s-create-eip-, got: %v", token)
+	}
+	if again := getIdempotencyToken(service.DeepCopy(), operationCreateEIP); again != token {
+		t.Fatalf("expected: %v, got: %v", token, again)
+	}
+
+	recreated := service.DeepCopy()
+	recreated.UID = types.UID("5f4e3d2c-1b0a-4978-8695-a4b3c2d1e0f9")
+	if other := getIdempotencyToken(recreated, operationCreateEIP); other == token {
+		t.Fatalf("expected: a different token for a different UID, got: %v", other)
+	}
+	if other := getIdempotencyToken(service, "create-elb"); other == token {
+		t.Fatalf("expected: a different token for a different operation, got: %v", other)
+	}
+}
+
+func TestCreateEIPWithTokenRetry(t *testing.T) {
+	service := newTestService(nil)
+	service.UID = types.UID("0d6a1f5e-2b1c-4c4e-9f0a-7e6d5c4b3a21")
+	token := getIdempotencyToken(service, operationCreateEIP)
+	client := &fakeEIPCreator{timeouts: 1}
+
+	if _, err := createEIPWithToken(client, token, newCreatePublicipRequestBody()); err == nil {
+		t.Fatalf("expected: timeout error, got: nil")
+	}
+
+	id, err := createEIPWithToken(client, token, newCreatePublicipRequestBody())
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if id != "eip-1" {
+		t.Fatalf("expected: %v, got: %v", "eip-1", id)
+	}
+	if len(client.eips) != 1 {
+		t.Fatalf("expected: 1 EIP, got: %v", len(client.eips))
+	}
+}
+
+func TestCreateEIPWithTokenNotReused(t *testing.T) {
+	token := getIdempotencyToken(newTestService(nil), operationCreateEIP)
+	otherID, boundID, portID, otherToken := "eip-other", "eip-bound", "port-id", "k8s-create-eip-other"
+	client := &fakeEIPCreator{eips: []eipmodel.PublicipShowResp{
+		{Id: &otherID, Alias: &otherToken},
+		{Id: &boundID, Alias: &token, PortId: &portID},
+	}}
+
+	id, err := createEIPWithToken(client, token, newCreatePublicipRequestBody())
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if id != "eip-3" {
+		t.Fatalf("expected: a new EIP eip-3, got: %v", id)
+	}
+	if alias := client.eips[2].Alias; alias == nil || *alias != token {
+		t.Fatalf("expected: %v, got: %v", token, alias)
+	}
+}
+
+func TestCreateLoadBalancerOnceRetry(t *testing.T) {
+	service := newTestService(nil)
+	service.UID = types.UID("0d6a1f5e-2b1c-4c4e-9f0a-7e6d5c4b3a21")
+	name, desc := "k8s_service_test", getLoadBalancerDescription("kubernetes", service)
+
+	dedicated := &fakeInstanceReplacer{timeouts: 1}
+	opt := &elbmodel.CreateLoadBalancerOption{Name: &name, Description: &desc}
+	if _, err := createDedicatedLoadBalancerOnce(dedicated, opt); err == nil {
+		t.Fatalf("expected: timeout error, got: nil")
+	}
+	id, err := createDedicatedLoadBalancerOnce(dedicated, opt)
+	if err != nil || id != "elb-1" || len(dedicated.instances) != 1 {
+		t.Fatalf("expected: elb-1 created once, got: %v of %d, %v", id, len(dedicated.instances), err)
+	}
+
+	// the instance of another service with the same name is not reused.
+	otherDesc := getLoadBalancerDescription("kubernetes", newTestService(nil))
+	id, err = createDedicatedLoadBalancerOnce(dedicated, &elbmodel.CreateLoadBalancerOption{
+		Name: &name, Description: &otherDesc})
+	if err != nil || id != "elb-2" {
+		t.Fatalf("expected: elb-2, got: %v, %v", id, err)
+	}
+
+	shared := &fakeSharedELBCreator{timeouts: 1}
+	req := &elbmodelv2.CreateLoadbalancerReq{Name: &name, Description: &desc}
+	if _, err := createSharedLoadBalancerOnce(shared, req); err == nil {
+		t.Fatalf("expected: timeout error, got: nil")
+	}
+	id, err = createSharedLoadBalancerOnce(shared, req)
+	if err != nil || id != "elb-1" || len(shared.instances) != 1 {
+		t.Fatalf("expected: elb-1 created once, got: %v of %d, %v", id, len(shared.instances), err)
+	}
+}
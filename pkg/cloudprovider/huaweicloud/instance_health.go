@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NodeConditionInstanceUnhealthy is true if the ECS of the node is in a status that the node may fail soon,
+	// before the node becomes NotReady.
+	NodeConditionInstanceUnhealthy v1.NodeConditionType = "InstanceUnhealthy"
+
+	// TaintInstanceUnhealthy is the key of the taint recommended for the unhealthy ECS, its value is the status.
+	TaintInstanceUnhealthy = "node.cloudprovider.huaweicloud.com/instance-unhealthy"
+)
+
+// InstanceHealth is the node condition and taint recommended for an ECS status.
+type InstanceHealth struct {
+	Condition v1.NodeCondition
+	Taint     v1.Taint
+}
+
+// unhealthyStatuses are the ECS statuses that are mapped to the condition and taint, by the reason and the effect.
+// The SHUTOFF status is not included, it is reported by InstanceShutdown.
+var unhealthyStatuses = map[string]struct {
+	reason string
+	effect v1.TaintEffect
+}{
+	"ERROR":       {reason: "InstanceError", effect: v1.TaintEffectNoExecute},
+	"REBOOT":      {reason: "InstanceRebooting", effect: v1.TaintEffectNoSchedule},
+	"HARD_REBOOT": {reason: "InstanceRebooting", effect: v1.TaintEffectNoSchedule},
+	"MIGRATING":   {reason: "InstanceMigrating", effect: v1.TaintEffectPreferNoSchedule},
+}
+
+// GetInstanceHealth returns the node condition and taint recommended for the ECS status, nil if the status is
+// healthy or not mapped, such as ACTIVE and SHUTOFF. It only recommends, the node is not updated.
+func GetInstanceHealth(ecsStatus string) *InstanceHealth {
+	ecsStatus = strings.ToUpper(ecsStatus)
+	mapped, ok := unhealthyStatuses[ecsStatus]
+	if !ok {
+		return nil
+	}
+	return &InstanceHealth{
+		Condition: v1.NodeCondition{
+			Type:    NodeConditionInstanceUnhealthy,
+			Status:  v1.ConditionTrue,
+			Reason:  mapped.reason,
+			Message: fmt.Sprintf("The ECS of the node is in the %s status", ecsStatus),
+		},
+		Taint: v1.Taint{
+			Key:    TaintInstanceUnhealthy,
+			Value:  ecsStatus,
+			Effect: mapped.effect,
+		},
+	}
+}
+
+// InstanceHealthByProviderID returns the node condition and taint recommended for the status of the instance,
+// nil if the instance is healthy.
+func (i *Instances) InstanceHealthByProviderID(_ context.Context, providerID string) (*InstanceHealth, error) {
+	klog.Infof("InstanceHealthByProviderID is called with provider ID %s", providerID)
+	projectID, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		return nil, err
+	}
+	server, err := i.ecsClient.InProject(projectID).Get(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetInstanceHealth(server.Status), nil
+}
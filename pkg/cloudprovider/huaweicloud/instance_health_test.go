@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetInstanceHealth(t *testing.T) {
+	tests := []struct {
+		status string
+		reason string
+		effect v1.TaintEffect
+	}{
+		{status: "ERROR", reason: "InstanceError", effect: v1.TaintEffectNoExecute},
+		{status: "REBOOT", reason: "InstanceRebooting", effect: v1.TaintEffectNoSchedule},
+		{status: "HARD_REBOOT", reason: "InstanceRebooting", effect: v1.TaintEffectNoSchedule},
+		{status: "MIGRATING", reason: "InstanceMigrating", effect: v1.TaintEffectPreferNoSchedule},
+		{status: "migrating", reason: "InstanceMigrating", effect: v1.TaintEffectPreferNoSchedule},
+		{status: "ACTIVE"},
+		{status: "SHUTOFF"},
+		{status: "BUILD"},
+		{status: ""},
+	}
+
+	for _, te := range tests {
+		t.Run(te.status, func(t *testing.T) {
+			health := GetInstanceHealth(te.status)
+			if te.reason == "" {
+				if health != nil {
+					t.Fatalf("expected: nil, got: %+v", health)
+				}
+				return
+			}
+			if health == nil {
+				t.Fatalf("expected: %v, got: nil", te.reason)
+			}
+
+			condition := health.Condition
+			if condition.Type != NodeConditionInstanceUnhealthy || condition.Status != v1.ConditionTrue ||
+				condition.Reason != te.reason {
+				t.Fatalf("expected: %v %v %v, got: %+v", NodeConditionInstanceUnhealthy, v1.ConditionTrue,
+					te.reason, condition)
+			}
+			taint := health.Taint
+			if taint.Key != TaintInstanceUnhealthy || taint.Effect != te.effect || taint.Value == "" {
+				t.Fatalf("expected: %v %v, got: %+v", TaintInstanceUnhealthy, te.effect, taint)
+			}
+		})
+	}
+}
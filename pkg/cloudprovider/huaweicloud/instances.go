@@ -18,172 +18,825 @@ package huaweicloud
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
 	"k8s.io/klog/v2"
 
+	wpmodel "sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/model"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
 )
 
 const (
 	instanceShutoffStatus = "SHUTOFF"
+	// The addresses of the ECS in the BUILD status may not be assigned yet.
+	instanceBuildStatus    = "BUILD"
+	instanceBuildingStatus = "BUILDING"
+
+	// maxServerIDsPerList limits the number of IDs in one query to keep the request URL short.
+	maxServerIDsPerList = 100
+
+	// newNodeLookupRetryInterval is the initial interval between the retries of the lookup of the ECS of a new node,
+	// it grows by newNodeLookupRetryFactor after each retry.
+	newNodeLookupRetryInterval = time.Second
+	newNodeLookupRetryFactor   = 1.5
 )
 
-var providerIDRegexp = regexp.MustCompile(`^` + ProviderName + `://([^/]+)$`)
+// providerIDPrefix is the prefix of the provider IDs set to spec.providerID of the nodes.
+const providerIDPrefix = ProviderName + "://"
+
+// providerIDRegexp matches huaweicloud://InstanceID, or huaweicloud://ProjectID/InstanceID
+// if the instance is in another project than the one of the cloud-config.
+var providerIDRegexp = regexp.MustCompile(`^` + providerIDPrefix + `(?:([0-9a-f]{32})/)?([^/]+)$`)
 
 type Instances struct {
 	Basic
+	// servers gets the ECS details of the exported getters, such as GetServerByName.
+	servers serverGetter
+	// inProject returns the getter scoped to the project of the provider ID, servers is used if it is nil.
+	inProject func(projectID string) serverGetter
+}
+
+// getServers returns the getter of the project, the default one if the project is empty.
+func (i *Instances) getServers(projectID string) serverGetter {
+	if projectID == "" || i.inProject == nil {
+		return i.servers
+	}
+	return i.inProject(projectID)
+}
+
+// serverLister lists the ECS details of the specified IDs, the IDs that do not exist are ignored.
+type serverLister interface {
+	ListByIDs(ids []string) ([]wpmodel.ServerDetail, error)
 }
 
 // NodeAddresses returns the addresses of the specified instance.
 func (i *Instances) NodeAddresses(ctx context.Context, name types.NodeName) ([]v1.NodeAddress, error) {
-	klog.Infof("NodeAddresses is called with name %s", name)
+	klog.InfoS("Instances API is called", "operation", "NodeAddresses", "node", name)
+	node := i.getNode(ctx, string(name))
+	if addresses, ok, err := getAnnotatedNodeAddresses(node, i.cloudConfig.AuthOpts.NodeAddressesAnnotation); ok {
+		return addresses, err
+	}
+
 	instance, err := i.ecsClient.GetByNodeName(string(name))
 	if err != nil {
 		return nil, err
 	}
-	return i.NodeAddressesByProviderID(ctx, instance.Id)
+	addresses, err := i.getNodeAddressesByProviderID(instance.Id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return addresses, nil
+	}
+	return applyProvidedNodeIP(addresses, node.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]), nil
+}
+
+// getNode returns the node by the name, nil is returned if the node can not be got.
+func (i *Instances) getNode(ctx context.Context, name string) *v1.Node {
+	if i.kubeClient == nil {
+		return nil
+	}
+	node, err := i.kubeClient.Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		klog.V(4).Infof("failed to get node %s, ignore the annotations of the node: %s", name, err)
+		return nil
+	}
+	return node
+}
+
+// getNodeByProviderID returns the node with the provider ID, nil is returned if it is not found.
+// The node is looked up from the node informer. Without the informer, the nodes are only listed if the addresses
+// can be annotated to the nodes, so that the lookups of the addresses do not list all the nodes each time.
+func (i *Instances) getNodeByProviderID(ctx context.Context, providerID string) *v1.Node {
+	if node, ok := i.nodeIndexer.GetByProviderID(providerID); ok {
+		return node
+	}
+	if i.kubeClient == nil || i.cloudConfig.AuthOpts.NodeAddressesAnnotation == "" {
+		return nil
+	}
+	// the nodes are listed from the cache of the API server.
+	nodes, err := i.kubeClient.Nodes().List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		klog.V(4).Infof("failed to list nodes, ignore the annotations of the node %s: %s", providerID, err)
+		return nil
+	}
+	for idx := range nodes.Items {
+		if nodes.Items[idx].Spec.ProviderID == providerID {
+			return &nodes.Items[idx]
+		}
+	}
+	return nil
+}
+
+// getAnnotatedNodeAddresses returns the addresses in the annotation of the node, which is in the JSON format of
+// the node addresses, such as [{"type": "InternalIP", "address": "192.168.0.10"}]. The addresses are returned
+// as they are, the allowed types and the denied CIDRs are not applied. The second return value is false
+// if the annotation key is empty or the node does not have the annotation.
+func getAnnotatedNodeAddresses(node *v1.Node, key string) ([]v1.NodeAddress, bool, error) {
+	if node == nil || key == "" {
+		return nil, false, nil
+	}
+	value, ok := node.Annotations[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	addresses := make([]v1.NodeAddress, 0)
+	if err := json.Unmarshal([]byte(value), &addresses); err != nil {
+		return nil, true, status.Errorf(codes.InvalidArgument, "invalid node addresses in annotation %s "+
+			"of node %s: %s", key, node.Name, err)
+	}
+	if len(addresses) == 0 {
+		return nil, true, status.Errorf(codes.InvalidArgument, "no node addresses in annotation %s of node %s",
+			key, node.Name)
+	}
+	for _, addr := range addresses {
+		switch addr.Type {
+		case v1.NodeInternalIP, v1.NodeExternalIP:
+			if net.ParseIP(addr.Address) == nil {
+				return nil, true, status.Errorf(codes.InvalidArgument, "invalid IP %q in annotation %s of node %s",
+					addr.Address, key, node.Name)
+			}
+		case v1.NodeHostName, v1.NodeInternalDNS, v1.NodeExternalDNS:
+			if addr.Address == "" {
+				return nil, true, status.Errorf(codes.InvalidArgument, "empty %s in annotation %s of node %s",
+					addr.Type, key, node.Name)
+			}
+		default:
+			return nil, true, status.Errorf(codes.InvalidArgument, "unsupported address type %q in annotation %s "+
+				"of node %s", addr.Type, key, node.Name)
+		}
+	}
+	klog.InfoS("Node addresses are resolved from the annotation", "node", node.Name, "annotation", key,
+		"addresses", addresses)
+	return addresses, true, nil
+}
+
+// applyProvidedNodeIP honors the IP chosen by kubelet. If the provided IP is one of the InternalIP addresses,
+// it is the only InternalIP returned and comes first, the addresses of other types are kept.
+// Otherwise, all the addresses are returned.
+func applyProvidedNodeIP(addresses []v1.NodeAddress, providedIP string) []v1.NodeAddress {
+	providedIP = strings.TrimSpace(providedIP)
+	if providedIP == "" {
+		return addresses
+	}
+
+	var matched *v1.NodeAddress
+	for idx := range addresses {
+		if addresses[idx].Type == v1.NodeInternalIP && addresses[idx].Address == providedIP {
+			matched = &addresses[idx]
+			break
+		}
+	}
+	if matched == nil {
+		klog.Warningf("the provided node IP %s is not an InternalIP of the node, return all addresses: %v",
+			providedIP, addresses)
+		return addresses
+	}
+
+	result := []v1.NodeAddress{*matched}
+	for _, addr := range addresses {
+		if addr.Type == v1.NodeInternalIP {
+			continue
+		}
+		result = append(result, addr)
+	}
+	return result
 }
 
 // NodeAddressesByProviderID returns the addresses of the specified instance.
-func (i *Instances) NodeAddressesByProviderID(_ context.Context, providerID string) ([]v1.NodeAddress, error) {
-	klog.Infof("NodeAddressesByProviderID is called with provider ID %s", providerID)
-	instanceID, err := parseInstanceID(providerID)
+func (i *Instances) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
+	klog.InfoS("Instances API is called", "operation", "NodeAddressesByProviderID", "providerID", providerID)
+	node := i.getNodeByProviderID(ctx, providerID)
+	if addresses, ok, err := getAnnotatedNodeAddresses(node, i.cloudConfig.AuthOpts.NodeAddressesAnnotation); ok {
+		return addresses, err
+	}
+
+	addresses, err := i.getNodeAddressesByProviderID(providerID)
+	if err != nil || node == nil {
+		return addresses, err
+	}
+	return applyProvidedNodeIP(addresses, node.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]), nil
+}
+
+// getNodeAddressesByProviderID looks up the addresses of the instance by the API, or the metadata service if the
+// CCM runs on the instance.
+func (i *Instances) getNodeAddressesByProviderID(providerID string) ([]v1.NodeAddress, error) {
+	projectID, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		return nil, err
+	}
+	ecsClient := i.ecsClient.InProject(projectID)
+
+	allowedTypes := i.cloudConfig.AuthOpts.GetAllowedAddressTypes()
+	deniedCIDRs := i.cloudConfig.AuthOpts.GetDeniedAddressCIDRs()
+	if addresses, ok := i.getLocalNodeAddresses(instanceID); ok {
+		addresses = filterDeniedAddresses(filterAddressTypes(addresses, allowedTypes), deniedCIDRs)
+		klog.InfoS("Node addresses are resolved from the metadata service", "operation", "NodeAddressesByProviderID",
+			"providerID", providerID, "serverID", instanceID, "addresses", addresses)
+		return addresses, nil
+	}
+
+	instance, err := ecsClient.Get(instanceID)
 	if err != nil {
+		if common.IsNotFound(err) {
+			i.addressCache.Delete(instanceID)
+		}
+		logInstanceError(err, "NodeAddressesByProviderID", providerID, instanceID)
 		return nil, err
 	}
 
-	interfaces, err := i.ecsClient.ListInterfaces(&ecsmodel.ListServerInterfacesRequest{ServerId: instanceID})
+	addresses, err := i.getInstanceAddresses(instance, func() ([]v1.NodeAddress, error) {
+		return i.buildInstanceAddresses(ecsClient, i.vpcClient.InProject(projectID), instance)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	instance, err := i.ecsClient.Get(instanceID)
+	addresses = filterDeniedAddresses(filterAddressTypes(addresses, allowedTypes), deniedCIDRs)
+	klog.InfoS("Node addresses are resolved", "operation", "NodeAddressesByProviderID", "providerID", providerID,
+		"serverID", instanceID, "addresses", addresses)
+	return addresses, nil
+}
+
+// logInstanceError logs the failed ECS API call of the Instances API, the error code and the request ID
+// of the API call are logged as discrete keys.
+func logInstanceError(err error, operation, providerID, serverID string) {
+	keysAndValues := []interface{}{"operation", operation, "providerID", providerID, "serverID", serverID}
+	klog.ErrorS(err, "Failed to query the ECS", append(keysAndValues, common.ErrorKeysAndValues(err)...)...)
+}
+
+// checkInstanceBuilt returns a retryable error if the ECS is still being built, so that the incomplete addresses
+// are neither reported nor cached, and the node is retried until the ECS is ACTIVE.
+func checkInstanceBuilt(instance *ecsmodel.ServerDetail) error {
+	if instance.Status == instanceBuildStatus || instance.Status == instanceBuildingStatus {
+		return status.Errorf(codes.Unavailable, "the ECS %s is in the %s status, its addresses are not ready yet",
+			instance.Id, instance.Status)
+	}
+	return nil
+}
+
+// isInstanceReadyToInitialize returns whether the node of the ECS can be initialized, that is, the ECS is not
+// in any of the not ready statuses, such as being built or rebooted.
+func isInstanceReadyToInitialize(instance *ecsmodel.ServerDetail, notReadyStatuses []string) bool {
+	for _, s := range notReadyStatuses {
+		if strings.EqualFold(instance.Status, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkInstanceReadyToInitialize returns a retryable error if the node is uninitialized and the ECS is not ready,
+// so that the node keeps the uninitialized taint and its initialization is retried until the ECS is ready.
+// The metadata of an initialized node is always returned, such as while its ECS is rebooting.
+func checkInstanceReadyToInitialize(node *v1.Node, instance *ecsmodel.ServerDetail, notReadyStatuses []string) error {
+	if !isNodeUninitialized(node) {
+		return nil
+	}
+	if !isInstanceReadyToInitialize(instance, notReadyStatuses) {
+		return status.Errorf(codes.Unavailable, "the ECS %s is in the %s status, it is not ready to initialize "+
+			"the node yet", instance.Id, instance.Status)
+	}
+	return nil
+}
+
+// isNodeUninitialized returns true if the node has the taint of the nodes not initialized by the CCM yet.
+func isNodeUninitialized(node *v1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == cloudproviderapi.TaintExternalCloudProvider {
+			return true
+		}
+	}
+	return false
+}
+
+// getInstanceAddresses returns the addresses of the ECS by build, the addresses are cached until the updated
+// timestamp of the ECS changes, which is used to check whether the cached addresses are stale.
+func (i *Instances) getInstanceAddresses(instance *ecsmodel.ServerDetail,
+	build func() ([]v1.NodeAddress, error)) ([]v1.NodeAddress, error) {
+	if err := checkInstanceBuilt(instance); err != nil {
+		return nil, err
+	}
+	return i.addressCache.Get(instance.Id, instance.Updated, build)
+}
+
+// buildInstanceAddresses returns the addresses of the ECS built from its interfaces, and the IPs of the allowed
+// address pairs of the interfaces as the internal IPs if "report-allowed-address-pairs" is enabled.
+func (i *Instances) buildInstanceAddresses(ecsClient *wrapper.EcsClient, ports portLister,
+	instance *ecsmodel.ServerDetail) ([]v1.NodeAddress, error) {
+	interfaces, err := ecsClient.ListInterfaces(&ecsmodel.ListServerInterfacesRequest{ServerId: instance.Id})
 	if err != nil {
 		return nil, err
 	}
+	addresses, err := ecsClient.BuildAddresses(instance, interfaces, i.networkingOpts)
+	if err != nil || !i.networkingOpts.ReportAllowedAddressPairs {
+		return addresses, err
+	}
 
-	addresses, err := i.ecsClient.BuildAddresses(instance, interfaces, i.networkingOpts)
+	pairs, err := getAllowedAddressPairAddresses(ports, instance.Id, interfaces)
 	if err != nil {
 		return nil, err
 	}
+	for _, pair := range pairs {
+		if !hasNodeAddress(addresses, pair.Address) {
+			addresses = append(addresses, pair)
+		}
+	}
+	return addresses, nil
+}
+
+// getAllowedAddressPairAddresses returns the IPv4 addresses of the allowed address pairs of the active interfaces
+// of the ECS as the internal IPs, such as the VIPs of keepalived that the node may answer on. The ports of the ECS
+// are correlated with the interfaces by the port ID. The pairs of a CIDR are skipped, except a single IP.
+func getAllowedAddressPairAddresses(ports portLister, serverID string, interfaces []ecsmodel.InterfaceAttachment) (
+	[]v1.NodeAddress, error) {
+	activePorts := make(map[string]bool)
+	for _, inter := range interfaces {
+		if inter.PortId != nil && inter.PortState != nil && *inter.PortState == "ACTIVE" {
+			activePorts[*inter.PortId] = true
+		}
+	}
+	if len(activePorts) == 0 {
+		return nil, nil
+	}
 
-	klog.Infof("NodeAddresses(ID: %v) => %v", providerID, addresses)
+	list, err := ports.ListPorts(&vpcmodel.ListPortsRequest{DeviceId: &serverID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ports of ECS %s: %s", serverID, err)
+	}
+	addresses := make([]v1.NodeAddress, 0)
+	for _, port := range list {
+		if !activePorts[port.Id] {
+			continue
+		}
+		for _, pair := range port.AllowedAddressPairs {
+			ip := strings.TrimSuffix(pair.IpAddress, "/32")
+			if net.ParseIP(ip).To4() == nil {
+				klog.V(4).Infof("The allowed address pair %s of port %s is not a single IPv4 address, skip it",
+					pair.IpAddress, port.Id)
+				continue
+			}
+			if !hasNodeAddress(addresses, ip) {
+				addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
+			}
+		}
+	}
 	return addresses, nil
 }
 
+// hasNodeAddress returns true if any of the addresses has the IP, regardless of the type.
+func hasNodeAddress(addresses []v1.NodeAddress, ip string) bool {
+	for _, addr := range addresses {
+		if addr.Address == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAddressTypes returns the addresses of the allowed types in order, all the addresses if allowedTypes is empty.
+func filterAddressTypes(addresses []v1.NodeAddress, allowedTypes []string) []v1.NodeAddress {
+	if len(allowedTypes) == 0 {
+		return addresses
+	}
+	rst := make([]v1.NodeAddress, 0, len(addresses))
+	for _, addr := range addresses {
+		if utils.IsStrSliceContains(allowedTypes, string(addr.Type)) {
+			rst = append(rst, addr)
+		}
+	}
+	return rst
+}
+
+// filterDeniedAddresses returns the addresses in order except the IPs in any of the denied CIDRs,
+// whatever their types are. The addresses that are not IPs, such as the hostname, are kept.
+func filterDeniedAddresses(addresses []v1.NodeAddress, deniedCIDRs []*net.IPNet) []v1.NodeAddress {
+	if len(deniedCIDRs) == 0 {
+		return addresses
+	}
+	rst := make([]v1.NodeAddress, 0, len(addresses))
+	for _, addr := range addresses {
+		if ip := net.ParseIP(addr.Address); ip != nil && isIPInCIDRs(ip, deniedCIDRs) {
+			klog.V(4).Infof("the %s %s is in the denied CIDRs, it is not reported", addr.Type, addr.Address)
+			continue
+		}
+		rst = append(rst, addr)
+	}
+	return rst
+}
+
+func isIPInCIDRs(ip net.IP, cidrs []*net.IPNet) bool {
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// localInstance fetches the instance data of the instance that the CCM runs on from the metadata service once.
+// If the metadata service does not respond in time, the ECS API is always used instead.
+type localInstance struct {
+	once  sync.Once
+	fetch func() (*metadata.InstanceData, error)
+	data  *metadata.InstanceData
+}
+
+func (l *localInstance) get() *metadata.InstanceData {
+	l.once.Do(func() {
+		data, err := l.fetch()
+		if err != nil {
+			klog.Warningf("failed to probe the metadata service, the ECS API is used instead: %s", err)
+			return
+		}
+		l.data = data
+	})
+	return l.data
+}
+
+// getLocalNodeAddresses returns the addresses from the metadata service if "node-addresses" is enabled
+// and the instance is the one that the CCM runs on.
+func (i *Instances) getLocalNodeAddresses(instanceID string) ([]v1.NodeAddress, bool) {
+	if i.localInstance == nil || i.metadataOpts == nil || !i.metadataOpts.NodeAddresses {
+		return nil, false
+	}
+	return buildLocalNodeAddresses(i.localInstance.get(), instanceID)
+}
+
+// buildLocalNodeAddresses returns the addresses in the network data as InternalIP,
+// the metadata is required to make sure that the data belongs to the instance.
+func buildLocalNodeAddresses(data *metadata.InstanceData, instanceID string) ([]v1.NodeAddress, bool) {
+	if data == nil || data.Metadata == nil || data.NetworkData == nil || data.Metadata.UUID != instanceID {
+		return nil, false
+	}
+	addresses := make([]v1.NodeAddress, 0)
+	for _, ip := range data.NetworkData.IPAddresses() {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
+	}
+	return addresses, len(addresses) > 0
+}
+
 // InstanceID returns the cloud provider ID of the node with the specified NodeName.
-func (i *Instances) InstanceID(_ context.Context, name types.NodeName) (string, error) {
-	klog.Infof("InstanceID is called with name %s", name)
-	server, err := i.ecsClient.GetByNodeName(string(name))
+func (i *Instances) InstanceID(ctx context.Context, name types.NodeName) (string, error) {
+	klog.InfoS("Instances API is called", "operation", "InstanceID", "node", name)
+	var byPrivateIP func() (*ecsmodel.ServerDetail, error)
+	if i.cloudConfig != nil && i.cloudConfig.AuthOpts.InstanceLookupByPrivateIP {
+		byPrivateIP = func() (*ecsmodel.ServerDetail, error) {
+			privateIP := i.getNodePrivateIP(ctx, string(name))
+			if privateIP == "" {
+				return nil, status.Errorf(codes.NotFound, "not found the private IP of node %s", name)
+			}
+			klog.V(4).Infof("not found ECS of node %s by name, query ECS by private IP: %s", name, privateIP)
+			return i.ecsClient.GetByPrivateIP(privateIP, i.cloudConfig.VpcOpts.ID)
+		}
+	}
+	return i.retryNewNodeLookup(ctx, func() *v1.Node { return i.getNode(ctx, string(name)) }, func() (string, error) {
+		return lookupInstanceID(i.ecsClient, i.nameCache, string(name), byPrivateIP)
+	})
+}
+
+// retryNewNodeLookup runs the lookup of the ECS of the node by name, it is retried "new-node-lookup-retries" times
+// while the ECS is not found if the node is new, as an ECS just created may not be listed by name for a few seconds.
+// getNode is only called if the retries are enabled.
+func (i *Instances) retryNewNodeLookup(ctx context.Context, getNode func() *v1.Node,
+	lookup func() (string, error)) (string, error) {
+	if i.cloudConfig == nil || i.cloudConfig.AuthOpts.NewNodeLookupRetries == 0 {
+		return lookup()
+	}
+	gracePeriod := time.Duration(i.cloudConfig.AuthOpts.GetNewNodeLookupGracePeriod()) * time.Second
+	if !isNewNode(getNode(), gracePeriod, time.Now()) {
+		return lookup()
+	}
+	return retryLookupNotFound(ctx, lookup, i.cloudConfig.AuthOpts.NewNodeLookupRetries, newNodeLookupRetryInterval)
+}
+
+// lookupWithSlot returns the lookup holding a slot of the concurrent calls only while it runs, not while waiting
+// for the retries, so that the reconciles of the load balancers sharing the slots are not blocked by the retries.
+func (i *Instances) lookupWithSlot(ctx context.Context, lookup func() (string, error)) func() (string, error) {
+	return func() (string, error) {
+		if err := i.reconcileSem.Acquire(ctx); err != nil {
+			return "", err
+		}
+		defer i.reconcileSem.Release()
+		return lookup()
+	}
+}
 
+// isNewNode returns true if the node is created within the grace period.
+func isNewNode(node *v1.Node, gracePeriod time.Duration, now time.Time) bool {
+	return node != nil && now.Sub(node.CreationTimestamp.Time) < gracePeriod
+}
+
+// retryLookupNotFound retries the lookup up to the retries while the ECS is not found, that is, an empty ID is
+// returned without an error, or with a not found error. The interval between the retries grows exponentially.
+// The result of the last lookup is returned, or the error of ctx if it is done before the retries are finished.
+func retryLookupNotFound(ctx context.Context, lookup func() (string, error), retries int,
+	interval time.Duration) (string, error) {
+	var id string
+	var err error
+	attempt := 0
+	backoff := wait.Backoff{Duration: interval, Factor: newNodeLookupRetryFactor, Jitter: 0.1, Steps: retries + 1}
+	waitErr := wait.ExponentialBackoffWithContext(ctx, backoff, func() (bool, error) {
+		if attempt > 0 {
+			klog.V(4).Infof("not found the ECS of the new node yet, retry the lookup, attempt %d", attempt)
+		}
+		attempt++
+		id, err = lookup()
+		notFound := id == "" && (err == nil || err == cloudprovider.InstanceNotFound || common.IsNotFound(err))
+		return !notFound, nil
+	})
+	if waitErr != nil && waitErr != wait.ErrWaitTimeout {
+		return "", waitErr
+	}
+	return id, err
+}
+
+// getNodePrivateIP returns the private IP of the node in Kubernetes, which is the IP set by kubelet --node-ip or
+// the first InternalIP. An empty string is returned if the node can not be got.
+func (i *Instances) getNodePrivateIP(ctx context.Context, name string) string {
+	if i.kubeClient == nil {
+		return ""
+	}
+	node, err := i.kubeClient.Nodes().Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		if common.IsNotFound(err) {
-			return "", nil
+		klog.V(4).Infof("failed to get node %s, can not query ECS by private IP: %s", name, err)
+		return ""
+	}
+	return nodePrivateIP(node)
+}
+
+func nodePrivateIP(node *v1.Node) string {
+	if ip := strings.TrimSpace(node.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]); ip != "" {
+		return ip
+	}
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP {
+			return addr.Address
 		}
-		return "", err
 	}
-	return server.Id, nil
+	return ""
+}
+
+// lookupInstanceID returns the ID of the instance of the node name, an empty string is returned if it is not found.
+// The instance is looked up by byPrivateIP as the last resort if it is not nil and the name is not found.
+// The instance IDs found are cached in nameCache if it is not nil.
+func lookupInstanceID(servers serverGetter, nameCache *NodeNameCache, name string,
+	byPrivateIP func() (*ecsmodel.ServerDetail, error)) (string, error) {
+	lookup := func() (string, error) {
+		server, err := servers.GetByNodeName(name)
+		if err != nil && common.IsNotFound(err) && byPrivateIP != nil {
+			server, err = byPrivateIP()
+		}
+		if err != nil {
+			if common.IsNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		return server.Id, nil
+	}
+	if nameCache == nil {
+		return lookup()
+	}
+	return nameCache.Get(name, lookup)
 }
 
 // InstanceType returns the type of the specified instance.
 func (i *Instances) InstanceType(_ context.Context, name types.NodeName) (string, error) {
-	klog.Infof("InstanceType is called with name %s", name)
+	klog.InfoS("Instances API is called", "operation", "InstanceType", "node", name)
 	instance, err := i.ecsClient.GetByNodeName(string(name))
 	if err != nil {
 		return "", err
 	}
 
-	return getInstanceFlavor(instance)
+	return getInstanceFlavor(instance, i.instanceTypeMapping())
 }
 
-func getInstanceFlavor(instance *ecsmodel.ServerDetail) (string, error) {
-	if len(instance.Flavor.Name) > 0 {
-		return instance.Flavor.Name, nil
+// getInstanceFlavor returns the instance type of the ECS, which is the flavor name, or the flavor ID if the name
+// is empty. The flavor is replaced by the normalized type if its ID or name is in the mapping.
+func getInstanceFlavor(instance *ecsmodel.ServerDetail, mapping map[string]string) (string, error) {
+	flavor := instance.Flavor.Name
+	if len(flavor) == 0 {
+		flavor = instance.Flavor.Id
 	}
-	if len(instance.Flavor.Id) > 0 {
-		return instance.Flavor.Name, nil
+	if len(flavor) == 0 {
+		return "", fmt.Errorf("flavor name/id not found")
 	}
 
-	return "", fmt.Errorf("flavor name/id not found")
+	for _, key := range []string{instance.Flavor.Id, instance.Flavor.Name} {
+		mapped, ok := mapping[key]
+		if !ok || len(key) == 0 {
+			continue
+		}
+		// The instance type is the value of the labels of the node, the invalid one is ignored.
+		if errs := validation.IsValidLabelValue(mapped); len(mapped) == 0 || len(errs) > 0 {
+			klog.Warningf("ignore the invalid instance type %q mapped from the flavor %s: %s",
+				mapped, key, strings.Join(errs, ", "))
+			break
+		}
+		return mapped, nil
+	}
+	return flavor, nil
+}
+
+// instanceTypeMapping returns the mapping from the flavors to the normalized instance types.
+func (i *Instances) instanceTypeMapping() map[string]string {
+	if i.metadataOpts == nil {
+		return nil
+	}
+	return i.metadataOpts.InstanceTypeMapping
 }
 
 // InstanceTypeByProviderID returns the type of the specified instance.
 func (i *Instances) InstanceTypeByProviderID(_ context.Context, providerID string) (string, error) {
-	klog.Infof("InstanceTypeByProviderID is called with provider ID %s", providerID)
-	instanceID, err := parseInstanceID(providerID)
+	klog.InfoS("Instances API is called", "operation", "InstanceTypeByProviderID", "providerID", providerID)
+	projectID, instanceID, err := parseProviderID(providerID)
 	if err != nil {
 		return "", err
 	}
 
-	instance, err := i.ecsClient.Get(instanceID)
+	instance, err := i.ecsClient.InProject(projectID).Get(instanceID)
 	if err != nil {
+		logInstanceError(err, "InstanceTypeByProviderID", providerID, instanceID)
 		return "", err
 	}
 
-	return getInstanceFlavor(instance)
+	return getInstanceFlavor(instance, i.instanceTypeMapping())
 }
 
 // AddSSHKeyToAllInstances adds an SSH public key as a legal identity for all instances
 // expected format for the key is standard ssh-keygen format: <protocol> <blob>
-func (i *Instances) AddSSHKeyToAllInstances(_ context.Context, _ string, _ []byte) error {
+func (i *Instances) AddSSHKeyToAllInstances(_ context.Context, user string, keyData []byte) error {
+	klog.V(4).Infof("AddSSHKeyToAllInstances is called with user %s, keyData: %s", user, utils.Redact(string(keyData)))
 	return cloudprovider.NotImplemented
 }
 
 // CurrentNodeName returns the name of the node we are currently running on
 // On most clouds (e.g. GCE) this is the hostname, so we provide the hostname
 func (i *Instances) CurrentNodeName(_ context.Context, hostname string) (types.NodeName, error) {
-	klog.Infof("CurrentNodeName is called, hostname: %s", hostname)
+	klog.InfoS("Instances API is called", "operation", "CurrentNodeName", "hostname", hostname)
 	return types.NodeName(hostname), nil
 }
 
 // InstanceExistsByProviderID returns true if the instance for the given provider exists.
 func (i *Instances) InstanceExistsByProviderID(_ context.Context, providerID string) (bool, error) {
-	klog.Infof("InstanceExistsByProviderID is called with provider ID %s", providerID)
-	instanceID, err := parseInstanceID(providerID)
+	klog.InfoS("Instances API is called", "operation", "InstanceExistsByProviderID", "providerID", providerID)
+	projectID, instanceID, err := parseProviderID(providerID)
 	if err != nil {
 		return false, err
 	}
 
-	_, err = i.ecsClient.Get(instanceID)
+	_, err = i.ecsClient.InProject(projectID).Get(instanceID)
 	if err != nil {
 		if common.IsNotFound(err) {
+			i.InvalidateInstance(providerID)
 			return false, nil
 		}
+		logInstanceError(err, "InstanceExistsByProviderID", providerID, instanceID)
 		return false, err
 	}
 
 	return true, nil
 }
 
+// BulkInstanceExists returns whether the instances of the given provider IDs exist, keyed by the provider ID.
+// The instances are queried in batches per project, which is cheaper than querying the details one by one.
+func (i *Instances) BulkInstanceExists(ctx context.Context, providerIDs []string) (map[string]bool, error) {
+	klog.InfoS("Instances API is called", "operation", "BulkInstanceExists", "count", len(providerIDs))
+	instanceIDs := make([]string, 0, len(providerIDs))
+	projectInstanceIDs := make(map[string][]string)
+	for _, providerID := range providerIDs {
+		projectID, instanceID, err := parseProviderID(providerID)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+		}
+		instanceIDs = append(instanceIDs, instanceID)
+		projectInstanceIDs[projectID] = append(projectInstanceIDs[projectID], instanceID)
+	}
+
+	if err := i.reconcileSem.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer i.reconcileSem.Release()
+
+	present := make(map[string]bool, len(instanceIDs))
+	for projectID, ids := range projectInstanceIDs {
+		rst, err := listExistingServers(i.ecsClient.InProject(projectID), ids)
+		if err != nil {
+			return nil, err
+		}
+		for id, ok := range rst {
+			present[id] = present[id] || ok
+		}
+	}
+
+	rst := make(map[string]bool, len(providerIDs))
+	for idx, providerID := range providerIDs {
+		rst[providerID] = present[instanceIDs[idx]]
+		if !rst[providerID] {
+			i.InvalidateInstance(providerID)
+		}
+	}
+	return rst, nil
+}
+
+// listExistingServers returns a presence map of the specified instance IDs.
+func listExistingServers(lister serverLister, instanceIDs []string) (map[string]bool, error) {
+	present := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		present[id] = false
+	}
+
+	ids := make([]string, 0, len(present))
+	for id := range present {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for start := 0; start < len(ids); start += maxServerIDsPerList {
+		end := start + maxServerIDsPerList
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		servers, err := lister.ListByIDs(ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range servers {
+			if _, ok := present[server.Id]; ok {
+				present[server.Id] = true
+			}
+		}
+	}
+	return present, nil
+}
+
 // InstanceShutdownByProviderID returns true if the instance is shutdown in cloudprovider
 func (i *Instances) InstanceShutdownByProviderID(_ context.Context, providerID string) (bool, error) {
-	klog.Infof("InstanceShutdownByProviderID is called with provider ID %s", providerID)
-	instanceID, err := parseInstanceID(providerID)
+	klog.InfoS("Instances API is called", "operation", "InstanceShutdownByProviderID", "providerID", providerID)
+	projectID, instanceID, err := parseProviderID(providerID)
 	if err != nil {
 		return false, err
 	}
-	server, err := i.ecsClient.Get(instanceID)
+	server, err := i.ecsClient.InProject(projectID).Get(instanceID)
 	if err != nil {
+		logInstanceError(err, "InstanceShutdownByProviderID", providerID, instanceID)
 		return false, err
 	}
 
-	return server.Status == instanceShutoffStatus, nil
+	return isInstanceShutdown(server.Status, i.cloudConfig.AuthOpts.GetShutoffInstancePolicy()), nil
+}
+
+// isInstanceShutdown returns true if the node of the ECS in the status is reported as shutdown by the policy.
+// The SHUTOFF ECS is not reported if the policy is ignore, the node is left alone.
+func isInstanceShutdown(ecsStatus, policy string) bool {
+	if ecsStatus != instanceShutoffStatus {
+		return false
+	}
+	if policy == config.ShutoffInstancePolicyIgnore {
+		klog.V(4).Infof("the SHUTOFF instance is not reported as shutdown by the policy %q", policy)
+		return false
+	}
+	return true
 }
 
 // InstanceExists returns true if the instance for the given node exists according to the cloud provider.
 func (i *Instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
-	klog.Infof("InstanceExists is called with node %s", node.Name)
+	klog.InfoS("Instances API is called", "operation", "InstanceExists", "node", node.Name)
+	if err := i.reconcileSem.Acquire(ctx); err != nil {
+		return false, err
+	}
+	defer i.reconcileSem.Release()
+
 	_, err := i.ecsClient.GetByNodeName(node.Name)
 
 	if err != nil {
 		if common.IsNotFound(err) {
+			i.InvalidateInstance(node.Spec.ProviderID)
 			return false, nil
 		}
 		return false, err
@@ -193,47 +846,81 @@ func (i *Instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, er
 
 // InstanceShutdown returns true if the instance is shutdown according to the cloud provider.
 func (i *Instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
-	klog.Infof("InstanceShutdown is called with node %s/%s", node.Namespace, node.Name)
+	klog.InfoS("Instances API is called", "operation", "InstanceShutdown", "node", node.Name,
+		"providerID", node.Spec.ProviderID)
+	if err := i.reconcileSem.Acquire(ctx); err != nil {
+		return false, err
+	}
+	defer i.reconcileSem.Release()
+
 	return i.InstanceShutdownByProviderID(ctx, node.Spec.ProviderID)
 }
 
 // InstanceMetadata returns the instance's metadata. The values returned in InstanceMetadata are
 // translated into specific fields in the Node object on registration.
 func (i *Instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
-	klog.Infof("InstanceMetadata is called with node %s", node.Name)
+	klog.InfoS("Instances API is called", "operation", "InstanceMetadata", "node", node.Name,
+		"providerID", node.Spec.ProviderID)
 	providerID := node.Spec.ProviderID
 	if providerID == "" {
 		klog.V(4).Infof("node.Spec.ProviderID is empty, query ECS details by hostname: %s", node.Name)
-		id, err := i.InstanceID(ctx, types.NodeName(node.Name))
+		id, err := i.retryNewNodeLookup(ctx, func() *v1.Node { return node }, i.lookupWithSlot(ctx, func() (string, error) {
+			return getProviderIDByName(i.ecsClient, node.Name)
+		}))
 		if err != nil {
 			return nil, err
 		}
 		providerID = id
 	}
-	instanceID, err := parseInstanceID(providerID)
+
+	if err := i.reconcileSem.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer i.reconcileSem.Release()
+	projectID, instanceID, err := parseProviderID(providerID)
 	if err != nil {
 		return nil, err
 	}
+	ecsClient := i.ecsClient.InProject(projectID)
 
-	instance, err := i.ecsClient.Get(instanceID)
+	instance, err := ecsClient.Get(instanceID)
 	if err != nil {
+		if common.IsNotFound(err) {
+			i.addressCache.Delete(instanceID)
+		}
+		logInstanceError(err, "InstanceMetadata", providerID, instanceID)
 		return nil, err
 	}
 
-	instanceFlavor, err := getInstanceFlavor(instance)
+	err = checkInstanceReadyToInitialize(node, instance, i.cloudConfig.AuthOpts.GetNotReadyInstanceStatuses())
 	if err != nil {
 		return nil, err
 	}
-
-	interfaces, err := i.ecsClient.ListInterfaces(&ecsmodel.ListServerInterfacesRequest{ServerId: instanceID})
+	if !isSystemUUIDMatched(node, instance.Id) {
+		klog.Warningf("The system UUID %s of node %s does not match ECS %s, the provider ID %s may be stale",
+			node.Status.NodeInfo.SystemUUID, node.Name, instance.Id, providerID)
+	}
+	instanceFlavor, err := getInstanceFlavor(instance, i.instanceTypeMapping())
 	if err != nil {
 		return nil, err
 	}
 
-	addresses, err := i.ecsClient.BuildAddresses(instance, interfaces, i.networkingOpts)
+	addresses, ok, err := getAnnotatedNodeAddresses(node, i.cloudConfig.AuthOpts.NodeAddressesAnnotation)
 	if err != nil {
 		return nil, err
 	}
+	if !ok {
+		// the interfaces are only listed again if the updated timestamp of the ECS has changed.
+		addresses, err = i.getInstanceAddresses(instance, func() ([]v1.NodeAddress, error) {
+			return i.buildInstanceAddresses(ecsClient, i.vpcClient.InProject(projectID), instance)
+		})
+		if err != nil {
+			return nil, err
+		}
+		addresses = filterAddressTypes(addresses, i.cloudConfig.AuthOpts.GetAllowedAddressTypes())
+		addresses = filterDeniedAddresses(addresses, i.cloudConfig.AuthOpts.GetDeniedAddressCIDRs())
+		addresses = applyProvidedNodeIP(addresses, node.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr])
+	}
 
 	return &cloudprovider.InstanceMetadata{
 		Region:        i.cloudConfig.AuthOpts.Region,
@@ -244,17 +931,126 @@ func (i *Instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 	}, nil
 }
 
-func parseInstanceID(providerID string) (string, error) {
-	klog.Infof("parseInstanceID is called with providerID %s", providerID)
+// getProviderIDByName resolves the provider ID of the node by the name, for the node that joins without
+// spec.providerID, such as the node still tainted as uninitialized. The CCM sets the returned provider ID
+// to spec.providerID, a cloudprovider.InstanceNotFound error is returned if the ECS does not exist.
+func getProviderIDByName(getter serverGetter, name string) (string, error) {
+	server, err := getter.GetByNodeName(name)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return "", cloudprovider.InstanceNotFound
+		}
+		return "", err
+	}
+	return providerIDPrefix + server.Id, nil
+}
+
+// GetServerByProviderID returns the ECS details of the specified provider ID.
+// A codes.InvalidArgument error is returned if the provider ID is malformed,
+// and a codes.NotFound error is returned if the ECS does not exist.
+func (i *Instances) GetServerByProviderID(providerID string) (*ecsmodel.ServerDetail, error) {
+	projectID, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	return getServerByID(i.getServers(projectID), instanceID)
+}
+
+// getServerByID returns the ECS of the instance ID, a codes.NotFound error is returned if the ECS does not exist.
+func getServerByID(getter serverGetter, instanceID string) (*ecsmodel.ServerDetail, error) {
+	server, err := getter.Get(instanceID)
+	if err != nil {
+		return nil, classifyServerError(err, instanceID)
+	}
+	return server, nil
+}
+
+// GetSystemUUID returns the system UUID of the ECS of the provider ID, it is reported by the ECS as the SMBIOS UUID
+// and set to status.nodeInfo.systemUUID of the node by kubelet. Comparing them detects a stale provider ID pointing
+// at another ECS, such as a node renamed or re-created with a recycled IP. The UUID of the instance that the CCM runs
+// on is read from the metadata service.
+func (i *Instances) GetSystemUUID(providerID string) (string, error) {
+	projectID, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	if i.localInstance != nil {
+		if data := i.localInstance.get(); data != nil && data.Metadata != nil &&
+			strings.EqualFold(data.Metadata.UUID, instanceID) {
+			return strings.ToLower(data.Metadata.UUID), nil
+		}
+	}
+	return getSystemUUID(i.getServers(projectID), instanceID)
+}
+
+// getSystemUUID returns the system UUID of the ECS, which is the ID of the ECS in lower case.
+func getSystemUUID(getter serverGetter, instanceID string) (string, error) {
+	server, err := getter.Get(instanceID)
+	if err != nil {
+		return "", classifyServerError(err, instanceID)
+	}
+	return strings.ToLower(server.Id), nil
+}
+
+// isSystemUUIDMatched returns false if the system UUID of the node is reported and differs from the one of the ECS.
+func isSystemUUIDMatched(node *v1.Node, systemUUID string) bool {
+	reported := strings.TrimSpace(node.Status.NodeInfo.SystemUUID)
+	return reported == "" || strings.EqualFold(reported, systemUUID)
+}
+
+// GetServerByName returns the ECS details of the specified node name.
+// A codes.NotFound error is returned if the ECS does not exist.
+func (i *Instances) GetServerByName(name string) (*ecsmodel.ServerDetail, error) {
+	return getServerByName(i.servers, name)
+}
+
+// getServerByName returns the ECS of the node name, a codes.NotFound error is returned if the ECS does not exist.
+func getServerByName(getter serverGetter, name string) (*ecsmodel.ServerDetail, error) {
+	server, err := getter.GetByNodeName(name)
+	if err != nil {
+		return nil, classifyServerError(err, name)
+	}
+	return server, nil
+}
+
+// InvalidateInstance purges the cached data of the instance immediately, so that the next lookup queries
+// the ECS API. It is called when the node is deleted or the instance is not found.
+func (b *Basic) InvalidateInstance(providerID string) {
+	_, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		klog.V(4).Infof("skip invalidating the cached data of the instance: %s", err)
+		return
+	}
+	if b.addressCache != nil {
+		klog.V(4).Infof("invalidate the cached data of the instance %s", instanceID)
+		b.addressCache.Delete(instanceID)
+	}
+	if b.nameCache != nil {
+		b.nameCache.DeleteInstance(instanceID)
+	}
+}
+
+func classifyServerError(err error, key string) error {
+	if common.IsNotFound(err) {
+		return status.Errorf(codes.NotFound, "not found ECS %s: %s", key, err)
+	}
+	return err
+}
+
+// parseProviderID returns the project ID and the instance ID of the provider ID,
+// the project ID is empty if the provider ID does not specify it.
+func parseProviderID(providerID string) (string, string, error) {
+	klog.V(4).Infof("parseProviderID is called with providerID %s", providerID)
 
 	if providerID != "" && !strings.Contains(providerID, "://") {
-		providerID = ProviderName + "://" + providerID
+		providerID = providerIDPrefix + providerID
 	}
 
 	matches := providerIDRegexp.FindStringSubmatch(providerID)
-	if len(matches) != 2 {
-		return "", fmt.Errorf("ProviderID \"%s\" didn't match expected format \"huaweicloud://InstanceID\"",
-			providerID)
+	if len(matches) != 3 {
+		return "", "", fmt.Errorf("ProviderID \"%s\" didn't match expected format \"huaweicloud://InstanceID\" "+
+			"or \"huaweicloud://ProjectID/InstanceID\"", providerID)
 	}
-	return matches[1], nil
+	return matches[1], matches[2], nil
 }
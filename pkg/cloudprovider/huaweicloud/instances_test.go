@@ -0,0 +1,1461 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
+	"k8s.io/klog/v2"
+
+	wpmodel "sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/model"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+func TestGetServerByProviderIDInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+	}{
+		{
+			name:       "empty provider ID",
+			providerID: "",
+		},
+		{
+			name:       "unknown provider",
+			providerID: "openstack://c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+		},
+		{
+			name:       "extra path segment",
+			providerID: "huaweicloud://region/c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+		},
+	}
+
+	instances := &Instances{}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := instances.GetServerByProviderID(testCase.providerID)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+			}
+		})
+	}
+}
+
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		projectID  string
+		instanceID string
+	}{
+		{
+			name:       "instance ID",
+			providerID: "huaweicloud://c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+			instanceID: "c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+		},
+		{
+			name:       "without scheme",
+			providerID: "c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+			instanceID: "c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+		},
+		{
+			name:       "project ID",
+			providerID: "huaweicloud://0a1b2c3d4e5f60718293a4b5c6d7e8f9/c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+			projectID:  "0a1b2c3d4e5f60718293a4b5c6d7e8f9",
+			instanceID: "c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			projectID, instanceID, err := parseProviderID(testCase.providerID)
+			if err != nil || projectID != testCase.projectID || instanceID != testCase.instanceID {
+				t.Fatalf("expected: %s, %s, got: %s, %s, %v", testCase.projectID, testCase.instanceID,
+					projectID, instanceID, err)
+			}
+		})
+	}
+}
+
+func TestGetProviderIDByName(t *testing.T) {
+	instanceID := "c3a9a8b2-4d13-4e8a-9c8e-1f3c0b7d9a21"
+	servers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{{Id: instanceID, Name: "node-1"}}}
+
+	providerID, err := getProviderIDByName(servers, "node-1")
+	if err != nil || providerID != "huaweicloud://"+instanceID {
+		t.Fatalf("expected: %v, got: %v, %v", "huaweicloud://"+instanceID, providerID, err)
+	}
+	// The provider ID resolves to the same ECS when it is set to spec.providerID.
+	_, id, err := parseProviderID(providerID)
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if server, err := servers.Get(id); err != nil || server.Name != "node-1" {
+		t.Fatalf("expected: %v, got: %v, %v", "node-1", server, err)
+	}
+
+	if _, err = getProviderIDByName(servers, "node-2"); err != cloudprovider.InstanceNotFound {
+		t.Fatalf("expected: %v, got: %v", cloudprovider.InstanceNotFound, err)
+	}
+}
+
+func TestGetServer(t *testing.T) {
+	instanceID := "C3A9A8B2-4D13-4E8A-9C8E-1F3C0B7D9A21"
+	projectID := "0123456789abcdef0123456789abcdef"
+	servers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{
+		{Id: "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2", Name: "node-2"},
+		{Id: instanceID, Name: "node-1"},
+	}}
+	otherServers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{{Id: "instance-4", Name: "node-4"}}}
+	i := &Instances{servers: servers, inProject: func(id string) serverGetter {
+		if id == projectID {
+			return otherServers
+		}
+		return servers
+	}}
+
+	// the exported getters return the ECS details of the client.
+	expected, _ := servers.GetByNodeName("node-1")
+	byName, err := i.GetServerByName("node-1")
+	if err != nil || !reflect.DeepEqual(byName, expected) {
+		t.Fatalf("expected: %v, got: %v, %v", expected, byName, err)
+	}
+	expected, _ = servers.Get(instanceID)
+	byID, err := i.GetServerByProviderID(providerIDPrefix + instanceID)
+	if err != nil || !reflect.DeepEqual(byID, expected) {
+		t.Fatalf("expected: %v, got: %v, %v", expected, byID, err)
+	}
+	expected, _ = otherServers.Get("instance-4")
+	byID, err = i.GetServerByProviderID(providerIDPrefix + projectID + "/instance-4")
+	if err != nil || !reflect.DeepEqual(byID, expected) {
+		t.Fatalf("expected: %v, got: %v, %v", expected, byID, err)
+	}
+
+	// the not found errors of the client are reported as codes.NotFound.
+	if _, err = servers.GetByNodeName("node-3"); !common.IsNotFound(err) {
+		t.Fatalf("expected: a not found error, got: %v", err)
+	}
+	if _, err = i.GetServerByName("node-3"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected: %v, got: %v", codes.NotFound, err)
+	}
+	if _, err = servers.Get("instance-3"); !common.IsNotFound(err) {
+		t.Fatalf("expected: a not found error, got: %v", err)
+	}
+	if _, err = i.GetServerByProviderID(providerIDPrefix + "instance-3"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected: %v, got: %v", codes.NotFound, err)
+	}
+	if _, err = i.GetServerByProviderID("aws:///i-1"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestClassifyServerError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected codes.Code
+	}{
+		{
+			name:     "SDK not found",
+			err:      &sdkerr.ServiceResponseError{StatusCode: 404},
+			expected: codes.NotFound,
+		},
+		{
+			name:     "status not found",
+			err:      status.Errorf(codes.NotFound, "not found"),
+			expected: codes.NotFound,
+		},
+		{
+			name:     "SDK internal error",
+			err:      &sdkerr.ServiceResponseError{StatusCode: 500},
+			expected: codes.Unknown,
+		},
+		{
+			name:     "plain error",
+			err:      fmt.Errorf("connection refused"),
+			expected: codes.Unknown,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := classifyServerError(testCase.err, "node-1")
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+			if testCase.expected != codes.NotFound && err != testCase.err {
+				t.Fatalf("expected: %v, got: %v", testCase.err, err)
+			}
+		})
+	}
+}
+
+type fakeServerLister struct {
+	existing map[string]bool
+	requests [][]string
+	err      error
+}
+
+func (f *fakeServerLister) ListByIDs(ids []string) ([]wpmodel.ServerDetail, error) {
+	f.requests = append(f.requests, ids)
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	servers := make([]wpmodel.ServerDetail, 0)
+	for _, id := range ids {
+		if f.existing[id] {
+			servers = append(servers, wpmodel.ServerDetail{Id: id})
+		}
+	}
+	return servers, nil
+}
+
+func TestListExistingServers(t *testing.T) {
+	manyIDs := make([]string, 0, maxServerIDsPerList+1)
+	for i := 0; i <= maxServerIDsPerList; i++ {
+		manyIDs = append(manyIDs, fmt.Sprintf("server-%03d", i))
+	}
+
+	tests := []struct {
+		name        string
+		instanceIDs []string
+		existing    map[string]bool
+		expected    map[string]bool
+		requests    int
+	}{
+		{
+			name:        "no instance IDs",
+			instanceIDs: []string{},
+			expected:    map[string]bool{},
+			requests:    0,
+		},
+		{
+			name:        "subset present",
+			instanceIDs: []string{"server-1", "server-2", "server-3"},
+			existing:    map[string]bool{"server-1": true, "server-3": true},
+			expected:    map[string]bool{"server-1": true, "server-2": false, "server-3": true},
+			requests:    1,
+		},
+		{
+			name:        "duplicated IDs",
+			instanceIDs: []string{"server-1", "server-1"},
+			existing:    map[string]bool{"server-1": true},
+			expected:    map[string]bool{"server-1": true},
+			requests:    1,
+		},
+		{
+			name:        "unrequested server in response",
+			instanceIDs: []string{"server-1"},
+			existing:    map[string]bool{"server-1": true, "server-2": true},
+			expected:    map[string]bool{"server-1": true},
+			requests:    1,
+		},
+		{
+			name:        "split into batches",
+			instanceIDs: manyIDs,
+			existing:    map[string]bool{manyIDs[0]: true, manyIDs[maxServerIDsPerList]: true},
+			expected: func() map[string]bool {
+				m := make(map[string]bool, len(manyIDs))
+				for _, id := range manyIDs {
+					m[id] = false
+				}
+				m[manyIDs[0]] = true
+				m[manyIDs[maxServerIDsPerList]] = true
+				return m
+			}(),
+			requests: 2,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			lister := &fakeServerLister{existing: testCase.existing}
+			got, err := listExistingServers(lister, testCase.instanceIDs)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, got)
+			}
+			if len(lister.requests) != testCase.requests {
+				t.Fatalf("expected: %v, got: %v", testCase.requests, len(lister.requests))
+			}
+			for _, ids := range lister.requests {
+				if len(ids) > maxServerIDsPerList {
+					t.Fatalf("expected at most %v IDs in one request, got: %v", maxServerIDsPerList, len(ids))
+				}
+			}
+		})
+	}
+}
+
+func TestListExistingServersError(t *testing.T) {
+	lister := &fakeServerLister{err: fmt.Errorf("connection refused")}
+	_, err := listExistingServers(lister, []string{"server-1"})
+	if err != lister.err {
+		t.Fatalf("expected: %v, got: %v", lister.err, err)
+	}
+}
+
+func TestBulkInstanceExistsInvalid(t *testing.T) {
+	instances := &Instances{}
+	_, err := instances.BulkInstanceExists(context.TODO(), []string{"openstack://server-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestApplyProvidedNodeIP(t *testing.T) {
+	addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+		{Type: v1.NodeInternalIP, Address: "172.16.0.10"},
+		{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+		{Type: v1.NodeHostName, Address: "node-1"},
+	}
+
+	tests := []struct {
+		name       string
+		providedIP string
+		expected   []v1.NodeAddress
+	}{
+		{
+			name:       "no hint",
+			providedIP: "",
+			expected:   addresses,
+		},
+		{
+			name:       "hint matches the second InternalIP",
+			providedIP: "172.16.0.10",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "172.16.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeHostName, Address: "node-1"},
+			},
+		},
+		{
+			name:       "hint does not match",
+			providedIP: "10.0.0.10",
+			expected:   addresses,
+		},
+		{
+			name:       "hint matches an ExternalIP only",
+			providedIP: "100.85.0.10",
+			expected:   addresses,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := applyProvidedNodeIP(addresses, te.providedIP)
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterAddressTypes(t *testing.T) {
+	addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+		{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+		{Type: v1.NodeHostName, Address: "node-1"},
+		{Type: v1.NodeInternalIP, Address: "172.16.0.10"},
+	}
+
+	tests := []struct {
+		name     string
+		allowed  string
+		expected []v1.NodeAddress
+	}{
+		{
+			name:     "all types by default",
+			allowed:  "",
+			expected: addresses,
+		},
+		{
+			name:    "suppress ExternalIP",
+			allowed: "InternalIP,Hostname,InternalDNS",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeHostName, Address: "node-1"},
+				{Type: v1.NodeInternalIP, Address: "172.16.0.10"},
+			},
+		},
+		{
+			name:    "InternalIP only, case-insensitive",
+			allowed: " internalip ",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeInternalIP, Address: "172.16.0.10"},
+			},
+		},
+		{
+			name:     "no address of the allowed types",
+			allowed:  "InternalDNS",
+			expected: []v1.NodeAddress{},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			opts := &config.AuthOptions{AllowedAddressTypes: te.allowed}
+			got := filterAddressTypes(addresses, opts.GetAllowedAddressTypes())
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterDeniedAddresses(t *testing.T) {
+	addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+		{Type: v1.NodeInternalIP, Address: "10.10.0.10"},
+		{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+		{Type: v1.NodeExternalIP, Address: "100.86.0.10"},
+		{Type: v1.NodeHostName, Address: "node-1"},
+	}
+
+	tests := []struct {
+		name     string
+		denied   string
+		expected []v1.NodeAddress
+	}{
+		{
+			name:     "all addresses by default",
+			denied:   "",
+			expected: addresses,
+		},
+		{
+			name:   "internal and external addresses denied",
+			denied: "10.10.0.0/16, 100.86.0.0/24",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeHostName, Address: "node-1"},
+			},
+		},
+		{
+			name:   "single address denied",
+			denied: "192.168.0.10/32",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.10.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.86.0.10"},
+				{Type: v1.NodeHostName, Address: "node-1"},
+			},
+		},
+		{
+			name:     "no address in the denied CIDRs",
+			denied:   "172.16.0.0/12",
+			expected: addresses,
+		},
+		{
+			name:   "all IPs denied",
+			denied: "0.0.0.0/0",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "node-1"},
+			},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			opts := &config.AuthOptions{DeniedAddressCIDRs: te.denied}
+			got := filterDeniedAddresses(addresses, opts.GetDeniedAddressCIDRs())
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetLocalNodeAddresses(t *testing.T) {
+	instanceID := "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2"
+	networkData := &metadata.NetworkData{Networks: []metadata.Network{
+		{ID: "network0", IPAddress: "192.168.0.10"},
+		{ID: "network1", IPAddress: "192.168.1.10"},
+	}}
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		data       *metadata.InstanceData
+		err        error
+		instanceID string
+		expected   []v1.NodeAddress
+		ok         bool
+	}{
+		{
+			name:       "local instance",
+			enabled:    true,
+			data:       &metadata.InstanceData{Metadata: &metadata.Metadata{UUID: instanceID}, NetworkData: networkData},
+			instanceID: instanceID,
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+			},
+			ok: true,
+		},
+		{
+			name:       "disabled",
+			enabled:    false,
+			data:       &metadata.InstanceData{Metadata: &metadata.Metadata{UUID: instanceID}, NetworkData: networkData},
+			instanceID: instanceID,
+		},
+		{
+			name:       "other instance",
+			enabled:    true,
+			data:       &metadata.InstanceData{Metadata: &metadata.Metadata{UUID: instanceID}, NetworkData: networkData},
+			instanceID: "other-instance",
+		},
+		{
+			name:       "only network data fetched",
+			enabled:    true,
+			data:       &metadata.InstanceData{NetworkData: networkData},
+			instanceID: instanceID,
+		},
+		{
+			name:       "metadata service timeout",
+			enabled:    true,
+			err:        fmt.Errorf("context deadline exceeded"),
+			instanceID: instanceID,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			fetched := 0
+			instances := &Instances{Basic: Basic{
+				metadataOpts: &config.MetadataOptions{NodeAddresses: te.enabled},
+				localInstance: &localInstance{fetch: func() (*metadata.InstanceData, error) {
+					fetched++
+					return te.data, te.err
+				}},
+			}}
+
+			for n := 0; n < 2; n++ {
+				got, ok := instances.getLocalNodeAddresses(te.instanceID)
+				if ok != te.ok {
+					t.Fatalf("expected: %v, got: %v", te.ok, ok)
+				}
+				if ok && !reflect.DeepEqual(got, te.expected) {
+					t.Fatalf("expected: %v, got: %v", te.expected, got)
+				}
+			}
+			if fetched > 1 {
+				t.Fatalf("expected: the metadata service is probed at most once, got: %v", fetched)
+			}
+		})
+	}
+}
+
+func TestNodeAddressesByProviderIDProvidedNodeIP(t *testing.T) {
+	instanceID := "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2"
+	providerID := "huaweicloud://" + instanceID
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{nodeProviderIDIndex: indexNodeByProviderID})
+	for _, node := range []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-2",
+				Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.10"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "huaweicloud://other-instance"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node-1",
+				Annotations: map[string]string{cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.1.10"},
+			},
+			Spec: v1.NodeSpec{ProviderID: providerID},
+		},
+	} {
+		if err := indexer.Add(node); err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+	}
+	nodeIndexer := &NodeIndexer{}
+	nodeIndexer.SetIndexer(indexer)
+
+	instances := &Instances{Basic: Basic{
+		cloudConfig:  &config.CloudConfig{},
+		metadataOpts: &config.MetadataOptions{NodeAddresses: true},
+		nodeIndexer:  nodeIndexer,
+		localInstance: &localInstance{fetch: func() (*metadata.InstanceData, error) {
+			return &metadata.InstanceData{
+				Metadata: &metadata.Metadata{UUID: instanceID},
+				NetworkData: &metadata.NetworkData{Networks: []metadata.Network{
+					{ID: "network0", IPAddress: "192.168.0.10"},
+					{ID: "network1", IPAddress: "192.168.1.10"},
+				}},
+			}, nil
+		}},
+	}}
+
+	addresses, err := instances.NodeAddressesByProviderID(context.TODO(), providerID)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	expected := []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.1.10"}}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, addresses)
+	}
+}
+
+func TestGetNodeByProviderIDWithoutInformer(t *testing.T) {
+	providerID := "huaweicloud://b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2"
+	lists := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v1.NodeList{
+			TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"},
+			Items: []v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{ProviderID: providerID}},
+			},
+		})
+	}))
+	defer server.Close()
+	kubeClient, err := corev1.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		annotation    string
+		nodeIndexer   *NodeIndexer
+		expectedNode  bool
+		expectedLists int
+	}{
+		{
+			name:          "annotation disabled",
+			expectedLists: 0,
+		},
+		{
+			name:          "informer not set",
+			nodeIndexer:   &NodeIndexer{},
+			expectedLists: 0,
+		},
+		{
+			name:          "annotation enabled",
+			annotation:    "example.com/node-addresses",
+			expectedNode:  true,
+			expectedLists: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lists = 0
+			cloudConfig := &config.CloudConfig{}
+			cloudConfig.AuthOpts.NodeAddressesAnnotation = tt.annotation
+			instances := &Instances{Basic: Basic{
+				cloudConfig: cloudConfig,
+				kubeClient:  kubeClient,
+				nodeIndexer: tt.nodeIndexer,
+			}}
+
+			node := instances.getNodeByProviderID(context.TODO(), providerID)
+			if (node != nil) != tt.expectedNode {
+				t.Fatalf("expected node: %v, got: %v", tt.expectedNode, node)
+			}
+			if lists != tt.expectedLists {
+				t.Fatalf("expected: %d lists, got: %d", tt.expectedLists, lists)
+			}
+		})
+	}
+}
+
+func TestInvalidateInstance(t *testing.T) {
+	b := &Basic{addressCache: NewNodeAddressCache(time.Minute)}
+	calls := 0
+	build := func() ([]v1.NodeAddress, error) {
+		calls++
+		return []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}, nil
+	}
+	lookup := func() {
+		if _, err := b.addressCache.Get("instance-1", "2023-06-01T08:00:00Z", build); err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+	}
+
+	lookup()
+	lookup()
+	if calls != 1 {
+		t.Fatalf("expected: 1 call, got: %v", calls)
+	}
+
+	// the malformed provider ID and the other instances do not purge the entry.
+	b.InvalidateInstance("openstack://instance-1")
+	b.InvalidateInstance("huaweicloud://instance-2")
+	lookup()
+	if calls != 1 {
+		t.Fatalf("expected: 1 call, got: %v", calls)
+	}
+
+	b.InvalidateInstance("huaweicloud://0a1b2c3d4e5f60718293a4b5c6d7e8f9/instance-1")
+	lookup()
+	if calls != 2 {
+		t.Fatalf("expected: the entry is purged and refetched, got: %v calls", calls)
+	}
+
+	// the cache is not required.
+	(&Basic{}).InvalidateInstance("huaweicloud://instance-1")
+}
+
+func TestGetInstanceFlavor(t *testing.T) {
+	mapping := map[string]string{
+		"s6.large.2":     "general-2c4g",
+		"c7.xlarge.2":    "compute_4c8g",
+		"legacy-flavor":  "",
+		"m6.large.8":     "memory/2c16g",
+		"flavor-id-only": "mapped-by-id",
+	}
+	tests := []struct {
+		name     string
+		flavor   ecsmodel.ServerFlavor
+		mapping  map[string]string
+		expected string
+	}{
+		{name: "passthrough", flavor: ecsmodel.ServerFlavor{Id: "s6.large.2", Name: "s6.large.2"},
+			expected: "s6.large.2"},
+		{name: "unmapped", flavor: ecsmodel.ServerFlavor{Id: "s6.xlarge.2", Name: "s6.xlarge.2"}, mapping: mapping,
+			expected: "s6.xlarge.2"},
+		{name: "mapped by ID", flavor: ecsmodel.ServerFlavor{Id: "flavor-id-only", Name: "custom"}, mapping: mapping,
+			expected: "mapped-by-id"},
+		{name: "mapped by name", flavor: ecsmodel.ServerFlavor{Id: "1234", Name: "c7.xlarge.2"}, mapping: mapping,
+			expected: "compute_4c8g"},
+		{name: "ID without name", flavor: ecsmodel.ServerFlavor{Id: "s3.small.1"}, expected: "s3.small.1"},
+		{name: "empty mapped type", flavor: ecsmodel.ServerFlavor{Id: "legacy-flavor", Name: "legacy-flavor"},
+			mapping: mapping, expected: "legacy-flavor"},
+		{name: "invalid mapped type", flavor: ecsmodel.ServerFlavor{Id: "m6.large.8", Name: "m6.large.8"},
+			mapping: mapping, expected: "m6.large.8"},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got, err := getInstanceFlavor(&ecsmodel.ServerDetail{Flavor: &te.flavor}, te.mapping)
+			if err != nil || got != te.expected {
+				t.Fatalf("expected: %v, got: %v, %v", te.expected, got, err)
+			}
+		})
+	}
+
+	if _, err := getInstanceFlavor(&ecsmodel.ServerDetail{Flavor: &ecsmodel.ServerFlavor{}}, mapping); err == nil {
+		t.Fatalf("expected: an error, got: nil")
+	}
+}
+
+func TestGetInstanceAddressesBuilding(t *testing.T) {
+	i := &Instances{Basic: Basic{addressCache: NewNodeAddressCache(time.Minute)}}
+	builds := 0
+	build := func(addresses ...v1.NodeAddress) func() ([]v1.NodeAddress, error) {
+		return func() ([]v1.NodeAddress, error) {
+			builds++
+			return addresses, nil
+		}
+	}
+	internal := v1.NodeAddress{Type: v1.NodeInternalIP, Address: "192.168.0.10"}
+
+	// the ECS being built has no addresses yet, the error is retryable and nothing is cached.
+	for _, building := range []string{"BUILD", "BUILDING"} {
+		server := &ecsmodel.ServerDetail{Id: "ecs-1", Status: building, Updated: "2023-01-01T00:00:00Z"}
+		_, err := i.getInstanceAddresses(server, build())
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected: %v, got: %v", codes.Unavailable, err)
+		}
+	}
+	if builds != 0 {
+		t.Fatalf("expected: the addresses are not built, got: %v builds", builds)
+	}
+
+	// the ECS becomes ACTIVE with the same updated timestamp, the addresses are built rather than the empty ones.
+	server := &ecsmodel.ServerDetail{Id: "ecs-1", Status: "ACTIVE", Updated: "2023-01-01T00:00:00Z"}
+	addresses, err := i.getInstanceAddresses(server, build(internal))
+	if err != nil || !reflect.DeepEqual(addresses, []v1.NodeAddress{internal}) {
+		t.Fatalf("expected: %v, got: %v, %v", []v1.NodeAddress{internal}, addresses, err)
+	}
+	if addresses, err = i.getInstanceAddresses(server, build()); err != nil || len(addresses) != 1 || builds != 1 {
+		t.Fatalf("expected: the cached addresses, got: %v, %v, %v builds", addresses, err, builds)
+	}
+}
+
+func TestGetInstanceAddressesUpdated(t *testing.T) {
+	i := &Instances{Basic: Basic{addressCache: NewNodeAddressCache(time.Minute)}}
+	builds := 0
+	build := func(address string) func() ([]v1.NodeAddress, error) {
+		return func() ([]v1.NodeAddress, error) {
+			builds++
+			return []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: address}}, nil
+		}
+	}
+
+	tests := []struct {
+		name           string
+		updated        string
+		address        string
+		expected       string
+		expectedBuilds int
+	}{
+		{
+			name:           "not cached",
+			updated:        "2023-01-01T00:00:00Z",
+			address:        "192.168.0.10",
+			expected:       "192.168.0.10",
+			expectedBuilds: 1,
+		},
+		{
+			name:           "updated timestamp unchanged",
+			updated:        "2023-01-01T00:00:00Z",
+			address:        "192.168.0.20",
+			expected:       "192.168.0.10",
+			expectedBuilds: 1,
+		},
+		{
+			name:           "updated timestamp changed",
+			updated:        "2023-01-01T00:05:00Z",
+			address:        "192.168.0.20",
+			expected:       "192.168.0.20",
+			expectedBuilds: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &ecsmodel.ServerDetail{Id: "ecs-1", Status: "ACTIVE", Updated: tt.updated}
+			addresses, err := i.getInstanceAddresses(server, build(tt.address))
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if len(addresses) != 1 || addresses[0].Address != tt.expected {
+				t.Fatalf("expected: %v, got: %v", tt.expected, addresses)
+			}
+			if builds != tt.expectedBuilds {
+				t.Fatalf("expected: %d builds, got: %d", tt.expectedBuilds, builds)
+			}
+		})
+	}
+}
+
+func TestIsInstanceReadyToInitialize(t *testing.T) {
+	defaults := (&config.AuthOptions{}).GetNotReadyInstanceStatuses()
+	tests := []struct {
+		name             string
+		status           string
+		notReadyStatuses []string
+		expected         bool
+	}{
+		{
+			name:             "active",
+			status:           "ACTIVE",
+			notReadyStatuses: defaults,
+			expected:         true,
+		},
+		{
+			name:             "building",
+			status:           "BUILD",
+			notReadyStatuses: defaults,
+		},
+		{
+			name:             "rebooting",
+			status:           "REBOOT",
+			notReadyStatuses: defaults,
+		},
+		{
+			name:             "hard rebooting",
+			status:           "HARD_REBOOT",
+			notReadyStatuses: defaults,
+		},
+		{
+			name:             "shutoff",
+			status:           "SHUTOFF",
+			notReadyStatuses: defaults,
+			expected:         true,
+		},
+		{
+			name:             "customized statuses",
+			status:           "REBOOT",
+			notReadyStatuses: []string{"BUILD"},
+			expected:         true,
+		},
+	}
+	uninitializedNode := newTestNode(nil)
+	uninitializedNode.Spec.Taints = []v1.Taint{
+		{Key: cloudproviderapi.TaintExternalCloudProvider, Value: "true", Effect: v1.TaintEffectNoSchedule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &ecsmodel.ServerDetail{Id: "ecs-1", Status: tt.status}
+			if ready := isInstanceReadyToInitialize(server, tt.notReadyStatuses); ready != tt.expected {
+				t.Fatalf("expected: %v, got: %v", tt.expected, ready)
+			}
+			err := checkInstanceReadyToInitialize(uninitializedNode, server, tt.notReadyStatuses)
+			if tt.expected && err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if !tt.expected && status.Code(err) != codes.Unavailable {
+				t.Fatalf("expected: %v, got: %v", codes.Unavailable, err)
+			}
+		})
+	}
+
+	// the metadata of an initialized node is returned while its ECS is rebooting.
+	server := &ecsmodel.ServerDetail{Id: "ecs-1", Status: "REBOOT"}
+	if err := checkInstanceReadyToInitialize(newTestNode(nil), server, defaults); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+}
+
+func TestIsInstanceShutdown(t *testing.T) {
+	tests := []struct {
+		status   string
+		policy   string
+		expected bool
+	}{
+		{status: "SHUTOFF", policy: config.ShutoffInstancePolicyShutdown, expected: true},
+		{status: "SHUTOFF", policy: config.ShutoffInstancePolicyIgnore, expected: false},
+		{status: "ACTIVE", policy: config.ShutoffInstancePolicyShutdown, expected: false},
+		{status: "ACTIVE", policy: config.ShutoffInstancePolicyIgnore, expected: false},
+		{status: "ERROR", policy: config.ShutoffInstancePolicyShutdown, expected: false},
+	}
+
+	for _, te := range tests {
+		t.Run(te.status+"/"+te.policy, func(t *testing.T) {
+			if got := isInstanceShutdown(te.status, te.policy); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+// captureLogs returns the logs written by klog while the function runs.
+func captureLogs(fn func()) string {
+	logs := &bytes.Buffer{}
+	klog.LogToStderr(false)
+	klog.SetOutput(logs)
+	defer func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	}()
+
+	fn()
+	klog.Flush()
+	return logs.String()
+}
+
+func TestInstancesStructuredLogs(t *testing.T) {
+	apiErr := sdkerr.ServiceResponseError{StatusCode: 500, RequestId: "req-1", ErrorCode: "Ecs.0000"}
+
+	tests := []struct {
+		name     string
+		call     func()
+		expected []string
+	}{
+		{
+			name: "called",
+			call: func() {
+				_, _ = (&Instances{}).CurrentNodeName(context.TODO(), "node-1")
+			},
+			expected: []string{`"Instances API is called"`, `operation="CurrentNodeName"`, `hostname="node-1"`},
+		},
+		{
+			name: "failed",
+			call: func() {
+				logInstanceError(apiErr, "InstanceMetadata", "ecs://project-1/server-1", "server-1")
+			},
+			expected: []string{`"Failed to query the ECS"`, `operation="InstanceMetadata"`,
+				`providerID="ecs://project-1/server-1"`, `serverID="server-1"`, `errorCode="Ecs.0000"`,
+				`statusCode=500`, `requestID="req-1"`},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			logs := captureLogs(te.call)
+			for _, field := range te.expected {
+				if !strings.Contains(logs, field) {
+					t.Fatalf("expected: %v, got: %v", field, logs)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupInstanceIDByPrivateIP(t *testing.T) {
+	servers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{{Id: "instance-1", Name: "node-1"}}}
+	byIP := map[string]*ecsmodel.ServerDetail{"192.168.0.20": {Id: "instance-2", Name: "ecs-migrated"}}
+
+	tests := []struct {
+		name     string
+		node     string
+		ip       string
+		enabled  bool
+		expected string
+		lookups  int
+	}{
+		{name: "found by name", node: "node-1", ip: "192.168.0.10", enabled: true, expected: "instance-1"},
+		{name: "found by private IP", node: "node-2", ip: "192.168.0.20", enabled: true, expected: "instance-2",
+			lookups: 1},
+		{name: "not found by private IP", node: "node-3", ip: "192.168.0.30", enabled: true, expected: "",
+			lookups: 1},
+		{name: "disabled", node: "node-2", ip: "192.168.0.20", enabled: false, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			var fallback func() (*ecsmodel.ServerDetail, error)
+			if tt.enabled {
+				fallback = func() (*ecsmodel.ServerDetail, error) {
+					lookups++
+					if server, ok := byIP[tt.ip]; ok {
+						return server, nil
+					}
+					return nil, status.Errorf(codes.NotFound, "not found any ECS, PrivateIP: %s", tt.ip)
+				}
+			}
+			id, err := lookupInstanceID(servers, nil, tt.node, fallback)
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if id != tt.expected || lookups != tt.lookups {
+				t.Fatalf("expected: %v with %d lookups by IP, got: %v with %d", tt.expected, tt.lookups, id, lookups)
+			}
+		})
+	}
+}
+
+func TestNodePrivateIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *v1.Node
+		expected string
+	}{
+		{
+			name: "provided node IP",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					cloudproviderapi.AnnotationAlphaProvidedIPAddr: "192.168.0.20",
+				}},
+				Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}},
+			},
+			expected: "192.168.0.20",
+		},
+		{
+			name: "first InternalIP",
+			node: &v1.Node{Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "node-1"},
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+			}}},
+			expected: "192.168.0.10",
+		},
+		{
+			name:     "no address",
+			node:     &v1.Node{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nodePrivateIP(tt.node); got != tt.expected {
+				t.Fatalf("expected: %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetAnnotatedNodeAddresses(t *testing.T) {
+	const key = "example.com/node-addresses"
+	newNode := func(annotations map[string]string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: annotations}}
+	}
+	tests := []struct {
+		name          string
+		node          *v1.Node
+		key           string
+		expected      []v1.NodeAddress
+		expectedOK    bool
+		expectedError bool
+	}{
+		{
+			name: "annotation present",
+			node: newNode(map[string]string{key: `[{"type": "InternalIP", "address": "192.168.0.10"},
+				{"type": "ExternalIP", "address": "100.85.0.10"}, {"type": "Hostname", "address": "node-1"}]`}),
+			key: key,
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeHostName, Address: "node-1"},
+			},
+			expectedOK: true,
+		},
+		{
+			name: "annotation absent",
+			node: newNode(map[string]string{"other": "value"}),
+			key:  key,
+		},
+		{
+			name: "option not set",
+			node: newNode(map[string]string{key: `[{"type": "InternalIP", "address": "192.168.0.10"}]`}),
+			key:  "",
+		},
+		{
+			name: "node not found",
+			key:  key,
+		},
+		{
+			name:          "invalid JSON",
+			node:          newNode(map[string]string{key: "192.168.0.10"}),
+			key:           key,
+			expectedOK:    true,
+			expectedError: true,
+		},
+		{
+			name:          "empty list",
+			node:          newNode(map[string]string{key: "[]"}),
+			key:           key,
+			expectedOK:    true,
+			expectedError: true,
+		},
+		{
+			name:          "invalid IP",
+			node:          newNode(map[string]string{key: `[{"type": "InternalIP", "address": "node-1"}]`}),
+			key:           key,
+			expectedOK:    true,
+			expectedError: true,
+		},
+		{
+			name:          "unsupported type",
+			node:          newNode(map[string]string{key: `[{"type": "PodIP", "address": "10.0.0.1"}]`}),
+			key:           key,
+			expectedOK:    true,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addresses, ok, err := getAnnotatedNodeAddresses(tt.node, tt.key)
+			if ok != tt.expectedOK || (err != nil) != tt.expectedError {
+				t.Fatalf("expected: %v with error %v, got: %v with %v", tt.expectedOK, tt.expectedError, ok, err)
+			}
+			if err != nil && status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, status.Code(err))
+			}
+			if !reflect.DeepEqual(addresses, tt.expected) {
+				t.Fatalf("expected: %v, got: %v", tt.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestGetSystemUUID(t *testing.T) {
+	getter := &fakeServerGetter{servers: []ecsmodel.ServerDetail{
+		{Id: "B77C45C1-B6CF-4F5E-B072-0EE86DAEB6C2", Name: "node-1"},
+	}}
+	tests := []struct {
+		name         string
+		instanceID   string
+		expected     string
+		expectedCode codes.Code
+	}{
+		{
+			name:       "server",
+			instanceID: "B77C45C1-B6CF-4F5E-B072-0EE86DAEB6C2",
+			expected:   "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2",
+		},
+		{
+			name:         "not found",
+			instanceID:   "0c8f6b3e-8d3c-4b7a-9f3e-2a6f0d1c5b4a",
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getSystemUUID(getter, tt.instanceID)
+			if status.Code(err) != tt.expectedCode || got != tt.expected {
+				t.Fatalf("expected: %v with %v, got: %v with %v", tt.expected, tt.expectedCode, got, err)
+			}
+		})
+	}
+}
+
+func TestGetSystemUUIDFromMetadata(t *testing.T) {
+	i := &Instances{Basic: Basic{localInstance: &localInstance{fetch: func() (*metadata.InstanceData, error) {
+		return &metadata.InstanceData{Metadata: &metadata.Metadata{UUID: "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2"}}, nil
+	}}}}
+	got, err := i.GetSystemUUID(providerIDPrefix + "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2")
+	if err != nil || got != "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2" {
+		t.Fatalf("expected: %v, got: %v with %v", "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2", got, err)
+	}
+	if _, err = i.GetSystemUUID("aws:///i-1"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestIsSystemUUIDMatched(t *testing.T) {
+	tests := []struct {
+		name       string
+		systemUUID string
+		expected   bool
+	}{
+		{name: "matched", systemUUID: "B77C45C1-B6CF-4F5E-B072-0EE86DAEB6C2", expected: true},
+		{name: "not reported", systemUUID: "", expected: true},
+		{name: "another ECS", systemUUID: "0c8f6b3e-8d3c-4b7a-9f3e-2a6f0d1c5b4a", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &v1.Node{Status: v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{SystemUUID: tt.systemUUID}}}
+			if got := isSystemUUIDMatched(node, "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2"); got != tt.expected {
+				t.Fatalf("expected: %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func newTestAllowedAddressPairPort(id, address string, pairs ...string) vpcmodel.Port {
+	port := newTestPort(id, address, "subnet-a")
+	for _, pair := range pairs {
+		port.AllowedAddressPairs = append(port.AllowedAddressPairs, vpcmodel.AllowedAddressPair{IpAddress: pair})
+	}
+	return port
+}
+
+func newTestInterface(portID, state string) ecsmodel.InterfaceAttachment {
+	return ecsmodel.InterfaceAttachment{PortId: &portID, PortState: &state}
+}
+
+func TestGetAllowedAddressPairAddresses(t *testing.T) {
+	ports := &fakePortLister{ports: map[string][]vpcmodel.Port{
+		"server-a": {
+			newTestAllowedAddressPairPort("port-a0", "192.168.0.10", "192.168.0.100", "192.168.0.101/32"),
+			// the CIDRs and IPv6 addresses are not reported.
+			newTestAllowedAddressPairPort("port-a1", "192.168.1.10", "192.168.1.0/24", "fd00::100", "192.168.0.100"),
+			newTestAllowedAddressPairPort("port-a2", "192.168.2.10", "192.168.2.100"),
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		interfaces []ecsmodel.InterfaceAttachment
+		expected   []v1.NodeAddress
+	}{
+		{
+			name: "active interfaces",
+			interfaces: []ecsmodel.InterfaceAttachment{
+				newTestInterface("port-a0", "ACTIVE"),
+				newTestInterface("port-a1", "ACTIVE"),
+				newTestInterface("port-a2", "DOWN"),
+			},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.100"},
+				{Type: v1.NodeInternalIP, Address: "192.168.0.101"},
+			},
+		},
+		{
+			name:       "no active interface",
+			interfaces: []ecsmodel.InterfaceAttachment{newTestInterface("port-a0", "DOWN")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addresses, err := getAllowedAddressPairAddresses(ports, "server-a", tt.interfaces)
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if len(addresses) != len(tt.expected) || (len(addresses) > 0 && !reflect.DeepEqual(addresses, tt.expected)) {
+				t.Fatalf("expected: %v, got: %v", tt.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestRetryLookupNotFound(t *testing.T) {
+	server := ecsmodel.ServerDetail{Id: "instance-1", Name: "node-1"}
+	tests := []struct {
+		name          string
+		appearAfter   int
+		retries       int
+		expected      string
+		expectedCalls int
+	}{
+		{
+			name:          "listed after the first lookup",
+			appearAfter:   1,
+			retries:       3,
+			expected:      "instance-1",
+			expectedCalls: 2,
+		},
+		{
+			name:          "listed immediately",
+			retries:       3,
+			expected:      "instance-1",
+			expectedCalls: 1,
+		},
+		{
+			name:          "not listed within the retries",
+			appearAfter:   5,
+			retries:       3,
+			expectedCalls: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the ECS just created is not listed by name until the index catches up.
+			servers := &fakeServerGetter{}
+			id, err := retryLookupNotFound(context.TODO(), func() (string, error) {
+				if servers.calls >= tt.appearAfter {
+					servers.servers = []ecsmodel.ServerDetail{server}
+				}
+				return lookupInstanceID(servers, nil, "node-1", nil)
+			}, tt.retries, time.Millisecond)
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if id != tt.expected || servers.calls != tt.expectedCalls {
+				t.Fatalf("expected: %q after %d calls, got: %q after %d calls", tt.expected, tt.expectedCalls,
+					id, servers.calls)
+			}
+		})
+	}
+
+	// the provider ID of the node without spec.providerID is resolved once the ECS is listed.
+	servers := &fakeServerGetter{}
+	providerID, err := retryLookupNotFound(context.TODO(), func() (string, error) {
+		defer func() { servers.servers = []ecsmodel.ServerDetail{server} }()
+		return getProviderIDByName(servers, "node-1")
+	}, 3, time.Millisecond)
+	if err != nil || providerID != providerIDPrefix+server.Id {
+		t.Fatalf("expected: %s, got: %s, %v", providerIDPrefix+server.Id, providerID, err)
+	}
+
+	// the retries stop once ctx is done, without waiting for the interval.
+	ctx, cancel := context.WithCancel(context.TODO())
+	calls := 0
+	start := time.Now()
+	_, err = retryLookupNotFound(ctx, func() (string, error) {
+		calls++
+		cancel()
+		return "", nil
+	}, 3, time.Hour)
+	if !errors.Is(err, context.Canceled) || calls != 1 || time.Since(start) > time.Minute {
+		t.Fatalf("expected: %v after 1 call, got: %v after %d calls", context.Canceled, err, calls)
+	}
+}
+
+func TestRetryNewNodeLookup(t *testing.T) {
+	newNode := newTestNode(nil)
+	newNode.CreationTimestamp = metav1.NewTime(time.Now().Add(-10 * time.Second))
+	oldNode := newTestNode(nil)
+	oldNode.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name          string
+		node          *v1.Node
+		retries       int
+		expectedCalls int
+	}{
+		{name: "retries disabled", node: newNode, expectedCalls: 1},
+		{name: "old node", node: oldNode, retries: 1, expectedCalls: 1},
+		{name: "node not got", retries: 1, expectedCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Instances{Basic: Basic{cloudConfig: &config.CloudConfig{
+				AuthOpts: config.AuthOptions{NewNodeLookupRetries: tt.retries},
+			}}}
+			calls := 0
+			id, err := i.retryNewNodeLookup(context.TODO(), func() *v1.Node { return tt.node }, func() (string, error) {
+				calls++
+				return "", nil
+			})
+			if id != "" || err != nil || calls != tt.expectedCalls {
+				t.Fatalf("expected: %d calls, got: %d calls, %q, %v", tt.expectedCalls, calls, id, err)
+			}
+		})
+	}
+
+	if !isNewNode(newNode, 5*time.Minute, time.Now()) {
+		t.Fatalf("expected: node created %s is new", newNode.CreationTimestamp)
+	}
+}
+
+func TestRetryNewNodeLookupReleasesSlot(t *testing.T) {
+	node := newTestNode(nil)
+	node.CreationTimestamp = metav1.NewTime(time.Now())
+	i := &Instances{Basic: Basic{
+		cloudConfig:  &config.CloudConfig{AuthOpts: config.AuthOptions{NewNodeLookupRetries: 1}},
+		reconcileSem: semaphore.NewSemaphore(1),
+	}}
+
+	// a reconcile waits for the only slot while the lookup of the new node is being retried.
+	acquired := make(chan error, 1)
+	calls := 0
+	ctx := context.TODO()
+	id, err := i.retryNewNodeLookup(ctx, func() *v1.Node { return node }, i.lookupWithSlot(ctx, func() (string, error) {
+		calls++
+		if calls == 1 {
+			go func() {
+				waitCtx, cancel := context.WithTimeout(ctx, newNodeLookupRetryInterval*9/10)
+				defer cancel()
+				err := i.reconcileSem.Acquire(waitCtx)
+				if err == nil {
+					i.reconcileSem.Release()
+				}
+				acquired <- err
+			}()
+			return "", nil
+		}
+		return "instance-1", nil
+	}))
+	if id != "instance-1" || err != nil || calls != 2 {
+		t.Fatalf("expected: instance-1 after 2 calls, got: %q, %v after %d calls", id, err, calls)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected: the slot is released between the retries, got: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+type listenerAction string
+
+const (
+	listenerActionCreate listenerAction = "create"
+	listenerActionUpdate listenerAction = "update"
+	listenerActionDelete listenerAction = "delete"
+)
+
+const (
+	// listenerOwnerPrefix marks the description of the listeners created by the cloud provider,
+	// it is followed by the namespace and name of the service and the port.
+	listenerOwnerPrefix = "k8s_service:"
+
+	maxListenerDescriptionLength = 255
+)
+
+// listenerKey identifies an existing listener of the ELB instance, regardless of the ELB type.
+type listenerKey struct {
+	ID          string
+	Name        string
+	Description string
+	Protocol    string
+	Port        int32
+}
+
+// getListenerName returns the name of the listener, such as "nginx_TCP_80".
+func getListenerName(service *v1.Service, protocol string, port int32) string {
+	return utils.CutString(fmt.Sprintf("%s_%s_%v", service.Name, protocol, port), defaultMaxNameLength)
+}
+
+// getListenerOwner returns the prefix of the descriptions of the listeners created for the service.
+func getListenerOwner(service *v1.Service) string {
+	return fmt.Sprintf("%s%s/%s:", listenerOwnerPrefix, service.Namespace, service.Name)
+}
+
+// getListenerDescription returns the description of the listener, such as "k8s_service:default/nginx:80",
+// it identifies the service and the port the listener is created for.
+func getListenerDescription(service *v1.Service, port v1.ServicePort) string {
+	return utils.CutString(fmt.Sprintf("%s%d", getListenerOwner(service), port.Port), maxListenerDescriptionLength)
+}
+
+// isListenerOfOtherService returns true if the description shows the listener is created for another service.
+func isListenerOfOtherService(service *v1.Service, description string) bool {
+	return strings.HasPrefix(description, listenerOwnerPrefix) &&
+		!strings.HasPrefix(description, getListenerOwner(service))
+}
+
+// isListenerOwned returns true if the listener is created for the service. The listeners created before
+// the description is set are identified by the names given to the listeners of the service ports.
+func isListenerOwned(service *v1.Service, lis listenerKey) bool {
+	if strings.HasPrefix(lis.Description, listenerOwnerPrefix) {
+		return strings.HasPrefix(lis.Description, getListenerOwner(service))
+	}
+	for _, port := range service.Spec.Ports {
+		if lis.Name == getListenerName(service, string(port.Protocol), port.Port) ||
+			lis.Name == getListenerName(service, parseProtocol(service, port), port.Port) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenerOperation is a step to reconcile the listeners, the port is only set when creating or updating,
+// and the listenerIDs are the listener to update or the listeners to delete.
+type listenerOperation struct {
+	action      listenerAction
+	port        v1.ServicePort
+	listenerIDs []string
+}
+
+// planListenerOperations returns the steps to reconcile the listeners with the service ports in order.
+// The new listeners are created before the obsolete ones are deleted, so that the VIP keeps serving.
+// Only when a port is taken by an obsolete listener with a different protocol of the same transport layer,
+// such as TCP is changed to HTTP, the obsolete one is deleted right before the new one is created.
+// The obsolete listeners are not deleted if deleteObsolete is false, such as the ELB instance is specified.
+// The listeners created for other services are left untouched.
+func planListenerOperations(listeners []listenerKey, service *v1.Service, deleteObsolete bool) []listenerOperation {
+	existing := make([]listenerKey, 0, len(listeners))
+	for _, lis := range listeners {
+		if !isListenerOfOtherService(service, lis.Description) {
+			existing = append(existing, lis)
+		}
+	}
+
+	matched := make(map[string]bool)
+	ops := make([]listenerOperation, 0, len(service.Spec.Ports)+1)
+	for _, port := range service.Spec.Ports {
+		protocol := parseProtocol(service, port)
+		op := listenerOperation{action: listenerActionCreate, port: port}
+		for _, lis := range existing {
+			if lis.Protocol == protocol && lis.Port == port.Port {
+				op.action = listenerActionUpdate
+				op.listenerIDs = []string{lis.ID}
+				matched[lis.ID] = true
+				break
+			}
+		}
+		ops = append(ops, op)
+	}
+
+	if !deleteObsolete {
+		return ops
+	}
+
+	rst := make([]listenerOperation, 0, len(ops)+1)
+	for _, op := range ops {
+		if op.action == listenerActionCreate {
+			conflicts := make([]string, 0)
+			protocol := parseProtocol(service, op.port)
+			for _, lis := range existing {
+				if !matched[lis.ID] && lis.Port == op.port.Port && isSameTransportLayer(lis.Protocol, protocol) {
+					conflicts = append(conflicts, lis.ID)
+					matched[lis.ID] = true
+				}
+			}
+			if len(conflicts) > 0 {
+				rst = append(rst, listenerOperation{action: listenerActionDelete, listenerIDs: conflicts})
+			}
+		}
+		rst = append(rst, op)
+	}
+
+	obsolete := make([]string, 0)
+	for _, lis := range existing {
+		if !matched[lis.ID] {
+			obsolete = append(obsolete, lis.ID)
+		}
+	}
+	if len(obsolete) > 0 {
+		rst = append(rst, listenerOperation{action: listenerActionDelete, listenerIDs: obsolete})
+	}
+	return rst
+}
+
+// isSameTransportLayer returns true if the listeners of the protocols cannot listen on the same port.
+func isSameTransportLayer(protocol1, protocol2 string) bool {
+	return (protocol1 == ProtocolUDP) == (protocol2 == ProtocolUDP)
+}
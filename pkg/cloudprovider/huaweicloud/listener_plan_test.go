@@ -0,0 +1,222 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// formatListenerOperations formats the operations as "create TCP:80" or "delete id-1,id-2".
+func formatListenerOperations(ops []listenerOperation) []string {
+	rst := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.action == listenerActionCreate {
+			rst = append(rst, fmt.Sprintf("%s %s:%d", op.action, op.port.Protocol, op.port.Port))
+			continue
+		}
+		rst = append(rst, fmt.Sprintf("%s %s", op.action, strings.Join(op.listenerIDs, ",")))
+	}
+	return rst
+}
+
+func TestPlanListenerOperations(t *testing.T) {
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		ports          []v1.ServicePort
+		existing       []listenerKey
+		deleteObsolete bool
+		expected       []string
+	}{
+		{
+			name:  "unchanged",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}},
+			existing: []listenerKey{
+				{ID: "tcp-80", Protocol: ProtocolTCP, Port: 80},
+			},
+			deleteObsolete: true,
+			expected:       []string{"update tcp-80"},
+		},
+		{
+			name:  "port added",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}, {Protocol: v1.ProtocolTCP, Port: 443}},
+			existing: []listenerKey{
+				{ID: "tcp-80", Protocol: ProtocolTCP, Port: 80},
+			},
+			deleteObsolete: true,
+			expected:       []string{"update tcp-80", "create TCP:443"},
+		},
+		{
+			name:  "port removed",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 443}},
+			existing: []listenerKey{
+				{ID: "tcp-80", Protocol: ProtocolTCP, Port: 80},
+				{ID: "tcp-443", Protocol: ProtocolTCP, Port: 443},
+			},
+			deleteObsolete: true,
+			expected:       []string{"update tcp-443", "delete tcp-80"},
+		},
+		{
+			name:  "port replaced, add before remove",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 8080}},
+			existing: []listenerKey{
+				{ID: "tcp-80", Protocol: ProtocolTCP, Port: 80},
+			},
+			deleteObsolete: true,
+			expected:       []string{"create TCP:8080", "delete tcp-80"},
+		},
+		{
+			name:        "protocol changed on the same port",
+			annotations: map[string]string{ElbXForwardedHost: "true"},
+			ports:       []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}, {Protocol: v1.ProtocolTCP, Port: 8080}},
+			existing: []listenerKey{
+				{ID: "tcp-80", Protocol: ProtocolTCP, Port: 80},
+				{ID: "tcp-9090", Protocol: ProtocolTCP, Port: 9090},
+			},
+			deleteObsolete: true,
+			expected:       []string{"delete tcp-80", "create TCP:80", "create TCP:8080", "delete tcp-9090"},
+		},
+		{
+			name:  "TCP and UDP on the same port",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolUDP, Port: 53}},
+			existing: []listenerKey{
+				{ID: "tcp-53", Protocol: ProtocolTCP, Port: 53},
+			},
+			deleteObsolete: true,
+			expected:       []string{"create UDP:53", "delete tcp-53"},
+		},
+		{
+			name:  "specified ELB keeps the obsolete listeners",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 443}},
+			existing: []listenerKey{
+				{ID: "tcp-80", Protocol: ProtocolTCP, Port: 80},
+				{ID: "http-443", Protocol: ProtocolHTTP, Port: 443},
+			},
+			deleteObsolete: false,
+			expected:       []string{"create TCP:443"},
+		},
+		{
+			name:  "matched by the description",
+			ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}, {Protocol: v1.ProtocolTCP, Port: 443}},
+			existing: []listenerKey{
+				{ID: "other-80", Description: "k8s_service:other/test:80", Protocol: ProtocolTCP, Port: 80},
+				{ID: "tcp-443", Description: "k8s_service:default/test:443", Protocol: ProtocolTCP, Port: 443},
+				{ID: "other-8080", Description: "k8s_service:default/test-2:8080", Protocol: ProtocolTCP, Port: 8080},
+			},
+			deleteObsolete: true,
+			expected:       []string{"create TCP:80", "update tcp-443"},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := newTestService(testCase.annotations)
+			service.Spec.Ports = testCase.ports
+			ops := planListenerOperations(testCase.existing, service, testCase.deleteObsolete)
+			got := formatListenerOperations(ops)
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestListenerNameAndDescription(t *testing.T) {
+	service := newTestService(nil)
+	port := v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80}
+
+	if name := getListenerName(service, ProtocolTCP, port.Port); name != "test_TCP_80" {
+		t.Fatalf("expected: %v, got: %v", "test_TCP_80", name)
+	}
+	if desc := getListenerDescription(service, port); desc != "k8s_service:default/test:80" {
+		t.Fatalf("expected: %v, got: %v", "k8s_service:default/test:80", desc)
+	}
+
+	service.Name = strings.Repeat("a", 63)
+	service.Namespace = strings.Repeat("b", 63)
+	if name := getListenerName(service, ProtocolTerminatedHTTPS, port.Port); len(name) > defaultMaxNameLength {
+		t.Fatalf("expected: name within %d characters, got: %v", defaultMaxNameLength, name)
+	}
+	if desc := getListenerDescription(service, port); len(desc) > maxListenerDescriptionLength ||
+		!strings.HasSuffix(desc, ":80") {
+		t.Fatalf("expected: description ends with the port, got: %v", desc)
+	}
+}
+
+func TestIsListenerOwned(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener listenerKey
+		expected bool
+	}{
+		{
+			name:     "description of the service",
+			listener: listenerKey{Name: "renamed", Description: "k8s_service:default/test:80"},
+			expected: true,
+		},
+		{
+			name:     "description of another namespace",
+			listener: listenerKey{Name: "test_TCP_80", Description: "k8s_service:other/test:80"},
+			expected: false,
+		},
+		{
+			name:     "description of a service with the same prefix",
+			listener: listenerKey{Name: "test_TCP_80", Description: "k8s_service:default/test-2:80"},
+			expected: false,
+		},
+		{
+			name:     "legacy name of the service",
+			listener: listenerKey{Name: "test_TCP_80", Description: "created by user"},
+			expected: true,
+		},
+		{
+			name:     "legacy name of the service with the protocol of the annotations",
+			listener: listenerKey{Name: "test_HTTP_8080"},
+			expected: true,
+		},
+		{
+			name:     "legacy name of a port not in the service",
+			listener: listenerKey{Name: "test_TCP_443"},
+			expected: false,
+		},
+		{
+			name:     "legacy name of a service with the same prefix",
+			listener: listenerKey{Name: "test_web_TCP_80"},
+			expected: false,
+		},
+		{
+			name:     "foreign listener",
+			listener: listenerKey{Name: "manual-listener"},
+			expected: false,
+		},
+	}
+
+	service := newTestService(map[string]string{ElbXForwardedHost: "true"})
+	service.Spec.Ports = []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}, {Protocol: v1.ProtocolTCP, Port: 8080}}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := isListenerOwned(service, testCase.listener); got != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+const (
+	// ElbAvailabilityZoneGroups is the availability zones of the ELB instances of the service, the groups are
+	// separated by "|" and the zones of a group by ";", such as "az1;az2|az3". An ELB instance is created
+	// in each group, all of them forward to the same members.
+	ElbAvailabilityZoneGroups = "kubernetes.io/elb.availability-zone-groups"
+
+	// maxLoadBalancerReplicas is the maximum number of the ELB instances of a service.
+	maxLoadBalancerReplicas = 5
+)
+
+// instanceLister lists the ELB instances, such as the ones with the given names.
+type instanceLister interface {
+	ListInstances(req *elbmodel.ListLoadBalancersRequest) ([]elbmodel.LoadBalancer, error)
+}
+
+// parseAvailabilityZoneGroups returns the availability zone groups of the ELB instances of the service,
+// or nil if the service has only one ELB instance. The groups must not share any availability zone.
+func parseAvailabilityZoneGroups(service *v1.Service) ([][]string, error) {
+	str := getStringFromSvsAnnotation(service, ElbAvailabilityZoneGroups, "")
+	if str == "" {
+		return nil, nil
+	}
+
+	groups := make([][]string, 0)
+	zones := make(map[string]int)
+	for i, g := range strings.Split(str, "|") {
+		group := make([]string, 0)
+		for _, zone := range strings.Split(g, ";") {
+			zone = strings.TrimSpace(zone)
+			if zone == "" {
+				continue
+			}
+			if j, ok := zones[zone]; ok {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid annotation %s: %q, availability zone %s "+
+					"is in both group %d and group %d", ElbAvailabilityZoneGroups, str, zone, j, i)
+			}
+			zones[zone] = i
+			group = append(group, zone)
+		}
+		if len(group) == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid annotation %s: %q, group %d is empty",
+				ElbAvailabilityZoneGroups, str, i)
+		}
+		groups = append(groups, group)
+	}
+	if len(groups) > maxLoadBalancerReplicas {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid annotation %s: %q, at most %d groups are supported",
+			ElbAvailabilityZoneGroups, str, maxLoadBalancerReplicas)
+	}
+	return groups, nil
+}
+
+// validateAvailabilityZoneGroups checks that the availability zone groups are not used together with the annotations
+// that apply to only one ELB instance.
+func validateAvailabilityZoneGroups(service *v1.Service) error {
+	groups, err := parseAvailabilityZoneGroups(service)
+	if err != nil || len(groups) == 0 {
+		return err
+	}
+	for _, key := range []string{ElbID, ElbEipID} {
+		if getStringFromSvsAnnotation(service, key, "") != "" {
+			return status.Errorf(codes.InvalidArgument, "annotation %s cannot be used together with %s",
+				ElbAvailabilityZoneGroups, key)
+		}
+	}
+	return nil
+}
+
+// getReplicaLoadBalancerName returns the name of the ELB instance of the replica, the first one keeps the name
+// of the service's ELB instance, so that it is not re-created when the availability zone groups are added.
+func getReplicaLoadBalancerName(name string, replica int) string {
+	if replica == 0 {
+		return name
+	}
+	suffix := fmt.Sprintf("_%d", replica)
+	return utils.CutString(name, defaultMaxNameLength-len(suffix)) + suffix
+}
+
+// loadBalancerReplicas manages the ELB instances of a service in different availability zone groups,
+// they are reconciled one by one and their ingress IPs are reported together in the status of the service.
+type loadBalancerReplicas struct {
+	client instanceLister
+	// names are the names of all the possible replicas, indexed by the replica.
+	names []string
+	// deleteInstance deletes the ELB instance of the replica with its listeners and pools.
+	deleteInstance func(replica int, loadbalancer *elbmodel.LoadBalancer) error
+}
+
+func newLoadBalancerReplicas(client instanceLister, name string,
+	deleteInstance func(int, *elbmodel.LoadBalancer) error) *loadBalancerReplicas {
+	names := make([]string, 0, maxLoadBalancerReplicas)
+	for i := 0; i < maxLoadBalancerReplicas; i++ {
+		names = append(names, getReplicaLoadBalancerName(name, i))
+	}
+	return &loadBalancerReplicas{client: client, names: names, deleteInstance: deleteInstance}
+}
+
+// list returns the ELB instances of the replicas from index from to index to (exclusive), in the order of the replicas.
+func (r *loadBalancerReplicas) list(from, to int) ([]elbmodel.LoadBalancer, []int, error) {
+	if from >= to {
+		return nil, nil, nil
+	}
+	names := r.names[from:to]
+	list, err := r.client.ListInstances(&elbmodel.ListLoadBalancersRequest{Name: &names})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[string]int)
+	for i, name := range names {
+		index[name] = from + i
+	}
+	instances := make([]elbmodel.LoadBalancer, 0, len(list))
+	for _, lb := range list {
+		if _, ok := index[lb.Name]; ok {
+			instances = append(instances, lb)
+		}
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return index[instances[i].Name] < index[instances[j].Name]
+	})
+	replicas := make([]int, 0, len(instances))
+	for _, lb := range instances {
+		replicas = append(replicas, index[lb.Name])
+	}
+	return instances, replicas, nil
+}
+
+// ensure reconciles the ELB instances of the count replicas, then deletes the ones of the replicas no longer needed.
+// A failed replica does not stop reconciling the others, but the status is not returned until all of them succeed.
+func (r *loadBalancerReplicas) ensure(count int, ensure func(replica int) (*v1.LoadBalancerStatus, error)) (
+	*v1.LoadBalancerStatus, error) {
+	lbStatus := &v1.LoadBalancerStatus{}
+	errs := make([]error, 0)
+	for i := 0; i < count; i++ {
+		s, err := ensure(i)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to ensure the ELB %s: %w", r.names[i], err))
+			continue
+		}
+		lbStatus.Ingress = append(lbStatus.Ingress, s.Ingress...)
+	}
+	if len(errs) > 0 {
+		return nil, errors.NewAggregate(errs)
+	}
+
+	if err := r.deleteFrom(count); err != nil {
+		return nil, err
+	}
+	return lbStatus, nil
+}
+
+// update updates the members of the ELB instances of the count replicas, a failed replica does not stop
+// updating the others.
+func (r *loadBalancerReplicas) update(count int, update func(replica int) error) error {
+	errs := make([]error, 0)
+	for i := 0; i < count; i++ {
+		if err := update(i); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update the ELB %s: %w", r.names[i], err))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+// deleteFrom deletes the ELB instances of the replicas from index from on, the ones already deleted are tolerated.
+func (r *loadBalancerReplicas) deleteFrom(from int) error {
+	instances, replicas, err := r.list(from, len(r.names))
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for i := range instances {
+		klog.Infof("Deleting the ELB %s of replica %d", instances[i].Id, replicas[i])
+		if err := r.deleteInstance(replicas[i], &instances[i]); err != nil && !common.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete the ELB %s: %w", instances[i].Id, err))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+// withReplica returns the DedicatedLoadBalancer managing the ELB instance of the replica in the availability zones.
+func (d *DedicatedLoadBalancer) withReplica(replica int, zones []string) *DedicatedLoadBalancer {
+	return &DedicatedLoadBalancer{Basic: d.Basic, replica: replica, zones: zones}
+}
+
+func (d *DedicatedLoadBalancer) newLoadBalancerReplicas(ctx context.Context, clusterName string,
+	service *v1.Service) *loadBalancerReplicas {
+	name := d.withReplica(0, nil).GetLoadBalancerName(ctx, clusterName, service)
+	return newLoadBalancerReplicas(d.dedicatedELBClient, name, func(replica int, lb *elbmodel.LoadBalancer) error {
+		r := d.withReplica(replica, nil)
+		if err := r.deleteELBInstance(lb, service); err != nil {
+			return err
+		}
+		return r.deleteRetiredLoadBalancers(clusterName, service)
+	})
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/api/core/v1"
+)
+
+// fakeReplicaELB is the ELB instances of the replicas, keyed by the name.
+type fakeReplicaELB struct {
+	instances map[string]*elbmodel.LoadBalancer
+	// members are the nodes registered to the ELB instances, keyed by the name.
+	members map[string][]string
+	deleted []string
+	errs    map[string]error
+}
+
+func newFakeReplicaELB() *fakeReplicaELB {
+	return &fakeReplicaELB{
+		instances: make(map[string]*elbmodel.LoadBalancer),
+		members:   make(map[string][]string),
+		errs:      make(map[string]error),
+	}
+}
+
+func (f *fakeReplicaELB) ListInstances(req *elbmodel.ListLoadBalancersRequest) ([]elbmodel.LoadBalancer, error) {
+	list := make([]elbmodel.LoadBalancer, 0)
+	// the instances are not returned in the order of the names.
+	for i := len(*req.Name) - 1; i >= 0; i-- {
+		if lb, ok := f.instances[(*req.Name)[i]]; ok {
+			list = append(list, *lb)
+		}
+	}
+	return list, nil
+}
+
+// ensure creates the ELB instance of the replica if not found, and registers the nodes to it.
+func (f *fakeReplicaELB) ensure(r *loadBalancerReplicas, nodes []string) func(int) (*v1.LoadBalancerStatus, error) {
+	return func(replica int) (*v1.LoadBalancerStatus, error) {
+		name := r.names[replica]
+		if err := f.errs[name]; err != nil {
+			return nil, err
+		}
+		if _, ok := f.instances[name]; !ok {
+			f.instances[name] = &elbmodel.LoadBalancer{
+				Id:         fmt.Sprintf("elb-%d", replica),
+				Name:       name,
+				VipAddress: fmt.Sprintf("192.168.0.%d", replica+1),
+			}
+		}
+		f.members[name] = nodes
+		return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: f.instances[name].VipAddress}}}, nil
+	}
+}
+
+func (f *fakeReplicaELB) newReplicas() *loadBalancerReplicas {
+	return newLoadBalancerReplicas(f, "k8s_service_cluster_default_test",
+		func(replica int, lb *elbmodel.LoadBalancer) error {
+			if err := f.errs[lb.Name]; err != nil {
+				return err
+			}
+			f.deleted = append(f.deleted, lb.Name)
+			delete(f.instances, lb.Name)
+			delete(f.members, lb.Name)
+			return nil
+		})
+}
+
+func getIngressIPs(lbStatus *v1.LoadBalancerStatus) []string {
+	ips := make([]string, 0)
+	for _, ingress := range lbStatus.Ingress {
+		ips = append(ips, ingress.IP)
+	}
+	return ips
+}
+
+func TestParseAvailabilityZoneGroups(t *testing.T) {
+	tests := []struct {
+		name          string
+		annotation    string
+		expected      [][]string
+		expectedError bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:       "one group",
+			annotation: "az1;az2",
+			expected:   [][]string{{"az1", "az2"}},
+		},
+		{
+			name:       "multiple groups",
+			annotation: "az1;az2| az3 ;",
+			expected:   [][]string{{"az1", "az2"}, {"az3"}},
+		},
+		{
+			name:          "empty group",
+			annotation:    "az1||az2",
+			expectedError: true,
+		},
+		{
+			name:          "shared availability zone",
+			annotation:    "az1;az2|az2;az3",
+			expectedError: true,
+		},
+		{
+			name:          "too many groups",
+			annotation:    "az1|az2|az3|az4|az5|az6",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tt.annotation != "" {
+				annotations[ElbAvailabilityZoneGroups] = tt.annotation
+			}
+			groups, err := parseAvailabilityZoneGroups(newTestService(annotations))
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+			if fmt.Sprint(groups) != fmt.Sprint(tt.expected) {
+				t.Fatalf("expected: %v, got: %v", tt.expected, groups)
+			}
+		})
+	}
+}
+
+func TestValidateAvailabilityZoneGroups(t *testing.T) {
+	service := newTestService(map[string]string{ElbAvailabilityZoneGroups: "az1|az2", ElbEipID: "eip-1"})
+	if err := validateAvailabilityZoneGroups(service); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+
+	service = newTestService(map[string]string{ElbAvailabilityZoneGroups: "az1|az2"})
+	if err := validateAvailabilityZoneGroups(service); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+}
+
+func TestGetReplicaLoadBalancerName(t *testing.T) {
+	if name := getReplicaLoadBalancerName("k8s_service_test", 0); name != "k8s_service_test" {
+		t.Fatalf("expected: %s, got: %s", "k8s_service_test", name)
+	}
+	if name := getReplicaLoadBalancerName("k8s_service_test", 2); name != "k8s_service_test_2" {
+		t.Fatalf("expected: %s, got: %s", "k8s_service_test_2", name)
+	}
+
+	long := strings.Repeat("a", defaultMaxNameLength)
+	name := getReplicaLoadBalancerName(long, 1)
+	if len(name) != defaultMaxNameLength || !strings.HasSuffix(name, "_1") {
+		t.Fatalf("expected: a name of %d characters ending with _1, got: %s", defaultMaxNameLength, name)
+	}
+}
+
+func TestLoadBalancerReplicasEnsure(t *testing.T) {
+	f := newFakeReplicaELB()
+	r := f.newReplicas()
+
+	lbStatus, err := r.ensure(3, f.ensure(r, []string{"node-1", "node-2"}))
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	expected := []string{"192.168.0.1", "192.168.0.2", "192.168.0.3"}
+	if fmt.Sprint(getIngressIPs(lbStatus)) != fmt.Sprint(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, getIngressIPs(lbStatus))
+	}
+	if len(f.instances) != 3 {
+		t.Fatalf("expected: %d ELB instances, got: %d", 3, len(f.instances))
+	}
+
+	// the ELB instance of the group removed is deleted.
+	lbStatus, err = r.ensure(2, f.ensure(r, []string{"node-1", "node-2"}))
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	expected = []string{"192.168.0.1", "192.168.0.2"}
+	if fmt.Sprint(getIngressIPs(lbStatus)) != fmt.Sprint(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, getIngressIPs(lbStatus))
+	}
+	if fmt.Sprint(f.deleted) != fmt.Sprint([]string{r.names[2]}) {
+		t.Fatalf("expected: %v, got: %v", []string{r.names[2]}, f.deleted)
+	}
+}
+
+func TestLoadBalancerReplicasEnsureError(t *testing.T) {
+	f := newFakeReplicaELB()
+	r := f.newReplicas()
+	f.errs[r.names[0]] = status.Error(codes.Internal, "internal error")
+
+	if _, err := r.ensure(2, f.ensure(r, []string{"node-1"})); err == nil {
+		t.Fatalf("expected: the error of %s, got: %v", r.names[0], err)
+	}
+	// the other replicas are still reconciled.
+	if _, ok := f.instances[r.names[1]]; !ok {
+		t.Fatalf("expected: %s created", r.names[1])
+	}
+}
+
+func TestLoadBalancerReplicasUpdate(t *testing.T) {
+	f := newFakeReplicaELB()
+	r := f.newReplicas()
+	if _, err := r.ensure(3, f.ensure(r, []string{"node-1", "node-2"})); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+
+	f.errs[r.names[1]] = status.Error(codes.Internal, "internal error")
+	update := f.ensure(r, []string{"node-2", "node-3"})
+	err := r.update(3, func(replica int) error {
+		_, err := update(replica)
+		return err
+	})
+	if err == nil {
+		t.Fatalf("expected: the error of %s, got: %v", r.names[1], err)
+	}
+
+	// the members of the other replicas are still synced.
+	for _, replica := range []int{0, 2} {
+		members := f.members[r.names[replica]]
+		if fmt.Sprint(members) != fmt.Sprint([]string{"node-2", "node-3"}) {
+			t.Fatalf("expected: %v, got: %v", []string{"node-2", "node-3"}, members)
+		}
+	}
+}
+
+func TestLoadBalancerReplicasDelete(t *testing.T) {
+	f := newFakeReplicaELB()
+	r := f.newReplicas()
+	if _, err := r.ensure(3, f.ensure(r, []string{"node-1"})); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	// the ELB instance already deleted is tolerated.
+	f.errs[r.names[1]] = status.Error(codes.NotFound, "not found")
+
+	if err := r.deleteFrom(0); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	expected := []string{r.names[0], r.names[2]}
+	if fmt.Sprint(f.deleted) != fmt.Sprint(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, f.deleted)
+	}
+
+	instances, _, err := r.list(0, 2)
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if len(instances) != 1 || instances[0].Name != r.names[1] {
+		t.Fatalf("expected: only %s left, got: %v", r.names[1], instances)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"math"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxMemberWeight is the maximum weight of a member of the ELB pool.
+	maxMemberWeight = 100
+	// minMemberWeight is the minimum weight of a member, 0 would stop forwarding the requests to the member.
+	minMemberWeight = 1
+	// defaultMemberWeight is the weight of the members added without a weight, the default of the ELB.
+	defaultMemberWeight = 1
+
+	MemberWeightResourceCPU    = "cpu"
+	MemberWeightResourceMemory = "memory"
+)
+
+// getAllocatable returns the allocatable amount of the resource of the node, 0 if it is not reported.
+func getAllocatable(node *v1.Node, resource string) float64 {
+	switch resource {
+	case MemberWeightResourceCPU:
+		return float64(node.Status.Allocatable.Cpu().MilliValue())
+	case MemberWeightResourceMemory:
+		return float64(node.Status.Allocatable.Memory().Value())
+	}
+	return 0
+}
+
+// getMemberWeights returns the weights of the members by the node name, in proportion to the allocatable resource
+// of the nodes, the largest node has the maximum weight. It returns nil if the resource is empty or not supported,
+// then the members are added with the default weight of the ELB, which is equal for all the members.
+func getMemberWeights(nodes []*v1.Node, resource string) map[string]int32 {
+	if resource != MemberWeightResourceCPU && resource != MemberWeightResourceMemory {
+		if resource != "" {
+			klog.Warningf("unsupported member weight resource %q, the members have equal weights", resource)
+		}
+		return nil
+	}
+
+	largest := float64(0)
+	for _, node := range nodes {
+		largest = math.Max(largest, getAllocatable(node, resource))
+	}
+
+	weights := make(map[string]int32, len(nodes))
+	for _, node := range nodes {
+		weight := int32(minMemberWeight)
+		if largest > 0 {
+			weight = int32(math.Round(getAllocatable(node, resource) / largest * maxMemberWeight))
+		}
+		if weight < minMemberWeight {
+			weight = minMemberWeight
+		}
+		weights[node.Name] = weight
+	}
+	return weights
+}
+
+// drainCordonedNodes sets the weights of the members on the cordoned nodes to 0 if enabled, so that the ELB stops
+// forwarding the new connections to them before they are deleted, such as during the upgrades of the node pools.
+// The members on the schedulable nodes are restored to their weights, or the default weight if the weights are
+// disabled.
+func drainCordonedNodes(weights map[string]int32, nodes []*v1.Node, enabled bool) map[string]int32 {
+	if !enabled {
+		return weights
+	}
+	rst := make(map[string]int32, len(nodes))
+	for _, node := range nodes {
+		weight, ok := weights[node.Name]
+		if !ok {
+			weight = defaultMemberWeight
+		}
+		if node.Spec.Unschedulable {
+			klog.V(4).Infof("node %s is cordoned, draining its members", node.Name)
+			weight = 0
+		}
+		rst[node.Name] = weight
+	}
+	return rst
+}
+
+// getMemberWeight returns the weight of the member on the node, nil if the weights are disabled.
+func getMemberWeight(weights map[string]int32, node *v1.Node) *int32 {
+	weight, ok := weights[node.Name]
+	if !ok {
+		return nil
+	}
+	return &weight
+}
+
+// isAllocatableChanged returns true if the allocatable resource used as the member weight is changed.
+func isAllocatableChanged(oldNode, newNode *v1.Node, resource string) bool {
+	if resource == "" {
+		return false
+	}
+	return getAllocatable(oldNode, resource) != getAllocatable(newNode, resource)
+}
+
+// isCordonChanged returns true if the node is cordoned or uncordoned.
+func isCordonChanged(oldNode, newNode *v1.Node) bool {
+	return oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable
+}
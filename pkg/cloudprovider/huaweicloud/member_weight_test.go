@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newCapacityNode(name, cpu, memory string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	node.Status.Allocatable = v1.ResourceList{}
+	if cpu != "" {
+		node.Status.Allocatable[v1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		node.Status.Allocatable[v1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return node
+}
+
+func TestGetMemberWeights(t *testing.T) {
+	nodes := []*v1.Node{
+		newCapacityNode("large", "16", "32Gi"),
+		newCapacityNode("medium", "8", "64Gi"),
+		newCapacityNode("small", "3900m", "16Gi"),
+		newCapacityNode("tiny", "100m", "128Mi"),
+	}
+
+	tests := []struct {
+		name     string
+		nodes    []*v1.Node
+		resource string
+		expected map[string]int32
+	}{
+		{
+			name:     "cpu",
+			nodes:    nodes,
+			resource: MemberWeightResourceCPU,
+			expected: map[string]int32{"large": 100, "medium": 50, "small": 24, "tiny": 1},
+		},
+		{
+			name:     "memory",
+			nodes:    nodes,
+			resource: MemberWeightResourceMemory,
+			expected: map[string]int32{"large": 50, "medium": 100, "small": 25, "tiny": 1},
+		},
+		{
+			name:     "equal capacity",
+			nodes:    []*v1.Node{newCapacityNode("node-1", "4", ""), newCapacityNode("node-2", "4", "")},
+			resource: MemberWeightResourceCPU,
+			expected: map[string]int32{"node-1": 100, "node-2": 100},
+		},
+		{
+			name:     "allocatable not reported",
+			nodes:    []*v1.Node{newCapacityNode("node-1", "", ""), newCapacityNode("node-2", "2", "")},
+			resource: MemberWeightResourceCPU,
+			expected: map[string]int32{"node-1": 1, "node-2": 100},
+		},
+		{
+			name:     "disabled",
+			nodes:    nodes,
+			resource: "",
+			expected: nil,
+		},
+		{
+			name:     "unsupported resource",
+			nodes:    nodes,
+			resource: "gpu",
+			expected: nil,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := getMemberWeights(te.nodes, te.resource)
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsAllocatableChanged(t *testing.T) {
+	before := newCapacityNode("node-1", "4", "8Gi")
+
+	tests := []struct {
+		name     string
+		new      *v1.Node
+		resource string
+		expected bool
+	}{
+		{name: "cpu changed", new: newCapacityNode("node-1", "8", "8Gi"), resource: MemberWeightResourceCPU, expected: true},
+		{name: "memory changed", new: newCapacityNode("node-1", "4", "16Gi"), resource: MemberWeightResourceCPU, expected: false},
+		{name: "unchanged", new: newCapacityNode("node-1", "4000m", "8Gi"), resource: MemberWeightResourceCPU, expected: false},
+		{name: "disabled", new: newCapacityNode("node-1", "8", "16Gi"), resource: "", expected: false},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			if got := isAllocatableChanged(before, te.new, te.resource); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func newCordonedNode(node *v1.Node, unschedulable bool) *v1.Node {
+	node = node.DeepCopy()
+	node.Spec.Unschedulable = unschedulable
+	return node
+}
+
+func TestDrainCordonedNodes(t *testing.T) {
+	large := newCapacityNode("large", "16", "32Gi")
+	small := newCapacityNode("small", "8", "16Gi")
+
+	// the node pool is upgraded: the large node is cordoned, then uncordoned after the upgrade is rolled back.
+	steps := []struct {
+		name     string
+		old      *v1.Node
+		new      *v1.Node
+		resource string
+		changed  bool
+		expected map[string]int32
+	}{
+		{
+			name:     "cordon drains",
+			old:      large,
+			new:      newCordonedNode(large, true),
+			resource: MemberWeightResourceCPU,
+			changed:  true,
+			expected: map[string]int32{"large": 0, "small": 50},
+		},
+		{
+			name:     "still cordoned",
+			old:      newCordonedNode(large, true),
+			new:      newCordonedNode(large, true),
+			resource: MemberWeightResourceCPU,
+			changed:  false,
+			expected: map[string]int32{"large": 0, "small": 50},
+		},
+		{
+			name:     "uncordon restores",
+			old:      newCordonedNode(large, true),
+			new:      newCordonedNode(large, false),
+			resource: MemberWeightResourceCPU,
+			changed:  true,
+			expected: map[string]int32{"large": 100, "small": 50},
+		},
+		{
+			name:     "cordon drains with equal weights",
+			old:      large,
+			new:      newCordonedNode(large, true),
+			resource: "",
+			changed:  true,
+			expected: map[string]int32{"large": 0, "small": defaultMemberWeight},
+		},
+		{
+			name:     "uncordon restores the default weight",
+			old:      newCordonedNode(large, true),
+			new:      large,
+			resource: "",
+			changed:  true,
+			expected: map[string]int32{"large": defaultMemberWeight, "small": defaultMemberWeight},
+		},
+	}
+
+	for _, te := range steps {
+		t.Run(te.name, func(t *testing.T) {
+			if changed := isCordonChanged(te.old, te.new); changed != te.changed {
+				t.Fatalf("expected: %v, got: %v", te.changed, changed)
+			}
+			nodes := []*v1.Node{te.new, small}
+			got := drainCordonedNodes(getMemberWeights(nodes, te.resource), nodes, true)
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+
+	// the weights are unchanged if disabled.
+	nodes := []*v1.Node{newCordonedNode(large, true), small}
+	if got := drainCordonedNodes(getMemberWeights(nodes, ""), nodes, false); got != nil {
+		t.Fatalf("expected: %v, got: %v", nil, got)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper"
+)
+
+const (
+	metricsNamespace = "cloudprovider"
+	metricsSubsystem = "huaweicloud"
+
+	reconcileResultSuccess = "success"
+	reconcileResultError   = "error"
+)
+
+var (
+	managedLoadBalancersDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "managed_loadbalancers"),
+		"Number of the LoadBalancer services managed by the cloud provider.",
+		nil, nil, metrics.ALPHA, "")
+
+	pendingLoadBalancerSecondsDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "pending_loadbalancer_seconds"),
+		"Age in seconds of the oldest LoadBalancer service whose reconcile has not succeeded yet, 0 if there is none.",
+		nil, nil, metrics.ALPHA, "")
+
+	registerMetricsOnce sync.Once
+	// defaultReconcileMetrics is shared by the providers and registered with the metrics registry of the CCM.
+	defaultReconcileMetrics = newReconcileMetrics()
+)
+
+// reconcileMetrics records the outcomes of the LoadBalancer reconciles.
+type reconcileMetrics struct {
+	metrics.BaseStableCollector
+
+	mu sync.Mutex
+	// managed is the services whose load balancers are ensured, pending is the time when the services
+	// started to be reconciled and have not succeeded yet.
+	managed map[string]bool
+	pending map[string]time.Time
+	now     func() time.Time
+
+	reconcileTotal *metrics.CounterVec
+}
+
+func newReconcileMetrics() *reconcileMetrics {
+	return &reconcileMetrics{
+		managed: make(map[string]bool),
+		pending: make(map[string]time.Time),
+		now:     time.Now,
+		reconcileTotal: metrics.NewCounterVec(&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "reconcile_total",
+			Help:           "Number of the LoadBalancer reconciles by the result.",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"result"}),
+	}
+}
+
+// registerMetrics registers the metrics with the registry, the registry serves them on the /metrics of the CCM.
+func (m *reconcileMetrics) registerMetrics(registry metrics.KubeRegistry) {
+	registry.MustRegister(m.reconcileTotal)
+	registry.CustomMustRegister(m)
+}
+
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(defaultReconcileMetrics.reconcileTotal)
+		legacyregistry.CustomMustRegister(defaultReconcileMetrics)
+	})
+	wrapper.RegisterMetrics()
+}
+
+// startReconcile marks the service as pending, unless it is already pending.
+func (m *reconcileMetrics) startReconcile(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pending[key]; !ok {
+		m.pending[key] = m.now()
+	}
+}
+
+// finishReconcile records the result of ensuring or updating the load balancer of the service.
+func (m *reconcileMetrics) finishReconcile(key string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.reconcileTotal.WithLabelValues(reconcileResultError).Inc()
+		return
+	}
+	m.reconcileTotal.WithLabelValues(reconcileResultSuccess).Inc()
+	m.managed[key] = true
+	delete(m.pending, key)
+}
+
+// finishDelete records the result of deleting the load balancer of the service.
+func (m *reconcileMetrics) finishDelete(key string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.reconcileTotal.WithLabelValues(reconcileResultError).Inc()
+		return
+	}
+	m.reconcileTotal.WithLabelValues(reconcileResultSuccess).Inc()
+	delete(m.managed, key)
+	delete(m.pending, key)
+}
+
+func (m *reconcileMetrics) DescribeWithStability(ch chan<- *metrics.Desc) {
+	ch <- managedLoadBalancersDesc
+	ch <- pendingLoadBalancerSecondsDesc
+}
+
+func (m *reconcileMetrics) CollectWithStability(ch chan<- metrics.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldest := 0.0
+	now := m.now()
+	for _, started := range m.pending {
+		if age := now.Sub(started).Seconds(); age > oldest {
+			oldest = age
+		}
+	}
+	ch <- metrics.NewLazyConstMetric(managedLoadBalancersDesc, metrics.GaugeValue, float64(len(m.managed)))
+	ch <- metrics.NewLazyConstMetric(pendingLoadBalancerSecondsDesc, metrics.GaugeValue, oldest)
+}
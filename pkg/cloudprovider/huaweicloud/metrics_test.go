@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+// fakeLoadBalancer fails the reconciles of the services in failed.
+type fakeLoadBalancer struct {
+	failed map[string]bool
+}
+
+func (f *fakeLoadBalancer) result(service *v1.Service) error {
+	if f.failed[service.Name] {
+		return fmt.Errorf("failed to reconcile %s", service.Name)
+	}
+	return nil
+}
+
+func (f *fakeLoadBalancer) GetLoadBalancer(_ context.Context, _ string, _ *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	return nil, false, nil
+}
+
+func (f *fakeLoadBalancer) GetLoadBalancerName(_ context.Context, _ string, service *v1.Service) string {
+	return service.Name
+}
+
+func (f *fakeLoadBalancer) EnsureLoadBalancer(_ context.Context, _ string, service *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	if err := f.result(service); err != nil {
+		return nil, err
+	}
+	return &v1.LoadBalancerStatus{}, nil
+}
+
+func (f *fakeLoadBalancer) UpdateLoadBalancer(_ context.Context, _ string, service *v1.Service, _ []*v1.Node) error {
+	return f.result(service)
+}
+
+func (f *fakeLoadBalancer) EnsureLoadBalancerDeleted(_ context.Context, _ string, service *v1.Service) error {
+	return f.result(service)
+}
+
+func newMetricsTestService(name string) *v1.Service {
+	service := newTestService(map[string]string{ElbClass: "dedicated"})
+	service.Name = name
+	service.Spec.Type = v1.ServiceTypeLoadBalancer
+	return service
+}
+
+func TestReconcileMetrics(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	m := newReconcileMetrics()
+	m.now = func() time.Time { return now }
+	registry := metrics.NewKubeRegistry()
+	m.registerMetrics(registry)
+
+	lb := &fakeLoadBalancer{failed: map[string]bool{"broken": true}}
+	h := &CloudProvider{
+		Basic: Basic{
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: m,
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	nodes := []*v1.Node{newTestNode(nil)}
+	ctx := context.TODO()
+
+	steps := []struct {
+		name      string
+		reconcile func() error
+		advance   time.Duration
+		expected  string
+	}{
+		{
+			name: "ensure",
+			reconcile: func() error {
+				_, err := h.EnsureLoadBalancer(ctx, "kubernetes", newMetricsTestService("web"), nodes)
+				return err
+			},
+			expected: `
+cloudprovider_huaweicloud_managed_loadbalancers 1
+cloudprovider_huaweicloud_pending_loadbalancer_seconds 0
+cloudprovider_huaweicloud_reconcile_total{result="success"} 1
+`,
+		},
+		{
+			name: "ensure failed",
+			reconcile: func() error {
+				_, err := h.EnsureLoadBalancer(ctx, "kubernetes", newMetricsTestService("broken"), nodes)
+				return err
+			},
+			advance: 30 * time.Second,
+			expected: `
+cloudprovider_huaweicloud_managed_loadbalancers 1
+cloudprovider_huaweicloud_pending_loadbalancer_seconds 30
+cloudprovider_huaweicloud_reconcile_total{result="error"} 1
+cloudprovider_huaweicloud_reconcile_total{result="success"} 1
+`,
+		},
+		{
+			name: "update failed again",
+			reconcile: func() error {
+				return h.UpdateLoadBalancer(ctx, "kubernetes", newMetricsTestService("broken"), nodes)
+			},
+			advance: 30 * time.Second,
+			expected: `
+cloudprovider_huaweicloud_managed_loadbalancers 1
+cloudprovider_huaweicloud_pending_loadbalancer_seconds 60
+cloudprovider_huaweicloud_reconcile_total{result="error"} 2
+cloudprovider_huaweicloud_reconcile_total{result="success"} 1
+`,
+		},
+		{
+			name: "update another",
+			reconcile: func() error {
+				return h.UpdateLoadBalancer(ctx, "kubernetes", newMetricsTestService("api"), nodes)
+			},
+			expected: `
+cloudprovider_huaweicloud_managed_loadbalancers 2
+cloudprovider_huaweicloud_pending_loadbalancer_seconds 60
+cloudprovider_huaweicloud_reconcile_total{result="error"} 2
+cloudprovider_huaweicloud_reconcile_total{result="success"} 2
+`,
+		},
+		{
+			name: "delete",
+			reconcile: func() error {
+				return h.EnsureLoadBalancerDeleted(ctx, "kubernetes", newMetricsTestService("web"))
+			},
+			expected: `
+cloudprovider_huaweicloud_managed_loadbalancers 1
+cloudprovider_huaweicloud_pending_loadbalancer_seconds 60
+cloudprovider_huaweicloud_reconcile_total{result="error"} 2
+cloudprovider_huaweicloud_reconcile_total{result="success"} 3
+`,
+		},
+		{
+			name: "recovered",
+			reconcile: func() error {
+				lb.failed = nil
+				return h.UpdateLoadBalancer(ctx, "kubernetes", newMetricsTestService("broken"), nodes)
+			},
+			expected: `
+cloudprovider_huaweicloud_managed_loadbalancers 2
+cloudprovider_huaweicloud_pending_loadbalancer_seconds 0
+cloudprovider_huaweicloud_reconcile_total{result="error"} 2
+cloudprovider_huaweicloud_reconcile_total{result="success"} 4
+`,
+		},
+	}
+
+	for _, step := range steps {
+		_ = step.reconcile()
+		now = now.Add(step.advance)
+
+		header := `
+# HELP cloudprovider_huaweicloud_managed_loadbalancers [ALPHA] Number of the LoadBalancer services managed by the cloud provider.
+# TYPE cloudprovider_huaweicloud_managed_loadbalancers gauge
+# HELP cloudprovider_huaweicloud_pending_loadbalancer_seconds [ALPHA] Age in seconds of the oldest LoadBalancer service whose reconcile has not succeeded yet, 0 if there is none.
+# TYPE cloudprovider_huaweicloud_pending_loadbalancer_seconds gauge
+# HELP cloudprovider_huaweicloud_reconcile_total [ALPHA] Number of the LoadBalancer reconciles by the result.
+# TYPE cloudprovider_huaweicloud_reconcile_total counter
+`
+		err := testutil.GatherAndCompare(registry, strings.NewReader(header+step.expected),
+			"cloudprovider_huaweicloud_managed_loadbalancers",
+			"cloudprovider_huaweicloud_pending_loadbalancer_seconds",
+			"cloudprovider_huaweicloud_reconcile_total")
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+}
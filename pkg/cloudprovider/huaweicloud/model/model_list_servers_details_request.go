@@ -0,0 +1,30 @@
+// nolint: golint
+package model
+
+import (
+	"strings"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/utils"
+)
+
+// Request Object, the ServerId can be specified multiple times to query servers in bulk.
+type ListServersDetailsRequest struct {
+
+	// 查询返回云服务器列表当前页面的数量。每页默认值是25，最多返回1000台云服务器的信息。
+	Limit *int32 `json:"limit,omitempty"`
+
+	// 页码。 当前页面数，默认为1，取值范围大于等于0。
+	Offset *int32 `json:"offset,omitempty"`
+
+	// 云服务器ID，格式为UUID，匹配规则为精确匹配  示例: server_id={id1}&server_id={id2}
+	ServerId *[]string `json:"server_id,omitempty"`
+}
+
+func (o ListServersDetailsRequest) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "ListServersDetailsRequest struct{}"
+	}
+
+	return strings.Join([]string{"ListServersDetailsRequest", string(data)}, " ")
+}
@@ -192,7 +192,7 @@ func (nat *NATCloud) EnsureLoadBalancer(ctx context.Context, clusterName string,
 	lbServers, _ := nat.kubeClient.Services("").List(context.TODO(), metav1.ListOptions{})
 	var lbPorts []v1.ServicePort
 	for _, svc := range lbServers.Items {
-		lbType := svc.Annotations[ElbClass]
+		lbType, _ := getAnnotation(svc.Annotations, ElbClass)
 		if lbType != "dnat" || svc.Spec.LoadBalancerIP != service.Spec.LoadBalancerIP {
 			continue
 		}
@@ -431,7 +431,15 @@ func (nat *NATCloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName
  */
 func (nat *NATCloud) getNATClient() (*NATClient, error) {
 	authOpts := nat.cloudConfig.AuthOpts
-	return NewNATClient(authOpts.Cloud, authOpts.Region, authOpts.ProjectID, authOpts.AccessKey, authOpts.SecretKey), nil
+	ak, sk, _, err := authOpts.GetCredentialProvider().GetCredentials(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	projectID, err := authOpts.GetProjectID()
+	if err != nil {
+		return nil, err
+	}
+	return NewNATClient(authOpts.GetCloud(), authOpts.Region, projectID, ak, sk, authOpts.GetUserAgent()), nil
 }
 
 func (nat *NATCloud) getPods(name, namespace string) (*v1.PodList, error) {
@@ -621,9 +629,9 @@ func GetHealthCheckPort(service *v1.Service) *v1.ServicePort {
 }
 
 func GetSessionAffinityType(service *v1.Service) string {
-	return service.Annotations[ElbSessionAffinityFlag]
+	return getStringFromSvsAnnotation(service, ElbSessionAffinityFlag, "")
 }
 
 func GetSessionAffinityOptions(service *v1.Service) string {
-	return service.Annotations[ElbHealthCheckOptions]
+	return getStringFromSvsAnnotation(service, ElbHealthCheckOptions, "")
 }
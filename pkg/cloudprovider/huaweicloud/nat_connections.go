@@ -191,7 +191,7 @@ type NATClient struct {
 	throttler *Throttler
 }
 
-func NewNATClient(cloud, region, projectID, accessKey, secretKey string) *NATClient {
+func NewNATClient(cloud, region, projectID, accessKey, secretKey, userAgent string) *NATClient {
 	natEndpoint := fmt.Sprintf("https://nat.%s.%s", region, cloud)
 	vpcEndpoint := fmt.Sprintf("https://vpc.%s.%s", region, cloud)
 
@@ -202,16 +202,18 @@ func NewNATClient(cloud, region, projectID, accessKey, secretKey string) *NATCli
 		ServiceType: "ec2",
 	}
 	natClient := &ServiceClient{
-		Client:   httpClient,
-		Endpoint: natEndpoint,
-		Access:   access,
-		TenantId: projectID,
+		Client:    httpClient,
+		Endpoint:  natEndpoint,
+		Access:    access,
+		TenantId:  projectID,
+		UserAgent: userAgent,
 	}
 	vpcClient := &ServiceClient{
-		Client:   httpClient,
-		Endpoint: vpcEndpoint,
-		Access:   access,
-		TenantId: projectID,
+		Client:    httpClient,
+		Endpoint:  vpcEndpoint,
+		Access:    access,
+		TenantId:  projectID,
+		UserAgent: userAgent,
 	}
 
 	return &NATClient{
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// defaultNodeAddressCacheTTL is the maximum time that the addresses of a node are cached.
+const defaultNodeAddressCacheTTL = 5 * time.Minute
+
+// NodeAddressCache caches the addresses of the ECS instances.
+// An entry is only valid when the updated timestamp of the ECS is unchanged and the entry is not expired,
+// so that a node that gets a new IP address will not serve the stale addresses.
+type NodeAddressCache struct {
+	mutex sync.Mutex
+
+	entries map[string]*nodeAddressEntry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type nodeAddressEntry struct {
+	updated   string
+	addresses []v1.NodeAddress
+	expireAt  time.Time
+}
+
+func NewNodeAddressCache(ttl time.Duration) *NodeAddressCache {
+	return &NodeAddressCache{
+		entries: make(map[string]*nodeAddressEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached addresses of the instance if the updated timestamp is unchanged,
+// otherwise the entry is invalidated and the addresses are refetched by build.
+func (c *NodeAddressCache) Get(instanceID, updated string, build func() ([]v1.NodeAddress, error)) ([]v1.NodeAddress, error) {
+	if addresses, ok := c.get(instanceID, updated); ok {
+		klog.V(4).Infof("use the cached addresses of the instance %s: %v", instanceID, addresses)
+		return addresses, nil
+	}
+
+	addresses, err := build()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[instanceID] = &nodeAddressEntry{
+		updated:   updated,
+		addresses: addresses,
+		expireAt:  c.now().Add(c.ttl),
+	}
+	return addresses, nil
+}
+
+// Delete invalidates the cached addresses of the instance.
+func (c *NodeAddressCache) Delete(instanceID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, instanceID)
+}
+
+func (c *NodeAddressCache) get(instanceID, updated string) ([]v1.NodeAddress, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[instanceID]
+	if !ok {
+		return nil, false
+	}
+	if updated == "" || entry.updated != updated || c.now().After(entry.expireAt) {
+		klog.V(4).Infof("the cached addresses of the instance %s are stale, updated: %s => %s",
+			instanceID, entry.updated, updated)
+		delete(c.entries, instanceID)
+		return nil, false
+	}
+	return entry.addresses, true
+}
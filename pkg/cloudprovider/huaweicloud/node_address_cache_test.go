@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNodeAddressCacheRefreshOnMismatch(t *testing.T) {
+	now := time.Now()
+	c := NewNodeAddressCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	addresses := []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}
+	build := func() ([]v1.NodeAddress, error) {
+		calls++
+		return addresses, nil
+	}
+
+	tests := []struct {
+		name     string
+		updated  string
+		advance  time.Duration
+		change   []v1.NodeAddress
+		expected []v1.NodeAddress
+		calls    int
+	}{
+		{
+			name:     "first call",
+			updated:  "2023-06-01T08:00:00Z",
+			expected: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}},
+			calls:    1,
+		},
+		{
+			name:     "server not updated",
+			updated:  "2023-06-01T08:00:00Z",
+			advance:  30 * time.Second,
+			expected: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}},
+			calls:    1,
+		},
+		{
+			name:    "server updated with a new floating IP",
+			updated: "2023-06-01T08:00:40Z",
+			change: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.10.10"},
+			},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.10.10"},
+			},
+			calls: 2,
+		},
+		{
+			name:    "server not updated after refresh",
+			updated: "2023-06-01T08:00:40Z",
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.10.10"},
+			},
+			calls: 2,
+		},
+		{
+			name:     "cache expired",
+			updated:  "2023-06-01T08:00:40Z",
+			advance:  2 * time.Minute,
+			change:   []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.11"}},
+			expected: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.11"}},
+			calls:    3,
+		},
+		{
+			name:     "updated timestamp is missing",
+			updated:  "",
+			expected: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.11"}},
+			calls:    4,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			now = now.Add(testCase.advance)
+			if testCase.change != nil {
+				addresses = testCase.change
+			}
+
+			rst, err := c.Get("instance-1", testCase.updated, build)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(rst, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, rst)
+			}
+			if calls != testCase.calls {
+				t.Fatalf("expected: %v calls, got: %v", testCase.calls, calls)
+			}
+		})
+	}
+}
+
+func TestNodeAddressCacheBuildFailed(t *testing.T) {
+	c := NewNodeAddressCache(time.Minute)
+	addresses := []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}
+	if _, err := c.Get("instance-1", "2023-06-01T08:00:00Z", func() ([]v1.NodeAddress, error) {
+		return addresses, nil
+	}); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	// The stale entry must not be served when refetching fails.
+	_, err := c.Get("instance-1", "2023-06-01T08:00:40Z", func() ([]v1.NodeAddress, error) {
+		return nil, fmt.Errorf("service unavailable")
+	})
+	if err == nil {
+		t.Fatalf("expected: error, got: nil")
+	}
+
+	calls := 0
+	rst, err := c.Get("instance-1", "2023-06-01T08:00:00Z", func() ([]v1.NodeAddress, error) {
+		calls++
+		return addresses, nil
+	})
+	if err != nil || calls != 1 || !reflect.DeepEqual(rst, addresses) {
+		t.Fatalf("expected: refetched %v, got: %v, calls: %v, error: %v", addresses, rst, calls, err)
+	}
+}
+
+func TestNodeAddressCacheDelete(t *testing.T) {
+	c := NewNodeAddressCache(time.Minute)
+	calls := 0
+	build := func() ([]v1.NodeAddress, error) {
+		calls++
+		return []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get("instance-1", "2023-06-01T08:00:00Z", build); err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+	}
+	c.Delete("instance-1")
+	if _, err := c.Get("instance-1", "2023-06-01T08:00:00Z", build); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected: 2 calls, got: %v", calls)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+// getExcludeNodeLabels returns the labels to exclude the nodes from the backends of the load balancers,
+// the control plane label is included if "exclude-control-plane-nodes" is enabled.
+func getExcludeNodeLabels(opts *config.LoadBalancerOptions) []string {
+	labelKeys := make([]string, 0, 2)
+	if opts.ExcludeNodeLabel != "" {
+		labelKeys = append(labelKeys, opts.ExcludeNodeLabel)
+	}
+	if opts.ExcludeControlPlaneNodes && opts.ControlPlaneNodeLabel != "" {
+		labelKeys = append(labelKeys, opts.ControlPlaneNodeLabel)
+	}
+	return labelKeys
+}
+
+// isNodeExcluded returns true if the node has any of the labels to exclude it from the backends
+// of the load balancers, the values of the labels are ignored.
+func isNodeExcluded(node *v1.Node, labelKeys ...string) bool {
+	if node == nil {
+		return false
+	}
+	for _, labelKey := range labelKeys {
+		if _, ok := node.Labels[labelKey]; labelKey != "" && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterExcludedNodes returns the nodes that can be added to the backends of the load balancers.
+func filterExcludedNodes(nodes []*v1.Node, labelKeys ...string) []*v1.Node {
+	if len(labelKeys) == 0 {
+		return nodes
+	}
+	rst := make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if isNodeExcluded(node, labelKeys...) {
+			klog.V(4).Infof("node %s is excluded from the load balancers by the labels %q", node.Name, labelKeys)
+			continue
+		}
+		rst = append(rst, node)
+	}
+	return rst
+}
+
+// NodeExclusionListener reconciles the members of the load balancers when the exclusion label of a node changes,
+// the allocatable resource that the member weights derive from, or the node is cordoned or uncordoned if the members
+// on the cordoned nodes are drained, because the service controller only resyncs the nodes on the changes of
+// the well-known labels.
+type NodeExclusionListener struct {
+	kubeClient     *corev1.CoreV1Client
+	labelKeys      []string
+	weightResource string
+	drainCordoned  bool
+
+	stopChannel chan struct{}
+}
+
+func (n *NodeExclusionListener) startNodeExclusionListener(handle func(*v1.Service, bool)) {
+	if len(n.labelKeys) == 0 && n.weightResource == "" && !n.drainCordoned {
+		klog.Infof(`no node exclusion labels, "member-weight-resource" is empty and "drain-cordoned-nodes" ` +
+			`is disabled, no need to watch the nodes`)
+		return
+	}
+
+	klog.Info("starting NodeExclusionListener")
+	for {
+		nodeInformer, err := n.createNodeInformer(handle)
+		if err != nil {
+			klog.Errorf("failed to watch kubernetes cluster node list, starting NodeExclusionListener failed: %s", err)
+			continue
+		}
+
+		go nodeInformer.Run(n.stopChannel)
+		break
+	}
+}
+
+func (n *NodeExclusionListener) createNodeInformer(handle func(*v1.Service, bool)) (cache.SharedIndexInformer, error) {
+	nodeList, err := n.kubeClient.Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("failed to query a list of node, try again later, error: %s", err)
+		time.Sleep(5 * time.Second)
+		return nil, err
+	}
+	nodeInformer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if options.ResourceVersion == "" || options.ResourceVersion == "0" {
+					options.ResourceVersion = nodeList.ResourceVersion
+				}
+				return n.kubeClient.Nodes().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if options.ResourceVersion == "" || options.ResourceVersion == "0" {
+					options.ResourceVersion = nodeList.ResourceVersion
+				}
+				return n.kubeClient.Nodes().Watch(context.TODO(), options)
+			},
+		},
+		&v1.Node{},
+		0,
+		cache.Indexers{},
+	)
+
+	_, err = nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok1 := oldObj.(*v1.Node)
+			newNode, ok2 := newObj.(*v1.Node)
+			if !ok1 || !ok2 {
+				return
+			}
+			if isNodeExcluded(oldNode, n.labelKeys...) != isNodeExcluded(newNode, n.labelKeys...) {
+				klog.Infof("detected that the exclusion label of node %s has changed, excluded: %v",
+					newNode.Name, isNodeExcluded(newNode, n.labelKeys...))
+				n.reconcileServices(handle)
+				return
+			}
+			if isAllocatableChanged(oldNode, newNode, n.weightResource) {
+				klog.Infof("detected that the allocatable %s of node %s has changed, updating the member weights",
+					n.weightResource, newNode.Name)
+				n.reconcileServices(handle)
+				return
+			}
+			if n.drainCordoned && isCordonChanged(oldNode, newNode) {
+				klog.Infof("detected that node %s is cordoned or uncordoned, updating the member weights, "+
+					"unschedulable: %v", newNode.Name, newNode.Spec.Unschedulable)
+				n.reconcileServices(handle)
+			}
+		},
+	})
+	if err != nil {
+		klog.Errorf("failed to start nodeEventHandler, try again later, error: %s", err)
+		time.Sleep(5 * time.Second)
+		return nil, err
+	}
+	return nodeInformer, nil
+}
+
+// reconcileServices updates the members of all the LoadBalancer services.
+func (n *NodeExclusionListener) reconcileServices(handle func(*v1.Service, bool)) {
+	services, err := n.kubeClient.Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("failed to query a list of service, error: %s", err)
+		return
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+			continue
+		}
+		handle(svc, false)
+	}
+}
+
+func (n *NodeExclusionListener) stopNodeExclusionListener() {
+	klog.Warningf("Stop listening to node exclusion")
+	n.stopChannel <- struct{}{}
+}
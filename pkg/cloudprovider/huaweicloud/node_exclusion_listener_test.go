@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+func newLabeledNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func nodeNames(nodes []*v1.Node) []string {
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestFilterExcludedNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		newLabeledNode("worker-1", nil),
+		newLabeledNode("ingress-1", map[string]string{config.DefaultExcludeNodeLabel: ""}),
+		newLabeledNode("worker-2", map[string]string{"example.com/ingress-only": "true"}),
+		newLabeledNode("ingress-2", map[string]string{config.DefaultExcludeNodeLabel: "true"}),
+	}
+
+	tests := []struct {
+		name     string
+		labelKey string
+		expected []string
+	}{
+		{
+			name:     "default label",
+			labelKey: config.DefaultExcludeNodeLabel,
+			expected: []string{"worker-1", "worker-2"},
+		},
+		{
+			name:     "custom label",
+			labelKey: "example.com/ingress-only",
+			expected: []string{"worker-1", "ingress-1", "ingress-2"},
+		},
+		{
+			name:     "disabled",
+			labelKey: "",
+			expected: []string{"worker-1", "ingress-1", "worker-2", "ingress-2"},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := nodeNames(filterExcludedNodes(nodes, te.labelKey))
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterExcludedNodesLabelChanged(t *testing.T) {
+	labelKey := config.DefaultExcludeNodeLabel
+	before := newLabeledNode("node-1", nil)
+	excluded := newLabeledNode("node-1", map[string]string{labelKey: ""})
+	unexcluded := newLabeledNode("node-1", map[string]string{"kubernetes.io/os": "linux"})
+
+	steps := []struct {
+		name     string
+		old      *v1.Node
+		new      *v1.Node
+		changed  bool
+		expected []string
+	}{
+		{name: "label added", old: before, new: excluded, changed: true, expected: []string{}},
+		{name: "other labels updated", old: excluded, new: excluded, changed: false, expected: []string{}},
+		{name: "label removed", old: excluded, new: unexcluded, changed: true, expected: []string{"node-1"}},
+	}
+
+	for _, te := range steps {
+		t.Run(te.name, func(t *testing.T) {
+			changed := isNodeExcluded(te.old, labelKey) != isNodeExcluded(te.new, labelKey)
+			if changed != te.changed {
+				t.Fatalf("expected: %v, got: %v", te.changed, changed)
+			}
+			got := nodeNames(filterExcludedNodes([]*v1.Node{te.new}, labelKey))
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilterControlPlaneNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		newLabeledNode("master-1", map[string]string{config.DefaultControlPlaneNodeLabel: ""}),
+		newLabeledNode("worker-1", map[string]string{"node-role.kubernetes.io/worker": ""}),
+		newLabeledNode("master-2", map[string]string{"node-role.kubernetes.io/master": ""}),
+		newLabeledNode("ingress-1", map[string]string{config.DefaultExcludeNodeLabel: ""}),
+		newLabeledNode("worker-2", nil),
+	}
+
+	tests := []struct {
+		name     string
+		opts     *config.LoadBalancerOptions
+		expected []string
+	}{
+		{
+			name: "control plane excluded",
+			opts: &config.LoadBalancerOptions{
+				ExcludeNodeLabel:         config.DefaultExcludeNodeLabel,
+				ExcludeControlPlaneNodes: true,
+				ControlPlaneNodeLabel:    config.DefaultControlPlaneNodeLabel,
+			},
+			expected: []string{"worker-1", "master-2", "worker-2"},
+		},
+		{
+			name: "legacy label",
+			opts: &config.LoadBalancerOptions{
+				ExcludeControlPlaneNodes: true,
+				ControlPlaneNodeLabel:    "node-role.kubernetes.io/master",
+			},
+			expected: []string{"master-1", "worker-1", "ingress-1", "worker-2"},
+		},
+		{
+			name: "disabled",
+			opts: &config.LoadBalancerOptions{
+				ExcludeNodeLabel:      config.DefaultExcludeNodeLabel,
+				ControlPlaneNodeLabel: config.DefaultControlPlaneNodeLabel,
+			},
+			expected: []string{"master-1", "worker-1", "master-2", "worker-2"},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := nodeNames(filterExcludedNodes(nodes, getExcludeNodeLabels(te.opts)...))
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// nodeProviderIDIndex is the name of the index of the node informer by the provider IDs of the nodes.
+const nodeProviderIDIndex = "providerID"
+
+// NodeIndexer looks up the nodes by their provider IDs from the cache of the node informer, instead of listing
+// all the nodes from the API server for each lookup. It is empty until the informer is set by SetInformers.
+type NodeIndexer struct {
+	mutex sync.RWMutex
+
+	indexer cache.Indexer
+}
+
+// indexNodeByProviderID indexes the nodes that have got their provider IDs.
+func indexNodeByProviderID(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok || node.Spec.ProviderID == "" {
+		return nil, nil
+	}
+	return []string{node.Spec.ProviderID}, nil
+}
+
+// SetIndexer sets the indexer of the node informer, whose nodes are indexed by nodeProviderIDIndex.
+func (n *NodeIndexer) SetIndexer(indexer cache.Indexer) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.indexer = indexer
+}
+
+// GetByProviderID returns the node with the provider ID, ok is false if the indexer is not set.
+func (n *NodeIndexer) GetByProviderID(providerID string) (node *v1.Node, ok bool) {
+	if n == nil {
+		return nil, false
+	}
+	n.mutex.RLock()
+	indexer := n.indexer
+	n.mutex.RUnlock()
+	if indexer == nil {
+		return nil, false
+	}
+
+	objs, err := indexer.ByIndex(nodeProviderIDIndex, providerID)
+	if err != nil {
+		klog.V(4).Infof("failed to look up the node of %s from the informer: %s", providerID, err)
+		return nil, true
+	}
+	for _, obj := range objs {
+		if node, ok := obj.(*v1.Node); ok {
+			return node, true
+		}
+	}
+	return nil, true
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// defaultNodeNameCacheTTL is the maximum time that the instance ID of a node name is cached.
+// It is kept short, so that an instance recreated with the same name is picked up soon.
+const defaultNodeNameCacheTTL = 30 * time.Second
+
+// NodeNameCache caches the instance IDs of the node names, so that the repeated lookups of a node
+// that has not got its provider ID yet, such as during the node bootstrap, do not list the ECS each time.
+// An entry is invalidated when it expires, when the provider ID of the node is set, when the node is deleted
+// or when the instance is not found.
+type NodeNameCache struct {
+	mutex sync.Mutex
+
+	entries map[string]*nodeNameEntry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type nodeNameEntry struct {
+	instanceID string
+	expireAt   time.Time
+}
+
+func NewNodeNameCache(ttl time.Duration) *NodeNameCache {
+	return &NodeNameCache{
+		entries: make(map[string]*nodeNameEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached instance ID of the node name if it is not expired, otherwise it is looked up by lookup.
+// Only the instance IDs found are cached, the nodes whose instances are not found yet are looked up each time.
+func (c *NodeNameCache) Get(name string, lookup func() (string, error)) (string, error) {
+	if instanceID, ok := c.get(name); ok {
+		klog.V(4).Infof("use the cached instance ID of the node %s: %s", name, instanceID)
+		return instanceID, nil
+	}
+
+	instanceID, err := lookup()
+	if err != nil || instanceID == "" {
+		return instanceID, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[name] = &nodeNameEntry{
+		instanceID: instanceID,
+		expireAt:   c.now().Add(c.ttl),
+	}
+	return instanceID, nil
+}
+
+// Delete invalidates the cached instance ID of the node name.
+func (c *NodeNameCache) Delete(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, name)
+}
+
+// DeleteInstance invalidates the node names cached with the instance ID.
+func (c *NodeNameCache) DeleteInstance(instanceID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for name, entry := range c.entries {
+		if entry.instanceID == instanceID {
+			delete(c.entries, name)
+		}
+	}
+}
+
+func (c *NodeNameCache) get(name string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok {
+		return "", false
+	}
+	if c.now().After(entry.expireAt) {
+		delete(c.entries, name)
+		return "", false
+	}
+	return entry.instanceID, true
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"testing"
+	"time"
+
+	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeNameCacheBootstrap(t *testing.T) {
+	now := time.Now()
+	c := NewNodeNameCache(30 * time.Second)
+	c.now = func() time.Time { return now }
+	servers := &fakeServerGetter{}
+
+	// the instance is not found until it is created, the lookups are not cached.
+	for n := 1; n <= 2; n++ {
+		if id, err := lookupInstanceID(servers, c, "node-1", nil); err != nil || id != "" || servers.calls != n {
+			t.Fatalf("expected: no instance after %d calls, got: %v, %v, %d calls", n, id, err, servers.calls)
+		}
+	}
+
+	// the repeated lookups during the bootstrap reuse the instance found.
+	servers.servers = []ecsmodel.ServerDetail{{Id: "instance-1", Name: "node-1"}}
+	for n := 0; n < 5; n++ {
+		now = now.Add(5 * time.Second)
+		if id, err := lookupInstanceID(servers, c, "node-1", nil); err != nil || id != "instance-1" {
+			t.Fatalf("expected: %v, got: %v, %v", "instance-1", id, err)
+		}
+	}
+	if servers.calls != 3 {
+		t.Fatalf("expected: %d calls, got: %d", 3, servers.calls)
+	}
+
+	// the entry expires, so that an instance recreated with the same name is not masked.
+	servers.servers = []ecsmodel.ServerDetail{{Id: "instance-2", Name: "node-1"}}
+	now = now.Add(15 * time.Second)
+	if id, _ := lookupInstanceID(servers, c, "node-1", nil); id != "instance-2" || servers.calls != 4 {
+		t.Fatalf("expected: %v after expiring, got: %v, %d calls", "instance-2", id, servers.calls)
+	}
+
+	// the entry is purged when the instance is not found.
+	servers.servers = []ecsmodel.ServerDetail{{Id: "instance-3", Name: "node-1"}}
+	(&Basic{nameCache: c}).InvalidateInstance("huaweicloud://instance-2")
+	if id, _ := lookupInstanceID(servers, c, "node-1", nil); id != "instance-3" || servers.calls != 5 {
+		t.Fatalf("expected: %v after invalidating, got: %v, %d calls", "instance-3", id, servers.calls)
+	}
+}
+
+func TestNodeNameCacheInvalidatedByNode(t *testing.T) {
+	c := NewNodeNameCache(time.Minute)
+	h := &CloudProvider{Basic: Basic{nameCache: c}}
+	servers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{{Id: "instance-1", Name: "node-1"}}}
+	if _, err := lookupInstanceID(servers, c, "node-1", nil); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	registered := node.DeepCopy()
+	registered.Spec.ProviderID = "huaweicloud://instance-1"
+
+	tests := []struct {
+		name    string
+		event   func()
+		trigger bool
+	}{
+		{name: "provider ID unchanged", event: func() { h.onNodeUpdated(node, node) }, trigger: false},
+		{name: "provider ID set", event: func() { h.onNodeUpdated(node, registered) }, trigger: true},
+		{name: "node deleted", event: func() { h.onNodeDeleted(node) }, trigger: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := lookupInstanceID(servers, c, "node-1", nil); err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			calls := servers.calls
+			tt.event()
+			if _, err := lookupInstanceID(servers, c, "node-1", nil); err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if (servers.calls > calls) != tt.trigger {
+				t.Fatalf("expected lookup: %v, got: %d => %d calls", tt.trigger, calls, servers.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+const (
+	// maxNodePoolWeight is the maximum weight of a pool in the forwarding policy.
+	maxNodePoolWeight = 100
+
+	l7PolicyActionRedirectToPool = "REDIRECT_TO_POOL"
+)
+
+// nodePool is a group of the nodes with the same value of the node pool label.
+// The value is empty for the nodes without the label, they are the members of the default pool of the listener.
+type nodePool struct {
+	value string
+	name  string
+	nodes []*v1.Node
+}
+
+// planNodePools groups the nodes by the value of the label, the groups are sorted by the value,
+// so the group of the nodes without the label comes first.
+// All the nodes are in one group of the default pool if the label key is empty.
+func planNodePools(listenerName string, nodes []*v1.Node, labelKey string) []nodePool {
+	groups := make(map[string][]*v1.Node)
+	for _, node := range nodes {
+		value := ""
+		if labelKey != "" {
+			value = node.Labels[labelKey]
+		}
+		groups[value] = append(groups[value], node)
+	}
+
+	pools := make([]nodePool, 0, len(groups))
+	for value, members := range groups {
+		pools = append(pools, nodePool{
+			value: value,
+			name:  getNodePoolName(listenerName, value),
+			nodes: members,
+		})
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].value < pools[j].value
+	})
+	return pools
+}
+
+// getNodePoolName returns the name of the pool, which is the same as the default pool for the nodes without the label.
+func getNodePoolName(listenerName, value string) string {
+	if value == "" {
+		return fmt.Sprintf("pl_%s", listenerName)
+	}
+	return utils.CutString(fmt.Sprintf("pl_%s_%s", listenerName, value), defaultMaxNameLength)
+}
+
+func getNodePoolPolicyName(listenerName string) string {
+	return utils.CutString(fmt.Sprintf("np_%s", listenerName), defaultMaxNameLength)
+}
+
+// getNodePoolWeight returns the weight of the pool in the forwarding policy,
+// the requests are distributed to the pools in proportion to the number of the nodes.
+func getNodePoolWeight(pool nodePool) int32 {
+	if len(pool.nodes) > maxNodePoolWeight {
+		return maxNodePoolWeight
+	}
+	return int32(len(pool.nodes))
+}
+
+// isNodePoolSupported returns true if the listener can forward the requests to multiple pools.
+func isNodePoolSupported(protocol string) bool {
+	return protocol == ProtocolHTTP || protocol == ProtocolHTTPS || protocol == ProtocolTerminatedHTTPS
+}
+
+// getDefaultPoolNodes returns the nodes without the label, which are the members of the default pool.
+func getDefaultPoolNodes(pools []nodePool) []*v1.Node {
+	if len(pools) > 0 && pools[0].value == "" {
+		return pools[0].nodes
+	}
+	return []*v1.Node{}
+}
+
+// getNodePoolLabel returns the node pool label for the listener, empty if the members are not split.
+func (d *DedicatedLoadBalancer) getNodePoolLabel(listener *elbmodel.Listener) string {
+	if !isNodePoolSupported(listener.Protocol) {
+		if d.loadbalancerOpts.NodePoolLabel != "" {
+			klog.V(4).Infof("Listener %s is %s, node pools are only supported by HTTP/HTTPS listeners",
+				listener.Id, listener.Protocol)
+		}
+		return ""
+	}
+	return d.loadbalancerOpts.NodePoolLabel
+}
+
+// planListenerNodePools splits the nodes into the pools of the listener by the node pool label, and returns the pools
+// with the nodes of the default pool. All the nodes are in the default pool if the listener does not support
+// multiple pools.
+func (d *DedicatedLoadBalancer) planListenerNodePools(listener *elbmodel.Listener, nodes []*v1.Node) (
+	[]nodePool, []*v1.Node) {
+	if !isNodePoolSupported(listener.Protocol) {
+		return nil, nodes
+	}
+	pools := planNodePools(listener.Name, nodes, d.getNodePoolLabel(listener))
+	return pools, getDefaultPoolNodes(pools)
+}
+
+// ensureNodePools creates a pool for each group of the labeled nodes, and forwards the requests of the listener
+// to the pools by a policy. The pools and the policy are deleted if the nodes are not split.
+func (d *DedicatedLoadBalancer) ensureNodePools(loadbalancer *elbmodel.LoadBalancer, listener *elbmodel.Listener,
+	defaultPool *elbmodel.Pool, service *v1.Service, port v1.ServicePort, pools []nodePool) error {
+	loadbalancerIDs := []string{loadbalancer.Id}
+	existing, err := d.dedicatedELBClient.ListPools(&elbmodel.ListPoolsRequest{
+		LoadbalancerId: &loadbalancerIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	redirects := make([]elbmodel.CreateRedirectPoolsConfig, 0, len(pools))
+	keep := make(map[string]bool)
+	for _, np := range pools {
+		if np.value == "" {
+			redirects = append(redirects, elbmodel.CreateRedirectPoolsConfig{
+				PoolId: defaultPool.Id,
+				Weight: getNodePoolWeight(np),
+			})
+			continue
+		}
+
+		pool := findPoolByName(existing, np.name)
+		if pool == nil {
+			klog.Infof("Creating pool %s for the nodes with label value %s", np.name, np.value)
+			createOpt, err := d.newCreatePoolOption(listener, service, np.name)
+			if err != nil {
+				return err
+			}
+			// The pool is not the default pool of the listener, it is associated by the policy.
+			createOpt.LoadbalancerId = &loadbalancer.Id
+			if pool, err = d.dedicatedELBClient.CreatePool(createOpt); err != nil {
+				return err
+			}
+		}
+		keep[pool.Id] = true
+
+		if err = d.addOrRemoveMembers(loadbalancer, service, pool, port, np.nodes); err != nil {
+			return err
+		}
+		if err = d.ensureHealthCheck(loadbalancer.Id, pool, port, service, np.nodes[0]); err != nil {
+			return err
+		}
+		redirects = append(redirects, elbmodel.CreateRedirectPoolsConfig{
+			PoolId: pool.Id,
+			Weight: getNodePoolWeight(np),
+		})
+	}
+
+	if len(keep) == 0 {
+		redirects = nil
+	}
+	if err = d.ensureNodePoolPolicy(listener, redirects); err != nil {
+		return err
+	}
+	return d.deleteNodePools(listener, existing, keep)
+}
+
+// ensureNodePoolPolicy creates or updates the policy forwarding all the requests of the listener to the pools,
+// the policy is deleted if redirects is empty.
+func (d *DedicatedLoadBalancer) ensureNodePoolPolicy(listener *elbmodel.Listener,
+	redirects []elbmodel.CreateRedirectPoolsConfig) error {
+	name := getNodePoolPolicyName(listener.Name)
+	policies, err := d.dedicatedELBClient.ListL7Policies(&elbmodel.ListL7PoliciesRequest{
+		ListenerId: &[]string{listener.Id},
+		Name:       &[]string{name},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(redirects) == 0 {
+		for _, p := range policies {
+			klog.Infof("Deleting policy %s of listener %s, the nodes are not split into pools", p.Id, listener.Id)
+			if err = d.dedicatedELBClient.DeleteL7Policy(p.Id); err != nil && !common.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The policy forwarding to multiple pools requires the advanced forwarding of the listener.
+	if !listener.EnhanceL7policyEnable {
+		enable := true
+		err = d.dedicatedELBClient.UpdateListener(listener.Id, &elbmodel.UpdateListenerOption{
+			EnhanceL7policyEnable: &enable,
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to enable the advanced forwarding of listener %s: %v",
+				listener.Id, err)
+		}
+		listener.EnhanceL7policyEnable = true
+	}
+
+	if len(policies) > 0 {
+		return d.dedicatedELBClient.UpdateL7Policy(policies[0].Id, &elbmodel.UpdateL7PolicyOption{
+			RedirectPoolsConfig: &redirects,
+		})
+	}
+
+	klog.Infof("Creating policy %s to forward the requests of listener %s to the node pools", name, listener.Id)
+	_, err = d.dedicatedELBClient.CreateL7Policy(&elbmodel.CreateL7PolicyOption{
+		Name:                &name,
+		ListenerId:          listener.Id,
+		Action:              l7PolicyActionRedirectToPool,
+		RedirectPoolsConfig: &redirects,
+		Rules: &[]elbmodel.CreateL7PolicyRuleOption{
+			{Type: "PATH", CompareType: "STARTS_WITH", Value: "/"},
+		},
+	})
+	return err
+}
+
+// deleteNodePools deletes the node pools of the listener that are not in keep.
+func (d *DedicatedLoadBalancer) deleteNodePools(listener *elbmodel.Listener, pools []elbmodel.Pool,
+	keep map[string]bool) error {
+	prefix := getNodePoolName(listener.Name, "") + "_"
+	errs := make([]error, 0)
+	for _, pool := range pools {
+		if keep[pool.Id] || !strings.HasPrefix(pool.Name, prefix) {
+			continue
+		}
+		klog.Infof("Deleting pool %s, there are no nodes with its label value", pool.Id)
+		pool := pool
+		errs = append(errs, d.deletePool(&pool)...)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to delete node pools of listener %s: %s", listener.Id, errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// deleteListenerNodePools deletes the policy and the node pools of the listener before the listener is deleted.
+func (d *DedicatedLoadBalancer) deleteListenerNodePools(elbID string, listener *elbmodel.Listener) error {
+	if !isNodePoolSupported(listener.Protocol) {
+		return nil
+	}
+	if err := d.ensureNodePoolPolicy(listener, nil); err != nil {
+		return err
+	}
+
+	loadbalancerIDs := []string{elbID}
+	pools, err := d.dedicatedELBClient.ListPools(&elbmodel.ListPoolsRequest{
+		LoadbalancerId: &loadbalancerIDs,
+	})
+	if err != nil {
+		return err
+	}
+	return d.deleteNodePools(listener, pools, nil)
+}
+
+func findPoolByName(pools []elbmodel.Pool, name string) *elbmodel.Pool {
+	for _, pool := range pools {
+		if pool.Name == name {
+			return &pool
+		}
+	}
+	return nil
+}
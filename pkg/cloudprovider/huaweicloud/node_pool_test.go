@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"reflect"
+	"testing"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+func TestPlanNodePools(t *testing.T) {
+	labelKey := "example.com/node-pool"
+	nodes := []*v1.Node{
+		newLabeledNode("gpu-1", map[string]string{labelKey: "gpu"}),
+		newLabeledNode("worker-1", nil),
+		newLabeledNode("cpu-1", map[string]string{labelKey: "cpu"}),
+		newLabeledNode("gpu-2", map[string]string{labelKey: "gpu"}),
+		newLabeledNode("worker-2", map[string]string{"kubernetes.io/os": "linux"}),
+	}
+
+	type expectedPool struct {
+		value string
+		name  string
+		nodes []string
+	}
+
+	tests := []struct {
+		name     string
+		nodes    []*v1.Node
+		labelKey string
+		expected []expectedPool
+		defaults []string
+	}{
+		{
+			name:     "label not configured",
+			nodes:    nodes,
+			labelKey: "",
+			expected: []expectedPool{
+				{value: "", name: "pl_web_HTTP_80", nodes: []string{"gpu-1", "worker-1", "cpu-1", "gpu-2", "worker-2"}},
+			},
+			defaults: []string{"gpu-1", "worker-1", "cpu-1", "gpu-2", "worker-2"},
+		},
+		{
+			name:     "split by label",
+			nodes:    nodes,
+			labelKey: labelKey,
+			expected: []expectedPool{
+				{value: "", name: "pl_web_HTTP_80", nodes: []string{"worker-1", "worker-2"}},
+				{value: "cpu", name: "pl_web_HTTP_80_cpu", nodes: []string{"cpu-1"}},
+				{value: "gpu", name: "pl_web_HTTP_80_gpu", nodes: []string{"gpu-1", "gpu-2"}},
+			},
+			defaults: []string{"worker-1", "worker-2"},
+		},
+		{
+			name:     "all nodes labeled",
+			nodes:    []*v1.Node{nodes[0], nodes[2]},
+			labelKey: labelKey,
+			expected: []expectedPool{
+				{value: "cpu", name: "pl_web_HTTP_80_cpu", nodes: []string{"cpu-1"}},
+				{value: "gpu", name: "pl_web_HTTP_80_gpu", nodes: []string{"gpu-1"}},
+			},
+			defaults: []string{},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			pools := planNodePools("web_HTTP_80", te.nodes, te.labelKey)
+			got := make([]expectedPool, 0, len(pools))
+			for _, p := range pools {
+				got = append(got, expectedPool{value: p.value, name: p.name, nodes: nodeNames(p.nodes)})
+			}
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+
+			defaults := nodeNames(getDefaultPoolNodes(pools))
+			if !reflect.DeepEqual(defaults, te.defaults) {
+				t.Fatalf("expected: %v, got: %v", te.defaults, defaults)
+			}
+		})
+	}
+}
+
+func TestGetNodePoolWeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		expected int32
+	}{
+		{name: "one node", count: 1, expected: 1},
+		{name: "many nodes", count: 30, expected: 30},
+		{name: "capped", count: 150, expected: maxNodePoolWeight},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := getNodePoolWeight(nodePool{nodes: make([]*v1.Node, te.count)})
+			if got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestPlanListenerNodePools(t *testing.T) {
+	labelKey := "example.com/node-pool"
+	gpu := newLabeledNode("gpu-1", map[string]string{labelKey: "gpu"})
+	worker := newLabeledNode("worker-1", nil)
+	nodes := []*v1.Node{gpu, worker}
+	d := &DedicatedLoadBalancer{Basic: Basic{loadbalancerOpts: &config.LoadBalancerOptions{NodePoolLabel: labelKey}}}
+
+	tests := []struct {
+		name     string
+		protocol string
+		pools    int
+		expected []*v1.Node
+	}{
+		{name: "HTTP listener", protocol: ProtocolHTTP, pools: 2, expected: []*v1.Node{worker}},
+		{name: "TCP listener", protocol: ProtocolTCP, pools: 0, expected: nodes},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			pools, members := d.planListenerNodePools(&elbmodel.Listener{Name: "test_80", Protocol: te.protocol}, nodes)
+			if len(pools) != te.pools {
+				t.Fatalf("expected: %v, got: %v", te.pools, len(pools))
+			}
+			if !reflect.DeepEqual(members, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, members)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	eipmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+const (
+	OwnershipTagCluster    = "kubernetes-cluster"
+	OwnershipTagServiceUID = "kubernetes-service-uid"
+
+	// maxTagValueLength is the maximum length of the tag values accepted by both ELB and EIP.
+	maxTagValueLength = 43
+)
+
+// resourceTagger reads and adds the tags of the ELB or EIP instances.
+type resourceTagger interface {
+	ListTags(id string) (map[string]string, error)
+	AddTags(id string, tags map[string]string) error
+}
+
+// eipTagger looks up the EIP bound to the VIP port of the ELB and tags it.
+type eipTagger interface {
+	resourceTagger
+	List(req *eipmodel.ListPublicipsRequest) ([]eipmodel.PublicipShowResp, error)
+}
+
+// getOwnershipTags returns the tags that identify the ELB and EIP created for the service.
+func getOwnershipTags(clusterName string, service *v1.Service) map[string]string {
+	return map[string]string{
+		OwnershipTagCluster:    utils.CutString(clusterName, maxTagValueLength),
+		OwnershipTagServiceUID: string(service.UID),
+	}
+}
+
+// repairOwnershipTags adds the expected tags which are missing or changed on the resource, the other tags are
+// left untouched. Nothing is written if all the tags are present, so it is safe to run repeatedly.
+func repairOwnershipTags(tagger resourceTagger, id string, expected map[string]string) (bool, error) {
+	tags, err := tagger.ListTags(id)
+	if err != nil {
+		return false, err
+	}
+
+	missing := make(map[string]string)
+	for k, v := range expected {
+		if current, ok := tags[k]; !ok || current != v {
+			missing[k] = v
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	klog.Infof("repairing the ownership tags of %s: %v", id, missing)
+	return true, tagger.AddTags(id, missing)
+}
+
+// repairLoadBalancerOwnershipTags repairs the tags of the ELB and the EIP bound to its VIP port.
+// The ELB is skipped if it is specified by the user, so is the EIP.
+func repairLoadBalancerOwnershipTags(elbTagger resourceTagger, eipClient eipTagger, service *v1.Service,
+	elbID, vipPortID string, expected map[string]string) error {
+	if getStringFromSvsAnnotation(service, ElbID, "") == "" {
+		if _, err := repairOwnershipTags(elbTagger, elbID, expected); err != nil {
+			return fmt.Errorf("failed to repair the tags of ELB %s: %s", elbID, err)
+		}
+	}
+
+	if getStringFromSvsAnnotation(service, ElbEipID, "") != "" || vipPortID == "" {
+		return nil
+	}
+	ips, err := eipClient.List(&eipmodel.ListPublicipsRequest{PortId: &[]string{vipPortID}})
+	if err != nil {
+		return fmt.Errorf("error querying EIPs base on PortId (%s): %s", vipPortID, err)
+	}
+	for _, ip := range ips {
+		if ip.Id == nil {
+			continue
+		}
+		if _, err := repairOwnershipTags(eipClient, *ip.Id, expected); err != nil {
+			return fmt.Errorf("failed to repair the tags of EIP %s: %s", *ip.Id, err)
+		}
+	}
+	return nil
+}
+
+// repairServiceOwnershipTags repairs the ownership tags of the ELB and EIP of a shared or dedicated service.
+func (h *CloudProvider) repairServiceOwnershipTags(ctx context.Context, clusterName string, service *v1.Service) error {
+	version, err := getLoadBalancerVersion(service)
+	if err != nil {
+		return nil
+	}
+
+	var elbID, vipPortID string
+	switch provider := h.providers[version].(type) {
+	case *SharedLoadBalancer:
+		loadbalancer, err := provider.getLoadBalancerInstance(ctx, clusterName, service)
+		if err != nil {
+			return err
+		}
+		elbID, vipPortID = loadbalancer.Id, loadbalancer.VipPortId
+	case *DedicatedLoadBalancer:
+		loadbalancer, err := provider.getLoadBalancerInstance(ctx, clusterName, service)
+		if err != nil {
+			return err
+		}
+		elbID, vipPortID = loadbalancer.Id, loadbalancer.VipPortId
+	default:
+		return nil
+	}
+
+	// The tags of both shared and dedicated ELBs are managed by the v2 API.
+	return repairLoadBalancerOwnershipTags(h.sharedELBClient, h.eipClient, service, elbID, vipPortID,
+		getOwnershipTags(clusterName, service))
+}
+
+// RepairOwnershipTags re-applies the missing ownership tags to the ELBs and EIPs of the LoadBalancer services.
+// The services whose ELB is not found are skipped.
+func (h *CloudProvider) RepairOwnershipTags(ctx context.Context, clusterName string, services []*v1.Service) error {
+	errs := make([]error, 0)
+	for _, service := range services {
+		if !h.isSupportedSvc(service) {
+			continue
+		}
+		if err := h.repairServiceOwnershipTags(ctx, clusterName, service); err != nil && !common.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("service %s/%s: %s", service.Namespace, service.Name, err))
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+// runOwnershipTagRepair repairs the ownership tags periodically until the context is done.
+func (h *CloudProvider) runOwnershipTagRepair(ctx context.Context, clusterName string, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		list, err := h.kubeClient.Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			klog.Errorf("failed to list services to repair the ownership tags: %s", err)
+			return
+		}
+		services := make([]*v1.Service, 0, len(list.Items))
+		for i := range list.Items {
+			services = append(services, &list.Items[i])
+		}
+		if err := h.RepairOwnershipTags(ctx, clusterName, services); err != nil {
+			klog.Errorf("failed to repair the ownership tags: %s", err)
+		}
+	}, interval)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"reflect"
+	"testing"
+
+	eipmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeTagger keeps the tags by the resource ID and records the tags added and deleted.
+type fakeTagger struct {
+	tags    map[string]map[string]string
+	ports   map[string]string
+	added   []map[string]string
+	deleted [][]string
+}
+
+func (f *fakeTagger) ListTags(id string) (map[string]string, error) {
+	rst := make(map[string]string)
+	for k, v := range f.tags[id] {
+		rst[k] = v
+	}
+	return rst, nil
+}
+
+func (f *fakeTagger) AddTags(id string, tags map[string]string) error {
+	if f.tags[id] == nil {
+		f.tags[id] = make(map[string]string)
+	}
+	for k, v := range tags {
+		f.tags[id][k] = v
+	}
+	f.added = append(f.added, tags)
+	return nil
+}
+
+func (f *fakeTagger) DeleteTags(id string, keys []string) error {
+	for _, k := range keys {
+		delete(f.tags[id], k)
+	}
+	f.deleted = append(f.deleted, keys)
+	return nil
+}
+
+func (f *fakeTagger) List(req *eipmodel.ListPublicipsRequest) ([]eipmodel.PublicipShowResp, error) {
+	rst := make([]eipmodel.PublicipShowResp, 0)
+	for id, portID := range f.ports {
+		id := id
+		if portID == (*req.PortId)[0] {
+			rst = append(rst, eipmodel.PublicipShowResp{Id: &id})
+		}
+	}
+	return rst, nil
+}
+
+func TestRepairLoadBalancerOwnershipTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		elbTags     map[string]string
+		eipTags     map[string]string
+		expectedELB map[string]string
+		expectedEIP map[string]string
+	}{
+		{
+			name:        "untagged",
+			elbTags:     map[string]string{"owner": "team-a"},
+			expectedELB: map[string]string{"owner": "team-a", OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-1"},
+			expectedEIP: map[string]string{OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-1"},
+		},
+		{
+			name:        "changed tag",
+			elbTags:     map[string]string{OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-0"},
+			eipTags:     map[string]string{OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-1"},
+			expectedELB: map[string]string{OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-1"},
+			expectedEIP: map[string]string{OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-1"},
+		},
+		{
+			name:        "specified resources",
+			annotations: map[string]string{ElbID: "elb-1", ElbEipID: "eip-1"},
+			expectedELB: map[string]string{},
+			expectedEIP: map[string]string{},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			service := newTestService(te.annotations)
+			service.UID = types.UID("uid-1")
+			elbTagger := &fakeTagger{tags: map[string]map[string]string{"elb-1": te.elbTags}}
+			eipTagger := &fakeTagger{
+				tags:  map[string]map[string]string{"eip-1": te.eipTags},
+				ports: map[string]string{"eip-1": "port-1"},
+			}
+
+			expected := getOwnershipTags("kubernetes", service)
+			err := repairLoadBalancerOwnershipTags(elbTagger, eipTagger, service, "elb-1", "port-1", expected)
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if got, _ := elbTagger.ListTags("elb-1"); !reflect.DeepEqual(got, te.expectedELB) {
+				t.Fatalf("expected: %v, got: %v", te.expectedELB, got)
+			}
+			if got, _ := eipTagger.ListTags("eip-1"); !reflect.DeepEqual(got, te.expectedEIP) {
+				t.Fatalf("expected: %v, got: %v", te.expectedEIP, got)
+			}
+
+			// The repaired resources are not written again.
+			elbAdded, eipAdded := len(elbTagger.added), len(eipTagger.added)
+			err = repairLoadBalancerOwnershipTags(elbTagger, eipTagger, service, "elb-1", "port-1", expected)
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if len(elbTagger.added) != elbAdded || len(eipTagger.added) != eipAdded {
+				t.Fatalf("expected no tags added, got: %v, %v", elbTagger.added[elbAdded:], eipTagger.added[eipAdded:])
+			}
+		})
+	}
+}
+
+func TestRepairOwnershipTagsOnlyMissing(t *testing.T) {
+	tagger := &fakeTagger{tags: map[string]map[string]string{
+		"elb-1": {OwnershipTagCluster: "kubernetes"},
+	}}
+	expected := map[string]string{OwnershipTagCluster: "kubernetes", OwnershipTagServiceUID: "uid-1"}
+
+	repaired, err := repairOwnershipTags(tagger, "elb-1", expected)
+	if err != nil || !repaired {
+		t.Fatalf("expected: %v, got: %v, %v", true, repaired, err)
+	}
+	want := []map[string]string{{OwnershipTagServiceUID: "uid-1"}}
+	if !reflect.DeepEqual(tagger.added, want) {
+		t.Fatalf("expected: %v, got: %v", want, tagger.added)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"time"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+)
+
+// provisioningBackoff is applied to polling the provisioning status of the ELB instance before each retry.
+var provisioningBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   1.5,
+	Jitter:   0.1,
+	Steps:    10,
+}
+
+// loadBalancerGetter gets the ELB instance, its provisioning status is not ACTIVE while the resources just created
+// in it, such as the listeners, are being provisioned.
+type loadBalancerGetter interface {
+	GetInstance(id string) (*elbmodel.LoadBalancer, error)
+}
+
+// loadBalancerGetterFunc adapts a function to loadBalancerGetter.
+type loadBalancerGetterFunc func(id string) (*elbmodel.LoadBalancer, error)
+
+// GetInstance calls f(id).
+func (f loadBalancerGetterFunc) GetInstance(id string) (*elbmodel.LoadBalancer, error) {
+	return f(id)
+}
+
+// retryOnNotReady runs the operation depending on a resource just created in the ELB instance, such as adding
+// the members to the pool of a new listener. If it fails because the resource is not ready yet, the operation is
+// retried up to the retries times, after the provisioning status of the ELB instance turns ACTIVE.
+// These retries are separate from the retries of the throttled API requests.
+func retryOnNotReady(ctx context.Context, client loadBalancerGetter, loadbalancerID string, retries int,
+	backoff wait.Backoff, op func() error) error {
+	err := op()
+	for i := 0; i < retries && common.IsConflict(err); i++ {
+		klog.V(4).Infof("The resources of loadbalancer %s are not ready, retry after it is ACTIVE: %s",
+			loadbalancerID, err)
+		if err := waitProvisioned(ctx, client, loadbalancerID, backoff); err != nil {
+			return err
+		}
+		err = op()
+	}
+	return err
+}
+
+// waitProvisioned waits until the provisioning status of the ELB instance is ACTIVE, or ctx is done.
+func waitProvisioned(ctx context.Context, client loadBalancerGetter, loadbalancerID string,
+	backoff wait.Backoff) error {
+	provisioningStatus := ""
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func() (bool, error) {
+		loadbalancer, err := client.GetInstance(loadbalancerID)
+		if err != nil {
+			return false, err
+		}
+		provisioningStatus = loadbalancer.ProvisioningStatus
+		switch provisioningStatus {
+		case "ACTIVE":
+			return true, nil
+		case "ERROR":
+			return false, status.Errorf(codes.Unavailable, "loadbalancer %s has gone into ERROR provisioning status",
+				loadbalancerID)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return status.Errorf(codes.Unavailable, "timeout when waiting for loadbalancer %s to be ACTIVE, "+
+			"current provisioning status %s", loadbalancerID, provisioningStatus)
+	}
+	return err
+}
+
+// retryOnNotReady runs the operation on the dedicated ELB instance, it is retried by "provisioning-retries"
+// if a resource just created in the instance is not ready yet.
+func (d *DedicatedLoadBalancer) retryOnNotReady(loadbalancerID string, op func() error) error {
+	return retryOnNotReady(d.reconcileContext(), d.dedicatedELBClient, loadbalancerID,
+		d.loadbalancerOpts.ProvisioningRetries, provisioningBackoff, op)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// fakeProvisioningELB is an ELB instance with a listener just created. The listener is not ready until the
+// provisioning status of the instance has been polled notReady times, adding a member fails with 409 meanwhile.
+type fakeProvisioningELB struct {
+	notReady int
+	polls    int
+	adds     int
+	status   string
+}
+
+func (f *fakeProvisioningELB) GetInstance(id string) (*elbmodel.LoadBalancer, error) {
+	f.polls++
+	provisioningStatus := "PENDING_UPDATE"
+	if f.polls >= f.notReady {
+		provisioningStatus = "ACTIVE"
+	}
+	if f.status != "" {
+		provisioningStatus = f.status
+	}
+	return &elbmodel.LoadBalancer{Id: id, ProvisioningStatus: provisioningStatus}, nil
+}
+
+func (f *fakeProvisioningELB) addMember() error {
+	f.adds++
+	if f.polls < f.notReady {
+		return sdkerr.ServiceResponseError{StatusCode: http.StatusConflict, ErrorMessage: "listener is not ready"}
+	}
+	return nil
+}
+
+func TestRetryOnNotReady(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Steps: 3}
+	tests := []struct {
+		name          string
+		elb           *fakeProvisioningELB
+		retries       int
+		op            func(f *fakeProvisioningELB) error
+		expectedError bool
+		expectedAdds  int
+	}{
+		{
+			name:         "ready",
+			elb:          &fakeProvisioningELB{},
+			retries:      3,
+			expectedAdds: 1,
+		},
+		{
+			name:         "not ready once then ready",
+			elb:          &fakeProvisioningELB{notReady: 1},
+			retries:      3,
+			expectedAdds: 2,
+		},
+		{
+			name:          "no retries",
+			elb:           &fakeProvisioningELB{notReady: 1},
+			retries:       0,
+			expectedError: true,
+			expectedAdds:  1,
+		},
+		{
+			name:          "loadbalancer not active",
+			elb:           &fakeProvisioningELB{notReady: 10},
+			retries:       3,
+			expectedError: true,
+			expectedAdds:  1,
+		},
+		{
+			name:          "loadbalancer in error",
+			elb:           &fakeProvisioningELB{notReady: 1, status: "ERROR"},
+			retries:       3,
+			expectedError: true,
+			expectedAdds:  1,
+		},
+		{
+			name:    "other errors are not retried",
+			elb:     &fakeProvisioningELB{},
+			retries: 3,
+			op: func(f *fakeProvisioningELB) error {
+				f.adds++
+				return fmt.Errorf("invalid member")
+			},
+			expectedError: true,
+			expectedAdds:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := tt.elb.addMember
+			if tt.op != nil {
+				op = func() error { return tt.op(tt.elb) }
+			}
+			err := retryOnNotReady(context.TODO(), tt.elb, "elb-1", tt.retries, backoff, op)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+			if tt.elb.adds != tt.expectedAdds {
+				t.Fatalf("expected: %d adds, got: %d", tt.expectedAdds, tt.elb.adds)
+			}
+		})
+	}
+}
+
+func TestWaitProvisionedCancelled(t *testing.T) {
+	// the ELB instance never turns ACTIVE, the wait stops once the reconcile is cancelled.
+	elb := &fakeProvisioningELB{status: "PENDING_UPDATE"}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	backoff := wait.Backoff{Duration: time.Hour, Steps: 10}
+	if err := waitProvisioned(ctx, elb, "elb-1", backoff); err != context.Canceled {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if elb.polls > 1 {
+		t.Fatalf("expected: at most %d poll, got: %d", 1, elb.polls)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// runWithTimeout runs the operation with a context derived from ctx that is cancelled after the timeout,
+// 0 means no timeout. If the operation does not return in time, a codes.DeadlineExceeded error is returned
+// without waiting for it. The API calls and the waits of the operation are bound to the context through the
+// clients scoped to the reconcile, so the operation returns in the background at its next API call or wait.
+func runWithTimeout(ctx context.Context, timeout time.Duration, op func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return op(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- op(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return status.Errorf(codes.DeadlineExceeded, "the operation is not finished in %s and will be retried: %v",
+			timeout, ctx.Err())
+	}
+}
+
+// reconcile runs the reconcile of the service with the lock of the service and a slot of the reconciles held,
+// bounded by "reconcile-timeout". A reconcile that times out is cancelled at its next API call or wait, it keeps
+// the lock until it returns in the background, the next reconcile of the service waits for it within its own
+// timeout, so the partial changes of the reconcile that times out are never reconciled concurrently, and they are
+// picked up by the next reconcile.
+func (h *CloudProvider) reconcile(ctx context.Context, key string, op func(ctx context.Context) error) error {
+	if err := h.shutdown.enter(); err != nil {
+		return err
+	}
+	timeout := time.Duration(h.loadbalancerOpts.ReconcileTimeout) * time.Second
+	return runWithTimeout(ctx, timeout, func(ctx context.Context) error {
+		defer h.shutdown.leave()
+		if err := h.mutexLock.LockWithContext(ctx, key); err != nil {
+			return err
+		}
+		defer h.mutexLock.Unlock(key)
+
+		if err := h.reconcileSem.Acquire(ctx); err != nil {
+			return err
+		}
+		defer h.reconcileSem.Release()
+		return op(ctx)
+	})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+// hangingLoadBalancer is an ELB instance whose provisioning status is polled until release is closed,
+// the poll does not observe the cancellation of the reconcile.
+type hangingLoadBalancer struct {
+	fakeLoadBalancer
+	release chan struct{}
+	ensures int32
+}
+
+func (h *hangingLoadBalancer) EnsureLoadBalancer(_ context.Context, _ string, _ *v1.Service, _ []*v1.Node) (
+	*v1.LoadBalancerStatus, error) {
+	atomic.AddInt32(&h.ensures, 1)
+	<-h.release
+	return &v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "192.168.0.10"}}}, nil
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	// the poll hangs until the operation is cancelled.
+	cancelled := make(chan struct{})
+	start := time.Now()
+	err := runWithTimeout(context.TODO(), 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected: %v, got: %v", codes.DeadlineExceeded, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("expected: timed out in %s, got: %s", 10*time.Millisecond, time.Since(start))
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected: the operation is cancelled")
+	}
+
+	// the error of the operation finished in time is returned as it is.
+	expected := fmt.Errorf("invalid service")
+	err = runWithTimeout(context.TODO(), time.Second, func(ctx context.Context) error {
+		return expected
+	})
+	if err != expected {
+		t.Fatalf("expected: %v, got: %v", expected, err)
+	}
+
+	// no timeout.
+	err = runWithTimeout(context.TODO(), 0, func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok {
+			return fmt.Errorf("unexpected deadline")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	lb := &hangingLoadBalancer{release: make(chan struct{})}
+	h := &CloudProvider{
+		Basic: Basic{
+			loadbalancerOpts: &config.LoadBalancerOptions{ReconcileTimeout: 1},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+	service := newMetricsTestService("web")
+	nodes := []*v1.Node{newTestNode(nil)}
+
+	if _, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes); status.Code(err) !=
+		codes.DeadlineExceeded {
+		t.Fatalf("expected: %v, got: %v", codes.DeadlineExceeded, err)
+	}
+
+	// the retry waits for the stuck reconcile rather than reconciling the service concurrently.
+	if _, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes); status.Code(err) !=
+		codes.DeadlineExceeded {
+		t.Fatalf("expected: %v, got: %v", codes.DeadlineExceeded, err)
+	}
+	if ensures := atomic.LoadInt32(&lb.ensures); ensures != 1 {
+		t.Fatalf("expected: %d reconcile, got: %d", 1, ensures)
+	}
+
+	// the retry succeeds once the stuck reconcile returns.
+	close(lb.release)
+	lbStatus, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes)
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	if len(lbStatus.Ingress) != 1 {
+		t.Fatalf("expected: %d ingress, got: %v", 1, lbStatus.Ingress)
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+const (
+	// retiredLoadBalancerSuffix is appended to the name of the ELB instance replaced by a new one.
+	retiredLoadBalancerSuffix = "_retired"
+	// retireDeadlineKey precedes the time in the description of the retired ELB instance, it is deleted after then.
+	retireDeadlineKey = "delete-after="
+)
+
+// instanceReplacer creates the new ELB instances and retires the replaced ones.
+type instanceReplacer interface {
+	dedicatedLoadBalancerCreator
+	WaitStatusActive(id string) (*elbmodel.LoadBalancer, error)
+	UpdateInstance(id, name, description string) (*elbmodel.LoadBalancer, error)
+	ListPools(req *elbmodel.ListPoolsRequest) ([]elbmodel.Pool, error)
+}
+
+// loadBalancerReplacement replaces the ELB instance with a new one when an immutable field is changed.
+// The replaced instance is renamed as retired and keeps serving, it is deleted after the grace period
+// once the new instance is ready and the status of the service is switched to it.
+type loadBalancerReplacement struct {
+	client      instanceReplacer
+	eipClient   eipReleaser
+	gracePeriod time.Duration
+	now         func() time.Time
+	// deleteInstance deletes the retired ELB instance with its listeners and pools.
+	deleteInstance func(loadbalancer *elbmodel.LoadBalancer) error
+}
+
+// isVipSubnetChanged returns true if the service specifies a VIP subnet other than the one of the ELB instance,
+// the VIP subnet cannot be changed without re-creating the instance.
+func isVipSubnetChanged(service *v1.Service, loadbalancer *elbmodel.LoadBalancer) bool {
+	subnetID := getStringFromSvsAnnotation(service, ElbSubnetID, "")
+	return subnetID != "" && loadbalancer.VipSubnetCidrId != "" && subnetID != loadbalancer.VipSubnetCidrId
+}
+
+func getRetiredLoadBalancerName(name string) string {
+	return utils.CutString(name, defaultMaxNameLength-len(retiredLoadBalancerSuffix)) + retiredLoadBalancerSuffix
+}
+
+// getRetiredDescription returns the description of the retired ELB instance, the deadline is omitted if it is zero.
+func getRetiredDescription(service *v1.Service, deadline time.Time) string {
+	desc := fmt.Sprintf("Replaced by a new ELB of the service(%s/%s).", service.Namespace, service.Name)
+	if deadline.IsZero() {
+		return desc
+	}
+	return fmt.Sprintf("%s %s%s", desc, retireDeadlineKey, deadline.UTC().Format(time.RFC3339))
+}
+
+// getRetireDeadline returns the time after which the retired ELB instance is deleted,
+// false if the new instance is not ready yet.
+func getRetireDeadline(description string) (time.Time, bool) {
+	idx := strings.Index(description, retireDeadlineKey)
+	if idx < 0 {
+		return time.Time{}, false
+	}
+	deadline, err := time.Parse(time.RFC3339, strings.TrimSpace(description[idx+len(retireDeadlineKey):]))
+	if err != nil {
+		klog.Warningf("failed to parse the deadline of the retired ELB from %q: %s", description, err)
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// isLoadBalancerReady returns true if the ELB instance is active and all its pools have members.
+func isLoadBalancerReady(loadbalancer *elbmodel.LoadBalancer, pools []elbmodel.Pool) bool {
+	if loadbalancer.ProvisioningStatus != "ACTIVE" || len(pools) == 0 {
+		return false
+	}
+	for _, pool := range pools {
+		if len(pool.Members) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// start renames the ELB instance as retired and creates the new one, the retired one keeps serving
+// until the new one is ready.
+func (r *loadBalancerReplacement) start(service *v1.Service, old *elbmodel.LoadBalancer,
+	createOpt *elbmodel.CreateLoadBalancerOption) (*elbmodel.LoadBalancer, error) {
+	klog.Infof("Replacing the ELB %s of service %s/%s, its VIP subnet cannot be changed in place",
+		old.Id, service.Namespace, service.Name)
+	_, err := r.client.UpdateInstance(old.Id, getRetiredLoadBalancerName(old.Name),
+		getRetiredDescription(service, time.Time{}))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to retire the ELB %s: %v", old.Id, err)
+	}
+
+	id, err := createDedicatedLoadBalancerOnce(r.client, createOpt)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create the ELB replacing %s: %v", old.Id, err)
+	}
+	loadbalancer, err := r.client.WaitStatusActive(id)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create the ELB replacing %s: %v", old.Id, err)
+	}
+	klog.Infof("Created the ELB %s replacing %s", loadbalancer.Id, old.Id)
+	return loadbalancer, nil
+}
+
+// finish sets the deadlines of the retired ELB instances once the current one is ready, and deletes the ones
+// whose deadlines have passed. The EIP specified by the service is unbound from the retired ones to be bound to
+// the current one. It returns an error if the current one is not ready, so that the status of the service
+// is not switched to it, and returns true if a deadline is set.
+func (r *loadBalancerReplacement) finish(service *v1.Service, current *elbmodel.LoadBalancer) (bool, error) {
+	names := []string{getRetiredLoadBalancerName(current.Name)}
+	retired, err := r.client.ListInstances(&elbmodel.ListLoadBalancersRequest{Name: &names})
+	if err != nil {
+		return false, err
+	}
+	if len(retired) == 0 {
+		return false, nil
+	}
+
+	loadbalancerIDs := []string{current.Id}
+	pools, err := r.client.ListPools(&elbmodel.ListPoolsRequest{LoadbalancerId: &loadbalancerIDs})
+	if err != nil {
+		return false, err
+	}
+	if !isLoadBalancerReady(current, pools) {
+		return false, status.Errorf(codes.Unavailable, "the ELB %s is not ready to replace %d retired ELB(s), "+
+			"waiting for it to be active and have members", current.Id, len(retired))
+	}
+
+	scheduled := false
+	now := r.now()
+	for _, lb := range retired {
+		lb := lb
+		deadline, ok := getRetireDeadline(lb.Description)
+		if !ok {
+			if eipID := getStringFromSvsAnnotation(service, ElbEipID, ""); eipID != "" {
+				if err = unbindEIP(r.eipClient, lb.VipPortId, eipID, true); err != nil {
+					return false, status.Errorf(codes.Internal, "failed to unbind the EIP %s from the retired ELB %s: %v",
+						eipID, lb.Id, err)
+				}
+			}
+
+			deadline = now.Add(r.gracePeriod)
+			if _, err = r.client.UpdateInstance(lb.Id, lb.Name, getRetiredDescription(service, deadline)); err != nil {
+				return false, err
+			}
+			klog.Infof("The ELB %s is replaced by %s, it will be deleted after %s", lb.Id, current.Id, deadline)
+			scheduled = true
+		}
+
+		if now.Before(deadline) {
+			continue
+		}
+		klog.Infof("Deleting the retired ELB %s, the grace period is over", lb.Id)
+		if err = r.deleteInstance(&lb); err != nil && !common.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return scheduled, nil
+}
+
+func (d *DedicatedLoadBalancer) newLoadBalancerReplacement(service *v1.Service) *loadBalancerReplacement {
+	return &loadBalancerReplacement{
+		client:      d.dedicatedELBClient,
+		eipClient:   d.eipClient,
+		gracePeriod: time.Duration(d.loadbalancerOpts.RecreateGracePeriod) * time.Second,
+		now:         time.Now,
+		deleteInstance: func(loadbalancer *elbmodel.LoadBalancer) error {
+			return d.deleteELBInstance(loadbalancer, service)
+		},
+	}
+}
+
+// replaceLoadBalancer creates a new ELB instance in the VIP subnet specified by the service to replace the current one.
+func (d *DedicatedLoadBalancer) replaceLoadBalancer(clusterName string, service *v1.Service,
+	loadbalancer *elbmodel.LoadBalancer) (*elbmodel.LoadBalancer, error) {
+	subnetID := getStringFromSvsAnnotation(service, ElbSubnetID, "")
+	createOpt, err := d.newCreateLoadBalancerOption(clusterName, subnetID, service)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateSubnetAvailabilityZone(d.vpcClient, d.cloudConfig.VpcOpts.ID, subnetID,
+		createOpt.AvailabilityZoneList); err != nil {
+		return nil, err
+	}
+	// The specified EIP is still bound to the current instance, it is moved once the new instance is ready.
+	createOpt.PublicipIds = nil
+
+	return d.newLoadBalancerReplacement(service).start(service, loadbalancer, createOpt)
+}
+
+// finishReplacement deletes the retired ELB instances replaced by the current one after the grace period.
+func (d *DedicatedLoadBalancer) finishReplacement(clusterName string, service *v1.Service,
+	loadbalancer *elbmodel.LoadBalancer) error {
+	replacement := d.newLoadBalancerReplacement(service)
+	scheduled, err := replacement.finish(service, loadbalancer)
+	if err != nil || !scheduled {
+		return err
+	}
+
+	// The service may not be reconciled again after the grace period, so the deletion is scheduled.
+	key := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+	d.shutdown.afterFunc(replacement.gracePeriod, func() {
+		d.mutexLock.Lock(key)
+		defer d.mutexLock.Unlock(key)
+
+		current, err := d.getLoadBalancerInstance(context.TODO(), clusterName, service)
+		if err == nil {
+			_, err = replacement.finish(service, current)
+		}
+		if err != nil && !common.IsNotFound(err) {
+			klog.Errorf("failed to delete the retired ELB of service %s: %s", key, err)
+		}
+	})
+	return nil
+}
+
+// deleteRetiredLoadBalancers deletes the retired ELB instances of the service regardless of the grace period.
+func (d *DedicatedLoadBalancer) deleteRetiredLoadBalancers(clusterName string, service *v1.Service) error {
+	names := []string{getRetiredLoadBalancerName(d.GetLoadBalancerName(context.TODO(), clusterName, service))}
+	retired, err := d.dedicatedELBClient.ListInstances(&elbmodel.ListLoadBalancersRequest{Name: &names})
+	if err != nil {
+		return err
+	}
+	for _, lb := range retired {
+		lb := lb
+		klog.Infof("Deleting the retired ELB %s of service %s/%s", lb.Id, service.Namespace, service.Name)
+		if err = d.deleteELBInstance(&lb, service); err != nil && !common.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
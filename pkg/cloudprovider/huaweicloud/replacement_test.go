@@ -0,0 +1,249 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/utils/pointer"
+)
+
+// fakeInstanceReplacer stores the ELB instances and records the calls in order.
+type fakeInstanceReplacer struct {
+	instances []elbmodel.LoadBalancer
+	pools     map[string][]elbmodel.Pool
+	calls     []string
+	// timeouts is the number of the creates that take effect but return an error.
+	timeouts int
+}
+
+func (f *fakeInstanceReplacer) CreateInstance(req *elbmodel.CreateLoadBalancerOption) (
+	*elbmodel.LoadBalancer, error) {
+	lb := elbmodel.LoadBalancer{
+		Id:                 fmt.Sprintf("elb-%d", len(f.instances)+1),
+		Name:               *req.Name,
+		Description:        pointer.StringDeref(req.Description, ""),
+		VipSubnetCidrId:    pointer.StringDeref(req.VipSubnetCidrId, ""),
+		VipAddress:         "192.168.1.100",
+		ProvisioningStatus: "ACTIVE",
+	}
+	f.instances = append(f.instances, lb)
+	f.calls = append(f.calls, "create "+lb.Id)
+	if f.timeouts > 0 {
+		f.timeouts--
+		return nil, fmt.Errorf("request timeout")
+	}
+	return &lb, nil
+}
+
+func (f *fakeInstanceReplacer) WaitStatusActive(id string) (*elbmodel.LoadBalancer, error) {
+	for i := range f.instances {
+		if f.instances[i].Id == id {
+			return &f.instances[i], nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "ELB %s not found", id)
+}
+
+func (f *fakeInstanceReplacer) ListInstances(req *elbmodel.ListLoadBalancersRequest) ([]elbmodel.LoadBalancer, error) {
+	rst := make([]elbmodel.LoadBalancer, 0)
+	for _, lb := range f.instances {
+		if lb.Name != (*req.Name)[0] {
+			continue
+		}
+		if req.Description != nil && lb.Description != (*req.Description)[0] {
+			continue
+		}
+		rst = append(rst, lb)
+	}
+	return rst, nil
+}
+
+func (f *fakeInstanceReplacer) UpdateInstance(id, name, description string) (*elbmodel.LoadBalancer, error) {
+	for i := range f.instances {
+		if f.instances[i].Id == id {
+			f.instances[i].Name = name
+			f.instances[i].Description = description
+			f.calls = append(f.calls, "update "+id)
+			return &f.instances[i], nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "ELB %s not found", id)
+}
+
+func (f *fakeInstanceReplacer) ListPools(req *elbmodel.ListPoolsRequest) ([]elbmodel.Pool, error) {
+	return f.pools[(*req.LoadbalancerId)[0]], nil
+}
+
+func (f *fakeInstanceReplacer) delete(loadbalancer *elbmodel.LoadBalancer) error {
+	for i := range f.instances {
+		if f.instances[i].Id == loadbalancer.Id {
+			f.instances = append(f.instances[:i], f.instances[i+1:]...)
+			f.calls = append(f.calls, "delete "+loadbalancer.Id)
+			return nil
+		}
+	}
+	return status.Errorf(codes.NotFound, "ELB %s not found", loadbalancer.Id)
+}
+
+func TestIsVipSubnetChanged(t *testing.T) {
+	loadbalancer := &elbmodel.LoadBalancer{VipSubnetCidrId: "subnet-1"}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{name: "not specified", annotations: nil, expected: false},
+		{name: "unchanged", annotations: map[string]string{ElbSubnetID: "subnet-1"}, expected: false},
+		{name: "changed", annotations: map[string]string{ElbSubnetID: "subnet-2"}, expected: true},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			if got := isVipSubnetChanged(newTestService(te.annotations), loadbalancer); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetRetireDeadline(t *testing.T) {
+	service := newTestService(nil)
+	if _, ok := getRetireDeadline(getRetiredDescription(service, time.Time{})); ok {
+		t.Fatalf("expected: no deadline, got: a deadline")
+	}
+
+	deadline := time.Date(2023, 6, 1, 0, 5, 0, 0, time.UTC)
+	got, ok := getRetireDeadline(getRetiredDescription(service, deadline))
+	if !ok || !got.Equal(deadline) {
+		t.Fatalf("expected: %v, got: %v", deadline, got)
+	}
+}
+
+func TestLoadBalancerReplacement(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	service := newTestService(map[string]string{ElbSubnetID: "subnet-2"})
+	name := "k8s_service_kubernetes_default_test"
+	client := &fakeInstanceReplacer{
+		instances: []elbmodel.LoadBalancer{{
+			Id:                 "elb-old",
+			Name:               name,
+			VipSubnetCidrId:    "subnet-1",
+			VipAddress:         "192.168.0.100",
+			ProvisioningStatus: "ACTIVE",
+		}},
+		pools: map[string][]elbmodel.Pool{},
+		calls: []string{},
+	}
+	r := &loadBalancerReplacement{
+		client:         client,
+		eipClient:      &fakeEIPReleaser{eips: map[string]string{}},
+		gracePeriod:    5 * time.Minute,
+		now:            func() time.Time { return now },
+		deleteInstance: client.delete,
+	}
+
+	old := client.instances[0]
+	current, err := r.start(service, &old, &elbmodel.CreateLoadBalancerOption{
+		Name:            &name,
+		VipSubnetCidrId: pointer.String("subnet-2"),
+	})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	expected := []string{"update elb-old", "create elb-2"}
+	if !reflect.DeepEqual(client.calls, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, client.calls)
+	}
+	if lb := client.instances[0]; lb.Name != getRetiredLoadBalancerName(name) {
+		t.Fatalf("expected: the old ELB is retired and kept, got: %v", lb.Name)
+	}
+
+	// the new ELB has no members yet, the status is not switched to it.
+	client.pools[current.Id] = []elbmodel.Pool{{Id: "pool-1"}}
+	if _, err = r.finish(service, current); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected: %v, got: %v", codes.Unavailable, err)
+	}
+
+	client.pools[current.Id] = []elbmodel.Pool{{Id: "pool-1", Members: []elbmodel.MemberRef{{Id: "member-1"}}}}
+	scheduled, err := r.finish(service, current)
+	if err != nil || !scheduled {
+		t.Fatalf("expected: the deletion is scheduled, got: %v, %v", scheduled, err)
+	}
+	lbStatus := (&DedicatedLoadBalancer{}).buildStatus(newTestService(nil), current)
+	if ip := lbStatus.Ingress[0].IP; ip != "192.168.1.100" {
+		t.Fatalf("expected: the status is switched to the new ELB 192.168.1.100, got: %v", ip)
+	}
+
+	// the retired ELB is kept within the grace period.
+	now = now.Add(time.Minute)
+	if _, err = r.finish(service, current); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if len(client.instances) != 2 {
+		t.Fatalf("expected: 2 ELB instances, got: %v", len(client.instances))
+	}
+
+	now = now.Add(5 * time.Minute)
+	if _, err = r.finish(service, current); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	expected = []string{"update elb-old", "create elb-2", "update elb-old", "delete elb-old"}
+	if !reflect.DeepEqual(client.calls, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, client.calls)
+	}
+}
+
+func TestLoadBalancerReplacementMovesEIP(t *testing.T) {
+	service := newTestService(map[string]string{ElbSubnetID: "subnet-2", ElbEipID: "eip-1"})
+	retired := elbmodel.LoadBalancer{
+		Id:        "elb-old",
+		Name:      getRetiredLoadBalancerName("elb"),
+		VipPortId: "old-port",
+	}
+	client := &fakeInstanceReplacer{
+		instances: []elbmodel.LoadBalancer{retired},
+		pools: map[string][]elbmodel.Pool{
+			"elb-new": {{Id: "pool-1", Members: []elbmodel.MemberRef{{Id: "member-1"}}}},
+		},
+		calls: []string{},
+	}
+	eipClient := &fakeEIPReleaser{eips: map[string]string{"eip-1": "old-port"}, calls: []string{}}
+	r := &loadBalancerReplacement{
+		client:         client,
+		eipClient:      eipClient,
+		now:            time.Now,
+		deleteInstance: client.delete,
+	}
+
+	current := &elbmodel.LoadBalancer{Id: "elb-new", Name: "elb", ProvisioningStatus: "ACTIVE"}
+	if _, err := r.finish(service, current); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if !reflect.DeepEqual(eipClient.calls, []string{"unbind eip-1"}) {
+		t.Fatalf("expected: %v, got: %v", []string{"unbind eip-1"}, eipClient.calls)
+	}
+	if len(client.instances) != 0 {
+		t.Fatalf("expected: the retired ELB is deleted, got: %v", client.instances)
+	}
+}
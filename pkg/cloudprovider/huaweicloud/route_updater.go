@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+// routeTableClient reads and updates the routes of the route table.
+type routeTableClient interface {
+	GetRouteTable(id string) (*vpcmodel.RouteTableResp, error)
+	UpdateRouteTableRoutes(id string, routes map[string][]vpcmodel.RouteTableRoute) error
+}
+
+// routeRequest is a route waiting to be added to the route table, the result is sent to done.
+type routeRequest struct {
+	clusterName string
+	route       vpcmodel.RouteTableRoute
+	action      string
+	done        chan error
+}
+
+// routeUpdater bounds the routes created simultaneously, and coalesces the routes created concurrently into bulk
+// updates of the route table, so that creating the routes of a large cluster does not exceed the VPC API limits.
+type routeUpdater struct {
+	client       routeTableClient
+	mutexLock    *mutexkv.MutexKV
+	routeTableID string
+	sem          *semaphore.Semaphore
+	batchSize    int
+	// backoff is applied to the updates throttled by the VPC API.
+	backoff wait.Backoff
+
+	mu      sync.Mutex
+	pending []*routeRequest
+}
+
+func newRouteUpdater(client routeTableClient, mutexLock *mutexkv.MutexKV, opts *config.VpcOptions) *routeUpdater {
+	return &routeUpdater{
+		client:       client,
+		mutexLock:    mutexLock,
+		routeTableID: opts.RouteTableID,
+		sem:          semaphore.NewSemaphore(opts.GetRouteConcurrency()),
+		batchSize:    opts.GetRouteBatchSize(),
+		backoff: wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    opts.GetRouteMaxRetries() + 1,
+		},
+	}
+}
+
+// addRoute builds the route, such as looking up the ECS of the target node, and adds it to the route table.
+// The number of the routes added simultaneously is bounded by "route-concurrency".
+func (u *routeUpdater) addRoute(ctx context.Context, clusterName string,
+	build func() (vpcmodel.RouteTableRoute, error)) error {
+	if err := u.sem.Acquire(ctx); err != nil {
+		return err
+	}
+	defer u.sem.Release()
+
+	route, err := build()
+	if err != nil {
+		return err
+	}
+	return u.submit(clusterName, route)
+}
+
+// submit queues the route and waits until it is added. The caller holding the lock of the route table adds the
+// queued routes in batches of "route-batch-size", including the routes queued by the others in the meantime.
+func (u *routeUpdater) submit(clusterName string, route vpcmodel.RouteTableRoute) error {
+	req := &routeRequest{clusterName: clusterName, route: route, done: make(chan error, 1)}
+	u.mu.Lock()
+	u.pending = append(u.pending, req)
+	u.mu.Unlock()
+
+	u.mutexLock.Lock(u.routeTableID)
+	defer u.mutexLock.Unlock(u.routeTableID)
+	for {
+		select {
+		case err := <-req.done:
+			return err
+		default:
+			u.flush(u.takePending())
+		}
+	}
+}
+
+// takePending returns the earliest queued routes, up to the batch size.
+func (u *routeUpdater) takePending() []*routeRequest {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	n := len(u.pending)
+	if u.batchSize > 0 && n > u.batchSize {
+		n = u.batchSize
+	}
+	batch := u.pending[:n:n]
+	u.pending = u.pending[n:]
+	return batch
+}
+
+// flush adds the routes of the batch in one update. If the update fails, the routes are added one by one,
+// so that a failed route is reported to its caller without failing the others.
+func (u *routeUpdater) flush(batch []*routeRequest) {
+	if len(batch) == 0 {
+		return
+	}
+	routeTable, err := u.client.GetRouteTable(u.routeTableID)
+	if err != nil {
+		for _, req := range batch {
+			req.done <- err
+		}
+		return
+	}
+
+	routes := make(map[string][]vpcmodel.RouteTableRoute)
+	queued := make([]*routeRequest, 0, len(batch))
+	for _, req := range batch {
+		action, err := getRouteAction(routeTable.Routes, req.route, req.clusterName)
+		if err != nil || action == "" {
+			req.done <- err
+			continue
+		}
+		klog.Infof("Route %s -> %s of cluster %s is to be %s", req.route.Destination, req.route.Nexthop,
+			req.clusterName, action)
+		req.action = action
+		routes[action] = append(routes[action], req.route)
+		queued = append(queued, req)
+	}
+	if len(queued) == 0 {
+		return
+	}
+
+	err = u.update(routes)
+	if err == nil || len(queued) == 1 {
+		for _, req := range queued {
+			req.done <- err
+		}
+		return
+	}
+	klog.Warningf("Failed to update %d routes of route table %s in bulk, update them one by one: %s",
+		len(queued), u.routeTableID, err)
+	for _, req := range queued {
+		req.done <- u.update(map[string][]vpcmodel.RouteTableRoute{req.action: {req.route}})
+	}
+}
+
+// update updates the routes of the route table, the update is retried with backoff while it is throttled.
+func (u *routeUpdater) update(routes map[string][]vpcmodel.RouteTableRoute) error {
+	var err error
+	_ = wait.ExponentialBackoff(u.backoff, func() (bool, error) {
+		err = u.client.UpdateRouteTableRoutes(u.routeTableID, routes)
+		if common.IsThrottled(err) {
+			klog.V(4).Infof("Updating the routes of route table %s is throttled, retry later: %s", u.routeTableID, err)
+			return false, nil
+		}
+		return true, nil
+	})
+	return err
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+)
+
+// fakeRouteTable keeps the routes of a route table and records the updates. The routes to the destinations in
+// rejected fail to be added, and the first throttled updates are rejected with 429.
+type fakeRouteTable struct {
+	mu        sync.Mutex
+	routes    []vpcmodel.RouteTableRoute
+	updates   []int
+	rejected  map[string]bool
+	throttled int
+}
+
+func (f *fakeRouteTable) GetRouteTable(_ string) (*vpcmodel.RouteTableResp, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &vpcmodel.RouteTableResp{Routes: append([]vpcmodel.RouteTableRoute{}, f.routes...)}, nil
+}
+
+func (f *fakeRouteTable) UpdateRouteTableRoutes(_ string, routes map[string][]vpcmodel.RouteTableRoute) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.throttled > 0 {
+		f.throttled--
+		return sdkerr.ServiceResponseError{StatusCode: http.StatusTooManyRequests}
+	}
+	for _, route := range routes[routeActionAdd] {
+		if f.rejected[route.Destination] {
+			return fmt.Errorf("invalid destination %s", route.Destination)
+		}
+	}
+	f.routes = append(f.routes, routes[routeActionAdd]...)
+	f.updates = append(f.updates, len(routes[routeActionAdd]))
+	return nil
+}
+
+func newTestRouteUpdater(client routeTableClient, concurrency, batchSize int) *routeUpdater {
+	u := newRouteUpdater(client, mutexkv.NewMutexKV(), &config.VpcOptions{
+		RouteTableID:     "rtb-1",
+		RouteConcurrency: concurrency,
+		RouteBatchSize:   batchSize,
+	})
+	u.backoff = wait.Backoff{Duration: time.Millisecond, Steps: 3}
+	return u
+}
+
+// addRoutes adds the routes to the destinations 172.16.{i}.0/24 concurrently, and returns the errors by destination.
+func addRoutes(u *routeUpdater, count int, build func(route vpcmodel.RouteTableRoute) error) map[string]error {
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			description := routeDescription("kubernetes", fmt.Sprintf("node-%d", i))
+			route := vpcmodel.RouteTableRoute{Type: routeTypeECS, Destination: fmt.Sprintf("172.16.%d.0/24", i),
+				Nexthop: fmt.Sprintf("ecs-%d", i), Description: &description}
+			err := u.addRoute(context.TODO(), "kubernetes", func() (vpcmodel.RouteTableRoute, error) {
+				return route, build(route)
+			})
+			mu.Lock()
+			errs[route.Destination] = err
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestRouteUpdaterConcurrency(t *testing.T) {
+	client := &fakeRouteTable{}
+	u := newTestRouteUpdater(client, 3, 0)
+
+	var running, peak int32
+	errs := addRoutes(u, 20, func(_ vpcmodel.RouteTableRoute) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+
+	for destination, err := range errs {
+		if err != nil {
+			t.Fatalf("expected: %v of %s, got: %v", nil, destination, err)
+		}
+	}
+	if peak > 3 {
+		t.Fatalf("expected: at most %d routes built simultaneously, got: %d", 3, peak)
+	}
+	if len(client.routes) != 20 {
+		t.Fatalf("expected: %d routes, got: %d", 20, len(client.routes))
+	}
+}
+
+func TestRouteUpdaterBatching(t *testing.T) {
+	client := &fakeRouteTable{}
+	u := newTestRouteUpdater(client, 50, 4)
+
+	// the routes are queued while the route table is locked, then added in batches.
+	u.mutexLock.Lock(u.routeTableID)
+	var queued int32
+	done := make(chan map[string]error)
+	go func() {
+		done <- addRoutes(u, 10, func(_ vpcmodel.RouteTableRoute) error {
+			atomic.AddInt32(&queued, 1)
+			return nil
+		})
+	}()
+	err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return len(u.pending) == 10, nil
+	})
+	if err != nil {
+		t.Fatalf("expected: %d routes queued, got: %d", 10, atomic.LoadInt32(&queued))
+	}
+	u.mutexLock.Unlock(u.routeTableID)
+
+	for destination, err := range <-done {
+		if err != nil {
+			t.Fatalf("expected: %v of %s, got: %v", nil, destination, err)
+		}
+	}
+	expected := []int{4, 4, 2}
+	if fmt.Sprint(client.updates) != fmt.Sprint(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, client.updates)
+	}
+
+	// the existing routes are not added again.
+	if errs := addRoutes(u, 10, func(_ vpcmodel.RouteTableRoute) error { return nil }); len(client.updates) != 3 {
+		t.Fatalf("expected: no updates, got: %v, %v", client.updates, errs)
+	}
+}
+
+func TestRouteUpdaterPartialFailure(t *testing.T) {
+	client := &fakeRouteTable{rejected: map[string]bool{"172.16.1.0/24": true}}
+	u := newTestRouteUpdater(client, 0, 0)
+
+	errs := addRoutes(u, 4, func(_ vpcmodel.RouteTableRoute) error { return nil })
+	for destination, err := range errs {
+		if (err != nil) != client.rejected[destination] {
+			t.Fatalf("expected error of %s: %v, got: %v", destination, client.rejected[destination], err)
+		}
+	}
+	if len(client.routes) != 3 {
+		t.Fatalf("expected: %d routes, got: %v", 3, client.routes)
+	}
+}
+
+func TestRouteUpdaterThrottled(t *testing.T) {
+	client := &fakeRouteTable{throttled: 2}
+	u := newTestRouteUpdater(client, 0, 0)
+	if err := u.submit("kubernetes", newTestRoute("172.16.0.0/24", "ecs-1",
+		routeDescription("kubernetes", "node-1"))); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+
+	// the update fails when it is still throttled after the retries.
+	client.throttled = 3
+	err := u.submit("kubernetes", newTestRoute("172.16.1.0/24", "ecs-2", routeDescription("kubernetes", "node-2")))
+	if err == nil || len(client.routes) != 1 {
+		t.Fatalf("expected: the throttled error, got: %v, %v", client.routes, err)
+	}
+}
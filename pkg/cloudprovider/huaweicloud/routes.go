@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+const (
+	routeTypeECS = "ecs"
+
+	routeActionAdd = "add"
+	routeActionMod = "mod"
+	routeActionDel = "del"
+
+	// routeDescriptionPrefix marks the routes created by the cluster, the description is
+	// "kubernetes.io/cluster/{clusterName}:{nodeName}", routes without the mark are never touched.
+	routeDescriptionPrefix = "kubernetes.io/cluster/"
+)
+
+type Routes struct {
+	Basic
+}
+
+// ListRoutes lists the routes of the cluster in the route table,
+// the routes whose target node no longer exists are pruned.
+func (r *Routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudprovider.Route, error) {
+	klog.Infof("ListRoutes is called with cluster %s", clusterName)
+	routeTableID := r.cloudConfig.VpcOpts.RouteTableID
+	r.mutexLock.Lock(routeTableID)
+	defer r.mutexLock.Unlock(routeTableID)
+
+	routeTable, err := r.vpcClient.GetRouteTable(routeTableID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeList, err := r.kubeClient.Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %s", err)
+	}
+	nodeNames := sets.NewString()
+	for _, node := range nodeList.Items {
+		nodeNames.Insert(node.Name)
+	}
+
+	routes, stale := partitionRoutes(routeTable.Routes, clusterName, nodeNames)
+	if len(stale) > 0 && r.isReadOnly() {
+		klog.Infof("Skip pruning %d stale routes of cluster %s in the read-only mode: %v", len(stale), clusterName, stale)
+	} else if len(stale) > 0 {
+		klog.Infof("Prune %d routes of cluster %s whose target node no longer exists: %v",
+			len(stale), clusterName, stale)
+		err = r.vpcClient.UpdateRouteTableRoutes(routeTableID, map[string][]vpcmodel.RouteTableRoute{
+			routeActionDel: stale,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune the stale routes of route table %s: %s", routeTableID, err)
+		}
+	}
+
+	return routes, nil
+}
+
+// CreateRoute creates the route to the ECS of the target node, it does nothing if the route already exists.
+// The routes created concurrently are added to the route table in batches, see routeUpdater.
+func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint string, route *cloudprovider.Route) error {
+	klog.Infof("CreateRoute is called with cluster %s, name hint: %s, route: %s -> %s",
+		clusterName, nameHint, route.DestinationCIDR, route.TargetNode)
+	if err := r.checkReadOnly("CreateRoute"); err != nil {
+		return err
+	}
+
+	return r.routeUpdater.addRoute(ctx, clusterName, func() (vpcmodel.RouteTableRoute, error) {
+		server, err := r.ecsClient.GetByNodeName(string(route.TargetNode))
+		if err != nil {
+			return vpcmodel.RouteTableRoute{}, err
+		}
+		description := routeDescription(clusterName, string(route.TargetNode))
+		return vpcmodel.RouteTableRoute{
+			Type:        routeTypeECS,
+			Destination: route.DestinationCIDR,
+			Nexthop:     server.Id,
+			Description: &description,
+		}, nil
+	})
+}
+
+// DeleteRoute deletes the route of the cluster, it does nothing if the route does not exist.
+func (r *Routes) DeleteRoute(_ context.Context, clusterName string, route *cloudprovider.Route) error {
+	klog.Infof("DeleteRoute is called with cluster %s, route: %s -> %s",
+		clusterName, route.DestinationCIDR, route.TargetNode)
+	if err := r.checkReadOnly("DeleteRoute"); err != nil {
+		return err
+	}
+	routeTableID := r.cloudConfig.VpcOpts.RouteTableID
+	r.mutexLock.Lock(routeTableID)
+	defer r.mutexLock.Unlock(routeTableID)
+
+	routeTable, err := r.vpcClient.GetRouteTable(routeTableID)
+	if err != nil {
+		return err
+	}
+
+	for _, rt := range routeTable.Routes {
+		if rt.Destination != route.DestinationCIDR {
+			continue
+		}
+		if _, ok := parseRouteTargetNode(rt, clusterName); !ok {
+			klog.Warningf("Route %s is not created by cluster %s, skip deleting", rt.Destination, clusterName)
+			return nil
+		}
+		return r.vpcClient.UpdateRouteTableRoutes(routeTableID, map[string][]vpcmodel.RouteTableRoute{
+			routeActionDel: {rt},
+		})
+	}
+
+	klog.Infof("Route %s not found in route table %s, skip deleting", route.DestinationCIDR, routeTableID)
+	return nil
+}
+
+func routeDescription(clusterName, nodeName string) string {
+	return fmt.Sprintf("%s%s:%s", routeDescriptionPrefix, clusterName, nodeName)
+}
+
+// parseRouteTargetNode returns the target node of the route, and false if the route is not created by the cluster.
+func parseRouteTargetNode(route vpcmodel.RouteTableRoute, clusterName string) (string, bool) {
+	if route.Description == nil {
+		return "", false
+	}
+
+	prefix := fmt.Sprintf("%s%s:", routeDescriptionPrefix, clusterName)
+	if !strings.HasPrefix(*route.Description, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(*route.Description, prefix), true
+}
+
+// partitionRoutes splits the routes of the cluster into the routes whose target node exists,
+// and the stale routes whose target node no longer exists. The routes of the others are ignored.
+func partitionRoutes(routes []vpcmodel.RouteTableRoute, clusterName string, nodeNames sets.String) (
+	[]*cloudprovider.Route, []vpcmodel.RouteTableRoute) {
+	rst := make([]*cloudprovider.Route, 0)
+	stale := make([]vpcmodel.RouteTableRoute, 0)
+	for _, route := range routes {
+		nodeName, ok := parseRouteTargetNode(route, clusterName)
+		if !ok {
+			continue
+		}
+
+		if !nodeNames.Has(nodeName) {
+			stale = append(stale, route)
+			continue
+		}
+		rst = append(rst, &cloudprovider.Route{
+			Name:            route.Destination,
+			TargetNode:      types.NodeName(nodeName),
+			DestinationCIDR: route.Destination,
+		})
+	}
+	return rst, stale
+}
+
+// getRouteAction returns the action to make the route table contain the expected route,
+// the route of the cluster with the same destination is modified, such as the PodCIDR is reused by another node.
+// An empty action is returned if the route already exists.
+func getRouteAction(routes []vpcmodel.RouteTableRoute, expected vpcmodel.RouteTableRoute, clusterName string) (
+	string, error) {
+	for _, route := range routes {
+		if route.Destination != expected.Destination {
+			continue
+		}
+
+		if _, ok := parseRouteTargetNode(route, clusterName); !ok {
+			return "", status.Errorf(codes.AlreadyExists, "the destination %s is already used by another route "+
+				"to %s %s", route.Destination, route.Type, route.Nexthop)
+		}
+		if route.Type == expected.Type && route.Nexthop == expected.Nexthop &&
+			*route.Description == *expected.Description {
+			return "", nil
+		}
+		return routeActionMod, nil
+	}
+	return routeActionAdd, nil
+}
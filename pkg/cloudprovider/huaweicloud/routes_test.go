@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"reflect"
+	"testing"
+
+	vpcmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/vpc/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/utils/pointer"
+)
+
+func newTestRoute(destination, nexthop, description string) vpcmodel.RouteTableRoute {
+	route := vpcmodel.RouteTableRoute{
+		Type:        routeTypeECS,
+		Destination: destination,
+		Nexthop:     nexthop,
+	}
+	if description != "" {
+		route.Description = pointer.String(description)
+	}
+	return route
+}
+
+func TestPartitionRoutes(t *testing.T) {
+	routes := []vpcmodel.RouteTableRoute{
+		newTestRoute("172.16.0.0/24", "ecs-1", routeDescription("cluster-a", "node-1")),
+		newTestRoute("172.16.1.0/24", "ecs-2", routeDescription("cluster-a", "node-2")),
+		newTestRoute("172.16.2.0/24", "ecs-3", routeDescription("cluster-b", "node-3")),
+		newTestRoute("10.0.0.0/8", "vpn-1", ""),
+		newTestRoute("192.168.0.0/16", "peering-1", "created manually"),
+	}
+
+	tests := []struct {
+		name      string
+		nodeNames sets.String
+		expected  []*cloudprovider.Route
+		stale     []vpcmodel.RouteTableRoute
+	}{
+		{
+			name:      "all nodes exist",
+			nodeNames: sets.NewString("node-1", "node-2"),
+			expected: []*cloudprovider.Route{
+				{Name: "172.16.0.0/24", TargetNode: "node-1", DestinationCIDR: "172.16.0.0/24"},
+				{Name: "172.16.1.0/24", TargetNode: "node-2", DestinationCIDR: "172.16.1.0/24"},
+			},
+			stale: []vpcmodel.RouteTableRoute{},
+		},
+		{
+			name:      "target node deleted",
+			nodeNames: sets.NewString("node-1"),
+			expected: []*cloudprovider.Route{
+				{Name: "172.16.0.0/24", TargetNode: "node-1", DestinationCIDR: "172.16.0.0/24"},
+			},
+			stale: []vpcmodel.RouteTableRoute{routes[1]},
+		},
+		{
+			name:      "all nodes deleted",
+			nodeNames: sets.NewString(),
+			expected:  []*cloudprovider.Route{},
+			stale:     []vpcmodel.RouteTableRoute{routes[0], routes[1]},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, stale := partitionRoutes(routes, "cluster-a", testCase.nodeNames)
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, got)
+			}
+			if !reflect.DeepEqual(stale, testCase.stale) {
+				t.Fatalf("expected: %v, got: %v", testCase.stale, stale)
+			}
+		})
+	}
+}
+
+func TestGetRouteAction(t *testing.T) {
+	expected := newTestRoute("172.16.0.0/24", "ecs-1", routeDescription("cluster-a", "node-1"))
+
+	tests := []struct {
+		name     string
+		routes   []vpcmodel.RouteTableRoute
+		action   string
+		expected codes.Code
+	}{
+		{
+			name:   "not exist",
+			routes: []vpcmodel.RouteTableRoute{newTestRoute("172.16.1.0/24", "ecs-2", routeDescription("cluster-a", "node-2"))},
+			action: routeActionAdd,
+		},
+		{
+			name:   "already exists",
+			routes: []vpcmodel.RouteTableRoute{newTestRoute("172.16.0.0/24", "ecs-1", routeDescription("cluster-a", "node-1"))},
+			action: "",
+		},
+		{
+			name:   "PodCIDR reused by another node",
+			routes: []vpcmodel.RouteTableRoute{newTestRoute("172.16.0.0/24", "ecs-9", routeDescription("cluster-a", "node-9"))},
+			action: routeActionMod,
+		},
+		{
+			name:     "destination used by a foreign route",
+			routes:   []vpcmodel.RouteTableRoute{newTestRoute("172.16.0.0/24", "ecs-9", routeDescription("cluster-b", "node-9"))},
+			expected: codes.AlreadyExists,
+		},
+		{
+			name:     "destination used by a manual route",
+			routes:   []vpcmodel.RouteTableRoute{newTestRoute("172.16.0.0/24", "vpn-1", "")},
+			expected: codes.AlreadyExists,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			action, err := getRouteAction(testCase.routes, expected, "cluster-a")
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+			if action != testCase.action {
+				t.Fatalf("expected: %v, got: %v", testCase.action, action)
+			}
+		})
+	}
+}
+
+func TestParseRouteTargetNode(t *testing.T) {
+	route := newTestRoute("172.16.0.0/24", "ecs-1", routeDescription("cluster-a", "node-1"))
+	if node, ok := parseRouteTargetNode(route, "cluster-a"); !ok || node != "node-1" {
+		t.Fatalf("expected: node-1, got: %v, %v", node, ok)
+	}
+	// The cluster name must match exactly, not only the prefix.
+	if node, ok := parseRouteTargetNode(route, "cluster"); ok {
+		t.Fatalf("expected the route not to be owned by cluster, got: %v", node)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+// maxTagKeyLength is the maximum length of the tag keys of the ELB instances.
+const maxTagKeyLength = 36
+
+// serviceTagger reads, adds and deletes the tags of the ELB instances.
+type serviceTagger interface {
+	resourceTagger
+	DeleteTags(id string, keys []string) error
+}
+
+// sanitizeTag replaces the characters not accepted in the tags with "_" and cuts the string to the length.
+// The keys accept letters, digits, "_" and "-", and the values accept "." as well.
+func sanitizeTag(s string, length int, allowDot bool) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r == '.' && allowDot:
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+	return utils.CutString(s, length)
+}
+
+func sanitizeTagKey(key string) string {
+	return sanitizeTag(key, maxTagKeyLength, false)
+}
+
+func sanitizeTagValue(value string) string {
+	return sanitizeTag(value, maxTagValueLength, true)
+}
+
+// getServiceTags returns the tags copied from the labels and annotations of the service by the options, and all
+// the tag keys managed by the options, including the keys that the service does not have. The labels take
+// precedence over the annotations with the same tag key, and the ownership tags are never overwritten.
+func getServiceTags(opts *config.LoadBalancerOptions, service *v1.Service) (map[string]string, map[string]bool) {
+	tags := make(map[string]string)
+	managed := make(map[string]bool)
+	copyTags := func(keys []string, values map[string]string) {
+		for _, key := range keys {
+			tagKey := sanitizeTagKey(strings.TrimSpace(key))
+			if tagKey == "" || tagKey == OwnershipTagCluster || tagKey == OwnershipTagServiceUID {
+				continue
+			}
+			managed[tagKey] = true
+			if value, ok := values[strings.TrimSpace(key)]; ok {
+				tags[tagKey] = sanitizeTagValue(value)
+			}
+		}
+	}
+	copyTags(opts.TagServiceAnnotations, service.Annotations)
+	copyTags(opts.TagServiceLabels, service.Labels)
+	return tags, managed
+}
+
+// reconcileServiceTags adds the tags which are missing or changed on the ELB instance, and deletes the managed
+// tags which the service no longer has. The tags not managed by the options are left untouched.
+func reconcileServiceTags(tagger serviceTagger, id string, expected map[string]string, managed map[string]bool) error {
+	if len(managed) == 0 {
+		return nil
+	}
+	tags, err := tagger.ListTags(id)
+	if err != nil {
+		return err
+	}
+	changed := make(map[string]string)
+	for k, v := range expected {
+		if current, ok := tags[k]; !ok || current != v {
+			changed[k] = v
+		}
+	}
+	stale := make([]string, 0)
+	for k := range tags {
+		if _, ok := expected[k]; !ok && managed[k] {
+			stale = append(stale, k)
+		}
+	}
+
+	if len(changed) > 0 {
+		klog.Infof("updating the tags of %s copied from the service: %v", id, changed)
+		if err := tagger.AddTags(id, changed); err != nil {
+			return err
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		klog.Infof("deleting the tags of %s which are removed from the service: %v", id, stale)
+		return tagger.DeleteTags(id, stale)
+	}
+	return nil
+}
+
+// ensureServiceTags copies the labels and annotations of the service to the tags of the ELB instance.
+func (b Basic) ensureServiceTags(service *v1.Service, elbID string) error {
+	tags, managed := getServiceTags(b.loadbalancerOpts, service)
+	// The tags of both shared and dedicated ELBs are managed by the v2 API.
+	if err := reconcileServiceTags(b.sharedELBClient, elbID, tags, managed); err != nil {
+		return status.Errorf(codes.Internal, "failed to update the tags of the ELB %s: %v", elbID, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+func TestSanitizeTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		value    string
+		expected [2]string
+	}{
+		{name: "valid", key: "team", value: "payments-1.0", expected: [2]string{"team", "payments-1.0"}},
+		{name: "prefixed key", key: "example.com/cost-center", value: "cc_42",
+			expected: [2]string{"example_com_cost-center", "cc_42"}},
+		{name: "spaces", key: "owner name", value: "team a/b", expected: [2]string{"owner_name", "team_a_b"}},
+		{name: "too long", key: strings.Repeat("k", 50), value: strings.Repeat("v", 50),
+			expected: [2]string{strings.Repeat("k", maxTagKeyLength), strings.Repeat("v", maxTagValueLength)}},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := [2]string{sanitizeTagKey(te.key), sanitizeTagValue(te.value)}
+			if got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetServiceTags(t *testing.T) {
+	opts := &config.LoadBalancerOptions{
+		TagServiceLabels:      []string{"team", "example.com/cost-center", OwnershipTagCluster},
+		TagServiceAnnotations: []string{"owner", "team"},
+	}
+	service := newTestService(map[string]string{"owner": "alice@example.com", "team": "annotated", "other": "x"})
+	service.Labels = map[string]string{"team": "payments", "example.com/cost-center": "cc-42", OwnershipTagCluster: "x"}
+
+	tags, managed := getServiceTags(opts, service)
+	expected := map[string]string{"team": "payments", "example_com_cost-center": "cc-42", "owner": "alice_example.com"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, tags)
+	}
+	expectedManaged := map[string]bool{"team": true, "example_com_cost-center": true, "owner": true}
+	if !reflect.DeepEqual(managed, expectedManaged) {
+		t.Fatalf("expected: %v, got: %v", expectedManaged, managed)
+	}
+}
+
+func TestReconcileServiceTags(t *testing.T) {
+	opts := &config.LoadBalancerOptions{TagServiceLabels: []string{"team", "cost-center"}}
+	tagger := &fakeTagger{tags: map[string]map[string]string{
+		"elb-1": {OwnershipTagCluster: "kubernetes", "owner": "ops"},
+	}}
+	service := newTestService(nil)
+	service.Labels = map[string]string{"team": "payments", "cost-center": "cc-42"}
+
+	reconcile := func() {
+		tags, managed := getServiceTags(opts, service)
+		if err := reconcileServiceTags(tagger, "elb-1", tags, managed); err != nil {
+			t.Fatalf("expected: %v, got: %v", nil, err)
+		}
+	}
+
+	reconcile()
+	expected := map[string]string{OwnershipTagCluster: "kubernetes", "owner": "ops", "team": "payments", "cost-center": "cc-42"}
+	if !reflect.DeepEqual(tagger.tags["elb-1"], expected) {
+		t.Fatalf("expected: %v, got: %v", expected, tagger.tags["elb-1"])
+	}
+
+	// Nothing is written if the tags are up to date.
+	reconcile()
+	if len(tagger.added) != 1 || len(tagger.deleted) != 0 {
+		t.Fatalf("expected: 1 added and 0 deleted, got: %v, %v", tagger.added, tagger.deleted)
+	}
+
+	// The changed label is updated, the removed label is deleted, and the other tags are left untouched.
+	service.Labels = map[string]string{"team": "billing"}
+	reconcile()
+	expected = map[string]string{OwnershipTagCluster: "kubernetes", "owner": "ops", "team": "billing"}
+	if !reflect.DeepEqual(tagger.tags["elb-1"], expected) {
+		t.Fatalf("expected: %v, got: %v", expected, tagger.tags["elb-1"])
+	}
+	if want := [][]string{{"cost-center"}}; !reflect.DeepEqual(tagger.deleted, want) {
+		t.Fatalf("expected: %v, got: %v", want, tagger.deleted)
+	}
+}
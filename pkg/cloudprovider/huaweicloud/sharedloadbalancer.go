@@ -20,7 +20,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -28,23 +31,24 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
-	"k8s.io/utils/pointer"
 
 	eipmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
 	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
 	elbmodelv3 "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
 
-	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
 )
 
 const (
-	defaultMaxNameLength     = 255
-	maxServerGroupNameLength = 64
+	defaultMaxNameLength             = 255
+	maxLoadBalancerDescriptionLength = 255
+	maxServerGroupNameLength         = 64
 )
 
 var (
@@ -58,6 +62,10 @@ type SharedLoadBalancer struct {
 	Basic
 }
 
+func (l *SharedLoadBalancer) withReconcile(ctx context.Context, correlationID string) cloudprovider.LoadBalancer {
+	return &SharedLoadBalancer{Basic: l.Basic.withReconcile(ctx, correlationID)}
+}
+
 func (l *SharedLoadBalancer) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
 	klog.Infof("GetLoadBalancer: called with service %s/%s", service.Namespace, service.Name)
 	loadbalancer, err := l.getLoadBalancerInstance(ctx, clusterName, service)
@@ -84,9 +92,7 @@ func (l *SharedLoadBalancer) GetLoadBalancer(ctx context.Context, clusterName st
 	}
 
 	return &corev1.LoadBalancerStatus{
-		Ingress: []corev1.LoadBalancerIngress{
-			{IP: ingressIP},
-		},
+		Ingress: buildIngress(service, ingressIP, loadbalancer.VipAddress),
 	}, true, nil
 }
 
@@ -121,6 +127,40 @@ func (l *SharedLoadBalancer) GetLoadBalancerName(_ context.Context, clusterName
 	return utils.CutString(name, defaultMaxNameLength)
 }
 
+// getLoadBalancerDescription returns the description of the ELB instance created for the service, such as
+// "Created by the ELB service(default/nginx) of the k8s cluster(kubernetes), UID: <uid>, created at <RFC3339 time>.",
+// it links the instance back to the service in the console. The UID and the time are omitted if they are not set.
+func getLoadBalancerDescription(clusterName string, service *v1.Service) string {
+	desc := fmt.Sprintf("Created by the ELB service(%s/%s) of the k8s cluster(%s)",
+		service.Namespace, service.Name, clusterName)
+	if service.UID != "" {
+		desc = fmt.Sprintf("%s, UID: %s", desc, service.UID)
+	}
+	if !service.CreationTimestamp.IsZero() {
+		desc = fmt.Sprintf("%s, created at %s", desc, service.CreationTimestamp.UTC().Format(time.RFC3339))
+	}
+	return utils.CutString(desc+".", maxLoadBalancerDescriptionLength)
+}
+
+// ensureDescription updates the description of the ELB instance if it does not match the service,
+// such as the instance is created before the description contains the UID.
+func (l *SharedLoadBalancer) ensureDescription(clusterName string, service *v1.Service,
+	loadbalancer *elbmodel.LoadbalancerResp) error {
+	desc := getLoadBalancerDescription(clusterName, service)
+	if loadbalancer.Description == desc {
+		return nil
+	}
+	klog.InfoS("Updating the description of the ELB", "operation", "UpdateInstance", "serviceName", klog.KObj(service),
+		"loadbalancerID", loadbalancer.Id, "description", desc)
+	if _, err := l.sharedELBClient.UpdateInstance(loadbalancer.Id, loadbalancer.Name, desc); err != nil {
+		logLoadBalancerError(err, "Failed to update the description of the ELB", "UpdateInstance", service,
+			loadbalancer.Id)
+		return status.Errorf(codes.Internal, "failed to update the description of the ELB %s: %v", loadbalancer.Id, err)
+	}
+	loadbalancer.Description = desc
+	return nil
+}
+
 func ensureLoadBalancerValidation(service *v1.Service, nodes []*v1.Node) error {
 	if len(nodes) == 0 {
 		return fmt.Errorf("there are no available nodes for LoadBalancer service %s/%s",
@@ -136,14 +176,47 @@ func ensureLoadBalancerValidation(service *v1.Service, nodes []*v1.Node) error {
 			"services custom endpoints are not supported")
 	}
 
+	if isInternalLoadBalancer(service) && getStringFromSvsAnnotation(service, ElbEipID, "") != "" {
+		return status.Errorf(codes.InvalidArgument, "the annotation %q cannot be used with an internal load balancer",
+			ElbEipID)
+	}
+	if hostname := getIngressHostname(service); hostname != "" {
+		if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid %q: %s", ElbHostname, strings.Join(errs, ", "))
+		}
+	}
+
 	return nil
 }
 
+// getIngressHostname returns the hostname reported in the status of the service instead of the IP, such as
+// the CNAME pointing at the EIP.
+func getIngressHostname(service *v1.Service) string {
+	return strings.TrimSpace(getStringFromSvsAnnotation(service, ElbHostname, ""))
+}
+
+// buildIngress returns the ingress of the status of the service, the hostname is reported instead of the IP
+// if it is specified, or both if kubernetes.io/elb.hostname-with-ip is true. The private VIP of the external ELB
+// is reported after the EIP, so that the in-cluster clients can reach the service through the ELB privately,
+// it is not reported if only the hostname is.
+func buildIngress(service *v1.Service, ip, vip string) []v1.LoadBalancerIngress {
+	hostname := getIngressHostname(service)
+	if hostname != "" && !getBoolFromSvsAnnotation(service, ElbHostnameWithIP, false) {
+		return []v1.LoadBalancerIngress{{Hostname: hostname}}
+	}
+	ingress := []v1.LoadBalancerIngress{{IP: ip, Hostname: hostname}}
+	if vip != "" && vip != ip {
+		ingress = append(ingress, v1.LoadBalancerIngress{IP: vip})
+	}
+	return ingress
+}
+
 // EnsureLoadBalancer creates a new load balancer 'name', or updates the existing one. Returns the status of the balancer
 //
 //nolint:gocyclo
 func (l *SharedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
-	klog.Infof("EnsureLoadBalancer: called with service %s/%s, node: %d", service.Namespace, service.Name, len(nodes))
+	klog.InfoS("Load balancer API is called", "operation", "EnsureLoadBalancer", "serviceName", klog.KObj(service),
+		"nodes", len(nodes))
 	if !l.isSupportedSvc(service) {
 		return nil, cloudprovider.ImplementedElsewhere
 	}
@@ -151,6 +224,25 @@ func (l *SharedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName
 	if err := ensureLoadBalancerValidation(service, nodes); err != nil {
 		return nil, err
 	}
+	if _, err := parseHealthCheckPort(service); err != nil {
+		return nil, err
+	}
+	// The listeners of shared ELB do not support the PROXY protocol.
+	if _, ok := getAnnotation(service.Annotations, ElbProxyProtocol); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "%q is only supported by dedicated load balancers",
+			ElbProxyProtocol)
+	}
+	for _, port := range service.Spec.Ports {
+		if _, err := parseInsertHeaders(service, parseProtocol(service, port)); err != nil {
+			return nil, err
+		}
+		if _, err := parseSniContainerRefs(service, parseProtocol(service, port)); err != nil {
+			return nil, err
+		}
+		if _, _, err := parseL7Timeouts(service, parseProtocol(service, port), l.loadbalancerOpts); err != nil {
+			return nil, err
+		}
+	}
 
 	// get exits or create a new ELB instance
 	loadbalancer, err := l.getLoadBalancerInstance(ctx, clusterName, service)
@@ -169,6 +261,14 @@ func (l *SharedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName
 	if err != nil {
 		return nil, err
 	}
+	if specifiedID == "" {
+		if err = l.ensureDescription(clusterName, service, loadbalancer); err != nil {
+			return nil, err
+		}
+		if err = l.ensureServiceTags(service, loadbalancer.Id); err != nil {
+			return nil, err
+		}
+	}
 
 	// query ELB listeners list
 	listeners, err := l.sharedELBClient.ListListeners(&elbmodel.ListListenersRequest{LoadbalancerId: &loadbalancer.Id})
@@ -176,20 +276,38 @@ func (l *SharedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName
 		return nil, err
 	}
 
-	for _, port := range service.Spec.Ports {
-		listener := l.filterListenerByPort(listeners, service, port)
+	keys := make([]listenerKey, 0, len(listeners))
+	for _, lis := range listeners {
+		keys = append(keys, listenerKey{
+			ID:          lis.Id,
+			Name:        lis.Name,
+			Description: lis.Description,
+			Protocol:    lis.Protocol.Value(),
+			Port:        lis.ProtocolPort,
+		})
+	}
+
+	for _, op := range planListenerOperations(keys, service, specifiedID == "") {
+		if op.action == listenerActionDelete {
+			if err = l.deleteListeners(loadbalancer.Id, filterListenerRespByID(listeners, op.listenerIDs)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		port := op.port
+		var listener *elbmodel.ListenerResp
 		// add or update listener
-		if listener == nil {
+		if op.action == listenerActionCreate {
 			listener, err = l.createListener(loadbalancer.Id, service, port)
 		} else {
+			listener = &filterListenerRespByID(listeners, op.listenerIDs)[0]
 			err = l.updateListener(listener, service)
 		}
 		if err != nil {
 			return nil, err
 		}
 
-		listeners = popListener(listeners, listener.Id)
-
 		// query pool or create pool
 		pool, err := l.getPool(loadbalancer.Id, listener.Id)
 		if err != nil && common.IsNotFound(err) {
@@ -210,15 +328,16 @@ func (l *SharedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName
 		}
 	}
 
-	if specifiedID == "" {
-		// All remaining listeners are obsolete, delete them
-		err = l.deleteListeners(loadbalancer.Id, listeners)
-		if err != nil {
+	ingressIP := loadbalancer.VipAddress
+	if isInternalLoadBalancer(service) {
+		if err = l.releaseEIP(loadbalancer, service); err != nil {
 			return nil, err
 		}
+		return &corev1.LoadBalancerStatus{
+			Ingress: buildIngress(service, ingressIP, loadbalancer.VipAddress),
+		}, nil
 	}
 
-	ingressIP := loadbalancer.VipAddress
 	publicIPAddr, err := l.createOrAssociateEIP(loadbalancer, service)
 	if err == nil {
 		if publicIPAddr != "" {
@@ -226,17 +345,18 @@ func (l *SharedLoadBalancer) EnsureLoadBalancer(ctx context.Context, clusterName
 		}
 
 		return &corev1.LoadBalancerStatus{
-			Ingress: []corev1.LoadBalancerIngress{{IP: ingressIP}},
+			Ingress: buildIngress(service, ingressIP, loadbalancer.VipAddress),
 		}, nil
 	}
 
 	// rollback
-	klog.Errorf("rollback：failed to create the EIP, delete ELB instance created, error: %s", err)
+	logLoadBalancerError(err, "Failed to create the EIP, rolling back the ELB", "CreateInstance", service,
+		loadbalancer.Id)
 	errs := []error{err}
 	err = l.EnsureLoadBalancerDeleted(ctx, clusterName, service)
 	if err != nil {
 		errs = append(errs, err)
-		klog.Errorf("rollback: error deleting ELB instance: %s", err)
+		logLoadBalancerError(err, "Failed to roll back the ELB", "DeleteInstance", service, loadbalancer.Id)
 	}
 	return nil, errors.NewAggregate(errs)
 }
@@ -251,7 +371,7 @@ func (l *SharedLoadBalancer) createOrAssociateEIP(loadbalancer *elbmodel.Loadbal
 
 	eipID := getStringFromSvsAnnotation(service, ElbEipID, "")
 	if eipID == "" {
-		opts, err := parseEIPAutoCreateOptions(service)
+		opts, err := parseEIPAutoCreateOptions(service, &l.loadbalancerOpts.EIPAutoCreateOption)
 		if err != nil || opts == nil {
 			return "", err
 		}
@@ -307,6 +427,21 @@ func (l *SharedLoadBalancer) createOrAssociateEIP(loadbalancer *elbmodel.Loadbal
 	return getEipAddress(eip)
 }
 
+// releaseEIP unbinds the EIP from the internal load balancer, it was bound when the load balancer was external.
+func (l *SharedLoadBalancer) releaseEIP(loadbalancer *elbmodel.LoadbalancerResp, service *v1.Service) error {
+	if getStringFromSvsAnnotation(service, ElbID, "") != "" {
+		klog.Infof("the ELB %s is specified by the service %s/%s, skip releasing the EIP",
+			loadbalancer.Id, service.Namespace, service.Name)
+		return nil
+	}
+
+	if err := unbindEIP(l.eipClient, loadbalancer.VipPortId, "", isEIPKept(service, l.loadbalancerOpts)); err != nil {
+		return status.Errorf(codes.Internal, "failed to release the EIP of the internal ELB %s: %s",
+			loadbalancer.Id, err)
+	}
+	return nil
+}
+
 func getEipAddress(eip *eipmodel.PublicipShowResp) (string, error) {
 	if eip.PublicIpAddress == nil {
 		return "", status.Errorf(codes.Internal, "rollback: error EIP address is empty, delete ELB instance")
@@ -317,18 +452,20 @@ func getEipAddress(eip *eipmodel.PublicipShowResp) (string, error) {
 func (l *SharedLoadBalancer) createLoadbalancer(clusterName, subnetID string, service *v1.Service) (*elbmodel.LoadbalancerResp, error) {
 	name := l.GetLoadBalancerName(context.TODO(), clusterName, service)
 	provider := elbmodel.GetCreateLoadbalancerReqProviderEnum().VLB
-	desc := fmt.Sprintf("Created by the ELB service(%s/%s) of the k8s cluster(%s).",
-		service.Namespace, service.Name, clusterName)
-	loadbalancer, err := l.sharedELBClient.CreateInstanceCompleted(&elbmodel.CreateLoadbalancerReq{
+	desc := getLoadBalancerDescription(clusterName, service)
+	id, err := createSharedLoadBalancerOnce(l.sharedELBClient, &elbmodel.CreateLoadbalancerReq{
 		Name:        &name,
 		VipSubnetId: subnetID,
 		Provider:    &provider,
 		Description: &desc,
 	})
 	if err != nil {
+		logLoadBalancerError(err, "Failed to create the ELB", "CreateInstance", service, "")
 		return nil, err
 	}
-	return loadbalancer, nil
+	klog.InfoS("Created the ELB", "operation", "CreateInstance", "serviceName", klog.KObj(service),
+		"loadbalancerID", id)
+	return l.sharedELBClient.WaitStatusActive(id)
 }
 
 // ensureHealthCheck add or update or remove health check
@@ -336,18 +473,18 @@ func (l *SharedLoadBalancer) ensureHealthCheck(loadbalancerID string, pool *elbm
 	port v1.ServicePort, service *v1.Service, node *v1.Node) error {
 	healthCheckOpts := getHealthCheckOptionFromAnnotation(service, l.loadbalancerOpts)
 	monitorID := pool.HealthmonitorId
-	klog.Infof("add or update or remove health check: %s : %#v", monitorID, healthCheckOpts)
+	klog.V(4).Infof("add or update or remove health check: %s : %#v", monitorID, healthCheckOpts)
 
 	protocolStr := parseProtocol(service, port)
 	// create health monitor
 	if monitorID == "" && healthCheckOpts.Enable {
-		_, err := l.createHealthMonitor(loadbalancerID, pool.Id, protocolStr, healthCheckOpts)
+		_, err := l.createHealthMonitor(loadbalancerID, pool.Id, protocolStr, service, port, healthCheckOpts)
 		return err
 	}
 
 	// update health monitor
 	if monitorID != "" && healthCheckOpts.Enable {
-		return l.updateHealthMonitor(monitorID, protocolStr, healthCheckOpts)
+		return updateSharedHealthMonitor(l.sharedELBClient, monitorID, protocolStr, service, port, healthCheckOpts)
 	}
 
 	// delete health monitor
@@ -362,45 +499,59 @@ func (l *SharedLoadBalancer) ensureHealthCheck(loadbalancerID string, pool *elbm
 	return nil
 }
 
-func (l *SharedLoadBalancer) updateHealthMonitor(id, protocol string, opts *config.HealthCheckOption) error {
+// sharedHealthMonitorUpdater updates the health monitors of the shared ELB pools.
+type sharedHealthMonitorUpdater interface {
+	UpdateHealthMonitor(id string, req *elbmodel.UpdateHealthmonitorReq) error
+}
+
+// updateSharedHealthMonitor updates the health monitor in place, including the port and the URL path it checks,
+// the same as updateDedicatedHealthMonitor.
+func updateSharedHealthMonitor(client sharedHealthMonitorUpdater, id, protocol string, service *v1.Service,
+	svcPort v1.ServicePort, opts *config.HealthCheckOption) error {
 	if protocol == ProtocolHTTPS || protocol == ProtocolTerminatedHTTPS {
 		protocol = ProtocolHTTP
 	} else if protocol == ProtocolUDP {
 		protocol = ""
 	}
 
+	target := getHealthMonitorTarget(service, svcPort, protocol, opts)
 	updateOpts := elbmodel.UpdateHealthmonitorReq{
-		Timeout:    &opts.Timeout,
-		Delay:      &opts.Delay,
-		MaxRetries: &opts.MaxRetries,
+		Timeout:     &opts.Timeout,
+		Delay:       &opts.Delay,
+		MaxRetries:  &opts.MaxRetries,
+		MonitorPort: target.portPtr(),
+		UrlPath:     target.pathPtr(),
 	}
 
-	if protocol != "" {
-		updateOpts.Type = &protocol
+	if target.protocol != "" {
+		updateOpts.Type = &target.protocol
 	}
 
-	return l.sharedELBClient.UpdateHealthMonitor(id, &updateOpts)
+	return client.UpdateHealthMonitor(id, &updateOpts)
 }
 
-func (l *SharedLoadBalancer) createHealthMonitor(loadbalancerID, poolID, protocol string,
-	opts *config.HealthCheckOption) (*elbmodel.HealthmonitorResp, error) {
+func (l *SharedLoadBalancer) createHealthMonitor(loadbalancerID, poolID, protocol string, service *v1.Service,
+	svcPort v1.ServicePort, opts *config.HealthCheckOption) (*elbmodel.HealthmonitorResp, error) {
 	if protocol == ProtocolHTTPS || protocol == ProtocolTerminatedHTTPS {
 		protocol = ProtocolHTTP
 	} else if protocol == ProtocolUDP {
 		protocol = "UDP_CONNECT"
 	}
 
+	target := getHealthMonitorTarget(service, svcPort, protocol, opts)
 	protocolType := elbmodel.CreateHealthmonitorReqType{}
-	if err := protocolType.UnmarshalJSON([]byte(protocol)); err != nil {
+	if err := protocolType.UnmarshalJSON([]byte(target.protocol)); err != nil {
 		return nil, err
 	}
 
 	monitor, err := l.sharedELBClient.CreateHealthMonitor(&elbmodel.CreateHealthmonitorReq{
-		PoolId:     poolID,
-		Type:       protocolType,
-		Timeout:    opts.Timeout,
-		Delay:      opts.Delay,
-		MaxRetries: opts.MaxRetries,
+		PoolId:      poolID,
+		Type:        protocolType,
+		Timeout:     opts.Timeout,
+		Delay:       opts.Delay,
+		MaxRetries:  opts.MaxRetries,
+		MonitorPort: target.portPtr(),
+		UrlPath:     target.pathPtr(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating SharedLoadBalancer pool health monitor: %v", err)
@@ -431,6 +582,8 @@ func (l *SharedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.Loadbalan
 	for _, node := range nodes {
 		nodeNameMapping[node.Name] = node
 	}
+	weights := drainCordonedNodes(getMemberWeights(nodes, l.loadbalancerOpts.MemberWeightResource), nodes,
+		l.loadbalancerOpts.DrainCordonedNodes)
 
 	podList, err := l.listPodsBySelector(context.TODO(), service.Namespace, service.Spec.Selector)
 	if err != nil {
@@ -449,8 +602,10 @@ func (l *SharedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.Loadbalan
 
 		node, ok := nodeNameMapping[pod.Spec.NodeName]
 		if !ok {
-			return fmt.Errorf("could not find the node where the Pod resides, Pod: %s/%s",
-				pod.Namespace, pod.Spec.NodeName)
+			// The node is excluded from the load balancers, or it is not ready.
+			klog.V(4).Infof("Pod %s/%s resides on node %s which is not a backend, skipping adding to ELB",
+				pod.Namespace, pod.Name, pod.Spec.NodeName)
+			continue
 		}
 
 		// address, err := getNodeAddress(node)
@@ -459,6 +614,8 @@ func (l *SharedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.Loadbalan
 			if common.IsNotFound(err) {
 				// Node failure, do not create member
 				klog.Warningf("Failed to create SharedLoadBalancer pool member for node %s: %v", node.Name, err)
+				l.sendWarningEvent("SkipLoadBalancerMember",
+					fmt.Sprintf("Skip adding node %s to the pool %s: %s", node.Name, pool.Name, err), service)
 				continue
 			} else {
 				return fmt.Errorf("error getting address for node %s: %v", node.Name, err)
@@ -469,6 +626,10 @@ func (l *SharedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.Loadbalan
 		if existsMember[key] {
 			klog.Infof("[addOrRemoveMembers] node already exists, skip adding, name: %s, address: %s, port: %d",
 				node.Name, address, portNum)
+			if err = l.updateMemberWeight(loadbalancer.Id, pool.Id, members, address, portNum,
+				getMemberWeight(weights, node)); err != nil {
+				return err
+			}
 			members = popMember(members, address, portNum)
 			continue
 		}
@@ -476,7 +637,7 @@ func (l *SharedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.Loadbalan
 		klog.Infof("[addOrRemoveMembers] add node to pool, name: %s, address: %s, port: %d",
 			node.Name, address, portNum)
 		// Add a member to the pool.
-		if err = l.addMember(service, loadbalancer.Id, pool.Id, svcPort, pod, node); err != nil {
+		if err = l.addMember(service, loadbalancer.Id, pool.Id, svcPort, pod, node, getMemberWeight(weights, node)); err != nil {
 			return err
 		}
 		existsMember[key] = true
@@ -496,48 +657,34 @@ func (l *SharedLoadBalancer) addOrRemoveMembers(loadbalancer *elbmodel.Loadbalan
 }
 
 func (l *SharedLoadBalancer) getMemberIP(service *v1.Service, node *v1.Node, pod v1.Pod, svcPort v1.ServicePort) (string, int32, error) {
-	if service.Spec.AllocateLoadBalancerNodePorts != nil && *service.Spec.AllocateLoadBalancerNodePorts {
-		klog.Infof("add member using the Node's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-
-		address := ""
-		if pod.Status.HostIP != "" {
-			address = pod.Status.HostIP
-		} else {
-			addr, err := getNodeAddress(node)
-			if err != nil {
-				return "", 0, err
-			}
-			address = addr
-		}
+	if isPodTargeted(service) {
+		klog.Infof("add member using the Pod's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
+		return getPodMemberAddress(pod, svcPort)
+	}
 
-		address, err := l.getPrimaryIP(address)
+	klog.Infof("add member using the Node's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
+	if cidr := getBackendSubnetCIDR(service, node); cidr != "" {
+		address, err := getNodeAddressInCIDR(node, cidr)
 		if err != nil {
 			return "", 0, err
 		}
 		return address, svcPort.NodePort, nil
 	}
 
-	if service.Spec.AllocateLoadBalancerNodePorts != nil && !*service.Spec.AllocateLoadBalancerNodePorts {
-		klog.Infof("add member using the Pod's IP and port, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-		// get IP and port from Pod
-		if svcPort.TargetPort.Type == intstr.Int {
-			klog.V(6).Infof("targetPort is a number, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-			return pod.Status.PodIP, svcPort.TargetPort.IntVal, nil
-		}
-
-		klog.V(6).Infof("targetPort is a name, service: %s/%s, port: %s ", service.Namespace, service.Name, svcPort.Name)
-		for _, c := range pod.Spec.Containers {
-			for _, p := range c.Ports {
-				if p.Name == svcPort.TargetPort.StrVal && string(p.Protocol) == string(svcPort.Protocol) {
-					return pod.Status.PodIP, p.ContainerPort, nil
-				}
-			}
-		}
+	address, err := getNodeMemberAddress(node, pod.Status.HostIP)
+	if err != nil {
+		return "", 0, err
 	}
-	return "", 0, fmt.Errorf("not found member IP and port")
+
+	address, err = l.getPrimaryIP(address)
+	if err != nil {
+		return "", 0, err
+	}
+	return address, svcPort.NodePort, nil
 }
 
-func (l *SharedLoadBalancer) addMember(service *v1.Service, elbID, poolID string, svcPort v1.ServicePort, pod v1.Pod, node *v1.Node) error {
+func (l *SharedLoadBalancer) addMember(service *v1.Service, elbID, poolID string, svcPort v1.ServicePort, pod v1.Pod,
+	node *v1.Node, weight *int32) error {
 	klog.Infof("Add a member(%s) to pool %s", node.Name, poolID)
 	address, port, err := l.getMemberIP(service, node, pod, svcPort)
 	if err != nil {
@@ -553,6 +700,7 @@ func (l *SharedLoadBalancer) addMember(service *v1.Service, elbID, poolID string
 		ProtocolPort: port,
 		SubnetId:     subnetID,
 		Address:      address,
+		Weight:       weight,
 	}
 	_, err = l.sharedELBClient.AddMember(poolID, &req)
 	if err != nil {
@@ -569,6 +717,31 @@ func (l *SharedLoadBalancer) addMember(service *v1.Service, elbID, poolID string
 	return nil
 }
 
+// updateMemberWeight updates the weight of the existing member if it differs, nil weight leaves it unchanged.
+func (l *SharedLoadBalancer) updateMemberWeight(elbID, poolID string, members []elbmodel.MemberResp, addr string,
+	port int32, weight *int32) error {
+	if weight == nil {
+		return nil
+	}
+	for _, m := range members {
+		if m.Address != addr || m.ProtocolPort != port || m.Weight == *weight {
+			continue
+		}
+		klog.Infof("[addOrRemoveMembers] update the weight of member %s from %d to %d, address: %s, port: %d",
+			m.Id, m.Weight, *weight, addr, port)
+		if _, err := l.sharedELBClient.UpdateMember(poolID, m.Id,
+			&elbmodel.UpdateMemberReq{Weight: weight}); err != nil {
+			return fmt.Errorf("error updating the weight of member %s for pool %s: %v", m.Id, poolID, err)
+		}
+		loadbalancer, err := l.sharedELBClient.WaitStatusActive(elbID)
+		if err != nil {
+			return fmt.Errorf("timeout when waiting for loadbalancer to be ACTIVE after updating member, "+
+				"current provisioning status %s", loadbalancer.ProvisioningStatus)
+		}
+	}
+	return nil
+}
+
 func (l *SharedLoadBalancer) deleteMember(elbID string, poolID string, member elbmodel.MemberResp) error {
 	klog.V(4).Infof("Deleting obsolete member %s for pool %s address %s", member.Id, poolID, member.Address)
 	err := l.sharedELBClient.DeleteMember(poolID, member.Id)
@@ -673,37 +846,51 @@ func popMember(members []elbmodel.MemberResp, addr string, port int32) []elbmode
 	return members
 }
 
-func popListener(arr []elbmodel.ListenerResp, id string) []elbmodel.ListenerResp {
-	for i, lis := range arr {
-		if lis.Id == id {
-			arr[i] = arr[len(arr)-1]
-			arr = arr[:len(arr)-1]
-			break
+func filterListenerRespByID(listeners []elbmodel.ListenerResp, ids []string) []elbmodel.ListenerResp {
+	rst := make([]elbmodel.ListenerResp, 0, len(ids))
+	for _, id := range ids {
+		for _, lis := range listeners {
+			if lis.Id == id {
+				rst = append(rst, lis)
+				break
+			}
 		}
 	}
-	return arr
+	return rst
 }
 
+// deleteListeners deletes the listeners and their pools, members and health monitors tier by tier as the dedicated
+// load balancer does, the deletes of each tier run concurrently, up to "delete-concurrency" at a time, and are
+// retried while the ELB instance is being updated by the other deletes.
 func (l *SharedLoadBalancer) deleteListeners(elbID string, listeners []elbmodel.ListenerResp) error {
 	errs := make([]error, 0)
+	var members, monitors, pools, listenerTasks []deleteTask
+	deletingPools := sets.NewString()
 	for _, lis := range listeners {
+		lis := lis
+		group := lis.Id
 		pool, err := l.getPool(elbID, lis.Id)
 		if err != nil && !common.IsNotFound(err) {
 			errs = append(errs, err)
 			continue
 		}
 		if err == nil {
-			delErrs := l.deletePool(pool)
-			if len(delErrs) > 0 {
-				errs = append(errs, delErrs...)
+			// The listeners are deleted after the pool they use, the pool is deleted only once.
+			group = pool.Id
+			if !deletingPools.Has(pool.Id) {
+				deletingPools.Insert(pool.Id)
+				members, monitors, pools = l.appendPoolDeleteTasks(elbID, pool, members, monitors, pools)
 			}
 		}
-		// delete ELB listener
-		if err = l.sharedELBClient.DeleteListener(elbID, lis.Id); err != nil && !common.IsNotFound(err) {
-			errs = append(errs, fmt.Errorf("failed to delete ELB listener %s : %s ", lis.Id, err))
-		}
+		listenerTasks = append(listenerTasks, l.newDeleteTask(elbID, group, "ELB listener "+lis.Id, func() error {
+			return l.sharedELBClient.DeleteListener(elbID, lis.Id)
+		}))
 	}
 
+	if err := runDeleteTiers(l.loadbalancerOpts.DeleteConcurrency, members, monitors, pools,
+		listenerTasks); err != nil {
+		errs = append(errs, err)
+	}
 	if len(errs) != 0 {
 		return fmt.Errorf("failed to delete listeners: %s", errors.NewAggregate(errs))
 	}
@@ -711,21 +898,44 @@ func (l *SharedLoadBalancer) deleteListeners(elbID string, listeners []elbmodel.
 	return nil
 }
 
-func (l *SharedLoadBalancer) deletePool(pool *elbmodel.PoolResp) []error {
-	errs := make([]error, 0)
-	// delete all members of pool
-	if err := l.sharedELBClient.DeleteAllPoolMembers(pool.Id); err != nil {
-		errs = append(errs, err)
-	}
-	// delete the pool monitor if exists
-	if err := l.sharedELBClient.DeleteHealthMonitor(pool.HealthmonitorId); err != nil && !common.IsNotFound(err) {
-		errs = append(errs, err)
-	}
-	// delete ELB listener pool
-	if err := l.sharedELBClient.DeletePool(pool.Id); err != nil && !common.IsNotFound(err) {
-		errs = append(errs, err)
-	}
-	return errs
+// appendPoolDeleteTasks appends the tasks deleting the members, the health monitor and the pool itself to the
+// tiers, they are grouped by the pool.
+func (l *SharedLoadBalancer) appendPoolDeleteTasks(elbID string, pool *elbmodel.PoolResp, members, monitors,
+	pools []deleteTask) ([]deleteTask, []deleteTask, []deleteTask) {
+	members = append(members, l.newDeleteTask(elbID, pool.Id, "members of pool "+pool.Id, func() error {
+		return l.sharedELBClient.DeleteAllPoolMembers(pool.Id)
+	}))
+	if pool.HealthmonitorId != "" {
+		monitors = append(monitors, l.newDeleteTask(elbID, pool.Id, "health monitor "+pool.HealthmonitorId,
+			func() error {
+				return l.sharedELBClient.DeleteHealthMonitor(pool.HealthmonitorId)
+			}))
+	}
+	pools = append(pools, l.newDeleteTask(elbID, pool.Id, "pool "+pool.Id, func() error {
+		return l.sharedELBClient.DeletePool(pool.Id)
+	}))
+	return members, monitors, pools
+}
+
+// newDeleteTask returns the task deleting a resource of the listener or its pool, the delete is retried if the ELB
+// instance is not ready, such as being updated by the other deletes of the tier.
+func (l *SharedLoadBalancer) newDeleteTask(elbID, group, name string, del func() error) deleteTask {
+	return deleteTask{group: group, name: name, run: func() error {
+		return retryOnNotReady(l.reconcileContext(), sharedProvisioningGetter(l.sharedELBClient.GetInstance), elbID,
+			l.loadbalancerOpts.ProvisioningRetries, provisioningBackoff, del)
+	}}
+}
+
+// sharedProvisioningGetter returns the getter polling the provisioning status of the shared ELB instance for
+// retryOnNotReady.
+func sharedProvisioningGetter(get func(id string) (*elbmodel.LoadbalancerResp, error)) loadBalancerGetter {
+	return loadBalancerGetterFunc(func(id string) (*elbmodelv3.LoadBalancer, error) {
+		instance, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+		return &elbmodelv3.LoadBalancer{Id: instance.Id, ProvisioningStatus: instance.ProvisioningStatus.Value()}, nil
+	})
 }
 
 func (l *SharedLoadBalancer) createListener(loadbalancerID string, service *v1.Service, port v1.ServicePort) (
@@ -734,7 +944,6 @@ func (l *SharedLoadBalancer) createListener(loadbalancerID string, service *v1.S
 	createOpt := &elbmodelv3.CreateListenerOption{
 		LoadbalancerId: loadbalancerID,
 		ProtocolPort:   port.Port,
-		InsertHeaders:  &elbmodelv3.ListenerInsertHeaders{XForwardedHost: &xForwardFor},
 	}
 
 	protocol := parseProtocol(service, port)
@@ -745,22 +954,32 @@ func (l *SharedLoadBalancer) createListener(loadbalancerID string, service *v1.S
 		protocol = ProtocolHTTP
 	}
 	createOpt.Protocol = protocol
-	name := utils.CutString(fmt.Sprintf("%s_%s_%v", service.Name, protocol, port.Port), defaultMaxNameLength)
+	insertHeaders, err := parseInsertHeaders(service, protocol)
+	if err != nil {
+		return nil, err
+	}
+	createOpt.InsertHeaders = insertHeaders
+	createOpt.AdminStateUp = getAdminStateUp(service)
+	sniContainerRefs, err := parseSniContainerRefs(service, protocol)
+	if err != nil {
+		return nil, err
+	}
+	createOpt.SniContainerRefs = sniContainerRefs
+	name := getListenerName(service, protocol, port.Port)
+	description := getListenerDescription(service, port)
 	createOpt.Name = &name
+	createOpt.Description = &description
 
 	// Set timeout parameters
 	globalOpts := l.loadbalancerOpts
-	if timeout := getIntFromSvsAnnotation(service, ElbIdleTimeout, globalOpts.IdleTimeout); timeout != 0 {
-		createOpt.KeepaliveTimeout = pointer.Int32(int32(timeout))
+	createOpt.KeepaliveTimeout, err = parseKeepaliveTimeout(service, protocol, globalOpts)
+	if err != nil {
+		return nil, err
 	}
 
-	if protocol == ProtocolHTTP || protocol == ProtocolTerminatedHTTPS {
-		if timeout := getIntFromSvsAnnotation(service, ElbRequestTimeout, globalOpts.RequestTimeout); timeout != 0 {
-			createOpt.ClientTimeout = pointer.Int32(int32(timeout))
-		}
-		if timeout := getIntFromSvsAnnotation(service, ElbResponseTimeout, globalOpts.ResponseTimeout); timeout != 0 {
-			createOpt.MemberTimeout = pointer.Int32(int32(timeout))
-		}
+	createOpt.ClientTimeout, createOpt.MemberTimeout, err = parseL7Timeouts(service, protocol, globalOpts)
+	if err != nil {
+		return nil, err
 	}
 
 	if protocol == ProtocolTCP || protocol == ProtocolUDP {
@@ -780,26 +999,33 @@ func (l *SharedLoadBalancer) createListener(loadbalancerID string, service *v1.S
 }
 
 func (l *SharedLoadBalancer) updateListener(listener *elbmodel.ListenerResp, service *v1.Service) error {
-	name := fmt.Sprintf("%s_%s_%v", service.Name, listener.Protocol.Value(), listener.ProtocolPort)
-	name = utils.CutString(name, defaultMaxNameLength)
-	xForwardFor := getBoolFromSvsAnnotation(service, ElbXForwardedHost, false)
+	name := getListenerName(service, listener.Protocol.Value(), listener.ProtocolPort)
+	description := getListenerDescription(service, v1.ServicePort{Port: listener.ProtocolPort})
+	insertHeaders, err := parseInsertHeaders(service, listener.Protocol.Value())
+	if err != nil {
+		return err
+	}
+	sniContainerRefs, err := parseSniContainerRefs(service, listener.Protocol.Value())
+	if err != nil {
+		return err
+	}
 	updateOpt := &elbmodelv3.UpdateListenerOption{
-		Name:          &name,
-		InsertHeaders: &elbmodelv3.ListenerInsertHeaders{XForwardedHost: &xForwardFor},
+		Name:             &name,
+		Description:      &description,
+		InsertHeaders:    insertHeaders,
+		AdminStateUp:     getAdminStateUp(service),
+		SniContainerRefs: sniContainerRefs,
 	}
 
 	// Set timeout parameters
 	globalOpts := l.loadbalancerOpts
-	if timeout := getIntFromSvsAnnotation(service, ElbIdleTimeout, globalOpts.IdleTimeout); timeout != 0 {
-		updateOpt.KeepaliveTimeout = pointer.Int32(int32(timeout))
+	updateOpt.KeepaliveTimeout, err = parseKeepaliveTimeout(service, listener.Protocol.Value(), globalOpts)
+	if err != nil {
+		return err
 	}
-	if listener.Protocol.Value() == ProtocolHTTP || listener.Protocol.Value() == ProtocolTerminatedHTTPS {
-		if timeout := getIntFromSvsAnnotation(service, ElbRequestTimeout, globalOpts.RequestTimeout); timeout != 0 {
-			updateOpt.ClientTimeout = pointer.Int32(int32(timeout))
-		}
-		if timeout := getIntFromSvsAnnotation(service, ElbResponseTimeout, globalOpts.ResponseTimeout); timeout != 0 {
-			updateOpt.MemberTimeout = pointer.Int32(int32(timeout))
-		}
+	updateOpt.ClientTimeout, updateOpt.MemberTimeout, err = parseL7Timeouts(service, listener.Protocol.Value(), globalOpts)
+	if err != nil {
+		return err
 	}
 
 	if listener.Protocol.Value() == ProtocolTCP || listener.Protocol.Value() == ProtocolUDP {
@@ -809,7 +1035,7 @@ func (l *SharedLoadBalancer) updateListener(listener *elbmodel.ListenerResp, ser
 		updateOpt.TransparentClientIpEnable = &transparentClientIPEnable
 	}
 
-	err := l.dedicatedELBClient.UpdateListener(listener.Id, updateOpt)
+	err = l.dedicatedELBClient.UpdateListener(listener.Id, updateOpt)
 	if err != nil {
 		return err
 	}
@@ -873,7 +1099,8 @@ func convertToListenerV2(listener *elbmodelv3.Listener) (*elbmodel.ListenerResp,
 func (l *SharedLoadBalancer) filterListenerByPort(listeners []elbmodel.ListenerResp, service *v1.Service, port v1.ServicePort) *elbmodel.ListenerResp {
 	protocol := parseProtocol(service, port)
 	for _, listener := range listeners {
-		if listener.Protocol.Value() == protocol && listener.ProtocolPort == port.Port {
+		if listener.Protocol.Value() == protocol && listener.ProtocolPort == port.Port &&
+			!isListenerOfOtherService(service, listener.Description) {
 			return &listener
 		}
 	}
@@ -883,10 +1110,15 @@ func (l *SharedLoadBalancer) filterListenerByPort(listeners []elbmodel.ListenerR
 
 // UpdateLoadBalancer updates hosts under the specified load balancer.
 func (l *SharedLoadBalancer) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
-	klog.Infof("UpdateLoadBalancer: called with service %s/%s, node: %d", service.Namespace, service.Name, len(nodes))
+	klog.InfoS("Load balancer API is called", "operation", "UpdateLoadBalancer", "serviceName", klog.KObj(service),
+		"nodes", len(nodes))
 	if !l.isSupportedSvc(service) {
 		return cloudprovider.ImplementedElsewhere
 	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("there are no available nodes for LoadBalancer service %s/%s",
+			service.Namespace, service.Name)
+	}
 
 	// get exits or create a new ELB instance
 	loadbalancer, err := l.getLoadBalancerInstance(ctx, clusterName, service)
@@ -931,7 +1163,8 @@ func (l *SharedLoadBalancer) UpdateLoadBalancer(ctx context.Context, clusterName
 
 // EnsureLoadBalancerDeleted deletes the specified load balancer
 func (l *SharedLoadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
-	klog.Infof("EnsureLoadBalancerDeleted: called with service %s/%s", service.Namespace, service.Name)
+	klog.InfoS("Load balancer API is called", "operation", "EnsureLoadBalancerDeleted",
+		"serviceName", klog.KObj(service), "clusterName", clusterName)
 
 	loadBalancer, err := l.getLoadBalancerInstance(ctx, clusterName, service)
 	if err != nil {
@@ -989,22 +1222,75 @@ func (l *SharedLoadBalancer) deleteELBInstance(loadBalancer *elbmodel.Loadbalanc
 		return err
 	}
 
+	keys := make([]listenerKey, 0, len(listenerArr))
+	for _, lis := range listenerArr {
+		keys = append(keys, listenerKey{ID: lis.Id, Name: lis.Name, Description: lis.Description})
+	}
+	if err = checkDeletionProtection(service, keys, l.cloudConfig.AuthOpts.ForceDeleteELB); err != nil {
+		klog.InfoS("Skipped deleting the ELB, only the listeners of the service are deleted",
+			"operation", "DeleteInstance", "serviceName", klog.KObj(service), "loadbalancerID", loadBalancer.Id,
+			"reason", err.Error())
+		return l.deleteListener(loadBalancer, service)
+	}
+
 	if err = l.deleteListeners(loadBalancer.Id, listenerArr); err != nil {
 		return err
 	}
 
 	eipID := getStringFromSvsAnnotation(service, ElbEipID, "")
-	keepEip := getBoolFromSvsAnnotation(service, ELBKeepEip, l.loadbalancerOpts.KeepEIP)
-	if err = unbindEIP(l.eipClient, loadBalancer.VipPortId, eipID, keepEip); err != nil {
+	if err = unbindEIP(l.eipClient, loadBalancer.VipPortId, eipID, isEIPKept(service, l.loadbalancerOpts)); err != nil {
 		return err
 	}
-	if err = l.sharedELBClient.DeleteInstance(loadBalancer.Id); err != nil {
+	klog.InfoS("Deleting the ELB", "operation", "DeleteInstance", "serviceName", klog.KObj(service),
+		"loadbalancerID", loadBalancer.Id)
+	if err = l.sharedELBClient.DeleteInstance(loadBalancer.Id); err != nil && !common.IsNotFound(err) {
+		logLoadBalancerError(err, "Failed to delete the ELB", "DeleteInstance", service, loadBalancer.Id)
 		return err
 	}
 	return nil
 }
 
-func unbindEIP(eipClient *wrapper.EIpClient, vipPortID, eipID string, keepEIP bool) error {
+// logLoadBalancerError logs the failed ELB API call of a service, the error code and the request ID
+// of the API call are logged as discrete keys as logInstanceError does.
+func logLoadBalancerError(err error, msg, operation string, service *v1.Service, loadbalancerID string) {
+	keysAndValues := []interface{}{"operation", operation, "serviceName", klog.KObj(service),
+		"loadbalancerID", loadbalancerID}
+	klog.ErrorS(err, msg, append(keysAndValues, common.ErrorKeysAndValues(err)...)...)
+}
+
+// checkDeletionProtection returns an error if the ELB instance still has listeners that are not created by
+// the service, the ELB instance should not be deleted unless forceDelete is true.
+func checkDeletionProtection(service *v1.Service, listeners []listenerKey, forceDelete bool) error {
+	if forceDelete {
+		return nil
+	}
+
+	foreign := make([]string, 0)
+	for _, lis := range listeners {
+		if !isListenerOwned(service, lis) {
+			foreign = append(foreign, lis.Name)
+		}
+	}
+	if len(foreign) > 0 {
+		return status.Errorf(codes.FailedPrecondition, "the ELB still has listeners not created by service "+
+			"%s/%s: %s, set \"force-delete-elb\" to true to delete it anyway",
+			service.Namespace, service.Name, strings.Join(foreign, ", "))
+	}
+	return nil
+}
+
+// eipReleaser unbinds the EIPs from the ELB instances and releases them.
+type eipReleaser interface {
+	Get(id string) (*eipmodel.PublicipShowResp, error)
+	List(req *eipmodel.ListPublicipsRequest) ([]eipmodel.PublicipShowResp, error)
+	Unbind(id string) error
+	Delete(id string) error
+}
+
+// unbindEIP unbinds the EIP from the VIP port before releasing it, releasing a bound EIP fails in some regions.
+// The EIP bound to the port is used if eipID is empty. The EIP is only unbound if keepEIP is true.
+// An EIP that is already unbound or released is tolerated, and an EIP bound to another port is left untouched.
+func unbindEIP(eipClient eipReleaser, vipPortID, eipID string, keepEIP bool) error {
 	if eipID == "" {
 		ips, err := eipClient.List(&eipmodel.ListPublicipsRequest{
 			PortId: &[]string{vipPortID},
@@ -1019,18 +1305,49 @@ func unbindEIP(eipClient *wrapper.EIpClient, vipPortID, eipID string, keepEIP bo
 		eipID = *ips[0].Id
 	}
 
-	if err := eipClient.Unbind(eipID); err != nil {
+	eip, err := eipClient.Get(eipID)
+	if err != nil {
+		if common.IsNotFound(err) {
+			klog.Infof("the EIP %s is already released", eipID)
+			return nil
+		}
 		return err
 	}
+
+	if eip.PortId != nil && *eip.PortId != "" && vipPortID != "" && *eip.PortId != vipPortID {
+		klog.Infof("the EIP %s is bound to another port %s, skip releasing it", eipID, *eip.PortId)
+		return nil
+	}
+
+	if eip.PortId != nil && *eip.PortId != "" {
+		klog.Infof("unbinding the EIP %s from the port %s", eipID, *eip.PortId)
+		if err = eipClient.Unbind(eipID); err != nil {
+			if common.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+	}
 	if keepEIP {
 		return nil
 	}
-	if err := eipClient.Delete(eipID); err != nil {
+
+	klog.Infof("releasing the EIP %s", eipID)
+	if err = eipClient.Delete(eipID); err != nil && !common.IsNotFound(err) {
 		return err
 	}
 	return nil
 }
 
+// isEIPKept returns true if the EIP of the ELB instance should not be released. The EIP is kept if "keep-eip"
+// is enabled, or it is specified by the service, which is a reused EIP not allocated by the provider.
+func isEIPKept(service *v1.Service, opts *config.LoadBalancerOptions) bool {
+	if getStringFromSvsAnnotation(service, ElbEipID, "") != "" {
+		return true
+	}
+	return getBoolFromSvsAnnotation(service, ELBKeepEip, opts.KeepEIP)
+}
+
 func getNodeAddress(node *corev1.Node) (string, error) {
 	addresses := node.Status.Addresses
 	if len(addresses) == 0 {
@@ -1047,6 +1364,65 @@ func getNodeAddress(node *corev1.Node) (string, error) {
 		node.Name)
 }
 
+// getNodeMemberAddress returns the address of the node added to the pools. The host IP of the pod is used if it is
+// still an address of the node. Otherwise the private IP of the node has changed, such as the NIC is re-attached,
+// while the host IP of the pod is not updated, so the current address of the node is used, and the member of
+// the stale address is replaced.
+func getNodeMemberAddress(node *corev1.Node, hostIP string) (string, error) {
+	if hostIP != "" {
+		if len(node.Status.Addresses) == 0 {
+			return hostIP, nil
+		}
+		for _, addr := range node.Status.Addresses {
+			if addr.Address == hostIP {
+				return hostIP, nil
+			}
+		}
+		klog.Warningf("the host IP %s of the pods is no longer an address of node %s, use the current address",
+			hostIP, node.Name)
+	}
+	return getNodeAddress(node)
+}
+
+// getBackendSubnetCIDR returns the CIDRs used to select the member IP of the node,
+// the annotation on the node takes precedence over the one on the service.
+func getBackendSubnetCIDR(service *v1.Service, node *v1.Node) string {
+	if cidr, ok := getAnnotation(node.Annotations, ElbBackendSubnetCIDR); ok && strings.TrimSpace(cidr) != "" {
+		return cidr
+	}
+	return getStringFromSvsAnnotation(service, ElbBackendSubnetCIDR, "")
+}
+
+// getNodeAddressInCIDR returns the first valid address of the node that belongs to one of the CIDRs,
+// the CIDRs are separated by commas.
+func getNodeAddressInCIDR(node *v1.Node, cidrs string) (string, error) {
+	ipNets := make([]*net.IPNet, 0)
+	for _, cidr := range strings.Split(cidrs, ",") {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return "", status.Errorf(codes.InvalidArgument, "invalid backend subnet CIDR: %s, error: %s", cidr, err)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+
+	for _, addr := range node.Status.Addresses {
+		if _, ok := allowedIPTypes[addr.Type]; !ok {
+			continue
+		}
+		ip := net.ParseIP(addr.Address)
+		if ip == nil {
+			continue
+		}
+		for _, ipNet := range ipNets {
+			if ipNet.Contains(ip) {
+				return addr.Address, nil
+			}
+		}
+	}
+	return "", status.Errorf(codes.NotFound, "error, current node do not have any address in the backend subnet %s, "+
+		"nodeName: %s", cidrs, node.Name)
+}
+
 func getHealthCheckOptionFromAnnotation(service *v1.Service, opts *config.LoadBalancerOptions) *config.HealthCheckOption {
 	checkOpts := opts.HealthCheckOption
 
@@ -1065,8 +1441,8 @@ func getHealthCheckOptionFromAnnotation(service *v1.Service, opts *config.LoadBa
 	return &checkOpts
 }
 
-func (l *SharedLoadBalancer) createEIP(service *v1.Service) (string, error) {
-	opts, err := parseEIPAutoCreateOptions(service)
+func (b Basic) createEIP(service *v1.Service) (string, error) {
+	opts, err := parseEIPAutoCreateOptions(service, &b.loadbalancerOpts.EIPAutoCreateOption)
 	if err != nil || opts == nil {
 		return "", err
 	}
@@ -1084,7 +1460,8 @@ func (l *SharedLoadBalancer) createEIP(service *v1.Service) (string, error) {
 	}
 
 	name := fmt.Sprintf("%s_%s", service.Namespace, service.Name)
-	eip, err := l.eipClient.Create(&eipmodel.CreatePublicipRequestBody{
+	token := getIdempotencyToken(service, operationCreateEIP)
+	return createEIPWithToken(b.eipClient, token, &eipmodel.CreatePublicipRequestBody{
 		Bandwidth: &eipmodel.CreatePublicipBandwidthOption{
 			Name:       &name,
 			Id:         &opts.ShareID,
@@ -1094,11 +1471,6 @@ func (l *SharedLoadBalancer) createEIP(service *v1.Service) (string, error) {
 		},
 		Publicip: &eipmodel.CreatePublicipOption{Type: opts.IPType},
 	})
-	if err != nil {
-		return "", err
-	}
-
-	return *eip.Id, nil
 }
 
 type CreateEIPOptions struct {
@@ -1110,18 +1482,50 @@ type CreateEIPOptions struct {
 	IPType string `json:"ip_type"`
 }
 
-func parseEIPAutoCreateOptions(service *v1.Service) (*CreateEIPOptions, error) {
+// parseEIPAutoCreateOptions parses the options from the annotation,
+// the fields not specified in the annotation use the default values in the loadbalancer config.
+// Without the annotation, the default values are used if "auto_create" is enabled, unless the service is internal
+// or uses a specified ELB instance, which is left as it is.
+func parseEIPAutoCreateOptions(service *v1.Service, defaults *config.EIPAutoCreateOption) (*CreateEIPOptions, error) {
 	str := getStringFromSvsAnnotation(service, AutoCreateEipOptions, "")
 	if str == "" {
-		return nil, nil
+		if !defaults.AutoCreate || isInternalLoadBalancer(service) || getStringFromSvsAnnotation(service, ElbID, "") != "" {
+			return nil, nil
+		}
+		str = "{}"
 	}
 
-	opts := &CreateEIPOptions{}
-	err := json.Unmarshal([]byte(str), opts)
+	opts := &CreateEIPOptions{
+		BandwidthSize: defaults.BandwidthSize,
+		ShareType:     defaults.ShareType,
+		ChargeMode:    defaults.ChargeMode,
+		IPType:        defaults.IPType,
+	}
+	if err := json.Unmarshal([]byte(str), opts); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "error parsing %q: %s", AutoCreateEipOptions, err)
+	}
 	if opts.ChargeMode == "" {
 		opts.ChargeMode = "traffic"
 	}
-	return opts, err
+
+	if err := validateEIPAutoCreateOptions(opts, defaults); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// validateEIPAutoCreateOptions checks the bandwidth size of the PER bandwidth against the limits in the config.
+func validateEIPAutoCreateOptions(opts *CreateEIPOptions, limits *config.EIPAutoCreateOption) error {
+	if opts.ShareType != "PER" {
+		return nil
+	}
+
+	maxSize := limits.MaxBandwidthSizeOf(opts.ChargeMode)
+	if opts.BandwidthSize < 1 || opts.BandwidthSize > maxSize {
+		return status.Errorf(codes.InvalidArgument, "invalid bandwidth_size %d in %q, the value ranges from 1 to %d "+
+			"when charge_mode is %s", opts.BandwidthSize, AutoCreateEipOptions, maxSize, opts.ChargeMode)
+	}
+	return nil
 }
 
 func parseProtocol(service *v1.Service, port v1.ServicePort) string {
@@ -1137,8 +1541,36 @@ func parseProtocol(service *v1.Service, port v1.ServicePort) string {
 	return protocol
 }
 
+// isInternalLoadBalancer returns true if the load balancer only has a private VIP.
+func isInternalLoadBalancer(service *v1.Service) bool {
+	return getBoolFromSvsAnnotation(service, ElbInternal, getBoolFromSvsAnnotation(service, ElbInternalBeta, false))
+}
+
+// annotationPrefix is the prefix of the LoadBalancer annotations, it is set from the cloud-config at startup.
+var annotationPrefix = config.DefaultAnnotationPrefix
+
+func setAnnotationPrefix(prefix string) {
+	if prefix == "" {
+		prefix = config.DefaultAnnotationPrefix
+	}
+	annotationPrefix = prefix
+}
+
+// getAnnotation returns the annotation of the key, such as kubernetes.io/elb.class.
+// If the annotation prefix is customized, the key under the custom prefix takes precedence,
+// and the default key is still read for compatibility.
+func getAnnotation(annotations map[string]string, key string) (string, bool) {
+	if annotationPrefix != config.DefaultAnnotationPrefix && strings.HasPrefix(key, config.DefaultAnnotationPrefix+".") {
+		if value, ok := annotations[annotationPrefix+strings.TrimPrefix(key, config.DefaultAnnotationPrefix)]; ok {
+			return value, true
+		}
+	}
+	value, ok := annotations[key]
+	return value, ok
+}
+
 func getStringFromSvsAnnotation(service *corev1.Service, key string, defaultSetting string) string {
-	if annotationValue, ok := service.Annotations[key]; ok {
+	if annotationValue, ok := getAnnotation(service.Annotations, key); ok {
 		klog.V(4).Infof("Found annotation: %v = %v", key, annotationValue)
 		return annotationValue
 	}
@@ -1147,7 +1579,7 @@ func getStringFromSvsAnnotation(service *corev1.Service, key string, defaultSett
 }
 
 func getBoolFromSvsAnnotation(service *corev1.Service, key string, defaultVal bool) bool {
-	value, ok := service.Annotations[key]
+	value, ok := getAnnotation(service.Annotations, key)
 	if !ok {
 		return defaultVal
 	}
@@ -1166,7 +1598,7 @@ func getBoolFromSvsAnnotation(service *corev1.Service, key string, defaultVal bo
 }
 
 func getIntFromSvsAnnotation(service *v1.Service, key string, defaultVal int) int {
-	if annotationValue, ok := service.Annotations[key]; ok {
+	if annotationValue, ok := getAnnotation(service.Annotations, key); ok {
 		klog.V(4).Infof("Found annotation: %v = %v", key, annotationValue)
 		val, err := strconv.Atoi(annotationValue)
 		if err != nil {
@@ -1177,3 +1609,33 @@ func getIntFromSvsAnnotation(service *v1.Service, key string, defaultVal int) in
 	klog.V(4).Infof("Annotation %s is empty, use default value: %v", key, defaultVal)
 	return defaultVal
 }
+
+// isPodTargeted returns true if the service does not allocate the node ports, or registers the pods by their ENIs,
+// then the ready pods are added to the backends with their IPs and target ports directly.
+func isPodTargeted(service *v1.Service) bool {
+	if isENIMembers(service) {
+		return true
+	}
+	return service.Spec.AllocateLoadBalancerNodePorts != nil && !*service.Spec.AllocateLoadBalancerNodePorts
+}
+
+// getPodMemberAddress returns the IP and the target port of the pod, the named target port is looked up
+// in the container ports with the same protocol.
+func getPodMemberAddress(pod v1.Pod, svcPort v1.ServicePort) (string, int32, error) {
+	if pod.Status.PodIP == "" {
+		return "", 0, status.Errorf(codes.NotFound, "error, pod %s/%s does not have an IP", pod.Namespace, pod.Name)
+	}
+	if svcPort.TargetPort.Type == intstr.Int {
+		return pod.Status.PodIP, svcPort.TargetPort.IntVal, nil
+	}
+
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == svcPort.TargetPort.StrVal && string(p.Protocol) == string(svcPort.Protocol) {
+				return pod.Status.PodIP, p.ContainerPort, nil
+			}
+		}
+	}
+	return "", 0, status.Errorf(codes.NotFound, "error, pod %s/%s does not have the port %s",
+		pod.Namespace, pod.Name, svcPort.TargetPort.StrVal)
+}
@@ -0,0 +1,958 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	eipmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+func TestParseEIPAutoCreateOptions(t *testing.T) {
+	defaults := &config.EIPAutoCreateOption{
+		BandwidthSize: 5,
+		ShareType:     "PER",
+		ChargeMode:    "traffic",
+		IPType:        "5_bgp",
+	}
+	autoCreate := &config.EIPAutoCreateOption{BandwidthSize: 10, ShareType: "PER", ChargeMode: "traffic", AutoCreate: true}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		defaults    *config.EIPAutoCreateOption
+		expected    *CreateEIPOptions
+		code        codes.Code
+	}{
+		{
+			name:        "not specified",
+			annotations: map[string]string{},
+			expected:    nil,
+			code:        codes.OK,
+		},
+		{
+			name:        "use defaults",
+			annotations: map[string]string{AutoCreateEipOptions: `{}`},
+			expected: &CreateEIPOptions{
+				BandwidthSize: 5,
+				ShareType:     "PER",
+				ChargeMode:    "traffic",
+				IPType:        "5_bgp",
+			},
+			code: codes.OK,
+		},
+		{
+			name: "override defaults",
+			annotations: map[string]string{
+				AutoCreateEipOptions: `{"ip_type": "5_sbgp", "bandwidth_size": 500, "charge_mode": "bandwidth"}`,
+			},
+			expected: &CreateEIPOptions{
+				BandwidthSize: 500,
+				ShareType:     "PER",
+				ChargeMode:    "bandwidth",
+				IPType:        "5_sbgp",
+			},
+			code: codes.OK,
+		},
+		{
+			name:        "shared bandwidth",
+			annotations: map[string]string{AutoCreateEipOptions: `{"share_type": "WHOLE", "share_id": "bw-1"}`},
+			expected: &CreateEIPOptions{
+				BandwidthSize: 5,
+				ShareType:     "WHOLE",
+				ShareID:       "bw-1",
+				ChargeMode:    "traffic",
+				IPType:        "5_bgp",
+			},
+			code: codes.OK,
+		},
+		{
+			name:        "bandwidth exceeds the traffic limit",
+			annotations: map[string]string{AutoCreateEipOptions: `{"bandwidth_size": 500}`},
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "bandwidth exceeds the limit",
+			annotations: map[string]string{AutoCreateEipOptions: `{"bandwidth_size": 3000, "charge_mode": "bandwidth"}`},
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "bandwidth within the limit of the config",
+			annotations: map[string]string{AutoCreateEipOptions: `{"bandwidth_size": 500}`},
+			defaults:    &config.EIPAutoCreateOption{ShareType: "PER", ChargeMode: "traffic", MaxTrafficBandwidthSize: 500},
+			expected:    &CreateEIPOptions{BandwidthSize: 500, ShareType: "PER", ChargeMode: "traffic"},
+			code:        codes.OK,
+		},
+		{
+			name:        "bandwidth exceeds the limit of the config",
+			annotations: map[string]string{AutoCreateEipOptions: `{"bandwidth_size": 1500, "charge_mode": "bandwidth"}`},
+			defaults:    &config.EIPAutoCreateOption{ShareType: "PER", ChargeMode: "traffic", MaxBandwidthSize: 1000},
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "auto create without the annotation",
+			annotations: map[string]string{},
+			defaults:    autoCreate,
+			expected:    &CreateEIPOptions{BandwidthSize: 10, ShareType: "PER", ChargeMode: "traffic"},
+			code:        codes.OK,
+		},
+		{
+			name:        "auto create skips the internal service",
+			annotations: map[string]string{ElbInternal: "true"},
+			defaults:    autoCreate,
+			expected:    nil,
+			code:        codes.OK,
+		},
+		{
+			name:        "auto create skips the specified ELB",
+			annotations: map[string]string{ElbID: "elb-1"},
+			defaults:    autoCreate,
+			expected:    nil,
+			code:        codes.OK,
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{AutoCreateEipOptions: `{"bandwidth_size": "5"`},
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			limits := defaults
+			if testCase.defaults != nil {
+				limits = testCase.defaults
+			}
+			opts, err := parseEIPAutoCreateOptions(newTestService(testCase.annotations), limits)
+			if status.Code(err) != testCase.code {
+				t.Fatalf("expected: %v, got: %v", testCase.code, err)
+			}
+			if !reflect.DeepEqual(opts, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, opts)
+			}
+		})
+	}
+}
+
+func TestELBStructuredLogs(t *testing.T) {
+	apiErr := sdkerr.ServiceResponseError{StatusCode: 500, RequestId: "req-1", ErrorCode: "ELB.0000"}
+	service := newTestService(nil)
+	service.Spec.Type = v1.ServiceTypeLoadBalancer
+
+	tests := []struct {
+		name     string
+		call     func()
+		expected []string
+	}{
+		{
+			name: "called",
+			call: func() {
+				lb := &SharedLoadBalancer{Basic: Basic{loadbalancerOpts: &config.LoadBalancerOptions{}}}
+				_ = lb.UpdateLoadBalancer(context.TODO(), "kubernetes", service, nil)
+			},
+			expected: []string{`"Load balancer API is called"`, `operation="UpdateLoadBalancer"`,
+				`serviceName="default/test"`},
+		},
+		{
+			name: "failed",
+			call: func() {
+				logLoadBalancerError(apiErr, "Failed to delete the ELB", "DeleteInstance", service, "elb-1")
+			},
+			expected: []string{`"Failed to delete the ELB"`, `operation="DeleteInstance"`, `serviceName="default/test"`,
+				`loadbalancerID="elb-1"`, `errorCode="ELB.0000"`, `statusCode=500`, `requestID="req-1"`},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			logs := captureLogs(te.call)
+			for _, field := range te.expected {
+				if !strings.Contains(logs, field) {
+					t.Fatalf("expected: %v, got: %v", field, logs)
+				}
+			}
+		})
+	}
+}
+
+func newTestNode(annotations map[string]string, addresses ...v1.NodeAddress) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-1",
+			Annotations: annotations,
+		},
+		Status: v1.NodeStatus{
+			Addresses: addresses,
+		},
+	}
+}
+
+func TestGetNodeAddressInCIDR(t *testing.T) {
+	multiIPNode := newTestNode(nil,
+		v1.NodeAddress{Type: v1.NodeHostName, Address: "node-1"},
+		v1.NodeAddress{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+		v1.NodeAddress{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+		v1.NodeAddress{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+	)
+
+	tests := []struct {
+		name     string
+		node     *v1.Node
+		cidrs    string
+		expected string
+		code     codes.Code
+	}{
+		{
+			name:     "first address matched",
+			node:     multiIPNode,
+			cidrs:    "192.168.0.0/24",
+			expected: "192.168.0.10",
+			code:     codes.OK,
+		},
+		{
+			name:     "secondary address matched",
+			node:     multiIPNode,
+			cidrs:    "192.168.1.0/24",
+			expected: "192.168.1.10",
+			code:     codes.OK,
+		},
+		{
+			name:     "multiple CIDRs",
+			node:     multiIPNode,
+			cidrs:    "172.16.0.0/16, 10.0.0.0/8",
+			expected: "10.0.0.10",
+			code:     codes.OK,
+		},
+		{
+			name:  "no address matched",
+			node:  multiIPNode,
+			cidrs: "172.16.0.0/16",
+			code:  codes.NotFound,
+		},
+		{
+			name:  "hostname is ignored",
+			node:  newTestNode(nil, v1.NodeAddress{Type: v1.NodeHostName, Address: "192.168.0.10"}),
+			cidrs: "192.168.0.0/24",
+			code:  codes.NotFound,
+		},
+		{
+			name:  "invalid CIDR",
+			node:  multiIPNode,
+			cidrs: "192.168.0.0",
+			code:  codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			address, err := getNodeAddressInCIDR(testCase.node, testCase.cidrs)
+			if status.Code(err) != testCase.code {
+				t.Fatalf("expected: %v, got: %v", testCase.code, err)
+			}
+			if address != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, address)
+			}
+		})
+	}
+}
+
+func TestGetMemberIPWithBackendSubnet(t *testing.T) {
+	addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+		{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+	}
+	svcPort := v1.ServicePort{Name: "http", Port: 80, NodePort: 30080}
+	pod := v1.Pod{Status: v1.PodStatus{HostIP: "192.168.0.10"}}
+
+	tests := []struct {
+		name            string
+		svcAnnotations  map[string]string
+		nodeAnnotations map[string]string
+		expected        string
+		code            codes.Code
+	}{
+		{
+			name:           "backend subnet on service",
+			svcAnnotations: map[string]string{ElbBackendSubnetCIDR: "192.168.1.0/24"},
+			expected:       "192.168.1.10",
+			code:           codes.OK,
+		},
+		{
+			name:            "backend subnet on node takes precedence",
+			svcAnnotations:  map[string]string{ElbBackendSubnetCIDR: "192.168.1.0/24"},
+			nodeAnnotations: map[string]string{ElbBackendSubnetCIDR: "192.168.0.0/24"},
+			expected:        "192.168.0.10",
+			code:            codes.OK,
+		},
+		{
+			name:            "node not in the backend subnet",
+			nodeAnnotations: map[string]string{ElbBackendSubnetCIDR: "10.0.0.0/8"},
+			code:            codes.NotFound,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := newTestService(testCase.svcAnnotations)
+			service.Spec.AllocateLoadBalancerNodePorts = pointer.Bool(true)
+			node := newTestNode(testCase.nodeAnnotations, addresses...)
+
+			for _, getMemberIP := range []func(*v1.Service, *v1.Node, v1.Pod, v1.ServicePort) (string, int32, error){
+				(&SharedLoadBalancer{}).getMemberIP,
+				(&DedicatedLoadBalancer{}).getMemberIP,
+			} {
+				address, port, err := getMemberIP(service, node, pod, svcPort)
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected: %v, got: %v", testCase.code, err)
+				}
+				if address != testCase.expected {
+					t.Fatalf("expected: %v, got: %v", testCase.expected, address)
+				}
+				if err == nil && port != svcPort.NodePort {
+					t.Fatalf("expected: %v, got: %v", svcPort.NodePort, port)
+				}
+			}
+		})
+	}
+}
+
+func TestGetNodeMemberAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []v1.NodeAddress
+		hostIP    string
+		expected  string
+		code      codes.Code
+	}{
+		{
+			name:      "host IP of the node",
+			addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}},
+			hostIP:    "192.168.0.10",
+			expected:  "192.168.0.10",
+		},
+		{
+			name:      "stale host IP",
+			addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.20"}},
+			hostIP:    "192.168.0.10",
+			expected:  "192.168.0.20",
+		},
+		{
+			name:     "node without addresses",
+			hostIP:   "192.168.0.10",
+			expected: "192.168.0.10",
+		},
+		{
+			name:      "without host IP",
+			addresses: []v1.NodeAddress{{Type: v1.NodeHostName, Address: "node-1"}},
+			code:      codes.NotFound,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			address, err := getNodeMemberAddress(newTestNode(nil, testCase.addresses...), testCase.hostIP)
+			if status.Code(err) != testCase.code || address != testCase.expected {
+				t.Fatalf("expected: %v, %v, got: %v, %v", testCase.expected, testCase.code, address, err)
+			}
+		})
+	}
+}
+
+func TestGetMemberIPNodeAddressChanged(t *testing.T) {
+	service := newTestService(nil)
+	svcPort := v1.ServicePort{Name: "http", Port: 80, NodePort: 30080}
+	// the host IP of the pod is not updated when the private IP of the node changes.
+	pod := v1.Pod{Status: v1.PodStatus{HostIP: "192.168.0.10"}}
+	basic := Basic{loadbalancerOpts: &config.LoadBalancerOptions{}}
+
+	for _, getMemberIP := range []func(*v1.Service, *v1.Node, v1.Pod, v1.ServicePort) (string, int32, error){
+		(&SharedLoadBalancer{Basic: basic}).getMemberIP,
+		(&DedicatedLoadBalancer{Basic: basic}).getMemberIP,
+	} {
+		// the first reconcile registers the member of the original address.
+		node := newTestNode(nil, v1.NodeAddress{Type: v1.NodeInternalIP, Address: "192.168.0.10"})
+		address, port, err := getMemberIP(service, node, pod, svcPort)
+		if err != nil || address != "192.168.0.10" {
+			t.Fatalf("expected: %v, got: %v, %v", "192.168.0.10", address, err)
+		}
+		members := []elbmodel.MemberResp{{Id: "member-1", Address: address, ProtocolPort: port}}
+
+		// the next reconcile adds the member of the new address, and the stale member is left to be deleted.
+		node.Status.Addresses = []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.20"}}
+		address, port, err = getMemberIP(service, node, pod, svcPort)
+		if err != nil || address != "192.168.0.20" {
+			t.Fatalf("expected: %v, got: %v, %v", "192.168.0.20", address, err)
+		}
+		remaining := popMember(members, address, port)
+		if len(remaining) != 1 || remaining[0].Id != "member-1" {
+			t.Fatalf("expected: the stale member is deleted, got: %v", remaining)
+		}
+	}
+}
+
+func TestInternalLoadBalancerValidation(t *testing.T) {
+	nodes := []*v1.Node{newTestNode(nil)}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		internal    bool
+		expected    codes.Code
+	}{
+		{
+			name:        "external",
+			annotations: map[string]string{ElbEipID: "eip-1"},
+			internal:    false,
+			expected:    codes.OK,
+		},
+		{
+			name:        "internal",
+			annotations: map[string]string{ElbInternal: "true"},
+			internal:    true,
+			expected:    codes.OK,
+		},
+		{
+			name:        "internal by the beta annotation",
+			annotations: map[string]string{ElbInternalBeta: "true"},
+			internal:    true,
+			expected:    codes.OK,
+		},
+		{
+			name:        "annotation takes precedence over the beta annotation",
+			annotations: map[string]string{ElbInternal: "false", ElbInternalBeta: "true"},
+			internal:    false,
+			expected:    codes.OK,
+		},
+		{
+			name:        "internal with specified EIP",
+			annotations: map[string]string{ElbInternal: "true", ElbEipID: "eip-1"},
+			internal:    true,
+			expected:    codes.InvalidArgument,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := newTestService(testCase.annotations)
+			service.Spec.Ports = []v1.ServicePort{{Name: "http", Port: 80}}
+			service.Spec.Selector = map[string]string{"app": "test"}
+
+			if internal := isInternalLoadBalancer(service); internal != testCase.internal {
+				t.Fatalf("expected: %v, got: %v", testCase.internal, internal)
+			}
+			err := ensureLoadBalancerValidation(service, nodes)
+			if status.Code(err) != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, err)
+			}
+		})
+	}
+}
+
+func TestGetMemberIPAllocateNodePorts(t *testing.T) {
+	nodes := map[string]*v1.Node{
+		"node-1": {ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		"node-2": {ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+	}
+	newPod := func(name, nodeName, hostIP, podIP string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: v1.PodSpec{
+				NodeName: nodeName,
+				Containers: []v1.Container{
+					{Ports: []v1.ContainerPort{{Name: "web", ContainerPort: 8080, Protocol: v1.ProtocolTCP}}},
+				},
+			},
+			Status: v1.PodStatus{HostIP: hostIP, PodIP: podIP},
+		}
+	}
+	pods := []v1.Pod{
+		newPod("pod-1", "node-1", "192.168.0.10", "172.16.0.11"),
+		newPod("pod-2", "node-1", "192.168.0.10", "172.16.0.12"),
+		newPod("pod-3", "node-2", "192.168.0.20", "172.16.1.13"),
+	}
+
+	tests := []struct {
+		name     string
+		allocate *bool
+		svcPort  v1.ServicePort
+		expected []string
+	}{
+		{
+			name:     "node ports by default",
+			allocate: nil,
+			svcPort:  v1.ServicePort{Port: 80, NodePort: 30080, TargetPort: intstr.FromInt(8080)},
+			expected: []string{"192.168.0.10:30080", "192.168.0.20:30080"},
+		},
+		{
+			name:     "node ports",
+			allocate: pointer.Bool(true),
+			svcPort:  v1.ServicePort{Port: 80, NodePort: 30080, TargetPort: intstr.FromInt(8080)},
+			expected: []string{"192.168.0.10:30080", "192.168.0.20:30080"},
+		},
+		{
+			name:     "pod IPs",
+			allocate: pointer.Bool(false),
+			svcPort:  v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)},
+			expected: []string{"172.16.0.11:8080", "172.16.0.12:8080", "172.16.1.13:8080"},
+		},
+		{
+			name:     "pod IPs with named target port",
+			allocate: pointer.Bool(false),
+			svcPort:  v1.ServicePort{Port: 80, Protocol: v1.ProtocolTCP, TargetPort: intstr.FromString("web")},
+			expected: []string{"172.16.0.11:8080", "172.16.0.12:8080", "172.16.1.13:8080"},
+		},
+	}
+
+	basic := Basic{loadbalancerOpts: &config.LoadBalancerOptions{}}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := newTestService(nil)
+			service.Spec.AllocateLoadBalancerNodePorts = testCase.allocate
+
+			for _, getMemberIP := range []func(*v1.Service, *v1.Node, v1.Pod, v1.ServicePort) (string, int32, error){
+				(&SharedLoadBalancer{Basic: basic}).getMemberIP,
+				(&DedicatedLoadBalancer{Basic: basic}).getMemberIP,
+			} {
+				members := make([]string, 0)
+				exists := make(map[string]bool)
+				for _, pod := range pods {
+					address, port, err := getMemberIP(service, nodes[pod.Spec.NodeName], pod, testCase.svcPort)
+					if err != nil {
+						t.Fatalf("expected: nil, got: %v", err)
+					}
+					key := fmt.Sprintf("%s:%d", address, port)
+					if !exists[key] {
+						exists[key] = true
+						members = append(members, key)
+					}
+				}
+				if !reflect.DeepEqual(members, testCase.expected) {
+					t.Fatalf("expected: %v, got: %v", testCase.expected, members)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPodMemberAddressNotFound(t *testing.T) {
+	pod := v1.Pod{
+		Spec:   v1.PodSpec{Containers: []v1.Container{{Ports: []v1.ContainerPort{{Name: "web", ContainerPort: 8080}}}}},
+		Status: v1.PodStatus{PodIP: "172.16.0.11"},
+	}
+
+	tests := []struct {
+		name    string
+		pod     v1.Pod
+		svcPort v1.ServicePort
+	}{
+		{
+			name:    "pod without IP",
+			pod:     v1.Pod{},
+			svcPort: v1.ServicePort{TargetPort: intstr.FromInt(8080)},
+		},
+		{
+			name:    "named port not found",
+			pod:     pod,
+			svcPort: v1.ServicePort{Protocol: v1.ProtocolTCP, TargetPort: intstr.FromString("metrics")},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, _, err := getPodMemberAddress(testCase.pod, testCase.svcPort)
+			if status.Code(err) != codes.NotFound {
+				t.Fatalf("expected: %v, got: %v", codes.NotFound, err)
+			}
+		})
+	}
+}
+
+func TestGetAnnotationWithPrefix(t *testing.T) {
+	annotations := map[string]string{
+		ElbClass:                      "shared",
+		ElbHealthCheckFlag:            "off",
+		"example.com/lb.class":        "dedicated",
+		"example.com/lb.keep-eip":     "true",
+		"example.com/lb.idle-timeout": "120",
+		ElbBackendSubnetCIDR:          "192.168.0.0/24",
+	}
+
+	tests := []struct {
+		name            string
+		prefix          string
+		class           string
+		keepEIP         bool
+		idleTimeout     int
+		healthCheckFlag string
+	}{
+		{
+			name:            "default prefix",
+			prefix:          "",
+			class:           "shared",
+			keepEIP:         false,
+			idleTimeout:     0,
+			healthCheckFlag: "off",
+		},
+		{
+			name:            "custom prefix",
+			prefix:          "example.com/lb",
+			class:           "dedicated",
+			keepEIP:         true,
+			idleTimeout:     120,
+			healthCheckFlag: "off",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			setAnnotationPrefix(testCase.prefix)
+			defer setAnnotationPrefix("")
+
+			service := newTestService(annotations)
+			if class := getStringFromSvsAnnotation(service, ElbClass, ""); class != testCase.class {
+				t.Fatalf("expected: %v, got: %v", testCase.class, class)
+			}
+			if keepEIP := getBoolFromSvsAnnotation(service, ELBKeepEip, false); keepEIP != testCase.keepEIP {
+				t.Fatalf("expected: %v, got: %v", testCase.keepEIP, keepEIP)
+			}
+			if idleTimeout := getIntFromSvsAnnotation(service, ElbIdleTimeout, 0); idleTimeout != testCase.idleTimeout {
+				t.Fatalf("expected: %v, got: %v", testCase.idleTimeout, idleTimeout)
+			}
+			// The annotation under the default prefix is still read for compatibility.
+			if flag := getStringFromSvsAnnotation(service, ElbHealthCheckFlag, "on"); flag != testCase.healthCheckFlag {
+				t.Fatalf("expected: %v, got: %v", testCase.healthCheckFlag, flag)
+			}
+		})
+	}
+}
+
+// fakeEIPReleaser records the calls, the EIPs are keyed by ID and their values are the bound port IDs.
+type fakeEIPReleaser struct {
+	eips  map[string]string
+	calls []string
+}
+
+func (f *fakeEIPReleaser) Get(id string) (*eipmodel.PublicipShowResp, error) {
+	portID, ok := f.eips[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "EIP %s not found", id)
+	}
+	return &eipmodel.PublicipShowResp{Id: &id, PortId: &portID}, nil
+}
+
+func (f *fakeEIPReleaser) List(req *eipmodel.ListPublicipsRequest) ([]eipmodel.PublicipShowResp, error) {
+	rst := make([]eipmodel.PublicipShowResp, 0)
+	for id, portID := range f.eips {
+		id, portID := id, portID
+		if req.PortId != nil && portID == (*req.PortId)[0] {
+			rst = append(rst, eipmodel.PublicipShowResp{Id: &id, PortId: &portID})
+		}
+	}
+	return rst, nil
+}
+
+func (f *fakeEIPReleaser) Unbind(id string) error {
+	f.calls = append(f.calls, "unbind "+id)
+	f.eips[id] = ""
+	return nil
+}
+
+func (f *fakeEIPReleaser) Delete(id string) error {
+	if f.eips[id] != "" {
+		return fmt.Errorf("EIP %s is still bound", id)
+	}
+	f.calls = append(f.calls, "delete "+id)
+	delete(f.eips, id)
+	return nil
+}
+
+func TestUnbindEIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		eips     map[string]string
+		eipID    string
+		keepEIP  bool
+		expected []string
+	}{
+		{
+			name:     "unbind then release",
+			eips:     map[string]string{"eip-1": "vip-port"},
+			eipID:    "eip-1",
+			expected: []string{"unbind eip-1", "delete eip-1"},
+		},
+		{
+			name:     "found by the VIP port",
+			eips:     map[string]string{"eip-1": "vip-port", "eip-2": "other-port"},
+			expected: []string{"unbind eip-1", "delete eip-1"},
+		},
+		{
+			name:     "kept",
+			eips:     map[string]string{"eip-1": "vip-port"},
+			eipID:    "eip-1",
+			keepEIP:  true,
+			expected: []string{"unbind eip-1"},
+		},
+		{
+			name:     "already unbound",
+			eips:     map[string]string{"eip-1": ""},
+			eipID:    "eip-1",
+			expected: []string{"delete eip-1"},
+		},
+		{
+			name:     "bound to another port",
+			eips:     map[string]string{"eip-1": "other-port"},
+			eipID:    "eip-1",
+			expected: []string{},
+		},
+		{
+			name:     "already released",
+			eips:     map[string]string{},
+			eipID:    "eip-1",
+			expected: []string{},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			client := &fakeEIPReleaser{eips: te.eips, calls: []string{}}
+			if err := unbindEIP(client, "vip-port", te.eipID, te.keepEIP); err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(client.calls, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, client.calls)
+			}
+		})
+	}
+}
+
+func TestIsEIPKept(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		opts        *config.LoadBalancerOptions
+		expected    bool
+	}{
+		{
+			name:     "allocated by the provider",
+			opts:     &config.LoadBalancerOptions{},
+			expected: false,
+		},
+		{
+			name:        "reused EIP",
+			annotations: map[string]string{ElbEipID: "eip-1"},
+			opts:        &config.LoadBalancerOptions{},
+			expected:    true,
+		},
+		{
+			name:     "keep-eip",
+			opts:     &config.LoadBalancerOptions{KeepEIP: true},
+			expected: true,
+		},
+		{
+			name:        "keep-eip disabled by the annotation",
+			annotations: map[string]string{ELBKeepEip: "false"},
+			opts:        &config.LoadBalancerOptions{KeepEIP: true},
+			expected:    false,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			got := isEIPKept(newTestService(te.annotations), te.opts)
+			if got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetLoadBalancerDescription(t *testing.T) {
+	created := metav1.NewTime(time.Date(2023, 6, 1, 16, 0, 0, 0, time.FixedZone("CST", 8*3600)))
+
+	tests := []struct {
+		name     string
+		uid      types.UID
+		created  metav1.Time
+		expected string
+	}{
+		{
+			name:    "full",
+			uid:     "2f9a6c1e-0d4b-4a55-9b8e-3c1d2e4f5a6b",
+			created: created,
+			expected: "Created by the ELB service(default/test) of the k8s cluster(kubernetes), " +
+				"UID: 2f9a6c1e-0d4b-4a55-9b8e-3c1d2e4f5a6b, created at 2023-06-01T08:00:00Z.",
+		},
+		{
+			name:     "without UID and creation time",
+			expected: "Created by the ELB service(default/test) of the k8s cluster(kubernetes).",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			service := newTestService(nil)
+			service.UID = testCase.uid
+			service.CreationTimestamp = testCase.created
+			if desc := getLoadBalancerDescription("kubernetes", service); desc != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, desc)
+			}
+		})
+	}
+}
+
+func TestBuildIngress(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		vip         string
+		expected    []v1.LoadBalancerIngress
+	}{
+		{
+			name:        "IP only",
+			annotations: nil,
+			expected:    []v1.LoadBalancerIngress{{IP: "100.85.0.10"}},
+		},
+		{
+			name:        "hostname only",
+			annotations: map[string]string{ElbHostname: "lb.example.com"},
+			vip:         "192.168.0.10",
+			expected:    []v1.LoadBalancerIngress{{Hostname: "lb.example.com"}},
+		},
+		{
+			name:        "hostname and IP",
+			annotations: map[string]string{ElbHostname: " lb.example.com ", ElbHostnameWithIP: "true"},
+			expected:    []v1.LoadBalancerIngress{{IP: "100.85.0.10", Hostname: "lb.example.com"}},
+		},
+		{
+			name:        "with IP but no hostname",
+			annotations: map[string]string{ElbHostnameWithIP: "true"},
+			expected:    []v1.LoadBalancerIngress{{IP: "100.85.0.10"}},
+		},
+		{
+			name:     "EIP and private VIP",
+			vip:      "192.168.0.10",
+			expected: []v1.LoadBalancerIngress{{IP: "100.85.0.10"}, {IP: "192.168.0.10"}},
+		},
+		{
+			name:        "hostname, EIP and private VIP",
+			annotations: map[string]string{ElbHostname: "lb.example.com", ElbHostnameWithIP: "true"},
+			vip:         "192.168.0.10",
+			expected:    []v1.LoadBalancerIngress{{IP: "100.85.0.10", Hostname: "lb.example.com"}, {IP: "192.168.0.10"}},
+		},
+		{
+			name:     "internal",
+			vip:      "100.85.0.10",
+			expected: []v1.LoadBalancerIngress{{IP: "100.85.0.10"}},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			ingress := buildIngress(newTestService(testCase.annotations), "100.85.0.10", testCase.vip)
+			if !reflect.DeepEqual(ingress, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, ingress)
+			}
+		})
+	}
+}
+
+func TestValidateIngressHostname(t *testing.T) {
+	nodes := []*v1.Node{newTestNode(nil)}
+	for hostname, valid := range map[string]bool{
+		"lb.example.com":  true,
+		"LB.example.com":  false,
+		"lb_example.com":  false,
+		"-lb.example.com": false,
+	} {
+		t.Run(hostname, func(t *testing.T) {
+			service := newTestService(map[string]string{ElbHostname: hostname})
+			service.Spec.Ports = []v1.ServicePort{{Port: 80, Protocol: v1.ProtocolTCP}}
+			service.Spec.Selector = map[string]string{"app": "nginx"}
+			err := ensureLoadBalancerValidation(service, nodes)
+			if valid != (err == nil) {
+				t.Fatalf("expected valid: %v, got: %v", valid, err)
+			}
+			if err != nil && status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestSharedProvisioningGetter(t *testing.T) {
+	// the shared ELB instance is being updated by the other deletes of the tier, the delete conflicts until it is
+	// ACTIVE again.
+	statuses := elbmodel.GetLoadbalancerRespProvisioningStatusEnum()
+	polls := 0
+	get := func(id string) (*elbmodel.LoadbalancerResp, error) {
+		polls++
+		provisioningStatus := statuses.PENDING_CREATE
+		if polls >= 2 {
+			provisioningStatus = statuses.ACTIVE
+		}
+		return &elbmodel.LoadbalancerResp{Id: id, ProvisioningStatus: provisioningStatus}, nil
+	}
+	deletes := 0
+	del := func() error {
+		deletes++
+		if polls < 2 {
+			return sdkerr.ServiceResponseError{StatusCode: http.StatusConflict, ErrorMessage: "PENDING_UPDATE"}
+		}
+		return nil
+	}
+
+	backoff := wait.Backoff{Duration: time.Millisecond, Steps: 3}
+	if err := retryOnNotReady(context.TODO(), sharedProvisioningGetter(get), "elb-1", 3, backoff, del); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if deletes != 2 || polls != 2 {
+		t.Fatalf("expected: 2 deletes and 2 polls, got: %d deletes and %d polls", deletes, polls)
+	}
+}
+
+func TestSharedProxyProtocolRejected(t *testing.T) {
+	service := newTestService(map[string]string{ElbProxyProtocol: "true"})
+	service.Spec = v1.ServiceSpec{
+		Type:     v1.ServiceTypeLoadBalancer,
+		Selector: map[string]string{"app": "test"},
+		Ports:    []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80}},
+	}
+	l := &SharedLoadBalancer{Basic: Basic{loadbalancerOpts: &config.LoadBalancerOptions{}}}
+
+	_, err := l.EnsureLoadBalancer(context.TODO(), "kubernetes", service, []*v1.Node{newTestNode(nil)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
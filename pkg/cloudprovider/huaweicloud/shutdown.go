@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// defaultShutdownTimeout bounds the time to wait for the in-flight reconciles when the CCM stops.
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownGuard tracks the in-flight reconciles and the scheduled operations of the providers.
+// Once the shutdown begins, the new reconciles are rejected and the scheduled operations are stopped,
+// they are resumed by the next reconcile of the service after the CCM restarts.
+// A nil guard accepts all the reconciles.
+type shutdownGuard struct {
+	mu       sync.Mutex
+	closing  bool
+	inflight sync.WaitGroup
+	timers   map[*time.Timer]struct{}
+}
+
+func newShutdownGuard() *shutdownGuard {
+	return &shutdownGuard{timers: make(map[*time.Timer]struct{})}
+}
+
+// enter registers an in-flight operation, it returns an error if the shutdown has begun.
+// leave must be called once the operation is done.
+func (g *shutdownGuard) enter() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closing {
+		return status.Errorf(codes.Unavailable, "the cloud provider is shutting down")
+	}
+	g.inflight.Add(1)
+	return nil
+}
+
+func (g *shutdownGuard) leave() {
+	if g == nil {
+		return
+	}
+	g.inflight.Done()
+}
+
+// afterFunc schedules f like time.AfterFunc, f runs as an in-flight operation and is dropped
+// if the shutdown has begun.
+func (g *shutdownGuard) afterFunc(d time.Duration, f func()) {
+	if g == nil {
+		time.AfterFunc(d, f)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closing {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		g.mu.Lock()
+		delete(g.timers, timer)
+		g.mu.Unlock()
+
+		if err := g.enter(); err != nil {
+			return
+		}
+		defer g.leave()
+		f()
+	})
+	g.timers[timer] = struct{}{}
+}
+
+// shutdown rejects the new operations, stops the scheduled ones and waits for the in-flight ones to complete.
+// It returns the error of ctx if they are not completed before ctx is done.
+func (g *shutdownGuard) shutdown(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	g.closing = true
+	for timer := range g.timers {
+		timer.Stop()
+	}
+	klog.Infof("Shutting down, stopped %d scheduled operation(s)", len(g.timers))
+	g.timers = make(map[*time.Timer]struct{})
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/mutexkv"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/semaphore"
+)
+
+// blockingLoadBalancer blocks the reconciles until release is closed.
+type blockingLoadBalancer struct {
+	fakeLoadBalancer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingLoadBalancer) EnsureLoadBalancer(_ context.Context, _ string, _ *v1.Service, _ []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &v1.LoadBalancerStatus{}, nil
+}
+
+func newShutdownTestProvider(lb cloudprovider.LoadBalancer) *CloudProvider {
+	return &CloudProvider{
+		Basic: Basic{
+			loadbalancerOpts: &config.LoadBalancerOptions{},
+			reconcileSem:     semaphore.NewSemaphore(0),
+			reconcileMetrics: newReconcileMetrics(),
+			shutdown:         newShutdownGuard(),
+			mutexLock:        mutexkv.NewMutexKV(),
+		},
+		providers: map[LoadBalanceVersion]cloudprovider.LoadBalancer{VersionDedicated: lb},
+	}
+}
+
+func TestShutdownDrainsInFlightReconciles(t *testing.T) {
+	lb := &blockingLoadBalancer{started: make(chan struct{}), release: make(chan struct{})}
+	h := newShutdownTestProvider(lb)
+	nodes := []*v1.Node{newTestNode(nil)}
+
+	reconciled := make(chan error)
+	go func() {
+		_, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", newMetricsTestService("web"), nodes)
+		reconciled <- err
+	}()
+	<-lb.started
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- h.Shutdown(context.TODO())
+	}()
+
+	// wait for the shutdown to begin, the new reconciles are rejected after then.
+	err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		return h.UpdateLoadBalancer(context.TODO(), "kubernetes", newMetricsTestService("api"), nodes) != nil, nil
+	})
+	if err != nil {
+		t.Fatalf("expected: the new reconcile is rejected, got: %v", err)
+	}
+	err = h.EnsureLoadBalancerDeleted(context.TODO(), "kubernetes", newMetricsTestService("api"))
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected: %v, got: %v", codes.Unavailable, err)
+	}
+
+	select {
+	case err = <-stopped:
+		t.Fatalf("expected: the shutdown waits for the in-flight reconcile, got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(lb.release)
+	if err = <-reconciled; err != nil {
+		t.Fatalf("expected: the in-flight reconcile completes, got: %v", err)
+	}
+	if err = <-stopped; err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	lb := &blockingLoadBalancer{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(lb.release)
+	h := newShutdownTestProvider(lb)
+
+	go func() {
+		_, _ = h.EnsureLoadBalancer(context.TODO(), "kubernetes", newMetricsTestService("web"), nil)
+	}()
+	<-lb.started
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestShutdownStopsScheduledOperations(t *testing.T) {
+	g := newShutdownGuard()
+	fired := make(chan struct{}, 1)
+	g.afterFunc(50*time.Millisecond, func() { fired <- struct{}{} })
+
+	if err := g.shutdown(context.TODO()); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	g.afterFunc(0, func() { fired <- struct{}{} })
+
+	select {
+	case <-fired:
+		t.Fatalf("expected: the scheduled operations are stopped, got: fired")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestInitializeStartsBackgroundLoops(t *testing.T) {
+	refreshed := make(chan struct{}, 1)
+	h := newShutdownTestProvider(&fakeLoadBalancer{})
+	h.azCache = &AvailabilityZoneCache{
+		zones:    sets.NewString(),
+		interval: time.Millisecond,
+		listFunc: func() ([]string, error) {
+			select {
+			case refreshed <- struct{}{}:
+			default:
+			}
+			return []string{"ap-southeast-1a"}, nil
+		},
+	}
+
+	stop := make(chan struct{})
+	h.Initialize(nil, stop)
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatalf("expected: the availability zones are loaded after Initialize, got: not loaded")
+	}
+
+	close(stop)
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		_, err := h.EnsureLoadBalancer(context.TODO(), "kubernetes", newMetricsTestService("web"), nil)
+		return status.Code(err) == codes.Unavailable, nil
+	}); err != nil {
+		t.Fatalf("expected: the reconciles are rejected once stop is closed, got: %v", err)
+	}
+
+	// the refresh loop returns once stop is closed, drop the signal sent before then.
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-refreshed:
+	default:
+	}
+	select {
+	case <-refreshed:
+		t.Fatalf("expected: the availability zones are not refreshed after stop is closed, got: refreshed")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 
+	wpmodel "sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper/model"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
 )
@@ -65,7 +66,7 @@ func (s *DedicatedLoadBalanceClient) CreateInstanceCompleted(req *model.CreateLo
 func (s *DedicatedLoadBalanceClient) WaitStatusActive(id string) (*model.LoadBalancer, error) {
 	var instance *model.LoadBalancer
 
-	err := common.WaitForCompleted(func() (bool, error) {
+	err := common.WaitForCompleted(s.AuthOpts.Context(), func() (bool, error) {
 		ins, err := s.GetInstance(id)
 		if err != nil {
 			return false, err
@@ -105,6 +106,14 @@ func (s *DedicatedLoadBalanceClient) ListInstances(req *model.ListLoadBalancersR
 	return rst, err
 }
 
+func (s *DedicatedLoadBalanceClient) ListAvailabilityZones() ([][]model.AvailabilityZone, error) {
+	var rst [][]model.AvailabilityZone
+	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.ListAvailabilityZones(&model.ListAvailabilityZonesRequest{})
+	}, "AvailabilityZones", &rst)
+	return rst, err
+}
+
 func (s *DedicatedLoadBalanceClient) UpdateInstance(id, name, description string) (*model.LoadBalancer, error) {
 	var rst *model.LoadBalancer
 	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
@@ -126,7 +135,7 @@ func (s *DedicatedLoadBalanceClient) DeleteInstance(id string) error {
 		req := model.DeleteLoadBalancerRequest{
 			LoadbalancerId: id,
 		}
-		klog.Infof("Delete Req: %#v", req)
+		klog.V(4).Infof("Delete Req: %#v", req)
 		return c.DeleteLoadBalancer(&req)
 	})
 }
@@ -175,6 +184,58 @@ func (s *DedicatedLoadBalanceClient) UpdateListener(id string, opt *model.Update
 	})
 }
 
+// GetListenerProxyProtocol returns whether the PROXY protocol of the listener is enabled.
+func (s *DedicatedLoadBalanceClient) GetListenerProxyProtocol(id string) (bool, error) {
+	var rst *wpmodel.ListenerProxyProtocol
+	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		requestDef := wpmodel.GenReqDefForShowListenerProxyProtocol()
+		resp, err := c.HcClient.Sync(&wpmodel.ShowListenerProxyProtocolRequest{ListenerId: id}, requestDef)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*wpmodel.ShowListenerProxyProtocolResponse), nil
+	}, "Listener", &rst)
+	if err != nil || rst == nil {
+		return false, err
+	}
+	return rst.ProxyProtocolEnable, nil
+}
+
+func (s *DedicatedLoadBalanceClient) UpdateListenerProxyProtocol(id string, enable bool) error {
+	return s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		requestDef := wpmodel.GenReqDefForUpdateListenerProxyProtocol()
+		resp, err := c.HcClient.Sync(&wpmodel.UpdateListenerProxyProtocolRequest{
+			ListenerId: id,
+			Body: &wpmodel.UpdateListenerProxyProtocolRequestBody{
+				Listener: &wpmodel.UpdateListenerProxyProtocolOption{
+					ProxyProtocolEnable: &enable,
+				},
+			},
+		}, requestDef)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*wpmodel.UpdateListenerProxyProtocolResponse), nil
+	})
+}
+
+// RemoveListenerDefaultPool removes the default pool of the listener, the pool will not be deleted.
+func (s *DedicatedLoadBalanceClient) RemoveListenerDefaultPool(id string) error {
+	return s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		requestDef := wpmodel.GenReqDefForUpdateListenerDefaultPool()
+		resp, err := c.HcClient.Sync(&wpmodel.UpdateListenerDefaultPoolRequest{
+			ListenerId: id,
+			Body: &wpmodel.UpdateListenerDefaultPoolRequestBody{
+				Listener: &wpmodel.UpdateListenerDefaultPoolOption{},
+			},
+		}, requestDef)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*wpmodel.UpdateListenerDefaultPoolResponse), nil
+	})
+}
+
 func (s *DedicatedLoadBalanceClient) DeleteListener(elbID string, listenerID string) error {
 	// Check pools bound to this listener
 	ids := []string{elbID}
@@ -259,6 +320,49 @@ func (s *DedicatedLoadBalanceClient) DeletePool(id string) error {
 	})
 }
 
+/** L7 Policies **/
+
+func (s *DedicatedLoadBalanceClient) CreateL7Policy(req *model.CreateL7PolicyOption) (*model.L7Policy, error) {
+	var rst *model.L7Policy
+	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.CreateL7Policy(&model.CreateL7PolicyRequest{
+			Body: &model.CreateL7PolicyRequestBody{
+				L7policy: req,
+			},
+		})
+	}, "L7policy", &rst)
+
+	return rst, err
+}
+
+func (s *DedicatedLoadBalanceClient) ListL7Policies(req *model.ListL7PoliciesRequest) ([]model.L7Policy, error) {
+	var rst []model.L7Policy
+	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.ListL7Policies(req)
+	}, "L7policies", &rst)
+
+	return rst, err
+}
+
+func (s *DedicatedLoadBalanceClient) UpdateL7Policy(id string, req *model.UpdateL7PolicyOption) error {
+	return s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.UpdateL7Policy(&model.UpdateL7PolicyRequest{
+			L7policyId: id,
+			Body: &model.UpdateL7PolicyRequestBody{
+				L7policy: req,
+			},
+		})
+	})
+}
+
+func (s *DedicatedLoadBalanceClient) DeleteL7Policy(id string) error {
+	return s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.DeleteL7Policy(&model.DeleteL7PolicyRequest{
+			L7policyId: id,
+		})
+	})
+}
+
 /** Health Monitor **/
 
 func (s *DedicatedLoadBalanceClient) CreateHealthMonitor(req *model.CreateHealthMonitorOption) (*model.HealthMonitor, error) {
@@ -345,10 +449,11 @@ func (s *DedicatedLoadBalanceClient) ListMembers(req *model.ListMembersRequest)
 	return rst, err
 }
 
-func (s *DedicatedLoadBalanceClient) UpdateMember(id string, req *model.UpdateMemberOption) (*model.Member, error) {
+func (s *DedicatedLoadBalanceClient) UpdateMember(poolID, id string, req *model.UpdateMemberOption) (*model.Member, error) {
 	var rst *model.Member
 	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
 		return c.UpdateMember(&model.UpdateMemberRequest{
+			PoolId:   poolID,
 			MemberId: id,
 			Body: &model.UpdateMemberRequestBody{
 				Member: req,
@@ -376,7 +481,7 @@ func (s *DedicatedLoadBalanceClient) DeleteAllPoolMembers(poolID string) error {
 
 	errs := make([]error, 0)
 	for _, m := range members {
-		if err := s.DeleteMember(poolID, m.Id); err != nil {
+		if err := s.DeleteMember(poolID, m.Id); err != nil && !common.IsNotFound(err) {
 			errs = append(errs, err)
 		}
 	}
@@ -387,8 +492,11 @@ func (s *DedicatedLoadBalanceClient) DeleteAllPoolMembers(poolID string) error {
 }
 
 func (s *DedicatedLoadBalanceClient) wrapper(handler func(*elb.ElbClient) (interface{}, error), args ...interface{}) error {
-	return commonWrapper(func() (interface{}, error) {
-		hc := s.AuthOpts.GetHcClient("elb")
+	return commonWrapper(s.AuthOpts.Context(), func() (interface{}, error) {
+		hc, err := s.AuthOpts.GetHcClient("elb")
+		if err != nil {
+			return nil, err
+		}
 		return handler(elb.NewElbClient(hc))
 	}, OKCodes, args...)
 }
@@ -17,11 +17,14 @@ limitations under the License.
 package wrapper
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -33,17 +36,42 @@ import (
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
 
 	wpmodel "sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/model"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
 )
 
 var OKCodes = []int{200, 201, 204}
 
+var serverIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 type EcsClient struct {
 	AuthOpts *config.AuthOptions
 }
 
+// InProject returns the client scoped to the project with the same credentials,
+// the client itself is returned if the project is empty or the same as the project of the client.
+func (e *EcsClient) InProject(projectID string) *EcsClient {
+	if projectID == "" || projectID == e.AuthOpts.ProjectID {
+		return e
+	}
+	opts := *e.AuthOpts
+	opts.ProjectID = projectID
+	return &EcsClient{AuthOpts: &opts}
+}
+
 func (e *EcsClient) Get(id string) (*model.ServerDetail, error) {
+	rst, err := getServer(e.show, e.List, id, e.AuthOpts.ListServerOnThrottle)
+	if err != nil {
+		return rst, err
+	}
+	if err = checkServerScope(rst, e.AuthOpts); err != nil {
+		return nil, err
+	}
+	return rst, nil
+}
+
+func (e *EcsClient) show(id string) (*model.ServerDetail, error) {
 	var rst *model.ServerDetail
 	err := e.wrapper(func(c *ecs.EcsClient) (interface{}, error) {
 		return c.ShowServer(&model.ShowServerRequest{ServerId: id})
@@ -51,7 +79,71 @@ func (e *EcsClient) Get(id string) (*model.ServerDetail, error) {
 	return rst, err
 }
 
+// getServer queries the ECS by ID. If the query is throttled and listOnThrottle is true, the ECS is looked up
+// with the list API filtered by the ID instead, which has a separate rate limit. The error of the query is
+// returned if the list fails or does not return the ECS.
+func getServer(show func(string) (*model.ServerDetail, error),
+	list func(*model.ListServersDetailsRequest) (*model.ListServersDetailsResponse, error),
+	id string, listOnThrottle bool) (*model.ServerDetail, error) {
+	server, err := show(id)
+	if err == nil || !listOnThrottle || !common.IsThrottled(err) {
+		return server, err
+	}
+
+	klog.Warningf("The query of ECS %s is throttled, look it up by listing the ECSs: %s", id, err)
+	limit := int32(1)
+	rsp, listErr := list(&model.ListServersDetailsRequest{ServerId: &id, Limit: &limit})
+	if listErr != nil {
+		klog.Warningf("Failed to list ECS %s after the query is throttled: %s", id, listErr)
+		return nil, err
+	}
+	if rsp != nil && rsp.Servers != nil {
+		for idx := range *rsp.Servers {
+			if (*rsp.Servers)[idx].Id == id {
+				return &(*rsp.Servers)[idx], nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// checkServerScope returns an error if the ECS is out of the project or the enterprise project of the client,
+// the error is NotFound or PermissionDenied by "foreign-server-policy". The project is not checked if it is
+// discovered from the credentials, neither is an empty project or enterprise project of the ECS.
+func checkServerScope(server *model.ServerDetail, opts *config.AuthOptions) error {
+	if server == nil {
+		return nil
+	}
+	scope, got := "", ""
+	if opts.ProjectID != "" && server.TenantId != "" && server.TenantId != opts.ProjectID {
+		scope, got = "project "+opts.ProjectID, server.TenantId
+	} else if opts.EnterpriseProjectID != "" && server.EnterpriseProjectId != nil &&
+		*server.EnterpriseProjectId != "" && *server.EnterpriseProjectId != opts.EnterpriseProjectID {
+		scope, got = "enterprise project "+opts.EnterpriseProjectID, *server.EnterpriseProjectId
+	}
+	if scope == "" {
+		return nil
+	}
+
+	klog.Warningf("ECS %s belongs to %s, which is out of the %s managed", server.Id, got, scope)
+	if opts.GetForeignServerPolicy() == config.ForeignServerPolicyFail {
+		return status.Errorf(codes.PermissionDenied, "ECS %s belongs to %s, which is out of the %s managed",
+			server.Id, got, scope)
+	}
+	return status.Errorf(codes.NotFound, "not found ECS %s in the %s", server.Id, scope)
+}
+
 func (e *EcsClient) GetByNodeName(name string) (*model.ServerDetail, error) {
+	// Some nodes are named with the ECS ID, query ECS details by ID first.
+	if isServerID(name) {
+		klog.V(6).Infof("query ECS detail by ID: %s", name)
+		server, err := e.Get(name)
+		if err == nil || !common.IsNotFound(err) {
+			return server, err
+		}
+		klog.V(6).Infof("not found ECS by ID: %s, query ECS detail by name or IP", name)
+	}
+
 	privateIP := ""
 	if net.ParseIP(name).To4() != nil {
 		privateIP = name
@@ -70,60 +162,107 @@ func (e *EcsClient) GetByNodeName(name string) (*model.ServerDetail, error) {
 	}
 
 	klog.V(6).Infof("query ECS detail by private IP: %s, NodeName: %s", privateIP, name)
+	return e.getByPrivateIP(privateIP, fmt.Errorf("not found any ECS, node: %s, PrivateIP: %s", name, privateIP))
+}
 
-	rsp, err := e.List(&model.ListServersDetailsRequest{
-		IpEq: &privateIP,
-	})
-	if err != nil {
-		return nil, err
-	}
+func isServerID(name string) bool {
+	return serverIDRegexp.MatchString(name)
+}
 
-	notFound := fmt.Errorf("not found any ECS, node: %s, PrivateIP: %s", name, privateIP)
-	if rsp.Servers == nil || len(*rsp.Servers) == 0 {
-		return nil, notFound
+func (e *EcsClient) GetByNodeIP(privateIP string) (*model.ServerDetail, error) {
+	if privateIP == "" {
+		return nil, fmt.Errorf("privateIP can be empty")
 	}
 
-	for _, sv := range *rsp.Servers {
-		for _, addresses := range sv.Addresses {
-			for _, addr := range addresses {
-				if addr.Addr == privateIP {
-					return &sv, nil
-				}
+	return e.getByPrivateIP(privateIP, fmt.Errorf("not found any ECS, PrivateIP: %s", privateIP))
+}
+
+// getByPrivateIP returns the ECS that has the private IP, the paging stops once it is found.
+func (e *EcsClient) getByPrivateIP(privateIP string, notFound error) (*model.ServerDetail, error) {
+	var found *model.ServerDetail
+	err := e.ListPages(&model.ListServersDetailsRequest{IpEq: &privateIP}, func(servers []model.ServerDetail) bool {
+		for idx := range servers {
+			if hasServerAddress(&servers[idx], privateIP) {
+				found = &servers[idx]
+				return true
 			}
 		}
+		return false
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, notFound
+	if found == nil {
+		return nil, notFound
+	}
+	return found, nil
 }
 
-func (e *EcsClient) GetByNodeIP(privateIP string) (*model.ServerDetail, error) {
+// GetByPrivateIP returns the ECS that has the private IP, the ECSs are filtered by the IP by the ECS API.
+// If multiple ECSs have the IP, such as the ECSs in different VPCs, the ones in the VPC are preferred
+// if vpcID is not empty, then one of them is picked by "duplicate-server-name-policy".
+func (e *EcsClient) GetByPrivateIP(privateIP, vpcID string) (*model.ServerDetail, error) {
 	if privateIP == "" {
-		return nil, fmt.Errorf("privateIP can be empty")
+		return nil, fmt.Errorf("privateIP can not be empty")
 	}
-
-	rsp, err := e.List(&model.ListServersDetailsRequest{
-		IpEq: &privateIP,
-	})
+	matched, err := findServersByPrivateIP(e.List, privateIP, vpcID, e.AuthOpts.GetServerListPageSize(),
+		e.AuthOpts.GetServerListMaxResults())
 	if err != nil {
 		return nil, err
 	}
-
-	notFound := fmt.Errorf("not found any ECS, PrivateIP: %s", privateIP)
-	if rsp.Servers == nil || len(*rsp.Servers) == 0 {
-		return nil, notFound
+	if len(matched) == 0 {
+		return nil, status.Errorf(codes.NotFound, "not found any ECS, PrivateIP: %s", privateIP)
 	}
+	return pickServer(matched, privateIP, e.AuthOpts.GetDuplicateServerNamePolicy())
+}
 
-	for _, sv := range *rsp.Servers {
-		for _, addresses := range sv.Addresses {
-			for _, addr := range addresses {
-				if addr.Addr == privateIP {
-					return &sv, nil
+// findServersByPrivateIP returns the ECSs that have the private IP, only the ones in the VPC are returned
+// if any of them is in the VPC.
+func findServersByPrivateIP(list func(*model.ListServersDetailsRequest) (*model.ListServersDetailsResponse, error),
+	privateIP, vpcID string, pageSize, maxResults int) ([]model.ServerDetail, error) {
+	matched := make([]model.ServerDetail, 0, 1)
+	inVPC := make([]model.ServerDetail, 0, 1)
+	err := listServerPages(list, model.ListServersDetailsRequest{IpEq: &privateIP}, pageSize, maxResults,
+		func(servers []model.ServerDetail) bool {
+			for idx := range servers {
+				if !hasServerAddress(&servers[idx], privateIP) {
+					continue
+				}
+				matched = append(matched, servers[idx])
+				if vpcID != "" && hasVPCAddress(&servers[idx], vpcID, privateIP) {
+					inVPC = append(inVPC, servers[idx])
 				}
 			}
+			return false
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(inVPC) > 0 {
+		return inVPC, nil
+	}
+	return matched, nil
+}
+
+// hasVPCAddress returns true if the ECS has the IP in the VPC, the addresses of the ECS are keyed by the VPC ID.
+func hasVPCAddress(server *model.ServerDetail, vpcID, ip string) bool {
+	for _, addr := range server.Addresses[vpcID] {
+		if addr.Addr == ip {
+			return true
 		}
 	}
+	return false
+}
 
-	return nil, notFound
+func hasServerAddress(server *model.ServerDetail, ip string) bool {
+	for _, addresses := range server.Addresses {
+		for _, addr := range addresses {
+			if addr.Addr == ip {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (e *EcsClient) GetByNodeIPNew(privateIP string) (*wpmodel.ServerDetail, error) {
@@ -165,19 +304,155 @@ func (e *EcsClient) GetByNodeIPNew(privateIP string) (*wpmodel.ServerDetail, err
 	return nil, notFound
 }
 
+// ListByIDs returns the ECS details of the specified IDs in one request, the IDs that do not exist are ignored.
+func (e *EcsClient) ListByIDs(ids []string) ([]wpmodel.ServerDetail, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	limit := int32(len(ids))
+	var rsp *wpmodel.ListServersDetailsResponse
+	err := e.wrapper(func(c *ecs.EcsClient) (interface{}, error) {
+		requestDef := wpmodel.GenReqDefForListServersDetails()
+		resp, err := c.HcClient.Sync(&wpmodel.ListServersDetailsRequest{
+			Limit:    &limit,
+			ServerId: &ids,
+		}, requestDef)
+
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*wpmodel.ListServersDetailsResponse), nil
+	}, &rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.Servers == nil {
+		return nil, nil
+	}
+	return *rsp.Servers, nil
+}
+
+// GetByName returns the ECS matching the name. If multiple ECSs match, one is picked by "duplicate-server-name-policy",
+// with the default policy, the paging stops once a second match is found and the first one is returned with a warning.
 func (e *EcsClient) GetByName(name string) (*model.ServerDetail, error) {
 	name = fmt.Sprintf("^%s$", name)
+	policy := e.AuthOpts.GetDuplicateServerNamePolicy()
 
-	rsp, err := e.List(&model.ListServersDetailsRequest{Name: &name})
+	matched := make([]model.ServerDetail, 0, 2)
+	err := e.ListPages(&model.ListServersDetailsRequest{Name: &name}, func(servers []model.ServerDetail) bool {
+		matched = append(matched, servers...)
+		return policy == config.DuplicateServerNamePolicyPickFirst && len(matched) > 1
+	})
 	if err != nil {
 		return nil, err
 	}
-	serverList := *rsp.Servers
-	if len(serverList) == 0 {
+	if len(matched) == 0 {
 		return nil, status.Errorf(codes.NotFound, "Error, not found any servers matched name: %s", name)
 	}
+	return pickServer(matched, name, policy)
+}
+
+// pickServer returns the one of the ECSs matching the name by the policy, an error is returned if
+// the policy can not tell them apart.
+func pickServer(matched []model.ServerDetail, name, policy string) (*model.ServerDetail, error) {
+	if len(matched) == 1 {
+		return &matched[0], nil
+	}
+
+	ids := make([]string, 0, len(matched))
+	active := make([]model.ServerDetail, 0, len(matched))
+	for _, server := range matched {
+		ids = append(ids, server.Id)
+		if server.Status == "ACTIVE" {
+			active = append(active, server)
+		}
+	}
+
+	switch policy {
+	case config.DuplicateServerNamePolicyPickFirst:
+		klog.Warningf("found multiple servers matched name: %s, use the first one %s", name, matched[0].Id)
+		return &matched[0], nil
+	case config.DuplicateServerNamePolicyPickActive:
+		if len(active) == 1 {
+			klog.Warningf("found multiple servers matched name: %s, use the ACTIVE one %s", name, active[0].Id)
+			return &active[0], nil
+		}
+	case config.DuplicateServerNamePolicyPickNewest:
+		if server := pickNewestServer(active); server != nil {
+			klog.Warningf("found multiple servers matched name: %s, use the newest ACTIVE one %s", name, server.Id)
+			return server, nil
+		}
+	}
+	return nil, status.Errorf(codes.FailedPrecondition, "found multiple servers matched name: %s, IDs: %v, "+
+		"policy: %s", name, ids, policy)
+}
+
+// pickNewestServer returns the latest created one of the servers, nil if there is no server,
+// or the creation time of the latest ones is the same or unknown.
+func pickNewestServer(servers []model.ServerDetail) *model.ServerDetail {
+	var newest *model.ServerDetail
+	var newestCreated time.Time
+	ambiguous := false
+	for idx := range servers {
+		created, err := time.Parse(time.RFC3339, servers[idx].Created)
+		if err != nil {
+			klog.Warningf("failed to parse the creation time %q of server %s: %s", servers[idx].Created,
+				servers[idx].Id, err)
+			return nil
+		}
+		switch {
+		case newest == nil || created.After(newestCreated):
+			newest, newestCreated, ambiguous = &servers[idx], created, false
+		case created.Equal(newestCreated):
+			ambiguous = true
+		}
+	}
+	if ambiguous {
+		return nil
+	}
+	return newest
+}
 
-	return &serverList[0], nil
+// ListPages lists the ECSs page by page with the page size of the options, visit is called with each page
+// and returns true to stop paging. The paging stops after the maximum results of the options are listed.
+func (e *EcsClient) ListPages(req *model.ListServersDetailsRequest, visit func([]model.ServerDetail) bool) error {
+	return listServerPages(e.List, *req, e.AuthOpts.GetServerListPageSize(), e.AuthOpts.GetServerListMaxResults(),
+		visit)
+}
+
+func listServerPages(list func(*model.ListServersDetailsRequest) (*model.ListServersDetailsResponse, error),
+	req model.ListServersDetailsRequest, pageSize, maxResults int, visit func([]model.ServerDetail) bool) error {
+	// The offset is the page number, so the page size is kept and the last page is truncated to the cap.
+	limit := int32(pageSize)
+	listed := 0
+	for page := int32(1); ; page++ {
+		offset := page
+		req.Limit, req.Offset = &limit, &offset
+
+		rsp, err := list(&req)
+		if err != nil {
+			return err
+		}
+		if rsp.Servers == nil || len(*rsp.Servers) == 0 {
+			return nil
+		}
+		servers := *rsp.Servers
+		lastPage := len(servers) < pageSize
+		if remaining := maxResults - listed; len(servers) > remaining {
+			servers = servers[:remaining]
+		}
+		listed += len(servers)
+		if visit(servers) || lastPage || (rsp.Count != nil && listed >= int(*rsp.Count)) {
+			return nil
+		}
+		if listed >= maxResults {
+			break
+		}
+	}
+	klog.Warningf("stop listing the servers after %d results, the rest are not checked", maxResults)
+	return nil
 }
 
 func (e *EcsClient) List(req *model.ListServersDetailsRequest) (*model.ListServersDetailsResponse, error) {
@@ -196,6 +471,10 @@ func (e *EcsClient) ListInterfaces(req *model.ListServerInterfacesRequest) ([]mo
 	return rst, err
 }
 
+// BuildAddresses returns the IPv4 addresses of the server, the private IPs of the active interfaces come first.
+// The returned slice is never nil: it is empty if the server has no address at all, and an ExternalIP is only
+// reported for a floating IP, the access IP or an address in the public networks, it is never synthesized.
+// If WarnMissingExternalIP is enabled, a warning is logged for a server without any ExternalIP.
 func (e *EcsClient) BuildAddresses(server *model.ServerDetail, interfaces []model.InterfaceAttachment,
 	networkingOpts *config.NetworkingOptions) ([]v1.NodeAddress, error) {
 	nodeAddresses := make([]v1.NodeAddress, 0)
@@ -232,10 +511,22 @@ func (e *EcsClient) BuildAddresses(server *model.ServerDetail, interfaces []mode
 	}
 	sort.Strings(nicIDs)
 
+	addressNetworks := make(map[string]string)
 	for _, nicID := range nicIDs {
 		for _, serverAddr := range server.Addresses[nicID] {
+			addressNetworks[serverAddr.Addr] = nicID
 			var addressType v1.NodeAddressType
-			if serverAddr.OSEXTIPStype != nil && serverAddr.OSEXTIPStype.Value() == "floating" {
+			if serverAddr.OSEXTIPStype != nil && serverAddr.OSEXTIPStype.Value() == "floating" &&
+				matchNetworks(serverAddr.Addr, nicID, networkingOpts.InternalFloatingIPs) >= 0 {
+				// the floating IP is a private IP of a peered VPC, which is not reachable externally.
+				addressType = v1.NodeInternalIP
+			} else if serverAddr.OSEXTIPStype != nil && serverAddr.OSEXTIPStype.Value() == "floating" {
+				if len(networkingOpts.FloatingIPPools) > 0 &&
+					matchNetworks(serverAddr.Addr, nicID, networkingOpts.FloatingIPPools) < 0 {
+					klog.V(4).Infof("[DEBUG] Node '%s' floating IP '%s' ignored due to 'floating-ip-pools' option",
+						server.Name, serverAddr.Addr)
+					continue
+				}
 				addressType = v1.NodeExternalIP
 			} else if utils.IsStrSliceContains(networkingOpts.PublicNetworkName, nicID) {
 				addressType = v1.NodeExternalIP
@@ -273,10 +564,70 @@ func (e *EcsClient) BuildAddresses(server *model.ServerDetail, interfaces []mode
 			}
 		}
 	}
+	sortExternalAddresses(nodeAddresses, addressNetworks, networkingOpts.ExternalIPPriority)
+	if networkingOpts.WarnMissingExternalIP && !hasAddressType(nodeAddresses, v1.NodeExternalIP) {
+		klog.Warningf("server %s/%s has no ExternalIP, only the internal addresses are reported: %s",
+			server.Name, server.Id, utils.ToString(nodeAddresses))
+	}
 	klog.V(6).Infof("server: %s/%s, network addresses: %s", server.Name, server.Id, utils.ToString(nodeAddresses))
 	return nodeAddresses, nil
 }
 
+// hasAddressType returns true if any of the addresses is of the type.
+func hasAddressType(addresses []v1.NodeAddress, addressType v1.NodeAddressType) bool {
+	for _, addr := range addresses {
+		if addr.Type == addressType {
+			return true
+		}
+	}
+	return false
+}
+
+// sortExternalAddresses orders the external IPs by the priority in place, the internal IPs are not moved.
+// Each item of the priority is a network name or a CIDR, the IPs matching none of them come last.
+func sortExternalAddresses(addresses []v1.NodeAddress, addressNetworks map[string]string, priority []string) {
+	if len(priority) == 0 {
+		return
+	}
+
+	rank := func(address string) int {
+		if i := matchNetworks(address, addressNetworks[address], priority); i >= 0 {
+			return i
+		}
+		return len(priority)
+	}
+
+	indexes := make([]int, 0)
+	external := make([]v1.NodeAddress, 0)
+	for i, addr := range addresses {
+		if addr.Type == v1.NodeExternalIP {
+			indexes = append(indexes, i)
+			external = append(external, addr)
+		}
+	}
+	sort.SliceStable(external, func(i, j int) bool {
+		return rank(external[i].Address) < rank(external[j].Address)
+	})
+	for i, index := range indexes {
+		addresses[index] = external[i]
+	}
+}
+
+// matchNetworks returns the index of the first item matching the address in the network, -1 if none matches.
+// Each item is a network name or a CIDR.
+func matchNetworks(address, network string, items []string) int {
+	ip := net.ParseIP(address)
+	for i, item := range items {
+		if network == item {
+			return i
+		}
+		if _, cidr, err := net.ParseCIDR(item); err == nil && ip != nil && cidr.Contains(ip) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (e *EcsClient) ListSecurityGroups(instanceID string) ([]model.NovaSecurityGroup, error) {
 	var rst []model.NovaSecurityGroup
 	err := e.wrapper(func(c *ecs.EcsClient) (interface{}, error) {
@@ -360,17 +711,20 @@ func removeFromNodeAddresses(addresses *[]v1.NodeAddress, removeAddresses ...v1.
 }
 
 func (e *EcsClient) wrapper(handler func(*ecs.EcsClient) (interface{}, error), args ...interface{}) error {
-	return commonWrapper(func() (interface{}, error) {
-		hc := e.AuthOpts.GetHcClient("ecs")
+	return commonWrapper(e.AuthOpts.Context(), func() (interface{}, error) {
+		hc, err := e.AuthOpts.GetHcClient("ecs")
+		if err != nil {
+			return nil, err
+		}
 		return handler(ecs.NewEcsClient(hc))
 	}, OKCodes, args...)
 }
 
-// commonWrapper wrapper common steps.
+// commonWrapper wrapper common steps, the retries of the API call are stopped once ctx is done.
 // args[0]: string, keys
 // args[1]: interface, result
-func commonWrapper(handler func() (interface{}, error), okCodes []int, args ...interface{}) error {
-	response, err := handler()
+func commonWrapper(ctx context.Context, handler func() (interface{}, error), okCodes []int, args ...interface{}) error {
+	response, err := defaultRetryBudget.invoke(ctx, handler)
 	if err != nil {
 		klog.ErrorDepth(2, fmt.Sprintf("Error in wrapper handler(), args: %#v, error: %s", args, err))
 		return err
@@ -0,0 +1,825 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrapper
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+func TestIsServerID(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodeName string
+		expected bool
+	}{
+		{
+			name:     "ECS ID",
+			nodeName: "0b5f4d1e-7a8c-4f2b-9e6d-3a1c5b7e9f0a",
+			expected: true,
+		},
+		{
+			name:     "upper case ECS ID",
+			nodeName: "0B5F4D1E-7A8C-4F2B-9E6D-3A1C5B7E9F0A",
+			expected: true,
+		},
+		{
+			name:     "regular name",
+			nodeName: "k8s-node-01",
+			expected: false,
+		},
+		{
+			name:     "IP address",
+			nodeName: "192.168.0.10",
+			expected: false,
+		},
+		{
+			name:     "name with ECS ID",
+			nodeName: "node-0b5f4d1e-7a8c-4f2b-9e6d-3a1c5b7e9f0a",
+			expected: false,
+		},
+		{
+			name:     "ECS ID without hyphens",
+			nodeName: "0b5f4d1e7a8c4f2b9e6d3a1c5b7e9f0a",
+			expected: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			rst := isServerID(testCase.nodeName)
+			if rst != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, rst)
+			}
+		})
+	}
+}
+
+func TestBuildAddressesExternalIPPriority(t *testing.T) {
+	fixed := model.GetServerAddressOSEXTIPStypeEnum().FIXED
+	floating := model.GetServerAddressOSEXTIPStypeEnum().FLOATING
+	server := &model.ServerDetail{
+		Name: "k8s-node-01",
+		Addresses: map[string][]model.ServerAddress{
+			"vpc-a": {
+				{Addr: "192.168.0.10", OSEXTIPStype: &fixed},
+				{Addr: "100.85.0.10", OSEXTIPStype: &floating},
+			},
+			"vpc-b": {
+				{Addr: "192.168.1.10", OSEXTIPStype: &fixed},
+				{Addr: "100.95.0.10", OSEXTIPStype: &floating},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		priority []string
+		expected []v1.NodeAddress
+	}{
+		{
+			name:     "no priority",
+			priority: nil,
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: v1.NodeExternalIP, Address: "100.95.0.10"},
+			},
+		},
+		{
+			name:     "by network name",
+			priority: []string{"vpc-b"},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.95.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+			},
+		},
+		{
+			name:     "by CIDR",
+			priority: []string{"100.95.0.0/16", "vpc-a"},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.95.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+			},
+		},
+		{
+			name:     "not matched",
+			priority: []string{"vpc-c", "10.0.0.0/8"},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: v1.NodeExternalIP, Address: "100.95.0.10"},
+			},
+		},
+	}
+
+	e := &EcsClient{}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts := &config.NetworkingOptions{ExternalIPPriority: testCase.priority}
+			addresses, err := e.BuildAddresses(server, nil, opts)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(addresses, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestBuildAddressesFloatingIPPools(t *testing.T) {
+	fixed := model.GetServerAddressOSEXTIPStypeEnum().FIXED
+	floating := model.GetServerAddressOSEXTIPStypeEnum().FLOATING
+	// the node has a floating IP from the public internet pool and one from the partner network pool.
+	server := &model.ServerDetail{
+		Name: "k8s-node-01",
+		Addresses: map[string][]model.ServerAddress{
+			"vpc-a": {
+				{Addr: "192.168.0.10", OSEXTIPStype: &fixed},
+				{Addr: "100.85.0.10", OSEXTIPStype: &floating},
+				{Addr: "10.200.0.10", OSEXTIPStype: &floating},
+			},
+			"vpc-partner": {
+				{Addr: "192.168.1.10", OSEXTIPStype: &fixed},
+				{Addr: "10.201.0.10", OSEXTIPStype: &floating},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		pools    []string
+		expected []v1.NodeAddress
+	}{
+		{
+			name:  "all floating IPs by default",
+			pools: nil,
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeExternalIP, Address: "10.200.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: v1.NodeExternalIP, Address: "10.201.0.10"},
+			},
+		},
+		{
+			name:  "public internet pool by CIDR",
+			pools: []string{"100.85.0.0/16"},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+			},
+		},
+		{
+			name:  "pool by network name",
+			pools: []string{"vpc-partner"},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: v1.NodeExternalIP, Address: "10.201.0.10"},
+			},
+		},
+		{
+			name:  "no floating IP selected",
+			pools: []string{"172.16.0.0/12"},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+			},
+		},
+	}
+
+	e := &EcsClient{}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts := &config.NetworkingOptions{FloatingIPPools: testCase.pools}
+			addresses, err := e.BuildAddresses(server, nil, opts)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(addresses, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestBuildAddressesInternalFloatingIPs(t *testing.T) {
+	fixed := model.GetServerAddressOSEXTIPStypeEnum().FIXED
+	floating := model.GetServerAddressOSEXTIPStypeEnum().FLOATING
+	// the floating IP 10.200.0.10 is a private IP of the peered VPC.
+	server := &model.ServerDetail{
+		Name: "k8s-node-01",
+		Addresses: map[string][]model.ServerAddress{
+			"vpc-a": {
+				{Addr: "192.168.0.10", OSEXTIPStype: &fixed},
+				{Addr: "100.85.0.10", OSEXTIPStype: &floating},
+				{Addr: "10.200.0.10", OSEXTIPStype: &floating},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		opts     *config.NetworkingOptions
+		expected []v1.NodeAddress
+	}{
+		{
+			name: "floating IPs are external by default",
+			opts: &config.NetworkingOptions{},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeExternalIP, Address: "10.200.0.10"},
+			},
+		},
+		{
+			name: "floating IP in the override CIDR",
+			opts: &config.NetworkingOptions{InternalFloatingIPs: []string{"10.200.0.0/16"}},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeInternalIP, Address: "10.200.0.10"},
+			},
+		},
+		{
+			name: "override takes precedence over the pools",
+			opts: &config.NetworkingOptions{
+				InternalFloatingIPs: []string{"10.200.0.0/16"},
+				FloatingIPPools:     []string{"100.85.0.0/16"},
+			},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeInternalIP, Address: "10.200.0.10"},
+			},
+		},
+		{
+			name: "floating IPs in the override network",
+			opts: &config.NetworkingOptions{InternalFloatingIPs: []string{"vpc-a"}},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeInternalIP, Address: "100.85.0.10"},
+				{Type: v1.NodeInternalIP, Address: "10.200.0.10"},
+			},
+		},
+	}
+
+	e := &EcsClient{}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			addresses, err := e.BuildAddresses(server, nil, testCase.opts)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(addresses, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestBuildAddressesWithoutExternalIP(t *testing.T) {
+	fixed := model.GetServerAddressOSEXTIPStypeEnum().FIXED
+	floating := model.GetServerAddressOSEXTIPStypeEnum().FLOATING
+	active, fixedIP := "ACTIVE", "192.168.0.10"
+	interfaces := []model.InterfaceAttachment{
+		{PortState: &active, FixedIps: &[]model.ServerInterfaceFixedIp{{IpAddress: &fixedIP}}},
+	}
+
+	tests := []struct {
+		name       string
+		server     *model.ServerDetail
+		interfaces []model.InterfaceAttachment
+		expected   []v1.NodeAddress
+	}{
+		{
+			name:     "no address",
+			server:   &model.ServerDetail{Name: "k8s-node-01"},
+			expected: []v1.NodeAddress{},
+		},
+		{
+			name: "internal only",
+			server: &model.ServerDetail{
+				Name: "k8s-node-01",
+				Addresses: map[string][]model.ServerAddress{
+					"vpc-a": {{Addr: "192.168.0.10", OSEXTIPStype: &fixed}},
+				},
+			},
+			interfaces: interfaces,
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+			},
+		},
+		{
+			name: "internal and external",
+			server: &model.ServerDetail{
+				Name: "k8s-node-01",
+				Addresses: map[string][]model.ServerAddress{
+					"vpc-a": {
+						{Addr: "192.168.0.10", OSEXTIPStype: &fixed},
+						{Addr: "100.85.0.10", OSEXTIPStype: &floating},
+					},
+				},
+			},
+			interfaces: interfaces,
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.0.10"},
+				{Type: v1.NodeExternalIP, Address: "100.85.0.10"},
+			},
+		},
+	}
+
+	e := &EcsClient{}
+	for _, testCase := range tests {
+		for _, warn := range []bool{false, true} {
+			t.Run(testCase.name, func(t *testing.T) {
+				opts := &config.NetworkingOptions{WarnMissingExternalIP: warn}
+				addresses, err := e.BuildAddresses(testCase.server, testCase.interfaces, opts)
+				if err != nil {
+					t.Fatalf("expected: nil, got: %v", err)
+				}
+				if addresses == nil || !reflect.DeepEqual(addresses, testCase.expected) {
+					t.Fatalf("expected: %#v, got: %#v", testCase.expected, addresses)
+				}
+			})
+		}
+	}
+}
+
+func TestEcsClientInProject(t *testing.T) {
+	e := &EcsClient{AuthOpts: &config.AuthOptions{Region: "ap-southeast-1", ProjectID: "default"}}
+
+	if got := e.InProject(""); got != e {
+		t.Fatalf("expected: the default client, got: %v", got.AuthOpts)
+	}
+	if got := e.InProject("default"); got != e {
+		t.Fatalf("expected: the default client, got: %v", got.AuthOpts)
+	}
+
+	got := e.InProject("other")
+	if got.AuthOpts.ProjectID != "other" || got.AuthOpts.Region != "ap-southeast-1" {
+		t.Fatalf("expected: the client of project other, got: %v", got.AuthOpts)
+	}
+	if e.AuthOpts.ProjectID != "default" {
+		t.Fatalf("expected: the default client is unchanged, got: %v", e.AuthOpts)
+	}
+}
+
+// fakeServerPages serves the servers page by page, the offset is the page number starting from 1.
+// The servers are filtered by the IP if IpEq is specified.
+type fakeServerPages struct {
+	servers []model.ServerDetail
+	pages   []int32
+}
+
+func (f *fakeServerPages) list(req *model.ListServersDetailsRequest) (*model.ListServersDetailsResponse, error) {
+	f.pages = append(f.pages, *req.Offset)
+	servers := f.servers
+	if req.IpEq != nil {
+		servers = make([]model.ServerDetail, 0)
+		for idx := range f.servers {
+			if hasServerAddress(&f.servers[idx], *req.IpEq) {
+				servers = append(servers, f.servers[idx])
+			}
+		}
+	}
+	start := int(*req.Limit) * int(*req.Offset-1)
+	end := start + int(*req.Limit)
+	if start > len(servers) {
+		start = len(servers)
+	}
+	if end > len(servers) {
+		end = len(servers)
+	}
+	page := servers[start:end]
+	count := int32(len(servers))
+	return &model.ListServersDetailsResponse{Servers: &page, Count: &count}, nil
+}
+
+func TestListServerPages(t *testing.T) {
+	servers := make([]model.ServerDetail, 0, 20000)
+	for i := 0; i < 20000; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		if i == 4321 || i == 15000 {
+			name = "duplicated"
+		}
+		servers = append(servers, model.ServerDetail{Id: fmt.Sprintf("id-%d", i), Name: name})
+	}
+
+	// visitName collects the servers of the name, and stops at the second match.
+	visitName := func(name string, matched *[]string) func([]model.ServerDetail) bool {
+		return func(page []model.ServerDetail) bool {
+			for _, s := range page {
+				if s.Name == name {
+					*matched = append(*matched, s.Id)
+				}
+			}
+			return len(*matched) > 1
+		}
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		pageSize   int
+		maxResults int
+		expected   []string
+		pages      int
+	}{
+		{
+			name:       "stop at the match",
+			target:     "node-250",
+			pageSize:   100,
+			maxResults: 10000,
+			expected:   []string{"id-250"},
+			pages:      100,
+		},
+		{
+			name:       "stop at the multiple matches",
+			target:     "duplicated",
+			pageSize:   1000,
+			maxResults: 20000,
+			expected:   []string{"id-4321", "id-15000"},
+			pages:      16,
+		},
+		{
+			name:       "not found within the cap",
+			target:     "node-12345",
+			pageSize:   1000,
+			maxResults: 10000,
+			expected:   []string{},
+			pages:      10,
+		},
+		{
+			name:       "cap in the middle of a page",
+			target:     "node-1050",
+			pageSize:   100,
+			maxResults: 1050,
+			expected:   []string{},
+			pages:      11,
+		},
+		{
+			name:       "not found after all the pages",
+			target:     "missing",
+			pageSize:   1000,
+			maxResults: 30000,
+			expected:   []string{},
+			pages:      20,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			fake := &fakeServerPages{servers: servers}
+			matched := make([]string, 0)
+			var visited int
+			visit := visitName(testCase.target, &matched)
+			err := listServerPages(fake.list, model.ListServersDetailsRequest{}, testCase.pageSize,
+				testCase.maxResults, func(page []model.ServerDetail) bool {
+					visited += len(page)
+					return visit(page)
+				})
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(matched, testCase.expected) {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, matched)
+			}
+			if len(fake.pages) != testCase.pages {
+				t.Fatalf("expected: %v pages, got: %v", testCase.pages, len(fake.pages))
+			}
+			if visited > testCase.maxResults {
+				t.Fatalf("expected at most %v servers visited, got: %v", testCase.maxResults, visited)
+			}
+		})
+	}
+}
+
+func TestPickServer(t *testing.T) {
+	deleting := model.ServerDetail{Id: "old", Status: "DELETING", Created: "2023-03-01T08:00:00Z"}
+	oldActive := model.ServerDetail{Id: "old", Status: "ACTIVE", Created: "2023-03-01T08:00:00Z"}
+	newActive := model.ServerDetail{Id: "new", Status: "ACTIVE", Created: "2023-03-02T08:00:00Z"}
+	building := model.ServerDetail{Id: "new", Status: "BUILD", Created: "2023-03-02T08:00:00Z"}
+
+	tests := []struct {
+		name     string
+		policy   string
+		servers  []model.ServerDetail
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "single server",
+			policy:   config.DuplicateServerNamePolicyFail,
+			servers:  []model.ServerDetail{newActive},
+			expected: "new",
+		},
+		{
+			name:     "pick-first",
+			policy:   config.DuplicateServerNamePolicyPickFirst,
+			servers:  []model.ServerDetail{deleting, newActive},
+			expected: "old",
+		},
+		{
+			name:    "fail",
+			policy:  config.DuplicateServerNamePolicyFail,
+			servers: []model.ServerDetail{deleting, newActive},
+			wantErr: true,
+		},
+		{
+			name:     "pick-active during replacement",
+			policy:   config.DuplicateServerNamePolicyPickActive,
+			servers:  []model.ServerDetail{deleting, newActive},
+			expected: "new",
+		},
+		{
+			name:    "pick-active with two ACTIVE servers",
+			policy:  config.DuplicateServerNamePolicyPickActive,
+			servers: []model.ServerDetail{oldActive, newActive},
+			wantErr: true,
+		},
+		{
+			name:     "pick-newest with two ACTIVE servers",
+			policy:   config.DuplicateServerNamePolicyPickNewest,
+			servers:  []model.ServerDetail{newActive, oldActive},
+			expected: "new",
+		},
+		{
+			name:     "pick-newest skips the newer server not ACTIVE",
+			policy:   config.DuplicateServerNamePolicyPickNewest,
+			servers:  []model.ServerDetail{oldActive, building},
+			expected: "old",
+		},
+		{
+			name:   "pick-newest with the same creation time",
+			policy: config.DuplicateServerNamePolicyPickNewest,
+			servers: []model.ServerDetail{newActive,
+				{Id: "another", Status: "ACTIVE", Created: newActive.Created}},
+			wantErr: true,
+		},
+		{
+			name:    "pick-newest without ACTIVE servers",
+			policy:  config.DuplicateServerNamePolicyPickNewest,
+			servers: []model.ServerDetail{deleting, building},
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			server, err := pickServer(te.servers, "node-1", te.policy)
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", server.Id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if server.Id != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, server.Id)
+			}
+		})
+	}
+}
+
+func TestCheckServerScope(t *testing.T) {
+	const projectID = "0a1b2c3d4e5f60718293a4b5c6d7e8f9"
+	eps := func(id string) *string { return &id }
+
+	tests := []struct {
+		name     string
+		opts     config.AuthOptions
+		server   model.ServerDetail
+		expected codes.Code
+	}{
+		{
+			name:     "same project",
+			opts:     config.AuthOptions{ProjectID: projectID},
+			server:   model.ServerDetail{Id: "server-1", TenantId: projectID},
+			expected: codes.OK,
+		},
+		{
+			name:     "different project",
+			opts:     config.AuthOptions{ProjectID: projectID},
+			server:   model.ServerDetail{Id: "server-1", TenantId: "9f8e7d6c5b4a30291807f6e5d4c3b2a1"},
+			expected: codes.NotFound,
+		},
+		{
+			name: "different project with the fail policy",
+			opts: config.AuthOptions{ProjectID: projectID, ForeignServerPolicy: config.ForeignServerPolicyFail},
+			server: model.ServerDetail{Id: "server-1",
+				TenantId: "9f8e7d6c5b4a30291807f6e5d4c3b2a1"},
+			expected: codes.PermissionDenied,
+		},
+		{
+			name:     "discovered project",
+			opts:     config.AuthOptions{},
+			server:   model.ServerDetail{Id: "server-1", TenantId: "9f8e7d6c5b4a30291807f6e5d4c3b2a1"},
+			expected: codes.OK,
+		},
+		{
+			name: "different enterprise project",
+			opts: config.AuthOptions{ProjectID: projectID, EnterpriseProjectID: "eps-1"},
+			server: model.ServerDetail{Id: "server-1", TenantId: projectID,
+				EnterpriseProjectId: eps("eps-2")},
+			expected: codes.NotFound,
+		},
+		{
+			name: "same enterprise project",
+			opts: config.AuthOptions{ProjectID: projectID, EnterpriseProjectID: "eps-1"},
+			server: model.ServerDetail{Id: "server-1", TenantId: projectID,
+				EnterpriseProjectId: eps("eps-1")},
+			expected: codes.OK,
+		},
+		{
+			name:     "enterprise project not configured",
+			opts:     config.AuthOptions{ProjectID: projectID},
+			server:   model.ServerDetail{Id: "server-1", TenantId: projectID, EnterpriseProjectId: eps("eps-2")},
+			expected: codes.OK,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			err := checkServerScope(&te.server, &te.opts)
+			if got := status.Code(err); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, err)
+			}
+			if te.expected == codes.NotFound && !common.IsNotFound(err) {
+				t.Fatalf("expected: the foreign server is not found, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestFindServersByPrivateIP(t *testing.T) {
+	newServer := func(id, vpcID, ip string) model.ServerDetail {
+		return model.ServerDetail{Id: id, Addresses: map[string][]model.ServerAddress{vpcID: {{Addr: ip}}}}
+	}
+	servers := make([]model.ServerDetail, 0, 250)
+	for i := 0; i < 250; i++ {
+		servers = append(servers, newServer(fmt.Sprintf("id-%d", i), "vpc-1", fmt.Sprintf("192.168.%d.%d", i/200, i%200)))
+	}
+	servers = append(servers, newServer("peer", "vpc-2", "192.168.0.10"), newServer("other", "vpc-2", "10.0.0.8"),
+		newServer("duplicated", "vpc-3", "10.0.0.8"))
+
+	tests := []struct {
+		name     string
+		ip       string
+		vpcID    string
+		expected []string
+	}{
+		{name: "single match", ip: "192.168.1.20", vpcID: "vpc-1", expected: []string{"id-220"}},
+		{name: "match in the VPC preferred", ip: "192.168.0.10", vpcID: "vpc-1", expected: []string{"id-10"}},
+		{name: "multiple matches without VPC", ip: "192.168.0.10", expected: []string{"id-10", "peer"}},
+		{name: "multiple matches out of the VPC", ip: "10.0.0.8", vpcID: "vpc-1",
+			expected: []string{"other", "duplicated"}},
+		{name: "not found", ip: "172.16.0.8", vpcID: "vpc-1", expected: []string{}},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			fake := &fakeServerPages{servers: servers}
+			matched, err := findServersByPrivateIP(fake.list, te.ip, te.vpcID, 100, 10000)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			ids := make([]string, 0, len(matched))
+			for _, s := range matched {
+				ids = append(ids, s.Id)
+			}
+			if !reflect.DeepEqual(ids, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, ids)
+			}
+			if len(fake.pages) != 1 {
+				t.Fatalf("expected: the servers filtered by IP in %d page, got: %v pages", 1, len(fake.pages))
+			}
+		})
+	}
+}
+
+func TestGetServerOnThrottle(t *testing.T) {
+	throttled := sdkerr.ServiceResponseError{StatusCode: 429, ErrorMessage: "too many requests"}
+	server := model.ServerDetail{Id: "server-1", Name: "node-1"}
+
+	tests := []struct {
+		name           string
+		showErr        error
+		listed         []model.ServerDetail
+		listErr        error
+		listOnThrottle bool
+		expected       string
+		expectedErr    error
+		expectedLists  int
+	}{
+		{
+			name:           "show succeeds",
+			listOnThrottle: true,
+			expected:       "node-1",
+		},
+		{
+			name:           "show throttled and list succeeds",
+			showErr:        throttled,
+			listed:         []model.ServerDetail{server},
+			listOnThrottle: true,
+			expected:       "node-1",
+			expectedLists:  1,
+		},
+		{
+			name:        "fallback disabled",
+			showErr:     throttled,
+			listed:      []model.ServerDetail{server},
+			expectedErr: throttled,
+		},
+		{
+			name:           "show throttled and list throttled",
+			showErr:        throttled,
+			listErr:        throttled,
+			listOnThrottle: true,
+			expectedErr:    throttled,
+			expectedLists:  1,
+		},
+		{
+			name:           "show throttled and not listed",
+			showErr:        throttled,
+			listed:         []model.ServerDetail{},
+			listOnThrottle: true,
+			expectedErr:    throttled,
+			expectedLists:  1,
+		},
+		{
+			name:           "show not found",
+			showErr:        status.Errorf(codes.NotFound, "not found"),
+			listed:         []model.ServerDetail{server},
+			listOnThrottle: true,
+			expectedErr:    status.Errorf(codes.NotFound, "not found"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			show := func(id string) (*model.ServerDetail, error) {
+				if tt.showErr != nil {
+					return nil, tt.showErr
+				}
+				return &server, nil
+			}
+			lists := 0
+			list := func(req *model.ListServersDetailsRequest) (*model.ListServersDetailsResponse, error) {
+				lists++
+				if *req.ServerId != server.Id {
+					return nil, fmt.Errorf("expected the ECSs filtered by ID %s, got: %s", server.Id, *req.ServerId)
+				}
+				if tt.listErr != nil {
+					return nil, tt.listErr
+				}
+				return &model.ListServersDetailsResponse{Servers: &tt.listed}, nil
+			}
+
+			got, err := getServer(show, list, server.Id, tt.listOnThrottle)
+			if fmt.Sprint(err) != fmt.Sprint(tt.expectedErr) {
+				t.Fatalf("expected: %v, got: %v", tt.expectedErr, err)
+			}
+			if err == nil && got.Name != tt.expected {
+				t.Fatalf("expected: %s, got: %s", tt.expected, got.Name)
+			}
+			if lists != tt.expectedLists {
+				t.Fatalf("expected: %d lists, got: %d", tt.expectedLists, lists)
+			}
+		})
+	}
+}
@@ -19,7 +19,9 @@ package wrapper
 import (
 	eip "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/services/eip/v2/model"
+	"k8s.io/utils/pointer"
 
+	wpmodel "sigs.k8s.io/cloud-provider-huaweicloud/pkg/cloudprovider/huaweicloud/wrapper/model"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
 )
 
@@ -55,6 +57,32 @@ func (e *EIpClient) List(req *model.ListPublicipsRequest) ([]model.PublicipShowR
 	return rst, err
 }
 
+// ListByAlias lists the EIPs with the alias, the port ID is set if the EIP is bound.
+func (e *EIpClient) ListByAlias(alias string) ([]model.PublicipShowResp, error) {
+	var rst []wpmodel.PublicipAlias
+	err := e.wrapper(func(c *eip.EipClient) (interface{}, error) {
+		requestDef := wpmodel.GenReqDefForListPublicipsByAlias()
+		resp, err := c.HcClient.Sync(&wpmodel.ListPublicipsByAliasRequest{Alias: &[]string{alias}}, requestDef)
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*wpmodel.ListPublicipsByAliasResponse), nil
+	}, "Publicips", &rst)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]model.PublicipShowResp, 0, len(rst))
+	for _, ip := range rst {
+		resp := model.PublicipShowResp{Id: ip.Id, Alias: ip.Alias}
+		if ip.Vnic != nil {
+			resp.PortId = ip.Vnic.PortId
+		}
+		ips = append(ips, resp)
+	}
+	return ips, nil
+}
+
 func (e *EIpClient) Update(id string, opts *model.UpdatePublicipOption) error {
 	return e.wrapper(func(c *eip.EipClient) (interface{}, error) {
 		return c.UpdatePublicip(&model.UpdatePublicipRequest{
@@ -81,9 +109,48 @@ func (e *EIpClient) Delete(id string) error {
 	})
 }
 
+// ListTags returns the tags of the EIP by the key.
+func (e *EIpClient) ListTags(id string) (map[string]string, error) {
+	var rst []model.ResourceTagResp
+	err := e.wrapper(func(c *eip.EipClient) (interface{}, error) {
+		return c.ShowPublicipTags(&model.ShowPublicipTagsRequest{PublicipId: id})
+	}, "Tags", &rst)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(rst))
+	for _, t := range rst {
+		if t.Key != nil {
+			tags[*t.Key] = pointer.StringDeref(t.Value, "")
+		}
+	}
+	return tags, nil
+}
+
+// AddTags adds the tags to the EIP, the values of the existing keys are overwritten.
+func (e *EIpClient) AddTags(id string, tags map[string]string) error {
+	opts := make([]model.ResourceTagOption, 0, len(tags))
+	for k, v := range tags {
+		opts = append(opts, model.ResourceTagOption{Key: k, Value: v})
+	}
+	return e.wrapper(func(c *eip.EipClient) (interface{}, error) {
+		return c.BatchCreatePublicipTags(&model.BatchCreatePublicipTagsRequest{
+			PublicipId: id,
+			Body: &model.BatchCreatePublicipTagsRequestBody{
+				Tags:   opts,
+				Action: model.GetBatchCreatePublicipTagsRequestBodyActionEnum().CREATE,
+			},
+		})
+	})
+}
+
 func (e *EIpClient) wrapper(handler func(*eip.EipClient) (interface{}, error), args ...interface{}) error {
-	return commonWrapper(func() (interface{}, error) {
-		hc := e.AuthOpts.GetHcClient("vpc")
+	return commonWrapper(e.AuthOpts.Context(), func() (interface{}, error) {
+		hc, err := e.AuthOpts.GetHcClient("vpc")
+		if err != nil {
+			return nil, err
+		}
 		return handler(eip.NewEipClient(hc))
 	}, OKCodes, args...)
 }
@@ -0,0 +1,133 @@
+// nolint: golint
+package model
+
+import (
+	"strings"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/utils"
+)
+
+// Request Object
+type UpdateListenerProxyProtocolRequest struct {
+
+	// 监听器ID。
+	ListenerId string `json:"listener_id"`
+
+	Body *UpdateListenerProxyProtocolRequestBody `json:"body,omitempty"`
+}
+
+func (o UpdateListenerProxyProtocolRequest) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "UpdateListenerProxyProtocolRequest struct{}"
+	}
+
+	return strings.Join([]string{"UpdateListenerProxyProtocolRequest", string(data)}, " ")
+}
+
+type UpdateListenerProxyProtocolRequestBody struct {
+	Listener *UpdateListenerProxyProtocolOption `json:"listener"`
+}
+
+// The SDK does not support the proxy_protocol_enable field yet.
+type UpdateListenerProxyProtocolOption struct {
+
+	// 是否开启proxy_protocol。仅TCP监听器支持。
+	ProxyProtocolEnable *bool `json:"proxy_protocol_enable,omitempty"`
+}
+
+// Response Object
+type UpdateListenerProxyProtocolResponse struct {
+	HttpStatusCode int `json:"-"`
+}
+
+func (o UpdateListenerProxyProtocolResponse) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "UpdateListenerProxyProtocolResponse struct{}"
+	}
+
+	return strings.Join([]string{"UpdateListenerProxyProtocolResponse", string(data)}, " ")
+}
+
+// Request Object
+type ShowListenerProxyProtocolRequest struct {
+
+	// 监听器ID。
+	ListenerId string `json:"listener_id"`
+}
+
+func (o ShowListenerProxyProtocolRequest) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "ShowListenerProxyProtocolRequest struct{}"
+	}
+
+	return strings.Join([]string{"ShowListenerProxyProtocolRequest", string(data)}, " ")
+}
+
+// Response Object
+type ShowListenerProxyProtocolResponse struct {
+	Listener *ListenerProxyProtocol `json:"listener,omitempty"`
+
+	HttpStatusCode int `json:"-"`
+}
+
+func (o ShowListenerProxyProtocolResponse) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "ShowListenerProxyProtocolResponse struct{}"
+	}
+
+	return strings.Join([]string{"ShowListenerProxyProtocolResponse", string(data)}, " ")
+}
+
+// The SDK does not support the proxy_protocol_enable field yet.
+type ListenerProxyProtocol struct {
+
+	// 是否开启proxy_protocol。仅TCP监听器支持。
+	ProxyProtocolEnable bool `json:"proxy_protocol_enable"`
+}
+
+// Request Object
+type UpdateListenerDefaultPoolRequest struct {
+
+	// 监听器ID。
+	ListenerId string `json:"listener_id"`
+
+	Body *UpdateListenerDefaultPoolRequestBody `json:"body,omitempty"`
+}
+
+func (o UpdateListenerDefaultPoolRequest) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "UpdateListenerDefaultPoolRequest struct{}"
+	}
+
+	return strings.Join([]string{"UpdateListenerDefaultPoolRequest", string(data)}, " ")
+}
+
+type UpdateListenerDefaultPoolRequestBody struct {
+	Listener *UpdateListenerDefaultPoolOption `json:"listener"`
+}
+
+// The default_pool_id must be sent as null to remove the default pool, the SDK always omits it.
+type UpdateListenerDefaultPoolOption struct {
+
+	// 监听器的默认后端云服务器组ID。当设置为null时表示解除默认后端云服务器组。
+	DefaultPoolId *string `json:"default_pool_id"`
+}
+
+// Response Object
+type UpdateListenerDefaultPoolResponse struct {
+	HttpStatusCode int `json:"-"`
+}
+
+func (o UpdateListenerDefaultPoolResponse) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "UpdateListenerDefaultPoolResponse struct{}"
+	}
+
+	return strings.Join([]string{"UpdateListenerDefaultPoolResponse", string(data)}, " ")
+}
@@ -0,0 +1,59 @@
+// nolint: golint
+package model
+
+import (
+	"strings"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/utils"
+)
+
+// Request Object
+type ListPublicipsByAliasRequest struct {
+
+	// 根据alias过滤。
+	Alias *[]string `json:"alias,omitempty"`
+}
+
+func (o ListPublicipsByAliasRequest) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "ListPublicipsByAliasRequest struct{}"
+	}
+
+	return strings.Join([]string{"ListPublicipsByAliasRequest", string(data)}, " ")
+}
+
+// Response Object
+type ListPublicipsByAliasResponse struct {
+	Publicips *[]PublicipAlias `json:"publicips,omitempty"`
+
+	HttpStatusCode int `json:"-"`
+}
+
+func (o ListPublicipsByAliasResponse) String() string {
+	data, err := utils.Marshal(o)
+	if err != nil {
+		return "ListPublicipsByAliasResponse struct{}"
+	}
+
+	return strings.Join([]string{"ListPublicipsByAliasResponse", string(data)}, " ")
+}
+
+// The SDK of EIP v2 does not support filtering the EIPs by the alias, which is supported by the API of EIP v3.
+type PublicipAlias struct {
+
+	// 弹性公网IP唯一标识。
+	Id *string `json:"id,omitempty"`
+
+	// 弹性公网IP名称。
+	Alias *string `json:"alias,omitempty"`
+
+	// 弹性公网IP绑定的端口信息。
+	Vnic *PublicipVnic `json:"vnic,omitempty"`
+}
+
+type PublicipVnic struct {
+
+	// 端口ID。
+	PortId *string `json:"port_id,omitempty"`
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrapper
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+)
+
+const (
+	// maxRetries is the maximum number of the retries of an API call.
+	maxRetries = 3
+	// retryInterval is the initial interval between the retries, it is doubled after each retry.
+	retryInterval = time.Second
+)
+
+var (
+	retryBudgetExhaustedTotal = metrics.NewCounter(&metrics.CounterOpts{
+		Namespace:      "cloudprovider",
+		Subsystem:      "huaweicloud",
+		Name:           "retry_budget_exhausted_total",
+		Help:           "Number of the API call retries skipped because the retry budget is exhausted.",
+		StabilityLevel: metrics.ALPHA,
+	})
+	registerMetricsOnce sync.Once
+
+	// defaultRetryBudget is shared by all the clients, it is replaced by SetRetryBudget at startup.
+	defaultRetryBudget = newRetryBudget(config.DefaultRetryBudget, config.DefaultRetryBudgetRefillRatio)
+	// retryPredicate decides whether a failed API call is retried, it is replaced by SetRetryPredicate at startup.
+	retryPredicate config.RetryPredicate = isRetryable
+)
+
+// RegisterMetrics registers the metrics of the clients with the metrics registry of the CCM.
+func RegisterMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(retryBudgetExhaustedTotal)
+	})
+}
+
+// SetRetryBudget replaces the retry budget shared by all the clients.
+func SetRetryBudget(budget int, refillRatio float64) {
+	defaultRetryBudget = newRetryBudget(budget, refillRatio)
+}
+
+// SetRetryPredicate replaces the predicate that decides whether a failed API call is retried,
+// nil restores the default predicate isRetryable.
+func SetRetryPredicate(predicate config.RetryPredicate) {
+	if predicate == nil {
+		predicate = isRetryable
+	}
+	retryPredicate = predicate
+}
+
+// retryBudget is a token bucket that throttles the retries package-wide. Each retry takes a token and each
+// successful call refills a part of a token, so the retries are curtailed when the success rate drops,
+// instead of multiplying the load on the degraded API.
+type retryBudget struct {
+	mu          sync.Mutex
+	tokens      float64
+	capacity    float64
+	refillRatio float64
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryBudget(budget int, refillRatio float64) *retryBudget {
+	return &retryBudget{
+		tokens:      float64(budget),
+		capacity:    float64(budget),
+		refillRatio: refillRatio,
+		sleep:       sleepWithContext,
+	}
+}
+
+// sleepWithContext waits for the duration, it returns the error of ctx if ctx is done before.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (b *retryBudget) refill() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += b.refillRatio
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// withdraw takes a token for a retry, it returns false if the budget is exhausted or the retries are disabled
+// by a zero budget.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.capacity == 0 {
+		return false
+	}
+	if b.tokens < 1 {
+		retryBudgetExhaustedTotal.Inc()
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// invoke calls the handler and retries it within the budget if the error is retryable by the retry predicate.
+// The retries are stopped once ctx is done, such as the reconcile making the call is cancelled.
+func (b *retryBudget) invoke(ctx context.Context, handler func() (interface{}, error)) (interface{}, error) {
+	backoff := wait.Backoff{Duration: retryInterval, Factor: 2, Jitter: 0.1, Steps: maxRetries}
+	for retries := 0; ; retries++ {
+		response, err := handler()
+		if err == nil {
+			b.refill()
+			return response, nil
+		}
+		if !retryPredicate(err) || retries >= maxRetries {
+			return response, err
+		}
+		if !b.withdraw() {
+			klog.V(4).Infof("Skip retrying the API call, the retry budget is exhausted: %s", err)
+			return response, err
+		}
+
+		interval := backoff.Step()
+		klog.V(4).Infof("Retrying the API call in %s, attempt %d: %s", interval, retries+1, err)
+		if ctxErr := b.sleep(ctx, interval); ctxErr != nil {
+			klog.V(4).Infof("Stop retrying the API call: %s: %s", ctxErr, err)
+			return response, err
+		}
+	}
+}
+
+// isRetryable is the default retry predicate, it returns true if the API call is throttled or rejected by
+// the gateway without being processed, so it is safe to retry. The status codes 500 and 504 are not retried,
+// the call may have been processed, such as creating a resource.
+func isRetryable(err error) bool {
+	code := 0
+	if e, ok := err.(sdkerr.ServiceResponseError); ok {
+		code = e.StatusCode
+	}
+	if e, ok := err.(*sdkerr.ServiceResponseError); ok {
+		code = e.StatusCode
+	}
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+// fakeAPI fails the calls with the status code until it is healthy, and counts the calls.
+type fakeAPI struct {
+	code  int
+	calls int
+}
+
+func (f *fakeAPI) call() (interface{}, error) {
+	f.calls++
+	if f.code != 0 {
+		return nil, &sdkerr.ServiceResponseError{StatusCode: f.code}
+	}
+	return struct{}{}, nil
+}
+
+func newTestRetryBudget(budget int, refillRatio float64) *retryBudget {
+	b := newRetryBudget(budget, refillRatio)
+	b.sleep = func(context.Context, time.Duration) error { return nil }
+	return b
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "throttled", err: &sdkerr.ServiceResponseError{StatusCode: 429}, expected: true},
+		{name: "unavailable", err: sdkerr.ServiceResponseError{StatusCode: 503}, expected: true},
+		{name: "bad gateway", err: &sdkerr.ServiceResponseError{StatusCode: 502}, expected: true},
+		{name: "gateway timeout", err: &sdkerr.ServiceResponseError{StatusCode: 504}, expected: false},
+		{name: "internal error", err: &sdkerr.ServiceResponseError{StatusCode: 500}, expected: false},
+		{name: "not found", err: &sdkerr.ServiceResponseError{StatusCode: 404}, expected: false},
+		{name: "other error", err: fmt.Errorf("connection reset"), expected: false},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			if got := isRetryable(te.err); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryBudgetRetries(t *testing.T) {
+	b := newTestRetryBudget(10, 0.1)
+	api := &fakeAPI{code: 429}
+
+	if _, err := b.invoke(context.TODO(), api.call); err == nil {
+		t.Fatalf("expected: an error, got: nil")
+	}
+	if api.calls != maxRetries+1 {
+		t.Fatalf("expected: %v, got: %v", maxRetries+1, api.calls)
+	}
+
+	api = &fakeAPI{code: 404}
+	if _, err := b.invoke(context.TODO(), api.call); err == nil || api.calls != 1 {
+		t.Fatalf("expected: the error is not retried, got: %v calls, %v", api.calls, err)
+	}
+}
+
+func TestRetryBudgetDisabled(t *testing.T) {
+	b := newTestRetryBudget(0, 0.1)
+	api := &fakeAPI{code: 429}
+
+	if _, err := b.invoke(context.TODO(), api.call); err == nil || api.calls != 1 {
+		t.Fatalf("expected: the error is not retried, got: %v calls, %v", api.calls, err)
+	}
+}
+
+func TestRetryBudgetCancelled(t *testing.T) {
+	// the reconcile making the call is cancelled, the call is not retried after the backoff.
+	b := newRetryBudget(10, 0.1)
+	api := &fakeAPI{code: 503}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	start := time.Now()
+	if _, err := b.invoke(ctx, api.call); err == nil || api.calls != 1 {
+		t.Fatalf("expected: the error is not retried, got: %v calls, %v", api.calls, err)
+	}
+	if elapsed := time.Since(start); elapsed >= retryInterval {
+		t.Fatalf("expected: the backoff is stopped, got: %v", elapsed)
+	}
+}
+
+func TestRetryBudgetCustomPredicate(t *testing.T) {
+	defer SetRetryPredicate(nil)
+
+	// the custom predicate retries the internal errors instead of the throttled calls.
+	SetRetryPredicate(func(err error) bool {
+		e, ok := err.(*sdkerr.ServiceResponseError)
+		return ok && e.StatusCode == 500
+	})
+	b := newTestRetryBudget(10, 0.1)
+
+	api := &fakeAPI{code: 500}
+	if _, err := b.invoke(context.TODO(), api.call); err == nil || api.calls != maxRetries+1 {
+		t.Fatalf("expected: %v calls, got: %v calls, %v", maxRetries+1, api.calls, err)
+	}
+	api = &fakeAPI{code: 429}
+	if _, err := b.invoke(context.TODO(), api.call); err == nil || api.calls != 1 {
+		t.Fatalf("expected: the error is not retried, got: %v calls, %v", api.calls, err)
+	}
+
+	// nil restores the default predicate.
+	SetRetryPredicate(nil)
+	api = &fakeAPI{code: 500}
+	if _, err := b.invoke(context.TODO(), api.call); err == nil || api.calls != 1 {
+		t.Fatalf("expected: the error is not retried, got: %v calls, %v", api.calls, err)
+	}
+	api = &fakeAPI{code: 429}
+	if _, err := b.invoke(context.TODO(), api.call); err == nil || api.calls != maxRetries+1 {
+		t.Fatalf("expected: %v calls, got: %v calls, %v", maxRetries+1, api.calls, err)
+	}
+}
+
+func TestRetryBudgetCurtailsRetries(t *testing.T) {
+	registry := metrics.NewKubeRegistry()
+	registry.MustRegister(retryBudgetExhaustedTotal)
+	before, _ := testutil.GetCounterMetricValue(retryBudgetExhaustedTotal)
+
+	b := newTestRetryBudget(10, 0.1)
+	api := &fakeAPI{code: 503}
+
+	// the whole API is degraded, the calls of the nodes are only retried within the budget.
+	for i := 0; i < 100; i++ {
+		_, _ = b.invoke(context.TODO(), api.call)
+	}
+	if expected := 100 + 10; api.calls != expected {
+		t.Fatalf("expected: %v calls, got: %v", expected, api.calls)
+	}
+	exhausted, err := testutil.GetCounterMetricValue(retryBudgetExhaustedTotal)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if exhausted-before == 0 {
+		t.Fatalf("expected: the exhaustion is recorded, got: %v", exhausted-before)
+	}
+
+	// the successful calls refill the budget, a token is earned back by every 4 successful calls.
+	b.refillRatio = 0.25
+	api.code = 0
+	for i := 0; i < 4; i++ {
+		_, _ = b.invoke(context.TODO(), api.call)
+	}
+	api.code, api.calls = 503, 0
+	_, _ = b.invoke(context.TODO(), api.call)
+	if api.calls != 2 {
+		t.Fatalf("expected: 2 calls, got: %v", api.calls)
+	}
+}
@@ -77,7 +77,7 @@ func (s *SharedLoadBalanceClient) CreateInstanceCompleted(req *model.CreateLoadb
 func (s *SharedLoadBalanceClient) WaitStatusActive(id string) (*model.LoadbalancerResp, error) {
 	var instance *model.LoadbalancerResp
 
-	err := common.WaitForCompleted(func() (bool, error) {
+	err := common.WaitForCompleted(s.AuthOpts.Context(), func() (bool, error) {
 		ins, err := s.GetInstance(id)
 		instance = ins
 		if err != nil {
@@ -139,7 +139,7 @@ func (s *SharedLoadBalanceClient) DeleteInstance(id string) error {
 		req := model.DeleteLoadbalancerRequest{
 			LoadbalancerId: id,
 		}
-		klog.Infof("Delete Req: %#v", req)
+		klog.V(4).Infof("Delete Req: %#v", req)
 		return c.DeleteLoadbalancer(&req)
 	})
 }
@@ -360,10 +360,11 @@ func (s *SharedLoadBalanceClient) ListMembers(req *model.ListMembersRequest) ([]
 	return rst, err
 }
 
-func (s *SharedLoadBalanceClient) UpdateMember(id string, req *model.UpdateMemberReq) (*model.MemberResp, error) {
+func (s *SharedLoadBalanceClient) UpdateMember(poolID, id string, req *model.UpdateMemberReq) (*model.MemberResp, error) {
 	var rst *model.MemberResp
 	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
 		return c.UpdateMember(&model.UpdateMemberRequest{
+			PoolId:   poolID,
 			MemberId: id,
 			Body: &model.UpdateMemberRequestBody{
 				Member: req,
@@ -401,9 +402,65 @@ func (s *SharedLoadBalanceClient) DeleteAllPoolMembers(poolID string) error {
 	return nil
 }
 
+/** Tags **/
+
+// ListTags returns the tags of the ELB instance by the key.
+func (s *SharedLoadBalanceClient) ListTags(id string) (map[string]string, error) {
+	var rst []model.ResourceTag
+	err := s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.ShowLoadbalancerTags(&model.ShowLoadbalancerTagsRequest{LoadbalancerId: id})
+	}, "Tags", &rst)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(rst))
+	for _, t := range rst {
+		tags[t.Key] = t.Value
+	}
+	return tags, nil
+}
+
+// AddTags adds the tags to the ELB instance, the values of the existing keys are overwritten.
+func (s *SharedLoadBalanceClient) AddTags(id string, tags map[string]string) error {
+	resourceTags := make([]model.ResourceTag, 0, len(tags))
+	for k, v := range tags {
+		resourceTags = append(resourceTags, model.ResourceTag{Key: k, Value: v})
+	}
+	return s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.BatchCreateLoadbalancerTags(&model.BatchCreateLoadbalancerTagsRequest{
+			LoadbalancerId: id,
+			Body: &model.BatchCreateLoadbalancerTagsRequestBody{
+				Action: model.GetBatchCreateLoadbalancerTagsRequestBodyActionEnum().CREATE,
+				Tags:   &resourceTags,
+			},
+		})
+	})
+}
+
+// DeleteTags deletes the tags of the keys from the ELB instance, the keys that do not exist are ignored.
+func (s *SharedLoadBalanceClient) DeleteTags(id string, keys []string) error {
+	resourceTags := make([]model.ResourceTag, 0, len(keys))
+	for _, k := range keys {
+		resourceTags = append(resourceTags, model.ResourceTag{Key: k})
+	}
+	return s.wrapper(func(c *elb.ElbClient) (interface{}, error) {
+		return c.BatchDeleteLoadbalancerTags(&model.BatchDeleteLoadbalancerTagsRequest{
+			LoadbalancerId: id,
+			Body: &model.BatchDeleteLoadbalancerTagsRequestBody{
+				Action: model.GetBatchDeleteLoadbalancerTagsRequestBodyActionEnum().DELETE,
+				Tags:   &resourceTags,
+			},
+		})
+	})
+}
+
 func (s *SharedLoadBalanceClient) wrapper(handler func(*elb.ElbClient) (interface{}, error), args ...interface{}) error {
-	return commonWrapper(func() (interface{}, error) {
-		hc := s.AuthOpts.GetHcClient("elb")
+	return commonWrapper(s.AuthOpts.Context(), func() (interface{}, error) {
+		hc, err := s.AuthOpts.GetHcClient("elb")
+		if err != nil {
+			return nil, err
+		}
 		return handler(elb.NewElbClient(hc))
 	}, OKCodes, args...)
 }
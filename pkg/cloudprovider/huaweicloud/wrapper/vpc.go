@@ -10,6 +10,17 @@ type VpcClient struct {
 	AuthOpts *config.AuthOptions
 }
 
+// InProject returns the client scoped to the project with the same credentials,
+// the client itself is returned if the project is empty or the same as the project of the client.
+func (c *VpcClient) InProject(projectID string) *VpcClient {
+	if projectID == "" || projectID == c.AuthOpts.ProjectID {
+		return c
+	}
+	opts := *c.AuthOpts
+	opts.ProjectID = projectID
+	return &VpcClient{AuthOpts: &opts}
+}
+
 func (c *VpcClient) ListSecurityGroupRules(securityGroupID string) ([]model.SecurityGroupRule, error) {
 	var rst []model.SecurityGroupRule
 	err := c.wrapper(func(c *vpc.VpcClient) (interface{}, error) {
@@ -40,9 +51,54 @@ func (c *VpcClient) DeleteSecurityGroupRule(ruleID string) error {
 	})
 }
 
+func (c *VpcClient) GetRouteTable(id string) (*model.RouteTableResp, error) {
+	var rst *model.RouteTableResp
+	err := c.wrapper(func(c *vpc.VpcClient) (interface{}, error) {
+		return c.ShowRouteTable(&model.ShowRouteTableRequest{
+			RoutetableId: id,
+		})
+	}, "Routetable", &rst)
+	return rst, err
+}
+
+// UpdateRouteTableRoutes updates the routes of the route table, the routes are keyed by the action: add, mod or del.
+func (c *VpcClient) UpdateRouteTableRoutes(id string, routes map[string][]model.RouteTableRoute) error {
+	return c.wrapper(func(c *vpc.VpcClient) (interface{}, error) {
+		return c.UpdateRouteTable(&model.UpdateRouteTableRequest{
+			RoutetableId: id,
+			Body: &model.UpdateRoutetableReqBody{
+				Routetable: &model.UpdateRouteTableReq{
+					Routes: routes,
+				},
+			},
+		})
+	})
+}
+
+// ListPorts returns the VPC ports matching the request, such as the ports of an ECS by the device ID.
+func (c *VpcClient) ListPorts(req *model.ListPortsRequest) ([]model.Port, error) {
+	var rst []model.Port
+	err := c.wrapper(func(c *vpc.VpcClient) (interface{}, error) {
+		return c.ListPorts(req)
+	}, "Ports", &rst)
+	return rst, err
+}
+
+// ListSubnets returns the subnets matching the request, such as the subnets of a VPC.
+func (c *VpcClient) ListSubnets(req *model.ListSubnetsRequest) ([]model.Subnet, error) {
+	var rst []model.Subnet
+	err := c.wrapper(func(c *vpc.VpcClient) (interface{}, error) {
+		return c.ListSubnets(req)
+	}, "Subnets", &rst)
+	return rst, err
+}
+
 func (c *VpcClient) wrapper(handler func(*vpc.VpcClient) (interface{}, error), args ...interface{}) error {
-	return commonWrapper(func() (interface{}, error) {
-		hc := c.AuthOpts.GetHcClient("vpc")
+	return commonWrapper(c.AuthOpts.Context(), func() (interface{}, error) {
+		hc, err := c.AuthOpts.GetHcClient("vpc")
+		if err != nil {
+			return nil, err
+		}
 		return handler(vpc.NewVpcClient(hc))
 	}, OKCodes, args...)
 }
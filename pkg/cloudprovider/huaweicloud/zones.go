@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+
+	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
+)
+
+// serverGetter gets the ECS details by the ID or the node name.
+type serverGetter interface {
+	Get(id string) (*ecsmodel.ServerDetail, error)
+	GetByNodeName(name string) (*ecsmodel.ServerDetail, error)
+}
+
+// Zones implements cloudprovider.Zones. The zone of the instance that the CCM runs on is read from
+// the metadata service, so that it is available even if the ECS API is slow or restricted,
+// the ECS API is used for the other instances or if the metadata service is not available.
+type Zones struct {
+	Basic
+	servers  serverGetter
+	hostname func() (string, error)
+	// inProject returns the getter scoped to the project of the provider ID, servers is used if it is nil.
+	inProject func(projectID string) serverGetter
+}
+
+// getServers returns the getter of the project, the default one if the project is empty.
+func (z *Zones) getServers(projectID string) serverGetter {
+	if projectID == "" || z.inProject == nil {
+		return z.servers
+	}
+	return z.inProject(projectID)
+}
+
+// getLocalZone returns the metadata and the zone of the instance that the CCM runs on,
+// false if the metadata service is not available. The region of the cloud-config is used
+// if the metadata omits it.
+func (z *Zones) getLocalZone() (*metadata.Metadata, cloudprovider.Zone, bool) {
+	if z.localInstance == nil {
+		return nil, cloudprovider.Zone{}, false
+	}
+	data := z.localInstance.get()
+	if data == nil || data.Metadata == nil || data.Metadata.AvailabilityZone == "" {
+		return nil, cloudprovider.Zone{}, false
+	}
+
+	region := data.Metadata.RegionID
+	if region == "" {
+		region = z.cloudConfig.AuthOpts.Region
+	}
+	return data.Metadata, cloudprovider.Zone{FailureDomain: data.Metadata.AvailabilityZone, Region: region}, true
+}
+
+func (z *Zones) buildZone(server *ecsmodel.ServerDetail) cloudprovider.Zone {
+	return cloudprovider.Zone{
+		FailureDomain: server.OSEXTAZavailabilityZone,
+		Region:        z.cloudConfig.AuthOpts.Region,
+	}
+}
+
+// GetZone returns the zone of the instance that the CCM runs on.
+func (z *Zones) GetZone(_ context.Context) (cloudprovider.Zone, error) {
+	if _, zone, ok := z.getLocalZone(); ok {
+		return zone, nil
+	}
+
+	hostname, err := z.hostname()
+	if err != nil {
+		return cloudprovider.Zone{}, err
+	}
+	klog.V(4).Infof("The zone is not available from the metadata service, query ECS details by hostname: %s",
+		hostname)
+	server, err := z.servers.GetByNodeName(hostname)
+	if err != nil {
+		return cloudprovider.Zone{}, classifyServerError(err, hostname)
+	}
+	return z.buildZone(server), nil
+}
+
+// GetZoneByProviderID returns the zone of the instance with the specified provider ID.
+func (z *Zones) GetZoneByProviderID(_ context.Context, providerID string) (cloudprovider.Zone, error) {
+	projectID, instanceID, err := parseProviderID(providerID)
+	if err != nil {
+		return cloudprovider.Zone{}, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	if md, zone, ok := z.getLocalZone(); ok && md.UUID == instanceID {
+		return zone, nil
+	}
+
+	server, err := z.getServers(projectID).Get(instanceID)
+	if err != nil {
+		return cloudprovider.Zone{}, classifyServerError(err, instanceID)
+	}
+	return z.buildZone(server), nil
+}
+
+// GetZoneByNodeName returns the zone of the instance with the specified node name.
+func (z *Zones) GetZoneByNodeName(_ context.Context, nodeName types.NodeName) (cloudprovider.Zone, error) {
+	if md, zone, ok := z.getLocalZone(); ok && md.Name == string(nodeName) {
+		return zone, nil
+	}
+
+	server, err := z.servers.GetByNodeName(string(nodeName))
+	if err != nil {
+		return cloudprovider.Zone{}, classifyServerError(err, string(nodeName))
+	}
+	return z.buildZone(server), nil
+}
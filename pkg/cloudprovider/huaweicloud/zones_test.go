@@ -0,0 +1,208 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package huaweicloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/config"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
+)
+
+// fakeServerGetter returns the ECS details in servers by the ID or the name, and counts the calls.
+type fakeServerGetter struct {
+	servers []ecsmodel.ServerDetail
+	calls   int
+}
+
+func (f *fakeServerGetter) find(match func(s ecsmodel.ServerDetail) bool) (*ecsmodel.ServerDetail, error) {
+	f.calls++
+	for _, s := range f.servers {
+		if match(s) {
+			return &s, nil
+		}
+	}
+	return nil, sdkerr.ServiceResponseError{StatusCode: http.StatusNotFound}
+}
+
+func (f *fakeServerGetter) Get(id string) (*ecsmodel.ServerDetail, error) {
+	return f.find(func(s ecsmodel.ServerDetail) bool { return s.Id == id })
+}
+
+func (f *fakeServerGetter) GetByNodeName(name string) (*ecsmodel.ServerDetail, error) {
+	return f.find(func(s ecsmodel.ServerDetail) bool { return s.Name == name })
+}
+
+func newMetadataServer(metaData string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openstack/latest/meta_data.json" || metaData == "" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(metaData))
+	}))
+}
+
+func newTestZones(metadataURL string, servers *fakeServerGetter) *Zones {
+	opts := metadata.Options{BaseURL: metadataURL, Format: metadata.FormatOpenStack, Timeout: time.Second}
+	return &Zones{
+		Basic: Basic{
+			cloudConfig: &config.CloudConfig{AuthOpts: config.AuthOptions{Region: "ap-southeast-1"}},
+			localInstance: &localInstance{fetch: func() (*metadata.InstanceData, error) {
+				return metadata.GetInstanceData(opts)
+			}},
+		},
+		servers:  servers,
+		hostname: func() (string, error) { return "node-1", nil },
+	}
+}
+
+func TestZonesFromMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metaData string
+		expected cloudprovider.Zone
+	}{
+		{
+			name: "region in metadata",
+			metaData: `{"uuid": "instance-1", "name": "node-1", "availability_zone": "ap-southeast-1b",
+				"region_id": "ap-southeast-2"}`,
+			expected: cloudprovider.Zone{FailureDomain: "ap-southeast-1b", Region: "ap-southeast-2"},
+		},
+		{
+			name:     "region omitted",
+			metaData: `{"uuid": "instance-1", "name": "node-1", "availability_zone": "ap-southeast-1b"}`,
+			expected: cloudprovider.Zone{FailureDomain: "ap-southeast-1b", Region: "ap-southeast-1"},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			server := newMetadataServer(te.metaData)
+			defer server.Close()
+			servers := &fakeServerGetter{}
+			z := newTestZones(server.URL, servers)
+
+			got, err := z.GetZone(context.TODO())
+			if err != nil || got != te.expected {
+				t.Fatalf("expected: %v, got: %v, %v", te.expected, got, err)
+			}
+			got, err = z.GetZoneByProviderID(context.TODO(), "huaweicloud://instance-1")
+			if err != nil || got != te.expected {
+				t.Fatalf("expected: %v, got: %v, %v", te.expected, got, err)
+			}
+			got, err = z.GetZoneByNodeName(context.TODO(), "node-1")
+			if err != nil || got != te.expected {
+				t.Fatalf("expected: %v, got: %v, %v", te.expected, got, err)
+			}
+			if servers.calls != 0 {
+				t.Fatalf("expected: the ECS API is not called, got: %v calls", servers.calls)
+			}
+		})
+	}
+}
+
+func TestZonesFallback(t *testing.T) {
+	servers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{
+		{Id: "instance-1", Name: "node-1", OSEXTAZavailabilityZone: "ap-southeast-1a"},
+		{Id: "instance-2", Name: "node-2", OSEXTAZavailabilityZone: "ap-southeast-1c"},
+	}}
+	local := cloudprovider.Zone{FailureDomain: "ap-southeast-1a", Region: "ap-southeast-1"}
+	other := cloudprovider.Zone{FailureDomain: "ap-southeast-1c", Region: "ap-southeast-1"}
+
+	// the metadata service does not serve the metadata, the ECS API is used.
+	server := newMetadataServer("")
+	defer server.Close()
+	z := newTestZones(server.URL, servers)
+
+	got, err := z.GetZone(context.TODO())
+	if err != nil || got != local {
+		t.Fatalf("expected: %v, got: %v, %v", local, got, err)
+	}
+
+	// the other instances are always queried by the ECS API.
+	server = newMetadataServer(`{"uuid": "instance-1", "name": "node-1", "availability_zone": "ap-southeast-1b"}`)
+	defer server.Close()
+	z = newTestZones(server.URL, servers)
+
+	got, err = z.GetZoneByProviderID(context.TODO(), "huaweicloud://instance-2")
+	if err != nil || got != other {
+		t.Fatalf("expected: %v, got: %v, %v", other, got, err)
+	}
+	got, err = z.GetZoneByNodeName(context.TODO(), "node-2")
+	if err != nil || got != other {
+		t.Fatalf("expected: %v, got: %v, %v", other, got, err)
+	}
+
+	if _, err = z.GetZoneByNodeName(context.TODO(), "node-3"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected: %v, got: %v", codes.NotFound, err)
+	}
+	if _, err = z.GetZoneByProviderID(context.TODO(), "aws://instance-1"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected: %v, got: %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestZonesInProject(t *testing.T) {
+	projectID := "0a1b2c3d4e5f60718293a4b5c6d7e8f9"
+	defaultServers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{
+		{Id: "instance-1", Name: "node-1", OSEXTAZavailabilityZone: "ap-southeast-1a"},
+	}}
+	projectServers := &fakeServerGetter{servers: []ecsmodel.ServerDetail{
+		{Id: "instance-2", Name: "node-2", OSEXTAZavailabilityZone: "ap-southeast-1c"},
+	}}
+
+	server := newMetadataServer("")
+	defer server.Close()
+	z := newTestZones(server.URL, defaultServers)
+	z.inProject = func(id string) serverGetter {
+		if id == projectID {
+			return projectServers
+		}
+		return &fakeServerGetter{}
+	}
+
+	// the server lives in the other project, it is not found by the client of the default project.
+	if _, err := z.GetZoneByProviderID(context.TODO(), "huaweicloud://instance-2"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected: %v, got: %v", codes.NotFound, err)
+	}
+
+	expected := cloudprovider.Zone{FailureDomain: "ap-southeast-1c", Region: "ap-southeast-1"}
+	got, err := z.GetZoneByProviderID(context.TODO(), "huaweicloud://"+projectID+"/instance-2")
+	if err != nil || got != expected {
+		t.Fatalf("expected: %v, got: %v, %v", expected, got, err)
+	}
+	if projectServers.calls != 1 {
+		t.Fatalf("expected: the client of the project is called once, got: %v calls", projectServers.calls)
+	}
+
+	// the provider ID without the project falls back to the default project.
+	expected = cloudprovider.Zone{FailureDomain: "ap-southeast-1a", Region: "ap-southeast-1"}
+	got, err = z.GetZoneByProviderID(context.TODO(), "huaweicloud://instance-1")
+	if err != nil || got != expected {
+		t.Fatalf("expected: %v, got: %v, %v", expected, got, err)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package common
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -49,14 +50,57 @@ func IsNotFound(err error) bool {
 	return false
 }
 
-// WaitForCompleted wait for completion, interval 2s+, up to 30 pols
-func WaitForCompleted(condition wait.ConditionFunc) error {
+// IsThrottled returns true if the request is rejected by the rate limit of the API, it can be retried later.
+func IsThrottled(err error) bool {
+	if status.Code(err) == codes.ResourceExhausted {
+		return true
+	}
+	if e, ok := err.(sdkerr.ServiceResponseError); ok {
+		return e.StatusCode == 429
+	}
+	if e, ok := err.(*sdkerr.ServiceResponseError); ok {
+		return e.StatusCode == 429
+	}
+	return false
+}
+
+// IsConflict returns true if the request conflicts with the current state of the resource, such as a resource
+// that is still being provisioned, it can be retried after the resource is ready.
+func IsConflict(err error) bool {
+	if status.Code(err) == codes.Aborted {
+		return true
+	}
+	if e, ok := err.(sdkerr.ServiceResponseError); ok {
+		return e.StatusCode == 409
+	}
+	if e, ok := err.(*sdkerr.ServiceResponseError); ok {
+		return e.StatusCode == 409
+	}
+	return false
+}
+
+// IsUnauthorized returns true if the request is rejected because the credentials are invalid or expired.
+func IsUnauthorized(err error) bool {
+	if code := status.Code(err); code == codes.Unauthenticated || code == codes.PermissionDenied {
+		return true
+	}
+	if e, ok := err.(sdkerr.ServiceResponseError); ok {
+		return e.StatusCode == 401 || e.StatusCode == 403
+	}
+	if e, ok := err.(*sdkerr.ServiceResponseError); ok {
+		return e.StatusCode == 401 || e.StatusCode == 403
+	}
+	return false
+}
+
+// WaitForCompleted wait for completion, interval 2s+, up to 30 pols, it stops waiting once the context is done.
+func WaitForCompleted(ctx context.Context, condition wait.ConditionFunc) error {
 	backoff := wait.Backoff{
 		Duration: DefaultInitDelay,
 		Factor:   DefaultFactor,
 		Steps:    DefaultSteps,
 	}
-	return wait.ExponentialBackoff(backoff, condition)
+	return wait.ExponentialBackoffWithContext(ctx, backoff, condition)
 }
 
 type JobHandle func()
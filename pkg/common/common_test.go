@@ -17,6 +17,7 @@ limitations under the License.
 package common
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -74,6 +75,90 @@ func TestIsNotFound(t *testing.T) {
 	}
 }
 
+func TestIsThrottled(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "resource exhausted",
+			err:      status.Error(codes.ResourceExhausted, "too many requests"),
+			expected: true,
+		},
+		{
+			name:     "too many requests",
+			err:      sdkerr.ServiceResponseError{StatusCode: 429},
+			expected: true,
+		},
+		{
+			name:     "pointer",
+			err:      &sdkerr.ServiceResponseError{StatusCode: 429},
+			expected: true,
+		},
+		{
+			name:     "service unavailable",
+			err:      sdkerr.ServiceResponseError{StatusCode: 503},
+			expected: false,
+		},
+		{
+			name:     "nil",
+			err:      nil,
+			expected: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := IsThrottled(testCase.err); got != testCase.expected {
+				t.Fatalf("expected: %v, got : %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsUnauthorized(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "unauthenticated",
+			err:      status.Error(codes.Unauthenticated, "the token expires"),
+			expected: true,
+		},
+		{
+			name:     "unauthorized",
+			err:      sdkerr.ServiceResponseError{StatusCode: 401},
+			expected: true,
+		},
+		{
+			name:     "forbidden",
+			err:      &sdkerr.ServiceResponseError{StatusCode: 403},
+			expected: true,
+		},
+		{
+			name:     "too many requests",
+			err:      sdkerr.ServiceResponseError{StatusCode: 429},
+			expected: false,
+		},
+		{
+			name:     "nil",
+			err:      nil,
+			expected: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := IsUnauthorized(testCase.err); got != testCase.expected {
+				t.Fatalf("expected: %v, got : %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
 func TestWaitForCompleted(t *testing.T) {
 	count := 0
 	tests := []struct {
@@ -105,7 +190,7 @@ func TestWaitForCompleted(t *testing.T) {
 
 	for _, testCase := range tests {
 		t.Run(testCase.name, func(t *testing.T) {
-			hasErr := WaitForCompleted(testCase.condition) == nil
+			hasErr := WaitForCompleted(context.TODO(), testCase.condition) == nil
 			if hasErr == testCase.hasErr {
 				t.Fatalf("expected: %v, got : %v", testCase.hasErr, hasErr)
 			}
@@ -0,0 +1,38 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnsureCorrelationID(t *testing.T) {
+	ctx, id := EnsureCorrelationID(context.TODO())
+	if id == "" || CorrelationIDFromContext(ctx) != id {
+		t.Fatalf("expected: a generated correlation ID, got: %q, %q", id, CorrelationIDFromContext(ctx))
+	}
+
+	// the correlation ID of the context is kept.
+	ctx = WithCorrelationID(context.TODO(), "reconcile-1")
+	if _, got := EnsureCorrelationID(ctx); got != "reconcile-1" {
+		t.Fatalf("expected: %v, got: %v", "reconcile-1", got)
+	}
+	if _, other := EnsureCorrelationID(context.TODO()); other == id {
+		t.Fatalf("expected: a new correlation ID, got: %v", other)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"errors"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorKeysAndValues returns the key-value pairs of the error for the structured logs, such as klog.ErrorS.
+// The error code and the request ID of a failed API call are returned as discrete keys, so that the log pipelines
+// can match them without parsing the messages.
+func ErrorKeysAndValues(err error) []interface{} {
+	if err == nil {
+		return nil
+	}
+
+	var e sdkerr.ServiceResponseError
+	var pe *sdkerr.ServiceResponseError
+	switch {
+	case errors.As(err, &pe) && pe != nil:
+		e = *pe
+	case errors.As(err, &e):
+	default:
+		if s, ok := status.FromError(err); ok {
+			return []interface{}{"errorCode", s.Code().String()}
+		}
+		return nil
+	}
+	return []interface{}{"errorCode", e.ErrorCode, "statusCode", e.StatusCode, "requestID", e.RequestId}
+}
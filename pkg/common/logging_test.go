@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorKeysAndValues(t *testing.T) {
+	apiErr := sdkerr.ServiceResponseError{StatusCode: 404, RequestId: "req-1", ErrorCode: "Ecs.0114"}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected []interface{}
+	}{
+		{
+			name:     "API error",
+			err:      apiErr,
+			expected: []interface{}{"errorCode", "Ecs.0114", "statusCode", 404, "requestID", "req-1"},
+		},
+		{
+			name:     "API error pointer",
+			err:      &apiErr,
+			expected: []interface{}{"errorCode", "Ecs.0114", "statusCode", 404, "requestID", "req-1"},
+		},
+		{
+			name:     "wrapped API error",
+			err:      fmt.Errorf("failed to get the ECS: %w", apiErr),
+			expected: []interface{}{"errorCode", "Ecs.0114", "statusCode", 404, "requestID", "req-1"},
+		},
+		{
+			name:     "status error",
+			err:      status.Errorf(codes.InvalidArgument, "invalid provider ID"),
+			expected: []interface{}{"errorCode", "InvalidArgument"},
+		},
+		{
+			name:     "other error",
+			err:      fmt.Errorf("unexpected error"),
+			expected: nil,
+		},
+		{
+			name:     "no error",
+			err:      nil,
+			expected: nil,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			if got := ErrorKeysAndValues(te.err); !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
@@ -17,10 +17,13 @@ limitations under the License.
 package config
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/basic"
@@ -28,61 +31,667 @@ import (
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/httphandler"
 	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/region"
 	"gopkg.in/gcfg.v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/component-base/version"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
 	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
 )
 
-// CloudConfig define
+const (
+	userAgentPrefix = "cloud-provider-huaweicloud"
+
+	// CloudTypePublic is Huawei Cloud, CloudTypeHCS is Huawei Cloud Stack, the private cloud.
+	CloudTypePublic = "public"
+	CloudTypeHCS    = "hcs"
+
+	defaultPublicCloud = "myhuaweicloud.com"
+
+	// DefaultAnnotationPrefix is the prefix of the LoadBalancer annotations, such as kubernetes.io/elb.class.
+	DefaultAnnotationPrefix = "kubernetes.io/elb"
+
+	// DefaultRetryBudget is the number of the retries that can be made in a row when the API keeps failing,
+	// DefaultRetryBudgetRefillRatio is the part of a retry earned back by each successful call.
+	DefaultRetryBudget            = 10
+	DefaultRetryBudgetRefillRatio = 0.1
+
+	// DefaultServerListPageSize is the number of the ECSs in a page when listing the ECSs, up to MaxServerListPageSize.
+	// DefaultServerListMaxResults caps the number of the ECSs listed in total.
+	DefaultServerListPageSize   = 100
+	MaxServerListPageSize       = 1000
+	DefaultServerListMaxResults = 10000
+
+	// ShutoffInstancePolicyShutdown reports the SHUTOFF ECS as shutdown, so that the node is tainted,
+	// ShutoffInstancePolicyIgnore leaves the node of the SHUTOFF ECS alone.
+	ShutoffInstancePolicyShutdown = "shutdown"
+	ShutoffInstancePolicyIgnore   = "ignore"
+
+	// DefaultNotReadyInstanceStatuses are the statuses of the ECSs that are not ready for their nodes to be
+	// initialized, such as the ECSs being built or rebooted.
+	DefaultNotReadyInstanceStatuses = "BUILD,BUILDING,REBOOT,HARD_REBOOT,REBUILD"
+
+	// DefaultNewNodeLookupGracePeriod is the seconds after a node is created, during which the node is new
+	// and the lookup of its ECS by name is retried if the ECS is not listed yet.
+	DefaultNewNodeLookupGracePeriod = 300
+	// MaxNewNodeLookupRetries caps the retries of the lookup of the ECS of a new node, the node controller waits
+	// for the retries.
+	MaxNewNodeLookupRetries = 10
+
+	// DuplicateServerNamePolicyPickFirst uses the first of the ECSs with the same name as the node,
+	// DuplicateServerNamePolicyFail fails the lookup, DuplicateServerNamePolicyPickActive uses the only ACTIVE one,
+	// and DuplicateServerNamePolicyPickNewest uses the latest created one of the ACTIVE ones.
+	DuplicateServerNamePolicyPickFirst  = "pick-first"
+	DuplicateServerNamePolicyFail       = "fail"
+	DuplicateServerNamePolicyPickActive = "pick-active"
+	DuplicateServerNamePolicyPickNewest = "pick-newest"
+
+	// ForeignServerPolicyNotFound treats the ECS out of the project or the enterprise project of the CCM as not found,
+	// ForeignServerPolicyFail fails the lookup of the ECS.
+	ForeignServerPolicyNotFound = "not-found"
+	ForeignServerPolicyFail     = "fail"
+
+	// DefaultRouteConcurrency is the number of the routes created simultaneously, DefaultRouteBatchSize is the number
+	// of the routes added to the route table in one update, and DefaultRouteMaxRetries is the number of the retries
+	// of the update when it is throttled.
+	DefaultRouteConcurrency = 10
+	DefaultRouteBatchSize   = 50
+	DefaultRouteMaxRetries  = 5
+)
+
+// supportedAddressTypes are the node address types that can be specified in "allowed-address-types".
+var supportedAddressTypes = []string{"InternalIP", "ExternalIP", "Hostname", "InternalDNS"}
+
+// CloudConfig is the cloud-config of the cloud provider, it is either in the INI format of gcfg,
+// or in the YAML or JSON format with the same section and option names, such as:
+//
+//	global:
+//	  region: ap-southeast-1
+//	  access-key: ...
+//	vpc:
+//	  id: ...
 type CloudConfig struct {
-	AuthOpts AuthOptions `gcfg:"Global"`
-	VpcOpts  VpcOptions  `gcfg:"Vpc"`
+	AuthOpts AuthOptions `gcfg:"Global" json:"global"`
+	VpcOpts  VpcOptions  `gcfg:"Vpc" json:"vpc"`
 }
 
 type VpcOptions struct {
-	ID              string `gcfg:"id"`
-	SubnetID        string `gcfg:"subnet-id"`
-	SecurityGroupID string `gcfg:"security-group-id"`
+	ID              string `gcfg:"id" json:"id,omitempty"`
+	SubnetID        string `gcfg:"subnet-id" json:"subnet-id,omitempty"`
+	SecurityGroupID string `gcfg:"security-group-id" json:"security-group-id,omitempty"`
+	RouteTableID    string `gcfg:"route-table-id" json:"route-table-id,omitempty"`
+
+	// RouteConcurrency, RouteBatchSize and RouteMaxRetries bound the updates of the route table when many routes
+	// are created at once, such as bootstrapping a large cluster, so that the VPC API is not throttled.
+	RouteConcurrency int `gcfg:"route-concurrency" json:"route-concurrency,omitempty"`
+	RouteBatchSize   int `gcfg:"route-batch-size" json:"route-batch-size,omitempty"`
+	RouteMaxRetries  int `gcfg:"route-max-retries" json:"route-max-retries,omitempty"`
+}
+
+// GetRouteConcurrency returns the number of the routes created simultaneously, defaults to DefaultRouteConcurrency.
+func (v *VpcOptions) GetRouteConcurrency() int {
+	if v.RouteConcurrency <= 0 {
+		return DefaultRouteConcurrency
+	}
+	return v.RouteConcurrency
+}
+
+// GetRouteBatchSize returns the number of the routes added in one update, defaults to DefaultRouteBatchSize.
+func (v *VpcOptions) GetRouteBatchSize() int {
+	if v.RouteBatchSize <= 0 {
+		return DefaultRouteBatchSize
+	}
+	return v.RouteBatchSize
+}
+
+// GetRouteMaxRetries returns the number of the retries of the throttled updates, defaults to DefaultRouteMaxRetries.
+func (v *VpcOptions) GetRouteMaxRetries() int {
+	if v.RouteMaxRetries <= 0 {
+		return DefaultRouteMaxRetries
+	}
+	return v.RouteMaxRetries
+}
+
+// Validate checks the ranges of the options of the routes.
+func (v *VpcOptions) Validate() error {
+	if v.RouteConcurrency < 0 {
+		return fmt.Errorf(`"route-concurrency" must not be negative, got: %d`, v.RouteConcurrency)
+	}
+	if v.RouteBatchSize < 0 {
+		return fmt.Errorf(`"route-batch-size" must not be negative, got: %d`, v.RouteBatchSize)
+	}
+	if v.RouteMaxRetries < 0 {
+		return fmt.Errorf(`"route-max-retries" must not be negative, got: %d`, v.RouteMaxRetries)
+	}
+	return nil
 }
 
 type AuthOptions struct {
-	Cloud     string `gcfg:"cloud"`
-	AuthURL   string `gcfg:"auth-url"`
-	Region    string `gcfg:"region"`
-	AccessKey string `gcfg:"access-key"`
-	SecretKey string `gcfg:"secret-key"`
-	ProjectID string `gcfg:"project-id"`
-}
-
-func (a *AuthOptions) GetCredentials() *basic.Credentials {
-	return basic.NewCredentialsBuilder().
-		WithAk(a.AccessKey).
-		WithSk(a.SecretKey).
-		WithProjectId(a.ProjectID).
-		Build()
+	Cloud     string `gcfg:"cloud" json:"cloud,omitempty"`
+	CloudType string `gcfg:"cloud-type" json:"cloud-type,omitempty"`
+	AuthURL   string `gcfg:"auth-url" json:"auth-url,omitempty"`
+	Region    string `gcfg:"region" json:"region,omitempty"`
+	AccessKey string `gcfg:"access-key" json:"access-key,omitempty"`
+	SecretKey string `gcfg:"secret-key" json:"secret-key,omitempty"`
+	ProjectID string `gcfg:"project-id" json:"project-id,omitempty"`
+	UserAgent string `gcfg:"user-agent" json:"user-agent,omitempty"`
+
+	// MetadataURL, MetadataVersion and MetadataFormat specify the layout of the metadata service,
+	// they vary across Huawei Cloud environments.
+	MetadataURL     string `gcfg:"metadata-url" json:"metadata-url,omitempty"`
+	MetadataVersion string `gcfg:"metadata-version" json:"metadata-version,omitempty"`
+	MetadataFormat  string `gcfg:"metadata-format" json:"metadata-format,omitempty"`
+	// MetadataTimeout is the timeout in seconds to fetch the documents from the metadata service.
+	MetadataTimeout int `gcfg:"metadata-timeout" json:"metadata-timeout,omitempty"`
+
+	// AnnotationPrefix replaces the prefix kubernetes.io/elb of the LoadBalancer annotations.
+	AnnotationPrefix string `gcfg:"annotation-prefix" json:"annotation-prefix,omitempty"`
+
+	// CredentialSecret is the Secret in the format of namespace/name that the AK/SK and the security token are
+	// read from instead of access-key and secret-key, the keys in the data of the Secret can be customized.
+	CredentialSecret                 string `gcfg:"credential-secret" json:"credential-secret,omitempty"`
+	CredentialSecretAccessKeyKey     string `gcfg:"credential-secret-access-key-key" json:"credential-secret-access-key-key,omitempty"`
+	CredentialSecretSecretKeyKey     string `gcfg:"credential-secret-secret-key-key" json:"credential-secret-secret-key-key,omitempty"`
+	CredentialSecretSecurityTokenKey string `gcfg:"credential-secret-security-token-key" json:"credential-secret-security-token-key,omitempty"`
+	CredentialSecretExpiresAtKey     string `gcfg:"credential-secret-expires-at-key" json:"credential-secret-expires-at-key,omitempty"`
+
+	// CredentialProbeInterval is the interval in seconds to validate the credentials in the background, and refresh
+	// the ones expiring within CredentialRefreshSkew seconds. 0 disables the probe.
+	CredentialProbeInterval int `gcfg:"credential-probe-interval" json:"credential-probe-interval,omitempty"`
+	CredentialRefreshSkew   int `gcfg:"credential-refresh-skew" json:"credential-refresh-skew,omitempty"`
+
+	// AllowedAddressTypes is a comma-separated list of the node address types reported to Kubernetes,
+	// such as "InternalIP,Hostname". All the types are reported if it is empty.
+	AllowedAddressTypes string `gcfg:"allowed-address-types" json:"allowed-address-types,omitempty"`
+	// DeniedAddressCIDRs is a comma-separated list of the CIDRs whose addresses are never reported to Kubernetes,
+	// such as the management or storage subnets that the pods can not route to.
+	DeniedAddressCIDRs string `gcfg:"denied-address-cidrs" json:"denied-address-cidrs,omitempty"`
+	// NodeAddressesAnnotation is the key of the node annotation with the exact addresses reported for the node,
+	// in the JSON format of the node addresses, such as in the air-gapped or static setups. The addresses are
+	// looked up by the API if it is empty or the node does not have the annotation.
+	NodeAddressesAnnotation string `gcfg:"node-addresses-annotation" json:"node-addresses-annotation,omitempty"`
+
+	// RetryBudget and RetryBudgetRefillRatio throttle the retries of the throttled or unavailable API calls
+	// across all the clients, so that the retries do not multiply the load when the API is degraded.
+	// A zero RetryBudget disables the retries.
+	RetryBudget            int     `gcfg:"retry-budget" json:"retry-budget"`
+	RetryBudgetRefillRatio float64 `gcfg:"retry-budget-refill-ratio" json:"retry-budget-refill-ratio,omitempty"`
+
+	// Endpoints is a comma-separated list of the private endpoints in the format of service=endpoint,
+	// which override the endpoints derived from the region, such as the VPC endpoints of the APIs.
+	// PreferPrivateEndpoints uses the private endpoints only if they resolve, that is, inside the VPC.
+	Endpoints              string `gcfg:"endpoints" json:"endpoints,omitempty"`
+	PreferPrivateEndpoints bool   `gcfg:"prefer-private-endpoints" json:"prefer-private-endpoints,omitempty"`
+
+	// ServerListPageSize and ServerListMaxResults bound the pages and the total number of the ECSs listed,
+	// such as looking up the ECS of a node by name or IP in a large project.
+	ServerListPageSize   int `gcfg:"server-list-page-size" json:"server-list-page-size,omitempty"`
+	ServerListMaxResults int `gcfg:"server-list-max-results" json:"server-list-max-results,omitempty"`
+
+	// ReadOnly makes the CCM only read the state of the cloud resources, such as the addresses and zones of the
+	// nodes, and reject all the operations that create, update or delete the cloud resources.
+	ReadOnly bool `gcfg:"read-only" json:"read-only,omitempty"`
+	// ForceDeleteELB deletes the ELB instance with the service even if the instance still has listeners
+	// not created by the service, such as the listeners created manually on a shared instance.
+	ForceDeleteELB bool `gcfg:"force-delete-elb" json:"force-delete-elb,omitempty"`
+
+	// ShutoffInstancePolicy is how the node of a SHUTOFF ECS is handled, "shutdown" or "ignore",
+	// such as a stopped ECS that is still billed. It does not affect whether the instance exists.
+	ShutoffInstancePolicy string `gcfg:"shutoff-instance-policy" json:"shutoff-instance-policy,omitempty"`
+	// NotReadyInstanceStatuses is a comma-separated list of the ECS statuses that the nodes are not initialized in,
+	// the initialization is retried until the ECS leaves them, such as "BUILD,REBOOT".
+	NotReadyInstanceStatuses string `gcfg:"not-ready-instance-statuses" json:"not-ready-instance-statuses,omitempty"`
+
+	// DuplicateServerNamePolicy is how an ECS is picked when multiple ECSs have the name of the node,
+	// such as the old and the new ECS during a node replacement.
+	DuplicateServerNamePolicy string `gcfg:"duplicate-server-name-policy" json:"duplicate-server-name-policy,omitempty"`
+	// InstanceLookupByPrivateIP looks up the ECS of a node by the private IP of the node as the last resort,
+	// if it is not found by the name, such as during a migration that the node names are not reliable.
+	InstanceLookupByPrivateIP bool `gcfg:"instance-lookup-by-private-ip" json:"instance-lookup-by-private-ip,omitempty"`
+	// ListServerOnThrottle looks up an ECS by ID with the list API if the query of the ECS is throttled,
+	// the APIs have separate rate limits, so the ECS may still be resolved during a partial throttling.
+	ListServerOnThrottle bool `gcfg:"list-server-on-throttle" json:"list-server-on-throttle,omitempty"`
+	// NewNodeLookupRetries is the number of the retries of the lookup of the ECS of a new node by name before
+	// the ECS is reported not found, as an ECS just created may not be listed for a few seconds. A node is new
+	// within NewNodeLookupGracePeriod seconds after it is created.
+	NewNodeLookupRetries     int `gcfg:"new-node-lookup-retries" json:"new-node-lookup-retries,omitempty"`
+	NewNodeLookupGracePeriod int `gcfg:"new-node-lookup-grace-period" json:"new-node-lookup-grace-period,omitempty"`
+
+	// EnterpriseProjectID is the enterprise project of the ECSs managed by the CCM, the ECSs of the other enterprise
+	// projects are foreign if it is set. ForeignServerPolicy is how a foreign ECS returned by ID is handled,
+	// such as an ECS of another project that the credentials can read.
+	EnterpriseProjectID string `gcfg:"enterprise-project-id" json:"enterprise-project-id,omitempty"`
+	ForeignServerPolicy string `gcfg:"foreign-server-policy" json:"foreign-server-policy,omitempty"`
+
+	credentialProvider CredentialProvider
+	retryPredicate     RetryPredicate
+	// correlationID is sent with the API calls and logged, so that the calls of a reconcile can be correlated.
+	correlationID string
+	// ctx is the context of the reconcile making the API calls, the calls and the waits for the resources
+	// are stopped once it is done, such as the reconcile timing out.
+	ctx context.Context
+}
+
+// RetryPredicate decides whether a failed API call is retried, within the retry budget.
+type RetryPredicate func(err error) bool
+
+// String implements fmt.Stringer, the AK/SK is redacted so that the options can be safely logged.
+func (a AuthOptions) String() string {
+	return fmt.Sprintf("{Cloud:%s CloudType:%s AuthURL:%s Region:%s AccessKey:%s SecretKey:%s ProjectID:%s "+
+		"UserAgent:%s}", a.Cloud, a.CloudType, a.AuthURL, a.Region, utils.Redact(a.AccessKey), utils.Redact(a.SecretKey),
+		a.ProjectID, a.UserAgent)
+}
+
+// GoString implements fmt.GoStringer, so that the AK/SK is also redacted when formatted with %#v.
+func (a AuthOptions) GoString() string {
+	return "config.AuthOptions" + a.String()
+}
+
+// SetCredentialProvider sets the provider that is consulted for the credentials before each client is built.
+func (a *AuthOptions) SetCredentialProvider(provider CredentialProvider) {
+	a.credentialProvider = provider
+}
+
+// GetCredentialProvider returns the credential provider, defaults to the AK/SK of the options.
+func (a *AuthOptions) GetCredentialProvider() CredentialProvider {
+	if a.credentialProvider == nil {
+		return &StaticCredentialProvider{AuthOpts: a}
+	}
+	return a.credentialProvider
+}
+
+// SetRetryPredicate sets the predicate that decides whether a failed API call is retried,
+// it replaces the default predicate of the clients, which retries the throttled and unavailable calls.
+func (a *AuthOptions) SetRetryPredicate(predicate RetryPredicate) {
+	a.retryPredicate = predicate
+}
+
+// GetRetryPredicate returns the retry predicate, nil if the default predicate of the clients is used.
+func (a *AuthOptions) GetRetryPredicate() RetryPredicate {
+	return a.retryPredicate
+}
+
+func (a *AuthOptions) GetCredentials() (*basic.Credentials, error) {
+	return a.getCredentials(newHTTPConfig(a.correlationID))
+}
+
+func (a *AuthOptions) getCredentials(httpConfig *sdkconfig.HttpConfig) (*basic.Credentials, error) {
+	ak, sk, token, err := a.GetCredentialProvider().GetCredentials(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %s", err)
+	}
+	if ak == "" || sk == "" {
+		return nil, fmt.Errorf("failed to get credentials: the access key or secret key is empty")
+	}
+
+	projectID := a.ProjectID
+	if projectID == "" {
+		if projectID, err = a.discoverProjectID(ak, sk, token, httpConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	builder := basic.NewCredentialsBuilder().
+		WithAk(ak).
+		WithSk(sk).
+		WithSecurityToken(token).
+		WithProjectId(projectID)
+	// The IAM of HCS is not served by the global endpoint, neither is the private endpoint of IAM.
+	if a.IsHCS() || a.getPrivateEndpoint("iam") != "" {
+		builder = builder.WithIamEndpointOverride(a.GetEndpoint("iam"))
+	}
+	return builder.Build(), nil
+}
+
+// IsHCS returns true if the cloud is Huawei Cloud Stack.
+func (a *AuthOptions) IsHCS() bool {
+	return strings.EqualFold(strings.TrimSpace(a.CloudType), CloudTypeHCS)
+}
+
+// GetCloud returns the domain of the endpoints, defaults to myhuaweicloud.com for the public cloud.
+func (a *AuthOptions) GetCloud() string {
+	if cloud := strings.TrimSpace(a.Cloud); cloud != "" {
+		return cloud
+	}
+	if a.IsHCS() {
+		return ""
+	}
+	return defaultPublicCloud
+}
+
+// GetEndpoint returns the endpoint of the service, such as https://elb.ap-southeast-1.myhuaweicloud.com.
+// The endpoints of the global services on HCS do not contain the region, such as https://iam-apigateway-proxy.{cloud}.
+// The private endpoint of the service in "endpoints" takes precedence.
+func (a *AuthOptions) GetEndpoint(service string) string {
+	if endpoint := a.getPrivateEndpoint(service); endpoint != "" {
+		return endpoint
+	}
+	if a.IsHCS() && service == "iam" {
+		return fmt.Sprintf("https://iam-apigateway-proxy.%s", a.GetCloud())
+	}
+	return fmt.Sprintf("https://%s.%s.%s", service, a.Region, a.GetCloud())
+}
+
+// GetServerListPageSize returns the number of the ECSs in a page, defaults to DefaultServerListPageSize.
+func (a *AuthOptions) GetServerListPageSize() int {
+	if a.ServerListPageSize <= 0 {
+		return DefaultServerListPageSize
+	}
+	return a.ServerListPageSize
+}
+
+// GetNewNodeLookupGracePeriod returns the seconds after a node is created, during which the lookup of its ECS
+// by name is retried, defaults to DefaultNewNodeLookupGracePeriod.
+func (a *AuthOptions) GetNewNodeLookupGracePeriod() int {
+	if a.NewNodeLookupGracePeriod <= 0 {
+		return DefaultNewNodeLookupGracePeriod
+	}
+	return a.NewNodeLookupGracePeriod
+}
+
+// GetShutoffInstancePolicy returns the policy of the SHUTOFF ECSs, defaults to ShutoffInstancePolicyShutdown.
+func (a *AuthOptions) GetShutoffInstancePolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(a.ShutoffInstancePolicy))
+	if policy == "" {
+		return ShutoffInstancePolicyShutdown
+	}
+	return policy
+}
+
+// GetNotReadyInstanceStatuses returns the ECS statuses that the nodes are not initialized in in upper case,
+// defaults to DefaultNotReadyInstanceStatuses.
+func (a *AuthOptions) GetNotReadyInstanceStatuses() []string {
+	list := splitList(a.NotReadyInstanceStatuses)
+	if len(list) == 0 {
+		list = splitList(DefaultNotReadyInstanceStatuses)
+	}
+	statuses := make([]string, 0, len(list))
+	for _, s := range list {
+		statuses = append(statuses, strings.ToUpper(s))
+	}
+	return statuses
+}
+
+// GetServerListMaxResults returns the maximum number of the ECSs listed, defaults to DefaultServerListMaxResults.
+func (a *AuthOptions) GetServerListMaxResults() int {
+	if a.ServerListMaxResults <= 0 {
+		return DefaultServerListMaxResults
+	}
+	return a.ServerListMaxResults
+}
+
+// GetCredentialRefreshSkew returns how long before the expiry the credentials are refreshed,
+// defaults to DefaultCredentialRefreshSkew.
+func (a *AuthOptions) GetCredentialRefreshSkew() time.Duration {
+	if a.CredentialRefreshSkew <= 0 {
+		return DefaultCredentialRefreshSkew
+	}
+	return time.Duration(a.CredentialRefreshSkew) * time.Second
+}
+
+// GetDuplicateServerNamePolicy returns the policy of the ECSs with the same name,
+// defaults to DuplicateServerNamePolicyPickFirst.
+func (a *AuthOptions) GetDuplicateServerNamePolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(a.DuplicateServerNamePolicy))
+	if policy == "" {
+		return DuplicateServerNamePolicyPickFirst
+	}
+	return policy
 }
 
-func (a *AuthOptions) GetHcClient(catalogName string) *core.HcHttpClient {
-	cloud := "myhuaweicloud.com"
-	if strings.TrimSpace(a.Cloud) != "" {
-		cloud = strings.TrimSpace(a.Cloud)
+// GetForeignServerPolicy returns the policy of the ECSs out of the project or the enterprise project,
+// defaults to ForeignServerPolicyNotFound.
+func (a *AuthOptions) GetForeignServerPolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(a.ForeignServerPolicy))
+	if policy == "" {
+		return ForeignServerPolicyNotFound
+	}
+	return policy
+}
+
+// Validate checks whether the required options of the cloud type are specified.
+func (a *AuthOptions) Validate() error {
+	if a.CredentialSecret != "" {
+		if _, _, err := parseSecretRef(a.CredentialSecret); err != nil {
+			return err
+		}
+	}
+	for _, addrType := range splitList(a.AllowedAddressTypes) {
+		if normalizeAddressType(addrType) == "" {
+			return fmt.Errorf(`unsupported address type %q in "allowed-address-types", supported values are %s`,
+				addrType, strings.Join(supportedAddressTypes, ", "))
+		}
+	}
+	if _, err := parseCIDRs(a.DeniedAddressCIDRs); err != nil {
+		return err
+	}
+	if a.NodeAddressesAnnotation != "" {
+		if errs := validation.IsQualifiedName(a.NodeAddressesAnnotation); len(errs) > 0 {
+			return fmt.Errorf(`invalid annotation key %q in "node-addresses-annotation": %s`,
+				a.NodeAddressesAnnotation, strings.Join(errs, "; "))
+		}
+	}
+	if _, err := parseEndpoints(a.Endpoints); err != nil {
+		return err
+	}
+	if a.ServerListPageSize < 0 || a.ServerListPageSize > MaxServerListPageSize {
+		return fmt.Errorf(`"server-list-page-size" must be between 0 and %d, got: %d`,
+			MaxServerListPageSize, a.ServerListPageSize)
+	}
+	if a.ServerListMaxResults < 0 {
+		return fmt.Errorf(`"server-list-max-results" must not be negative, got: %d`, a.ServerListMaxResults)
+	}
+	if a.NewNodeLookupRetries < 0 || a.NewNodeLookupRetries > MaxNewNodeLookupRetries {
+		return fmt.Errorf(`"new-node-lookup-retries" must be between 0 and %d, got: %d`,
+			MaxNewNodeLookupRetries, a.NewNodeLookupRetries)
+	}
+	if a.NewNodeLookupGracePeriod < 0 {
+		return fmt.Errorf(`"new-node-lookup-grace-period" must not be negative, got: %d`, a.NewNodeLookupGracePeriod)
+	}
+	if a.CredentialProbeInterval < 0 {
+		return fmt.Errorf(`"credential-probe-interval" must not be negative, got: %d`, a.CredentialProbeInterval)
+	}
+	if a.CredentialRefreshSkew < 0 {
+		return fmt.Errorf(`"credential-refresh-skew" must not be negative, got: %d`, a.CredentialRefreshSkew)
+	}
+	if a.RetryBudget < 0 {
+		return fmt.Errorf(`"retry-budget" must not be negative, got: %d`, a.RetryBudget)
+	}
+	if a.RetryBudgetRefillRatio < 0 || a.RetryBudgetRefillRatio > 1 {
+		return fmt.Errorf(`"retry-budget-refill-ratio" must be between 0 and 1, got: %v`, a.RetryBudgetRefillRatio)
+	}
+	if policy := a.GetShutoffInstancePolicy(); policy != ShutoffInstancePolicyShutdown &&
+		policy != ShutoffInstancePolicyIgnore {
+		return fmt.Errorf(`unsupported "shutoff-instance-policy" %q, supported values are %s and %s`,
+			a.ShutoffInstancePolicy, ShutoffInstancePolicyShutdown, ShutoffInstancePolicyIgnore)
+	}
+	switch a.GetDuplicateServerNamePolicy() {
+	case DuplicateServerNamePolicyPickFirst, DuplicateServerNamePolicyFail, DuplicateServerNamePolicyPickActive,
+		DuplicateServerNamePolicyPickNewest:
+	default:
+		return fmt.Errorf(`unsupported "duplicate-server-name-policy" %q, supported values are %s, %s, %s and %s`,
+			a.DuplicateServerNamePolicy, DuplicateServerNamePolicyPickFirst, DuplicateServerNamePolicyFail,
+			DuplicateServerNamePolicyPickActive, DuplicateServerNamePolicyPickNewest)
 	}
-	r := region.NewRegion(catalogName, fmt.Sprintf("https://%s.%s.%s", catalogName, a.Region, cloud))
+
+	if policy := a.GetForeignServerPolicy(); policy != ForeignServerPolicyNotFound && policy != ForeignServerPolicyFail {
+		return fmt.Errorf(`unsupported "foreign-server-policy" %q, supported values are %s and %s`,
+			a.ForeignServerPolicy, ForeignServerPolicyNotFound, ForeignServerPolicyFail)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(a.CloudType)) {
+	case "", CloudTypePublic:
+		return nil
+	case CloudTypeHCS:
+		if strings.TrimSpace(a.Cloud) == "" {
+			return fmt.Errorf(`"cloud" is required when "cloud-type" is %s`, CloudTypeHCS)
+		}
+		if a.ProjectID == "" {
+			return fmt.Errorf(`"project-id" is required when "cloud-type" is %s`, CloudTypeHCS)
+		}
+		return nil
+	default:
+		return fmt.Errorf(`unsupported "cloud-type" %q, supported values are %s and %s`,
+			a.CloudType, CloudTypePublic, CloudTypeHCS)
+	}
+}
+
+func (a *AuthOptions) GetHcClient(catalogName string) (*core.HcHttpClient, error) {
+	if err := a.Context().Err(); err != nil {
+		return nil, fmt.Errorf("the API call is cancelled: %w", err)
+	}
+	return a.getHcClient(catalogName, newHTTPConfig(a.correlationID))
+}
+
+// GetUserAgent returns the User-Agent used by the API calls, defaults to DefaultUserAgent.
+func (a *AuthOptions) GetUserAgent() string {
+	if strings.TrimSpace(a.UserAgent) == "" {
+		return DefaultUserAgent()
+	}
+	return strings.TrimSpace(a.UserAgent)
+}
+
+// DefaultUserAgent returns the default User-Agent, such as "cloud-provider-huaweicloud/v0.26.4".
+func DefaultUserAgent() string {
+	return fmt.Sprintf("%s/%s", userAgentPrefix, version.Get().GitVersion)
+}
+
+// GetAllowedAddressTypes returns the node address types reported to Kubernetes, nil means all the types.
+func (a *AuthOptions) GetAllowedAddressTypes() []string {
+	types := make([]string, 0)
+	for _, addrType := range splitList(a.AllowedAddressTypes) {
+		if normalized := normalizeAddressType(addrType); normalized != "" {
+			types = append(types, normalized)
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	return types
+}
+
+// GetDeniedAddressCIDRs returns the CIDRs whose addresses are not reported to Kubernetes, the invalid ones are ignored.
+func (a *AuthOptions) GetDeniedAddressCIDRs() []*net.IPNet {
+	cidrs := make([]*net.IPNet, 0)
+	for _, value := range splitList(a.DeniedAddressCIDRs) {
+		if _, cidr, err := net.ParseCIDR(value); err == nil {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+func parseCIDRs(value string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0)
+	for _, item := range splitList(value) {
+		_, cidr, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid CIDR %q in "denied-address-cidrs": %s`, item, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// splitList returns the non-empty items of the comma-separated list, with the spaces trimmed.
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeAddressType returns the supported address type case-insensitively equal to addrType, or "" if none.
+func normalizeAddressType(addrType string) string {
+	for _, supported := range supportedAddressTypes {
+		if strings.EqualFold(supported, addrType) {
+			return supported
+		}
+	}
+	return ""
+}
+
+// GetMetadataOptions returns the options used to fetch the documents from the metadata service.
+func (a *AuthOptions) GetMetadataOptions() metadata.Options {
+	return metadata.Options{
+		BaseURL: a.MetadataURL,
+		Version: a.MetadataVersion,
+		Format:  a.MetadataFormat,
+		Timeout: time.Duration(a.MetadataTimeout) * time.Second,
+	}
+}
+
+func (a *AuthOptions) getHcClient(catalogName string, httpConfig *sdkconfig.HttpConfig) (*core.HcHttpClient, error) {
+	credentials, err := a.getCredentials(httpConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	r := region.NewRegion(catalogName, a.GetEndpoint(catalogName))
 
 	client := core.NewHcHttpClientBuilder().
 		WithRegion(r).
-		WithCredential(a.GetCredentials()).
-		WithHttpConfig(newHTTPConfig()).
+		WithCredential(credentials).
+		WithHttpConfig(httpConfig).
 		Build()
 
-	client.PreInvoke(map[string]string{
-		"User-Agent": "huaweicloud-kubernetes-ccm",
-	})
-	return client
+	headers := map[string]string{
+		"User-Agent": a.GetUserAgent(),
+	}
+	if a.correlationID != "" {
+		headers[common.HeaderCorrelationID] = a.correlationID
+	}
+	client.PreInvoke(headers)
+	return client, nil
+}
+
+// WithCorrelationID returns a copy of the options whose API calls send the correlation ID in the header
+// and log it, the options themselves are returned if the ID is empty.
+func (a *AuthOptions) WithCorrelationID(id string) *AuthOptions {
+	if id == "" || id == a.correlationID {
+		return a
+	}
+	opts := *a
+	opts.correlationID = id
+	return &opts
+}
+
+// GetCorrelationID returns the correlation ID sent with the API calls, or "" if there is none.
+func (a *AuthOptions) GetCorrelationID() string {
+	return a.correlationID
+}
+
+// WithContext returns a copy of the options whose API calls and waits are stopped once the context is done,
+// the options themselves are returned if the context is nil.
+func (a *AuthOptions) WithContext(ctx context.Context) *AuthOptions {
+	if ctx == nil || ctx == a.ctx {
+		return a
+	}
+	opts := *a
+	opts.ctx = ctx
+	return &opts
 }
 
-func newHTTPConfig() *sdkconfig.HttpConfig {
+// Context returns the context of the API calls, context.Background() if there is none.
+func (a *AuthOptions) Context() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+// newHTTPConfig returns the HTTP config of the API calls, the correlation ID is logged with the calls if it is not empty.
+func newHTTPConfig(correlationID string) *sdkconfig.HttpConfig {
 	lrt := utils.LogRoundTripper{}
+	logSuffix := ""
+	if correlationID != "" {
+		logSuffix = ", correlation ID: " + correlationID
+	}
 	var err error
 
 	defConfig := sdkconfig.DefaultHttpConfig()
@@ -92,8 +701,8 @@ func newHTTPConfig() *sdkconfig.HttpConfig {
 	defConfig.HttpHandler = httpHandler
 
 	httpHandler.AddRequestHandler(func(request http.Request) {
-		klog.V(6).Infof("Request: [%s] %s\nHeaders: %s",
-			request.Method, request.URL, utils.FormatHeaders(request.Header, "\n    "))
+		klog.V(6).Infof("Request: [%s] %s%s\nHeaders: %s",
+			request.Method, request.URL, logSuffix, utils.FormatHeaders(request.Header, "\n    "))
 
 		if request.Body != nil {
 			request.Body, err = lrt.LogRequest(request.Body, request.Header.Get("Content-Type"))
@@ -114,33 +723,89 @@ func newHTTPConfig() *sdkconfig.HttpConfig {
 	})
 
 	httpHandler.AddMonitorHandler(func(m *httphandler.MonitorMetric) {
-		klog.Infof("%s https://%s%s%s %d in %d milliseconds, request ID: %s",
-			m.Method, m.Host, m.Path, m.Raw, m.StatusCode, m.Latency.Milliseconds(), m.RequestId)
+		klog.Infof("%s https://%s%s%s %d in %d milliseconds, request ID: %s%s",
+			m.Method, m.Host, m.Path, m.Raw, m.StatusCode, m.Latency.Milliseconds(), m.RequestId, logSuffix)
 	})
 
 	return defConfig
 }
 
+// ReadConfig reads the cloud-config in the INI, YAML or JSON format, then applies the defaults and validates it.
 func ReadConfig(cfg io.Reader) (*CloudConfig, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("Must provide a config file")
 	}
-	cc := &CloudConfig{}
+	data, err := io.ReadAll(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The retry budget is set before reading, so that "retry-budget=0" disables the retries.
+	cc := &CloudConfig{AuthOpts: AuthOptions{RetryBudget: DefaultRetryBudget}}
 	// Read configuration
-	err := gcfg.FatalOnly(gcfg.ReadInto(cc, cfg))
+	if isINIConfig(data) {
+		err = gcfg.FatalOnly(gcfg.ReadStringInto(cc, string(data)))
+	} else {
+		err = yaml.Unmarshal(data, cc)
+	}
 	if err != nil {
 		return nil, err
 	}
 	// Set default value
 	setDefaultConfig(cc)
+	if err = cc.AuthOpts.Validate(); err != nil {
+		return nil, err
+	}
+	if err = cc.VpcOpts.Validate(); err != nil {
+		return nil, err
+	}
 	return cc, nil
 }
 
+// isINIConfig returns true if the first section or option is an INI section header, such as [Global].
+func isINIConfig(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		return strings.HasPrefix(line, "[")
+	}
+	return true
+}
+
 func setDefaultConfig(cc *CloudConfig) {
+	if cc.AuthOpts.CloudType == "" {
+		cc.AuthOpts.CloudType = CloudTypePublic
+	}
 	if cc.AuthOpts.Cloud == "" {
-		cc.AuthOpts.Cloud = "myhuaweicloud.com"
+		cc.AuthOpts.Cloud = cc.AuthOpts.GetCloud()
 	}
-	if cc.AuthOpts.AuthURL == "" {
+	if cc.AuthOpts.AuthURL == "" && cc.AuthOpts.IsHCS() {
+		cc.AuthOpts.AuthURL = fmt.Sprintf("%s:443/v3/", cc.AuthOpts.GetEndpoint("iam"))
+	} else if cc.AuthOpts.AuthURL == "" {
 		cc.AuthOpts.AuthURL = fmt.Sprintf("https://iam.%s:443/v3/", cc.AuthOpts.Cloud)
 	}
+	if cc.AuthOpts.UserAgent == "" {
+		cc.AuthOpts.UserAgent = DefaultUserAgent()
+	}
+	if cc.AuthOpts.MetadataURL == "" {
+		cc.AuthOpts.MetadataURL = metadata.DefaultBaseURL
+	}
+	if cc.AuthOpts.MetadataVersion == "" {
+		cc.AuthOpts.MetadataVersion = metadata.DefaultVersion
+	}
+	if cc.AuthOpts.MetadataFormat == "" {
+		cc.AuthOpts.MetadataFormat = metadata.FormatOpenStack
+	}
+	if cc.AuthOpts.RetryBudgetRefillRatio == 0 {
+		cc.AuthOpts.RetryBudgetRefillRatio = DefaultRetryBudgetRefillRatio
+	}
+	if cc.AuthOpts.MetadataTimeout <= 0 {
+		cc.AuthOpts.MetadataTimeout = int(metadata.DefaultTimeout / time.Second)
+	}
+	cc.AuthOpts.AnnotationPrefix = strings.TrimSuffix(strings.TrimSpace(cc.AuthOpts.AnnotationPrefix), ".")
+	if cc.AuthOpts.AnnotationPrefix == "" {
+		cc.AuthOpts.AnnotationPrefix = DefaultAnnotationPrefix
+	}
 }
@@ -0,0 +1,1199 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	elb "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils/metadata"
+)
+
+func TestAuthOptionsRedacted(t *testing.T) {
+	const (
+		accessKey = "HPUAL1BFQDZ4XKVM7RTE"
+		secretKey = "Xq8fK2mR7pLwZ3nVb6JtYc9HsDuEgA1oWi4kNlQe"
+	)
+
+	cfg := &CloudConfig{
+		AuthOpts: AuthOptions{
+			Cloud:     "myhuaweicloud.com",
+			Region:    "ap-southeast-1",
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+			ProjectID: "0b5f4d1e7a8c4f2b9e6d3a1c5b7e9f0a",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		value  any
+	}{
+		{name: "value", format: "%v", value: cfg.AuthOpts},
+		{name: "pointer", format: "%v", value: &cfg.AuthOpts},
+		{name: "string", format: "%s", value: cfg.AuthOpts},
+		{name: "fields", format: "%+v", value: cfg.AuthOpts},
+		{name: "go syntax", format: "%#v", value: cfg.AuthOpts},
+		{name: "nested", format: "%v", value: cfg},
+		{name: "nested go syntax", format: "%#v", value: cfg},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			rst := fmt.Sprintf(te.format, te.value)
+			if strings.Contains(rst, accessKey) || strings.Contains(rst, secretKey) {
+				t.Fatalf("expected AK/SK to be redacted, got: %v", rst)
+			}
+			if !strings.Contains(rst, "ap-southeast-1") {
+				t.Fatalf("expected: region to be kept, got: %v", rst)
+			}
+		})
+	}
+}
+
+func TestReadConfigUserAgent(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: DefaultUserAgent(),
+		},
+		{
+			name:     "custom",
+			cfg:      "[Global]\nregion=ap-southeast-1\nuser-agent=my-cluster/v1\n",
+			expected: "my-cluster/v1",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if cfg.AuthOpts.UserAgent != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, cfg.AuthOpts.UserAgent)
+			}
+		})
+	}
+
+	if !strings.HasPrefix(DefaultUserAgent(), "cloud-provider-huaweicloud/") {
+		t.Fatalf("expected: cloud-provider-huaweicloud/<version>, got: %v", DefaultUserAgent())
+	}
+}
+
+func TestReadConfigMetadataOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected metadata.Options
+	}{
+		{
+			name: "default",
+			cfg:  "[Global]\nregion=ap-southeast-1\n",
+			expected: metadata.Options{
+				BaseURL: metadata.DefaultBaseURL,
+				Version: metadata.DefaultVersion,
+				Format:  metadata.FormatOpenStack,
+				Timeout: metadata.DefaultTimeout,
+			},
+		},
+		{
+			name: "custom",
+			cfg: "[Global]\nregion=ap-southeast-1\nmetadata-url=http://169.254.169.254/hcs\n" +
+				"metadata-version=2015-10-15\nmetadata-format=hcs\nmetadata-timeout=1\n",
+			expected: metadata.Options{
+				BaseURL: "http://169.254.169.254/hcs",
+				Version: "2015-10-15",
+				Format:  metadata.FormatHCS,
+				Timeout: time.Second,
+			},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := cfg.AuthOpts.GetMetadataOptions(); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetHcClientUserAgent(t *testing.T) {
+	userAgents := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"availability_zones": []}`))
+	}))
+	defer server.Close()
+
+	httpConfig := newTestHTTPConfig(server)
+
+	opts := &AuthOptions{
+		Region:    "ap-southeast-1",
+		AccessKey: "access-key",
+		SecretKey: "secret-key",
+		ProjectID: "project-id",
+		UserAgent: "my-cluster/v1",
+	}
+	_, err := elb.NewElbClient(mustGetHcClient(t, opts, "elb", httpConfig)).
+		ListAvailabilityZones(&elbmodel.ListAvailabilityZonesRequest{})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	userAgent := <-userAgents
+	if !strings.Contains(userAgent, "my-cluster/v1") {
+		t.Fatalf("expected: User-Agent contains %v, got: %v", "my-cluster/v1", userAgent)
+	}
+}
+
+func TestGetHcClientCorrelationID(t *testing.T) {
+	correlationIDs := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationIDs <- r.Header.Get(common.HeaderCorrelationID)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"availability_zones": []}`))
+	}))
+	defer server.Close()
+
+	logs := &bytes.Buffer{}
+	klog.LogToStderr(false)
+	klog.SetOutput(logs)
+	defer func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	}()
+
+	opts := (&AuthOptions{
+		Region:    "ap-southeast-1",
+		AccessKey: "access-key",
+		SecretKey: "secret-key",
+		ProjectID: "project-id",
+	}).WithCorrelationID("reconcile-1")
+	httpConfig := newHTTPConfig(opts.GetCorrelationID()).
+		WithIgnoreSSLVerification(true).
+		WithDialContext(func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		})
+	_, err := elb.NewElbClient(mustGetHcClient(t, opts, "elb", httpConfig)).
+		ListAvailabilityZones(&elbmodel.ListAvailabilityZonesRequest{})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	if got := <-correlationIDs; got != "reconcile-1" {
+		t.Fatalf("expected: %v, got: %v", "reconcile-1", got)
+	}
+	klog.Flush()
+	if !strings.Contains(logs.String(), "correlation ID: reconcile-1") {
+		t.Fatalf("expected: the API call is logged with the correlation ID, got: %v", logs.String())
+	}
+}
+
+func TestReadConfigCloudType(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       string
+		cloudType string
+		cloud     string
+		authURL   string
+		wantErr   bool
+	}{
+		{
+			name:      "default",
+			cfg:       "[Global]\nregion=ap-southeast-1\n",
+			cloudType: CloudTypePublic,
+			cloud:     "myhuaweicloud.com",
+			authURL:   "https://iam.myhuaweicloud.com:443/v3/",
+		},
+		{
+			name:      "hcs",
+			cfg:       "[Global]\nregion=region-1\ncloud-type=hcs\ncloud=hcs.example.com\nproject-id=project-id\n",
+			cloudType: CloudTypeHCS,
+			cloud:     "hcs.example.com",
+			authURL:   "https://iam-apigateway-proxy.hcs.example.com:443/v3/",
+		},
+		{
+			name:    "hcs without cloud",
+			cfg:     "[Global]\nregion=region-1\ncloud-type=hcs\nproject-id=project-id\n",
+			wantErr: true,
+		},
+		{
+			name:    "hcs without project ID",
+			cfg:     "[Global]\nregion=region-1\ncloud-type=hcs\ncloud=hcs.example.com\n",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported cloud type",
+			cfg:     "[Global]\nregion=region-1\ncloud-type=aws\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if cfg.AuthOpts.CloudType != te.cloudType {
+				t.Fatalf("expected: %v, got: %v", te.cloudType, cfg.AuthOpts.CloudType)
+			}
+			if cfg.AuthOpts.Cloud != te.cloud {
+				t.Fatalf("expected: %v, got: %v", te.cloud, cfg.AuthOpts.Cloud)
+			}
+			if cfg.AuthOpts.AuthURL != te.authURL {
+				t.Fatalf("expected: %v, got: %v", te.authURL, cfg.AuthOpts.AuthURL)
+			}
+		})
+	}
+}
+
+func TestGetEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *AuthOptions
+		service  string
+		expected string
+	}{
+		{
+			name:     "public default cloud",
+			opts:     &AuthOptions{Region: "ap-southeast-1"},
+			service:  "elb",
+			expected: "https://elb.ap-southeast-1.myhuaweicloud.com",
+		},
+		{
+			name:     "public custom cloud",
+			opts:     &AuthOptions{Region: "eu-west-101", Cloud: "myhuaweicloud.eu", CloudType: CloudTypePublic},
+			service:  "vpc",
+			expected: "https://vpc.eu-west-101.myhuaweicloud.eu",
+		},
+		{
+			name:     "public IAM",
+			opts:     &AuthOptions{Region: "ap-southeast-1"},
+			service:  "iam",
+			expected: "https://iam.ap-southeast-1.myhuaweicloud.com",
+		},
+		{
+			name:     "hcs",
+			opts:     &AuthOptions{Region: "region-1", Cloud: "hcs.example.com", CloudType: CloudTypeHCS},
+			service:  "ecs",
+			expected: "https://ecs.region-1.hcs.example.com",
+		},
+		{
+			name:     "hcs upper case",
+			opts:     &AuthOptions{Region: "region-1", Cloud: "hcs.example.com", CloudType: "HCS"},
+			service:  "ecs",
+			expected: "https://ecs.region-1.hcs.example.com",
+		},
+		{
+			name:     "hcs IAM",
+			opts:     &AuthOptions{Region: "region-1", Cloud: "hcs.example.com", CloudType: CloudTypeHCS},
+			service:  "iam",
+			expected: "https://iam-apigateway-proxy.hcs.example.com",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			if got := te.opts.GetEndpoint(te.service); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetHcClientCloudType(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        *AuthOptions
+		host        string
+		iamEndpoint string
+	}{
+		{
+			name:        "public",
+			opts:        &AuthOptions{Region: "ap-southeast-1", CloudType: CloudTypePublic},
+			host:        "elb.ap-southeast-1.myhuaweicloud.com",
+			iamEndpoint: "https://iam.myhuaweicloud.com",
+		},
+		{
+			name:        "hcs",
+			opts:        &AuthOptions{Region: "region-1", Cloud: "hcs.example.com", CloudType: CloudTypeHCS},
+			host:        "elb.region-1.hcs.example.com",
+			iamEndpoint: "https://iam-apigateway-proxy.hcs.example.com",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			hosts := make(chan string, 1)
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hosts <- r.Host
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"availability_zones": []}`))
+			}))
+			defer server.Close()
+
+			te.opts.AccessKey = "access-key"
+			te.opts.SecretKey = "secret-key"
+			te.opts.ProjectID = "project-id"
+			credentials, err := te.opts.GetCredentials()
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if credentials.IamEndpoint != te.iamEndpoint {
+				t.Fatalf("expected: %v, got: %v", te.iamEndpoint, credentials.IamEndpoint)
+			}
+
+			_, err = elb.NewElbClient(mustGetHcClient(t, te.opts, "elb", newTestHTTPConfig(server))).
+				ListAvailabilityZones(&elbmodel.ListAvailabilityZonesRequest{})
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := <-hosts; got != te.host {
+				t.Fatalf("expected: %v, got: %v", te.host, got)
+			}
+		})
+	}
+}
+
+func TestPrivateEndpointCache(t *testing.T) {
+	defer func(lookup func(string) ([]string, error)) { lookupHost = lookup }(lookupHost)
+	defer func(cache *privateEndpointCache) { privateEndpoints = cache }(privateEndpoints)
+
+	now := time.Now()
+	privateEndpoints = newPrivateEndpointCache(time.Minute)
+	privateEndpoints.now = func() time.Time { return now }
+	lookups := 0
+	lookupHost = func(host string) ([]string, error) {
+		lookups++
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+
+	opts := &AuthOptions{Region: "ap-southeast-1", Endpoints: "elb=elb.vpcep.example.com", PreferPrivateEndpoints: true}
+	for i := 0; i < 3; i++ {
+		// the copies of the options, such as the ones of each reconcile, share the cache.
+		if endpoint := opts.WithCorrelationID(fmt.Sprint(i)).getPrivateEndpoint("elb"); endpoint != "" {
+			t.Fatalf("expected: the private endpoint is not used, got: %v", endpoint)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("expected: 1 lookup, got: %v", lookups)
+	}
+
+	// the host is resolved again once the result expires, such as the CCM is moved into the VPC.
+	now = now.Add(time.Minute)
+	lookupHost = func(host string) ([]string, error) {
+		lookups++
+		return []string{"192.168.0.100"}, nil
+	}
+	if endpoint := opts.getPrivateEndpoint("elb"); endpoint != "https://elb.vpcep.example.com" {
+		t.Fatalf("expected: %v, got: %v", "https://elb.vpcep.example.com", endpoint)
+	}
+	if lookups != 2 {
+		t.Fatalf("expected: 2 lookups, got: %v", lookups)
+	}
+}
+
+func TestGetHcClientPrivateEndpoint(t *testing.T) {
+	defer func(lookup func(string) ([]string, error)) { lookupHost = lookup }(lookupHost)
+
+	endpoints := "ecs=ecs.vpcep.example.com, elb=https://elb.vpcep.example.com:8443/, iam=iam.vpcep.example.com"
+	tests := []struct {
+		name        string
+		prefer      bool
+		resolvable  bool
+		host        string
+		iamEndpoint string
+	}{
+		{
+			name:        "private endpoint",
+			host:        "elb.vpcep.example.com:8443",
+			iamEndpoint: "https://iam.vpcep.example.com",
+		},
+		{
+			name:        "prefer private endpoint inside VPC",
+			prefer:      true,
+			resolvable:  true,
+			host:        "elb.vpcep.example.com:8443",
+			iamEndpoint: "https://iam.vpcep.example.com",
+		},
+		{
+			name:        "prefer private endpoint outside VPC",
+			prefer:      true,
+			resolvable:  false,
+			host:        "elb.ap-southeast-1.myhuaweicloud.com",
+			iamEndpoint: "https://iam.myhuaweicloud.com",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			privateEndpoints = newPrivateEndpointCache(privateEndpointTTL)
+			lookupHost = func(host string) ([]string, error) {
+				if !te.resolvable {
+					return nil, fmt.Errorf("no such host %s", host)
+				}
+				return []string{"192.168.0.100"}, nil
+			}
+
+			hosts := make(chan string, 1)
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hosts <- r.Host
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"availability_zones": []}`))
+			}))
+			defer server.Close()
+
+			opts := &AuthOptions{
+				Region:                 "ap-southeast-1",
+				AccessKey:              "access-key",
+				SecretKey:              "secret-key",
+				ProjectID:              "project-id",
+				Endpoints:              endpoints,
+				PreferPrivateEndpoints: te.prefer,
+			}
+			credentials, err := opts.GetCredentials()
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if credentials.IamEndpoint != te.iamEndpoint {
+				t.Fatalf("expected: %v, got: %v", te.iamEndpoint, credentials.IamEndpoint)
+			}
+
+			_, err = elb.NewElbClient(mustGetHcClient(t, opts, "elb", newTestHTTPConfig(server))).
+				ListAvailabilityZones(&elbmodel.ListAvailabilityZonesRequest{})
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := <-hosts; got != te.host {
+				t.Fatalf("expected: %v, got: %v", te.host, got)
+			}
+			// The services without a private endpoint use the public one.
+			if got := opts.GetEndpoint("vpc"); got != "https://vpc.ap-southeast-1.myhuaweicloud.com" {
+				t.Fatalf("expected: %v, got: %v", "https://vpc.ap-southeast-1.myhuaweicloud.com", got)
+			}
+		})
+	}
+}
+
+func TestReadConfigEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:      "valid",
+			endpoints: "ECS=ecs.vpcep.example.com,vpc=http://vpc.vpcep.example.com",
+			expected:  "https://ecs.vpcep.example.com",
+		},
+		{
+			name:      "missing endpoint",
+			endpoints: "ecs=",
+			expectErr: true,
+		},
+		{
+			name:      "missing service",
+			endpoints: "ecs.vpcep.example.com",
+			expectErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(fmt.Sprintf("[Global]\nregion=ap-southeast-1\nendpoints=%s\n",
+				te.endpoints)))
+			if (err != nil) != te.expectErr {
+				t.Fatalf("expected error: %v, got: %v", te.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got := cfg.AuthOpts.GetEndpoint("ecs"); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfigFormats(t *testing.T) {
+	expected := CloudConfig{
+		AuthOpts: AuthOptions{
+			Cloud:           "myhuaweicloud.com",
+			CloudType:       CloudTypePublic,
+			AuthURL:         "https://iam.myhuaweicloud.com:443/v3/",
+			Region:          "ap-southeast-1",
+			AccessKey:       "access-key",
+			SecretKey:       "secret-key",
+			UserAgent:       DefaultUserAgent(),
+			MetadataURL:     metadata.DefaultBaseURL,
+			MetadataVersion: metadata.DefaultVersion,
+			MetadataFormat:  metadata.FormatOpenStack,
+			MetadataTimeout: 3,
+
+			AnnotationPrefix: DefaultAnnotationPrefix,
+
+			RetryBudget:            DefaultRetryBudget,
+			RetryBudgetRefillRatio: DefaultRetryBudgetRefillRatio,
+		},
+		VpcOpts: VpcOptions{
+			ID:           "vpc-id",
+			RouteTableID: "route-table-id",
+		},
+	}
+
+	tests := []struct {
+		name string
+		cfg  string
+	}{
+		{
+			name: "ini",
+			cfg: "# comment\n[Global]\nregion=ap-southeast-1\naccess-key=access-key\nsecret-key=secret-key\n" +
+				"[Vpc]\nid=vpc-id\nroute-table-id=route-table-id\n",
+		},
+		{
+			name: "yaml",
+			cfg: "# comment\nglobal:\n  region: ap-southeast-1\n  access-key: access-key\n  secret-key: secret-key\n" +
+				"vpc:\n  id: vpc-id\n  route-table-id: route-table-id\n",
+		},
+		{
+			name: "json",
+			cfg: `{"global": {"region": "ap-southeast-1", "access-key": "access-key", "secret-key": "secret-key"},` +
+				`"vpc": {"id": "vpc-id", "route-table-id": "route-table-id"}}`,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(*cfg, expected) {
+				t.Fatalf("expected: %#v, got: %#v", expected.AuthOpts, cfg.AuthOpts)
+			}
+		})
+	}
+}
+
+func TestReadConfigRoundTrip(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader("[Global]\nregion=region-1\ncloud-type=hcs\ncloud=hcs.example.com\n" +
+		"project-id=project-id\naccess-key=access-key\nsecret-key=secret-key\nmetadata-format=hcs\n" +
+		"[Vpc]\nid=vpc-id\nsubnet-id=subnet-id\nsecurity-group-id=security-group-id\n"))
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		marshal func(any) ([]byte, error)
+	}{
+		{name: "json", marshal: json.Marshal},
+		{name: "yaml", marshal: yaml.Marshal},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			data, err := te.marshal(cfg)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			got, err := ReadConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(got, cfg) {
+				t.Fatalf("expected: %#v, got: %#v\n%s", cfg.AuthOpts, got.AuthOpts, data)
+			}
+		})
+	}
+}
+
+func TestReadConfigDefaults(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader("global:\n  region: ap-southeast-1\n  metadata-version: 2018-08-27\n"))
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	opts := cfg.AuthOpts
+	defaults := map[string][2]string{
+		"cloud-type":       {CloudTypePublic, opts.CloudType},
+		"cloud":            {"myhuaweicloud.com", opts.Cloud},
+		"auth-url":         {"https://iam.myhuaweicloud.com:443/v3/", opts.AuthURL},
+		"user-agent":       {DefaultUserAgent(), opts.UserAgent},
+		"metadata-url":     {metadata.DefaultBaseURL, opts.MetadataURL},
+		"metadata-version": {"2018-08-27", opts.MetadataVersion},
+		"metadata-format":  {metadata.FormatOpenStack, opts.MetadataFormat},
+		"metadata-timeout": {"3", fmt.Sprint(opts.MetadataTimeout)},
+
+		"retry-budget":              {"10", fmt.Sprint(opts.RetryBudget)},
+		"retry-budget-refill-ratio": {"0.1", fmt.Sprint(opts.RetryBudgetRefillRatio)},
+	}
+	for name, v := range defaults {
+		if v[0] != v[1] {
+			t.Fatalf("%s, expected: %v, got: %v", name, v[0], v[1])
+		}
+	}
+
+	if _, err = ReadConfig(strings.NewReader("global:\n  region: region-1\n  cloud-type: hcs\n")); err == nil {
+		t.Fatalf("expected: error for HCS without cloud and project-id, got: nil")
+	}
+	if _, err = ReadConfig(strings.NewReader("global: [region-1]\n")); err == nil {
+		t.Fatalf("expected: error for malformed YAML, got: nil")
+	}
+}
+
+func TestReadConfigAnnotationPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: DefaultAnnotationPrefix,
+		},
+		{
+			name:     "custom",
+			cfg:      "[Global]\nregion=ap-southeast-1\nannotation-prefix=example.com/lb\n",
+			expected: "example.com/lb",
+		},
+		{
+			name:     "trailing dot",
+			cfg:      "[Global]\nregion=ap-southeast-1\nannotation-prefix=example.com/lb.\n",
+			expected: "example.com/lb",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if cfg.AuthOpts.AnnotationPrefix != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, cfg.AuthOpts.AnnotationPrefix)
+			}
+		})
+	}
+}
+
+func TestReadConfigAllowedAddressTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: nil,
+		},
+		{
+			name:     "internal only",
+			cfg:      "[Global]\nregion=ap-southeast-1\nallowed-address-types=InternalIP, hostname\n",
+			expected: []string{"InternalIP", "Hostname"},
+		},
+		{
+			name:    "unsupported type",
+			cfg:     "[Global]\nregion=ap-southeast-1\nallowed-address-types=InternalIP,ExternalDNS\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts.AllowedAddressTypes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := cfg.AuthOpts.GetAllowedAddressTypes(); !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfigDeniedAddressCIDRs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: []string{},
+		},
+		{
+			name:     "CIDRs",
+			cfg:      "[Global]\nregion=ap-southeast-1\ndenied-address-cidrs=10.10.0.1/16, 100.86.0.0/24\n",
+			expected: []string{"10.10.0.0/16", "100.86.0.0/24"},
+		},
+		{
+			name:    "invalid CIDR",
+			cfg:     "[Global]\nregion=ap-southeast-1\ndenied-address-cidrs=10.10.0.0/16,10.20.0.0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts.DeniedAddressCIDRs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			got := make([]string, 0)
+			for _, cidr := range cfg.AuthOpts.GetDeniedAddressCIDRs() {
+				got = append(got, cidr.String())
+			}
+			if !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfigNodeAddressesAnnotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: "",
+		},
+		{
+			name:     "annotation key",
+			cfg:      "[Global]\nregion=ap-southeast-1\nnode-addresses-annotation=example.com/node-addresses\n",
+			expected: "example.com/node-addresses",
+		},
+		{
+			name:    "invalid annotation key",
+			cfg:     "[Global]\nregion=ap-southeast-1\nnode-addresses-annotation=example.com/node addresses\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts.NodeAddressesAnnotation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if cfg.AuthOpts.NodeAddressesAnnotation != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, cfg.AuthOpts.NodeAddressesAnnotation)
+			}
+		})
+	}
+}
+
+func TestReadConfigDuplicateServerNamePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: DuplicateServerNamePolicyPickFirst,
+		},
+		{
+			name:     "pick-newest",
+			cfg:      "[Global]\nregion=ap-southeast-1\nduplicate-server-name-policy=Pick-Newest\n",
+			expected: DuplicateServerNamePolicyPickNewest,
+		},
+		{
+			name:    "unsupported policy",
+			cfg:     "[Global]\nregion=ap-southeast-1\nduplicate-server-name-policy=pick-oldest\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts.DuplicateServerNamePolicy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := cfg.AuthOpts.GetDuplicateServerNamePolicy(); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfigRetryBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           string
+		expected      int
+		expectedRatio float64
+		wantErr       bool
+	}{
+		{
+			name:          "default",
+			cfg:           "[Global]\nregion=ap-southeast-1\n",
+			expected:      DefaultRetryBudget,
+			expectedRatio: DefaultRetryBudgetRefillRatio,
+		},
+		{
+			name:          "specified",
+			cfg:           "[Global]\nregion=ap-southeast-1\nretry-budget=50\nretry-budget-refill-ratio=0.5\n",
+			expected:      50,
+			expectedRatio: 0.5,
+		},
+		{
+			name:          "retries disabled",
+			cfg:           "[Global]\nregion=ap-southeast-1\nretry-budget=0\n",
+			expected:      0,
+			expectedRatio: DefaultRetryBudgetRefillRatio,
+		},
+		{
+			name:          "retries disabled in YAML",
+			cfg:           "global:\n  region: ap-southeast-1\n  retry-budget: 0\n",
+			expected:      0,
+			expectedRatio: DefaultRetryBudgetRefillRatio,
+		},
+		{
+			name:    "negative budget",
+			cfg:     "[Global]\nregion=ap-southeast-1\nretry-budget=-1\n",
+			wantErr: true,
+		},
+		{
+			name:    "refill ratio out of range",
+			cfg:     "[Global]\nregion=ap-southeast-1\nretry-budget-refill-ratio=2\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v, %v", cfg.AuthOpts.RetryBudget, cfg.AuthOpts.RetryBudgetRefillRatio)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if cfg.AuthOpts.RetryBudget != te.expected || cfg.AuthOpts.RetryBudgetRefillRatio != te.expectedRatio {
+				t.Fatalf("expected: %v, %v, got: %v, %v", te.expected, te.expectedRatio,
+					cfg.AuthOpts.RetryBudget, cfg.AuthOpts.RetryBudgetRefillRatio)
+			}
+		})
+	}
+}
+
+func TestReadConfigServerList(t *testing.T) {
+	tests := []struct {
+		name               string
+		cfg                string
+		expectedPageSize   int
+		expectedMaxResults int
+		wantErr            bool
+	}{
+		{
+			name:               "default",
+			cfg:                "[Global]\nregion=ap-southeast-1\n",
+			expectedPageSize:   DefaultServerListPageSize,
+			expectedMaxResults: DefaultServerListMaxResults,
+		},
+		{
+			name:               "specified",
+			cfg:                "[Global]\nregion=ap-southeast-1\nserver-list-page-size=500\nserver-list-max-results=2000\n",
+			expectedPageSize:   500,
+			expectedMaxResults: 2000,
+		},
+		{
+			name:    "page size out of range",
+			cfg:     "[Global]\nregion=ap-southeast-1\nserver-list-page-size=1001\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative max results",
+			cfg:     "[Global]\nregion=ap-southeast-1\nserver-list-max-results=-1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v, %v", cfg.AuthOpts.ServerListPageSize,
+						cfg.AuthOpts.ServerListMaxResults)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			pageSize, maxResults := cfg.AuthOpts.GetServerListPageSize(), cfg.AuthOpts.GetServerListMaxResults()
+			if pageSize != te.expectedPageSize || maxResults != te.expectedMaxResults {
+				t.Fatalf("expected: %v, %v, got: %v, %v", te.expectedPageSize, te.expectedMaxResults,
+					pageSize, maxResults)
+			}
+		})
+	}
+}
+
+func TestReadConfigNewNodeLookup(t *testing.T) {
+	tests := []struct {
+		name                string
+		cfg                 string
+		expectedRetries     int
+		expectedGracePeriod int
+		wantErr             bool
+	}{
+		{
+			name:                "default",
+			cfg:                 "[Global]\nregion=ap-southeast-1\n",
+			expectedGracePeriod: DefaultNewNodeLookupGracePeriod,
+		},
+		{
+			name:                "specified",
+			cfg:                 "[Global]\nregion=ap-southeast-1\nnew-node-lookup-retries=3\nnew-node-lookup-grace-period=60\n",
+			expectedRetries:     3,
+			expectedGracePeriod: 60,
+		},
+		{
+			name:    "negative retries",
+			cfg:     "[Global]\nregion=ap-southeast-1\nnew-node-lookup-retries=-1\n",
+			wantErr: true,
+		},
+		{
+			name:    "too many retries",
+			cfg:     "[Global]\nregion=ap-southeast-1\nnew-node-lookup-retries=11\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative grace period",
+			cfg:     "[Global]\nregion=ap-southeast-1\nnew-node-lookup-grace-period=-1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v, %v", cfg.AuthOpts.NewNodeLookupRetries,
+						cfg.AuthOpts.NewNodeLookupGracePeriod)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			retries, gracePeriod := cfg.AuthOpts.NewNodeLookupRetries, cfg.AuthOpts.GetNewNodeLookupGracePeriod()
+			if retries != te.expectedRetries || gracePeriod != te.expectedGracePeriod {
+				t.Fatalf("expected: %v, %v, got: %v, %v", te.expectedRetries, te.expectedGracePeriod,
+					retries, gracePeriod)
+			}
+		})
+	}
+}
+
+func TestReadConfigShutoffInstancePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: ShutoffInstancePolicyShutdown,
+		},
+		{
+			name:     "ignore",
+			cfg:      "[Global]\nregion=ap-southeast-1\nshutoff-instance-policy=Ignore\n",
+			expected: ShutoffInstancePolicyIgnore,
+		},
+		{
+			name:    "unsupported",
+			cfg:     "[Global]\nregion=ap-southeast-1\nshutoff-instance-policy=delete\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts.ShutoffInstancePolicy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := cfg.AuthOpts.GetShutoffInstancePolicy(); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfigNotReadyInstanceStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected []string
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: []string{"BUILD", "BUILDING", "REBOOT", "HARD_REBOOT", "REBUILD"},
+		},
+		{
+			name:     "customized",
+			cfg:      "[Global]\nregion=ap-southeast-1\nnot-ready-instance-statuses=build, Migrating\n",
+			expected: []string{"BUILD", "MIGRATING"},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := cfg.AuthOpts.GetNotReadyInstanceStatuses(); !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfigForeignServerPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			cfg:      "[Global]\nregion=ap-southeast-1\n",
+			expected: ForeignServerPolicyNotFound,
+		},
+		{
+			name:     "fail",
+			cfg:      "[Global]\nregion=ap-southeast-1\nforeign-server-policy=Fail\n",
+			expected: ForeignServerPolicyFail,
+		},
+		{
+			name:    "unsupported",
+			cfg:     "[Global]\nregion=ap-southeast-1\nforeign-server-policy=manage\n",
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg, err := ReadConfig(strings.NewReader(te.cfg))
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", cfg.AuthOpts.ForeignServerPolicy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := cfg.AuthOpts.GetForeignServerPolicy(); got != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// The default keys of the AK/SK and the security token in the data of the credential Secret.
+	DefaultSecretAccessKeyKey     = "access-key"
+	DefaultSecretSecretKeyKey     = "secret-key"
+	DefaultSecretSecurityTokenKey = "security-token"
+	// DefaultSecretExpiresAtKey is the key of the expiry of the security token, in RFC 3339.
+	DefaultSecretExpiresAtKey = "expires-at"
+
+	// DefaultCredentialRefreshInterval is the interval to re-read the credential Secret.
+	DefaultCredentialRefreshInterval = 5 * time.Minute
+	// DefaultCredentialRefreshSkew is how long before the expiry the credentials are refreshed by the probe.
+	DefaultCredentialRefreshSkew = 5 * time.Minute
+)
+
+// CredentialProvider provides the credentials used to build the API clients.
+// It is consulted before each client is built, so that the credentials can be rotated without restart.
+type CredentialProvider interface {
+	// GetCredentials returns the AK/SK and the security token, the token is empty for permanent credentials.
+	GetCredentials(ctx context.Context) (ak, sk, token string, err error)
+}
+
+// RefreshableCredentialProvider is a CredentialProvider whose credentials expire, such as the temporary
+// security token, they can be refreshed before the expiry.
+type RefreshableCredentialProvider interface {
+	CredentialProvider
+	// ExpiresAt returns when the credentials expire, zero if they do not expire or it is unknown.
+	ExpiresAt() time.Time
+	// Refresh reloads the credentials regardless of the cache.
+	Refresh(ctx context.Context) error
+}
+
+// StaticCredentialProvider provides the permanent AK/SK read from the cloud-config.
+type StaticCredentialProvider struct {
+	AuthOpts *AuthOptions
+}
+
+func (p *StaticCredentialProvider) GetCredentials(_ context.Context) (string, string, string, error) {
+	return p.AuthOpts.AccessKey, p.AuthOpts.SecretKey, "", nil
+}
+
+// SecretCredentialProvider provides the AK/SK and the security token read from a Kubernetes Secret.
+// The Secret is re-read after the refresh interval, so that the rotated credentials take effect without restart.
+// The last credentials are still used if the Secret fails to be re-read. If the Secret specifies the expiry
+// of the security token, the Secret is re-read once the token expires.
+type SecretCredentialProvider struct {
+	client           corev1.SecretsGetter
+	namespace        string
+	name             string
+	accessKeyKey     string
+	secretKeyKey     string
+	securityTokenKey string
+	expiresAtKey     string
+	refreshInterval  time.Duration
+	now              func() time.Time
+
+	mu        sync.Mutex
+	ak        string
+	sk        string
+	token     string
+	expiresAt time.Time
+	loadedAt  time.Time
+}
+
+// NewSecretCredentialProvider returns the provider reading the Secret specified by "credential-secret".
+func NewSecretCredentialProvider(client corev1.SecretsGetter, opts *AuthOptions) (*SecretCredentialProvider, error) {
+	namespace, name, err := parseSecretRef(opts.CredentialSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCredentialProvider{
+		client:           client,
+		namespace:        namespace,
+		name:             name,
+		accessKeyKey:     getOrDefault(opts.CredentialSecretAccessKeyKey, DefaultSecretAccessKeyKey),
+		secretKeyKey:     getOrDefault(opts.CredentialSecretSecretKeyKey, DefaultSecretSecretKeyKey),
+		securityTokenKey: getOrDefault(opts.CredentialSecretSecurityTokenKey, DefaultSecretSecurityTokenKey),
+		expiresAtKey:     getOrDefault(opts.CredentialSecretExpiresAtKey, DefaultSecretExpiresAtKey),
+		refreshInterval:  DefaultCredentialRefreshInterval,
+		now:              time.Now,
+	}, nil
+}
+
+func (p *SecretCredentialProvider) GetCredentials(ctx context.Context) (string, string, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	expired := !p.expiresAt.IsZero() && !now.Before(p.expiresAt)
+	if !p.loadedAt.IsZero() && now.Sub(p.loadedAt) < p.refreshInterval && !expired {
+		return p.ak, p.sk, p.token, nil
+	}
+
+	if err := p.refresh(ctx); err != nil {
+		if p.loadedAt.IsZero() {
+			return "", "", "", err
+		}
+		klog.Warningf("failed to refresh the credentials, the last ones are used: %s", err)
+	}
+	return p.ak, p.sk, p.token, nil
+}
+
+// ExpiresAt returns the expiry of the security token read from the Secret, zero if it is not specified.
+func (p *SecretCredentialProvider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expiresAt
+}
+
+// Refresh re-reads the Secret, the last credentials are kept if it fails.
+func (p *SecretCredentialProvider) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.refresh(ctx)
+}
+
+func (p *SecretCredentialProvider) refresh(ctx context.Context) error {
+	secret, err := p.client.Secrets(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get the credential secret %s/%s: %s", p.namespace, p.name, err)
+	}
+
+	ak := strings.TrimSpace(string(secret.Data[p.accessKeyKey]))
+	sk := strings.TrimSpace(string(secret.Data[p.secretKeyKey]))
+	if ak == "" || sk == "" {
+		return fmt.Errorf("the credential secret %s/%s does not contain %q and %q",
+			p.namespace, p.name, p.accessKeyKey, p.secretKeyKey)
+	}
+	var expiresAt time.Time
+	if value := strings.TrimSpace(string(secret.Data[p.expiresAtKey])); value != "" {
+		if expiresAt, err = time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("invalid %q in the credential secret %s/%s: %s", p.expiresAtKey,
+				p.namespace, p.name, err)
+		}
+	}
+
+	p.ak, p.sk, p.token = ak, sk, strings.TrimSpace(string(secret.Data[p.securityTokenKey]))
+	p.expiresAt, p.loadedAt = expiresAt, p.now()
+	return nil
+}
+
+// parseSecretRef parses the Secret reference in the format of namespace/name.
+func parseSecretRef(ref string) (string, string, error) {
+	parts := strings.Split(strings.TrimSpace(ref), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf(`invalid "credential-secret" %q, it should be in the format of namespace/name`, ref)
+	}
+	return parts[0], parts[1], nil
+}
+
+func getOrDefault(value, defaultValue string) string {
+	if value = strings.TrimSpace(value); value != "" {
+		return value
+	}
+	return defaultValue
+}
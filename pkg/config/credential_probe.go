@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/common"
+)
+
+// CredentialProbe validates the credentials in the background with a cheap API call, and refreshes the ones
+// about to expire, so that the reconciles do not fail with the expired credentials.
+type CredentialProbe struct {
+	provider CredentialProvider
+	// validate makes an API call with the current credentials.
+	validate func() error
+	interval time.Duration
+	skew     time.Duration
+	now      func() time.Time
+}
+
+// NewCredentialProbe returns the probe of the credentials of the options, validate makes an API call with them.
+func NewCredentialProbe(opts *AuthOptions, validate func() error) *CredentialProbe {
+	return &CredentialProbe{
+		provider: opts.GetCredentialProvider(),
+		validate: validate,
+		interval: time.Duration(opts.CredentialProbeInterval) * time.Second,
+		skew:     opts.GetCredentialRefreshSkew(),
+		now:      time.Now,
+	}
+}
+
+// Run probes the credentials periodically until stopCh is closed, it returns at once if the probe is disabled.
+func (p *CredentialProbe) Run(stopCh <-chan struct{}) {
+	if p.interval <= 0 {
+		return
+	}
+	klog.Infof("probe the credentials every %s, refresh them %s before the expiry", p.interval, p.skew)
+	wait.Until(func() {
+		_ = p.probe(context.TODO())
+	}, p.interval, stopCh)
+}
+
+// probe refreshes the credentials if they expire within the skew, then validates them. If the credentials
+// are rejected, they are refreshed and validated again.
+func (p *CredentialProbe) probe(ctx context.Context) error {
+	refreshable, ok := p.provider.(RefreshableCredentialProvider)
+	if ok {
+		if expiresAt := refreshable.ExpiresAt(); !expiresAt.IsZero() && !p.now().Add(p.skew).Before(expiresAt) {
+			klog.Infof("the credentials expire at %s, refresh them", expiresAt.Format(time.RFC3339))
+			if err := refreshable.Refresh(ctx); err != nil {
+				klog.Warningf("failed to refresh the credentials before the expiry: %s", err)
+			}
+		}
+	}
+	if p.validate == nil {
+		return nil
+	}
+
+	err := p.validate()
+	if err != nil && ok && common.IsUnauthorized(err) {
+		klog.Warningf("the credentials are rejected, refresh them: %s", err)
+		if err = refreshable.Refresh(ctx); err == nil {
+			err = p.validate()
+		}
+	}
+	if err != nil {
+		klog.Errorf("failed to validate the credentials: %s", err)
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+)
+
+// expiringCredentialProvider provides a security token that expires, each refresh extends it by an hour.
+type expiringCredentialProvider struct {
+	expiresAt time.Time
+	refreshes int
+}
+
+func (p *expiringCredentialProvider) GetCredentials(_ context.Context) (string, string, string, error) {
+	return "ACCESSKEY", "secret-key", fmt.Sprintf("token-%d", p.refreshes), nil
+}
+
+func (p *expiringCredentialProvider) ExpiresAt() time.Time {
+	return p.expiresAt
+}
+
+func (p *expiringCredentialProvider) Refresh(_ context.Context) error {
+	p.refreshes++
+	p.expiresAt = p.expiresAt.Add(time.Hour)
+	return nil
+}
+
+func TestCredentialProbe(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	unauthorized := sdkerr.ServiceResponseError{StatusCode: 401}
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// validations are the results of the validations in order.
+		validations []error
+		refreshes   int
+		wantErr     bool
+	}{
+		{
+			name:        "token nears expiry",
+			expiresIn:   2 * time.Minute,
+			validations: []error{nil},
+			refreshes:   1,
+		},
+		{
+			name:        "token expired",
+			expiresIn:   -time.Minute,
+			validations: []error{nil},
+			refreshes:   1,
+		},
+		{
+			name:        "token valid beyond the skew",
+			expiresIn:   time.Hour,
+			validations: []error{nil},
+			refreshes:   0,
+		},
+		{
+			name:        "rejected credentials are refreshed",
+			expiresIn:   time.Hour,
+			validations: []error{unauthorized, nil},
+			refreshes:   1,
+		},
+		{
+			name:        "still rejected after the refresh",
+			expiresIn:   time.Hour,
+			validations: []error{unauthorized, unauthorized},
+			refreshes:   1,
+			wantErr:     true,
+		},
+		{
+			name:        "other errors are not refreshed",
+			expiresIn:   time.Hour,
+			validations: []error{sdkerr.ServiceResponseError{StatusCode: 503}},
+			refreshes:   0,
+			wantErr:     true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			provider := &expiringCredentialProvider{expiresAt: now.Add(te.expiresIn)}
+			opts := &AuthOptions{CredentialProbeInterval: 60}
+			opts.SetCredentialProvider(provider)
+
+			validations := 0
+			probe := NewCredentialProbe(opts, func() error {
+				err := te.validations[validations]
+				validations++
+				return err
+			})
+			probe.now = func() time.Time { return now }
+
+			err := probe.probe(context.TODO())
+			if (err != nil) != te.wantErr {
+				t.Fatalf("expected error: %v, got: %v", te.wantErr, err)
+			}
+			if provider.refreshes != te.refreshes || validations != len(te.validations) {
+				t.Fatalf("expected: %d refreshes and %d validations, got: %d, %d", te.refreshes,
+					len(te.validations), provider.refreshes, validations)
+			}
+		})
+	}
+}
+
+func TestCredentialProbeStaticCredentials(t *testing.T) {
+	probe := NewCredentialProbe(&AuthOptions{AccessKey: "ACCESSKEY", SecretKey: "secret-key"}, func() error {
+		return sdkerr.ServiceResponseError{StatusCode: 401}
+	})
+	// the permanent AK/SK can not be refreshed, the error is reported.
+	if err := probe.probe(context.TODO()); err == nil {
+		t.Fatalf("expected: an error, got: %v", err)
+	}
+
+	// the probe is disabled by default.
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	done := make(chan struct{})
+	go func() {
+		probe.Run(stopCh)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected: the disabled probe returns at once")
+	}
+}
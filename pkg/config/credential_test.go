@@ -0,0 +1,354 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core"
+	sdkconfig "github.com/huaweicloud/huaweicloud-sdk-go-v3/core/config"
+	elb "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3"
+	elbmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/elb/v3/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+type rotatingCredentialProvider struct {
+	calls int
+}
+
+func (p *rotatingCredentialProvider) GetCredentials(_ context.Context) (string, string, string, error) {
+	p.calls++
+	return fmt.Sprintf("ACCESSKEY%d", p.calls), fmt.Sprintf("secret-key-%d", p.calls),
+		fmt.Sprintf("token-%d", p.calls), nil
+}
+
+type failedCredentialProvider struct{}
+
+func (p *failedCredentialProvider) GetCredentials(_ context.Context) (string, string, string, error) {
+	return "", "", "", fmt.Errorf("secret manager is unavailable")
+}
+
+// newTestHTTPConfig sends all requests to the test server, regardless of the endpoint.
+func newTestHTTPConfig(server *httptest.Server) *sdkconfig.HttpConfig {
+	return newHTTPConfig("").
+		WithIgnoreSSLVerification(true).
+		WithDialContext(func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		})
+}
+
+func mustGetHcClient(t *testing.T, opts *AuthOptions, catalogName string, httpConfig *sdkconfig.HttpConfig) *core.HcHttpClient {
+	hc, err := opts.getHcClient(catalogName, httpConfig)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	return hc
+}
+
+func TestGetHcClientRotatingCredentials(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"availability_zones": []}`))
+	}))
+	defer server.Close()
+
+	opts := &AuthOptions{
+		Region:    "ap-southeast-1",
+		AccessKey: "STATICACCESSKEY",
+		SecretKey: "static-secret-key",
+		ProjectID: "project-id",
+	}
+	opts.SetCredentialProvider(&rotatingCredentialProvider{})
+
+	for i := 1; i <= 2; i++ {
+		// The client is rebuilt for each call, as the wrappers do.
+		hc := mustGetHcClient(t, opts, "elb", newTestHTTPConfig(server))
+		_, err := elb.NewElbClient(hc).ListAvailabilityZones(&elbmodel.ListAvailabilityZonesRequest{})
+		if err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+
+		header := <-headers
+		expected := fmt.Sprintf("Access=ACCESSKEY%d,", i)
+		if !strings.Contains(header.Get("Authorization"), expected) {
+			t.Fatalf("expected: Authorization contains %v, got: %v", expected, header.Get("Authorization"))
+		}
+		if token := header.Get("X-Security-Token"); token != fmt.Sprintf("token-%d", i) {
+			t.Fatalf("expected: token-%d, got: %v", i, token)
+		}
+	}
+}
+
+func TestGetCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider CredentialProvider
+		ak       string
+		err      bool
+	}{
+		{
+			name:     "static by default",
+			provider: nil,
+			ak:       "STATICACCESSKEY",
+		},
+		{
+			name:     "rotating",
+			provider: &rotatingCredentialProvider{},
+			ak:       "ACCESSKEY1",
+		},
+		{
+			name:     "failed",
+			provider: &failedCredentialProvider{},
+			err:      true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			opts := &AuthOptions{AccessKey: "STATICACCESSKEY", SecretKey: "static-secret-key", ProjectID: "project-id"}
+			if te.provider != nil {
+				opts.SetCredentialProvider(te.provider)
+			}
+
+			credentials, err := opts.GetCredentials()
+			if (err != nil) != te.err {
+				t.Fatalf("expected error: %v, got: %v", te.err, err)
+			}
+			if err == nil && credentials.AK != te.ak {
+				t.Fatalf("expected: %v, got: %v", te.ak, credentials.AK)
+			}
+		})
+	}
+
+	opts := &AuthOptions{}
+	if _, err := opts.GetCredentials(); err == nil {
+		t.Fatalf("expected: error for empty AK/SK, got: nil")
+	}
+}
+
+// fakeSecretServer serves the Secrets by the API path, such as /api/v1/namespaces/kube-system/secrets/name.
+type fakeSecretServer struct {
+	mu       sync.Mutex
+	secrets  map[string]map[string][]byte
+	requests int
+}
+
+func (f *fakeSecretServer) set(path string, data map[string][]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[path] = data
+}
+
+func (f *fakeSecretServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	w.Header().Set("Content-Type", "application/json")
+	data, ok := f.secrets[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Reason:   metav1.StatusReasonNotFound,
+			Code:     http.StatusNotFound,
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(v1.Secret{
+		TypeMeta: metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+		Data:     data,
+	})
+}
+
+func newFakeSecretClient(t *testing.T, handler http.Handler) corev1.SecretsGetter {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := corev1.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	return client
+}
+
+func TestSecretCredentialProvider(t *testing.T) {
+	path := "/api/v1/namespaces/kube-system/secrets/cloud-credential"
+	fake := &fakeSecretServer{secrets: map[string]map[string][]byte{
+		path: {"access-key": []byte("ACCESSKEY1"), "secret-key": []byte("secret-key-1"), "security-token": []byte("token-1")},
+	}}
+	provider, err := NewSecretCredentialProvider(newFakeSecretClient(t, fake),
+		&AuthOptions{CredentialSecret: "kube-system/cloud-credential"})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	assertCredentials := func(ak, sk, token string, requests int) {
+		t.Helper()
+		gotAK, gotSK, gotToken, err := provider.GetCredentials(context.TODO())
+		if err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+		if gotAK != ak || gotSK != sk || gotToken != token {
+			t.Fatalf("expected: %v/%v/%v, got: %v/%v/%v", ak, sk, token, gotAK, gotSK, gotToken)
+		}
+		if fake.requests != requests {
+			t.Fatalf("expected: %v requests, got: %v", requests, fake.requests)
+		}
+	}
+
+	assertCredentials("ACCESSKEY1", "secret-key-1", "token-1", 1)
+
+	// the credentials are cached within the refresh interval.
+	fake.set(path, map[string][]byte{"access-key": []byte("ACCESSKEY2"), "secret-key": []byte("secret-key-2")})
+	now = now.Add(time.Minute)
+	assertCredentials("ACCESSKEY1", "secret-key-1", "token-1", 1)
+
+	// the rotated credentials are read after the refresh interval.
+	now = now.Add(DefaultCredentialRefreshInterval)
+	assertCredentials("ACCESSKEY2", "secret-key-2", "", 2)
+
+	// the last credentials are used if the Secret fails to be re-read.
+	fake.set(path, map[string][]byte{})
+	now = now.Add(DefaultCredentialRefreshInterval)
+	assertCredentials("ACCESSKEY2", "secret-key-2", "", 3)
+}
+
+func TestSecretCredentialProviderExpiry(t *testing.T) {
+	path := "/api/v1/namespaces/kube-system/secrets/cloud-credential"
+	fake := &fakeSecretServer{secrets: map[string]map[string][]byte{
+		path: {"access-key": []byte("ACCESSKEY1"), "secret-key": []byte("secret-key-1"),
+			"security-token": []byte("token-1"), "expires-at": []byte("2023-06-01T00:03:00Z")},
+	}}
+	provider, err := NewSecretCredentialProvider(newFakeSecretClient(t, fake),
+		&AuthOptions{CredentialSecret: "kube-system/cloud-credential"})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	if _, _, token, err := provider.GetCredentials(context.TODO()); err != nil || token != "token-1" {
+		t.Fatalf("expected: token-1, got: %v, %v", token, err)
+	}
+	if expected := now.Add(3 * time.Minute); !provider.ExpiresAt().Equal(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, provider.ExpiresAt())
+	}
+
+	// the Secret is refreshed on demand, and re-read once the token expires within the refresh interval.
+	fake.set(path, map[string][]byte{"access-key": []byte("ACCESSKEY1"), "secret-key": []byte("secret-key-1"),
+		"security-token": []byte("token-2"), "expires-at": []byte("2023-06-01T01:00:00Z")})
+	now = now.Add(3 * time.Minute)
+	if _, _, token, err := provider.GetCredentials(context.TODO()); err != nil || token != "token-2" {
+		t.Fatalf("expected: token-2, got: %v, %v", token, err)
+	}
+	fake.set(path, map[string][]byte{"access-key": []byte("ACCESSKEY1"), "secret-key": []byte("secret-key-1"),
+		"security-token": []byte("token-3")})
+	if err = provider.Refresh(context.TODO()); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if _, _, token, _ := provider.GetCredentials(context.TODO()); token != "token-3" || !provider.ExpiresAt().IsZero() {
+		t.Fatalf("expected: token-3 without expiry, got: %v, %v", token, provider.ExpiresAt())
+	}
+
+	// the malformed expiry is rejected, the last credentials are kept.
+	fake.set(path, map[string][]byte{"access-key": []byte("ACCESSKEY1"), "secret-key": []byte("secret-key-1"),
+		"expires-at": []byte("tomorrow")})
+	if err = provider.Refresh(context.TODO()); err == nil {
+		t.Fatalf("expected: an error, got: %v", err)
+	}
+	if _, _, token, _ := provider.GetCredentials(context.TODO()); token != "token-3" {
+		t.Fatalf("expected: token-3, got: %v", token)
+	}
+}
+
+func TestSecretCredentialProviderCustomKeys(t *testing.T) {
+	fake := &fakeSecretServer{secrets: map[string]map[string][]byte{
+		"/api/v1/namespaces/default/secrets/hwcloud": {"ak": []byte("ACCESSKEY"), "sk": []byte("secret-key")},
+	}}
+	opts := &AuthOptions{
+		CredentialSecret:             "default/hwcloud",
+		CredentialSecretAccessKeyKey: "ak",
+		CredentialSecretSecretKeyKey: "sk",
+		ProjectID:                    "project-id",
+	}
+	provider, err := NewSecretCredentialProvider(newFakeSecretClient(t, fake), opts)
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	opts.SetCredentialProvider(provider)
+
+	credentials, err := opts.GetCredentials()
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if credentials.AK != "ACCESSKEY" || credentials.SK != "secret-key" {
+		t.Fatalf("expected: ACCESSKEY/secret-key, got: %v/%v", credentials.AK, credentials.SK)
+	}
+}
+
+func TestSecretCredentialProviderErrors(t *testing.T) {
+	fake := &fakeSecretServer{secrets: map[string]map[string][]byte{
+		"/api/v1/namespaces/default/secrets/empty": {"access-key": []byte("ACCESSKEY")},
+	}}
+	client := newFakeSecretClient(t, fake)
+
+	tests := []struct {
+		name      string
+		secret    string
+		createErr bool
+	}{
+		{name: "invalid reference", secret: "cloud-credential", createErr: true},
+		{name: "not found", secret: "default/missing"},
+		{name: "missing secret key", secret: "default/empty"},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			provider, err := NewSecretCredentialProvider(client, &AuthOptions{CredentialSecret: te.secret})
+			if te.createErr {
+				if err == nil {
+					t.Fatalf("expected: an error, got: nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if _, _, _, err = provider.GetCredentials(context.TODO()); err == nil {
+				t.Fatalf("expected: an error, got: nil")
+			}
+		})
+	}
+}
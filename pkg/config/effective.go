@@ -0,0 +1,83 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+
+	"sigs.k8s.io/cloud-provider-huaweicloud/pkg/utils"
+)
+
+// effectiveEndpointServices are the services whose endpoints are resolved in the effective config.
+var effectiveEndpointServices = []string{"ecs", "elb", "vpc", "iam"}
+
+// EffectiveConfig is the configuration in effect after the cloud-config and the loadbalancer-config are read
+// and the defaults are applied. The credentials are redacted, so that it can be logged or served for debugging.
+type EffectiveConfig struct {
+	Global AuthOptions `json:"global"`
+	Vpc    VpcOptions  `json:"vpc"`
+	// Endpoints are the resolved endpoints of the services called by the CCM, by the service name.
+	Endpoints map[string]string `json:"endpoints"`
+
+	LoadBalancerOpts LoadBalancerOptions `json:"loadBalancerOption"`
+	NetworkingOpts   NetworkingOptions   `json:"networkingOption"`
+	MetadataOpts     MetadataOptions     `json:"metadataOption"`
+}
+
+// NewEffectiveConfig returns the effective config of the cloud-config and the loadbalancer-config.
+// The options resolved by the getters, such as "server-list-page-size", are set to the values in effect.
+func NewEffectiveConfig(cc *CloudConfig, lbConfig *LoadbalancerConfig) *EffectiveConfig {
+	global := cc.AuthOpts
+	global.AccessKey = utils.Redact(global.AccessKey)
+	global.SecretKey = utils.Redact(global.SecretKey)
+	global.ServerListPageSize = cc.AuthOpts.GetServerListPageSize()
+	global.ServerListMaxResults = cc.AuthOpts.GetServerListMaxResults()
+	global.ShutoffInstancePolicy = cc.AuthOpts.GetShutoffInstancePolicy()
+	global.DuplicateServerNamePolicy = cc.AuthOpts.GetDuplicateServerNamePolicy()
+	global.ForeignServerPolicy = cc.AuthOpts.GetForeignServerPolicy()
+	global.CredentialRefreshSkew = int(cc.AuthOpts.GetCredentialRefreshSkew().Seconds())
+	global.credentialProvider = nil
+	global.retryPredicate = nil
+
+	vpc := cc.VpcOpts
+	vpc.RouteConcurrency = cc.VpcOpts.GetRouteConcurrency()
+	vpc.RouteBatchSize = cc.VpcOpts.GetRouteBatchSize()
+	vpc.RouteMaxRetries = cc.VpcOpts.GetRouteMaxRetries()
+
+	endpoints := make(map[string]string, len(effectiveEndpointServices))
+	for _, service := range effectiveEndpointServices {
+		endpoints[service] = cc.AuthOpts.GetEndpoint(service)
+	}
+
+	return &EffectiveConfig{
+		Global:           global,
+		Vpc:              vpc,
+		Endpoints:        endpoints,
+		LoadBalancerOpts: lbConfig.LoadBalancerOpts,
+		NetworkingOpts:   lbConfig.NetworkingOpts,
+		MetadataOpts:     lbConfig.MetadataOpts,
+	}
+}
+
+// String returns the effective config in JSON.
+func (e *EffectiveConfig) String() string {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
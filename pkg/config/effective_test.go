@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewEffectiveConfig(t *testing.T) {
+	const (
+		accessKey = "HPUAL1BFQDZ4XKVM7RTE"
+		secretKey = "Xq8fK2mR7pLwZ3nVb6JtYc9HsDuEgA1oWi4kNlQe"
+	)
+	cc, err := ReadConfig(strings.NewReader("[Global]\nregion=ap-southeast-1\naccess-key=" + accessKey +
+		"\nsecret-key=" + secretKey + "\nendpoints=elb=elb.vpcep.example.com\nretry-budget=20\n"))
+	if err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	lbConfig := LoadELBConfig(map[string]string{
+		"loadBalancerOption": `{"lb-algorithm": "SOURCE_IP", "deletion-protection": true}`,
+	})
+
+	effective := NewEffectiveConfig(cc, lbConfig)
+	dump := effective.String()
+	if strings.Contains(dump, accessKey) || strings.Contains(dump, secretKey) {
+		t.Fatalf("expected AK/SK to be redacted, got: %v", dump)
+	}
+	// the AK/SK of the cloud-config are not changed.
+	if cc.AuthOpts.AccessKey != accessKey || cc.AuthOpts.SecretKey != secretKey {
+		t.Fatalf("expected: the AK/SK is kept, got: %v", cc.AuthOpts)
+	}
+
+	rst := EffectiveConfig{}
+	if err = json.Unmarshal([]byte(dump), &rst); err != nil {
+		t.Fatalf("expected: %v, got: %v", nil, err)
+	}
+	checks := []struct {
+		name     string
+		expected any
+		got      any
+	}{
+		{name: "access-key", expected: "***", got: rst.Global.AccessKey},
+		{name: "retry-budget", expected: 20, got: rst.Global.RetryBudget},
+		{name: "retry-budget-refill-ratio", expected: DefaultRetryBudgetRefillRatio, got: rst.Global.RetryBudgetRefillRatio},
+		{name: "server-list-page-size", expected: DefaultServerListPageSize, got: rst.Global.ServerListPageSize},
+		{name: "shutoff-instance-policy", expected: ShutoffInstancePolicyShutdown, got: rst.Global.ShutoffInstancePolicy},
+		{name: "annotation-prefix", expected: DefaultAnnotationPrefix, got: rst.Global.AnnotationPrefix},
+		{name: "private endpoint", expected: "https://elb.vpcep.example.com", got: rst.Endpoints["elb"]},
+		{name: "endpoint", expected: "https://ecs.ap-southeast-1.myhuaweicloud.com", got: rst.Endpoints["ecs"]},
+		{name: "lb-algorithm", expected: "SOURCE_IP", got: rst.LoadBalancerOpts.LBAlgorithm},
+		{name: "deletion-protection", expected: true, got: rst.LoadBalancerOpts.DeletionProtection},
+		{name: "max-concurrent-reconciles", expected: DefaultMaxConcurrentReconciles,
+			got: rst.LoadBalancerOpts.MaxConcurrentReconciles},
+	}
+	for _, c := range checks {
+		if c.got != c.expected {
+			t.Fatalf("expected %s: %v, got: %v", c.name, c.expected, c.got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// privateEndpointTTL is the time that the private endpoints parsed and resolved are cached, so that the endpoints
+// are not parsed and resolved for each API client. It is kept short, so that moving the CCM in or out of the VPC
+// is picked up soon.
+const privateEndpointTTL = 5 * time.Minute
+
+var (
+	// lookupHost resolves the host of the private endpoints, it is replaced in the tests.
+	lookupHost = net.LookupHost
+	// privateEndpoints is shared by all the copies of the AuthOptions, such as the ones of each reconcile.
+	privateEndpoints = newPrivateEndpointCache(privateEndpointTTL)
+)
+
+// privateEndpointCache caches the private endpoints parsed from the value of "endpoints", and whether the hosts
+// of the private endpoints resolve.
+type privateEndpointCache struct {
+	mutex sync.Mutex
+
+	parsed   map[string]*parsedEndpoints
+	resolved map[string]*resolvedHost
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+type parsedEndpoints struct {
+	endpoints map[string]string
+	err       error
+}
+
+type resolvedHost struct {
+	err      error
+	expireAt time.Time
+}
+
+func newPrivateEndpointCache(ttl time.Duration) *privateEndpointCache {
+	return &privateEndpointCache{
+		parsed:   make(map[string]*parsedEndpoints),
+		resolved: make(map[string]*resolvedHost),
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// parse returns the private endpoints of the value of "endpoints", the value is only parsed once.
+func (c *privateEndpointCache) parse(value string) (map[string]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if p, ok := c.parsed[value]; ok {
+		return p.endpoints, p.err
+	}
+	endpoints, err := parseEndpoints(value)
+	c.parsed[value] = &parsedEndpoints{endpoints: endpoints, err: err}
+	return endpoints, err
+}
+
+// resolve returns the error of resolving the host, the host is resolved again once the result expires.
+// The lookup runs without holding the lock, the concurrent lookups of the same host may both resolve it.
+func (c *privateEndpointCache) resolve(host string) error {
+	c.mutex.Lock()
+	r, ok := c.resolved[host]
+	c.mutex.Unlock()
+	if ok && c.now().Before(r.expireAt) {
+		return r.err
+	}
+
+	_, err := lookupHost(host)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.resolved[host] = &resolvedHost{err: err, expireAt: c.now().Add(c.ttl)}
+	return err
+}
+
+// parseEndpoints parses the comma-separated list of the private endpoints in the format of service=endpoint,
+// such as "ecs=ecs.vpcep.example.com,elb=https://elb.vpcep.example.com". The endpoints without a scheme use HTTPS.
+func parseEndpoints(value string) (map[string]string, error) {
+	endpoints := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		service, endpoint, ok := strings.Cut(item, "=")
+		service, endpoint = strings.ToLower(strings.TrimSpace(service)), strings.TrimSpace(endpoint)
+		if !ok || service == "" || endpoint == "" {
+			return nil, fmt.Errorf(`invalid endpoint %q in "endpoints", the format is service=endpoint`, item)
+		}
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf(`invalid endpoint %q of the service %s in "endpoints"`, endpoint, service)
+		}
+		endpoints[service] = strings.TrimSuffix(endpoint, "/")
+	}
+	return endpoints, nil
+}
+
+// getPrivateEndpoint returns the private endpoint of the service in "endpoints", or "" if it is not specified.
+// If "prefer-private-endpoints" is enabled, the private endpoint is only used when its host resolves,
+// the private DNS of the VPC endpoint is not resolvable outside the VPC. The result is cached by privateEndpoints.
+func (a *AuthOptions) getPrivateEndpoint(service string) string {
+	endpoints, err := privateEndpoints.parse(a.Endpoints)
+	if err != nil {
+		klog.Warningf("ignore the private endpoints: %s", err)
+		return ""
+	}
+	endpoint, ok := endpoints[service]
+	if !ok || !a.PreferPrivateEndpoints {
+		return endpoint
+	}
+
+	u, _ := url.Parse(endpoint)
+	if err := privateEndpoints.resolve(u.Hostname()); err != nil {
+		klog.V(4).Infof("the private endpoint %s of %s is not resolvable, use the public endpoint: %s",
+			endpoint, service, err)
+		return ""
+	}
+	return endpoint
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -38,6 +39,25 @@ const (
 	HealthCheckTimeout    = 3
 	HealthCheckMaxRetries = 3
 	HealthCheckDelay      = 5
+
+	DefaultAZRefreshInterval = 600
+
+	DefaultMaxConcurrentReconciles = 20
+
+	DefaultRecreateGracePeriod = 300
+
+	DefaultProvisioningRetries = 3
+
+	DefaultDeleteConcurrency = 5
+
+	DefaultReconcileTimeout = 600
+
+	DefaultExcludeNodeLabel = "node.kubernetes.io/exclude-from-external-load-balancers"
+
+	DefaultControlPlaneNodeLabel = "node-role.kubernetes.io/control-plane"
+
+	DefaultMaxBandwidthSize        = 2000
+	DefaultMaxTrafficBandwidthSize = 300
 )
 
 type LoadbalancerConfig struct {
@@ -47,9 +67,10 @@ type LoadbalancerConfig struct {
 }
 
 type LoadBalancerOptions struct {
-	LBAlgorithm string `json:"lb-algorithm"`
-	LBProvider  string `json:"lb-provider"`
-	KeepEIP     bool   `json:"keep-eip"`
+	LBAlgorithm        string `json:"lb-algorithm"`
+	LBProvider         string `json:"lb-provider"`
+	KeepEIP            bool   `json:"keep-eip"`
+	DeletionProtection bool   `json:"deletion-protection"`
 
 	EnableCrossVpc bool   `json:"enable-cross-vpc"`
 	L4FlavorID     string `json:"l4-flavor-id"`
@@ -67,10 +88,67 @@ type LoadBalancerOptions struct {
 	HealthCheckFlag   string            `json:"health-check-flag"`
 	HealthCheckOption HealthCheckOption `json:"health-check-option"`
 
-	DisableCreateSecurityGroup bool   `json:"disable-create-security-group"`
-	LoadBalancerClass          string `json:"loadbalancer-class"`
-	BusinessName               string `json:"business-name"`
-	PrimaryNic                 string `json:"primary-nic"`
+	EIPAutoCreateOption EIPAutoCreateOption `json:"eip-auto-create-option"`
+
+	// The interval in seconds to refresh the cache of availability zones.
+	AZRefreshInterval int `json:"availability-zone-refresh-interval"`
+
+	// The maximum number of reconciles and instance lookups that run simultaneously, 0 means no limit.
+	MaxConcurrentReconciles int `json:"max-concurrent-reconciles"`
+
+	DisableCreateSecurityGroup bool `json:"disable-create-security-group"`
+	// Only the services with the load balancer class are reconciled if it is set, the services without the class
+	// are left to the other load balancer controllers.
+	LoadBalancerClass string `json:"loadbalancer-class"`
+	BusinessName      string `json:"business-name"`
+	PrimaryNic        string `json:"primary-nic"`
+
+	// The nodes with the label are not added to the backends of the load balancers, empty means no nodes are excluded.
+	ExcludeNodeLabel string `json:"exclude-node-label"`
+	// The control plane nodes, which have the label, are not added to the backends of the load balancers
+	// if ExcludeControlPlaneNodes is true.
+	ExcludeControlPlaneNodes bool   `json:"exclude-control-plane-nodes"`
+	ControlPlaneNodeLabel    string `json:"control-plane-node-label"`
+
+	// The members of the HTTP/HTTPS listeners of the dedicated load balancers are split into pools
+	// by the value of the node label, empty means all the members are in one pool.
+	NodePoolLabel string `json:"node-pool-label"`
+
+	// The weights of the members are in proportion to the allocatable resource of the nodes, "cpu" or "memory",
+	// empty means all the members have equal weights.
+	MemberWeightResource string `json:"member-weight-resource"`
+	// The members on the cordoned nodes are drained by setting their weights to 0, and restored when the nodes
+	// are uncordoned.
+	DrainCordonedNodes bool `json:"drain-cordoned-nodes"`
+
+	// The members of the dedicated load balancers are registered with the subnet looked up by the IP of the nodes,
+	// "ip", or with the subnet of the VPC port of the ECSs of the nodes, "port". Empty means "ip".
+	MemberRegistrationMode string `json:"member-registration-mode"`
+
+	// The dedicated load balancer is replaced by a new one if its VIP subnet is changed, which cannot be updated
+	// in place. The replaced one keeps serving until the new one is ready, and is deleted after the grace period
+	// in seconds.
+	EnableRecreation    bool `json:"enable-recreation"`
+	RecreateGracePeriod int  `json:"recreate-grace-period"`
+
+	// The interval in seconds to re-apply the missing ownership tags to the ELBs and EIPs created for the services,
+	// 0 means the tags are not repaired.
+	OwnershipTagRepairInterval int `json:"ownership-tag-repair-interval"`
+
+	// The times to retry the operations that fail because a resource just created in the ELB instance,
+	// such as a listener, is not ready yet. Each retry waits until the ELB instance is ACTIVE, 0 means no retry.
+	ProvisioningRetries int `json:"provisioning-retries"`
+	// The maximum number of the members, pools or listeners deleted simultaneously when a load balancer is deleted,
+	// 0 means no limit.
+	DeleteConcurrency int `json:"delete-concurrency"`
+	// The maximum seconds that a reconcile of a load balancer takes, the reconcile is retried later
+	// if it does not finish in time, 0 means no timeout.
+	ReconcileTimeout int `json:"reconcile-timeout"`
+
+	// The keys of the labels and annotations of the services that are copied to the tags of the ELB instances,
+	// such as the team or the cost center for the cost allocation.
+	TagServiceLabels      []string `json:"tag-service-labels"`
+	TagServiceAnnotations []string `json:"tag-service-annotations"`
 }
 
 type HealthCheckOption struct {
@@ -82,15 +160,68 @@ type HealthCheckOption struct {
 	Path       string `json:"path"`
 }
 
+// EIPAutoCreateOption is the default options used when automatically creating an EIP for the ELB service,
+// the keys are the same as the ones of the annotation kubernetes.io/elb.eip-auto-create-option.
+type EIPAutoCreateOption struct {
+	BandwidthSize int32  `json:"bandwidth_size"`
+	ShareType     string `json:"share_type"`
+	ChargeMode    string `json:"charge_mode"`
+	IPType        string `json:"ip_type"`
+
+	// AutoCreate creates an EIP with the default options for the services that are not internal and specify
+	// neither the EIP nor the options of the EIP by the annotations.
+	AutoCreate bool `json:"auto_create"`
+
+	// The maximum bandwidth sizes in Mbit/s, which vary depending on the region and the quota of the account.
+	// MaxTrafficBandwidthSize applies when the bandwidth is billed by traffic, MaxBandwidthSize applies otherwise.
+	MaxBandwidthSize        int32 `json:"max_bandwidth_size"`
+	MaxTrafficBandwidthSize int32 `json:"max_traffic_bandwidth_size"`
+}
+
+// MaxBandwidthSizeOf returns the maximum bandwidth size of the charge mode, the bandwidth sizes range
+// from 1 to 2000 Mbit/s, and from 1 to 300 Mbit/s when they are billed by traffic, unless the limits are specified.
+func (e *EIPAutoCreateOption) MaxBandwidthSizeOf(chargeMode string) int32 {
+	if chargeMode == "traffic" {
+		if e.MaxTrafficBandwidthSize > 0 {
+			return e.MaxTrafficBandwidthSize
+		}
+		return DefaultMaxTrafficBandwidthSize
+	}
+	if e.MaxBandwidthSize > 0 {
+		return e.MaxBandwidthSize
+	}
+	return DefaultMaxBandwidthSize
+}
+
 // NetworkingOptions is used for networking settings
 type NetworkingOptions struct {
 	PublicNetworkName   []string `json:"public-network-name"`
 	InternalNetworkName []string `json:"internal-network-name"`
+	// ExternalIPPriority orders the external IPs of the node, each item is a network name or a CIDR,
+	// the IPs matching the earlier items come first.
+	ExternalIPPriority []string `json:"external-ip-priority"`
+	// FloatingIPPools selects the floating IPs reported as the external IPs of the node, each item is a network
+	// name or a CIDR, the floating IPs matching none of them are not reported. Empty means all the floating IPs.
+	FloatingIPPools []string `json:"floating-ip-pools"`
+	// InternalFloatingIPs reclassifies the floating IPs as the internal IPs of the node, such as the private IPs
+	// of a peered VPC, each item is a network name or a CIDR.
+	InternalFloatingIPs []string `json:"internal-floating-ips"`
+	// WarnMissingExternalIP logs a warning for the node without any ExternalIP, the address is never synthesized.
+	WarnMissingExternalIP bool `json:"warn-missing-external-ip"`
+	// ReportAllowedAddressPairs reports the IPs of the allowed address pairs of the interfaces as the internal IPs
+	// of the node, such as the VIPs of keepalived.
+	ReportAllowedAddressPairs bool `json:"report-allowed-address-pairs"`
 }
 
 // MetadataOptions is used for configuring how to talk to metadata service or authConfig drive
 type MetadataOptions struct {
 	SearchOrder string `json:"search-order"`
+	// NodeAddresses reports the addresses of the instance that the CCM runs on from the metadata service,
+	// the ECS API is used if the metadata service does not respond in time.
+	NodeAddresses bool `json:"node-addresses"`
+	// InstanceTypeMapping maps the flavor IDs or names of the ECSs to the normalized instance types of the nodes,
+	// the flavor name is reported if it is not mapped.
+	InstanceTypeMapping map[string]string `json:"instance-type-mapping"`
 }
 
 func NewDefaultELBConfig() *LoadbalancerConfig {
@@ -115,7 +246,7 @@ func LoadElbConfigFromCM() (*LoadbalancerConfig, error) {
 		}
 	}
 
-	klog.Infof("get loadbalancer options: %v", configMap.Data)
+	klog.V(4).Infof("get loadbalancer options: %v", configMap.Data)
 
 	return LoadELBConfig(configMap.Data), nil
 }
@@ -161,6 +292,131 @@ func (l *LoadBalancerOptions) initDefaultValue() {
 		MaxRetries: HealthCheckMaxRetries,
 		Delay:      HealthCheckDelay,
 	}
+	l.AZRefreshInterval = DefaultAZRefreshInterval
+	l.MaxConcurrentReconciles = DefaultMaxConcurrentReconciles
+	l.RecreateGracePeriod = DefaultRecreateGracePeriod
+	l.ProvisioningRetries = DefaultProvisioningRetries
+	l.DeleteConcurrency = DefaultDeleteConcurrency
+	l.ReconcileTimeout = DefaultReconcileTimeout
+	l.ExcludeNodeLabel = DefaultExcludeNodeLabel
+	l.ControlPlaneNodeLabel = DefaultControlPlaneNodeLabel
+	l.EIPAutoCreateOption = EIPAutoCreateOption{
+		ShareType:  "PER",
+		ChargeMode: "traffic",
+	}
+}
+
+// Validate checks the default values of the load balancer options at startup, so that an invalid default fails fast
+// instead of rejecting the reconcile of every service. The values are checked by their ranges and enums,
+// the IDs of the cloud resources, such as the flavors, are not looked up.
+func (c *LoadbalancerConfig) Validate() error {
+	if err := c.LoadBalancerOpts.Validate(); err != nil {
+		return fmt.Errorf("invalid loadBalancerOption: %s", err)
+	}
+	return nil
+}
+
+// Validate checks the ranges and the enum values of the load balancer options.
+func (l *LoadBalancerOptions) Validate() error {
+	if err := validateEnum("lb-algorithm", l.LBAlgorithm, "ROUND_ROBIN", "LEAST_CONNECTIONS", "SOURCE_IP"); err != nil {
+		return err
+	}
+	if err := validateEnum("session-affinity-flag", l.SessionAffinityFlag, "on", "off"); err != nil {
+		return err
+	}
+	if err := validateEnum("session-affinity-option.type", l.SessionAffinityOption.Type.Value(),
+		"SOURCE_IP", "HTTP_COOKIE", "APP_COOKIE"); err != nil {
+		return err
+	}
+	if err := validateEnum("health-check-flag", l.HealthCheckFlag, "on", "off"); err != nil {
+		return err
+	}
+	if err := validateEnum("member-weight-resource", l.MemberWeightResource, "cpu", "memory"); err != nil {
+		return err
+	}
+	if err := validateEnum("member-registration-mode", l.MemberRegistrationMode, "ip", "port"); err != nil {
+		return err
+	}
+
+	checks := []struct {
+		name     string
+		value    int
+		min, max int
+	}{
+		{name: "health-check-option.delay", value: int(l.HealthCheckOption.Delay), min: 1, max: 50},
+		{name: "health-check-option.timeout", value: int(l.HealthCheckOption.Timeout), min: 1, max: 50},
+		{name: "health-check-option.max_retries", value: int(l.HealthCheckOption.MaxRetries), min: 1, max: 10},
+		{name: "idle-timeout", value: l.IdleTimeout, min: 0, max: 4000},
+		// 0 means the timeouts are not specified.
+		{name: "request-timeout", value: l.RequestTimeout, min: 0, max: 300},
+		{name: "response-timeout", value: l.ResponseTimeout, min: 0, max: 300},
+	}
+	for _, c := range checks {
+		if c.value < c.min || c.value > c.max {
+			return fmt.Errorf("%q must be between %d and %d, got: %d", c.name, c.min, c.max, c.value)
+		}
+	}
+	if path := l.HealthCheckOption.Path; path != "" && path[0] != '/' {
+		return fmt.Errorf(`"health-check-option.path" must start with "/", got: %q`, path)
+	}
+
+	// the options are checked in order, so that the same error is reported for the same config.
+	nonNegatives := []struct {
+		name  string
+		value int
+	}{
+		{name: "max-concurrent-reconciles", value: l.MaxConcurrentReconciles},
+		{name: "recreate-grace-period", value: l.RecreateGracePeriod},
+		{name: "ownership-tag-repair-interval", value: l.OwnershipTagRepairInterval},
+		{name: "provisioning-retries", value: l.ProvisioningRetries},
+		{name: "delete-concurrency", value: l.DeleteConcurrency},
+		{name: "reconcile-timeout", value: l.ReconcileTimeout},
+	}
+	for _, c := range nonNegatives {
+		if c.value < 0 {
+			return fmt.Errorf("%q must not be negative, got: %d", c.name, c.value)
+		}
+	}
+
+	return l.EIPAutoCreateOption.validate()
+}
+
+// validate checks the default options of the EIPs, the bandwidth size is only checked if it is specified.
+func (e *EIPAutoCreateOption) validate() error {
+	if err := validateEnum("eip-auto-create-option.share_type", e.ShareType, "PER", "WHOLE"); err != nil {
+		return err
+	}
+	if err := validateEnum("eip-auto-create-option.charge_mode", e.ChargeMode, "traffic", "bandwidth"); err != nil {
+		return err
+	}
+	if e.MaxBandwidthSize < 0 || e.MaxTrafficBandwidthSize < 0 {
+		return fmt.Errorf(`"eip-auto-create-option.max_bandwidth_size" and "max_traffic_bandwidth_size" `+
+			"must not be negative, got: %d and %d", e.MaxBandwidthSize, e.MaxTrafficBandwidthSize)
+	}
+	maxSize := e.MaxBandwidthSizeOf(e.ChargeMode)
+	if e.BandwidthSize < 0 || e.BandwidthSize > maxSize {
+		return fmt.Errorf(`"eip-auto-create-option.bandwidth_size" must be between 0 and %d when charge_mode is %s, `+
+			"0 means not specified, got: %d", maxSize, e.ChargeMode, e.BandwidthSize)
+	}
+	// The EIPs created without the annotation have no shared bandwidth to use, they need the bandwidth size.
+	if e.AutoCreate && (e.ShareType == "WHOLE" || e.BandwidthSize == 0) {
+		return fmt.Errorf(`"eip-auto-create-option.bandwidth_size" is required and "share_type" must be PER `+
+			`when "auto_create" is true, got: %d and %s`, e.BandwidthSize, e.ShareType)
+	}
+	return nil
+}
+
+// validateEnum returns an error if the value is not empty and not one of the valid values.
+func validateEnum(name, value string, valid ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, v := range valid {
+		if value == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported %q %q, supported values are %s", name, value, strings.Join(valid, ", "))
 }
 
 func (m *MetadataOptions) initDefaultValue() {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -96,3 +97,194 @@ func TestLoadELBConfigBasic(t *testing.T) {
 		t.Fatalf("SearchOrder, expected: %v, got: %v", searchOrder, cfg.MetadataOpts.SearchOrder)
 	}
 }
+
+func TestLoadELBConfigEIPAutoCreateOption(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]string
+		expected EIPAutoCreateOption
+	}{
+		{
+			name:     "default",
+			data:     map[string]string{},
+			expected: EIPAutoCreateOption{ShareType: "PER", ChargeMode: "traffic"},
+		},
+		{
+			name: "partially specified",
+			data: map[string]string{
+				"loadBalancerOption": `{"eip-auto-create-option": {"bandwidth_size": 10, "ip_type": "5_sbgp"}}`,
+			},
+			expected: EIPAutoCreateOption{BandwidthSize: 10, ShareType: "PER", ChargeMode: "traffic", IPType: "5_sbgp"},
+		},
+		{
+			name: "fully specified",
+			data: map[string]string{
+				"loadBalancerOption": `{"eip-auto-create-option": {"bandwidth_size": 100, "share_type": "PER", ` +
+					`"charge_mode": "bandwidth", "ip_type": "5_bgp"}}`,
+			},
+			expected: EIPAutoCreateOption{BandwidthSize: 100, ShareType: "PER", ChargeMode: "bandwidth", IPType: "5_bgp"},
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg := LoadELBConfig(te.data)
+			if cfg.LoadBalancerOpts.EIPAutoCreateOption != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, cfg.LoadBalancerOpts.EIPAutoCreateOption)
+			}
+		})
+	}
+}
+
+func TestLoadELBConfigExcludeNodeLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]string
+		expected string
+	}{
+		{
+			name:     "default",
+			data:     map[string]string{},
+			expected: DefaultExcludeNodeLabel,
+		},
+		{
+			name:     "custom",
+			data:     map[string]string{"loadBalancerOption": `{"exclude-node-label": "example.com/ingress-only"}`},
+			expected: "example.com/ingress-only",
+		},
+		{
+			name:     "disabled",
+			data:     map[string]string{"loadBalancerOption": `{"exclude-node-label": ""}`},
+			expected: "",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			cfg := LoadELBConfig(te.data)
+			if cfg.LoadBalancerOpts.ExcludeNodeLabel != te.expected {
+				t.Fatalf("expected: %v, got: %v", te.expected, cfg.LoadBalancerOpts.ExcludeNodeLabel)
+			}
+		})
+	}
+}
+
+func TestLoadbalancerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			data: map[string]string{},
+		},
+		{
+			name: "valid",
+			data: map[string]string{"loadBalancerOption": `{
+				"lb-algorithm": "LEAST_CONNECTIONS",
+				"session-affinity-flag": "on",
+				"session-affinity-option": {"type": "SOURCE_IP", "persistence_timeout": 15},
+				"health-check-flag": "off",
+				"health-check-option": {"delay": 50, "timeout": 1, "max_retries": 10, "path": "/healthz"},
+				"idle-timeout": 4000,
+				"request-timeout": 300,
+				"member-weight-resource": "cpu",
+				"eip-auto-create-option": {"bandwidth_size": 2000, "share_type": "PER", "charge_mode": "bandwidth"}
+			}`},
+		},
+		{
+			name:    "unknown algorithm",
+			data:    map[string]string{"loadBalancerOption": `{"lb-algorithm": "RANDOM"}`},
+			wantErr: "lb-algorithm",
+		},
+		{
+			name:    "unknown session affinity type",
+			data:    map[string]string{"loadBalancerOption": `{"session-affinity-option": {"type": "COOKIE"}}`},
+			wantErr: "session-affinity-option.type",
+		},
+		{
+			name:    "health check delay out of range",
+			data:    map[string]string{"loadBalancerOption": `{"health-check-option": {"delay": 60}}`},
+			wantErr: "health-check-option.delay",
+		},
+		{
+			name:    "health check path",
+			data:    map[string]string{"loadBalancerOption": `{"health-check-option": {"path": "healthz"}}`},
+			wantErr: "health-check-option.path",
+		},
+		{
+			name:    "request timeout out of range",
+			data:    map[string]string{"loadBalancerOption": `{"request-timeout": 301}`},
+			wantErr: "request-timeout",
+		},
+		{
+			name:    "negative grace period",
+			data:    map[string]string{"loadBalancerOption": `{"recreate-grace-period": -1}`},
+			wantErr: "recreate-grace-period",
+		},
+		{
+			name: "multiple negative values",
+			data: map[string]string{"loadBalancerOption": `{
+				"reconcile-timeout": -1,
+				"delete-concurrency": -1,
+				"max-concurrent-reconciles": -1
+			}`},
+			wantErr: `"max-concurrent-reconciles" must not be negative`,
+		},
+		{
+			name:    "unknown member weight resource",
+			data:    map[string]string{"loadBalancerOption": `{"member-weight-resource": "gpu"}`},
+			wantErr: "member-weight-resource",
+		},
+		{
+			name: "bandwidth billed by traffic",
+			data: map[string]string{"loadBalancerOption": `{
+				"eip-auto-create-option": {"bandwidth_size": 500, "charge_mode": "traffic"}
+			}`},
+			wantErr: "eip-auto-create-option.bandwidth_size\" must be between 0 and 300",
+		},
+		{
+			name: "bandwidth within the configured limit",
+			data: map[string]string{"loadBalancerOption": `{
+				"eip-auto-create-option": {"bandwidth_size": 500, "charge_mode": "traffic", "max_traffic_bandwidth_size": 1000}
+			}`},
+		},
+		{
+			name: "bandwidth exceeds the configured limit",
+			data: map[string]string{"loadBalancerOption": `{
+				"eip-auto-create-option": {"bandwidth_size": 1500, "charge_mode": "bandwidth", "max_bandwidth_size": 1000}
+			}`},
+			wantErr: "eip-auto-create-option.bandwidth_size",
+		},
+		{
+			name: "negative limit",
+			data: map[string]string{"loadBalancerOption": `{
+				"eip-auto-create-option": {"max_bandwidth_size": -1}
+			}`},
+			wantErr: "eip-auto-create-option.max_bandwidth_size",
+		},
+		{
+			name: "auto create without bandwidth size",
+			data: map[string]string{"loadBalancerOption": `{
+				"eip-auto-create-option": {"auto_create": true}
+			}`},
+			wantErr: "eip-auto-create-option.bandwidth_size",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			err := LoadELBConfig(te.data).Validate()
+			if te.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected: nil, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), te.wantErr) {
+				t.Fatalf("expected an error of %v, got: %v", te.wantErr, err)
+			}
+		})
+	}
+}
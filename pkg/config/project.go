@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/basic"
+	sdkconfig "github.com/huaweicloud/huaweicloud-sdk-go-v3/core/config"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/impl"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/request"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/sdkerr"
+	"k8s.io/klog/v2"
+)
+
+const keystoneListProjectsURI = "/v3/projects"
+
+// projectIDs caches the discovered project IDs, keyed by the IAM endpoint, the access key and the region.
+var projectIDs sync.Map
+
+type keystoneListProjectsResponse struct {
+	Projects []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"projects"`
+}
+
+// GetProjectID returns the project ID of the region. If "project-id" is not specified,
+// it is discovered from IAM with the AK/SK and cached, the explicit "project-id" always wins.
+func (a *AuthOptions) GetProjectID() (string, error) {
+	return a.getProjectID(newHTTPConfig(a.correlationID))
+}
+
+func (a *AuthOptions) getProjectID(httpConfig *sdkconfig.HttpConfig) (string, error) {
+	if a.ProjectID != "" {
+		return a.ProjectID, nil
+	}
+
+	ak, sk, token, err := a.GetCredentialProvider().GetCredentials(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("failed to get credentials: %s", err)
+	}
+	if ak == "" || sk == "" {
+		return "", fmt.Errorf("failed to get credentials: the access key or secret key is empty")
+	}
+	return a.discoverProjectID(ak, sk, token, httpConfig)
+}
+
+// discoverProjectID returns the cached project ID of the region, or lists it from IAM with the AK/SK.
+func (a *AuthOptions) discoverProjectID(ak, sk, token string, httpConfig *sdkconfig.HttpConfig) (string, error) {
+	iamEndpoint := a.GetEndpoint("iam")
+	key := fmt.Sprintf("%s/%s/%s", iamEndpoint, ak, a.Region)
+	if id, ok := projectIDs.Load(key); ok {
+		return id.(string), nil
+	}
+
+	id, err := listProjectID(iamEndpoint, a.Region, ak, sk, token, httpConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to discover the project ID of region %s, "+
+			"please specify \"project-id\" manually: %s", a.Region, err)
+	}
+	klog.Infof("discovered the project ID of region %s: %s", a.Region, id)
+	projectIDs.Store(key, id)
+	return id, nil
+}
+
+// listProjectID lists the projects named after the region with the keystone API of IAM.
+func listProjectID(iamEndpoint, region, ak, sk, token string, httpConfig *sdkconfig.HttpConfig) (string, error) {
+	credentials := basic.NewCredentialsBuilder().
+		WithAk(ak).
+		WithSk(sk).
+		WithSecurityToken(token).
+		Build()
+
+	req := request.NewHttpRequestBuilder().
+		WithEndpoint(iamEndpoint).
+		WithPath(keystoneListProjectsURI).
+		WithMethod("GET").
+		AddQueryParam("name", reflect.ValueOf(region)).
+		Build()
+
+	client := impl.NewDefaultHttpClient(httpConfig)
+	req, err := credentials.ProcessAuthRequest(client, req)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.SyncInvokeHttp(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Response.Body.Close()
+	if resp.GetStatusCode() >= 400 {
+		return "", sdkerr.NewServiceResponseError(resp.Response)
+	}
+
+	data, err := io.ReadAll(resp.Response.Body)
+	if err != nil {
+		return "", err
+	}
+	projects := &keystoneListProjectsResponse{}
+	if err = json.Unmarshal(data, projects); err != nil {
+		return "", err
+	}
+
+	switch len(projects.Projects) {
+	case 0:
+		return "", fmt.Errorf("no project found")
+	case 1:
+		return projects.Projects[0].ID, nil
+	default:
+		return "", fmt.Errorf("%d projects found", len(projects.Projects))
+	}
+}
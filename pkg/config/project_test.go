@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newFakeIAMServer serves the keystone API to list the projects, the project of each region is named after the region.
+func newFakeIAMServer(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if r.URL.Path != keystoneListProjectsURI {
+			t.Errorf("expected: %v, got: %v", keystoneListProjectsURI, r.URL.Path)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Access=DISCOVERYACCESSKEY") {
+			t.Errorf("expected: signed with the AK, got: %v", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		name := r.URL.Query().Get("name")
+		if name == "unknown-region" {
+			_, _ = w.Write([]byte(`{"projects": []}`))
+			return
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"projects": [{"id": "project-of-%s", "name": "%s"}]}`, name, name)))
+	}))
+}
+
+func TestGetProjectIDDiscovery(t *testing.T) {
+	var calls int32
+	server := newFakeIAMServer(t, &calls)
+	defer server.Close()
+
+	opts := &AuthOptions{
+		Region:    "ap-southeast-1",
+		AccessKey: "DISCOVERYACCESSKEY",
+		SecretKey: "discovery-secret-key",
+	}
+	for i := 0; i < 2; i++ {
+		id, err := opts.getProjectID(newTestHTTPConfig(server))
+		if err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+		if id != "project-of-ap-southeast-1" {
+			t.Fatalf("expected: %v, got: %v", "project-of-ap-southeast-1", id)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected: the project ID is cached, got: %v calls", calls)
+	}
+
+	credentials, err := opts.getCredentials(newTestHTTPConfig(server))
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if credentials.ProjectId != "project-of-ap-southeast-1" {
+		t.Fatalf("expected: %v, got: %v", "project-of-ap-southeast-1", credentials.ProjectId)
+	}
+	if calls != 1 {
+		t.Fatalf("expected: the project ID is cached, got: %v calls", calls)
+	}
+}
+
+func TestGetProjectIDExplicit(t *testing.T) {
+	var calls int32
+	server := newFakeIAMServer(t, &calls)
+	defer server.Close()
+
+	opts := &AuthOptions{
+		Region:    "ap-southeast-2",
+		AccessKey: "DISCOVERYACCESSKEY",
+		SecretKey: "discovery-secret-key",
+		ProjectID: "explicit-project-id",
+	}
+	id, err := opts.getProjectID(newTestHTTPConfig(server))
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if id != "explicit-project-id" {
+		t.Fatalf("expected: %v, got: %v", "explicit-project-id", id)
+	}
+
+	credentials, err := opts.getCredentials(newTestHTTPConfig(server))
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if credentials.ProjectId != "explicit-project-id" {
+		t.Fatalf("expected: %v, got: %v", "explicit-project-id", credentials.ProjectId)
+	}
+	if calls != 0 {
+		t.Fatalf("expected: IAM is not called, got: %v calls", calls)
+	}
+}
+
+func TestGetProjectIDNotFound(t *testing.T) {
+	var calls int32
+	server := newFakeIAMServer(t, &calls)
+	defer server.Close()
+
+	opts := &AuthOptions{
+		Region:    "unknown-region",
+		AccessKey: "DISCOVERYACCESSKEY",
+		SecretKey: "discovery-secret-key",
+	}
+	if _, err := opts.getProjectID(newTestHTTPConfig(server)); err == nil {
+		t.Fatalf("expected: error for no project found, got: nil")
+	}
+	if _, err := opts.getCredentials(newTestHTTPConfig(server)); err == nil {
+		t.Fatalf("expected: error for no project found, got: nil")
+	}
+}
@@ -154,7 +154,7 @@ func RedactHeaders(headers http.Header) (processedHeaders []string) {
 	var redactheaders = []string{"x-auth-token", "x-auth-key", "x-service-token",
 		"x-storage-token", "x-account-meta-temp-url-key", "x-account-meta-temp-url-key-2",
 		"x-container-meta-temp-url-key", "x-container-meta-temp-url-key-2", "set-cookie",
-		"x-subject-token", "authorization"}
+		"x-subject-token", "x-security-token", "authorization"}
 
 	for name, header := range headers {
 		for _, v := range header {
@@ -17,6 +17,7 @@ limitations under the License.
 package metadata
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,15 +26,36 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/exec"
 	"k8s.io/utils/mount"
 )
 
 const (
-	defaultMetadataVersion = "latest"
-	metadataURLTemplate    = "http://169.254.169.254/openstack/%s/meta_data.json"
+	// DefaultBaseURL and DefaultVersion match the metadata service of the public cloud.
+	DefaultBaseURL = "http://169.254.169.254"
+	DefaultVersion = "latest"
+	// DefaultTimeout bounds the probing of the metadata service, a slow metadata service should not block the callers.
+	DefaultTimeout = 3 * time.Second
+
+	// FormatOpenStack is the OpenStack network_data.json format, the addresses are listed in the networks.
+	FormatOpenStack = "openstack"
+	// FormatHCS is the Huawei Cloud Stack variant, the addresses are listed in the fixed_ips of the links.
+	FormatHCS = "hcs"
+
+	metadataPathTemplate    = "openstack/%s/meta_data.json"
+	networkDataPathTemplate = "openstack/%s/network_data.json"
+
+	// tokenPath serves the session tokens of the token-protected metadata service, the token is acquired by a PUT
+	// with the TTL header, and sent with the token header on the reads of the documents.
+	tokenPath       = "meta-data/latest/api/token"
+	tokenTTLHeader  = "X-Metadata-Token-Ttl-Seconds"
+	tokenHeader     = "X-Metadata-Token"
+	tokenTTLSeconds = "300"
 
 	// MetadataID is used as an identifier on the metadata search order configuration.
 	MetadataID = "metadataService"
@@ -48,6 +70,48 @@ const (
 	ConfigDriveID = "configDrive"
 )
 
+// knownVersions are tried in order if the configured version is not served by the metadata service.
+var knownVersions = []string{DefaultVersion, "2018-08-27", "2017-02-22", "2015-10-15"}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Options specifies where to fetch the metadata and how to parse the documents.
+type Options struct {
+	BaseURL string
+	Version string
+	Format  string
+	// Timeout bounds fetching the documents, including the retries of the versions.
+	Timeout time.Duration
+}
+
+func (o Options) baseURL() string {
+	if o.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(o.BaseURL, "/")
+}
+
+func (o Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// versions returns the configured version followed by the known versions as a fallback.
+func (o Options) versions() []string {
+	versions := make([]string, 0, len(knownVersions)+1)
+	if o.Version != "" {
+		versions = append(versions, o.Version)
+	}
+	for _, v := range knownVersions {
+		if v != o.Version {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
 // ErrBadMetadata is used to indicate a problem parsing data from metadata server
 var ErrBadMetadata = errors.New("invalid HuaweiCloud metadata, got empty uuid")
 
@@ -86,10 +150,6 @@ func parseMetadata(r io.Reader) (*Metadata, error) {
 	return &metadata, nil
 }
 
-func getMetadataURL(metadataVersion string) string {
-	return fmt.Sprintf(metadataURLTemplate, metadataVersion)
-}
-
 func getConfigDrivePath(metadataVersion string) string {
 	return fmt.Sprintf(configDrivePathTemplate, metadataVersion)
 }
@@ -158,27 +218,99 @@ func getBaseMounter() *mount.SafeFormatAndMount {
 	}
 }
 
-func getFromMetadataService(metadataVersion string) (*Metadata, error) {
-	// Try to get JSON from metadata server.
-	url := getMetadataURL(metadataVersion)
+// errDocumentNotFound is returned if the document is not served under the version, the next version is tried.
+var errDocumentNotFound = errors.New("metadata document not found")
+
+// getToken acquires a session token from the token-protected metadata service, it returns an empty token
+// if the token endpoint is not available, then the documents are read without the token.
+func getToken(ctx context.Context, opts Options) string {
+	url := fmt.Sprintf("%s/%s", opts.baseURL(), tokenPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, nil)
+	if err != nil {
+		klog.V(4).Infof("failed to build the token request, read the metadata without the token: %s", err)
+		return ""
+	}
+	req.Header.Set(tokenTTLHeader, tokenTTLSeconds)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		klog.V(4).Infof("failed to acquire the metadata token, read the metadata without the token: %s", err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		klog.V(4).Infof("the metadata token is not available from %s: %s, read the metadata without the token",
+			url, resp.Status)
+		return ""
+	}
+	token, err := io.ReadAll(resp.Body)
+	if err != nil {
+		klog.V(4).Infof("failed to read the metadata token, read the metadata without the token: %s", err)
+		return ""
+	}
+	return strings.TrimSpace(string(token))
+}
+
+// fetchDocument fetches the document from the metadata service, the versions are tried in order,
+// and the first one served is used. The token is sent if it is not empty.
+func fetchDocument(ctx context.Context, opts Options, token, pathTemplate string, parse func(io.Reader) error) error {
+	for _, version := range opts.versions() {
+		url := fmt.Sprintf("%s/%s", opts.baseURL(), fmt.Sprintf(pathTemplate, version))
+		err := fetchURL(ctx, url, token, parse)
+		if errors.Is(err, errDocumentNotFound) {
+			klog.V(4).Infof("%s is not served, try the next version", url)
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("none of the versions %v is served by the metadata service %s", opts.versions(), opts.baseURL())
+}
+
+func fetchURL(ctx context.Context, url, token string, parse func(io.Reader) error) error {
 	klog.V(4).Infof("Attempting to fetch metadata from %s", url)
-	resp, err := http.Get(url) //nolint: gosec
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching %s: %v", url, err)
+		return err
+	}
+	if token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %v", url, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return errDocumentNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("unexpected status code when reading metadata from %s: %s", url, resp.Status)
-		return nil, err
+		return fmt.Errorf("unexpected status code when reading metadata from %s: %s", url, resp.Status)
 	}
 
-	return parseMetadata(resp.Body)
+	return parse(resp.Body)
+}
+
+func getFromMetadataService(opts Options) (*Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout())
+	defer cancel()
+	return getMetadataWithContext(ctx, opts, getToken(ctx, opts))
+}
+
+func getMetadataWithContext(ctx context.Context, opts Options, token string) (*Metadata, error) {
+	var md *Metadata
+	err := fetchDocument(ctx, opts, token, metadataPathTemplate, func(r io.Reader) error {
+		var err error
+		md, err = parseMetadata(r)
+		return err
+	})
+	return md, err
 }
 
 // Get retrieves metadata from either config drive or metadata service.
 // Search order depends on the order set in config file.
-func Get(order string) (*Metadata, error) {
+func Get(order string, opts Options) (*Metadata, error) {
 	if metadataCache == nil {
 		var md *Metadata
 		var err error
@@ -188,9 +320,9 @@ func Get(order string) (*Metadata, error) {
 			id = strings.TrimSpace(id)
 			switch id {
 			case ConfigDriveID:
-				md, err = getFromConfigDrive(defaultMetadataVersion)
+				md, err = getFromConfigDrive(opts.versions()[0])
 			case MetadataID:
-				md, err = getFromMetadataService(defaultMetadataVersion)
+				md, err = getFromMetadataService(opts)
 			default:
 				err = fmt.Errorf("%s is not a valid metadata search order option. Supported options are %s and %s", id, ConfigDriveID, MetadataID)
 			}
@@ -207,3 +339,45 @@ func Get(order string) (*Metadata, error) {
 	}
 	return metadataCache, nil
 }
+
+// InstanceData has the documents of the instance fetched from the metadata service,
+// the document that fails to be fetched is nil.
+type InstanceData struct {
+	Metadata    *Metadata
+	NetworkData *NetworkData
+}
+
+// GetInstanceData fetches the meta_data.json and network_data.json concurrently within the timeout of the options.
+// The documents fetched are returned even if the other one fails, an error is returned only if both fail.
+// One session token is shared by both of the documents if the metadata service is token-protected.
+func GetInstanceData(opts Options) (*InstanceData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout())
+	defer cancel()
+
+	token := getToken(ctx, opts)
+	data := &InstanceData{}
+	var mdErr, ndErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		data.Metadata, mdErr = getMetadataWithContext(ctx, opts, token)
+	}()
+	go func() {
+		defer wg.Done()
+		data.NetworkData, ndErr = getNetworkDataWithContext(ctx, opts, token)
+	}()
+	wg.Wait()
+
+	if mdErr != nil && ndErr != nil {
+		return nil, fmt.Errorf("failed to fetch the instance data from %s within %v: %s",
+			opts.baseURL(), opts.timeout(), utilerrors.NewAggregate([]error{mdErr, ndErr}))
+	}
+	if mdErr != nil {
+		klog.Warningf("only the network data is fetched from the metadata service: %s", mdErr)
+	}
+	if ndErr != nil {
+		klog.Warningf("only the metadata is fetched from the metadata service: %s", ndErr)
+	}
+	return data, nil
+}
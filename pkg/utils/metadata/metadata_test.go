@@ -17,8 +17,13 @@ limitations under the License.
 package metadata
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseMetadata(t *testing.T) {
@@ -56,3 +61,164 @@ func TestParseMetadata(t *testing.T) {
 		t.Errorf("incorrect region: %s", md.AvailabilityZone)
 	}
 }
+
+const testMetadata = `{"uuid": "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2", "name": "k8s-a01"}`
+
+// newSlowMetadataServer serves the documents keyed by the path, the documents in slow are served after the delay.
+func newSlowMetadataServer(documents map[string]string, slow map[string]bool, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow[r.URL.Path] {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		doc, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	}))
+}
+
+func TestGetInstanceData(t *testing.T) {
+	documents := map[string]string{
+		"/openstack/latest/meta_data.json":    testMetadata,
+		"/openstack/latest/network_data.json": openstackNetworkData,
+	}
+
+	tests := []struct {
+		name        string
+		slow        map[string]bool
+		expectErr   bool
+		metadata    bool
+		networkData bool
+	}{
+		{
+			name:        "both fetched",
+			metadata:    true,
+			networkData: true,
+		},
+		{
+			name:        "metadata timeout",
+			slow:        map[string]bool{"/openstack/latest/meta_data.json": true},
+			networkData: true,
+		},
+		{
+			name: "total timeout",
+			slow: map[string]bool{
+				"/openstack/latest/meta_data.json":    true,
+				"/openstack/latest/network_data.json": true,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			server := newSlowMetadataServer(documents, te.slow, 5*time.Second)
+			defer server.Close()
+
+			start := time.Now()
+			data, err := GetInstanceData(Options{BaseURL: server.URL, Version: DefaultVersion,
+				Timeout: 200 * time.Millisecond})
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("expected: return within the timeout, got: %v", elapsed)
+			}
+			if te.expectErr {
+				if err == nil {
+					t.Fatalf("expected: error, got: nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if (data.Metadata != nil) != te.metadata {
+				t.Fatalf("expected metadata: %v, got: %v", te.metadata, data.Metadata)
+			}
+			if (data.NetworkData != nil) != te.networkData {
+				t.Fatalf("expected network data: %v, got: %v", te.networkData, data.NetworkData)
+			}
+		})
+	}
+}
+
+// newTokenMetadataServer serves the documents keyed by the path, the documents are only served with the token
+// if the server is token-protected, otherwise the token endpoint is not available.
+func newTokenMetadataServer(documents map[string]string, protected bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+tokenPath {
+			if !protected {
+				http.NotFound(w, r)
+				return
+			}
+			if r.Method != http.MethodPut || r.Header.Get(tokenTTLHeader) == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte("test-token\n"))
+			return
+		}
+		if protected && r.Header.Get(tokenHeader) != "test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		doc, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	}))
+}
+
+func TestGetInstanceDataWithToken(t *testing.T) {
+	documents := map[string]string{
+		"/openstack/latest/meta_data.json":    testMetadata,
+		"/openstack/latest/network_data.json": openstackNetworkData,
+	}
+
+	tests := []struct {
+		name      string
+		protected bool
+	}{
+		{name: "token required", protected: true},
+		{name: "tokenless", protected: false},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			server := newTokenMetadataServer(documents, te.protected)
+			defer server.Close()
+			opts := Options{BaseURL: server.URL, Version: DefaultVersion, Timeout: time.Second}
+
+			data, err := GetInstanceData(opts)
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if data.Metadata == nil || data.NetworkData == nil {
+				t.Fatalf("expected: both documents, got: %v, %v", data.Metadata, data.NetworkData)
+			}
+
+			md, err := getFromMetadataService(opts)
+			if err != nil || md.Name != "k8s-a01" {
+				t.Fatalf("expected: k8s-a01, got: %v, %v", md, err)
+			}
+		})
+	}
+}
+
+func TestGetInstanceDataTokenRejected(t *testing.T) {
+	server := newTokenMetadataServer(map[string]string{"/openstack/latest/meta_data.json": testMetadata}, true)
+	defer server.Close()
+
+	// the documents are not served without the token.
+	err := fetchDocument(context.TODO(), Options{BaseURL: server.URL}, "", metadataPathTemplate,
+		func(io.Reader) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected: 401 error, got: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// NetworkData has the network information fetched from the network_data.json of metadata service.
+type NetworkData struct {
+	Links    []NetworkLink `json:"links"`
+	Networks []Network     `json:"networks"`
+}
+
+// NetworkLink is a network interface of the instance.
+type NetworkLink struct {
+	ID                 string    `json:"id"`
+	Type               string    `json:"type"`
+	EthernetMACAddress string    `json:"ethernet_mac_address"`
+	FixedIPs           []FixedIP `json:"fixed_ips"`
+}
+
+// FixedIP is an address of the network interface, only used by the Huawei Cloud Stack variant.
+type FixedIP struct {
+	IPAddress string `json:"ip_address"`
+	SubnetID  string `json:"subnet_id"`
+}
+
+// Network is an address configured on a link.
+type Network struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Link      string `json:"link"`
+	IPAddress string `json:"ip_address"`
+	Netmask   string `json:"netmask"`
+	NetworkID string `json:"network_id"`
+}
+
+// IPAddresses returns the addresses of the instance, in the order of the document.
+func (n *NetworkData) IPAddresses() []string {
+	addresses := make([]string, 0)
+	for _, network := range n.Networks {
+		if network.IPAddress != "" {
+			addresses = append(addresses, network.IPAddress)
+		}
+	}
+	for _, link := range n.Links {
+		for _, ip := range link.FixedIPs {
+			if ip.IPAddress != "" {
+				addresses = append(addresses, ip.IPAddress)
+			}
+		}
+	}
+	return addresses
+}
+
+// parseNetworkData reads the network_data.json in the specified format.
+func parseNetworkData(r io.Reader, format string) (*NetworkData, error) {
+	if format == "" {
+		format = FormatOpenStack
+	}
+	if format != FormatOpenStack && format != FormatHCS {
+		return nil, fmt.Errorf("unsupported metadata format %q, supported formats are %s and %s",
+			format, FormatOpenStack, FormatHCS)
+	}
+
+	var data NetworkData
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	// The HCS variant lists the addresses in the links, the networks only describe how they are assigned.
+	if format == FormatOpenStack {
+		for i := range data.Links {
+			data.Links[i].FixedIPs = nil
+		}
+	} else {
+		for i := range data.Networks {
+			data.Networks[i].IPAddress = ""
+		}
+	}
+
+	if len(data.IPAddresses()) == 0 {
+		return nil, fmt.Errorf("invalid network data in %s format, got no IP address", format)
+	}
+	return &data, nil
+}
+
+// GetNetworkData retrieves the network data from metadata service,
+// the known versions are tried if the configured version is not served.
+func GetNetworkData(opts Options) (*NetworkData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout())
+	defer cancel()
+	return getNetworkDataWithContext(ctx, opts, getToken(ctx, opts))
+}
+
+func getNetworkDataWithContext(ctx context.Context, opts Options, token string) (*NetworkData, error) {
+	var data *NetworkData
+	err := fetchDocument(ctx, opts, token, networkDataPathTemplate, func(r io.Reader) error {
+		var err error
+		data, err = parseNetworkData(r, opts.Format)
+		return err
+	})
+	return data, err
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const openstackNetworkData = `
+{
+  "links": [
+    {"id": "tap0", "type": "phy", "ethernet_mac_address": "fa:16:3e:00:00:01"},
+    {"id": "tap1", "type": "phy", "ethernet_mac_address": "fa:16:3e:00:00:02"}
+  ],
+  "networks": [
+    {"id": "network0", "type": "ipv4", "link": "tap0", "ip_address": "192.168.0.10", "netmask": "255.255.255.0"},
+    {"id": "network1", "type": "ipv4", "link": "tap1", "ip_address": "192.168.1.10", "netmask": "255.255.255.0"}
+  ]
+}
+`
+
+const hcsNetworkData = `
+{
+  "links": [
+    {
+      "id": "tap0",
+      "type": "phy",
+      "ethernet_mac_address": "fa:16:3e:00:00:01",
+      "fixed_ips": [{"ip_address": "10.0.0.10", "subnet_id": "subnet-0"}]
+    }
+  ],
+  "networks": [
+    {"id": "network0", "type": "ipv4_dhcp", "link": "tap0"}
+  ]
+}
+`
+
+// newMetadataServer serves the documents keyed by the path, the other paths are not found.
+func newMetadataServer(documents map[string]string) (*httptest.Server, *[]string) {
+	requested := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the requests of the session token are not the reads of the documents.
+		if r.Method == http.MethodGet {
+			requested = append(requested, r.URL.Path)
+		}
+		doc, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	}))
+	return server, &requested
+}
+
+func TestParseNetworkData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		format   string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "openstack",
+			data:     openstackNetworkData,
+			format:   FormatOpenStack,
+			expected: []string{"192.168.0.10", "192.168.1.10"},
+		},
+		{
+			name:     "default format",
+			data:     openstackNetworkData,
+			format:   "",
+			expected: []string{"192.168.0.10", "192.168.1.10"},
+		},
+		{
+			name:     "hcs",
+			data:     hcsNetworkData,
+			format:   FormatHCS,
+			expected: []string{"10.0.0.10"},
+		},
+		{
+			name:    "hcs document in openstack format",
+			data:    hcsNetworkData,
+			format:  FormatOpenStack,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			data:    openstackNetworkData,
+			format:  "ec2",
+			wantErr: true,
+		},
+		{
+			name:    "bogus",
+			data:    "bogus",
+			format:  FormatOpenStack,
+			wantErr: true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			data, err := parseNetworkData(strings.NewReader(te.data), te.format)
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := data.IPAddresses(); !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetNetworkData(t *testing.T) {
+	tests := []struct {
+		name      string
+		documents map[string]string
+		opts      Options
+		expected  []string
+		requested []string
+		wantErr   bool
+	}{
+		{
+			name: "openstack latest",
+			documents: map[string]string{
+				"/openstack/latest/network_data.json": openstackNetworkData,
+			},
+			opts:      Options{Version: DefaultVersion, Format: FormatOpenStack},
+			expected:  []string{"192.168.0.10", "192.168.1.10"},
+			requested: []string{"/openstack/latest/network_data.json"},
+		},
+		{
+			name: "configured version",
+			documents: map[string]string{
+				"/openstack/2016-06-30/network_data.json": openstackNetworkData,
+			},
+			opts:      Options{Version: "2016-06-30", Format: FormatOpenStack},
+			expected:  []string{"192.168.0.10", "192.168.1.10"},
+			requested: []string{"/openstack/2016-06-30/network_data.json"},
+		},
+		{
+			name: "fallback to known version",
+			documents: map[string]string{
+				"/openstack/2017-02-22/network_data.json": hcsNetworkData,
+			},
+			opts:     Options{Version: DefaultVersion, Format: FormatHCS},
+			expected: []string{"10.0.0.10"},
+			requested: []string{
+				"/openstack/latest/network_data.json",
+				"/openstack/2018-08-27/network_data.json",
+				"/openstack/2017-02-22/network_data.json",
+			},
+		},
+		{
+			name: "base URL with path",
+			documents: map[string]string{
+				"/hcs/openstack/latest/network_data.json": hcsNetworkData,
+			},
+			opts:      Options{BaseURL: "/hcs/", Format: FormatHCS},
+			expected:  []string{"10.0.0.10"},
+			requested: []string{"/hcs/openstack/latest/network_data.json"},
+		},
+		{
+			name:      "no version served",
+			documents: map[string]string{},
+			opts:      Options{Version: DefaultVersion, Format: FormatOpenStack},
+			wantErr:   true,
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			server, requested := newMetadataServer(te.documents)
+			defer server.Close()
+
+			te.opts.BaseURL = server.URL + te.opts.BaseURL
+			data, err := GetNetworkData(te.opts)
+			if te.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", data)
+				}
+				if len(*requested) != len(knownVersions) {
+					t.Fatalf("expected: %v, got: %v", knownVersions, *requested)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected: nil, got: %v", err)
+			}
+			if got := data.IPAddresses(); !reflect.DeepEqual(got, te.expected) {
+				t.Fatalf("expected: %v, got: %v", te.expected, got)
+			}
+			if !reflect.DeepEqual(*requested, te.requested) {
+				t.Fatalf("expected: %v, got: %v", te.requested, *requested)
+			}
+		})
+	}
+}
+
+func TestGetFromMetadataService(t *testing.T) {
+	server, _ := newMetadataServer(map[string]string{
+		"/openstack/2015-10-15/meta_data.json": `{"uuid": "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2", "name": "k8s-a01"}`,
+	})
+	defer server.Close()
+
+	md, err := getFromMetadataService(Options{BaseURL: server.URL, Version: DefaultVersion})
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	if md.UUID != "b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2" {
+		t.Fatalf("expected: b77c45c1-b6cf-4f5e-b072-0ee86daeb6c2, got: %v", md.UUID)
+	}
+}
@@ -17,11 +17,16 @@ limitations under the License.
 package mutexkv
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"k8s.io/klog"
 )
 
+// lockPollInterval is the interval to try the mutex again in LockWithContext.
+const lockPollInterval = 100 * time.Millisecond
+
 // MutexKV is a simple key/value store for arbitrary mutexes. It can be used to
 // serialize changes across arbitrary collaborators that share knowledge of the
 // keys they must serialize on.
@@ -38,6 +43,25 @@ func (m *MutexKV) Lock(key string) {
 	klog.Infof("[DEBUG] Locked %q", key)
 }
 
+// LockWithContext locks the mutex for the given key like Lock, but gives up and returns the error of ctx
+// if ctx is done before the mutex is locked. Caller must call Unlock if nil is returned.
+func (m *MutexKV) LockWithContext(ctx context.Context, key string) error {
+	klog.Infof("[DEBUG] Locking %q", key)
+	mutex := m.get(key)
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for !mutex.TryLock() {
+		select {
+		case <-ctx.Done():
+			klog.Infof("[DEBUG] Gave up locking %q: %v", key, ctx.Err())
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	klog.Infof("[DEBUG] Locked %q", key)
+	return nil
+}
+
 // Unlock the mutex for the given key. Caller must have called Lock for the same key first
 func (m *MutexKV) Unlock(key string) {
 	klog.Infof("[DEBUG] Unlocking %q", key)
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package semaphore
+
+import (
+	"context"
+)
+
+// Semaphore limits the number of goroutines that run simultaneously,
+// the others are queued until a slot is released or their context is done.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// Acquire blocks until a slot is available or the context is done.
+// Caller is responsible for calling Release if no error is returned.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	if s.slots == nil {
+		return ctx.Err()
+	}
+
+	// Fail fast if the context is already done, even if a slot is available.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release releases the slot. Caller must have called Acquire first.
+func (s *Semaphore) Release() {
+	if s.slots == nil {
+		return
+	}
+	<-s.slots
+}
+
+// NewSemaphore returns a Semaphore that allows max goroutines to run simultaneously,
+// there is no limit if max is less than or equal to 0.
+func NewSemaphore(max int) *Semaphore {
+	if max <= 0 {
+		return &Semaphore{}
+	}
+	return &Semaphore{
+		slots: make(chan struct{}, max),
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package semaphore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreBoundsConcurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		workers  int
+		expected int32
+	}{
+		{
+			name:     "one at a time",
+			max:      1,
+			workers:  10,
+			expected: 1,
+		},
+		{
+			name:     "bounded",
+			max:      3,
+			workers:  20,
+			expected: 3,
+		},
+		{
+			name:     "fewer workers than slots",
+			max:      10,
+			workers:  4,
+			expected: 4,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			sem := NewSemaphore(testCase.max)
+			var running, peak int32
+			wg := sync.WaitGroup{}
+			start := make(chan struct{})
+			for i := 0; i < testCase.workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					if err := sem.Acquire(context.Background()); err != nil {
+						t.Errorf("expected: nil, got: %v", err)
+						return
+					}
+					defer sem.Release()
+
+					current := atomic.AddInt32(&running, 1)
+					for {
+						old := atomic.LoadInt32(&peak)
+						if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if peak != testCase.expected {
+				t.Fatalf("expected: %v, got: %v", testCase.expected, peak)
+			}
+		})
+	}
+}
+
+func TestSemaphoreCanceledWhileQueued(t *testing.T) {
+	sem := NewSemaphore(1)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- sem.Acquire(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("expected to be queued, got: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the queued goroutine to return after the context is canceled")
+	}
+
+	// The slot is still held, releasing it makes it available again.
+	sem.Release()
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+	sem.Release()
+}
+
+func TestSemaphoreUnlimited(t *testing.T) {
+	sem := NewSemaphore(0)
+	for i := 0; i < 100; i++ {
+		if err := sem.Acquire(context.Background()); err != nil {
+			t.Fatalf("expected: nil, got: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sem.Acquire(ctx); err != context.Canceled {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+}
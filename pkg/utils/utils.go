@@ -61,6 +61,15 @@ func ToString(val any) string {
 	}
 }
 
+// Redact masks a sensitive value, such as the AK/SK, security token or SSH key data,
+// so that it can be safely written to the logs.
+func Redact(val string) string {
+	if val == "" {
+		return ""
+	}
+	return "***"
+}
+
 func LookupHost(domain string) []string {
 	ns, err := net.LookupHost(domain)
 	if err != nil {
@@ -18,6 +18,7 @@ package utils
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"k8s.io/utils/pointer"
@@ -202,3 +203,53 @@ func TestToJsonStr(t *testing.T) {
 		})
 	}
 }
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{
+			name:     "empty",
+			value:    "",
+			expected: "",
+		},
+		{
+			name:     "secret key",
+			value:    "Xq8fK2mR7pLwZ3nV",
+			expected: "***",
+		},
+		{
+			name:     "ssh key",
+			value:    "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC7 user@host",
+			expected: "***",
+		},
+	}
+
+	for _, te := range tests {
+		t.Run(te.name, func(t *testing.T) {
+			rst := Redact(te.value)
+			if rst != te.expected {
+				t.Fatalf("expected: %v, got : %v", te.expected, rst)
+			}
+		})
+	}
+}
+
+func TestFormatHeadersRedacted(t *testing.T) {
+	const token = "MIIEhgYJKoZIhvcNAQcCoIIEdzCCBHMCAQExDTALBglghkgBZQMEAgEw"
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("X-Auth-Token", token)
+	headers.Set("X-Security-Token", token)
+	headers.Set("Authorization", "SDK-HMAC-SHA256 Access="+token)
+
+	rst := FormatHeaders(headers, "\n")
+	if strings.Contains(rst, token) {
+		t.Fatalf("expected sensitive headers to be redacted, got : %v", rst)
+	}
+	if !strings.Contains(rst, "Content-Type: application/json") {
+		t.Fatalf("expected: Content-Type header to be kept, got : %v", rst)
+	}
+}
@@ -291,7 +291,7 @@ func checkWebAvailable(ingress string) {
 }
 
 func getAZ() string {
-	mData, err := metadata.Get(metadata.MetadataID)
+	mData, err := metadata.Get(metadata.MetadataID, metadata.Options{})
 	if err != nil {
 		panic(fmt.Sprintf("failed to read from metadata: %s", err))
 	}